@@ -15,13 +15,19 @@
 package bufcli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/bufbuild/buf/private/buf/bufwkt/bufwktstore"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufgitdep"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagestore"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduleapi"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulecache"
@@ -30,7 +36,9 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufregistryapi/bufregistryapiowner"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
 	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/git"
 	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/storage"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 )
 
@@ -52,6 +60,9 @@ var (
 		v3CacheCommitsRelDirPath,
 		v3CacheWKTRelDirPath,
 		v3CacheModuleLockRelDirPath,
+		v3CacheGitDepsRelDirPath,
+		v3CacheGitDepLockRelDirPath,
+		v3CacheImagesRelDirPath,
 	}
 
 	// v1CacheModuleDataRelDirPath is the relative path to the cache directory where module data
@@ -108,11 +119,33 @@ var (
 	//
 	// Normalized.
 	v3CacheWasmRuntimeRelDirPath = normalpath.Join("v3", "wasmruntime")
+	// v3CacheGitDepsRelDirPath is the relative path to the git dependencies cache directory in its newest iteration.
+	// This directory is used to store the .proto files of git dependencies, keyed by commit.
+	//
+	// Normalized.
+	v3CacheGitDepsRelDirPath = normalpath.Join("v3", "gitdeps")
+	// v3CacheGitDepLockRelDirPath is the relative path to the lock files directory for git dependencies.
+	// This directory is used to store lock files for synchronizing reading and writing git dependencies from the cache.
+	//
+	// Normalized.
+	v3CacheGitDepLockRelDirPath = normalpath.Join("v3", "gitdeplocks")
+	// v3CacheImagesRelDirPath is the relative path to the compiled images cache directory in its newest iteration.
+	// This directory is used to store the compiled files of remote modules, keyed by module digest and compiler version.
+	//
+	// Normalized.
+	v3CacheImagesRelDirPath = normalpath.Join("v3", "images")
 )
 
 // NewModuleDataProvider returns a new ModuleDataProvider while creating the
 // required cache directories.
-func NewModuleDataProvider(container appext.Container) (bufmodule.ModuleDataProvider, error) {
+func NewModuleDataProvider(ctx context.Context, container appext.Container) (bufmodule.ModuleDataProvider, error) {
+	localRegistry, err := newLocalRegistryForEnv(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	if localRegistry != nil {
+		return localRegistry, nil
+	}
 	clientConfig, err := NewConnectClientConfig(container)
 	if err != nil {
 		return nil, err
@@ -130,7 +163,14 @@ func NewModuleDataProvider(container appext.Container) (bufmodule.ModuleDataProv
 
 // NewCommitProvider returns a new CommitProvider while creating the
 // required cache directories.
-func NewCommitProvider(container appext.Container) (bufmodule.CommitProvider, error) {
+func NewCommitProvider(ctx context.Context, container appext.Container) (bufmodule.CommitProvider, error) {
+	localRegistry, err := newLocalRegistryForEnv(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	if localRegistry != nil {
+		return localRegistry, nil
+	}
 	clientConfig, err := NewConnectClientConfig(container)
 	if err != nil {
 		return nil, err
@@ -177,6 +217,70 @@ func NewWKTStore(container appext.Container) (bufwktstore.Store, error) {
 	), nil
 }
 
+// NewGitDepProvider returns a new bufgitdep.Provider while creating the required cache directories.
+func NewGitDepProvider(container appext.Container) (bufgitdep.Provider, error) {
+	if err := createCacheDir(container.CacheDirPath(), v3CacheGitDepsRelDirPath); err != nil {
+		return nil, err
+	}
+	fullCacheDirPath := normalpath.Join(container.CacheDirPath(), v3CacheGitDepsRelDirPath)
+	// No symlinks.
+	storageosProvider := storageos.NewProvider()
+	cacheBucket, err := storageosProvider.NewReadWriteBucket(fullCacheDirPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := createCacheDir(container.CacheDirPath(), v3CacheGitDepLockRelDirPath); err != nil {
+		return nil, err
+	}
+	filelocker, err := filelock.NewLocker(normalpath.Join(container.CacheDirPath(), v3CacheGitDepLockRelDirPath))
+	if err != nil {
+		return nil, err
+	}
+	return bufgitdep.NewProvider(
+		container.Logger(),
+		container,
+		git.NewCloner(
+			container.Logger(),
+			storageosProvider,
+			defaultGitClonerOptions,
+		),
+		cacheBucket,
+		filelocker,
+	), nil
+}
+
+// NewImageFileStore returns a new bufimage.ImageFileStore while creating the required cache directories.
+func NewImageFileStore(container appext.Container) (bufimage.ImageFileStore, error) {
+	if err := createCacheDir(container.CacheDirPath(), v3CacheImagesRelDirPath); err != nil {
+		return nil, err
+	}
+	fullCacheDirPath := normalpath.Join(container.CacheDirPath(), v3CacheImagesRelDirPath)
+	// No symlinks.
+	cacheBucket, err := storageos.NewProvider().NewReadWriteBucket(fullCacheDirPath)
+	if err != nil {
+		return nil, err
+	}
+	return bufimagestore.NewImageFileStore(
+		container.Logger(),
+		cacheBucket,
+	), nil
+}
+
+// newLazyGitDepProvider returns a new bufgitdep.Provider that only creates the required cache
+// directories once git dependencies are requested.
+//
+// Most workspaces have no git dependencies, so we do not want to create the cache directories
+// for every command.
+func newLazyGitDepProvider(container appext.Container) bufgitdep.Provider {
+	return &lazyGitDepProvider{
+		getProvider: sync.OnceValues(
+			func() (bufgitdep.Provider, error) {
+				return NewGitDepProvider(container)
+			},
+		),
+	}
+}
+
 func newModuleDataProvider(
 	container appext.Container,
 	moduleClientProvider bufregistryapimodule.ClientProvider,
@@ -211,6 +315,7 @@ func newModuleDataProvider(
 			container.Logger(),
 			cacheBucket,
 			filelocker,
+			bufmodulestore.ModuleDataStoreWithRecordLastAccess(),
 		),
 	), nil
 }
@@ -271,3 +376,35 @@ func createCacheDir(baseCacheDirPath string, relDirPath string) error {
 	}
 	return nil
 }
+
+type lazyGitDepProvider struct {
+	getProvider func() (bufgitdep.Provider, error)
+}
+
+func (p *lazyGitDepProvider) GetGitDepKeysForGitDepRefs(
+	ctx context.Context,
+	gitDepRefs []bufconfig.GitDepRef,
+) ([]bufconfig.GitDepKey, error) {
+	if len(gitDepRefs) == 0 {
+		return nil, nil
+	}
+	provider, err := p.getProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetGitDepKeysForGitDepRefs(ctx, gitDepRefs)
+}
+
+func (p *lazyGitDepProvider) GetReadBucketsForGitDepKeys(
+	ctx context.Context,
+	gitDepKeys []bufconfig.GitDepKey,
+) ([]storage.ReadBucket, error) {
+	if len(gitDepKeys) == 0 {
+		return nil, nil
+	}
+	provider, err := p.getProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetReadBucketsForGitDepKeys(ctx, gitDepKeys)
+}
@@ -0,0 +1,507 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufgitdep"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagestore"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulestore"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/google/uuid"
+)
+
+const (
+	// CacheEntryKindModule is the kind of a cached module.
+	CacheEntryKindModule = "module"
+	// CacheEntryKindCommit is the kind of a cached commit.
+	CacheEntryKindCommit = "commit"
+	// CacheEntryKindWasm is the kind of a cached compiled Wasm plugin.
+	CacheEntryKindWasm = "wasm"
+	// CacheEntryKindImage is the kind of the cached compiled files of a module.
+	CacheEntryKindImage = "image"
+	// CacheEntryKindGitDep is the kind of the cached files of a commit of a git dependency.
+	CacheEntryKindGitDep = "git"
+)
+
+// ErrCacheEntryNotVerifiable is returned by CacheManager.VerifyCacheEntry if an entry
+// cannot be verified.
+//
+// This is the case for modules if the commit of the module is not cached, for compiled
+// Wasm plugins, which are validated by the Wasm runtime when they are loaded, and for git
+// dependencies, which are verified against the digest in buf.lock when they are used.
+var ErrCacheEntryNotVerifiable = errors.New("cache entry cannot be verified")
+
+// ErrCacheEntryCorrupt is wrapped by errors returned by CacheManager.VerifyCacheEntry if an
+// entry is corrupt.
+var ErrCacheEntryCorrupt = errors.New("cache entry is corrupt")
+
+// CacheEntry is a single entry in the cache.
+type CacheEntry interface {
+	// Kind is the kind of the entry, one of the CacheEntryKind constants.
+	Kind() string
+	// Name is the name of the entry for display.
+	Name() string
+	// Size is the size of the entry in bytes.
+	Size() int64
+	// LastAccessTime is the last time the entry was accessed, or the time the entry was
+	// written if accesses are not recorded for the entry. This is the zero time if unknown.
+	LastAccessTime() time.Time
+
+	isCacheEntry()
+}
+
+// CacheManager lists, verifies, and deletes entries in the module, commit, Wasm runtime,
+// compiled image, and git dependency caches.
+//
+// It is safe to use a CacheManager while other buf processes are using the cache.
+type CacheManager interface {
+	// ListCacheEntries lists all entries in the cache.
+	//
+	// Modules are listed first, then commits, then compiled Wasm plugins, then compiled images,
+	// then git dependencies.
+	ListCacheEntries(ctx context.Context) ([]CacheEntry, error)
+	// VerifyCacheEntry verifies the entry.
+	//
+	// Returns an error wrapping ErrCacheEntryCorrupt if the entry is corrupt, and
+	// ErrCacheEntryNotVerifiable if the entry cannot be verified.
+	VerifyCacheEntry(ctx context.Context, cacheEntry CacheEntry) error
+	// DeleteCacheEntry deletes the entry from the cache.
+	DeleteCacheEntry(ctx context.Context, cacheEntry CacheEntry) error
+}
+
+// NewCacheManager returns a new CacheManager while creating the required cache directories.
+func NewCacheManager(container appext.Container) (CacheManager, error) {
+	for _, relDirPath := range []string{
+		v3CacheModuleRelDirPath,
+		v3CacheCommitsRelDirPath,
+		v3CacheModuleLockRelDirPath,
+		v3CacheWasmRuntimeRelDirPath,
+		v3CacheImagesRelDirPath,
+		v3CacheGitDepsRelDirPath,
+		v3CacheGitDepLockRelDirPath,
+	} {
+		if err := createCacheDir(container.CacheDirPath(), relDirPath); err != nil {
+			return nil, err
+		}
+	}
+	// No symlinks.
+	storageosProvider := storageos.NewProvider()
+	moduleCacheBucket, err := storageosProvider.NewReadWriteBucket(
+		normalpath.Join(container.CacheDirPath(), v3CacheModuleRelDirPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+	commitCacheBucket, err := storageosProvider.NewReadWriteBucket(
+		normalpath.Join(container.CacheDirPath(), v3CacheCommitsRelDirPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+	imageCacheBucket, err := storageosProvider.NewReadWriteBucket(
+		normalpath.Join(container.CacheDirPath(), v3CacheImagesRelDirPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+	gitDepCacheBucket, err := storageosProvider.NewReadWriteBucket(
+		normalpath.Join(container.CacheDirPath(), v3CacheGitDepsRelDirPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+	filelocker, err := filelock.NewLocker(normalpath.Join(container.CacheDirPath(), v3CacheModuleLockRelDirPath))
+	if err != nil {
+		return nil, err
+	}
+	gitDepFilelocker, err := filelock.NewLocker(normalpath.Join(container.CacheDirPath(), v3CacheGitDepLockRelDirPath))
+	if err != nil {
+		return nil, err
+	}
+	return &cacheManager{
+		moduleDataStoreManager: bufmodulestore.NewModuleDataStoreManager(container.Logger(), moduleCacheBucket, filelocker),
+		commitStoreManager:     bufmodulestore.NewCommitStoreManager(container.Logger(), commitCacheBucket),
+		commitStore:            bufmodulestore.NewCommitStore(container.Logger(), commitCacheBucket),
+		wasmRuntimeCacheDirPath: filepath.Join(
+			container.CacheDirPath(),
+			normalpath.Unnormalize(v3CacheWasmRuntimeRelDirPath),
+		),
+		imageFileStoreManager: bufimagestore.NewImageFileStoreManager(container.Logger(), imageCacheBucket),
+		gitDepCacheManager:    bufgitdep.NewCacheManager(container.Logger(), gitDepCacheBucket, gitDepFilelocker),
+	}, nil
+}
+
+// *** PRIVATE ***
+
+type cacheManager struct {
+	moduleDataStoreManager  bufmodulestore.ModuleDataStoreManager
+	commitStoreManager      bufmodulestore.CommitStoreManager
+	commitStore             bufmodulestore.CommitStore
+	wasmRuntimeCacheDirPath string
+	imageFileStoreManager   bufimagestore.ImageFileStoreManager
+	gitDepCacheManager      bufgitdep.CacheManager
+}
+
+func (c *cacheManager) ListCacheEntries(ctx context.Context) ([]CacheEntry, error) {
+	var cacheEntries []CacheEntry
+	moduleDataStoreEntries, err := c.moduleDataStoreManager.ListModuleDataStoreEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, moduleDataStoreEntry := range moduleDataStoreEntries {
+		cacheEntries = append(cacheEntries, newModuleCacheEntry(moduleDataStoreEntry))
+	}
+	commitStoreEntries, err := c.commitStoreManager.ListCommitStoreEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, commitStoreEntry := range commitStoreEntries {
+		cacheEntries = append(cacheEntries, newCommitCacheEntry(commitStoreEntry))
+	}
+	wasmCacheEntries, err := c.listWasmCacheEntries()
+	if err != nil {
+		return nil, err
+	}
+	cacheEntries = append(cacheEntries, wasmCacheEntries...)
+	imageFileStoreEntries, err := c.imageFileStoreManager.ListImageFileStoreEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, imageFileStoreEntry := range imageFileStoreEntries {
+		cacheEntries = append(cacheEntries, newImageCacheEntry(imageFileStoreEntry))
+	}
+	gitDepCacheEntries, err := c.gitDepCacheManager.ListCacheEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, gitDepCacheEntry := range gitDepCacheEntries {
+		cacheEntries = append(cacheEntries, newGitDepCacheEntry(gitDepCacheEntry))
+	}
+	return cacheEntries, nil
+}
+
+func (c *cacheManager) VerifyCacheEntry(ctx context.Context, cacheEntry CacheEntry) error {
+	switch t := cacheEntry.(type) {
+	case *moduleCacheEntry:
+		expectedDigest, err := c.getExpectedDigestForModuleDataStoreEntry(ctx, t.moduleDataStoreEntry)
+		if err != nil {
+			return err
+		}
+		// Without an expected Digest, this still verifies that the entry was completely written.
+		if err := c.moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, t.moduleDataStoreEntry, expectedDigest); err != nil {
+			return wrapCorruptStoreEntryError(err, bufmodulestore.ErrCorruptStoreEntry)
+		}
+		if expectedDigest == nil {
+			return ErrCacheEntryNotVerifiable
+		}
+		return nil
+	case *commitCacheEntry:
+		return wrapCorruptStoreEntryError(
+			c.commitStoreManager.VerifyCommitStoreEntry(ctx, t.commitStoreEntry),
+			bufmodulestore.ErrCorruptStoreEntry,
+		)
+	case *wasmCacheEntry:
+		return ErrCacheEntryNotVerifiable
+	case *imageCacheEntry:
+		return wrapCorruptStoreEntryError(
+			c.imageFileStoreManager.VerifyImageFileStoreEntry(ctx, t.imageFileStoreEntry),
+			bufimagestore.ErrCorruptStoreEntry,
+		)
+	case *gitDepCacheEntry:
+		return ErrCacheEntryNotVerifiable
+	default:
+		return syserror.Newf("unknown CacheEntry type: %T", cacheEntry)
+	}
+}
+
+func (c *cacheManager) DeleteCacheEntry(ctx context.Context, cacheEntry CacheEntry) error {
+	switch t := cacheEntry.(type) {
+	case *moduleCacheEntry:
+		return c.moduleDataStoreManager.DeleteModuleDataStoreEntry(ctx, t.moduleDataStoreEntry)
+	case *commitCacheEntry:
+		return c.commitStoreManager.DeleteCommitStoreEntry(ctx, t.commitStoreEntry)
+	case *wasmCacheEntry:
+		// The Wasm runtime writes files atomically and treats a missing file as a cache miss,
+		// so no locking is required.
+		if err := os.Remove(t.filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	case *imageCacheEntry:
+		return c.imageFileStoreManager.DeleteImageFileStoreEntry(ctx, t.imageFileStoreEntry)
+	case *gitDepCacheEntry:
+		return c.gitDepCacheManager.DeleteCacheEntry(ctx, t.gitDepCacheEntry)
+	default:
+		return syserror.Newf("unknown CacheEntry type: %T", cacheEntry)
+	}
+}
+
+// getExpectedDigestForModuleDataStoreEntry returns the Digest of the cached commit of the module,
+// or nil if the commit is not cached.
+//
+// The commit is found by the registry, commit ID, and digest type of the entry, so the Digest
+// does not depend on the contents of the entry.
+func (c *cacheManager) getExpectedDigestForModuleDataStoreEntry(
+	ctx context.Context,
+	moduleDataStoreEntry bufmodulestore.ModuleDataStoreEntry,
+) (bufmodule.Digest, error) {
+	commitKey, err := bufmodule.NewCommitKey(
+		moduleDataStoreEntry.ModuleFullName().Registry(),
+		moduleDataStoreEntry.CommitID(),
+		moduleDataStoreEntry.DigestType(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	commits, _, err := c.commitStore.GetCommitsForCommitKeys(ctx, []bufmodule.CommitKey{commitKey})
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) != 1 {
+		return nil, nil
+	}
+	return commits[0].ModuleKey().Digest()
+}
+
+// wrapCorruptStoreEntryError wraps the error with ErrCacheEntryCorrupt if it wraps the
+// corrupt entry error of the store that returned it.
+func wrapCorruptStoreEntryError(err error, corruptStoreEntryErr error) error {
+	if errors.Is(err, corruptStoreEntryErr) {
+		return fmt.Errorf("%w: %w", ErrCacheEntryCorrupt, err)
+	}
+	return err
+}
+
+func (c *cacheManager) listWasmCacheEntries() ([]CacheEntry, error) {
+	var cacheEntries []CacheEntry
+	if err := filepath.WalkDir(
+		c.wasmRuntimeCacheDirPath,
+		func(path string, dirEntry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Skip files that are still being written by the Wasm runtime.
+			if !dirEntry.Type().IsRegular() || strings.HasSuffix(path, ".tmp") {
+				return nil
+			}
+			fileInfo, err := dirEntry.Info()
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(c.wasmRuntimeCacheDirPath, path)
+			if err != nil {
+				return err
+			}
+			cacheEntries = append(
+				cacheEntries,
+				&wasmCacheEntry{
+					name:     normalpath.Normalize(relPath),
+					filePath: path,
+					size:     fileInfo.Size(),
+					// Compiled plugins are never rewritten, so this is the time the plugin was compiled.
+					lastAccessTime: fileInfo.ModTime().UTC(),
+				},
+			)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	return cacheEntries, nil
+}
+
+type moduleCacheEntry struct {
+	moduleDataStoreEntry bufmodulestore.ModuleDataStoreEntry
+}
+
+func newModuleCacheEntry(moduleDataStoreEntry bufmodulestore.ModuleDataStoreEntry) *moduleCacheEntry {
+	return &moduleCacheEntry{
+		moduleDataStoreEntry: moduleDataStoreEntry,
+	}
+}
+
+func (*moduleCacheEntry) Kind() string {
+	return CacheEntryKindModule
+}
+
+func (m *moduleCacheEntry) Name() string {
+	return fmt.Sprintf(
+		"%s:%s (%v)",
+		m.moduleDataStoreEntry.ModuleFullName().String(),
+		uuidutil.ToDashless(m.moduleDataStoreEntry.CommitID()),
+		m.moduleDataStoreEntry.DigestType(),
+	)
+}
+
+func (m *moduleCacheEntry) Size() int64 {
+	return m.moduleDataStoreEntry.Size()
+}
+
+func (m *moduleCacheEntry) LastAccessTime() time.Time {
+	return m.moduleDataStoreEntry.LastAccessTime()
+}
+
+func (*moduleCacheEntry) isCacheEntry() {}
+
+type commitCacheEntry struct {
+	commitStoreEntry bufmodulestore.CommitStoreEntry
+}
+
+func newCommitCacheEntry(commitStoreEntry bufmodulestore.CommitStoreEntry) *commitCacheEntry {
+	return &commitCacheEntry{
+		commitStoreEntry: commitStoreEntry,
+	}
+}
+
+func (*commitCacheEntry) Kind() string {
+	return CacheEntryKindCommit
+}
+
+func (c *commitCacheEntry) Name() string {
+	commitKey := c.commitStoreEntry.CommitKey()
+	name := commitKey.Registry()
+	if moduleFullName := c.commitStoreEntry.ModuleFullName(); moduleFullName != nil {
+		name = moduleFullName.String()
+	}
+	return fmt.Sprintf(
+		"%s:%s (%v)",
+		name,
+		uuidutil.ToDashless(commitKey.CommitID()),
+		commitKey.DigestType(),
+	)
+}
+
+func (c *commitCacheEntry) Size() int64 {
+	return c.commitStoreEntry.Size()
+}
+
+func (c *commitCacheEntry) LastAccessTime() time.Time {
+	return c.commitStoreEntry.LastAccessTime()
+}
+
+func (*commitCacheEntry) isCacheEntry() {}
+
+type wasmCacheEntry struct {
+	name           string
+	filePath       string
+	size           int64
+	lastAccessTime time.Time
+}
+
+func (*wasmCacheEntry) Kind() string {
+	return CacheEntryKindWasm
+}
+
+func (w *wasmCacheEntry) Name() string {
+	return w.name
+}
+
+func (w *wasmCacheEntry) Size() int64 {
+	return w.size
+}
+
+func (w *wasmCacheEntry) LastAccessTime() time.Time {
+	return w.lastAccessTime
+}
+
+func (*wasmCacheEntry) isCacheEntry() {}
+
+type imageCacheEntry struct {
+	imageFileStoreEntry bufimagestore.ImageFileStoreEntry
+}
+
+func newImageCacheEntry(imageFileStoreEntry bufimagestore.ImageFileStoreEntry) *imageCacheEntry {
+	return &imageCacheEntry{
+		imageFileStoreEntry: imageFileStoreEntry,
+	}
+}
+
+func (*imageCacheEntry) Kind() string {
+	return CacheEntryKindImage
+}
+
+func (i *imageCacheEntry) Name() string {
+	// Modules without a name are identified by the digest the entry is keyed by.
+	name := i.imageFileStoreEntry.ModuleDigest().String()
+	if moduleFullName := i.imageFileStoreEntry.ModuleFullName(); moduleFullName != nil {
+		name = moduleFullName.String()
+		if commitID := i.imageFileStoreEntry.CommitID(); commitID != uuid.Nil {
+			name += ":" + uuidutil.ToDashless(commitID)
+		}
+	}
+	source := "source"
+	if i.imageFileStoreEntry.ExcludeSourceCodeInfo() {
+		source = "nosource"
+	}
+	return fmt.Sprintf(
+		"%s (%s, %s)",
+		name,
+		i.imageFileStoreEntry.CompilerVersion(),
+		source,
+	)
+}
+
+func (i *imageCacheEntry) Size() int64 {
+	return i.imageFileStoreEntry.Size()
+}
+
+func (i *imageCacheEntry) LastAccessTime() time.Time {
+	return i.imageFileStoreEntry.LastAccessTime()
+}
+
+func (*imageCacheEntry) isCacheEntry() {}
+
+type gitDepCacheEntry struct {
+	gitDepCacheEntry bufgitdep.CacheEntry
+}
+
+func newGitDepCacheEntry(cacheEntry bufgitdep.CacheEntry) *gitDepCacheEntry {
+	return &gitDepCacheEntry{
+		gitDepCacheEntry: cacheEntry,
+	}
+}
+
+func (*gitDepCacheEntry) Kind() string {
+	return CacheEntryKindGitDep
+}
+
+func (g *gitDepCacheEntry) Name() string {
+	return g.gitDepCacheEntry.Commit()
+}
+
+func (g *gitDepCacheEntry) Size() int64 {
+	return g.gitDepCacheEntry.Size()
+}
+
+func (g *gitDepCacheEntry) LastAccessTime() time.Time {
+	return g.gitDepCacheEntry.ModTime()
+}
+
+func (*gitDepCacheEntry) isCacheEntry() {}
@@ -15,15 +15,19 @@
 package bufcli
 
 import (
+	"context"
+
 	"github.com/bufbuild/buf/private/buf/bufctl"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduleapi"
 	"github.com/bufbuild/buf/private/bufpkg/bufregistryapi/bufregistryapimodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufregistryapi/bufregistryapiowner"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/slogext"
 )
 
 // NewController returns a new Controller.
 func NewController(
+	ctx context.Context,
 	container appext.Container,
 	options ...bufctl.ControllerOption,
 ) (bufctl.Controller, error) {
@@ -33,6 +37,44 @@ func NewController(
 			bufctl.WithCopyToInMemory(),
 		)
 	}
+	wktStore, err := NewWKTStore(container)
+	if err != nil {
+		return nil, err
+	}
+	gitDepProvider := newLazyGitDepProvider(container)
+	if container.Env(disableImageCacheEnvKey) == "" {
+		// The image cache only speeds up builds, so we build without it if it cannot be created,
+		// for example if the cache directory is not writable.
+		imageFileStore, err := NewImageFileStore(container)
+		if err != nil {
+			container.Logger().DebugContext(ctx, "image cache disabled", slogext.ErrorAttr(err))
+		} else {
+			options = append(
+				options,
+				bufctl.WithImageFileStore(imageFileStore),
+			)
+		}
+	}
+	localRegistry, err := newLocalRegistryForEnv(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	if localRegistry != nil {
+		return bufctl.NewController(
+			container.Logger(),
+			container,
+			localRegistry,
+			localRegistry,
+			localRegistry,
+			localRegistry,
+			gitDepProvider,
+			wktStore,
+			defaultHTTPClient,
+			defaultHTTPAuthenticator,
+			defaultGitClonerOptions,
+			options...,
+		)
+	}
 	clientConfig, err := NewConnectClientConfig(container)
 	if err != nil {
 		return nil, err
@@ -47,10 +89,6 @@ func NewController(
 	if err != nil {
 		return nil, err
 	}
-	wktStore, err := NewWKTStore(container)
-	if err != nil {
-		return nil, err
-	}
 	return bufctl.NewController(
 		container.Logger(),
 		container,
@@ -58,6 +96,7 @@ func NewController(
 		bufmoduleapi.NewModuleKeyProvider(container.Logger(), moduleClientProvider),
 		moduleDataProvider,
 		commitProvider,
+		gitDepProvider,
 		wktStore,
 		// TODO FUTURE: Delete defaultHTTPClient and use the one from newConfig
 		defaultHTTPClient,
@@ -38,6 +38,18 @@ const (
 	// at a per-file level.
 	copyToInMemoryEnvKey = "BUF_BETA_COPY_FILES_TO_MEMORY"
 
+	// disableImageCacheEnvKey disables the cache of compiled files of remote modules, so that
+	// all files are compiled from source.
+	disableImageCacheEnvKey = "BUF_DISABLE_IMAGE_CACHE"
+
+	// localRegistryEnvKey is a directory, file:// URL, or tarball of a local registry to
+	// resolve dependencies against instead of the BSR.
+	//
+	// This applies to all dependencies. Dependencies are still referred to by module name and
+	// commit in buf.yaml and buf.lock, so that the same configuration resolves against either
+	// the BSR or a local mirror of it, without paths of the local machine in buf.yaml.
+	localRegistryEnvKey = "BUF_LOCAL_REGISTRY"
+
 	// This should only be used for testing. This is not part of Buf's API, and should
 	// never be documented or part of Buf's contract.
 	legacyFederationRegistryEnvKey = "BUF_TESTING_LEGACY_FEDERATION_REGISTRY"
@@ -100,6 +100,18 @@ If specified multiple times, the union is taken`,
 	)
 }
 
+// BindAffectedSince binds the affected-since flag.
+func BindAffectedSince(flagSet *pflag.FlagSet, addr *string, flagName string) {
+	flagSet.StringVar(
+		addr,
+		flagName,
+		"",
+		`Limit to the modules affected by the changes since the given git ref, e.g. "main" or "HEAD~1"
+A module is affected if its files changed, or if it transitively depends on an affected module
+Only valid for directory inputs within a git checkout`,
+	)
+}
+
 // BindInputHashtag binds the input hashtag flag.
 //
 // This needs to be added to any command that has the input as the first argument.
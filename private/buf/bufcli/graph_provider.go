@@ -15,6 +15,8 @@
 package bufcli
 
 import (
+	"context"
+
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduleapi"
 	"github.com/bufbuild/buf/private/bufpkg/bufregistryapi/bufregistryapimodule"
@@ -23,7 +25,14 @@ import (
 )
 
 // NewGraphProvider returns a new GraphProvider.
-func NewGraphProvider(container appext.Container) (bufmodule.GraphProvider, error) {
+func NewGraphProvider(ctx context.Context, container appext.Container) (bufmodule.GraphProvider, error) {
+	localRegistry, err := newLocalRegistryForEnv(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	if localRegistry != nil {
+		return localRegistry, nil
+	}
 	clientConfig, err := NewConnectClientConfig(container)
 	if err != nil {
 		return nil, err
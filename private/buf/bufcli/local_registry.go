@@ -0,0 +1,106 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufcli
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduledir"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagearchive"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+)
+
+// NewLocalRegistry returns a new local registry for the location.
+//
+// The location is either a directory path or a file:// URL. If the location is a tarball
+// ending in .tar, .tar.gz, or .tgz, it is read into memory, and any uploads to the returned
+// registry are discarded.
+func NewLocalRegistry(
+	ctx context.Context,
+	container appext.Container,
+	location string,
+) (bufmoduledir.Registry, error) {
+	dirPath := strings.TrimPrefix(location, "file://")
+	if dirPath == "" {
+		return nil, errors.New("local registry location is empty")
+	}
+	if IsLocalRegistryTarball(dirPath) {
+		bucket, err := readLocalRegistryTarball(ctx, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return bufmoduledir.NewRegistry(container.Logger(), bucket), nil
+	}
+	bucket, err := storageos.NewProvider().NewReadWriteBucket(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	return bufmoduledir.NewRegistry(container.Logger(), bucket), nil
+}
+
+// IsLocalRegistryTarball returns true if the local registry location refers to a tarball.
+func IsLocalRegistryTarball(location string) bool {
+	return strings.HasSuffix(location, ".tar") ||
+		strings.HasSuffix(location, ".tar.gz") ||
+		strings.HasSuffix(location, ".tgz")
+}
+
+// *** PRIVATE ***
+
+// newLocalRegistryForEnv returns the local registry configured by the localRegistryEnvKey
+// environment variable, or nil if the environment variable is not set.
+//
+// A new registry is returned on every call, so a tarball registry is read once per caller.
+func newLocalRegistryForEnv(ctx context.Context, container appext.Container) (bufmoduledir.Registry, error) {
+	location := container.Env(localRegistryEnvKey)
+	if location == "" {
+		return nil, nil
+	}
+	return NewLocalRegistry(ctx, container, location)
+}
+
+func readLocalRegistryTarball(ctx context.Context, filePath string) (_ storage.ReadWriteBucket, retErr error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, file.Close())
+	}()
+	var reader io.Reader = file
+	if !strings.HasSuffix(filePath, ".tar") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			retErr = errors.Join(retErr, gzipReader.Close())
+		}()
+		reader = gzipReader
+	}
+	bucket := storagemem.NewReadWriteBucket()
+	if err := storagearchive.Untar(ctx, reader, bucket); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
@@ -15,6 +15,8 @@
 package bufcli
 
 import (
+	"context"
+
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduleapi"
 	"github.com/bufbuild/buf/private/bufpkg/bufregistryapi/bufregistryapimodule"
@@ -22,7 +24,14 @@ import (
 )
 
 // NewModuleKeyProvider returns a new ModuleKeyProvider.
-func NewModuleKeyProvider(container appext.Container) (bufmodule.ModuleKeyProvider, error) {
+func NewModuleKeyProvider(ctx context.Context, container appext.Container) (bufmodule.ModuleKeyProvider, error) {
+	localRegistry, err := newLocalRegistryForEnv(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	if localRegistry != nil {
+		return localRegistry, nil
+	}
 	clientConfig, err := NewConnectClientConfig(container)
 	if err != nil {
 		return nil, err
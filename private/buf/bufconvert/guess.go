@@ -0,0 +1,114 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconvert
+
+import (
+	"sort"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TypeGuess is a message type that the wire format data of a message may be of.
+type TypeGuess struct {
+	// Name is the full name of the message type.
+	Name protoreflect.FullName
+	// RecognizedBytes is the number of bytes of the data that are fields of the
+	// message type, or of the messages it contains.
+	RecognizedBytes int
+	// TotalBytes is the number of bytes of the data.
+	TotalBytes int
+}
+
+// GuessMessageTypes returns the message types of the image that the wire format data
+// may be of.
+//
+// The data is unmarshaled into each message type of the image, and the message types
+// that recognize at least one field are returned. They are ranked by the number of bytes
+// of the data that they recognize. Ties are ranked by the number of fields of the message
+// type, so that the message type with the fewest unset fields comes first, and then by name.
+func GuessMessageTypes(image bufimage.Image, data []byte) []TypeGuess {
+	resolver := image.Resolver()
+	unmarshaler := protoencoding.NewWireUnmarshaler(resolver)
+	var typeGuesses []TypeGuess
+	numFields := make(map[protoreflect.FullName]int)
+	for _, messageDescriptor := range getMessageDescriptors(image) {
+		// The resolver may refuse to resolve some message types, for example if the image
+		// does not support the message-set wire format.
+		messageType, err := resolver.FindMessageByName(messageDescriptor.FullName())
+		if err != nil {
+			continue
+		}
+		message := messageType.New().Interface()
+		// The data may not be valid for this message type, for example if a string
+		// field has invalid UTF-8.
+		if err := unmarshaler.Unmarshal(data, message); err != nil {
+			continue
+		}
+		recognizedBytes := len(data) - protoencoding.CountUnrecognized(message.ProtoReflect())
+		if recognizedBytes <= 0 {
+			continue
+		}
+		typeGuesses = append(
+			typeGuesses,
+			TypeGuess{
+				Name:            messageDescriptor.FullName(),
+				RecognizedBytes: recognizedBytes,
+				TotalBytes:      len(data),
+			},
+		)
+		numFields[messageDescriptor.FullName()] = messageDescriptor.Fields().Len()
+	}
+	sort.Slice(
+		typeGuesses,
+		func(i int, j int) bool {
+			one, two := typeGuesses[i], typeGuesses[j]
+			if one.RecognizedBytes != two.RecognizedBytes {
+				return one.RecognizedBytes > two.RecognizedBytes
+			}
+			if numFields[one.Name] != numFields[two.Name] {
+				return numFields[one.Name] < numFields[two.Name]
+			}
+			return one.Name < two.Name
+		},
+	)
+	return typeGuesses
+}
+
+// getMessageDescriptors returns all messages declared in the image, including nested
+// messages, but excluding map entries.
+func getMessageDescriptors(image bufimage.Image) []protoreflect.MessageDescriptor {
+	var messageDescriptors []protoreflect.MessageDescriptor
+	var addMessageDescriptors func(protoreflect.MessageDescriptors)
+	addMessageDescriptors = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			messageDescriptor := messages.Get(i)
+			if messageDescriptor.IsMapEntry() {
+				continue
+			}
+			messageDescriptors = append(messageDescriptors, messageDescriptor)
+			addMessageDescriptors(messageDescriptor.Messages())
+		}
+	}
+	for _, imageFile := range image.Files() {
+		fileDescriptor, err := image.Resolver().FindFileByPath(imageFile.Path())
+		if err != nil {
+			continue
+		}
+		addMessageDescriptors(fileDescriptor.Messages())
+	}
+	return messageDescriptors
+}
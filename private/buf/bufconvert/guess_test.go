@@ -0,0 +1,49 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconvert
+
+import (
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagetesting"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestGuessMessageTypes(t *testing.T) {
+	t.Parallel()
+	image := bufimagetesting.BuildImage(
+		t,
+		[]bufmoduletesting.ModuleData{{DirPath: "testdata"}},
+		bufimage.WithExcludeSourceCodeInfo(),
+	)
+
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, 7)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendString(data, "label")
+	require.Equal(
+		t,
+		[]TypeGuess{
+			{Name: "guess.Event", RecognizedBytes: 9, TotalBytes: 9},
+			{Name: "guess.EventWithTime", RecognizedBytes: 9, TotalBytes: 9},
+			{Name: "guess.ID", RecognizedBytes: 2, TotalBytes: 9},
+		},
+		GuessMessageTypes(image, data),
+	)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconvert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxRawDepth is the maximum depth of nested messages and groups that are decoded.
+//
+// Deeper length-delimited fields are printed as bytes.
+const maxRawDepth = 64
+
+// WriteRawMessage writes the fields of the wire format data to the writer, without a
+// schema, in the same form as protoc --decode_raw.
+//
+// Each field is written as its field number and value. Varints are written as unsigned
+// integers, fixed-width fields as hexadecimal, and length-delimited fields as nested
+// messages if they can be decoded as one, and as quoted strings otherwise.
+func WriteRawMessage(writer io.Writer, data []byte) error {
+	rawFields, err := parseRawFields(data, 0)
+	if err != nil {
+		return err
+	}
+	buffer := bytes.NewBuffer(nil)
+	writeRawFields(buffer, rawFields, 0)
+	_, err = writer.Write(buffer.Bytes())
+	return err
+}
+
+type rawField struct {
+	number   protowire.Number
+	wireType protowire.Type
+	// value is set for varint and fixed-width fields.
+	value uint64
+	// bytes is set for length-delimited fields that are not messages.
+	bytes []byte
+	// fields is set for groups and length-delimited fields that are messages.
+	fields []*rawField
+}
+
+func parseRawFields(data []byte, depth int) ([]*rawField, error) {
+	var rawFields []*rawField
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		rawField := &rawField{
+			number:   number,
+			wireType: wireType,
+		}
+		switch wireType {
+		case protowire.VarintType:
+			rawField.value, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed32Type:
+			var value uint32
+			value, n = protowire.ConsumeFixed32(data)
+			rawField.value = uint64(value)
+		case protowire.Fixed64Type:
+			rawField.value, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			rawField.bytes, n = protowire.ConsumeBytes(data)
+			if n >= 0 && len(rawField.bytes) > 0 && depth < maxRawDepth {
+				// Any length-delimited field that decodes is assumed to be a message, as
+				// there is no schema to tell strings and bytes apart from messages.
+				if fields, err := parseRawFields(rawField.bytes, depth+1); err == nil {
+					rawField.bytes = nil
+					rawField.fields = fields
+				}
+			}
+		case protowire.StartGroupType:
+			var groupData []byte
+			groupData, n = protowire.ConsumeGroup(number, data)
+			if n >= 0 {
+				if depth >= maxRawDepth {
+					return nil, errors.New("groups are nested too deeply")
+				}
+				// ConsumeGroup returns the data of the group without its end tag.
+				fields, err := parseRawFields(groupData, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				rawField.fields = fields
+			}
+		case protowire.EndGroupType:
+			return nil, fmt.Errorf("unexpected end of group %d", number)
+		default:
+			return nil, fmt.Errorf("invalid wire type %d for field %d", wireType, number)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("field %d: %w", number, protowire.ParseError(n))
+		}
+		data = data[n:]
+		rawFields = append(rawFields, rawField)
+	}
+	return rawFields, nil
+}
+
+func writeRawFields(buffer *bytes.Buffer, rawFields []*rawField, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, rawField := range rawFields {
+		switch {
+		case rawField.fields != nil || rawField.wireType == protowire.StartGroupType:
+			fmt.Fprintf(buffer, "%s%d {\n", indent, rawField.number)
+			writeRawFields(buffer, rawField.fields, depth+1)
+			fmt.Fprintf(buffer, "%s}\n", indent)
+		case rawField.wireType == protowire.VarintType:
+			fmt.Fprintf(buffer, "%s%d: %d\n", indent, rawField.number, rawField.value)
+		case rawField.wireType == protowire.Fixed32Type:
+			fmt.Fprintf(buffer, "%s%d: 0x%08x\n", indent, rawField.number, rawField.value)
+		case rawField.wireType == protowire.Fixed64Type:
+			fmt.Fprintf(buffer, "%s%d: 0x%016x\n", indent, rawField.number, rawField.value)
+		default:
+			fmt.Fprintf(buffer, "%s%d: %s\n", indent, rawField.number, strconv.Quote(string(rawField.bytes)))
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconvert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestWriteRawMessage(t *testing.T) {
+	t.Parallel()
+	var inner []byte
+	inner = protowire.AppendTag(inner, 1, protowire.VarintType)
+	inner = protowire.AppendVarint(inner, 150)
+	inner = protowire.AppendTag(inner, 2, protowire.Fixed32Type)
+	inner = protowire.AppendFixed32(inner, 5)
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, "hello world")
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, inner)
+	data = protowire.AppendTag(data, 3, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 0x3ff8000000000000)
+	data = protowire.AppendTag(data, 4, protowire.StartGroupType)
+	data = protowire.AppendTag(data, 5, protowire.VarintType)
+	data = protowire.AppendVarint(data, 1)
+	data = protowire.AppendTag(data, 4, protowire.EndGroupType)
+	data = protowire.AppendTag(data, 6, protowire.BytesType)
+	data = protowire.AppendBytes(data, nil)
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, WriteRawMessage(buffer, data))
+	require.Equal(
+		t,
+		`1: "hello world"
+2 {
+  1: 150
+  2: 0x00000005
+}
+3: 0x3ff8000000000000
+4 {
+  5: 1
+}
+6: ""
+`,
+		buffer.String(),
+	)
+}
+
+func TestWriteRawMessageInvalid(t *testing.T) {
+	t.Parallel()
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendVarint(data, 10)
+	data = append(data, "short"...)
+	require.Error(t, WriteRawMessage(bytes.NewBuffer(nil), data))
+	data = protowire.AppendTag(nil, 1, protowire.EndGroupType)
+	require.Error(t, WriteRawMessage(bytes.NewBuffer(nil), data))
+}
@@ -26,10 +26,12 @@ import (
 
 	"buf.build/go/protoyaml"
 	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/buf/buftarget"
 	"github.com/bufbuild/buf/private/buf/bufwkt/bufwktstore"
 	"github.com/bufbuild/buf/private/buf/bufworkspace"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufgitdep"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimageutil"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
@@ -61,6 +63,49 @@ type ImageWithConfig interface {
 	isImageWithConfig()
 }
 
+// MessageReader reads the messages of a message stream.
+type MessageReader interface {
+	// MessageEncoding returns the MessageEncoding of the stream.
+	MessageEncoding() buffetch.MessageEncoding
+	// Read reads the next message of the stream.
+	//
+	// Returns io.EOF when there are no more messages.
+	//
+	// Returns a *RecordError if the record could not be unmarshaled or validated. In this
+	// case, the record is skipped, and the next call to Read reads the next record.
+	// Any other error is not recoverable.
+	Read() (proto.Message, error)
+	io.Closer
+}
+
+// MessageWriter writes the messages of a message stream.
+type MessageWriter interface {
+	// Write writes the next message of the stream.
+	Write(message proto.Message) error
+	// Close flushes and closes the stream.
+	io.Closer
+}
+
+// RecordError is an error for a single record of a message stream.
+type RecordError struct {
+	// Index is the index of the record in the stream, starting at 1.
+	Index int
+	// Offset is the byte offset of the record in the stream.
+	Offset int64
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (r *RecordError) Error() string {
+	return fmt.Sprintf("record %d at offset %d: %v", r.Index, r.Offset, r.Err)
+}
+
+// Unwrap returns the underlying error.
+func (r *RecordError) Unwrap() error {
+	return r.Err
+}
+
 type Controller interface {
 	GetWorkspace(
 		ctx context.Context,
@@ -126,6 +171,36 @@ type Controller interface {
 		defaultMessageEncoding buffetch.MessageEncoding,
 		options ...FunctionOption,
 	) error
+	// GetMessageData gets the wire format data of the message input, without unmarshaling it.
+	//
+	// The input must have the binpb message encoding.
+	GetMessageData(
+		ctx context.Context,
+		messageInput string,
+		options ...FunctionOption,
+	) ([]byte, error)
+	// GetMessageReader gets a MessageReader for the message stream input.
+	//
+	// The input must have a message stream encoding. Records are read as they are needed,
+	// so streams of any size can be read.
+	GetMessageReader(
+		ctx context.Context,
+		schemaImage bufimage.Image,
+		messageInput string,
+		typeName string,
+		defaultMessageEncoding buffetch.MessageEncoding,
+		options ...FunctionOption,
+	) (MessageReader, error)
+	// GetMessageWriter gets a MessageWriter for the message stream output.
+	//
+	// The output must have a message stream encoding.
+	GetMessageWriter(
+		ctx context.Context,
+		schemaImage bufimage.Image,
+		messageOutput string,
+		defaultMessageEncoding buffetch.MessageEncoding,
+		options ...FunctionOption,
+	) (MessageWriter, error)
 }
 
 func NewController(
@@ -135,6 +210,7 @@ func NewController(
 	moduleKeyProvider bufmodule.ModuleKeyProvider,
 	moduleDataProvider bufmodule.ModuleDataProvider,
 	commitProvider bufmodule.CommitProvider,
+	gitDepProvider bufgitdep.Provider,
 	wktStore bufwktstore.Store,
 	httpClient *http.Client,
 	httpauthAuthenticator httpauth.Authenticator,
@@ -148,6 +224,7 @@ func NewController(
 		moduleKeyProvider,
 		moduleDataProvider,
 		commitProvider,
+		gitDepProvider,
 		wktStore,
 		httpClient,
 		httpauthAuthenticator,
@@ -175,6 +252,7 @@ type controller struct {
 	fileAnnotationErrorFormat string
 	fileAnnotationsToStdout   bool
 	copyToInMemory            bool
+	imageFileStore            bufimage.ImageFileStore
 
 	storageosProvider           storageos.Provider
 	buffetchRefParser           buffetch.RefParser
@@ -191,6 +269,7 @@ func newController(
 	moduleKeyProvider bufmodule.ModuleKeyProvider,
 	moduleDataProvider bufmodule.ModuleDataProvider,
 	commitProvider bufmodule.CommitProvider,
+	gitDepProvider bufgitdep.Provider,
 	wktStore bufwktstore.Store,
 	httpClient *http.Client,
 	httpauthAuthenticator httpauth.Authenticator,
@@ -231,6 +310,7 @@ func newController(
 		graphProvider,
 		moduleDataProvider,
 		commitProvider,
+		gitDepProvider,
 	)
 	controller.workspaceDepManagerProvider = bufworkspace.NewWorkspaceDepManagerProvider(
 		logger,
@@ -694,6 +774,131 @@ func (c *controller) PutMessage(
 	return errors.Join(err, writeCloser.Close())
 }
 
+func (c *controller) GetMessageData(
+	ctx context.Context,
+	messageInput string,
+	options ...FunctionOption,
+) (_ []byte, retErr error) {
+	defer c.handleFileAnnotationSetRetError(&retErr)
+	functionOptions := newFunctionOptions(c)
+	for _, option := range options {
+		option(functionOptions)
+	}
+	messageRef, err := buffetch.NewMessageRefParser(c.logger).GetMessageRef(ctx, messageInput)
+	if err != nil {
+		return nil, err
+	}
+	if messageEncoding := messageRef.MessageEncoding(); messageEncoding != buffetch.MessageEncodingBinpb {
+		return nil, fmt.Errorf("%q must be of format binpb to be read without a schema", messageInput)
+	}
+	readCloser, err := c.buffetchReader.GetMessageFile(ctx, c.container, messageRef)
+	if err != nil {
+		return nil, err
+	}
+	return ioext.ReadAllAndClose(readCloser)
+}
+
+func (c *controller) GetMessageReader(
+	ctx context.Context,
+	schemaImage bufimage.Image,
+	messageInput string,
+	typeName string,
+	defaultMessageEncoding buffetch.MessageEncoding,
+	options ...FunctionOption,
+) (_ MessageReader, retErr error) {
+	defer c.handleFileAnnotationSetRetError(&retErr)
+	functionOptions := newFunctionOptions(c)
+	for _, option := range options {
+		option(functionOptions)
+	}
+	messageRefParser := buffetch.NewMessageRefParser(
+		c.logger,
+		buffetch.MessageRefParserWithDefaultMessageEncoding(
+			defaultMessageEncoding,
+		),
+		buffetch.MessageRefParserWithMessageStreams(),
+	)
+	messageRef, err := messageRefParser.GetMessageRef(ctx, messageInput)
+	if err != nil {
+		return nil, err
+	}
+	var unmarshaler protoencoding.Unmarshaler
+	switch messageEncoding := messageRef.MessageEncoding(); messageEncoding {
+	case buffetch.MessageEncodingBinpbDelimited:
+		unmarshaler = protoencoding.NewWireUnmarshaler(schemaImage.Resolver())
+	case buffetch.MessageEncodingJSONL:
+		unmarshaler = protoencoding.NewJSONUnmarshaler(schemaImage.Resolver())
+	default:
+		return nil, fmt.Errorf("%q is not a message stream, the format must be one of %s", messageInput, buffetch.MessageStreamFormatsString)
+	}
+	var validator protoyaml.Validator
+	if functionOptions.messageValidation {
+		validator, err = protovalidate.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Only used to resolve the type, each record is unmarshaled into a new message of the same type.
+	message, err := bufreflect.NewMessage(ctx, schemaImage, typeName)
+	if err != nil {
+		return nil, err
+	}
+	readCloser, err := c.buffetchReader.GetMessageFile(ctx, c.container, messageRef)
+	if err != nil {
+		return nil, err
+	}
+	return newMessageReader(
+		readCloser,
+		messageRef.MessageEncoding(),
+		message.ProtoReflect().Type(),
+		unmarshaler,
+		validator,
+	), nil
+}
+
+func (c *controller) GetMessageWriter(
+	ctx context.Context,
+	schemaImage bufimage.Image,
+	messageOutput string,
+	defaultMessageEncoding buffetch.MessageEncoding,
+	options ...FunctionOption,
+) (_ MessageWriter, retErr error) {
+	defer c.handleFileAnnotationSetRetError(&retErr)
+	functionOptions := newFunctionOptions(c)
+	for _, option := range options {
+		option(functionOptions)
+	}
+	messageRefParser := buffetch.NewMessageRefParser(
+		c.logger,
+		buffetch.MessageRefParserWithDefaultMessageEncoding(
+			defaultMessageEncoding,
+		),
+		buffetch.MessageRefParserWithMessageStreams(),
+	)
+	messageRef, err := messageRefParser.GetMessageRef(ctx, messageOutput)
+	if err != nil {
+		return nil, err
+	}
+	var marshaler protoencoding.Marshaler
+	switch messageEncoding := messageRef.MessageEncoding(); messageEncoding {
+	case buffetch.MessageEncodingBinpbDelimited:
+		marshaler = protoencoding.NewWireMarshaler()
+	case buffetch.MessageEncodingJSONL:
+		// Not indented, so that each message is on a single line.
+		marshaler = newJSONMarshaler(schemaImage.Resolver(), messageRef)
+	default:
+		return nil, fmt.Errorf("%q is not a message stream, the format must be one of %s", messageOutput, buffetch.MessageStreamFormatsString)
+	}
+	if messageRef.IsNull() {
+		return newMessageWriter(ioext.NopWriteCloser(io.Discard), messageRef.MessageEncoding(), marshaler), nil
+	}
+	writeCloser, err := c.buffetchWriter.PutMessageFile(ctx, c.container, messageRef)
+	if err != nil {
+		return nil, err
+	}
+	return newMessageWriter(writeCloser, messageRef.MessageEncoding(), marshaler), nil
+}
+
 func (c *controller) getImage(
 	ctx context.Context,
 	input string,
@@ -788,6 +993,9 @@ func (c *controller) getWorkspaceForProtoFileRef(
 		// TODO FUTURE: Feed flag names through to here.
 		return nil, fmt.Errorf("--exclude-path is not valid for use with .proto file references")
 	}
+	if functionOptions.affectedSince != "" {
+		return nil, fmt.Errorf("--affected-since is not valid for use with .proto file references")
+	}
 	readBucketCloser, bucketTargeting, err := c.buffetchReader.GetSourceReadBucketCloser(
 		ctx,
 		c.container,
@@ -851,6 +1059,20 @@ func (c *controller) getWorkspaceForSourceRef(
 			bufworkspace.WithIgnoreAndDisallowV1BufWorkYAMLs(),
 		)
 	}
+	if functionOptions.affectedSince != "" {
+		dirRef, ok := sourceRef.(buffetch.DirRef)
+		if !ok {
+			return nil, fmt.Errorf("--affected-since is only valid for use with directory inputs")
+		}
+		changedPaths, err := c.getChangedPathsForDirRef(ctx, dirRef, bucketTargeting, functionOptions.affectedSince)
+		if err != nil {
+			return nil, err
+		}
+		options = append(
+			options,
+			bufworkspace.WithChangedPaths(changedPaths),
+		)
+	}
 	return c.workspaceProvider.GetWorkspaceForBucket(
 		ctx,
 		readBucketCloser,
@@ -859,6 +1081,30 @@ func (c *controller) getWorkspaceForSourceRef(
 	)
 }
 
+// getChangedPathsForDirRef gets the paths changed since the git ref, relative to the
+// root of the bucket for the DirRef.
+func (c *controller) getChangedPathsForDirRef(
+	ctx context.Context,
+	dirRef buffetch.DirRef,
+	bucketTargeting buftarget.BucketTargeting,
+	gitRef string,
+) ([]string, error) {
+	// The SubDirPath is the input directory relative to the root of the bucket, so we
+	// get the root of the bucket by walking up one directory per component.
+	bucketDirPath := dirRef.DirPath()
+	if subDirPath := bucketTargeting.SubDirPath(); subDirPath != "." {
+		for range normalpath.Components(subDirPath) {
+			bucketDirPath = normalpath.Join(bucketDirPath, "..")
+		}
+	}
+	return git.GetChangedFilesSinceRef(
+		ctx,
+		c.container,
+		normalpath.Unnormalize(bucketDirPath),
+		gitRef,
+	)
+}
+
 func (c *controller) getWorkspaceDepManagerForDirRef(
 	ctx context.Context,
 	dirRef buffetch.DirRef,
@@ -887,6 +1133,9 @@ func (c *controller) getWorkspaceForModuleRef(
 	moduleRef buffetch.ModuleRef,
 	functionOptions *functionOptions,
 ) (bufworkspace.Workspace, error) {
+	if functionOptions.affectedSince != "" {
+		return nil, fmt.Errorf("--affected-since is only valid for use with directory inputs")
+	}
 	moduleKey, err := c.buffetchReader.GetModuleKey(ctx, c.container, moduleRef)
 	if err != nil {
 		return nil, err
@@ -909,6 +1158,9 @@ func (c *controller) getImageForMessageRef(
 	messageRef buffetch.MessageRef,
 	functionOptions *functionOptions,
 ) (_ bufimage.Image, retErr error) {
+	if functionOptions.affectedSince != "" {
+		return nil, fmt.Errorf("--affected-since is only valid for use with directory inputs")
+	}
 	readCloser, err := c.buffetchReader.GetMessageFile(ctx, c.container, messageRef)
 	if err != nil {
 		return nil, err
@@ -989,6 +1241,9 @@ func (c *controller) buildImage(
 	if functionOptions.imageExcludeSourceInfo {
 		options = append(options, bufimage.WithExcludeSourceCodeInfo())
 	}
+	if c.imageFileStore != nil {
+		options = append(options, bufimage.WithImageFileStore(c.imageFileStore))
+	}
 	image, err := bufimage.BuildImage(
 		ctx,
 		c.logger,
@@ -1009,6 +1264,16 @@ func (c *controller) buildTargetImageWithConfigs(
 	functionOptions *functionOptions,
 ) ([]ImageWithConfig, error) {
 	modules := bufmodule.ModuleSetTargetModules(workspace)
+	if functionOptions.targetModuleOpaqueIDs != nil {
+		targetModuleOpaqueIDs := slicesext.ToStructMap(functionOptions.targetModuleOpaqueIDs)
+		modules = slicesext.Filter(
+			modules,
+			func(module bufmodule.Module) bool {
+				_, ok := targetModuleOpaqueIDs[module.OpaqueID()]
+				return ok
+			},
+		)
+	}
 	imageWithConfigs := make([]ImageWithConfig, 0, len(modules))
 	for _, module := range modules {
 		c.logger.DebugContext(
@@ -1071,7 +1336,8 @@ func (c *controller) buildTargetImageWithConfigs(
 }
 
 // warnUnconfiguredTransitiveImports will print a warning whenever a file imports another file that
-// is not in a local Module, or is not in the declared list of dependencies in your buf.yaml.
+// is not in a local Module, or is not in the declared list of dependencies or git dependencies in
+// your buf.yaml.
 //
 // If all the Modules in the Workspace are remote Modules, no warnings are printed.
 func (c *controller) warnUnconfiguredTransitiveImports(
@@ -1107,6 +1373,13 @@ func (c *controller) warnUnconfiguredTransitiveImports(
 			configuredModuleFullNameStringMap[moduleFullName.String()] = struct{}{}
 		}
 	}
+	// Remote Modules read from a bucket, such as git deps, are only in the Workspace if they
+	// are configured in the buf.yaml.
+	for _, module := range workspace.Modules() {
+		if bufmodule.IsRemoteModuleForBucket(module) {
+			configuredModuleFullNameStringMap[module.ModuleFullName().String()] = struct{}{}
+		}
+	}
 
 	// Construct a map from Image file path -> ModuleFullName string.
 	//
@@ -0,0 +1,169 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufctl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"buf.build/go/protoyaml"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type messageReader struct {
+	readCloser      io.ReadCloser
+	reader          *bufio.Reader
+	messageEncoding buffetch.MessageEncoding
+	messageType     protoreflect.MessageType
+	unmarshaler     protoencoding.Unmarshaler
+	validator       protoyaml.Validator
+
+	// index is the index of the last record read.
+	index int
+	// offset is the number of bytes read.
+	offset int64
+}
+
+func newMessageReader(
+	readCloser io.ReadCloser,
+	messageEncoding buffetch.MessageEncoding,
+	messageType protoreflect.MessageType,
+	unmarshaler protoencoding.Unmarshaler,
+	validator protoyaml.Validator,
+) *messageReader {
+	return &messageReader{
+		readCloser:      readCloser,
+		reader:          bufio.NewReader(readCloser),
+		messageEncoding: messageEncoding,
+		messageType:     messageType,
+		unmarshaler:     unmarshaler,
+		validator:       validator,
+	}
+}
+
+func (m *messageReader) MessageEncoding() buffetch.MessageEncoding {
+	return m.messageEncoding
+}
+
+func (m *messageReader) Read() (proto.Message, error) {
+	var data []byte
+	var offset int64
+	var err error
+	switch m.messageEncoding {
+	case buffetch.MessageEncodingBinpbDelimited:
+		data, offset, err = m.readDelimitedRecord()
+	case buffetch.MessageEncodingJSONL:
+		data, offset, err = m.readLineRecord()
+	default:
+		// This is a system error.
+		return nil, syserror.Newf("unknown message stream encoding: %v", m.messageEncoding)
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		// The stream cannot be read past this record.
+		return nil, fmt.Errorf("record %d at offset %d: %w", m.index+1, offset, err)
+	}
+	m.index++
+	message := m.messageType.New().Interface()
+	if err := m.unmarshaler.Unmarshal(data, message); err != nil {
+		return nil, &RecordError{Index: m.index, Offset: offset, Err: err}
+	}
+	if m.validator != nil {
+		if err := m.validator.Validate(message); err != nil {
+			return nil, &RecordError{Index: m.index, Offset: offset, Err: err}
+		}
+	}
+	return message, nil
+}
+
+func (m *messageReader) Close() error {
+	return m.readCloser.Close()
+}
+
+// readDelimitedRecord reads a record prefixed by its length as a varint.
+//
+// Returns the data of the record and the offset of its length prefix, which is also
+// returned with any error.
+func (m *messageReader) readDelimitedRecord() ([]byte, int64, error) {
+	offset := m.offset
+	var size uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := m.reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if shift == 0 {
+					return nil, offset, io.EOF
+				}
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, offset, fmt.Errorf("invalid length prefix: %w", err)
+		}
+		m.offset++
+		if shift >= 64 {
+			return nil, offset, errors.New("invalid length prefix: varint overflows a 64-bit integer")
+		}
+		size |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	// Messages larger than 2GB cannot be marshaled, so this is a corrupt length prefix.
+	if size > math.MaxInt32 {
+		return nil, offset, fmt.Errorf("invalid length prefix: length %d exceeds the maximum message size", size)
+	}
+	// Read into a growing buffer rather than allocating size bytes up front, so that
+	// a corrupt length prefix cannot allocate more memory than the stream contains.
+	var buffer bytes.Buffer
+	n, err := io.Copy(&buffer, io.LimitReader(m.reader, int64(size)))
+	m.offset += n
+	if err != nil {
+		return nil, offset, fmt.Errorf("read %d of %d bytes: %w", n, size, err)
+	}
+	if uint64(n) < size {
+		return nil, offset, fmt.Errorf("read %d of %d bytes: %w", n, size, io.ErrUnexpectedEOF)
+	}
+	return buffer.Bytes(), offset, nil
+}
+
+// readLineRecord reads a record terminated by a newline, skipping blank lines.
+//
+// Returns the data of the record and the offset of its line, which is also returned
+// with any error.
+func (m *messageReader) readLineRecord() ([]byte, int64, error) {
+	for {
+		offset := m.offset
+		line, err := m.reader.ReadBytes('\n')
+		m.offset += int64(len(line))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, offset, err
+		}
+		if data := bytes.TrimSpace(line); len(data) > 0 {
+			return data, offset, nil
+		}
+		if err != nil {
+			return nil, offset, io.EOF
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufctl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"google.golang.org/protobuf/proto"
+)
+
+type messageWriter struct {
+	writeCloser     io.WriteCloser
+	writer          *bufio.Writer
+	messageEncoding buffetch.MessageEncoding
+	marshaler       protoencoding.Marshaler
+}
+
+func newMessageWriter(
+	writeCloser io.WriteCloser,
+	messageEncoding buffetch.MessageEncoding,
+	marshaler protoencoding.Marshaler,
+) *messageWriter {
+	return &messageWriter{
+		writeCloser:     writeCloser,
+		writer:          bufio.NewWriter(writeCloser),
+		messageEncoding: messageEncoding,
+		marshaler:       marshaler,
+	}
+}
+
+func (m *messageWriter) Write(message proto.Message) error {
+	data, err := m.marshaler.Marshal(message)
+	if err != nil {
+		return err
+	}
+	switch m.messageEncoding {
+	case buffetch.MessageEncodingBinpbDelimited:
+		if _, err := m.writer.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+			return err
+		}
+		_, err = m.writer.Write(data)
+		return err
+	case buffetch.MessageEncodingJSONL:
+		if _, err := m.writer.Write(data); err != nil {
+			return err
+		}
+		return m.writer.WriteByte('\n')
+	default:
+		// This is a system error.
+		return syserror.Newf("unknown message stream encoding: %v", m.messageEncoding)
+	}
+}
+
+func (m *messageWriter) Close() error {
+	return errors.Join(m.writer.Flush(), m.writeCloser.Close())
+}
@@ -16,6 +16,7 @@ package bufctl
 
 import (
 	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 )
 
 type ControllerOption func(*controller)
@@ -44,6 +45,14 @@ func WithCopyToInMemory() ControllerOption {
 	}
 }
 
+// WithImageFileStore returns a new ControllerOption that reuses the compiled files of
+// remote Modules from the given ImageFileStore when building Images.
+func WithImageFileStore(imageFileStore bufimage.ImageFileStore) ControllerOption {
+	return func(controller *controller) {
+		controller.imageFileStore = imageFileStore
+	}
+}
+
 // TODO FUTURE: split up to per-function.
 type FunctionOption func(*functionOptions)
 
@@ -113,6 +122,31 @@ func WithIgnoreAndDisallowV1BufWorkYAMLs() FunctionOption {
 	}
 }
 
+// WithAffectedSince returns a new FunctionOption that says to only target the modules
+// affected by the changes since the given git ref.
+//
+// The changes are the files that were added, modified, or deleted since the git ref,
+// including uncommitted and untracked files. This is only valid for directory inputs
+// within a git checkout.
+//
+// See bufworkspace.WithChangedPaths for more details.
+func WithAffectedSince(affectedSince string) FunctionOption {
+	return func(functionOptions *functionOptions) {
+		functionOptions.affectedSince = affectedSince
+	}
+}
+
+// WithTargetModuleOpaqueIDs returns a new FunctionOption that says to only build images
+// for the target modules with the given OpaqueIDs.
+//
+// This only applies to GetTargetImageWithConfigs for workspaces. This is used to build
+// images for the same modules as another input, such as with breaking and --affected-since.
+func WithTargetModuleOpaqueIDs(targetModuleOpaqueIDs []string) FunctionOption {
+	return func(functionOptions *functionOptions) {
+		functionOptions.targetModuleOpaqueIDs = targetModuleOpaqueIDs
+	}
+}
+
 // WithMessageValidation returns a new FunctionOption that says to validate the
 // message as it is being read.
 //
@@ -137,6 +171,8 @@ type functionOptions struct {
 	imageAsFileDescriptorSet        bool
 	configOverride                  string
 	ignoreAndDisallowV1BufWorkYAMLs bool
+	affectedSince                   string
+	targetModuleOpaqueIDs           []string
 	messageValidation               bool
 }
 
@@ -290,7 +290,7 @@ func (inv *invoker) handleResponse(data []byte, msg *dynamicpb.Message) error {
 			protoencoding.JSONMarshalerWithEmitUnpopulated(),
 		)
 	}
-	unrecognized := countUnrecognized(msg.ProtoReflect())
+	unrecognized := protoencoding.CountUnrecognized(msg.ProtoReflect())
 	if unrecognized > 0 {
 		inv.printer.Printf("Response message (%s) contained %d bytes of unrecognized fields.",
 			msg.ProtoReflect().Descriptor().FullName(), unrecognized)
@@ -446,39 +446,3 @@ func (s *streamMessageProvider) next(msg proto.Message) error {
 		s.res, protoencoding.JSONUnmarshalerWithDisallowUnknown(),
 	).Unmarshal(jsonData, msg)
 }
-
-func countUnrecognized(msg protoreflect.Message) int {
-	var count int
-	msg.Range(func(field protoreflect.FieldDescriptor, val protoreflect.Value) bool {
-		switch {
-		case field.IsMap():
-			if !isMessageKind(field.MapValue().Kind()) {
-				break
-			}
-			// Note: Technically, each message entry could have had unrecognized field
-			// bytes, but they are discarded by the runtime. So we can only look at
-			// unrecognized fields in message values inside the map.
-			mapVal := val.Map()
-			mapVal.Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
-				count += countUnrecognized(v.Message())
-				return true
-			})
-		case field.IsList():
-			if !isMessageKind(field.Kind()) {
-				break
-			}
-			listVal := val.List()
-			for i, length := 0, listVal.Len(); i < length; i++ {
-				count += countUnrecognized(listVal.Get(i).Message())
-			}
-		case isMessageKind(field.Kind()):
-			count += countUnrecognized(val.Message())
-		}
-		return true
-	})
-	return count + len(msg.GetUnknown())
-}
-
-func isMessageKind(k protoreflect.Kind) bool {
-	return k == protoreflect.MessageKind || k == protoreflect.GroupKind
-}
@@ -0,0 +1,45 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufeditions migrates .proto files from proto2 and proto3 syntax to editions.
+package bufeditions
+
+import (
+	"context"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// MigrateBucket migrates the .proto files in the bucket that use proto2 or proto3 syntax
+// to the edition, and returns a new bucket with all the .proto files of the bucket.
+//
+// The image must contain the files of the bucket, at the same paths, and their imports.
+//
+// Features are added to the migrated files, messages, and fields where needed to preserve
+// their behavior exactly. Labels, groups, and packed options, which are not allowed in
+// editions, are converted to their equivalent features. The migrated files are then
+// compiled, and an error is returned if the resolved features of any element differ from
+// the image. The migrated files are formatted as with bufformat.
+//
+// Files that already use editions are returned unchanged. Only edition 2023 is supported.
+func MigrateBucket(
+	ctx context.Context,
+	image bufimage.Image,
+	bucket storage.ReadBucket,
+	edition descriptorpb.Edition,
+) (storage.ReadBucket, error) {
+	return migrateBucket(ctx, image, bucket, edition)
+}
@@ -0,0 +1,85 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufeditions
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/diff"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestMigrateBucket(t *testing.T) {
+	t.Parallel()
+	testMigrateBucket(t, "testdata/proto2")
+	testMigrateBucket(t, "testdata/proto3")
+}
+
+func TestMigrateBucketUnsupportedEdition(t *testing.T) {
+	t.Parallel()
+	_, err := MigrateBucket(context.Background(), nil, nil, descriptorpb.Edition_EDITION_2024)
+	require.EqualError(t, err, "unsupported edition EDITION_2024, only EDITION_2023 is supported")
+}
+
+// testMigrateBucket migrates the files in the input directory of the path, and compares
+// them with the files in the golden directory.
+func testMigrateBucket(t *testing.T, path string) {
+	t.Run(path, func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		moduleSet, err := bufmoduletesting.NewModuleSetForDirPath(filepath.Join(path, "input"))
+		require.NoError(t, err)
+		moduleReadBucket := bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet)
+		image, err := bufimage.BuildImage(ctx, slogtestext.NewLogger(t), moduleReadBucket)
+		require.NoError(t, err)
+		readBucket, err := MigrateBucket(
+			ctx,
+			image,
+			bufmodule.ModuleReadBucketToStorageReadBucket(moduleReadBucket),
+			descriptorpb.Edition_EDITION_2023,
+		)
+		require.NoError(t, err)
+		goldenBucket, err := storageos.NewProvider().NewReadWriteBucket(filepath.Join(path, "golden"))
+		require.NoError(t, err)
+		require.NoError(
+			t,
+			storage.WalkReadObjects(
+				ctx,
+				readBucket,
+				"",
+				func(readObject storage.ReadObject) error {
+					migratedData, err := io.ReadAll(readObject)
+					require.NoError(t, err)
+					goldenData, err := storage.ReadPath(ctx, goldenBucket, readObject.Path())
+					require.NoError(t, err)
+					fileDiff, err := diff.Diff(ctx, goldenData, migratedData, readObject.Path(), readObject.Path()+" (migrated)")
+					require.NoError(t, err)
+					require.Empty(t, string(fileDiff))
+					return nil
+				},
+			),
+		)
+	})
+}
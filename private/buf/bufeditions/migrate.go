@@ -0,0 +1,627 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufeditions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/buf/private/buf/bufformat"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	featureFieldPresenceImplicit         = "features.field_presence = IMPLICIT"
+	featureFieldPresenceExplicit         = "features.field_presence = EXPLICIT"
+	featureFieldPresenceLegacyRequired   = "features.field_presence = LEGACY_REQUIRED"
+	featureEnumTypeClosed                = "features.enum_type = CLOSED"
+	featureRepeatedFieldEncodingPacked   = "features.repeated_field_encoding = PACKED"
+	featureRepeatedFieldEncodingExpanded = "features.repeated_field_encoding = EXPANDED"
+	featureUTF8ValidationNone            = "features.utf8_validation = NONE"
+	featureMessageEncodingDelimited      = "features.message_encoding = DELIMITED"
+	featureJSONFormatLegacyBestEffort    = "features.json_format = LEGACY_BEST_EFFORT"
+	packedOptionName                     = "packed"
+)
+
+func migrateBucket(
+	ctx context.Context,
+	image bufimage.Image,
+	bucket storage.ReadBucket,
+	edition descriptorpb.Edition,
+) (storage.ReadBucket, error) {
+	if edition != descriptorpb.Edition_EDITION_2023 {
+		return nil, fmt.Errorf("unsupported edition %v, only %v is supported", edition, descriptorpb.Edition_EDITION_2023)
+	}
+	resolver := image.Resolver()
+	paths, err := storage.AllPaths(ctx, storage.FilterReadBucket(bucket, storage.MatchPathExt(".proto")), "")
+	if err != nil {
+		return nil, err
+	}
+	readWriteBucket := storagemem.NewReadWriteBucket()
+	pathToMigratedData := make(map[string][]byte)
+	for _, path := range paths {
+		data, externalPath, err := readFile(ctx, bucket, path)
+		if err != nil {
+			return nil, err
+		}
+		fileDescriptor, err := resolver.FindFileByPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", externalPath, err)
+		}
+		if fileDescriptor.Syntax() != protoreflect.Editions {
+			data, err = migrateFile(externalPath, data, fileDescriptor, resolver)
+			if err != nil {
+				return nil, err
+			}
+			pathToMigratedData[path] = data
+		}
+		if err := writeFile(ctx, readWriteBucket, path, externalPath, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := verifyMigratedFiles(ctx, resolver, pathToMigratedData); err != nil {
+		return nil, err
+	}
+	return readWriteBucket, nil
+}
+
+// migrateFile migrates the file to edition 2023, and formats the result.
+func migrateFile(
+	externalPath string,
+	data []byte,
+	fileDescriptor protoreflect.FileDescriptor,
+	resolver protoencoding.Resolver,
+) ([]byte, error) {
+	fileNode, err := parser.Parse(externalPath, bytes.NewReader(data), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, err
+	}
+	migrator := newMigrator(fileNode, data, fileDescriptor, resolver)
+	if err := migrator.migrate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", externalPath, err)
+	}
+	migratedFileNode, err := parser.Parse(externalPath, strings.NewReader(migrator.render(0, len(data))), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, fmt.Errorf("%s: migrated file is invalid: %w", externalPath, err)
+	}
+	buffer := bytes.NewBuffer(nil)
+	if err := bufformat.FormatFileNode(buffer, migratedFileNode); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// migrator rewrites a proto2 or proto3 file to edition 2023 with edits to its source.
+type migrator struct {
+	fileNode       *ast.FileNode
+	data           []byte
+	fileDescriptor protoreflect.FileDescriptor
+	resolver       protoencoding.Resolver
+	isProto2       bool
+	// fileFeatures are the features set at the file level.
+	fileFeatures []string
+	edits        []*edit
+}
+
+// edit replaces the source in [start, end) with the text of the edit.
+type edit struct {
+	start int
+	end   int
+	text  string
+	// body is the source of a group that is moved to a message declaration, which is
+	// rendered with its own edits after the text.
+	body *edit
+}
+
+func newMigrator(
+	fileNode *ast.FileNode,
+	data []byte,
+	fileDescriptor protoreflect.FileDescriptor,
+	resolver protoencoding.Resolver,
+) *migrator {
+	migrator := &migrator{
+		fileNode:       fileNode,
+		data:           data,
+		fileDescriptor: fileDescriptor,
+		resolver:       resolver,
+		isProto2:       fileDescriptor.Syntax() == protoreflect.Proto2,
+	}
+	migrator.fileFeatures = getFileFeatures(fileDescriptor)
+	return migrator
+}
+
+func (m *migrator) migrate() error {
+	var syntaxNode ast.Node
+	editionText := `edition = "2023";`
+	// The offset of the edition statement, if it is inserted.
+	editionOffset := len(m.data)
+	if m.fileNode.Syntax != nil {
+		syntaxNode = m.fileNode.Syntax
+		m.addEdit(m.start(syntaxNode), m.end(syntaxNode), editionText)
+	} else {
+		// Files without a syntax statement are proto2.
+		if len(m.fileNode.Decls) > 0 {
+			editionOffset = m.start(m.fileNode.Decls[0])
+		}
+		m.addEdit(editionOffset, editionOffset, editionText+"\n\n")
+	}
+	m.addFileFeaturesEdit(syntaxNode, editionOffset)
+	packageName := string(m.fileDescriptor.Package())
+	for _, decl := range m.fileNode.Decls {
+		switch decl := decl.(type) {
+		case *ast.MessageNode:
+			if err := m.migrateMessageBody(joinName(packageName, decl.Name.Val), decl.Decls); err != nil {
+				return err
+			}
+		case *ast.ExtendNode:
+			if err := m.migrateExtend(packageName, decl); err != nil {
+				return err
+			}
+		}
+	}
+	// Insertions are rendered before replacements at the same offset.
+	sort.SliceStable(m.edits, func(i int, j int) bool {
+		if m.edits[i].start != m.edits[j].start {
+			return m.edits[i].start < m.edits[j].start
+		}
+		return m.edits[i].end < m.edits[j].end
+	})
+	return nil
+}
+
+// addFileFeaturesEdit adds the file features after the last file option, import, or
+// package statement, or after the syntax statement otherwise.
+//
+// If there are no such statements, the file features are added after the edition
+// statement inserted at the offset.
+func (m *migrator) addFileFeaturesEdit(syntaxNode ast.Node, editionOffset int) {
+	if len(m.fileFeatures) == 0 {
+		return
+	}
+	var lastOptionNode, lastImportNode, packageNode ast.Node
+	for _, decl := range m.fileNode.Decls {
+		switch decl := decl.(type) {
+		case *ast.OptionNode:
+			lastOptionNode = decl
+		case *ast.ImportNode:
+			lastImportNode = decl
+		case *ast.PackageNode:
+			packageNode = decl
+		}
+	}
+	var text strings.Builder
+	anchorNode := lastOptionNode
+	if anchorNode == nil {
+		// Separate the options from the previous statements.
+		text.WriteString("\n")
+		for _, node := range []ast.Node{lastImportNode, packageNode, syntaxNode} {
+			if node != nil {
+				anchorNode = node
+				break
+			}
+		}
+	}
+	for _, feature := range m.fileFeatures {
+		text.WriteString("option " + feature + ";\n")
+	}
+	offset := editionOffset
+	if anchorNode != nil {
+		// Insert after the line of the statement, to keep its trailing comment.
+		offset = m.end(anchorNode)
+		if index := bytes.IndexByte(m.data[offset:], '\n'); index >= 0 {
+			offset += index + 1
+		} else {
+			offset = len(m.data)
+			m.addEdit(offset, offset, "\n")
+		}
+	}
+	m.addEdit(offset, offset, text.String())
+}
+
+func (m *migrator) migrateMessageBody(messageName string, decls []ast.MessageElement) error {
+	for _, decl := range decls {
+		var err error
+		switch decl := decl.(type) {
+		case *ast.FieldNode:
+			err = m.migrateField(messageName, decl)
+		case *ast.GroupNode:
+			err = m.migrateGroup(messageName, decl, nil)
+		case *ast.OneofNode:
+			for _, oneofDecl := range decl.Decls {
+				switch oneofDecl := oneofDecl.(type) {
+				case *ast.FieldNode:
+					err = m.migrateField(messageName, oneofDecl)
+				case *ast.GroupNode:
+					err = m.migrateGroup(messageName, oneofDecl, decl)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		case *ast.MessageNode:
+			err = m.migrateMessageBody(joinName(messageName, decl.Name.Val), decl.Decls)
+		case *ast.ExtendNode:
+			err = m.migrateExtend(messageName, decl)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateExtend migrates the extensions declared in the scope, which is a package or message.
+func (m *migrator) migrateExtend(scope string, extendNode *ast.ExtendNode) error {
+	for _, decl := range extendNode.Decls {
+		var err error
+		switch decl := decl.(type) {
+		case *ast.FieldNode:
+			err = m.migrateField(scope, decl)
+		case *ast.GroupNode:
+			err = m.migrateGroup(scope, decl, extendNode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateField migrates a field or extension declared in the scope.
+//
+// The optional and required labels are removed, and features are added to preserve the
+// presence and encoding of the field.
+func (m *migrator) migrateField(scope string, fieldNode *ast.FieldNode) error {
+	fieldDescriptor, err := m.getFieldDescriptor(joinName(scope, fieldNode.Name.Val))
+	if err != nil {
+		return err
+	}
+	var features []string
+	if fieldNode.Label.Required {
+		features = append(features, featureFieldPresenceLegacyRequired)
+	}
+	if !m.isProto2 &&
+		fieldDescriptor.HasOptionalKeyword() &&
+		!fieldDescriptor.IsExtension() &&
+		fieldDescriptor.Message() == nil &&
+		m.hasFileFeature(featureFieldPresenceImplicit) {
+		features = append(features, featureFieldPresenceExplicit)
+	}
+	features = append(features, m.getRepeatedFieldEncodingFeatures(fieldDescriptor)...)
+	if fieldNode.Label.IsPresent() && !fieldNode.Label.Repeated {
+		m.addEdit(m.start(fieldNode.Label.KeywordNode), m.start(fieldNode.FldType), "")
+	}
+	if fieldNode.Options != nil {
+		if text, ok := m.getCompactOptionsText(fieldNode.Options, features); ok {
+			m.addEdit(m.start(fieldNode.Options), m.end(fieldNode.Options), text)
+		}
+	} else if len(features) > 0 {
+		offset := m.end(fieldNode)
+		if fieldNode.Semicolon != nil {
+			offset = m.start(fieldNode.Semicolon)
+		}
+		m.addEdit(offset, offset, " ["+strings.Join(features, ", ")+"]")
+	}
+	return nil
+}
+
+// migrateGroup migrates a group declared in the scope, which is a package or message, to
+// a delimited field and a message declaration.
+//
+// If the group is declared in a oneof or extend block, the message is declared after the
+// block, as it cannot be declared within it.
+func (m *migrator) migrateGroup(scope string, groupNode *ast.GroupNode, blockNode ast.Node) error {
+	fieldName := strings.ToLower(groupNode.Name.Val)
+	if _, err := m.getFieldDescriptor(joinName(scope, fieldName)); err != nil {
+		return err
+	}
+	features := []string{featureMessageEncodingDelimited}
+	if groupNode.Label.Required {
+		features = append(features, featureFieldPresenceLegacyRequired)
+	}
+	var fieldText strings.Builder
+	if groupNode.Label.Repeated {
+		fieldText.WriteString("repeated ")
+	}
+	fieldText.WriteString(groupNode.Name.Val + " " + fieldName + " = " + m.rawText(groupNode.Tag) + " ")
+	if groupNode.Options != nil {
+		// The group options always change, as the message encoding is added.
+		text, _ := m.getCompactOptionsText(groupNode.Options, features)
+		fieldText.WriteString(text)
+	} else {
+		fieldText.WriteString("[" + strings.Join(features, ", ") + "]")
+	}
+	fieldText.WriteString(";")
+	body := &edit{
+		start: m.start(groupNode.OpenBrace),
+		end:   m.end(groupNode.CloseBrace),
+	}
+	messageText := "message " + groupNode.Name.Val + " "
+	if blockNode == nil {
+		m.edits = append(m.edits, &edit{
+			start: m.start(groupNode),
+			end:   m.end(groupNode),
+			text:  fieldText.String() + "\n\n" + messageText,
+			body:  body,
+		})
+	} else {
+		m.addEdit(m.start(groupNode), m.end(groupNode), fieldText.String())
+		m.edits = append(m.edits, &edit{
+			start: m.end(blockNode),
+			end:   m.end(blockNode),
+			text:  "\n\n" + messageText,
+			body:  body,
+		})
+	}
+	return m.migrateMessageBody(joinName(scope, groupNode.Name.Val), groupNode.Decls)
+}
+
+// getRepeatedFieldEncodingFeatures returns the features to preserve whether the field
+// is packed, as the packed option is not allowed in editions.
+func (m *migrator) getRepeatedFieldEncodingFeatures(fieldDescriptor protoreflect.FieldDescriptor) []string {
+	if !isPackable(fieldDescriptor) {
+		return nil
+	}
+	if m.isProto2 {
+		if fieldDescriptor.IsPacked() && m.hasFileFeature(featureRepeatedFieldEncodingExpanded) {
+			return []string{featureRepeatedFieldEncodingPacked}
+		}
+		return nil
+	}
+	if !fieldDescriptor.IsPacked() {
+		return []string{featureRepeatedFieldEncodingExpanded}
+	}
+	return nil
+}
+
+// getCompactOptionsText returns the text of the compact options without the packed
+// option and with the features added.
+//
+// Returns false if the options are unchanged.
+func (m *migrator) getCompactOptionsText(compactOptionsNode *ast.CompactOptionsNode, features []string) (string, bool) {
+	var optionTexts []string
+	changed := len(features) > 0
+	for _, optionNode := range compactOptionsNode.Options {
+		if isPackedOption(optionNode) {
+			changed = true
+			continue
+		}
+		optionTexts = append(optionTexts, m.rawText(optionNode))
+	}
+	if !changed {
+		return "", false
+	}
+	optionTexts = append(optionTexts, features...)
+	if len(optionTexts) == 0 {
+		return "", true
+	}
+	return "[" + strings.Join(optionTexts, ", ") + "]", true
+}
+
+func (m *migrator) getFieldDescriptor(fullName string) (protoreflect.FieldDescriptor, error) {
+	descriptor, err := m.resolver.FindDescriptorByName(protoreflect.FullName(fullName))
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", fullName, err)
+	}
+	fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a field", fullName)
+	}
+	return fieldDescriptor, nil
+}
+
+func (m *migrator) hasFileFeature(feature string) bool {
+	for _, fileFeature := range m.fileFeatures {
+		if fileFeature == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *migrator) addEdit(start int, end int, text string) {
+	m.edits = append(m.edits, &edit{start: start, end: end, text: text})
+}
+
+// render returns the source in [start, end) with the edits within it applied. The edits
+// must be sorted.
+//
+// Edits within an edit that was applied are skipped, as they are rendered as part of
+// its body, if any.
+func (m *migrator) render(start int, end int) string {
+	var builder strings.Builder
+	offset := start
+	for _, edit := range m.edits {
+		if edit.start < offset || edit.end > end {
+			continue
+		}
+		builder.Write(m.data[offset:edit.start])
+		builder.WriteString(edit.text)
+		if edit.body != nil {
+			builder.WriteString(m.render(edit.body.start, edit.body.end))
+		}
+		offset = edit.end
+	}
+	builder.Write(m.data[offset:end])
+	return builder.String()
+}
+
+func (m *migrator) start(node ast.Node) int {
+	return m.fileNode.NodeInfo(node).Start().Offset
+}
+
+// end returns the offset after the last character of the node.
+func (m *migrator) end(node ast.Node) int {
+	nodeInfo := m.fileNode.NodeInfo(node)
+	return nodeInfo.Start().Offset + len(nodeInfo.RawText())
+}
+
+func (m *migrator) rawText(node ast.Node) string {
+	return m.fileNode.NodeInfo(node).RawText()
+}
+
+// getFileFeatures returns the features to set at the file level to preserve the behavior
+// of the file in edition 2023.
+func getFileFeatures(fileDescriptor protoreflect.FileDescriptor) []string {
+	var hasImplicitPresence, hasExpanded, hasString, hasEnum, hasMessage bool
+	forEachField(fileDescriptor, func(fieldDescriptor protoreflect.FieldDescriptor) {
+		if !fieldDescriptor.IsList() && !fieldDescriptor.HasPresence() {
+			hasImplicitPresence = true
+		}
+		if isPackable(fieldDescriptor) && !fieldDescriptor.IsPacked() {
+			hasExpanded = true
+		}
+		if fieldDescriptor.Kind() == protoreflect.StringKind {
+			hasString = true
+		}
+	})
+	forEachType(fileDescriptor, func(descriptor protoreflect.Descriptor) {
+		switch descriptor.(type) {
+		case protoreflect.EnumDescriptor:
+			hasEnum = true
+		case protoreflect.MessageDescriptor:
+			hasMessage = true
+		}
+	})
+	var features []string
+	if fileDescriptor.Syntax() == protoreflect.Proto3 {
+		// Proto3 differs from edition 2023 only in the presence of fields.
+		if hasImplicitPresence {
+			features = append(features, featureFieldPresenceImplicit)
+		}
+		return features
+	}
+	if hasEnum {
+		features = append(features, featureEnumTypeClosed)
+	}
+	if hasExpanded {
+		features = append(features, featureRepeatedFieldEncodingExpanded)
+	}
+	if hasString {
+		features = append(features, featureUTF8ValidationNone)
+	}
+	if hasEnum || hasMessage {
+		features = append(features, featureJSONFormatLegacyBestEffort)
+	}
+	return features
+}
+
+// forEachField calls f for all the fields and extensions declared in the file, including
+// the fields of map entries.
+func forEachField(fileDescriptor protoreflect.FileDescriptor, f func(protoreflect.FieldDescriptor)) {
+	forEachExtension := func(extensions protoreflect.ExtensionDescriptors) {
+		for i := 0; i < extensions.Len(); i++ {
+			f(extensions.Get(i))
+		}
+	}
+	forEachExtension(fileDescriptor.Extensions())
+	forEachType(fileDescriptor, func(descriptor protoreflect.Descriptor) {
+		if messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor); ok {
+			for i := 0; i < messageDescriptor.Fields().Len(); i++ {
+				f(messageDescriptor.Fields().Get(i))
+			}
+			forEachExtension(messageDescriptor.Extensions())
+		}
+	})
+}
+
+// forEachType calls f for all the messages and enums declared in the file, including map
+// entries.
+func forEachType(fileDescriptor protoreflect.FileDescriptor, f func(protoreflect.Descriptor)) {
+	var forEachMessage func(protoreflect.MessageDescriptors)
+	forEachEnum := func(enums protoreflect.EnumDescriptors) {
+		for i := 0; i < enums.Len(); i++ {
+			f(enums.Get(i))
+		}
+	}
+	forEachMessage = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			messageDescriptor := messages.Get(i)
+			f(messageDescriptor)
+			forEachMessage(messageDescriptor.Messages())
+			forEachEnum(messageDescriptor.Enums())
+		}
+	}
+	forEachMessage(fileDescriptor.Messages())
+	forEachEnum(fileDescriptor.Enums())
+}
+
+// isPackable returns true if the field is a repeated field of a scalar type, which can be
+// packed.
+func isPackable(fieldDescriptor protoreflect.FieldDescriptor) bool {
+	if !fieldDescriptor.IsList() {
+		return false
+	}
+	switch fieldDescriptor.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	default:
+		return true
+	}
+}
+
+func isPackedOption(optionNode *ast.OptionNode) bool {
+	parts := optionNode.Name.Parts
+	return len(parts) == 1 && !parts[0].IsExtension() && string(parts[0].Name.AsIdentifier()) == packedOptionName
+}
+
+func joinName(scope string, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func readFile(ctx context.Context, bucket storage.ReadBucket, path string) (_ []byte, _ string, retErr error) {
+	readObjectCloser, err := bucket.Get(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, readObjectCloser.Close())
+	}()
+	data, err := io.ReadAll(readObjectCloser)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, readObjectCloser.ExternalPath(), nil
+}
+
+func writeFile(ctx context.Context, bucket storage.WriteBucket, path string, externalPath string, data []byte) (retErr error) {
+	writeObjectCloser, err := bucket.Put(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, writeObjectCloser.Close())
+	}()
+	if _, err := writeObjectCloser.Write(data); err != nil {
+		return err
+	}
+	return writeObjectCloser.SetExternalPath(externalPath)
+}
@@ -0,0 +1,266 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufeditions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/protoutil"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	featureSetDescriptor       = (&descriptorpb.FeatureSet{}).ProtoReflect().Descriptor()
+	utf8ValidationFeatureField = featureSetDescriptor.Fields().ByName("utf8_validation")
+	jsonFormatFeatureField     = featureSetDescriptor.Fields().ByName("json_format")
+)
+
+// fieldSemantics are the properties of a field that must be preserved by the migration.
+type fieldSemantics struct {
+	Name           protoreflect.Name
+	JSONName       string
+	Cardinality    protoreflect.Cardinality
+	Kind           protoreflect.Kind
+	TypeName       protoreflect.FullName
+	HasPresence    bool
+	IsPacked       bool
+	Default        string
+	Oneof          protoreflect.Name
+	Extendee       protoreflect.FullName
+	UTF8Validation string
+}
+
+// verifyMigratedFiles compiles the migrated files, and returns an error if the semantics
+// of any of their elements differ from the files of the resolver.
+func verifyMigratedFiles(
+	ctx context.Context,
+	resolver protoencoding.Resolver,
+	pathToMigratedData map[string][]byte,
+) error {
+	if len(pathToMigratedData) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(pathToMigratedData))
+	for path := range pathToMigratedData {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(
+			protocompile.ResolverFunc(
+				func(path string) (protocompile.SearchResult, error) {
+					if data, ok := pathToMigratedData[path]; ok {
+						return protocompile.SearchResult{Source: bytes.NewReader(data)}, nil
+					}
+					fileDescriptor, err := resolver.FindFileByPath(path)
+					if err != nil {
+						return protocompile.SearchResult{}, err
+					}
+					return protocompile.SearchResult{Desc: fileDescriptor}, nil
+				},
+			),
+		),
+	}
+	files, err := compiler.Compile(ctx, paths...)
+	if err != nil {
+		return fmt.Errorf("migrated files are invalid: %w", err)
+	}
+	for _, file := range files {
+		fileDescriptor, err := resolver.FindFileByPath(file.Path())
+		if err != nil {
+			return err
+		}
+		if err := verifyFile(fileDescriptor, file); err != nil {
+			return fmt.Errorf("%s: migration does not preserve behavior: %w", file.Path(), err)
+		}
+	}
+	return nil
+}
+
+func verifyFile(fileDescriptor protoreflect.FileDescriptor, migratedFileDescriptor protoreflect.FileDescriptor) error {
+	if err := verifyMessages(fileDescriptor.Messages(), migratedFileDescriptor.Messages()); err != nil {
+		return err
+	}
+	if err := verifyEnums(fileDescriptor.Enums(), migratedFileDescriptor.Enums()); err != nil {
+		return err
+	}
+	return verifyExtensions(fileDescriptor.Extensions(), migratedFileDescriptor.Extensions())
+}
+
+func verifyMessages(messages protoreflect.MessageDescriptors, migratedMessages protoreflect.MessageDescriptors) error {
+	if messages.Len() != migratedMessages.Len() {
+		return fmt.Errorf("expected %d messages, got %d", messages.Len(), migratedMessages.Len())
+	}
+	for i := 0; i < messages.Len(); i++ {
+		messageDescriptor := messages.Get(i)
+		migratedMessageDescriptor := migratedMessages.ByName(messageDescriptor.Name())
+		if migratedMessageDescriptor == nil {
+			return fmt.Errorf("message %s is missing", messageDescriptor.FullName())
+		}
+		if err := verifyFeatures(messageDescriptor, migratedMessageDescriptor, jsonFormatFeatureField); err != nil {
+			return err
+		}
+		fields := messageDescriptor.Fields()
+		migratedFields := migratedMessageDescriptor.Fields()
+		if fields.Len() != migratedFields.Len() {
+			return fmt.Errorf("expected %d fields in %s, got %d", fields.Len(), messageDescriptor.FullName(), migratedFields.Len())
+		}
+		for j := 0; j < fields.Len(); j++ {
+			fieldDescriptor := fields.Get(j)
+			migratedFieldDescriptor := migratedFields.ByNumber(fieldDescriptor.Number())
+			if migratedFieldDescriptor == nil {
+				return fmt.Errorf("field %s is missing", fieldDescriptor.FullName())
+			}
+			if err := verifyField(fieldDescriptor, migratedFieldDescriptor); err != nil {
+				return err
+			}
+		}
+		if err := verifyMessages(messageDescriptor.Messages(), migratedMessageDescriptor.Messages()); err != nil {
+			return err
+		}
+		if err := verifyEnums(messageDescriptor.Enums(), migratedMessageDescriptor.Enums()); err != nil {
+			return err
+		}
+		if err := verifyExtensions(messageDescriptor.Extensions(), migratedMessageDescriptor.Extensions()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyEnums(enums protoreflect.EnumDescriptors, migratedEnums protoreflect.EnumDescriptors) error {
+	if enums.Len() != migratedEnums.Len() {
+		return fmt.Errorf("expected %d enums, got %d", enums.Len(), migratedEnums.Len())
+	}
+	for i := 0; i < enums.Len(); i++ {
+		enumDescriptor := enums.Get(i)
+		migratedEnumDescriptor := migratedEnums.ByName(enumDescriptor.Name())
+		if migratedEnumDescriptor == nil {
+			return fmt.Errorf("enum %s is missing", enumDescriptor.FullName())
+		}
+		if enumDescriptor.IsClosed() != migratedEnumDescriptor.IsClosed() {
+			return fmt.Errorf("enum %s: closed changed from %t to %t", enumDescriptor.FullName(), enumDescriptor.IsClosed(), migratedEnumDescriptor.IsClosed())
+		}
+		if err := verifyFeatures(enumDescriptor, migratedEnumDescriptor, jsonFormatFeatureField); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyExtensions(extensions protoreflect.ExtensionDescriptors, migratedExtensions protoreflect.ExtensionDescriptors) error {
+	if extensions.Len() != migratedExtensions.Len() {
+		return fmt.Errorf("expected %d extensions, got %d", extensions.Len(), migratedExtensions.Len())
+	}
+	for i := 0; i < extensions.Len(); i++ {
+		extensionDescriptor := extensions.Get(i)
+		migratedExtensionDescriptor := migratedExtensions.ByName(extensionDescriptor.Name())
+		if migratedExtensionDescriptor == nil {
+			return fmt.Errorf("extension %s is missing", extensionDescriptor.FullName())
+		}
+		if err := verifyField(extensionDescriptor, migratedExtensionDescriptor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyField(fieldDescriptor protoreflect.FieldDescriptor, migratedFieldDescriptor protoreflect.FieldDescriptor) error {
+	semantics, err := getFieldSemantics(fieldDescriptor)
+	if err != nil {
+		return err
+	}
+	migratedSemantics, err := getFieldSemantics(migratedFieldDescriptor)
+	if err != nil {
+		return err
+	}
+	if semantics != migratedSemantics {
+		return fmt.Errorf("field %s changed from %+v to %+v", fieldDescriptor.FullName(), semantics, migratedSemantics)
+	}
+	return nil
+}
+
+// verifyFeatures returns an error if the resolved features differ for the descriptors.
+func verifyFeatures(descriptor protoreflect.Descriptor, migratedDescriptor protoreflect.Descriptor, features ...protoreflect.FieldDescriptor) error {
+	for _, feature := range features {
+		value, err := resolveFeature(descriptor, feature)
+		if err != nil {
+			return err
+		}
+		migratedValue, err := resolveFeature(migratedDescriptor, feature)
+		if err != nil {
+			return err
+		}
+		if value != migratedValue {
+			return fmt.Errorf("%s: feature %s changed from %s to %s", descriptor.FullName(), feature.Name(), value, migratedValue)
+		}
+	}
+	return nil
+}
+
+func getFieldSemantics(fieldDescriptor protoreflect.FieldDescriptor) (fieldSemantics, error) {
+	semantics := fieldSemantics{
+		Name:        fieldDescriptor.Name(),
+		JSONName:    fieldDescriptor.JSONName(),
+		Cardinality: fieldDescriptor.Cardinality(),
+		Kind:        fieldDescriptor.Kind(),
+		HasPresence: fieldDescriptor.HasPresence(),
+		IsPacked:    fieldDescriptor.IsPacked(),
+	}
+	if messageDescriptor := fieldDescriptor.Message(); messageDescriptor != nil {
+		semantics.TypeName = messageDescriptor.FullName()
+	}
+	if enumDescriptor := fieldDescriptor.Enum(); enumDescriptor != nil {
+		semantics.TypeName = enumDescriptor.FullName()
+	}
+	if fieldDescriptor.HasDefault() {
+		semantics.Default = fmt.Sprint(fieldDescriptor.Default().Interface())
+		if enumValueDescriptor := fieldDescriptor.DefaultEnumValue(); enumValueDescriptor != nil {
+			semantics.Default = string(enumValueDescriptor.Name())
+		}
+	}
+	if oneofDescriptor := fieldDescriptor.ContainingOneof(); oneofDescriptor != nil && !oneofDescriptor.IsSynthetic() {
+		semantics.Oneof = oneofDescriptor.Name()
+	}
+	if fieldDescriptor.IsExtension() {
+		semantics.Extendee = fieldDescriptor.ContainingMessage().FullName()
+	}
+	if fieldDescriptor.Kind() == protoreflect.StringKind {
+		utf8Validation, err := resolveFeature(fieldDescriptor, utf8ValidationFeatureField)
+		if err != nil {
+			return fieldSemantics{}, err
+		}
+		semantics.UTF8Validation = utf8Validation
+	}
+	return semantics, nil
+}
+
+// resolveFeature returns the name of the value of the enum feature for the descriptor.
+func resolveFeature(descriptor protoreflect.Descriptor, feature protoreflect.FieldDescriptor) (string, error) {
+	value, err := protoutil.ResolveFeature(descriptor, feature)
+	if err != nil {
+		return "", err
+	}
+	if enumValueDescriptor := feature.Enum().Values().ByNumber(value.Enum()); enumValueDescriptor != nil {
+		return string(enumValueDescriptor.Name()), nil
+	}
+	return fmt.Sprint(value.Enum()), nil
+}
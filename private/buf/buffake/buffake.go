@@ -0,0 +1,60 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buffake generates random messages that satisfy their protovalidate rules.
+package buffake
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Generator generates random messages.
+type Generator interface {
+	// Generate returns a new random message of the given type.
+	//
+	// The message satisfies the standard protovalidate rules of its fields where possible.
+	// Rules that cannot be satisfied by construction, such as CEL expressions, are only
+	// checked if the Generator has a Validator, in which case messages are generated until
+	// one passes validation.
+	Generate(messageType protoreflect.MessageType) (proto.Message, error)
+}
+
+// NewGenerator returns a new Generator.
+//
+// Generators with the same seed generate the same messages for the same sequence of
+// calls to Generate.
+func NewGenerator(seed int64, options ...GeneratorOption) Generator {
+	return newGenerator(seed, options...)
+}
+
+// Validator validates messages.
+type Validator interface {
+	Validate(message proto.Message) error
+}
+
+// GeneratorOption is an option for a new Generator.
+type GeneratorOption func(*generator)
+
+// GeneratorWithValidator returns a new GeneratorOption that validates the generated
+// messages with the Validator.
+//
+// A message that fails validation is discarded, and a new message is generated. If no
+// valid message can be generated after a number of attempts, Generate returns the last
+// validation error.
+func GeneratorWithValidator(validator Validator) GeneratorOption {
+	return func(generator *generator) {
+		generator.validator = validator
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffake
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagetesting"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	messageType := testNewMessageType(t, "test.v1.Constrained")
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	generator := NewGenerator(1)
+	// The rules are satisfied by construction, so validation is not needed.
+	for i := 0; i < 100; i++ {
+		message, err := generator.Generate(messageType)
+		require.NoError(t, err)
+		require.NoError(t, validator.Validate(message), protojson.Format(message))
+		code := message.ProtoReflect().Get(messageType.Descriptor().Fields().ByName("code")).String()
+		require.Regexp(t, regexp.MustCompile(`^[A-Z]{3}-[0-9]{2,4}$`), code)
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	t.Parallel()
+	messageType := testNewMessageType(t, "test.v1.Plain")
+	one := NewGenerator(42)
+	two := NewGenerator(42)
+	three := NewGenerator(43)
+	var differs bool
+	for i := 0; i < 10; i++ {
+		oneMessage, err := one.Generate(messageType)
+		require.NoError(t, err)
+		twoMessage, err := two.Generate(messageType)
+		require.NoError(t, err)
+		threeMessage, err := three.Generate(messageType)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(oneMessage, twoMessage))
+		differs = differs || !proto.Equal(oneMessage, threeMessage)
+	}
+	require.True(t, differs)
+}
+
+func TestGeneratorWithValidator(t *testing.T) {
+	t.Parallel()
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	even := testNewMessageType(t, "test.v1.Even")
+	generator := NewGenerator(1, GeneratorWithValidator(validator))
+	for i := 0; i < 10; i++ {
+		message, err := generator.Generate(even)
+		require.NoError(t, err)
+		require.NoError(t, validator.Validate(message))
+	}
+
+	impossible := testNewMessageType(t, "test.v1.Impossible")
+	_, err = generator.Generate(impossible)
+	require.ErrorContains(t, err, "could not generate a valid test.v1.Impossible after 100 attempts")
+	require.ErrorContains(t, err, "is impossible")
+}
+
+func TestGeneratePattern(t *testing.T) {
+	t.Parallel()
+	generator := newGenerator(1)
+	for _, pattern := range []string{
+		`^[a-z]+$`,
+		`^(foo|bar)\.baz$`,
+		`^\d{3}-\d{4}$`,
+		`^[^a-z]{2}x?$`,
+		`^\w+@\w+\.com$`,
+		`(?i)^abc$`,
+	} {
+		for i := 0; i < 10; i++ {
+			value, err := generator.generatePattern(pattern)
+			require.NoError(t, err)
+			require.Regexp(t, regexp.MustCompile(pattern), value)
+		}
+	}
+	_, err := generator.generatePattern(`(`)
+	require.Error(t, err)
+}
+
+func testNewMessageType(t *testing.T, fullName protoreflect.FullName) protoreflect.MessageType {
+	image := bufimagetesting.BuildImage(
+		t,
+		[]bufmoduletesting.ModuleData{
+			{
+				DirPath: filepath.Join("testdata", "proto"),
+			},
+			{
+				DirPath:     filepath.Join("testdata", "vendor", "protovalidate"),
+				NotTargeted: true,
+			},
+		},
+		bufimage.WithExcludeSourceCodeInfo(),
+	)
+	files, err := protodesc.NewFiles(bufimage.ImageToFileDescriptorSet(image))
+	require.NoError(t, err)
+	descriptor, err := files.FindDescriptorByName(fullName)
+	require.NoError(t, err)
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	require.True(t, ok)
+	return dynamicpb.NewMessageType(messageDescriptor)
+}
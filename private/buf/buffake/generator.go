@@ -0,0 +1,444 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffake
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go/resolver"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// maxAttempts is the number of messages that are generated until one passes validation.
+	maxAttempts = 100
+	// maxDistinctAttempts is the number of values that are generated until one is not
+	// excluded, for example by a not_in rule, or by a unique rule of a repeated field.
+	maxDistinctAttempts = 10
+	// maxOptionalDepth is the depth of nested messages from which only fields that must be
+	// set are generated, so that recursive messages are finite.
+	maxOptionalDepth = 4
+	// maxDepth is the depth of nested messages at which generation fails. It is only
+	// reached by recursive messages with required fields.
+	maxDepth = 32
+	// maxDefaultCount is the number of elements of repeated and map fields that are
+	// generated in addition to their min_items or min_pairs rule at most.
+	maxDefaultCount = 3
+
+	anyFullName       protoreflect.FullName = "google.protobuf.Any"
+	durationFullName  protoreflect.FullName = "google.protobuf.Duration"
+	timestampFullName protoreflect.FullName = "google.protobuf.Timestamp"
+	// valueKindFullName is the oneof of google.protobuf.Value, which must be set for the
+	// value to have a JSON representation.
+	valueKindFullName protoreflect.FullName = "google.protobuf.Value.kind"
+)
+
+// wrapperFullNames are the wrapper messages, whose field rules apply to their value field.
+var wrapperFullNames = map[protoreflect.FullName]struct{}{
+	"google.protobuf.DoubleValue": {},
+	"google.protobuf.FloatValue":  {},
+	"google.protobuf.Int64Value":  {},
+	"google.protobuf.UInt64Value": {},
+	"google.protobuf.Int32Value":  {},
+	"google.protobuf.UInt32Value": {},
+	"google.protobuf.BoolValue":   {},
+	"google.protobuf.StringValue": {},
+	"google.protobuf.BytesValue":  {},
+}
+
+type generator struct {
+	rand      *rand.Rand
+	validator Validator
+}
+
+func newGenerator(seed int64, options ...GeneratorOption) *generator {
+	generator := &generator{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+	for _, option := range options {
+		option(generator)
+	}
+	return generator
+}
+
+func (g *generator) Generate(messageType protoreflect.MessageType) (proto.Message, error) {
+	var validationErr error
+	for i := 0; i < maxAttempts; i++ {
+		message := messageType.New()
+		if err := g.generateMessage(message, nil, 0); err != nil {
+			return nil, err
+		}
+		if g.validator == nil {
+			return message.Interface(), nil
+		}
+		if validationErr = g.validator.Validate(message.Interface()); validationErr == nil {
+			return message.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf(
+		"could not generate a valid %s after %d attempts: %w",
+		messageType.Descriptor().FullName(),
+		maxAttempts,
+		validationErr,
+	)
+}
+
+// generateMessage sets random values to the fields of the message.
+//
+// The constraints are those of the field of the message, if any, which apply to
+// well-known types such as google.protobuf.Timestamp.
+func (g *generator) generateMessage(
+	message protoreflect.Message,
+	constraints *validate.FieldConstraints,
+	depth int,
+) error {
+	messageDescriptor := message.Descriptor()
+	if depth > maxDepth {
+		return fmt.Errorf("%s: exceeded the maximum depth of %d nested messages", messageDescriptor.FullName(), maxDepth)
+	}
+	switch fullName := messageDescriptor.FullName(); fullName {
+	case anyFullName:
+		// The type of the value is not known, so the Any is left empty.
+		return nil
+	case durationFullName:
+		return g.generateDuration(message, constraints.GetDuration())
+	case timestampFullName:
+		return g.generateTimestamp(message, constraints.GetTimestamp())
+	default:
+		if _, ok := wrapperFullNames[fullName]; ok {
+			valueFieldDescriptor := messageDescriptor.Fields().ByName("value")
+			value, err := g.generateValue(message.NewField(valueFieldDescriptor), valueFieldDescriptor, constraints, depth)
+			if err != nil {
+				return err
+			}
+			message.Set(valueFieldDescriptor, value)
+			return nil
+		}
+	}
+	// The numbers of the fields that are set in oneofs, at most one per oneof.
+	oneofFieldNumbers := make(map[protoreflect.FieldNumber]struct{})
+	oneofs := messageDescriptor.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		oneofDescriptor := oneofs.Get(i)
+		if oneofDescriptor.IsSynthetic() {
+			continue
+		}
+		required := oneofDescriptor.FullName() == valueKindFullName ||
+			resolver.DefaultResolver{}.ResolveOneofConstraints(oneofDescriptor).GetRequired()
+		if !required && (depth >= maxOptionalDepth || g.rand.Intn(4) == 0) {
+			continue
+		}
+		oneofFieldNumbers[g.chooseOneofField(oneofDescriptor, depth).Number()] = struct{}{}
+	}
+	fields := messageDescriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fieldDescriptor := fields.Get(i)
+		fieldConstraints := resolver.DefaultResolver{}.ResolveFieldConstraints(fieldDescriptor)
+		if oneofDescriptor := fieldDescriptor.ContainingOneof(); oneofDescriptor != nil && !oneofDescriptor.IsSynthetic() {
+			if _, ok := oneofFieldNumbers[fieldDescriptor.Number()]; !ok {
+				continue
+			}
+		} else if !g.shouldGenerateField(fieldDescriptor, fieldConstraints, depth) {
+			continue
+		}
+		switch {
+		case fieldDescriptor.IsList():
+			if err := g.generateList(message.Mutable(fieldDescriptor).List(), fieldDescriptor, fieldConstraints, depth); err != nil {
+				return err
+			}
+		case fieldDescriptor.IsMap():
+			if err := g.generateMap(message.Mutable(fieldDescriptor).Map(), fieldDescriptor, fieldConstraints, depth); err != nil {
+				return err
+			}
+		default:
+			value, err := g.generateValue(message.NewField(fieldDescriptor), fieldDescriptor, fieldConstraints, depth)
+			if err != nil {
+				return err
+			}
+			message.Set(fieldDescriptor, value)
+		}
+	}
+	return nil
+}
+
+// shouldGenerateField returns true if a value should be generated for the field, which
+// is not part of a oneof.
+func (g *generator) shouldGenerateField(
+	fieldDescriptor protoreflect.FieldDescriptor,
+	constraints *validate.FieldConstraints,
+	depth int,
+) bool {
+	switch {
+	case constraints.GetRequired(), fieldDescriptor.Cardinality() == protoreflect.Required:
+		return true
+	case fieldDescriptor.IsList(), fieldDescriptor.IsMap():
+		// The number of elements depends on the depth.
+		return true
+	case !fieldDescriptor.HasPresence():
+		return true
+	case fieldDescriptor.Message() != nil && depth >= maxOptionalDepth:
+		return false
+	default:
+		return g.rand.Intn(4) != 0
+	}
+}
+
+// chooseOneofField returns a random field of the oneof.
+//
+// From maxOptionalDepth, fields that are not messages are preferred.
+func (g *generator) chooseOneofField(oneofDescriptor protoreflect.OneofDescriptor, depth int) protoreflect.FieldDescriptor {
+	fields := oneofDescriptor.Fields()
+	var candidates []protoreflect.FieldDescriptor
+	for i := 0; i < fields.Len(); i++ {
+		if fieldDescriptor := fields.Get(i); depth < maxOptionalDepth || fieldDescriptor.Message() == nil {
+			candidates = append(candidates, fieldDescriptor)
+		}
+	}
+	if len(candidates) == 0 {
+		return fields.Get(g.rand.Intn(fields.Len()))
+	}
+	return candidates[g.rand.Intn(len(candidates))]
+}
+
+func (g *generator) generateList(
+	list protoreflect.List,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	constraints *validate.FieldConstraints,
+	depth int,
+) error {
+	repeatedRules := constraints.GetRepeated()
+	var maxItems *uint64
+	if repeatedRules != nil {
+		maxItems = repeatedRules.MaxItems
+	}
+	count := g.generateCount(repeatedRules.GetMinItems(), maxItems, depth)
+	for i := 0; i < count; i++ {
+		value, err := g.generateValue(list.NewElement(), fieldDescriptor, repeatedRules.GetItems(), depth)
+		if err != nil {
+			return err
+		}
+		if repeatedRules.GetUnique() {
+			for attempt := 0; attempt < maxDistinctAttempts && listContains(list, value); attempt++ {
+				if value, err = g.generateValue(list.NewElement(), fieldDescriptor, repeatedRules.GetItems(), depth); err != nil {
+					return err
+				}
+			}
+			if listContains(list, value) && uint64(list.Len()) >= repeatedRules.GetMinItems() {
+				// There may be no more unique values, so the list is as long as needed.
+				return nil
+			}
+		}
+		list.Append(value)
+	}
+	return nil
+}
+
+func (g *generator) generateMap(
+	mapValue protoreflect.Map,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	constraints *validate.FieldConstraints,
+	depth int,
+) error {
+	mapRules := constraints.GetMap()
+	var maxPairs *uint64
+	if mapRules != nil {
+		maxPairs = mapRules.MaxPairs
+	}
+	count := g.generateCount(mapRules.GetMinPairs(), maxPairs, depth)
+	for i := 0; i < count; i++ {
+		var mapKey protoreflect.MapKey
+		for attempt := 0; ; attempt++ {
+			key, err := g.generateValue(protoreflect.Value{}, fieldDescriptor.MapKey(), mapRules.GetKeys(), depth)
+			if err != nil {
+				return err
+			}
+			mapKey = key.MapKey()
+			if attempt >= maxDistinctAttempts || !mapValue.Has(mapKey) {
+				break
+			}
+		}
+		value, err := g.generateValue(mapValue.NewValue(), fieldDescriptor.MapValue(), mapRules.GetValues(), depth)
+		if err != nil {
+			return err
+		}
+		mapValue.Set(mapKey, value)
+	}
+	return nil
+}
+
+// generateCount returns the number of elements of a repeated or map field.
+func (g *generator) generateCount(minCount uint64, maxCount *uint64, depth int) int {
+	upper := minCount + maxDefaultCount
+	if depth >= maxOptionalDepth {
+		upper = minCount
+	}
+	if maxCount != nil && *maxCount < upper {
+		upper = *maxCount
+	}
+	if upper <= minCount {
+		return int(minCount)
+	}
+	return int(minCount) + g.rand.Intn(int(upper-minCount)+1)
+}
+
+// generateValue returns a random singular value of the field, or a random element or map
+// value for repeated and map fields.
+//
+// The newValue is the value that is set for messages, such as the result of NewField.
+func (g *generator) generateValue(
+	newValue protoreflect.Value,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	constraints *validate.FieldConstraints,
+	depth int,
+) (protoreflect.Value, error) {
+	switch kind := fieldDescriptor.Kind(); kind {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if err := g.generateMessage(newValue.Message(), constraints, depth+1); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return newValue, nil
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(g.generateBool(constraints.GetBool())), nil
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(g.generateEnum(fieldDescriptor.Enum(), constraints.GetEnum())), nil
+	case protoreflect.StringKind:
+		value, err := g.generateString(constraints.GetString_())
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		value, err := g.generateBytes(constraints.GetBytes())
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(value), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return g.generateFloat(kind, getTypeRules(constraints)), nil
+	default:
+		return g.generateInteger(kind, getTypeRules(constraints)), nil
+	}
+}
+
+func (g *generator) generateBool(rules *validate.BoolRules) bool {
+	if rules != nil && rules.Const != nil {
+		return rules.GetConst()
+	}
+	return g.rand.Intn(2) == 0
+}
+
+func (g *generator) generateEnum(enumDescriptor protoreflect.EnumDescriptor, rules *validate.EnumRules) protoreflect.EnumNumber {
+	if rules != nil && rules.Const != nil {
+		return protoreflect.EnumNumber(rules.GetConst())
+	}
+	var candidates []protoreflect.EnumNumber
+	if in := rules.GetIn(); len(in) > 0 {
+		for _, number := range in {
+			candidates = append(candidates, protoreflect.EnumNumber(number))
+		}
+	} else {
+		values := enumDescriptor.Values()
+		for i := 0; i < values.Len(); i++ {
+			candidates = append(candidates, values.Get(i).Number())
+		}
+	}
+	var allowed []protoreflect.EnumNumber
+	for _, candidate := range candidates {
+		if !slices.Contains(rules.GetNotIn(), int32(candidate)) {
+			allowed = append(allowed, candidate)
+		}
+	}
+	if len(allowed) == 0 {
+		allowed = candidates
+	}
+	return allowed[g.rand.Intn(len(allowed))]
+}
+
+// getTypeRules returns the rules of the type of the field, such as buf.validate.Int32Rules,
+// or nil if there are none.
+func getTypeRules(constraints *validate.FieldConstraints) protoreflect.Message {
+	if constraints == nil {
+		return nil
+	}
+	message := constraints.ProtoReflect()
+	fieldDescriptor := message.WhichOneof(message.Descriptor().Oneofs().ByName("type"))
+	if fieldDescriptor == nil || fieldDescriptor.Message() == nil {
+		return nil
+	}
+	return message.Get(fieldDescriptor).Message()
+}
+
+// getRule returns the value of the field of the rules with the name, if it is set.
+func getRule(rules protoreflect.Message, name protoreflect.Name) (protoreflect.Value, bool) {
+	if rules == nil {
+		return protoreflect.Value{}, false
+	}
+	fieldDescriptor := rules.Descriptor().Fields().ByName(name)
+	if fieldDescriptor == nil || !rules.Has(fieldDescriptor) {
+		return protoreflect.Value{}, false
+	}
+	return rules.Get(fieldDescriptor), true
+}
+
+// getRuleList returns the values of the repeated field of the rules with the name.
+func getRuleList(rules protoreflect.Message, name protoreflect.Name) []protoreflect.Value {
+	value, ok := getRule(rules, name)
+	if !ok {
+		return nil
+	}
+	list := value.List()
+	values := make([]protoreflect.Value, list.Len())
+	for i := range values {
+		values[i] = list.Get(i)
+	}
+	return values
+}
+
+// chooseValue returns a random value of the values that is not one of the excluded
+// values, or a random value of the values if all are excluded.
+func (g *generator) chooseValue(values []protoreflect.Value, excluded []protoreflect.Value) protoreflect.Value {
+	var allowed []protoreflect.Value
+	for _, value := range values {
+		if !valuesContain(excluded, value) {
+			allowed = append(allowed, value)
+		}
+	}
+	if len(allowed) == 0 {
+		allowed = values
+	}
+	return allowed[g.rand.Intn(len(allowed))]
+}
+
+func valuesContain(values []protoreflect.Value, value protoreflect.Value) bool {
+	for _, other := range values {
+		if other.Equal(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func listContains(list protoreflect.List, value protoreflect.Value) bool {
+	for i := 0; i < list.Len(); i++ {
+		if list.Get(i).Equal(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,377 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffake
+
+import (
+	"math"
+	"time"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// defaultNumberSpan is the width of the range of numbers without bounds, or with a
+	// bound on one side only.
+	defaultNumberSpan = 1000
+	// defaultDurationSpan is the width of the range of durations, in seconds.
+	defaultDurationSpan = 60 * 60
+	// defaultTimestampSpan is the width of the range of timestamps, in seconds.
+	defaultTimestampSpan = 365 * 24 * 60 * 60
+
+	minDurationSeconds = -315576000000
+	maxDurationSeconds = 315576000000
+	// minTimestampSeconds is 0001-01-01T00:00:00Z.
+	minTimestampSeconds = -62135596800
+	// maxTimestampSeconds is 9999-12-31T23:59:59Z.
+	maxTimestampSeconds = 253402300799
+)
+
+// defaultTimestampSeconds is the upper bound of timestamps without bounds, which is fixed
+// so that the generated messages do not depend on the current time.
+var defaultTimestampSeconds = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+// bounds are the inclusive bounds of an integer.
+type bounds struct {
+	lower    int64
+	upper    int64
+	hasLower bool
+	hasUpper bool
+	// min and max are the bounds of the type of the integer.
+	min int64
+	max int64
+}
+
+func (b *bounds) setLower(lower int64) {
+	if !b.hasLower || lower > b.lower {
+		b.lower = lower
+		b.hasLower = true
+	}
+}
+
+func (b *bounds) setUpper(upper int64) {
+	if !b.hasUpper || upper < b.upper {
+		b.upper = upper
+		b.hasUpper = true
+	}
+}
+
+// generateInt64 returns a random integer within the bounds.
+//
+// Without bounds, the integer is within span of defaultLower. With a bound on one side,
+// the integer is within span of the bound. If the lower bound is greater than the upper
+// bound, the range is exclusive, and the integer is either at most the upper bound, or
+// at least the lower bound.
+func (g *generator) generateInt64(b bounds, defaultLower int64, span int64) int64 {
+	var lower, upper int64
+	switch {
+	case b.hasLower && b.hasUpper && b.lower > b.upper:
+		if g.rand.Intn(2) == 0 {
+			lower, upper = subtractSaturated(b.upper, span, b.min), b.upper
+		} else {
+			lower, upper = b.lower, addSaturated(b.lower, span, b.max)
+		}
+	case b.hasLower && b.hasUpper:
+		lower, upper = b.lower, b.upper
+	case b.hasLower:
+		lower, upper = b.lower, addSaturated(b.lower, span, b.max)
+	case b.hasUpper:
+		lower, upper = subtractSaturated(b.upper, span, b.min), b.upper
+	default:
+		lower = max(defaultLower, b.min)
+		upper = addSaturated(lower, span, b.max)
+	}
+	width := uint64(upper) - uint64(lower)
+	if width == math.MaxUint64 {
+		return int64(g.rand.Uint64())
+	}
+	return int64(uint64(lower) + g.rand.Uint64()%(width+1))
+}
+
+// generateInteger returns a random integer of the kind that satisfies the rules, such as
+// buf.validate.Int32Rules.
+func (g *generator) generateInteger(kind protoreflect.Kind, rules protoreflect.Message) protoreflect.Value {
+	if value, ok := getRule(rules, "const"); ok {
+		return value
+	}
+	notIn := getRuleList(rules, "not_in")
+	if in := getRuleList(rules, "in"); len(in) > 0 {
+		return g.chooseValue(in, notIn)
+	}
+	if examples := getRuleList(rules, "example"); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		return g.chooseValue(examples, notIn)
+	}
+	minValue, maxValue := getIntegerKindBounds(kind)
+	b := bounds{min: minValue, max: maxValue}
+	if value, ok := getRule(rules, "gt"); ok {
+		b.setLower(addSaturated(valueToInt64(value), 1, maxValue))
+	}
+	if value, ok := getRule(rules, "gte"); ok {
+		b.setLower(valueToInt64(value))
+	}
+	if value, ok := getRule(rules, "lt"); ok {
+		b.setUpper(subtractSaturated(valueToInt64(value), 1, minValue))
+	}
+	if value, ok := getRule(rules, "lte"); ok {
+		b.setUpper(valueToInt64(value))
+	}
+	for attempt := 0; ; attempt++ {
+		value := int64ToValue(kind, g.generateInt64(b, 0, defaultNumberSpan))
+		if attempt >= maxDistinctAttempts || !valuesContain(notIn, value) {
+			return value
+		}
+	}
+}
+
+// generateFloat returns a random float or double that satisfies the rules, such as
+// buf.validate.FloatRules.
+//
+// Exclusive bounds are treated as inclusive, as generating the bound itself is unlikely.
+func (g *generator) generateFloat(kind protoreflect.Kind, rules protoreflect.Message) protoreflect.Value {
+	if value, ok := getRule(rules, "const"); ok {
+		return value
+	}
+	notIn := getRuleList(rules, "not_in")
+	if in := getRuleList(rules, "in"); len(in) > 0 {
+		return g.chooseValue(in, notIn)
+	}
+	if examples := getRuleList(rules, "example"); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		return g.chooseValue(examples, notIn)
+	}
+	var lowerBound, upperBound float64
+	var hasLowerBound, hasUpperBound bool
+	for _, name := range []protoreflect.Name{"gt", "gte"} {
+		if value, ok := getRule(rules, name); ok {
+			lowerBound, hasLowerBound = value.Float(), true
+		}
+	}
+	for _, name := range []protoreflect.Name{"lt", "lte"} {
+		if value, ok := getRule(rules, name); ok {
+			upperBound, hasUpperBound = value.Float(), true
+		}
+	}
+	var lower, upper float64
+	switch {
+	case hasLowerBound && hasUpperBound && lowerBound > upperBound:
+		if g.rand.Intn(2) == 0 {
+			lower, upper = upperBound-defaultNumberSpan, upperBound
+		} else {
+			lower, upper = lowerBound, lowerBound+defaultNumberSpan
+		}
+	case hasLowerBound && hasUpperBound:
+		lower, upper = lowerBound, upperBound
+	case hasLowerBound:
+		lower, upper = lowerBound, lowerBound+defaultNumberSpan
+	case hasUpperBound:
+		lower, upper = upperBound-defaultNumberSpan, upperBound
+	default:
+		lower, upper = 0, defaultNumberSpan
+	}
+	for attempt := 0; ; attempt++ {
+		float := lower + g.rand.Float64()*(upper-lower)
+		value := protoreflect.ValueOfFloat64(float)
+		if kind == protoreflect.FloatKind {
+			value = protoreflect.ValueOfFloat32(float32(float))
+		}
+		if attempt >= maxDistinctAttempts || !valuesContain(notIn, value) {
+			return value
+		}
+	}
+}
+
+// generateDuration sets a random google.protobuf.Duration of whole seconds that
+// satisfies the rules to the message.
+func (g *generator) generateDuration(message protoreflect.Message, rules *validate.DurationRules) error {
+	if duration := rules.GetConst(); duration != nil {
+		setSecondsAndNanos(message, duration.GetSeconds(), duration.GetNanos())
+		return nil
+	}
+	if in := rules.GetIn(); len(in) > 0 {
+		duration := in[g.rand.Intn(len(in))]
+		setSecondsAndNanos(message, duration.GetSeconds(), duration.GetNanos())
+		return nil
+	}
+	if examples := rules.GetExample(); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		duration := examples[g.rand.Intn(len(examples))]
+		setSecondsAndNanos(message, duration.GetSeconds(), duration.GetNanos())
+		return nil
+	}
+	b := bounds{min: minDurationSeconds, max: maxDurationSeconds}
+	if duration := rules.GetGt(); duration != nil {
+		b.setLower(getLowerSeconds(duration.GetSeconds(), duration.GetNanos(), true))
+	}
+	if duration := rules.GetGte(); duration != nil {
+		b.setLower(getLowerSeconds(duration.GetSeconds(), duration.GetNanos(), false))
+	}
+	if duration := rules.GetLt(); duration != nil {
+		b.setUpper(getUpperSeconds(duration.GetSeconds(), duration.GetNanos(), true))
+	}
+	if duration := rules.GetLte(); duration != nil {
+		b.setUpper(getUpperSeconds(duration.GetSeconds(), duration.GetNanos(), false))
+	}
+	for attempt := 0; ; attempt++ {
+		seconds := g.generateInt64(b, 0, defaultDurationSpan)
+		if attempt >= maxDistinctAttempts || !durationsContainSeconds(rules.GetNotIn(), seconds) {
+			setSecondsAndNanos(message, seconds, 0)
+			return nil
+		}
+	}
+}
+
+// generateTimestamp sets a random google.protobuf.Timestamp of whole seconds that
+// satisfies the rules to the message.
+//
+// The current time is only used by the lt_now, gt_now, and within rules.
+func (g *generator) generateTimestamp(message protoreflect.Message, rules *validate.TimestampRules) error {
+	if timestamp := rules.GetConst(); timestamp != nil {
+		setSecondsAndNanos(message, timestamp.GetSeconds(), timestamp.GetNanos())
+		return nil
+	}
+	if examples := rules.GetExample(); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		timestamp := examples[g.rand.Intn(len(examples))]
+		setSecondsAndNanos(message, timestamp.GetSeconds(), timestamp.GetNanos())
+		return nil
+	}
+	b := bounds{min: minTimestampSeconds, max: maxTimestampSeconds}
+	if timestamp := rules.GetGt(); timestamp != nil {
+		b.setLower(getLowerSeconds(timestamp.GetSeconds(), timestamp.GetNanos(), true))
+	}
+	if timestamp := rules.GetGte(); timestamp != nil {
+		b.setLower(getLowerSeconds(timestamp.GetSeconds(), timestamp.GetNanos(), false))
+	}
+	if timestamp := rules.GetLt(); timestamp != nil {
+		b.setUpper(getUpperSeconds(timestamp.GetSeconds(), timestamp.GetNanos(), true))
+	}
+	if timestamp := rules.GetLte(); timestamp != nil {
+		b.setUpper(getUpperSeconds(timestamp.GetSeconds(), timestamp.GetNanos(), false))
+	}
+	if rules.GetLtNow() || rules.GetGtNow() || rules.GetWithin() != nil {
+		// The bounds are a second inside of the current time, as the message is validated
+		// after it is generated.
+		now := time.Now().Unix()
+		if rules.GetLtNow() {
+			b.setUpper(now - 1)
+		}
+		if rules.GetGtNow() {
+			b.setLower(now + 1)
+		}
+		if within := rules.GetWithin(); within != nil {
+			b.setLower(now - within.GetSeconds() + 1)
+			b.setUpper(now + within.GetSeconds() - 1)
+		}
+	}
+	setSecondsAndNanos(message, g.generateInt64(b, defaultTimestampSeconds-defaultTimestampSpan, defaultTimestampSpan), 0)
+	return nil
+}
+
+// getIntegerKindBounds returns the minimum and maximum integer of the kind.
+//
+// The maximum of 64-bit unsigned integers is math.MaxInt64, so that all integers are int64s.
+func getIntegerKindBounds(kind protoreflect.Kind) (int64, int64) {
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return math.MinInt32, math.MaxInt32
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return 0, math.MaxUint32
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return 0, math.MaxInt64
+	default:
+		return math.MinInt64, math.MaxInt64
+	}
+}
+
+func valueToInt64(value protoreflect.Value) int64 {
+	switch value := value.Interface().(type) {
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	case uint32:
+		return int64(value)
+	case uint64:
+		if value > math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return int64(value)
+	default:
+		return 0
+	}
+}
+
+func int64ToValue(kind protoreflect.Kind, value int64) protoreflect.Value {
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(value))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(value))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(value))
+	default:
+		return protoreflect.ValueOfInt64(value)
+	}
+}
+
+// addSaturated returns value+delta, or maxValue if the sum is greater than maxValue.
+func addSaturated(value int64, delta int64, maxValue int64) int64 {
+	if value > maxValue-delta {
+		return maxValue
+	}
+	return value + delta
+}
+
+// subtractSaturated returns value-delta, or minValue if the difference is less than minValue.
+func subtractSaturated(value int64, delta int64, minValue int64) int64 {
+	if value < minValue+delta {
+		return minValue
+	}
+	return value - delta
+}
+
+// getLowerSeconds returns the least whole number of seconds that is greater than, or
+// equal to unless exclusive, the seconds and nanos.
+func getLowerSeconds(seconds int64, nanos int32, exclusive bool) int64 {
+	if nanos > 0 || (exclusive && nanos == 0) {
+		return seconds + 1
+	}
+	return seconds
+}
+
+// getUpperSeconds returns the greatest whole number of seconds that is less than, or
+// equal to unless exclusive, the seconds and nanos.
+func getUpperSeconds(seconds int64, nanos int32, exclusive bool) int64 {
+	if nanos < 0 || (exclusive && nanos == 0) {
+		return seconds - 1
+	}
+	return seconds
+}
+
+func durationsContainSeconds(durations []*durationpb.Duration, seconds int64) bool {
+	for _, duration := range durations {
+		if duration.GetSeconds() == seconds && duration.GetNanos() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setSecondsAndNanos sets the fields of a google.protobuf.Duration or google.protobuf.Timestamp.
+func setSecondsAndNanos(message protoreflect.Message, seconds int64, nanos int32) {
+	fields := message.Descriptor().Fields()
+	message.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(seconds))
+	if nanos != 0 {
+		message.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(nanos))
+	}
+}
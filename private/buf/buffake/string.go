@@ -0,0 +1,340 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buffake
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultMinLength is the minimum length of strings and bytes without length rules.
+	defaultMinLength = 4
+	// defaultLengthSpan is the width of the range of lengths of strings and bytes without
+	// a maximum length.
+	defaultLengthSpan = 8
+	// maxPatternRepeat is the number of repetitions that are generated for unbounded
+	// repetitions in patterns, such as a*, in addition to their minimum.
+	maxPatternRepeat = 3
+	// letters are the characters of generated text.
+	letters = "abcdefghijklmnopqrstuvwxyz"
+)
+
+func (g *generator) generateString(rules *validate.StringRules) (string, error) {
+	if rules == nil {
+		rules = &validate.StringRules{}
+	}
+	if rules.Const != nil {
+		return rules.GetConst(), nil
+	}
+	if in := rules.GetIn(); len(in) > 0 {
+		return in[g.rand.Intn(len(in))], nil
+	}
+	if examples := rules.GetExample(); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		return examples[g.rand.Intn(len(examples))], nil
+	}
+	for attempt := 0; ; attempt++ {
+		value, err := g.generateStringCandidate(rules)
+		if err != nil {
+			return "", err
+		}
+		excluded := slices.Contains(rules.GetNotIn(), value) ||
+			(rules.NotContains != nil && strings.Contains(value, rules.GetNotContains()))
+		if attempt >= maxDistinctAttempts || !excluded {
+			return value, nil
+		}
+	}
+}
+
+// generateStringCandidate returns a random string that satisfies the rules, except for
+// the rules that exclude values, such as not_in.
+func (g *generator) generateStringCandidate(rules *validate.StringRules) (string, error) {
+	switch {
+	case rules.GetEmail():
+		return g.generateWord() + "@" + g.generateHostname(), nil
+	case rules.GetHostname():
+		return g.generateHostname(), nil
+	case rules.GetIp(), rules.GetIpv4(), rules.GetAddress():
+		return g.generateIPv4().String(), nil
+	case rules.GetIpv6():
+		return g.generateIPv6().String(), nil
+	case rules.GetUri():
+		return "https://" + g.generateHostname() + "/" + g.generateWord(), nil
+	case rules.GetUriRef():
+		return "/" + g.generateWord() + "/" + g.generateWord(), nil
+	case rules.GetUuid():
+		return g.generateUUID()
+	case rules.GetTuuid():
+		value, err := g.generateUUID()
+		return strings.ReplaceAll(value, "-", ""), err
+	case rules.GetIpWithPrefixlen(), rules.GetIpv4WithPrefixlen():
+		return netip.PrefixFrom(g.generateIPv4(), g.rand.Intn(33)).String(), nil
+	case rules.GetIpv6WithPrefixlen():
+		return netip.PrefixFrom(g.generateIPv6(), g.rand.Intn(129)).String(), nil
+	case rules.GetIpPrefix(), rules.GetIpv4Prefix():
+		return netip.PrefixFrom(g.generateIPv4(), g.rand.Intn(33)).Masked().String(), nil
+	case rules.GetIpv6Prefix():
+		return netip.PrefixFrom(g.generateIPv6(), g.rand.Intn(129)).Masked().String(), nil
+	case rules.GetHostAndPort():
+		return g.generateHostname() + ":" + strconv.Itoa(1024+g.rand.Intn(64512)), nil
+	case rules.GetWellKnownRegex() != validate.KnownRegex_KNOWN_REGEX_UNSPECIFIED:
+		// Words are both valid HTTP header names and values.
+		return g.generateWord(), nil
+	case rules.Pattern != nil:
+		return g.generatePattern(rules.GetPattern())
+	}
+	minLength, maxLength := getLengthBounds(
+		[]uint64{rules.GetMinLen(), rules.GetMinBytes(), rules.GetLen(), rules.GetLenBytes()},
+		[]*uint64{rules.MaxLen, rules.MaxBytes, rules.Len, rules.LenBytes},
+	)
+	return g.generateWithAffixes(
+		minLength,
+		maxLength,
+		rules.GetPrefix(),
+		rules.GetContains(),
+		rules.GetSuffix(),
+		g.generateLetters,
+	), nil
+}
+
+func (g *generator) generateBytes(rules *validate.BytesRules) ([]byte, error) {
+	if rules == nil {
+		rules = &validate.BytesRules{}
+	}
+	if rules.Const != nil {
+		return rules.GetConst(), nil
+	}
+	if in := rules.GetIn(); len(in) > 0 {
+		return in[g.rand.Intn(len(in))], nil
+	}
+	if examples := rules.GetExample(); len(examples) > 0 && g.rand.Intn(2) == 0 {
+		return examples[g.rand.Intn(len(examples))], nil
+	}
+	for attempt := 0; ; attempt++ {
+		value, err := g.generateBytesCandidate(rules)
+		if err != nil {
+			return nil, err
+		}
+		excluded := slices.ContainsFunc(rules.GetNotIn(), func(other []byte) bool {
+			return bytes.Equal(other, value)
+		})
+		if attempt >= maxDistinctAttempts || !excluded {
+			return value, nil
+		}
+	}
+}
+
+// generateBytesCandidate returns random bytes that satisfy the rules, except for the
+// rules that exclude values, such as not_in.
+func (g *generator) generateBytesCandidate(rules *validate.BytesRules) ([]byte, error) {
+	switch {
+	case rules.GetIp():
+		if g.rand.Intn(2) == 0 {
+			return g.generateIPv6().AsSlice(), nil
+		}
+		return g.generateIPv4().AsSlice(), nil
+	case rules.GetIpv4():
+		return g.generateIPv4().AsSlice(), nil
+	case rules.GetIpv6():
+		return g.generateIPv6().AsSlice(), nil
+	case rules.Pattern != nil:
+		value, err := g.generatePattern(rules.GetPattern())
+		return []byte(value), err
+	}
+	minLength, maxLength := getLengthBounds(
+		[]uint64{rules.GetMinLen(), rules.GetLen()},
+		[]*uint64{rules.MaxLen, rules.Len},
+	)
+	return []byte(
+		g.generateWithAffixes(
+			minLength,
+			maxLength,
+			string(rules.GetPrefix()),
+			string(rules.GetContains()),
+			string(rules.GetSuffix()),
+			g.generateRandomBytes,
+		),
+	), nil
+}
+
+// generateWithAffixes returns a random string of bytes within the length bounds that
+// starts with the prefix, contains contains, and ends with the suffix. The rest of the
+// string is generated with generateBody.
+//
+// A maxLength of -1 is unbounded.
+func (g *generator) generateWithAffixes(
+	minLength int,
+	maxLength int,
+	prefix string,
+	contains string,
+	suffix string,
+	generateBody func(int) string,
+) string {
+	affixLength := len(prefix) + len(contains) + len(suffix)
+	lower := max(minLength, affixLength)
+	if minLength == 0 {
+		lower = max(lower, defaultMinLength)
+		if maxLength >= 0 {
+			lower = min(lower, max(maxLength, affixLength))
+		}
+	}
+	upper := lower + defaultLengthSpan
+	if maxLength >= 0 {
+		upper = max(min(upper, maxLength), lower)
+	}
+	bodyLength := lower + g.rand.Intn(upper-lower+1) - affixLength
+	split := g.rand.Intn(bodyLength + 1)
+	return prefix + generateBody(split) + contains + generateBody(bodyLength-split) + suffix
+}
+
+// generatePattern returns a random string that matches the RE2 pattern.
+func (g *generator) generatePattern(pattern string) (string, error) {
+	regexp, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	var builder strings.Builder
+	g.generateRegexp(&builder, regexp.Simplify())
+	return builder.String(), nil
+}
+
+func (g *generator) generateRegexp(builder *strings.Builder, regexp *syntax.Regexp) {
+	switch regexp.Op {
+	case syntax.OpLiteral:
+		builder.WriteString(string(regexp.Rune))
+	case syntax.OpCharClass:
+		builder.WriteRune(g.generateCharClassRune(regexp.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		builder.WriteByte(letters[g.rand.Intn(len(letters))])
+	case syntax.OpCapture:
+		g.generateRegexp(builder, regexp.Sub[0])
+	case syntax.OpStar:
+		g.generateRegexpRepeat(builder, regexp.Sub[0], 0, -1)
+	case syntax.OpPlus:
+		g.generateRegexpRepeat(builder, regexp.Sub[0], 1, -1)
+	case syntax.OpQuest:
+		g.generateRegexpRepeat(builder, regexp.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		g.generateRegexpRepeat(builder, regexp.Sub[0], regexp.Min, regexp.Max)
+	case syntax.OpConcat:
+		for _, sub := range regexp.Sub {
+			g.generateRegexp(builder, sub)
+		}
+	case syntax.OpAlternate:
+		g.generateRegexp(builder, regexp.Sub[g.rand.Intn(len(regexp.Sub))])
+	default:
+		// Empty matches and assertions, such as ^ and \b, do not generate anything.
+	}
+}
+
+// generateRegexpRepeat generates the regexp between minCount and maxCount times, where a
+// maxCount of -1 is unbounded.
+func (g *generator) generateRegexpRepeat(builder *strings.Builder, regexp *syntax.Regexp, minCount int, maxCount int) {
+	if maxCount < 0 {
+		maxCount = minCount + maxPatternRepeat
+	}
+	count := minCount + g.rand.Intn(maxCount-minCount+1)
+	for i := 0; i < count; i++ {
+		g.generateRegexp(builder, regexp)
+	}
+}
+
+// generateCharClassRune returns a random rune of the character class, given as pairs of
+// inclusive ranges. Printable ASCII characters are preferred.
+func (g *generator) generateCharClassRune(ranges []rune) rune {
+	var printableRanges []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if lower, upper := max(ranges[i], ' '), min(ranges[i+1], '~'); lower <= upper {
+			printableRanges = append(printableRanges, lower, upper)
+		}
+	}
+	if len(printableRanges) > 0 {
+		ranges = printableRanges
+	}
+	if len(ranges) < 2 {
+		return 'a'
+	}
+	i := 2 * g.rand.Intn(len(ranges)/2)
+	return ranges[i] + rune(g.rand.Intn(int(ranges[i+1]-ranges[i])+1))
+}
+
+func (g *generator) generateLetters(length int) string {
+	value := make([]byte, length)
+	for i := range value {
+		value[i] = letters[g.rand.Intn(len(letters))]
+	}
+	return string(value)
+}
+
+func (g *generator) generateRandomBytes(length int) string {
+	value := make([]byte, length)
+	_, _ = g.rand.Read(value)
+	return string(value)
+}
+
+// generateWord returns random letters of a typical word length.
+func (g *generator) generateWord() string {
+	return g.generateLetters(defaultMinLength + g.rand.Intn(defaultLengthSpan/2))
+}
+
+func (g *generator) generateHostname() string {
+	return g.generateWord() + ".example.com"
+}
+
+func (g *generator) generateIPv4() netip.Addr {
+	var addr [4]byte
+	_, _ = g.rand.Read(addr[:])
+	return netip.AddrFrom4(addr)
+}
+
+func (g *generator) generateIPv6() netip.Addr {
+	var addr [16]byte
+	_, _ = g.rand.Read(addr[:])
+	// Avoid IPv4-mapped addresses, which are printed as IPv4 addresses.
+	addr[0] |= 0x20
+	return netip.AddrFrom16(addr)
+}
+
+func (g *generator) generateUUID() (string, error) {
+	value, err := uuid.NewRandomFromReader(g.rand)
+	if err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// getLengthBounds returns the greatest of the minimum lengths, and the least of the
+// maximum lengths that are set, or -1 if none are set.
+func getLengthBounds(minLengths []uint64, maxLengths []*uint64) (int, int) {
+	var minLength uint64
+	for _, length := range minLengths {
+		minLength = max(minLength, length)
+	}
+	maxLength := -1
+	for _, length := range maxLengths {
+		if length != nil && (maxLength < 0 || int(*length) < maxLength) {
+			maxLength = int(*length)
+		}
+	}
+	return int(minLength), maxLength
+}
@@ -42,6 +42,12 @@ const (
 	MessageEncodingTxtpb
 	// MessageEncodingYAML is the YAML message encoding.
 	MessageEncodingYAML
+	// MessageEncodingBinpbDelimited is the length-delimited binary message stream encoding.
+	//
+	// Each message is prefixed by its length as a varint.
+	MessageEncodingBinpbDelimited
+	// MessageEncodingJSONL is the newline-delimited JSON message stream encoding.
+	MessageEncodingJSONL
 
 	useProtoNamesKey  = "use_proto_names"
 	useEnumNumbersKey = "use_enum_numbers"
@@ -52,6 +58,8 @@ var (
 	//
 	// This does not include deprecated formats.
 	MessageFormatsString = stringutil.SliceToString(messageFormatsNotDeprecated)
+	// MessageStreamFormatsString is the string representation of all message stream formats.
+	MessageStreamFormatsString = stringutil.SliceToString(messageStreamFormats)
 	// SourceDirFormatsString is the string representation of all source directory formats.
 	// This includes all of the formats in SourceFormatsString except the protofile format.
 	//
@@ -264,6 +272,15 @@ func MessageRefParserWithDefaultMessageEncoding(defaultMessageEncoding MessageEn
 	}
 }
 
+// MessageRefParserWithMessageStreams says to also accept the message stream formats.
+//
+// By default, only the formats of single messages are accepted.
+func MessageRefParserWithMessageStreams() MessageRefParserOption {
+	return func(messageRefParserOptions *messageRefParserOptions) {
+		messageRefParserOptions.messageStreams = true
+	}
+}
+
 // NewSourceRefParser returns a new RefParser for sources only.
 //
 // This defaults to dir.
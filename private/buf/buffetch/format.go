@@ -17,6 +17,9 @@ package buffetch
 const (
 	// formatBinpb is the protobuf binary format.
 	formatBinpb = "binpb"
+	// formatBinpbDelimited is the protobuf binary format for streams of messages, each
+	// prefixed by its varint-encoded length.
+	formatBinpbDelimited = "binpb-delimited"
 	// formatTxtpb is the protobuf text format.
 	formatTxtpb = "txtpb"
 	// formatDir is the directory format.
@@ -25,6 +28,8 @@ const (
 	formatGit = "git"
 	// formatJSON is the JSON format.
 	formatJSON = "json"
+	// formatJSONL is the JSON format for streams of messages, with one message per line.
+	formatJSONL = "jsonl"
 	// formatYAML is the YAML format.
 	formatYAML = "yaml"
 	// formatMod is the module format.
@@ -65,6 +70,23 @@ var (
 		formatYAML,
 	}
 	// sorted
+	messageStreamFormats = []string{
+		formatBinpbDelimited,
+		formatJSONL,
+	}
+	// sorted
+	messageFormatsWithStreams = []string{
+		formatBin,
+		formatBinpb,
+		formatBinpbDelimited,
+		formatBingz,
+		formatJSON,
+		formatJSONGZ,
+		formatJSONL,
+		formatTxtpb,
+		formatYAML,
+	}
+	// sorted
 	sourceFormats = []string{
 		formatDir,
 		formatGit,
@@ -165,5 +187,8 @@ var (
 		MessageEncodingJSON:  formatJSON,
 		MessageEncodingTxtpb: formatTxtpb,
 		MessageEncodingYAML:  formatYAML,
+
+		MessageEncodingBinpbDelimited: formatBinpbDelimited,
+		MessageEncodingJSONL:          formatJSONL,
 	}
 )
@@ -33,11 +33,14 @@ import (
 type refParser struct {
 	logger         *slog.Logger
 	fetchRefParser internal.RefParser
+	// messageFormats are the formats accepted for messages.
+	messageFormats []string
 }
 
 func newRefParser(logger *slog.Logger) *refParser {
 	return &refParser{
-		logger: logger,
+		logger:         logger,
+		messageFormats: messageFormats,
 		fetchRefParser: internal.NewRefParser(
 			logger,
 			internal.WithRawRefProcessor(processRawRef),
@@ -94,36 +97,53 @@ func newMessageRefParser(logger *slog.Logger, options ...MessageRefParserOption)
 	for _, option := range options {
 		option(messageRefParserOptions)
 	}
-	return &refParser{
-		logger: logger,
-		fetchRefParser: internal.NewRefParser(
-			logger,
-			internal.WithRawRefProcessor(newProcessRawRefMessage(messageRefParserOptions.defaultMessageEncoding)),
-			internal.WithSingleFormat(formatBin),
-			internal.WithSingleFormat(formatBinpb),
-			internal.WithSingleFormat(
-				formatJSON,
-				internal.WithSingleCustomOptionKey(useProtoNamesKey),
-				internal.WithSingleCustomOptionKey(useEnumNumbersKey),
+	fetchRefParserOptions := []internal.RefParserOption{
+		internal.WithRawRefProcessor(newProcessRawRefMessage(messageRefParserOptions.defaultMessageEncoding)),
+		internal.WithSingleFormat(formatBin),
+		internal.WithSingleFormat(formatBinpb),
+		internal.WithSingleFormat(
+			formatJSON,
+			internal.WithSingleCustomOptionKey(useProtoNamesKey),
+			internal.WithSingleCustomOptionKey(useEnumNumbersKey),
+		),
+		internal.WithSingleFormat(formatTxtpb),
+		internal.WithSingleFormat(
+			formatYAML,
+			internal.WithSingleCustomOptionKey(useProtoNamesKey),
+			internal.WithSingleCustomOptionKey(useEnumNumbersKey),
+		),
+		internal.WithSingleFormat(
+			formatBingz,
+			internal.WithSingleDefaultCompressionType(
+				internal.CompressionTypeGzip,
 			),
-			internal.WithSingleFormat(formatTxtpb),
+		),
+		internal.WithSingleFormat(
+			formatJSONGZ,
+			internal.WithSingleDefaultCompressionType(
+				internal.CompressionTypeGzip,
+			),
+		),
+	}
+	refParserMessageFormats := messageFormats
+	if messageRefParserOptions.messageStreams {
+		fetchRefParserOptions = append(
+			fetchRefParserOptions,
+			internal.WithSingleFormat(formatBinpbDelimited),
 			internal.WithSingleFormat(
-				formatYAML,
+				formatJSONL,
 				internal.WithSingleCustomOptionKey(useProtoNamesKey),
 				internal.WithSingleCustomOptionKey(useEnumNumbersKey),
 			),
-			internal.WithSingleFormat(
-				formatBingz,
-				internal.WithSingleDefaultCompressionType(
-					internal.CompressionTypeGzip,
-				),
-			),
-			internal.WithSingleFormat(
-				formatJSONGZ,
-				internal.WithSingleDefaultCompressionType(
-					internal.CompressionTypeGzip,
-				),
-			),
+		)
+		refParserMessageFormats = messageFormatsWithStreams
+	}
+	return &refParser{
+		logger:         logger,
+		messageFormats: refParserMessageFormats,
+		fetchRefParser: internal.NewRefParser(
+			logger,
+			fetchRefParserOptions...,
 		),
 	}
 }
@@ -335,7 +355,7 @@ func (a *refParser) GetMessageRef(
 	ctx context.Context,
 	value string,
 ) (MessageRef, error) {
-	parsedRef, err := a.getParsedRef(ctx, value, messageFormats)
+	parsedRef, err := a.getParsedRef(ctx, value, a.messageFormats)
 	if err != nil {
 		return nil, err
 	}
@@ -354,7 +374,7 @@ func (a *refParser) GetMessageRefForInputConfig(
 	ctx context.Context,
 	inputConfig bufconfig.InputConfig,
 ) (MessageRef, error) {
-	parsedRef, err := a.getParsedRefForInputConfig(ctx, inputConfig, messageFormats)
+	parsedRef, err := a.getParsedRefForInputConfig(ctx, inputConfig, a.messageFormats)
 	if err != nil {
 		return nil, err
 	}
@@ -769,6 +789,8 @@ func newProcessRawRefMessage(defaultMessageEncoding MessageEncoding) func(*inter
 				format = formatBinpb
 			case ".json":
 				format = formatJSON
+			case ".jsonl", ".ndjson":
+				format = formatJSONL
 			case ".txtpb":
 				format = formatTxtpb
 			case ".yaml":
@@ -780,6 +802,8 @@ func newProcessRawRefMessage(defaultMessageEncoding MessageEncoding) func(*inter
 					format = formatBinpb
 				case ".json":
 					format = formatJSON
+				case ".jsonl", ".ndjson":
+					format = formatJSONL
 				case ".txtpb":
 					format = formatTxtpb
 				case ".yaml":
@@ -794,6 +818,8 @@ func newProcessRawRefMessage(defaultMessageEncoding MessageEncoding) func(*inter
 					format = formatBinpb
 				case ".json":
 					format = formatJSON
+				case ".jsonl", ".ndjson":
+					format = formatJSONL
 				case ".txtpb":
 					format = formatTxtpb
 				case ".yaml":
@@ -826,6 +852,10 @@ func parseMessageEncoding(format string) (MessageEncoding, error) {
 		return MessageEncodingTxtpb, nil
 	case formatYAML:
 		return MessageEncodingYAML, nil
+	case formatBinpbDelimited:
+		return MessageEncodingBinpbDelimited, nil
+	case formatJSONL:
+		return MessageEncodingJSONL, nil
 	default:
 		return 0, fmt.Errorf("invalid format for message: %q", format)
 	}
@@ -854,6 +884,7 @@ func assumeModuleOrDir(path string) (string, error) {
 
 type messageRefParserOptions struct {
 	defaultMessageEncoding MessageEncoding
+	messageStreams         bool
 }
 
 func newMessageRefParserOptions() *messageRefParserOptions {
@@ -26,6 +26,7 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/chrometrace"
 	"github.com/bufbuild/buf/private/pkg/connectclient"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 )
@@ -120,6 +121,44 @@ func GenerateWithDeleteOuts(deleteOuts bool) GenerateOption {
 	}
 }
 
+const (
+	// TraceCategoryImage is the trace category for building and modifying images.
+	TraceCategoryImage = "image"
+	// TraceCategoryPlugin is the trace category for executing local plugins.
+	TraceCategoryPlugin = "plugin"
+	// TraceCategoryRemote is the trace category for executing remote plugins.
+	TraceCategoryRemote = "remote"
+	// TraceCategoryWrite is the trace category for writing generated files.
+	TraceCategoryWrite = "write"
+
+	// TraceMainThreadID is the trace thread for everything that is not
+	// done concurrently for each plugin.
+	TraceMainThreadID = 0
+)
+
+// GenerateWithTraceRecorder returns a new GenerateOption that records a timeline
+// of generation to the given recorder.
+//
+// The default is to not record a timeline.
+func GenerateWithTraceRecorder(recorder chrometrace.Recorder) GenerateOption {
+	return func(generateOptions *generateOptions) {
+		generateOptions.traceRecorder = recorder
+	}
+}
+
+// GenerateWithDumpRequestsDirPath returns a new GenerateOption that writes every
+// CodeGeneratorRequest sent to a plugin to the given directory, which is created
+// if it does not exist.
+//
+// Requests are written in the binary format to
+// <inputIndex>/<pluginIndex>_<pluginName>/<requestIndex>.binpb. For remote plugins,
+// the equivalent request that the plugin receives on the remote is written.
+func GenerateWithDumpRequestsDirPath(dumpRequestsDirPath string) GenerateOption {
+	return func(generateOptions *generateOptions) {
+		generateOptions.dumpRequestsDirPath = dumpRequestsDirPath
+	}
+}
+
 // GenerateWithIncludeImportsOverride is a strict override on whether imports are
 // generated. This overrides IncludeImports from the GeneratePluginConfig.
 //
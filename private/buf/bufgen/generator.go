@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"path/filepath"
 
 	connect "connectrpc.com/connect"
@@ -26,6 +27,7 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagemodify"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimageutil"
 	"github.com/bufbuild/buf/private/bufpkg/bufprotoplugin"
 	"github.com/bufbuild/buf/private/bufpkg/bufprotoplugin/bufprotopluginos"
 	"github.com/bufbuild/buf/private/bufpkg/bufremoteplugin"
@@ -33,10 +35,14 @@ import (
 	"github.com/bufbuild/buf/private/gen/proto/connect/buf/alpha/registry/v1alpha1/registryv1alpha1connect"
 	registryv1alpha1 "github.com/bufbuild/buf/private/gen/proto/go/buf/alpha/registry/v1alpha1"
 	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/chrometrace"
 	"github.com/bufbuild/buf/private/pkg/connectclient"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/storage"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/thread"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
@@ -93,8 +99,16 @@ func (g *generator) Generate(
 			g.logger.Warn("managed mode configs are set but are not enabled")
 		}
 	}
+	recorder := generateOptions.traceRecorder
+	recorder.SetThreadName(TraceMainThreadID, "buf generate")
+	for i, pluginConfig := range config.GeneratePluginConfigs() {
+		recorder.SetThreadName(tracePluginThreadID(i), "plugin "+pluginConfig.Name())
+	}
 	for _, image := range images {
-		if err := bufimagemodify.Modify(image, config.GenerateManagedConfig()); err != nil {
+		event := recorder.Start("modify image", TraceCategoryImage, TraceMainThreadID)
+		err := bufimagemodify.Modify(image, config.GenerateManagedConfig())
+		event.End()
+		if err != nil {
 			return err
 		}
 	}
@@ -103,15 +117,36 @@ func (g *generator) Generate(
 		shouldDeleteOuts = *generateOptions.deleteOuts
 	}
 	if shouldDeleteOuts {
-		if err := g.deleteOuts(
+		event := recorder.Start("delete outs", TraceCategoryWrite, TraceMainThreadID)
+		err := g.deleteOuts(
 			ctx,
 			generateOptions.baseOutDirPath,
 			config.GeneratePluginConfigs(),
-		); err != nil {
+		)
+		event.End()
+		if err != nil {
 			return err
 		}
 	}
-	for _, image := range images {
+	var dumpRequestsBucket storage.WriteBucket
+	if generateOptions.dumpRequestsDirPath != "" {
+		if err := os.MkdirAll(generateOptions.dumpRequestsDirPath, 0755); err != nil {
+			return err
+		}
+		readWriteBucket, err := g.storageosProvider.NewReadWriteBucket(
+			generateOptions.dumpRequestsDirPath,
+			storageos.ReadWriteBucketWithSymlinksIfSupported(),
+		)
+		if err != nil {
+			return err
+		}
+		dumpRequestsBucket = readWriteBucket
+	}
+	for i, image := range images {
+		var requestDumper *requestDumper
+		if dumpRequestsBucket != nil {
+			requestDumper = newRequestDumper(dumpRequestsBucket, i)
+		}
 		if err := g.generateCode(
 			ctx,
 			container,
@@ -120,6 +155,8 @@ func (g *generator) Generate(
 			config.GeneratePluginConfigs(),
 			generateOptions.includeImportsOverride,
 			generateOptions.includeWellKnownTypesOverride,
+			recorder,
+			requestDumper,
 		); err != nil {
 			return err
 		}
@@ -137,16 +174,21 @@ func (g *generator) deleteOuts(
 		slicesext.Map(
 			pluginConfigs,
 			func(pluginConfig bufconfig.GeneratePluginConfig) string {
-				out := pluginConfig.Out()
-				if baseOutDir != "" && baseOutDir != "." {
-					return filepath.Join(baseOutDir, out)
-				}
-				return out
+				return pluginOutWithBaseOutDir(baseOutDir, pluginConfig.Out())
 			},
 		),
 	)
 }
 
+// pluginOutWithBaseOutDir joins the plugin out to the base out directory, unless
+// the plugin out is stdout.
+func pluginOutWithBaseOutDir(baseOutDir string, pluginOut string) string {
+	if pluginOut == bufprotopluginos.StdoutPluginOut || baseOutDir == "" || baseOutDir == "." {
+		return pluginOut
+	}
+	return filepath.Join(baseOutDir, pluginOut)
+}
+
 func (g *generator) generateCode(
 	ctx context.Context,
 	container app.EnvStdioContainer,
@@ -155,6 +197,8 @@ func (g *generator) generateCode(
 	pluginConfigs []bufconfig.GeneratePluginConfig,
 	includeImportsOverride *bool,
 	includeWellKnownTypesOverride *bool,
+	recorder chrometrace.Recorder,
+	requestDumper *requestDumper,
 ) error {
 	responses, err := g.execPlugins(
 		ctx,
@@ -163,6 +207,8 @@ func (g *generator) generateCode(
 		inputImage,
 		includeImportsOverride,
 		includeWellKnownTypesOverride,
+		recorder,
+		requestDumper,
 	)
 	if err != nil {
 		return err
@@ -172,24 +218,29 @@ func (g *generator) generateCode(
 		g.logger,
 		g.storageosProvider,
 		bufprotopluginos.ResponseWriterWithCreateOutDirIfNotExists(),
+		bufprotopluginos.ResponseWriterWithStdout(container.Stdout()),
 	)
 	for i, pluginConfig := range pluginConfigs {
-		out := pluginConfig.Out()
-		if baseOutDir != "" && baseOutDir != "." {
-			out = filepath.Join(baseOutDir, out)
-		}
+		out := pluginOutWithBaseOutDir(baseOutDir, pluginConfig.Out())
 		response := responses[i]
 		if response == nil {
 			return fmt.Errorf("failed to get plugin response for %s", pluginConfig.Name())
 		}
-		if err := responseWriter.AddResponse(
+		event := recorder.Start("merge response "+pluginConfig.Name(), TraceCategoryWrite, TraceMainThreadID)
+		setTraceResponseArgs(event, response)
+		event.SetArg("out", out)
+		err := responseWriter.AddResponse(
 			ctx,
 			response,
 			out,
-		); err != nil {
+		)
+		event.End()
+		if err != nil {
 			return fmt.Errorf("plugin %s: %v", pluginConfig.Name(), err)
 		}
 	}
+	event := recorder.Start("write files", TraceCategoryWrite, TraceMainThreadID)
+	defer event.End()
 	if err := responseWriter.Close(); err != nil {
 		return err
 	}
@@ -203,6 +254,8 @@ func (g *generator) execPlugins(
 	image bufimage.Image,
 	includeImportsOverride *bool,
 	includeWellKnownTypesOverride *bool,
+	recorder chrometrace.Recorder,
+	requestDumper *requestDumper,
 ) ([]*pluginpb.CodeGeneratorResponse, error) {
 	imageProvider := newImageProvider(image)
 	// Collect all of the plugin jobs so that they can be executed in parallel.
@@ -217,7 +270,73 @@ func (g *generator) execPlugins(
 		//
 		// We should be using the enum here.
 		remote := currentPluginConfig.RemoteHost()
-		if remote != "" {
+		if pluginConfigHasImageFilters(currentPluginConfig) {
+			// Plugins with their own filters see a different image than all other plugins,
+			// so they cannot share an imageProvider or be batched with other remote plugins.
+			jobs = append(jobs, func(ctx context.Context) error {
+				event := recorder.Start("filter image", TraceCategoryImage, tracePluginThreadID(index))
+				pluginImage, err := imageForPluginConfig(image, currentPluginConfig)
+				event.End()
+				if err != nil {
+					return fmt.Errorf("plugin %s: %w", currentPluginConfig.Name(), err)
+				}
+				if pluginImage == nil {
+					g.logger.DebugContext(ctx, "no files to generate for plugin after filtering", slog.String("plugin", currentPluginConfig.Name()))
+					responses[index] = &pluginpb.CodeGeneratorResponse{}
+					return nil
+				}
+				if remote != "" {
+					results, err := g.execRemotePluginsV2(
+						ctx,
+						container,
+						pluginImage,
+						remote,
+						[]*remotePluginExecArgs{
+							{
+								Index:        index,
+								PluginConfig: currentPluginConfig,
+							},
+						},
+						includeImportsOverride,
+						includeWellKnownTypesOverride,
+						recorder,
+						requestDumper,
+						tracePluginThreadID(index),
+					)
+					if err != nil {
+						return err
+					}
+					for _, result := range results {
+						responses[result.Index] = result.CodeGeneratorResponse
+					}
+					return nil
+				}
+				includeImports := currentPluginConfig.IncludeImports()
+				if includeImportsOverride != nil {
+					includeImports = *includeImportsOverride
+				}
+				includeWellKnownTypes := currentPluginConfig.IncludeWKT()
+				if includeWellKnownTypesOverride != nil {
+					includeWellKnownTypes = *includeWellKnownTypesOverride
+				}
+				response, err := g.execLocalPlugin(
+					ctx,
+					container,
+					newImageProvider(pluginImage),
+					currentPluginConfig,
+					includeImports,
+					includeWellKnownTypes,
+					recorder,
+					requestDumper,
+					index,
+				)
+				if err != nil {
+					return err
+				}
+				responses[index] = response
+				return nil
+			})
+		} else if remote != "" {
 			remotePluginConfigTable[remote] = append(
 				remotePluginConfigTable[remote],
 				&remotePluginExecArgs{
@@ -242,6 +361,9 @@ func (g *generator) execPlugins(
 					currentPluginConfig,
 					includeImports,
 					includeWellKnownTypes,
+					recorder,
+					requestDumper,
+					index,
 				)
 				if err != nil {
 					return err
@@ -252,10 +374,16 @@ func (g *generator) execPlugins(
 		}
 	}
 	// Batch for each remote.
+	remoteThreadID := tracePluginThreadID(len(pluginConfigs))
 	for remote, indexedPluginConfigs := range remotePluginConfigTable {
 		remote := remote
 		indexedPluginConfigs := indexedPluginConfigs
 		if len(indexedPluginConfigs) > 0 {
+			// Batched remote plugins are executed in a single call, so they get their
+			// own thread in the trace.
+			threadID := remoteThreadID
+			remoteThreadID++
+			recorder.SetThreadName(threadID, "remote "+remote)
 			jobs = append(jobs, func(ctx context.Context) error {
 				results, err := g.execRemotePluginsV2(
 					ctx,
@@ -265,6 +393,9 @@ func (g *generator) execPlugins(
 					indexedPluginConfigs,
 					includeImportsOverride,
 					includeWellKnownTypesOverride,
+					recorder,
+					requestDumper,
+					threadID,
 				)
 				if err != nil {
 					return err
@@ -288,11 +419,14 @@ func (g *generator) execPlugins(
 	//      out: gen/proto
 	//    - name: insertion-point-writer
 	//      out: gen/proto
-	if err := thread.Parallelize(
+	event := recorder.Start("exec plugins", TraceCategoryPlugin, TraceMainThreadID)
+	err := thread.Parallelize(
 		ctx,
 		jobs,
 		thread.ParallelizeWithCancelOnFailure(),
-	); err != nil {
+	)
+	event.End()
+	if err != nil {
 		return nil, err
 	}
 	if err := validateResponses(responses, pluginConfigs); err != nil {
@@ -311,9 +445,17 @@ func (g *generator) execLocalPlugin(
 	pluginConfig bufconfig.GeneratePluginConfig,
 	includeImports bool,
 	includeWellKnownTypes bool,
+	recorder chrometrace.Recorder,
+	requestDumper *requestDumper,
+	index int,
 ) (*pluginpb.CodeGeneratorResponse, error) {
+	threadID := tracePluginThreadID(index)
+	event := recorder.Start(pluginConfig.Name(), TraceCategoryPlugin, threadID)
+	defer event.End()
+	requestsEvent := recorder.Start("build requests", TraceCategoryPlugin, threadID)
 	pluginImages, err := imageProvider.GetImages(Strategy(pluginConfig.Strategy()))
 	if err != nil {
+		requestsEvent.End()
 		return nil, err
 	}
 	requests, err := bufimage.ImagesToCodeGeneratorRequests(
@@ -323,9 +465,17 @@ func (g *generator) execLocalPlugin(
 		includeImports,
 		includeWellKnownTypes,
 	)
+	requestsEvent.End()
 	if err != nil {
 		return nil, err
 	}
+	if err := requestDumper.DumpRequests(ctx, index, pluginConfig.Name(), requests); err != nil {
+		return nil, err
+	}
+	event.SetArg("strategy", Strategy(pluginConfig.Strategy()).String())
+	event.SetArg("request_count", len(requests))
+	event.SetArg("request_size", traceRequestsSize(requests))
+	execEvent := recorder.Start("exec", TraceCategoryPlugin, threadID)
 	response, err := g.pluginexecGenerator.Generate(
 		ctx,
 		container,
@@ -334,9 +484,11 @@ func (g *generator) execLocalPlugin(
 		bufprotopluginexec.GenerateWithPluginPath(pluginConfig.Path()...),
 		bufprotopluginexec.GenerateWithProtocPath(pluginConfig.ProtocPath()...),
 	)
+	execEvent.End()
 	if err != nil {
 		return nil, fmt.Errorf("plugin %s: %v", pluginConfig.Name(), err)
 	}
+	setTraceResponseArgs(event, response)
 	return response, nil
 }
 
@@ -358,7 +510,21 @@ func (g *generator) execRemotePluginsV2(
 	pluginConfigs []*remotePluginExecArgs,
 	includeImportsOverride *bool,
 	includeWellKnownTypesOverride *bool,
+	recorder chrometrace.Recorder,
+	requestDumper *requestDumper,
+	threadID int,
 ) ([]*remotePluginExecutionResult, error) {
+	event := recorder.Start("remote "+remote, TraceCategoryRemote, threadID)
+	defer event.End()
+	event.SetArg(
+		"plugins",
+		slicesext.Map(
+			pluginConfigs,
+			func(pluginConfig *remotePluginExecArgs) string {
+				return pluginConfig.PluginConfig.Name()
+			},
+		),
+	)
 	requests := make([]*registryv1alpha1.PluginGenerationRequest, len(pluginConfigs))
 	for i, pluginConfig := range pluginConfigs {
 		includeImports := pluginConfig.PluginConfig.IncludeImports()
@@ -378,12 +544,36 @@ func (g *generator) execRemotePluginsV2(
 			return nil, err
 		}
 		requests[i] = request
+		if requestDumper != nil {
+			// Remote plugins are not sent a CodeGeneratorRequest directly, so we dump the
+			// equivalent request that the plugin receives on the remote, which always
+			// uses the "all" strategy.
+			codeGeneratorRequest, err := bufimage.ImageToCodeGeneratorRequest(
+				image,
+				pluginConfig.PluginConfig.Opt(),
+				nil,
+				includeImports,
+				includeWellKnownTypes,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if err := requestDumper.DumpRequests(
+				ctx,
+				pluginConfig.Index,
+				pluginConfig.PluginConfig.Name(),
+				[]*pluginpb.CodeGeneratorRequest{codeGeneratorRequest},
+			); err != nil {
+				return nil, err
+			}
+		}
 	}
 	codeGenerationService := connectclient.Make(g.clientConfig, remote, registryv1alpha1connect.NewCodeGenerationServiceClient)
 	protoImage, err := bufimage.ImageToProtoImage(image)
 	if err != nil {
 		return nil, err
 	}
+	event.SetArg("request_size", proto.Size(protoImage))
 	response, err := codeGenerationService.GenerateCode(
 		ctx,
 		connect.NewRequest(
@@ -396,6 +586,7 @@ func (g *generator) execRemotePluginsV2(
 	if err != nil {
 		return nil, err
 	}
+	event.SetArg("response_size", proto.Size(response.Msg))
 	responses := response.Msg.Responses
 	if len(responses) != len(requests) {
 		return nil, fmt.Errorf("unexpected number of responses received, got %d, wanted %d", len(responses), len(requests))
@@ -474,13 +665,101 @@ func validateResponses(
 	return nil
 }
 
+// pluginConfigHasImageFilters returns true if the plugin config restricts the
+// image the plugin generates for.
+func pluginConfigHasImageFilters(pluginConfig bufconfig.GeneratePluginConfig) bool {
+	return len(pluginConfig.Modules()) > 0 ||
+		len(pluginConfig.IncludePaths()) > 0 ||
+		len(pluginConfig.ExcludePaths()) > 0 ||
+		len(pluginConfig.Types()) > 0
+}
+
+// imageForPluginConfig returns the image filtered by the modules, paths and
+// types of the plugin config.
+//
+// Paths that do not exist in the image are ignored, as the same plugin config
+// is applied to every input. If no files remain to generate for, this returns nil.
+func imageForPluginConfig(
+	image bufimage.Image,
+	pluginConfig bufconfig.GeneratePluginConfig,
+) (bufimage.Image, error) {
+	moduleFullNameStrings := slicesext.ToStructMap(pluginConfig.Modules())
+	includePathMap := slicesext.ToStructMap(pluginConfig.IncludePaths())
+	excludePathMap := slicesext.ToStructMap(pluginConfig.ExcludePaths())
+	var targetPaths []string
+	for _, imageFile := range image.Files() {
+		if imageFile.IsImport() {
+			continue
+		}
+		if len(moduleFullNameStrings) > 0 {
+			moduleFullName := imageFile.ModuleFullName()
+			if moduleFullName == nil {
+				continue
+			}
+			if _, ok := moduleFullNameStrings[moduleFullName.String()]; !ok {
+				continue
+			}
+		}
+		if len(includePathMap) > 0 && !normalpath.MapHasEqualOrContainingPath(includePathMap, imageFile.Path(), normalpath.Relative) {
+			continue
+		}
+		if normalpath.MapHasEqualOrContainingPath(excludePathMap, imageFile.Path(), normalpath.Relative) {
+			continue
+		}
+		targetPaths = append(targetPaths, imageFile.Path())
+	}
+	if len(targetPaths) == 0 {
+		return nil, nil
+	}
+	filteredImage, err := bufimage.ImageWithOnlyPaths(image, targetPaths, nil)
+	if err != nil {
+		return nil, err
+	}
+	if types := pluginConfig.Types(); len(types) > 0 {
+		filteredImage, err = bufimageutil.ImageFilteredByTypesWithOptions(filteredImage, types)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filteredImage, nil
+}
+
+// tracePluginThreadID returns the trace thread for the plugin at the index.
+func tracePluginThreadID(index int) int {
+	return index + 1
+}
+
+func traceRequestsSize(requests []*pluginpb.CodeGeneratorRequest) int {
+	var size int
+	for _, request := range requests {
+		size += proto.Size(request)
+	}
+	return size
+}
+
+func setTraceResponseArgs(event chrometrace.Event, response *pluginpb.CodeGeneratorResponse) {
+	var insertionPointCount int
+	for _, file := range response.GetFile() {
+		if file.GetInsertionPoint() != "" {
+			insertionPointCount++
+		}
+	}
+	event.SetArg("response_size", proto.Size(response))
+	event.SetArg("file_count", len(response.GetFile()))
+	event.SetArg("insertion_point_count", insertionPointCount)
+}
+
 type generateOptions struct {
 	baseOutDirPath                string
 	deleteOuts                    *bool
 	includeImportsOverride        *bool
 	includeWellKnownTypesOverride *bool
+	traceRecorder                 chrometrace.Recorder
+	dumpRequestsDirPath           string
 }
 
 func newGenerateOptions() *generateOptions {
-	return &generateOptions{}
+	return &generateOptions{
+		traceRecorder: chrometrace.NewNopRecorder(),
+	}
 }
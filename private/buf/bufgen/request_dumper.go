@@ -0,0 +1,86 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufgen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// requestDumper writes the CodeGeneratorRequests sent to plugins to a bucket.
+//
+// A nil *requestDumper does nothing.
+type requestDumper struct {
+	writeBucket storage.WriteBucket
+	imageIndex  int
+}
+
+func newRequestDumper(writeBucket storage.WriteBucket, imageIndex int) *requestDumper {
+	return &requestDumper{
+		writeBucket: writeBucket,
+		imageIndex:  imageIndex,
+	}
+}
+
+// DumpRequests writes the requests for the plugin at the given index.
+//
+// Requests are written to <imageIndex>/<pluginIndex>_<pluginName>/<requestIndex>.binpb,
+// where the plugin name has any characters that are not valid in a file name replaced.
+func (d *requestDumper) DumpRequests(
+	ctx context.Context,
+	pluginIndex int,
+	pluginName string,
+	requests []*pluginpb.CodeGeneratorRequest,
+) error {
+	if d == nil {
+		return nil
+	}
+	pluginDirPath := normalpath.Join(
+		strconv.Itoa(d.imageIndex),
+		strconv.Itoa(pluginIndex)+"_"+sanitizePluginNameForPath(pluginName),
+	)
+	for i, request := range requests {
+		data, err := protoencoding.NewWireMarshaler().Marshal(request)
+		if err != nil {
+			return err
+		}
+		requestPath := normalpath.Join(pluginDirPath, strconv.Itoa(i)+".binpb")
+		if err := storage.PutPath(ctx, d.writeBucket, requestPath, data); err != nil {
+			return fmt.Errorf("failed to dump request for plugin %s: %w", pluginName, err)
+		}
+	}
+	return nil
+}
+
+func sanitizePluginNameForPath(pluginName string) string {
+	return strings.Map(
+		func(r rune) rune {
+			switch r {
+			case '/', '\\', ':', '@', ' ':
+				return '_'
+			default:
+				return r
+			}
+		},
+		pluginName,
+	)
+}
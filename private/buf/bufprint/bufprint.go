@@ -289,9 +289,29 @@ func NewTokenPrinter(writer io.Writer, format Format) (TokenPrinter, error) {
 	}
 }
 
+// StatsReport is a report of Stats for an input, with breakdowns by module and by package.
+type StatsReport struct {
+	*protostat.Stats
+
+	Modules  []*NamedStats `json:"modules" yaml:"modules"`
+	Packages []*NamedStats `json:"packages" yaml:"packages"`
+}
+
+// NamedStats are Stats for a named module or package.
+type NamedStats struct {
+	Name string `json:"name" yaml:"name"`
+
+	*protostat.Stats
+}
+
 // StatsPrinter is a printer of Stats.
 type StatsPrinter interface {
-	PrintStats(ctx context.Context, format Format, stats *protostat.Stats) error
+	PrintStats(ctx context.Context, format Format, statsReport *StatsReport) error
+	// PrintStatsAgainst prints the StatsReport alongside the againstStatsReport
+	// and the difference between the two.
+	//
+	// The text format only compares the totals.
+	PrintStatsAgainst(ctx context.Context, format Format, statsReport *StatsReport, againstStatsReport *StatsReport) error
 }
 
 // NewStatsPrinter returns a new StatsPrinter.
@@ -34,41 +34,277 @@ func newStatsPrinter(writer io.Writer) *statsPrinter {
 	}
 }
 
-func (p *statsPrinter) PrintStats(ctx context.Context, format Format, stats *protostat.Stats) error {
+func (p *statsPrinter) PrintStats(ctx context.Context, format Format, statsReport *StatsReport) error {
 	switch format {
 	case FormatText:
-		return WithTabWriter(
+		if err := WithTabWriter(
 			p.writer,
-			[]string{
-				"Files",
-				"Packages",
-				"Messages",
-				"Fields",
-				"Enums",
-				"Enum Values",
-				"Extensions",
-				"Services",
-				"Methods",
-				"Files With Errors",
+			[]string{"Metric", "Value"},
+			func(tabWriter TabWriter) error {
+				for _, row := range statsRows {
+					if err := tabWriter.Write(row.name, row.value(statsReport.Stats)); err != nil {
+						return err
+					}
+				}
+				return nil
 			},
+		); err != nil {
+			return err
+		}
+		if err := p.printNamedStatsTable("Module", statsReport.Modules); err != nil {
+			return err
+		}
+		return p.printNamedStatsTable("Package", statsReport.Packages)
+	case FormatJSON:
+		return json.NewEncoder(p.writer).Encode(statsReport)
+	default:
+		return fmt.Errorf("unknown format: %v", format)
+	}
+}
+
+func (p *statsPrinter) PrintStatsAgainst(
+	ctx context.Context,
+	format Format,
+	statsReport *StatsReport,
+	againstStatsReport *StatsReport,
+) error {
+	switch format {
+	case FormatText:
+		return WithTabWriter(
+			p.writer,
+			[]string{"Metric", "Input", "Against", "Diff"},
 			func(tabWriter TabWriter) error {
-				return tabWriter.Write(
-					strconv.Itoa(stats.NumFiles),
-					strconv.Itoa(stats.NumPackages),
-					strconv.Itoa(stats.NumMessages),
-					strconv.Itoa(stats.NumFields),
-					strconv.Itoa(stats.NumEnums),
-					strconv.Itoa(stats.NumEnumValues),
-					strconv.Itoa(stats.NumExtensions),
-					strconv.Itoa(stats.NumServices),
-					strconv.Itoa(stats.NumMethods),
-					strconv.Itoa(stats.NumFilesWithSyntaxErrors),
-				)
+				for _, row := range statsRows {
+					if err := tabWriter.Write(
+						row.name,
+						row.value(statsReport.Stats),
+						row.value(againstStatsReport.Stats),
+						row.diff(againstStatsReport.Stats, statsReport.Stats),
+					); err != nil {
+						return err
+					}
+				}
+				return nil
 			},
 		)
 	case FormatJSON:
-		return json.NewEncoder(p.writer).Encode(stats)
+		return json.NewEncoder(p.writer).Encode(
+			&statsAgainstReport{
+				Input:   statsReport,
+				Against: againstStatsReport,
+				Diff:    protostat.DiffStats(againstStatsReport.Stats, statsReport.Stats),
+			},
+		)
 	default:
 		return fmt.Errorf("unknown format: %v", format)
 	}
 }
+
+func (p *statsPrinter) printNamedStatsTable(kind string, namedStatsSlice []*NamedStats) error {
+	if len(namedStatsSlice) == 0 {
+		return nil
+	}
+	if _, err := p.writer.Write([]byte("\n")); err != nil {
+		return err
+	}
+	return WithTabWriter(
+		p.writer,
+		[]string{
+			kind,
+			"Files",
+			"Messages",
+			"Fields",
+			"Enums",
+			"Services",
+			"Methods",
+			"Max Message Depth",
+			"Deprecated",
+			"Commented",
+		},
+		func(tabWriter TabWriter) error {
+			for _, namedStats := range namedStatsSlice {
+				name := namedStats.Name
+				if name == "" {
+					name = "<none>"
+				}
+				if err := tabWriter.Write(
+					name,
+					strconv.Itoa(namedStats.NumFiles),
+					strconv.Itoa(namedStats.NumMessages),
+					strconv.Itoa(namedStats.NumFields),
+					strconv.Itoa(namedStats.NumEnums),
+					strconv.Itoa(namedStats.NumServices),
+					strconv.Itoa(namedStats.NumMethods),
+					strconv.Itoa(namedStats.MaxMessageDepth),
+					formatPercent(namedStats.Deprecated.Total(), namedStats.NumElements()),
+					formatPercent(namedStats.Commented.Total(), namedStats.NumElements()),
+				); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+type statsAgainstReport struct {
+	Input   *StatsReport     `json:"input,omitempty" yaml:"input,omitempty"`
+	Against *StatsReport     `json:"against,omitempty" yaml:"against,omitempty"`
+	Diff    *protostat.Stats `json:"diff,omitempty" yaml:"diff,omitempty"`
+}
+
+type statsRow struct {
+	name  string
+	value func(*protostat.Stats) string
+	diff  func(against *protostat.Stats, stats *protostat.Stats) string
+}
+
+var statsRows = []*statsRow{
+	newStatsCountRow("Files", func(stats *protostat.Stats) int { return stats.NumFiles }),
+	newStatsCountRow("Packages", func(stats *protostat.Stats) int { return stats.NumPackages }),
+	newStatsCountRow("Messages", func(stats *protostat.Stats) int { return stats.NumMessages }),
+	newStatsCountRow("Fields", func(stats *protostat.Stats) int { return stats.NumFields }),
+	newStatsCountRow("Enums", func(stats *protostat.Stats) int { return stats.NumEnums }),
+	newStatsCountRow("Enum Values", func(stats *protostat.Stats) int { return stats.NumEnumValues }),
+	newStatsCountRow("Extensions", func(stats *protostat.Stats) int { return stats.NumExtensions }),
+	newStatsCountRow("Services", func(stats *protostat.Stats) int { return stats.NumServices }),
+	newStatsCountRow("Methods", func(stats *protostat.Stats) int { return stats.NumMethods }),
+	newStatsCountRow("Imports", func(stats *protostat.Stats) int { return stats.NumImports }),
+	newStatsCountRow("Files With Errors", func(stats *protostat.Stats) int { return stats.NumFilesWithSyntaxErrors }),
+	newStatsCountRow("Max Message Depth", func(stats *protostat.Stats) int { return stats.MaxMessageDepth }),
+	newStatsNamedCountRow("Largest Message", "fields", func(stats *protostat.Stats) protostat.NamedCount { return stats.LargestMessage }),
+	newStatsNamedCountRow("Widest Oneof", "fields", func(stats *protostat.Stats) protostat.NamedCount {
+		if len(stats.WidestOneofs) == 0 {
+			return protostat.NamedCount{}
+		}
+		return stats.WidestOneofs[0]
+	}),
+	newStatsNamedCountRow("Max Import Fan-In", "importers", func(stats *protostat.Stats) protostat.NamedCount { return derefNamedCount(stats.MaxImportFanIn) }),
+	newStatsNamedCountRow("Max Import Fan-Out", "imports", func(stats *protostat.Stats) protostat.NamedCount { return derefNamedCount(stats.MaxImportFanOut) }),
+	newStatsShareRow(
+		"Deprecated",
+		func(stats *protostat.Stats) int { return stats.Deprecated.Total() },
+		func(stats *protostat.Stats) int { return stats.NumElements() },
+	),
+	newStatsShareRow(
+		"Commented",
+		func(stats *protostat.Stats) int { return stats.Commented.Total() },
+		func(stats *protostat.Stats) int { return stats.NumElements() },
+	),
+	newStatsShareRow(
+		"Commented Messages",
+		func(stats *protostat.Stats) int { return stats.Commented.Messages },
+		func(stats *protostat.Stats) int { return stats.NumMessages },
+	),
+	newStatsShareRow(
+		"Commented Fields",
+		func(stats *protostat.Stats) int { return stats.Commented.Fields },
+		func(stats *protostat.Stats) int { return stats.NumFields },
+	),
+	newStatsShareRow(
+		"Commented Enums",
+		func(stats *protostat.Stats) int { return stats.Commented.Enums },
+		func(stats *protostat.Stats) int { return stats.NumEnums },
+	),
+	newStatsShareRow(
+		"Commented Enum Values",
+		func(stats *protostat.Stats) int { return stats.Commented.EnumValues },
+		func(stats *protostat.Stats) int { return stats.NumEnumValues },
+	),
+	newStatsShareRow(
+		"Commented Extensions",
+		func(stats *protostat.Stats) int { return stats.Commented.Extensions },
+		func(stats *protostat.Stats) int { return stats.NumExtensions },
+	),
+	newStatsShareRow(
+		"Commented Services",
+		func(stats *protostat.Stats) int { return stats.Commented.Services },
+		func(stats *protostat.Stats) int { return stats.NumServices },
+	),
+	newStatsShareRow(
+		"Commented Methods",
+		func(stats *protostat.Stats) int { return stats.Commented.Methods },
+		func(stats *protostat.Stats) int { return stats.NumMethods },
+	),
+}
+
+func newStatsCountRow(name string, count func(*protostat.Stats) int) *statsRow {
+	return &statsRow{
+		name: name,
+		value: func(stats *protostat.Stats) string {
+			return strconv.Itoa(count(stats))
+		},
+		diff: func(against *protostat.Stats, stats *protostat.Stats) string {
+			return formatDiff(count(stats) - count(against))
+		},
+	}
+}
+
+func newStatsNamedCountRow(name string, unit string, namedCount func(*protostat.Stats) protostat.NamedCount) *statsRow {
+	return &statsRow{
+		name: name,
+		value: func(stats *protostat.Stats) string {
+			namedCount := namedCount(stats)
+			if namedCount.Name == "" {
+				return "-"
+			}
+			return fmt.Sprintf("%s (%d %s)", namedCount.Name, namedCount.Count, unit)
+		},
+		diff: func(against *protostat.Stats, stats *protostat.Stats) string {
+			return formatDiff(namedCount(stats).Count - namedCount(against).Count)
+		},
+	}
+}
+
+// derefNamedCount returns the zero NamedCount for nil.
+func derefNamedCount(namedCount *protostat.NamedCount) protostat.NamedCount {
+	if namedCount == nil {
+		return protostat.NamedCount{}
+	}
+	return *namedCount
+}
+
+func newStatsShareRow(name string, count func(*protostat.Stats) int, total func(*protostat.Stats) int) *statsRow {
+	return &statsRow{
+		name: name,
+		value: func(stats *protostat.Stats) string {
+			return fmt.Sprintf("%d/%d (%s)", count(stats), total(stats), formatPercent(count(stats), total(stats)))
+		},
+		diff: func(against *protostat.Stats, stats *protostat.Stats) string {
+			return fmt.Sprintf(
+				"%s (%s pp)",
+				formatDiff(count(stats)-count(against)),
+				formatFloatDiff(percent(count(stats), total(stats))-percent(count(against), total(against))),
+			)
+		},
+	}
+}
+
+func percent(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(count) / float64(total)
+}
+
+func formatPercent(count int, total int) string {
+	return strconv.FormatFloat(percent(count, total), 'f', 1, 64) + "%"
+}
+
+func formatDiff(diff int) string {
+	if diff > 0 {
+		return "+" + strconv.Itoa(diff)
+	}
+	return strconv.Itoa(diff)
+}
+
+func formatFloatDiff(diff float64) string {
+	s := strconv.FormatFloat(diff, 'f', 1, 64)
+	if diff > 0 && s != "0.0" {
+		return "+" + s
+	}
+	if s == "-0.0" {
+		return "0.0"
+	}
+	return s
+}
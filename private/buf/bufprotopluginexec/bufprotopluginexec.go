@@ -28,6 +28,7 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/pkg/app"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/bufbuild/buf/private/pkg/wasm"
 	"github.com/bufbuild/protoplugin"
 	"google.golang.org/protobuf/types/pluginpb"
 )
@@ -185,6 +186,13 @@ func NewBinaryHandler(logger *slog.Logger, pluginPath string, pluginArgs []strin
 	return newBinaryHandler(logger, pluginPath, pluginArgs), nil
 }
 
+// NewWasmHandler returns a new Handler that invokes the given compiled Wasm plugin.
+//
+// The pluginName is only used for logging.
+func NewWasmHandler(logger *slog.Logger, pluginName string, compiledModule wasm.CompiledModule) protoplugin.Handler {
+	return newWasmHandler(logger, pluginName, compiledModule)
+}
+
 type handlerOptions struct {
 	pluginPath []string
 	protocPath []string
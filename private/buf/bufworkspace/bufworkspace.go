@@ -13,3 +13,9 @@
 // limitations under the License.
 
 package bufworkspace
+
+import "errors"
+
+// ErrNoAffectedModules is returned from GetWorkspaceForBucket when WithChangedPaths is
+// used, but none of the Modules that would otherwise be targeted are affected by the changes.
+var ErrNoAffectedModules = errors.New("no modules are affected by the changes")
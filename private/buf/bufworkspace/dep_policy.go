@@ -0,0 +1,251 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufworkspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/dag"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+)
+
+const (
+	depPolicyTypeNotAllowed       = "DEP_NOT_ALLOWED"
+	depPolicyTypeDenied           = "DEP_DENIED"
+	depPolicyTypeTransitiveDenied = "DEP_TRANSITIVE_DENIED"
+	depPolicyTypeMaxDepth         = "DEP_MAX_DEPTH"
+	depPolicyTypeLabelRequired    = "DEP_LABEL_REQUIRED"
+)
+
+// depPolicyDep is a dependency that is validated against a DepPolicyConfig.
+type depPolicyDep struct {
+	moduleFullName bufmodule.ModuleFullName
+	// depth is the length of the shortest dependency path from a local Module, or
+	// from buf.yaml, to the dependency.
+	//
+	// 0 if the dependency is not reachable, or if its depth was not computed.
+	depth int
+}
+
+// validateDepPolicy validates the dependencies against the DepPolicyConfig.
+//
+// The allow, deny and label rules are validated against the configured dependencies. The
+// transitive deny and depth rules are validated against all dependencies.
+//
+// Violations are returned as a bufanalysis.FileAnnotationSet on the buf.yaml file.
+// fileInfo may be nil, for example if the buf.yaml was given as an override.
+func validateDepPolicy(
+	depPolicyConfig bufconfig.DepPolicyConfig,
+	fileInfo bufanalysis.FileInfo,
+	configuredDepModuleRefs []bufmodule.ModuleRef,
+	deps []depPolicyDep,
+) error {
+	var fileAnnotations []bufanalysis.FileAnnotation
+	addFileAnnotation := func(typeString string, message string) {
+		fileAnnotations = append(
+			fileAnnotations,
+			bufanalysis.NewFileAnnotation(fileInfo, 0, 0, 0, 0, typeString, message, ""),
+		)
+	}
+	allow := depPolicyConfig.Allow()
+	deny := depPolicyConfig.Deny()
+	denyTransitive := depPolicyConfig.DenyTransitive()
+	for _, configuredDepModuleRef := range configuredDepModuleRefs {
+		moduleFullName := configuredDepModuleRef.ModuleFullName()
+		if len(allow) > 0 && !depPolicyEntriesMatch(allow, moduleFullName) {
+			addFileAnnotation(
+				depPolicyTypeNotAllowed,
+				fmt.Sprintf("Dependency %q is not allowed by dep_policy.allow.", moduleFullName.String()),
+			)
+		}
+		if depPolicyEntriesMatch(deny, moduleFullName) {
+			addFileAnnotation(
+				depPolicyTypeDenied,
+				fmt.Sprintf("Dependency %q is denied by dep_policy.deny.", moduleFullName.String()),
+			)
+		}
+		if depPolicyConfig.RequireLabel() && !isLabelRef(configuredDepModuleRef.Ref()) {
+			addFileAnnotation(
+				depPolicyTypeLabelRequired,
+				fmt.Sprintf("Dependency %q must be pinned to a label, as required by dep_policy.require_label.", moduleFullName.String()),
+			)
+		}
+	}
+	for _, dep := range deps {
+		if depPolicyEntriesMatch(denyTransitive, dep.moduleFullName) {
+			addFileAnnotation(
+				depPolicyTypeTransitiveDenied,
+				fmt.Sprintf("Dependency %q is denied by dep_policy.deny_transitive.", dep.moduleFullName.String()),
+			)
+		}
+		if maxDepth := depPolicyConfig.MaxDepth(); maxDepth > 0 && dep.depth > maxDepth {
+			addFileAnnotation(
+				depPolicyTypeMaxDepth,
+				fmt.Sprintf(
+					"Dependency %q has a depth of %d, which exceeds dep_policy.max_depth of %d.",
+					dep.moduleFullName.String(),
+					dep.depth,
+					maxDepth,
+				),
+			)
+		}
+	}
+	if len(fileAnnotations) > 0 {
+		return bufanalysis.NewFileAnnotationSet(fileAnnotations...)
+	}
+	return nil
+}
+
+// getDepPolicyDepsForModuleSet returns the remote Modules of the ModuleSet as depPolicyDeps.
+//
+// Depths are only computed if computeDepths is true, as this requires the imports
+// of all Modules to be read.
+func getDepPolicyDepsForModuleSet(moduleSet bufmodule.ModuleSet, computeDepths bool) ([]depPolicyDep, error) {
+	opaqueIDToDepth := make(map[string]int)
+	if computeDepths {
+		var queue []bufmodule.Module
+		for _, module := range moduleSet.Modules() {
+			if module.IsLocal() {
+				opaqueIDToDepth[module.OpaqueID()] = 0
+				queue = append(queue, module)
+			}
+		}
+		for len(queue) > 0 {
+			module := queue[0]
+			queue = queue[1:]
+			moduleDeps, err := module.ModuleDeps()
+			if err != nil {
+				return nil, err
+			}
+			for _, moduleDep := range moduleDeps {
+				if !moduleDep.IsDirect() {
+					continue
+				}
+				if _, ok := opaqueIDToDepth[moduleDep.OpaqueID()]; ok {
+					continue
+				}
+				opaqueIDToDepth[moduleDep.OpaqueID()] = opaqueIDToDepth[module.OpaqueID()] + 1
+				queue = append(queue, moduleDep)
+			}
+		}
+	}
+	var deps []depPolicyDep
+	for _, module := range moduleSet.Modules() {
+		if module.IsLocal() || module.ModuleFullName() == nil {
+			continue
+		}
+		deps = append(
+			deps,
+			depPolicyDep{
+				moduleFullName: module.ModuleFullName(),
+				depth:          opaqueIDToDepth[module.OpaqueID()],
+			},
+		)
+	}
+	return deps, nil
+}
+
+// getDepPolicyDepsForGraph returns the ModuleKeys of the graph as depPolicyDeps.
+//
+// The configured dependencies have a depth of 1.
+func getDepPolicyDepsForGraph(
+	configuredDepModuleRefs []bufmodule.ModuleRef,
+	graph *dag.Graph[bufmodule.RegistryCommitID, bufmodule.ModuleKey],
+) ([]depPolicyDep, error) {
+	var moduleKeys []bufmodule.ModuleKey
+	moduleFullNameStringToDepModuleFullNameStrings := make(map[string][]string)
+	if err := graph.WalkNodes(
+		func(moduleKey bufmodule.ModuleKey, _ []bufmodule.ModuleKey, outboundModuleKeys []bufmodule.ModuleKey) error {
+			moduleKeys = append(moduleKeys, moduleKey)
+			for _, outboundModuleKey := range outboundModuleKeys {
+				moduleFullNameStringToDepModuleFullNameStrings[moduleKey.ModuleFullName().String()] = append(
+					moduleFullNameStringToDepModuleFullNameStrings[moduleKey.ModuleFullName().String()],
+					outboundModuleKey.ModuleFullName().String(),
+				)
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	moduleFullNameStringToDepth := make(map[string]int)
+	var queue []string
+	for _, configuredDepModuleRef := range configuredDepModuleRefs {
+		moduleFullNameString := configuredDepModuleRef.ModuleFullName().String()
+		moduleFullNameStringToDepth[moduleFullNameString] = 1
+		queue = append(queue, moduleFullNameString)
+	}
+	for len(queue) > 0 {
+		moduleFullNameString := queue[0]
+		queue = queue[1:]
+		for _, depModuleFullNameString := range moduleFullNameStringToDepModuleFullNameStrings[moduleFullNameString] {
+			if _, ok := moduleFullNameStringToDepth[depModuleFullNameString]; ok {
+				continue
+			}
+			moduleFullNameStringToDepth[depModuleFullNameString] = moduleFullNameStringToDepth[moduleFullNameString] + 1
+			queue = append(queue, depModuleFullNameString)
+		}
+	}
+	deps := make([]depPolicyDep, len(moduleKeys))
+	for i, moduleKey := range moduleKeys {
+		deps[i] = depPolicyDep{
+			moduleFullName: moduleKey.ModuleFullName(),
+			depth:          moduleFullNameStringToDepth[moduleKey.ModuleFullName().String()],
+		}
+	}
+	return deps, nil
+}
+
+// getBufYAMLFileInfo returns the FileInfo of the buf.yaml file at the prefix.
+//
+// Returns nil if the buf.yaml file does not exist, for example if it was given as an override.
+func getBufYAMLFileInfo(ctx context.Context, bucket storage.ReadBucket, prefix string) (bufanalysis.FileInfo, error) {
+	objectInfo, err := bucket.Stat(ctx, normalpath.Join(prefix, bufconfig.DefaultBufYAMLFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return objectInfo, nil
+}
+
+func depPolicyEntriesMatch(entries []string, moduleFullName bufmodule.ModuleFullName) bool {
+	return slices.ContainsFunc(
+		entries,
+		func(entry string) bool {
+			return bufconfig.DepPolicyEntryMatches(entry, moduleFullName)
+		},
+	)
+}
+
+// isLabelRef returns true if the ref of a ModuleRef is a label, that is it is
+// neither empty nor a commit ID.
+func isLabelRef(ref string) bool {
+	if ref == "" {
+		return false
+	}
+	_, err := uuidutil.FromDashless(ref)
+	return err != nil
+}
@@ -84,6 +84,24 @@ func WithTargetPaths(targetPaths []string, targetExcludePaths []string) Workspac
 	}
 }
 
+// WithChangedPaths returns a new WorkspaceBucketOption that says to only target the
+// Modules that are affected by the given changed paths.
+//
+// A Module is affected if one of the changed paths is within its directory, or if it
+// transitively depends on an affected Module. Changes to the buf.yaml, buf.lock, or
+// buf.work.yaml at the root of the workspace affect all Modules. Modules that would not
+// otherwise be targeted are never targeted.
+//
+// The changed paths are relative to the root of the bucket, and may include paths that
+// no longer exist. If no Modules are affected, ErrNoAffectedModules is returned.
+//
+// This is used to implement --affected-since.
+func WithChangedPaths(changedPaths []string) WorkspaceBucketOption {
+	return &workspaceChangedPathsOption{
+		changedPaths: changedPaths,
+	}
+}
+
 // WithConfigOverride applies the config override.
 //
 // This flag will only work if no buf.work.yaml is detected, and the buf.yaml is a v1beta1
@@ -142,6 +160,15 @@ func (c *workspaceConfigOverrideOption) applyToWorkspaceModuleKeyConfig(config *
 	config.configOverride = c.configOverride
 }
 
+type workspaceChangedPathsOption struct {
+	changedPaths []string
+}
+
+func (c *workspaceChangedPathsOption) applyToWorkspaceBucketConfig(config *workspaceBucketConfig) {
+	config.targetAffectedModulesOnly = true
+	config.changedPaths = c.changedPaths
+}
+
 type workspaceIgnoreAndDisallowV1BufWorkYAMLsOption struct{}
 
 func (c *workspaceIgnoreAndDisallowV1BufWorkYAMLsOption) applyToWorkspaceBucketConfig(config *workspaceBucketConfig) {
@@ -153,6 +180,8 @@ type workspaceBucketConfig struct {
 	includePackageFiles             bool
 	configOverride                  string
 	ignoreAndDisallowV1BufWorkYAMLs bool
+	targetAffectedModulesOnly       bool
+	changedPaths                    []string
 }
 
 func newWorkspaceBucketConfig(options []WorkspaceBucketOption) (*workspaceBucketConfig, error) {
@@ -163,6 +192,7 @@ func newWorkspaceBucketConfig(options []WorkspaceBucketOption) (*workspaceBucket
 	if config.protoFileTargetPath != "" {
 		config.protoFileTargetPath = normalpath.Normalize(config.protoFileTargetPath)
 	}
+	config.changedPaths = slicesext.Map(config.changedPaths, normalpath.Normalize)
 	return config, nil
 }
 
@@ -70,6 +70,12 @@ type Workspace interface {
 	// in the workspace. This should result in items such as the linter or breaking change
 	// detector ignoring these configs anyways.
 	GetBreakingConfigForOpaqueID(opaqueID string) bufconfig.BreakingConfig
+	// GetModuleDirPathForOpaqueID gets the directory path of the local Module with the
+	// OpaqueID, relative to the root of the workspace.
+	//
+	// Returns empty if there is no local Module with the given OpaqueID in the workspace,
+	// such as Modules read from buf.lock files, or Modules for ModuleKeys.
+	GetModuleDirPathForOpaqueID(opaqueID string) string
 	// PluginConfigs gets the configured PluginConfigs of the Workspace.
 	PluginConfigs() []bufconfig.PluginConfig
 	// ConfiguredDepModuleRefs returns the configured dependencies of the Workspace as ModuleRefs.
@@ -103,6 +109,7 @@ type workspace struct {
 
 	opaqueIDToLintConfig     map[string]bufconfig.LintConfig
 	opaqueIDToBreakingConfig map[string]bufconfig.BreakingConfig
+	opaqueIDToModuleDirPath  map[string]string
 	pluginConfigs            []bufconfig.PluginConfig
 	configuredDepModuleRefs  []bufmodule.ModuleRef
 
@@ -115,6 +122,7 @@ func newWorkspace(
 	moduleSet bufmodule.ModuleSet,
 	opaqueIDToLintConfig map[string]bufconfig.LintConfig,
 	opaqueIDToBreakingConfig map[string]bufconfig.BreakingConfig,
+	opaqueIDToModuleDirPath map[string]string,
 	pluginConfigs []bufconfig.PluginConfig,
 	configuredDepModuleRefs []bufmodule.ModuleRef,
 	isV2 bool,
@@ -123,6 +131,7 @@ func newWorkspace(
 		ModuleSet:                moduleSet,
 		opaqueIDToLintConfig:     opaqueIDToLintConfig,
 		opaqueIDToBreakingConfig: opaqueIDToBreakingConfig,
+		opaqueIDToModuleDirPath:  opaqueIDToModuleDirPath,
 		pluginConfigs:            pluginConfigs,
 		configuredDepModuleRefs:  configuredDepModuleRefs,
 		isV2:                     isV2,
@@ -137,6 +146,10 @@ func (w *workspace) GetBreakingConfigForOpaqueID(opaqueID string) bufconfig.Brea
 	return w.opaqueIDToBreakingConfig[opaqueID]
 }
 
+func (w *workspace) GetModuleDirPathForOpaqueID(opaqueID string) string {
+	return w.opaqueIDToModuleDirPath[opaqueID]
+}
+
 func (w *workspace) PluginConfigs() []bufconfig.PluginConfig {
 	return slicesext.Copy(w.pluginConfigs)
 }
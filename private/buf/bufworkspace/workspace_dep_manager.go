@@ -17,10 +17,15 @@ package bufworkspace
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"log/slog"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulestore"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/storage"
 	"github.com/bufbuild/buf/private/pkg/syserror"
 )
@@ -38,11 +43,21 @@ type WorkspaceDepManager interface {
 	BufLockFileDigestType() bufmodule.DigestType
 	// ExisingBufLockFileDepModuleKeys returns the ModuleKeys from the buf.lock file.
 	ExistingBufLockFileDepModuleKeys(ctx context.Context) ([]bufmodule.ModuleKey, error)
+	// ExistingBufLockFileGitDepKeys returns the GitDepKeys from the buf.lock file.
+	ExistingBufLockFileGitDepKeys(ctx context.Context) ([]bufconfig.GitDepKey, error)
 	// UpdateBufLockFile updates the lock file that backs the Workspace to contain exactly
 	// the given ModuleKeys.
 	//
+	// Any GitDepKeys in the existing buf.lock are kept.
 	// If a buf.lock does not exist, one will be created.
 	UpdateBufLockFile(ctx context.Context, depModuleKeys []bufmodule.ModuleKey) error
+	// UpdateBufLockFileGitDepKeys updates the lock file that backs the Workspace to contain
+	// exactly the given GitDepKeys.
+	//
+	// Any ModuleKeys in the existing buf.lock are kept. Git deps are only supported for
+	// v2 buf.yaml files.
+	// If a buf.lock does not exist, one will be created.
+	UpdateBufLockFileGitDepKeys(ctx context.Context, gitDepKeys []bufconfig.GitDepKey) error
 	// ConfiguredDepModuleRefs returns the configured dependencies of the Workspace as ModuleRefs.
 	//
 	// These come from buf.yaml files.
@@ -55,6 +70,36 @@ type WorkspaceDepManager interface {
 	//
 	// Sorted.
 	ConfiguredDepModuleRefs(ctx context.Context) ([]bufmodule.ModuleRef, error)
+	// ConfiguredGitDepRefs returns the configured dependencies of the Workspace that are git
+	// repositories.
+	//
+	// These come from v2 buf.yaml files. Always empty for v1beta1/v1 buf.yaml files.
+	//
+	// Sorted by Location.
+	ConfiguredGitDepRefs(ctx context.Context) ([]bufconfig.GitDepRef, error)
+	// VendorDirPath returns the directory that dependencies are vendored to, relative to
+	// the root of the workspace.
+	//
+	// Returns empty if no vendor directory is configured. Vendoring is only supported
+	// for v2 buf.yaml files.
+	VendorDirPath(ctx context.Context) (string, error)
+	// UpdateVendorDir updates the vendor directory to contain exactly the given ModuleDatas.
+	//
+	// The digests of the ModuleDatas are verified before they are written.
+	// Returns an error if no vendor directory is configured.
+	UpdateVendorDir(ctx context.Context, depModuleDatas []bufmodule.ModuleData) error
+	// ValidateDepPolicy validates the given dependencies against the dependency policy
+	// in the buf.yaml, before they are written to the buf.lock.
+	//
+	// The depModuleKeys are expected to contain all transitive dependencies. The graph of the
+	// dependencies is only retrieved from the GraphProvider if a dependency policy is configured.
+	// Violations are returned as a bufanalysis.FileAnnotationSet. Dependency policies are only
+	// supported for v2 buf.yaml files.
+	ValidateDepPolicy(
+		ctx context.Context,
+		graphProvider bufmodule.GraphProvider,
+		depModuleKeys []bufmodule.ModuleKey,
+	) error
 
 	isWorkspaceDepManager()
 }
@@ -62,6 +107,7 @@ type WorkspaceDepManager interface {
 // *** PRIVATE ***
 
 type workspaceDepManager struct {
+	logger *slog.Logger
 	bucket storage.ReadWriteBucket
 	// targetSubDirPath is the relative path within the bucket where a buf.yaml file should be and where a
 	// buf.lock can be written.
@@ -81,11 +127,13 @@ type workspaceDepManager struct {
 }
 
 func newWorkspaceDepManager(
+	logger *slog.Logger,
 	bucket storage.ReadWriteBucket,
 	targetSubDirPath string,
 	isV2 bool,
 ) *workspaceDepManager {
 	return &workspaceDepManager{
+		logger:           logger,
 		bucket:           bucket,
 		targetSubDirPath: targetSubDirPath,
 		isV2:             isV2,
@@ -117,6 +165,20 @@ func (w *workspaceDepManager) ConfiguredDepModuleRefs(ctx context.Context) ([]bu
 	return bufYAMLFile.ConfiguredDepModuleRefs(), nil
 }
 
+func (w *workspaceDepManager) ConfiguredGitDepRefs(ctx context.Context) ([]bufconfig.GitDepRef, error) {
+	if !w.isV2 {
+		return nil, nil
+	}
+	bufYAMLFile, err := bufconfig.GetBufYAMLFileForPrefix(ctx, w.bucket, w.targetSubDirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return bufYAMLFile.ConfiguredGitDepRefs(), nil
+}
+
 func (w *workspaceDepManager) BufLockFileDigestType() bufmodule.DigestType {
 	if w.isV2 {
 		return bufmodule.DigestTypeB5
@@ -135,11 +197,125 @@ func (w *workspaceDepManager) ExistingBufLockFileDepModuleKeys(ctx context.Conte
 	return bufLockFile.DepModuleKeys(), nil
 }
 
+func (w *workspaceDepManager) ExistingBufLockFileGitDepKeys(ctx context.Context) ([]bufconfig.GitDepKey, error) {
+	bufLockFile, err := bufconfig.GetBufLockFileForPrefix(ctx, w.bucket, w.targetSubDirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return bufLockFile.GitDepKeys(), nil
+}
+
 func (w *workspaceDepManager) UpdateBufLockFile(ctx context.Context, depModuleKeys []bufmodule.ModuleKey) error {
+	gitDepKeys, err := w.ExistingBufLockFileGitDepKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return w.updateBufLockFile(ctx, depModuleKeys, gitDepKeys)
+}
+
+func (w *workspaceDepManager) UpdateBufLockFileGitDepKeys(ctx context.Context, gitDepKeys []bufconfig.GitDepKey) error {
+	if len(gitDepKeys) > 0 && !w.isV2 {
+		return errors.New("git deps are only supported for v2 buf.yaml files")
+	}
+	depModuleKeys, err := w.ExistingBufLockFileDepModuleKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return w.updateBufLockFile(ctx, depModuleKeys, gitDepKeys)
+}
+
+func (w *workspaceDepManager) VendorDirPath(ctx context.Context) (string, error) {
+	if !w.isV2 {
+		return "", nil
+	}
+	bufYAMLFile, err := bufconfig.GetBufYAMLFileForPrefix(ctx, w.bucket, w.targetSubDirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return bufYAMLFile.VendorDirPath(), nil
+}
+
+func (w *workspaceDepManager) UpdateVendorDir(ctx context.Context, depModuleDatas []bufmodule.ModuleData) error {
+	vendorDirPath, err := w.VendorDirPath(ctx)
+	if err != nil {
+		return err
+	}
+	if vendorDirPath == "" {
+		return errors.New(`no vendor directory configured, set "vendor" in your v2 buf.yaml`)
+	}
+	// Verify all digests before we touch the existing vendor directory.
+	for _, depModuleData := range depModuleDatas {
+		if _, err := depModuleData.Bucket(); err != nil {
+			return fmt.Errorf("could not verify %s: %w", depModuleData.ModuleKey().String(), err)
+		}
+	}
+	vendorDirPath = normalpath.Join(w.targetSubDirPath, vendorDirPath)
+	if err := w.bucket.DeleteAll(ctx, vendorDirPath); err != nil {
+		return err
+	}
+	return bufmodulestore.NewModuleDataStore(
+		w.logger,
+		storage.MapReadWriteBucket(w.bucket, storage.MapOnPrefix(vendorDirPath)),
+		// The vendor directory is only ever written by a single buf invocation, and
+		// lock files should not be checked in.
+		filelock.NewNopLocker(),
+	).PutModuleDatas(ctx, depModuleDatas)
+}
+
+func (w *workspaceDepManager) ValidateDepPolicy(
+	ctx context.Context,
+	graphProvider bufmodule.GraphProvider,
+	depModuleKeys []bufmodule.ModuleKey,
+) error {
+	if !w.isV2 || len(depModuleKeys) == 0 {
+		return nil
+	}
+	bufYAMLFile, err := bufconfig.GetBufYAMLFileForPrefix(ctx, w.bucket, w.targetSubDirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	depPolicyConfig := bufYAMLFile.DepPolicyConfig()
+	if depPolicyConfig == nil {
+		return nil
+	}
+	graph, err := graphProvider.GetGraphForModuleKeys(ctx, depModuleKeys)
+	if err != nil {
+		return err
+	}
+	configuredDepModuleRefs := bufYAMLFile.ConfiguredDepModuleRefs()
+	deps, err := getDepPolicyDepsForGraph(configuredDepModuleRefs, graph)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := getBufYAMLFileInfo(ctx, w.bucket, w.targetSubDirPath)
+	if err != nil {
+		return err
+	}
+	return validateDepPolicy(depPolicyConfig, fileInfo, configuredDepModuleRefs, deps)
+}
+
+func (w *workspaceDepManager) updateBufLockFile(
+	ctx context.Context,
+	depModuleKeys []bufmodule.ModuleKey,
+	gitDepKeys []bufconfig.GitDepKey,
+) error {
 	var bufLockFile bufconfig.BufLockFile
 	var err error
 	if w.isV2 {
-		bufLockFile, err = bufconfig.NewBufLockFile(bufconfig.FileVersionV2, depModuleKeys)
+		bufLockFile, err = bufconfig.NewBufLockFile(
+			bufconfig.FileVersionV2,
+			depModuleKeys,
+			bufconfig.NewBufLockFileWithGitDepKeys(gitDepKeys...),
+		)
 		if err != nil {
 			return err
 		}
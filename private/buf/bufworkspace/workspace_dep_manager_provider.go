@@ -79,10 +79,10 @@ func (w *workspaceDepManagerProvider) GetWorkspaceDepManager(
 		// A v2 workspace was found, but we make sure
 		bufYAMLFile := controllingWorkspace.BufYAMLFile()
 		if bufYAMLFile.FileVersion() == bufconfig.FileVersionV2 {
-			return newWorkspaceDepManager(bucket, controllingWorkspace.Path(), true), nil
+			return newWorkspaceDepManager(w.logger, bucket, controllingWorkspace.Path(), true), nil
 		}
 	}
 	// Otherwise we simply ignore any buf.work.yaml that was found and attempt to build
 	// a v1 module at the SubDirPath
-	return newWorkspaceDepManager(bucket, bucketTargeting.SubDirPath(), false), nil
+	return newWorkspaceDepManager(w.logger, bucket, bucketTargeting.SubDirPath(), false), nil
 }
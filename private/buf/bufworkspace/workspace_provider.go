@@ -20,10 +20,14 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"slices"
 
 	"github.com/bufbuild/buf/private/buf/buftarget"
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufgitdep"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulecache"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulestore"
 	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/slicesext"
 	"github.com/bufbuild/buf/private/pkg/slogext"
@@ -76,12 +80,14 @@ func NewWorkspaceProvider(
 	graphProvider bufmodule.GraphProvider,
 	moduleDataProvider bufmodule.ModuleDataProvider,
 	commitProvider bufmodule.CommitProvider,
+	gitDepProvider bufgitdep.Provider,
 ) WorkspaceProvider {
 	return newWorkspaceProvider(
 		logger,
 		graphProvider,
 		moduleDataProvider,
 		commitProvider,
+		gitDepProvider,
 	)
 }
 
@@ -92,6 +98,7 @@ type workspaceProvider struct {
 	graphProvider      bufmodule.GraphProvider
 	moduleDataProvider bufmodule.ModuleDataProvider
 	commitProvider     bufmodule.CommitProvider
+	gitDepProvider     bufgitdep.Provider
 }
 
 func newWorkspaceProvider(
@@ -99,12 +106,14 @@ func newWorkspaceProvider(
 	graphProvider bufmodule.GraphProvider,
 	moduleDataProvider bufmodule.ModuleDataProvider,
 	commitProvider bufmodule.CommitProvider,
+	gitDepProvider bufgitdep.Provider,
 ) *workspaceProvider {
 	return &workspaceProvider{
 		logger:             logger,
 		graphProvider:      graphProvider,
 		moduleDataProvider: moduleDataProvider,
 		commitProvider:     commitProvider,
+		gitDepProvider:     gitDepProvider,
 	}
 }
 
@@ -207,6 +216,7 @@ func (w *workspaceProvider) GetWorkspaceForModuleKey(
 		moduleSet,
 		opaqueIDToLintConfig,
 		opaqueIDToBreakingConfig,
+		nil,
 		pluginConfigs,
 		nil,
 		false,
@@ -243,6 +253,47 @@ func (w *workspaceProvider) GetWorkspaceForBucket(
 	if err != nil {
 		return nil, err
 	}
+	workspace, err := w.getWorkspaceForBucketAndWorkspaceTargeting(
+		ctx,
+		bucket,
+		workspaceTargeting,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !config.targetAffectedModulesOnly {
+		return workspace, nil
+	}
+	affectedOpaqueIDs, err := getAffectedTargetOpaqueIDs(workspace, config.changedPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(affectedOpaqueIDs) == 0 {
+		return nil, ErrNoAffectedModules
+	}
+	// We need the full Workspace to compute the affected Modules, as the affected Modules
+	// depend on the graph of the target Modules. Once we know the affected Modules, we
+	// retarget the ModuleSet so that the unaffected Modules are non-targets.
+	moduleSet, err := workspace.WithTargetOpaqueIDs(affectedOpaqueIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return newWorkspace(
+		moduleSet,
+		workspace.opaqueIDToLintConfig,
+		workspace.opaqueIDToBreakingConfig,
+		workspace.opaqueIDToModuleDirPath,
+		workspace.pluginConfigs,
+		workspace.configuredDepModuleRefs,
+		workspace.isV2,
+	), nil
+}
+
+func (w *workspaceProvider) getWorkspaceForBucketAndWorkspaceTargeting(
+	ctx context.Context,
+	bucket storage.ReadBucket,
+	workspaceTargeting *workspaceTargeting,
+) (*workspace, error) {
 	if workspaceTargeting.v2 != nil {
 		return w.getWorkspaceForBucketBufYAMLV2(
 			ctx,
@@ -357,6 +408,7 @@ func (w *workspaceProvider) getWorkspaceForBucketAndModuleDirPathsV1Beta1OrV1(
 	return w.getWorkspaceForBucketModuleSet(
 		moduleSet,
 		v1WorkspaceTargeting.bucketIDToModuleConfig,
+		getBucketIDToModuleDirPath(v1WorkspaceTargeting.moduleBucketsAndTargeting),
 		nil,
 		v1WorkspaceTargeting.allConfiguredDepModuleRefs,
 		false,
@@ -368,7 +420,21 @@ func (w *workspaceProvider) getWorkspaceForBucketBufYAMLV2(
 	bucket storage.ReadBucket,
 	v2Targeting *v2Targeting,
 ) (*workspace, error) {
-	moduleSetBuilder := bufmodule.NewModuleSetBuilder(ctx, w.logger, w.moduleDataProvider, w.commitProvider)
+	moduleDataProvider := w.moduleDataProvider
+	commitProvider := w.commitProvider
+	if vendorDirPath := v2Targeting.bufYAMLFile.VendorDirPath(); vendorDirPath != "" {
+		// Dependencies are only read from the vendor directory, we never reach out to
+		// the network or the cache.
+		moduleDataProvider = bufmodulecache.NewVendorModuleDataProvider(
+			w.logger,
+			bufmodulestore.NewReadOnlyModuleDataStore(
+				w.logger,
+				storage.MapReadBucket(bucket, storage.MapOnPrefix(vendorDirPath)),
+			),
+		)
+		commitProvider = bufmodule.NopCommitProvider
+	}
+	moduleSetBuilder := bufmodule.NewModuleSetBuilder(ctx, w.logger, moduleDataProvider, commitProvider)
 	bufLockFile, err := bufconfig.GetBufLockFileForPrefix(
 		ctx,
 		bucket,
@@ -397,6 +463,88 @@ func (w *workspaceProvider) getWorkspaceForBucketBufYAMLV2(
 				false,
 			)
 		}
+		if gitDepKeys := bufLockFile.GitDepKeys(); len(gitDepKeys) > 0 {
+			gitDepReadBuckets, err := w.gitDepProvider.GetReadBucketsForGitDepKeys(ctx, gitDepKeys)
+			if err != nil {
+				return nil, err
+			}
+			// The buf.yaml of a git dep is optional, and may be nil.
+			gitDepBufYAMLFiles := make([]bufconfig.BufYAMLFile, len(gitDepKeys))
+			gitDepModuleFullNames := make([]bufmodule.ModuleFullName, len(gitDepKeys))
+			for i, gitDepKey := range gitDepKeys {
+				gitDepBufYAMLFile, err := getGitDepBufYAMLFile(ctx, gitDepKey, gitDepReadBuckets[i])
+				if err != nil {
+					return nil, err
+				}
+				gitDepBufYAMLFiles[i] = gitDepBufYAMLFile
+				var gitDepModuleFullName bufmodule.ModuleFullName
+				if gitDepBufYAMLFile != nil {
+					gitDepModuleFullName = gitDepBufYAMLFile.ModuleConfigs()[0].ModuleFullName()
+				}
+				if gitDepModuleFullName == nil {
+					gitDepModuleFullName, err = bufgitdep.ModuleFullNameForGitDepKey(gitDepKey)
+					if err != nil {
+						return nil, err
+					}
+				}
+				gitDepModuleFullNames[i] = gitDepModuleFullName
+			}
+			// The dependencies of a git dep must be satisfied by the modules of the workspace. Git
+			// deps are not resolved by the BSR, so their dependencies are not in the buf.lock unless
+			// the workspace also declares them.
+			availableModuleFullNameStrings := make(map[string]struct{})
+			for _, depModuleKey := range bufLockFile.DepModuleKeys() {
+				availableModuleFullNameStrings[depModuleKey.ModuleFullName().String()] = struct{}{}
+			}
+			for _, moduleConfig := range v2Targeting.bucketIDToModuleConfig {
+				if moduleFullName := moduleConfig.ModuleFullName(); moduleFullName != nil {
+					availableModuleFullNameStrings[moduleFullName.String()] = struct{}{}
+				}
+			}
+			for _, gitDepModuleFullName := range gitDepModuleFullNames {
+				availableModuleFullNameStrings[gitDepModuleFullName.String()] = struct{}{}
+			}
+			for i, gitDepKey := range gitDepKeys {
+				gitDepBufYAMLFile := gitDepBufYAMLFiles[i]
+				if gitDepBufYAMLFile == nil {
+					// Without a buf.yaml, the SubDirPath is the single root of the module, and the
+					// module has no dependencies.
+					moduleSetBuilder.AddRemoteModuleForBucket(
+						gitDepReadBuckets[i],
+						gitDepModuleFullNames[i],
+						"git dep "+gitDepKey.Location(),
+					)
+					continue
+				}
+				for _, depModuleRef := range gitDepBufYAMLFile.ConfiguredDepModuleRefs() {
+					if _, ok := availableModuleFullNameStrings[depModuleRef.ModuleFullName().String()]; !ok {
+						return nil, fmt.Errorf(
+							"git dep %s depends on %s, which must also be declared as a dependency in your buf.yaml",
+							gitDepKey.Location(),
+							depModuleRef.ModuleFullName().String(),
+						)
+					}
+				}
+				moduleConfig := gitDepBufYAMLFile.ModuleConfigs()[0]
+				rootBuckets, err := getModuleRootBuckets(
+					storage.MapReadBucket(gitDepReadBuckets[i], storage.MapOnPrefix(moduleConfig.DirPath())),
+					moduleConfig,
+				)
+				if err != nil {
+					return nil, err
+				}
+				// Git deps are not part of the workspace, so they are added as remote modules. This
+				// means that they are never pushed, and are treated like any other dependency.
+				moduleSetBuilder.AddRemoteModuleForBucket(
+					storage.MultiReadBucket(rootBuckets...),
+					gitDepModuleFullNames[i],
+					"git dep "+gitDepKey.Location(),
+				)
+			}
+		}
+	}
+	if err := validateGitDepRefsPinned(v2Targeting.bufYAMLFile.ConfiguredGitDepRefs(), bufLockFile); err != nil {
+		return nil, err
 	}
 	// Only check for duplicate module description in v2, which would be an user error, i.e.
 	// This is not a system error:
@@ -450,9 +598,28 @@ func (w *workspaceProvider) getWorkspaceForBucketBufYAMLV2(
 	if err != nil {
 		return nil, err
 	}
+	if depPolicyConfig := v2Targeting.bufYAMLFile.DepPolicyConfig(); depPolicyConfig != nil {
+		deps, err := getDepPolicyDepsForModuleSet(moduleSet, depPolicyConfig.MaxDepth() > 0)
+		if err != nil {
+			return nil, err
+		}
+		fileInfo, err := getBufYAMLFileInfo(ctx, bucket, ".")
+		if err != nil {
+			return nil, err
+		}
+		if err := validateDepPolicy(
+			depPolicyConfig,
+			fileInfo,
+			v2Targeting.bufYAMLFile.ConfiguredDepModuleRefs(),
+			deps,
+		); err != nil {
+			return nil, err
+		}
+	}
 	return w.getWorkspaceForBucketModuleSet(
 		moduleSet,
 		v2Targeting.bucketIDToModuleConfig,
+		getBucketIDToModuleDirPath(v2Targeting.moduleBucketsAndTargeting),
 		v2Targeting.bufYAMLFile.PluginConfigs(),
 		v2Targeting.bufYAMLFile.ConfiguredDepModuleRefs(),
 		true,
@@ -463,6 +630,8 @@ func (w *workspaceProvider) getWorkspaceForBucketBufYAMLV2(
 func (w *workspaceProvider) getWorkspaceForBucketModuleSet(
 	moduleSet bufmodule.ModuleSet,
 	bucketIDToModuleConfig map[string]bufconfig.ModuleConfig,
+	// Only contains the local Modules read from the bucket, i.e. not git deps.
+	bucketIDToModuleDirPath map[string]string,
 	pluginConfigs []bufconfig.PluginConfig,
 	// Expected to already be unique by ModuleFullName.
 	configuredDepModuleRefs []bufmodule.ModuleRef,
@@ -470,8 +639,12 @@ func (w *workspaceProvider) getWorkspaceForBucketModuleSet(
 ) (*workspace, error) {
 	opaqueIDToLintConfig := make(map[string]bufconfig.LintConfig)
 	opaqueIDToBreakingConfig := make(map[string]bufconfig.BreakingConfig)
+	opaqueIDToModuleDirPath := make(map[string]string)
 	for _, module := range moduleSet.Modules() {
 		if bucketID := module.BucketID(); bucketID != "" {
+			if moduleDirPath, ok := bucketIDToModuleDirPath[bucketID]; ok {
+				opaqueIDToModuleDirPath[module.OpaqueID()] = moduleDirPath
+			}
 			moduleConfig, ok := bucketIDToModuleConfig[bucketID]
 			if !ok {
 				// This is a system error.
@@ -488,6 +661,7 @@ func (w *workspaceProvider) getWorkspaceForBucketModuleSet(
 		moduleSet,
 		opaqueIDToLintConfig,
 		opaqueIDToBreakingConfig,
+		opaqueIDToModuleDirPath,
 		pluginConfigs,
 		configuredDepModuleRefs,
 		isV2,
@@ -530,3 +704,130 @@ func getLocalModuleDescription(pathDescription string, moduleConfig bufconfig.Mo
 	}
 	return description
 }
+
+// getGitDepBufYAMLFile returns the buf.yaml of the git dep, or nil if the git dep has no buf.yaml.
+//
+// If present, the buf.yaml must configure exactly one module. If the module has no name, the
+// name is derived from the location of the git dep.
+func getGitDepBufYAMLFile(
+	ctx context.Context,
+	gitDepKey bufconfig.GitDepKey,
+	readBucket storage.ReadBucket,
+) (bufconfig.BufYAMLFile, error) {
+	bufYAMLFile, err := bufconfig.GetBufYAMLFileForPrefix(ctx, readBucket, ".")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read %s of git dep %s: %w", bufconfig.DefaultBufYAMLFileName, gitDepKey.Location(), err)
+	}
+	moduleConfigs := bufYAMLFile.ModuleConfigs()
+	if len(moduleConfigs) != 1 {
+		return nil, fmt.Errorf(
+			"git dep %s must configure exactly one module in its %s but configures %d",
+			gitDepKey.Location(),
+			bufconfig.DefaultBufYAMLFileName,
+			len(moduleConfigs),
+		)
+	}
+	if len(bufYAMLFile.ConfiguredGitDepRefs()) > 0 {
+		return nil, fmt.Errorf("git dep %s has git deps of its own, which are not supported", gitDepKey.Location())
+	}
+	return bufYAMLFile, nil
+}
+
+// validateGitDepRefsPinned validates that every git dep in the buf.yaml has a commit pinned
+// in the buf.lock. Unlike module deps, git deps cannot be resolved on the fly.
+//
+// bufLockFile may be nil.
+func validateGitDepRefsPinned(gitDepRefs []bufconfig.GitDepRef, bufLockFile bufconfig.BufLockFile) error {
+	pinnedLocations := make(map[string]struct{})
+	if bufLockFile != nil {
+		for _, gitDepKey := range bufLockFile.GitDepKeys() {
+			pinnedLocations[gitDepKey.Location()] = struct{}{}
+		}
+	}
+	for _, gitDepRef := range gitDepRefs {
+		if _, ok := pinnedLocations[gitDepRef.Location()]; !ok {
+			return fmt.Errorf(`git dep %s is not pinned in buf.lock, run "buf dep update" to pin it`, gitDepRef.String())
+		}
+	}
+	return nil
+}
+
+func getBucketIDToModuleDirPath(moduleBucketsAndTargeting []*moduleBucketAndModuleTargeting) map[string]string {
+	bucketIDToModuleDirPath := make(map[string]string, len(moduleBucketsAndTargeting))
+	for _, moduleBucketAndTargeting := range moduleBucketsAndTargeting {
+		bucketIDToModuleDirPath[moduleBucketAndTargeting.bucketID] = moduleBucketAndTargeting.moduleTargeting.moduleDirPath
+	}
+	return bucketIDToModuleDirPath
+}
+
+// getAffectedTargetOpaqueIDs returns the OpaqueIDs of the target Modules of the Workspace
+// that are affected by the changed paths.
+//
+// A Module is changed if a changed path is within its directory. All Modules are changed
+// if a configuration file at the root of the workspace changed. A target Module is affected
+// if it is changed, or if it transitively depends on a changed Module.
+func getAffectedTargetOpaqueIDs(workspace *workspace, changedPaths []string) ([]string, error) {
+	isWorkspaceConfigChanged := slices.ContainsFunc(
+		changedPaths,
+		func(changedPath string) bool {
+			return changedPath == bufconfig.DefaultBufYAMLFileName ||
+				changedPath == bufconfig.DefaultBufLockFileName ||
+				changedPath == bufconfig.DefaultBufWorkYAMLFileName
+		},
+	)
+	graph, err := bufmodule.ModuleSetToDAG(workspace)
+	if err != nil {
+		return nil, err
+	}
+	affectedOpaqueIDs := make(map[string]struct{})
+	var addAffectedModuleRec func(bufmodule.Module) error
+	addAffectedModuleRec = func(module bufmodule.Module) error {
+		if _, ok := affectedOpaqueIDs[module.OpaqueID()]; ok {
+			return nil
+		}
+		affectedOpaqueIDs[module.OpaqueID()] = struct{}{}
+		dependentModules, err := graph.InboundNodes(module.OpaqueID())
+		if err != nil {
+			return err
+		}
+		for _, dependentModule := range dependentModules {
+			if err := addAffectedModuleRec(dependentModule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, module := range bufmodule.ModuleSetLocalModules(workspace) {
+		// The graph only contains the target Modules and their dependencies. Changes to Modules
+		// outside of the graph cannot affect the target Modules.
+		if !graph.ContainsNode(module.OpaqueID()) {
+			continue
+		}
+		moduleDirPath := workspace.GetModuleDirPathForOpaqueID(module.OpaqueID())
+		if moduleDirPath == "" {
+			// Git deps do not have a directory in the workspace.
+			continue
+		}
+		isModuleChanged := isWorkspaceConfigChanged || slices.ContainsFunc(
+			changedPaths,
+			func(changedPath string) bool {
+				return normalpath.EqualsOrContainsPath(moduleDirPath, changedPath, normalpath.Relative)
+			},
+		)
+		if isModuleChanged {
+			if err := addAffectedModuleRec(module); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var affectedTargetOpaqueIDs []string
+	for _, module := range bufmodule.ModuleSetTargetModules(workspace) {
+		if _, ok := affectedOpaqueIDs[module.OpaqueID()]; ok {
+			affectedTargetOpaqueIDs = append(affectedTargetOpaqueIDs, module.OpaqueID())
+		}
+	}
+	return affectedTargetOpaqueIDs, nil
+}
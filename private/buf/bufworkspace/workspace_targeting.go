@@ -209,6 +209,14 @@ func v2WorkspaceTargeting(
 	bucketIDToModuleConfig := make(map[string]bufconfig.ModuleConfig)
 	moduleBucketsAndTargeting := make([]*moduleBucketAndModuleTargeting, 0, len(bufYAMLFile.ModuleConfigs()))
 	moduleConfigs := bufYAMLFile.ModuleConfigs()
+	if vendorDirPath := bufYAMLFile.VendorDirPath(); vendorDirPath != "" {
+		// Vendored dependencies are never part of the workspace modules, even if the vendor
+		// directory is contained within a module directory.
+		bucket = storage.FilterReadBucket(
+			bucket,
+			storage.MatchNot(storage.MatchPathContained(vendorDirPath)),
+		)
+	}
 	bucketIDsForModuleConfigs := bucketIDsForModuleConfigsV2(moduleConfigs)
 	if len(bucketIDsForModuleConfigs) != len(moduleConfigs) {
 		// This is impossible, as the length is guaranteed by bucketIDsForModuleConfigsV2.
@@ -574,6 +582,75 @@ func getMappedModuleBucketAndModuleTargeting(
 		workspaceBucket,
 		storage.MapOnPrefix(moduleDirPath),
 	)
+	rootBuckets, err := getModuleRootBuckets(moduleBucket, moduleConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	docStorageReadBucket, err := bufmodule.GetDocStorageReadBucket(ctx, moduleBucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	licenseStorageReadBucket, err := bufmodule.GetLicenseStorageReadBucket(ctx, moduleBucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	if useWorkspaceLicenseDocIfNotFoundAtMoudle {
+		isModuleDocBucketEmpty, err := storage.IsEmpty(ctx, docStorageReadBucket, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		// If at moduleDirPath there isn't a doc file, we fall back to use the doc file
+		// at the workspace root if it exists.
+		if isModuleDocBucketEmpty {
+			// We do not need to check if a doc file exists at the workspace root by
+			// checking whether the doc bucket for the workspace is empty, because
+			// this bucket will just be empty there isn't one, which is what we want.
+			docStorageReadBucket, err = bufmodule.GetDocStorageReadBucket(ctx, workspaceBucket)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		isModuleLicenseBucketEmpty, err := storage.IsEmpty(ctx, licenseStorageReadBucket, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		// If at moduleDirPath there isn't a license, we fall back to use the license
+		// at the workspace root if it exists.
+		if isModuleLicenseBucketEmpty {
+			// We do not need to check if this bucket is empty for the same reason, see comment for doc bucket.
+			licenseStorageReadBucket, err = bufmodule.GetLicenseStorageReadBucket(ctx, workspaceBucket)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	rootBuckets = append(
+		rootBuckets,
+		docStorageReadBucket,
+		licenseStorageReadBucket,
+	)
+	mappedModuleBucket := storage.MultiReadBucket(rootBuckets...)
+	moduleTargeting, err := newModuleTargeting(
+		moduleDirPath,
+		slicesext.MapKeysToSlice(moduleConfig.RootToExcludes()),
+		bucketTargeting,
+		config,
+		isTargetModule,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mappedModuleBucket, moduleTargeting, nil
+}
+
+// getModuleRootBuckets returns a bucket of the .proto files for each root of the module, with
+// the includes and excludes of the ModuleConfig applied.
+//
+// The moduleBucket should be mapped to the directory of the module.
+func getModuleRootBuckets(
+	moduleBucket storage.ReadBucket,
+	moduleConfig bufconfig.ModuleConfig,
+) ([]storage.ReadBucket, error) {
 	rootToExcludes := moduleConfig.RootToExcludes()
 	rootToIncludes := moduleConfig.RootToIncludes()
 	var rootBuckets []storage.ReadBucket
@@ -581,7 +658,7 @@ func getMappedModuleBucketAndModuleTargeting(
 		includes, ok := rootToIncludes[root]
 		if !ok {
 			// This should never happen because ModuleConfig guarantees that they have the same keys.
-			return nil, nil, syserror.Newf("expected root %q to be also in rootToIncludes but not found", root)
+			return nil, syserror.Newf("expected root %q to be also in rootToIncludes but not found", root)
 		}
 		// Roots only applies to .proto files.
 		mappers := []storage.Mapper{
@@ -636,61 +713,7 @@ func getMappedModuleBucketAndModuleTargeting(
 			),
 		)
 	}
-	docStorageReadBucket, err := bufmodule.GetDocStorageReadBucket(ctx, moduleBucket)
-	if err != nil {
-		return nil, nil, err
-	}
-	licenseStorageReadBucket, err := bufmodule.GetLicenseStorageReadBucket(ctx, moduleBucket)
-	if err != nil {
-		return nil, nil, err
-	}
-	if useWorkspaceLicenseDocIfNotFoundAtMoudle {
-		isModuleDocBucketEmpty, err := storage.IsEmpty(ctx, docStorageReadBucket, "")
-		if err != nil {
-			return nil, nil, err
-		}
-		// If at moduleDirPath there isn't a doc file, we fall back to use the doc file
-		// at the workspace root if it exists.
-		if isModuleDocBucketEmpty {
-			// We do not need to check if a doc file exists at the workspace root by
-			// checking whether the doc bucket for the workspace is empty, because
-			// this bucket will just be empty there isn't one, which is what we want.
-			docStorageReadBucket, err = bufmodule.GetDocStorageReadBucket(ctx, workspaceBucket)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
-		isModuleLicenseBucketEmpty, err := storage.IsEmpty(ctx, licenseStorageReadBucket, "")
-		if err != nil {
-			return nil, nil, err
-		}
-		// If at moduleDirPath there isn't a license, we fall back to use the license
-		// at the workspace root if it exists.
-		if isModuleLicenseBucketEmpty {
-			// We do not need to check if this bucket is empty for the same reason, see comment for doc bucket.
-			licenseStorageReadBucket, err = bufmodule.GetLicenseStorageReadBucket(ctx, workspaceBucket)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
-	}
-	rootBuckets = append(
-		rootBuckets,
-		docStorageReadBucket,
-		licenseStorageReadBucket,
-	)
-	mappedModuleBucket := storage.MultiReadBucket(rootBuckets...)
-	moduleTargeting, err := newModuleTargeting(
-		moduleDirPath,
-		slicesext.MapKeysToSlice(rootToExcludes),
-		bucketTargeting,
-		config,
-		isTargetModule,
-	)
-	if err != nil {
-		return nil, nil, err
-	}
-	return mappedModuleBucket, moduleTargeting, nil
+	return rootBuckets, nil
 }
 
 func getModuleConfigAndConfiguredDepModuleRefsV1Beta1OrV1(
@@ -19,9 +19,14 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"strings"
 	"testing"
 
 	"github.com/bufbuild/buf/private/buf/buftarget"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufgitdep"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
 	"github.com/bufbuild/buf/private/pkg/dag/dagtest"
@@ -29,6 +34,8 @@ import (
 	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/slicesext"
 	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/stringutil"
 	"github.com/stretchr/testify/require"
@@ -246,6 +253,114 @@ func TestUnusedDep(t *testing.T) {
 	require.Equal(t, MalformedDepTypeUnused, malformedDeps[1].Type())
 }
 
+func TestChangedPaths(t *testing.T) {
+	t.Parallel()
+	testChangedPaths(
+		t,
+		".",
+		[]string{"common/money/proto/acme/money/v1/money.proto"},
+		"buf.testing/acme/bond",
+		"buf.testing/acme/money",
+		"finance/portfolio/proto",
+	)
+	testChangedPaths(
+		t,
+		".",
+		[]string{"finance/portfolio/proto/acme/portfolio/v1/portfolio.proto"},
+		"finance/portfolio/proto",
+	)
+	testChangedPaths(
+		t,
+		".",
+		[]string{"common/geo/proto/acme/geo/v1/deleted.proto", "README.md"},
+		"buf.testing/acme/bond",
+		"buf.testing/acme/geo",
+		"finance/portfolio/proto",
+	)
+	testChangedPaths(
+		t,
+		".",
+		[]string{"buf.lock"},
+		"buf.testing/acme/bond",
+		"buf.testing/acme/geo",
+		"buf.testing/acme/money",
+		"finance/portfolio/proto",
+	)
+	testChangedPaths(
+		t,
+		"finance/bond/proto",
+		[]string{"common/money/proto/acme/money/v1/money.proto"},
+		"buf.testing/acme/bond",
+	)
+	testChangedPaths(
+		t,
+		".",
+		[]string{"README.md"},
+	)
+	testChangedPaths(
+		t,
+		"common/geo/proto",
+		[]string{"finance/bond/proto/acme/bond/v2/bond.proto"},
+	)
+	testChangedPaths(
+		t,
+		".",
+		nil,
+	)
+}
+
+func testChangedPaths(
+	t *testing.T,
+	subDirPath string,
+	changedPaths []string,
+	expectedTargetOpaqueIDs ...string,
+) {
+	ctx := context.Background()
+	workspaceProvider := testNewWorkspaceProvider(
+		t,
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/date",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/date",
+		},
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/extension",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/extension",
+		},
+	)
+	storageosProvider := storageos.NewProvider()
+	bucket, err := storageosProvider.NewReadWriteBucket("testdata/basic/workspacev2")
+	require.NoError(t, err)
+	bucketTargeting, err := buftarget.NewBucketTargeting(
+		ctx,
+		slogtestext.NewLogger(t),
+		bucket,
+		subDirPath,
+		nil,
+		nil,
+		buftarget.TerminateAtControllingWorkspace,
+	)
+	require.NoError(t, err)
+
+	workspace, err := workspaceProvider.GetWorkspaceForBucket(
+		ctx,
+		bucket,
+		bucketTargeting,
+		WithChangedPaths(changedPaths),
+	)
+	if len(expectedTargetOpaqueIDs) == 0 {
+		require.ErrorIs(t, err, ErrNoAffectedModules)
+		return
+	}
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		expectedTargetOpaqueIDs,
+		slicesext.Map(bufmodule.ModuleSetTargetModules(workspace), bufmodule.Module.OpaqueID),
+	)
+	require.Equal(t, "finance/bond/proto", workspace.GetModuleDirPathForOpaqueID("buf.testing/acme/bond"))
+	require.Equal(t, "", workspace.GetModuleDirPathForOpaqueID("buf.testing/acme/date"))
+}
+
 func TestDuplicatePath(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -313,6 +428,329 @@ func TestDuplicatePath(t *testing.T) {
 	requireModuleContainFileNames(t, module, "v1/separate.proto")
 }
 
+func TestVendorDir(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// This represents some external dependencies from the BSR.
+	bsrProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/date",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/date",
+		},
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/extension",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/extension",
+		},
+	)
+	require.NoError(t, err)
+
+	storageosProvider := storageos.NewProvider()
+	osBucket, err := storageosProvider.NewReadWriteBucket("testdata/basic/workspace_unused_dep")
+	require.NoError(t, err)
+	bucket := storagemem.NewReadWriteBucket()
+	_, err = storage.Copy(ctx, osBucket, bucket)
+	require.NoError(t, err)
+	// The vendor directory is within the module directory to verify that vendored files
+	// are not added to the module.
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			bucket,
+			"buf.yaml",
+			[]byte(`version: v2
+modules:
+  - path: finance/bond/proto
+    name: buf.testing/acme/bond
+deps:
+  - buf.testing/acme/date
+  - buf.testing/acme/extension
+vendor: finance/bond/proto/buf_vendor
+`),
+		),
+	)
+
+	workspaceDepManager := newWorkspaceDepManager(slogtestext.NewLogger(t), bucket, ".", true)
+	vendorDirPath, err := workspaceDepManager.VendorDirPath(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "finance/bond/proto/buf_vendor", vendorDirPath)
+	depModuleKeys, err := workspaceDepManager.ExistingBufLockFileDepModuleKeys(ctx)
+	require.NoError(t, err)
+	depModuleDatas, err := bsrProvider.GetModuleDatasForModuleKeys(ctx, depModuleKeys)
+	require.NoError(t, err)
+	require.NoError(t, workspaceDepManager.UpdateVendorDir(ctx, depModuleDatas))
+
+	getWorkspace := func() (Workspace, error) {
+		bucketTargeting, err := buftarget.NewBucketTargeting(
+			ctx,
+			slogtestext.NewLogger(t),
+			bucket,
+			".",
+			nil,
+			nil,
+			buftarget.TerminateAtControllingWorkspace,
+		)
+		require.NoError(t, err)
+		// No providers are available, dependencies can only come from the vendor directory.
+		return NewWorkspaceProvider(
+			slogtestext.NewLogger(t),
+			bufmodule.NopGraphProvider,
+			bufmodule.NopModuleDataProvider,
+			bufmodule.NopCommitProvider,
+			bufgitdep.NopProvider,
+		).GetWorkspaceForBucket(
+			ctx,
+			bucket,
+			bucketTargeting,
+		)
+	}
+	workspace, err := getWorkspace()
+	require.NoError(t, err)
+	module := workspace.GetModuleForOpaqueID("buf.testing/acme/bond")
+	require.NotNil(t, module)
+	requireModuleContainFileNames(t, module, "acme/bond/v2/bond.proto")
+	module = workspace.GetModuleForOpaqueID("buf.testing/acme/date")
+	require.NotNil(t, module)
+	requireModuleContainFileNames(t, module, "acme/date/v1/date.proto")
+
+	// Remove a dependency from the vendor directory.
+	require.NoError(t, workspaceDepManager.UpdateVendorDir(ctx, depModuleDatas[:1]))
+	workspace, err = getWorkspace()
+	require.NoError(t, err)
+	module = workspace.GetModuleForOpaqueID("buf.testing/acme/extension")
+	require.NotNil(t, module)
+	_, err = module.GetFile(ctx, "acme/extension/v1/extension.proto")
+	require.ErrorContains(t, err, "buf dep vendor")
+}
+
+func TestGitDeps(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bucket := storagemem.NewReadWriteBucket()
+	require.NoError(t, storage.PutPath(ctx, bucket, "proto/a.proto", []byte(`syntax = "proto3";`)))
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			bucket,
+			"buf.yaml",
+			[]byte(`version: v2
+modules:
+  - path: proto
+deps:
+  - git: https://github.com/acme/protos.git#ref=v1.2.0,subdir=proto
+`),
+		),
+	)
+	getWorkspace := func() (Workspace, error) {
+		bucketTargeting, err := buftarget.NewBucketTargeting(
+			ctx,
+			slogtestext.NewLogger(t),
+			bucket,
+			".",
+			nil,
+			nil,
+			buftarget.TerminateAtControllingWorkspace,
+		)
+		require.NoError(t, err)
+		return testNewWorkspaceProvider(t).GetWorkspaceForBucket(ctx, bucket, bucketTargeting)
+	}
+	_, err := getWorkspace()
+	require.ErrorContains(t, err, "is not pinned in buf.lock")
+
+	workspaceDepManager := newWorkspaceDepManager(slogtestext.NewLogger(t), bucket, ".", true)
+	gitDepRefs, err := workspaceDepManager.ConfiguredGitDepRefs(ctx)
+	require.NoError(t, err)
+	require.Len(t, gitDepRefs, 1)
+	digest, err := bufcas.NewDigestForContent(strings.NewReader(""))
+	require.NoError(t, err)
+	gitDepKey, err := bufconfig.NewGitDepKey(
+		gitDepRefs[0].URL(),
+		gitDepRefs[0].SubDirPath(),
+		"9c8a2b1e4d3f5a6b7c8d9e0f1a2b3c4d5e6f7a8b",
+		digest,
+	)
+	require.NoError(t, err)
+	require.NoError(t, workspaceDepManager.UpdateBufLockFileGitDepKeys(ctx, []bufconfig.GitDepKey{gitDepKey}))
+	// Updating the module deps keeps the pinned git deps.
+	require.NoError(t, workspaceDepManager.UpdateBufLockFile(ctx, nil))
+	gitDepKeys, err := workspaceDepManager.ExistingBufLockFileGitDepKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, gitDepKeys, 1)
+	require.Equal(t, gitDepKey.Commit(), gitDepKeys[0].Commit())
+	// The git dep is pinned, so we get past validation and attempt to fetch it.
+	_, err = getWorkspace()
+	require.ErrorContains(t, err, "git dependencies are not supported")
+
+	gitDepBucket := storagemem.NewReadWriteBucket()
+	require.NoError(t, storage.PutPath(ctx, gitDepBucket, "acme/protos/v1/protos.proto", []byte(`syntax = "proto3";`)))
+	require.NoError(t, storage.PutPath(ctx, gitDepBucket, "acme/protos/internal/internal.proto", []byte(`syntax = "proto3";`)))
+	getWorkspaceWithGitDep := func() (Workspace, error) {
+		bucketTargeting, err := buftarget.NewBucketTargeting(
+			ctx,
+			slogtestext.NewLogger(t),
+			bucket,
+			".",
+			nil,
+			nil,
+			buftarget.TerminateAtControllingWorkspace,
+		)
+		require.NoError(t, err)
+		bsrProvider, err := bufmoduletesting.NewOmniProvider()
+		require.NoError(t, err)
+		return NewWorkspaceProvider(
+			slogtestext.NewLogger(t),
+			bsrProvider,
+			bsrProvider,
+			bsrProvider,
+			testGitDepProvider{readBucket: gitDepBucket},
+		).GetWorkspaceForBucket(ctx, bucket, bucketTargeting)
+	}
+	// Without a buf.yaml, the module name is derived from the location of the git dep, and
+	// all of its files are in the module.
+	workspace, err := getWorkspaceWithGitDep()
+	require.NoError(t, err)
+	module := workspace.GetModuleForOpaqueID("github.com/acme/protos-proto")
+	require.NotNil(t, module)
+	require.False(t, module.IsLocal())
+	requireModuleContainFileNames(t, module, "acme/protos/internal/internal.proto", "acme/protos/v1/protos.proto")
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			gitDepBucket,
+			"buf.yaml",
+			[]byte(`version: v2
+name: buf.testing/acme/protos
+deps:
+  - buf.testing/acme/date
+`),
+		),
+	)
+	_, err = getWorkspaceWithGitDep()
+	require.ErrorContains(t, err, "depends on buf.testing/acme/date, which must also be declared")
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			gitDepBucket,
+			"buf.yaml",
+			[]byte(`version: v2
+modules:
+  - path: .
+    name: buf.testing/acme/protos
+    excludes:
+      - acme/protos/internal
+`),
+		),
+	)
+	workspace, err = getWorkspaceWithGitDep()
+	require.NoError(t, err)
+	module = workspace.GetModuleForOpaqueID("buf.testing/acme/protos")
+	require.NotNil(t, module)
+	require.False(t, module.IsTarget())
+	// Git deps are not part of the workspace, and are never pushed.
+	require.False(t, module.IsLocal())
+	requireModuleContainFileNames(t, module, "acme/protos/v1/protos.proto")
+}
+
+func TestDepPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// This represents some external dependencies from the BSR.
+	bsrProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/date",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/date",
+		},
+		bufmoduletesting.ModuleData{
+			Name:    "buf.testing/acme/extension",
+			DirPath: "testdata/basic/bsr/buf.testing/acme/extension",
+		},
+	)
+	require.NoError(t, err)
+
+	storageosProvider := storageos.NewProvider()
+	osBucket, err := storageosProvider.NewReadWriteBucket("testdata/basic/workspace_unused_dep")
+	require.NoError(t, err)
+	bucket := storagemem.NewReadWriteBucket()
+	_, err = storage.Copy(ctx, osBucket, bucket)
+	require.NoError(t, err)
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			bucket,
+			"buf.yaml",
+			[]byte(`version: v2
+modules:
+  - path: finance/bond/proto
+    name: buf.testing/acme/bond
+deps:
+  - buf.testing/acme/date:main
+  - buf.testing/acme/extension
+dep_policy:
+  allow:
+    - buf.testing/acme
+  deny:
+    - buf.testing/acme/extension
+  require_label: true
+`),
+		),
+	)
+
+	bucketTargeting, err := buftarget.NewBucketTargeting(
+		ctx,
+		slogtestext.NewLogger(t),
+		bucket,
+		".",
+		nil,
+		nil,
+		buftarget.TerminateAtControllingWorkspace,
+	)
+	require.NoError(t, err)
+	_, err = NewWorkspaceProvider(
+		slogtestext.NewLogger(t),
+		bsrProvider,
+		bsrProvider,
+		bsrProvider,
+		bufgitdep.NopProvider,
+	).GetWorkspaceForBucket(
+		ctx,
+		bucket,
+		bucketTargeting,
+	)
+	var fileAnnotationSet bufanalysis.FileAnnotationSet
+	require.ErrorAs(t, err, &fileAnnotationSet)
+	require.Equal(
+		t,
+		[]string{
+			"DEP_DENIED",
+			"DEP_LABEL_REQUIRED",
+		},
+		slicesext.Map(
+			fileAnnotationSet.FileAnnotations(),
+			func(fileAnnotation bufanalysis.FileAnnotation) string {
+				return fileAnnotation.Type()
+			},
+		),
+	)
+	for _, fileAnnotation := range fileAnnotationSet.FileAnnotations() {
+		require.NotNil(t, fileAnnotation.FileInfo())
+		require.Equal(t, "buf.yaml", fileAnnotation.FileInfo().Path())
+	}
+
+	workspaceDepManager := newWorkspaceDepManager(slogtestext.NewLogger(t), bucket, ".", true)
+	depModuleKeys, err := workspaceDepManager.ExistingBufLockFileDepModuleKeys(ctx)
+	require.NoError(t, err)
+	err = workspaceDepManager.ValidateDepPolicy(ctx, bsrProvider, depModuleKeys)
+	require.ErrorAs(t, err, &fileAnnotationSet)
+	require.Len(t, fileAnnotationSet.FileAnnotations(), 2)
+}
+
 func testNewWorkspaceProvider(t *testing.T, testModuleDatas ...bufmoduletesting.ModuleData) WorkspaceProvider {
 	bsrProvider, err := bufmoduletesting.NewOmniProvider(testModuleDatas...)
 	require.NoError(t, err)
@@ -321,9 +759,34 @@ func testNewWorkspaceProvider(t *testing.T, testModuleDatas ...bufmoduletesting.
 		bsrProvider,
 		bsrProvider,
 		bsrProvider,
+		bufgitdep.NopProvider,
 	)
 }
 
+// testGitDepProvider is a bufgitdep.Provider that returns the same bucket for every git dep.
+type testGitDepProvider struct {
+	readBucket storage.ReadBucket
+}
+
+func (testGitDepProvider) GetGitDepKeysForGitDepRefs(
+	context.Context,
+	[]bufconfig.GitDepRef,
+) ([]bufconfig.GitDepKey, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p testGitDepProvider) GetReadBucketsForGitDepKeys(
+	_ context.Context,
+	gitDepKeys []bufconfig.GitDepKey,
+) ([]storage.ReadBucket, error) {
+	return slicesext.Map(
+		gitDepKeys,
+		func(bufconfig.GitDepKey) storage.ReadBucket {
+			return p.readBucket
+		},
+	), nil
+}
+
 func requireModuleContainFileNames(t *testing.T, module bufmodule.Module, expectedFileNames ...string) {
 	fileNamesToBeSeen := slicesext.ToStructMap(expectedFileNames)
 	require.NoError(t, module.WalkFileInfos(context.Background(), func(fi bufmodule.FileInfo) error {
@@ -33,17 +33,26 @@ import (
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/bufpluginv1"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/bufpluginv1beta1"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/bufpluginv2"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/editions/editionsmigrate"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/fake"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/lsp"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/messagediff"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/plugin/pluginrun"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/price"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/query"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/registry/plugin/plugindelete"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/registry/plugin/pluginpush"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/registry/webhook/webhookcreate"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/registry/webhook/webhookdelete"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/registry/webhook/webhooklist"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/schemaexport"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/stats"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/beta/studioagent"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/breaking"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/build"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/cache/cachels"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/cache/cacheprune"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/cache/cacheverify"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/config/configinit"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/config/configlsbreakingrules"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/config/configlslintrules"
@@ -52,8 +61,12 @@ import (
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/convert"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/curl"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depgraph"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depoutdated"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depprune"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depsbom"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depupdate"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depvendor"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/depwhy"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/export"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/format"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/generate"
@@ -129,13 +142,26 @@ func NewRootCommand(name string) *appcmd.Command {
 			push.NewCommand("push", builder),
 			convert.NewCommand("convert", builder),
 			curl.NewCommand("curl", builder),
+			{
+				Use:   "cache",
+				Short: "Manage the local cache",
+				SubCommands: []*appcmd.Command{
+					cachels.NewCommand("ls", builder),
+					cacheprune.NewCommand("prune", builder),
+					cacheverify.NewCommand("verify", builder),
+				},
+			},
 			{
 				Use:   "dep",
 				Short: "Work with dependencies",
 				SubCommands: []*appcmd.Command{
 					depgraph.NewCommand("graph", builder),
+					depoutdated.NewCommand("outdated", builder),
 					depprune.NewCommand("prune", builder, ``, false),
+					depsbom.NewCommand("sbom", builder),
 					depupdate.NewCommand("update", builder, ``, false),
+					depvendor.NewCommand("vendor", builder),
+					depwhy.NewCommand("why", builder),
 				},
 			},
 			{
@@ -234,13 +260,31 @@ func NewRootCommand(name string) *appcmd.Command {
 				Use:   "beta",
 				Short: "Beta commands. Unstable and likely to change",
 				SubCommands: []*appcmd.Command{
+					fake.NewCommand("fake", builder),
 					lsp.NewCommand("lsp", builder),
+					messagediff.NewCommand("message-diff", builder),
 					price.NewCommand("price", builder),
+					query.NewCommand("query", builder),
+					schemaexport.NewCommand("schema-export", builder),
 					stats.NewCommand("stats", builder),
 					bufpluginv1beta1.NewCommand("buf-plugin-v1beta1", builder),
 					bufpluginv1.NewCommand("buf-plugin-v1", builder),
 					bufpluginv2.NewCommand("buf-plugin-v2", builder),
 					studioagent.NewCommand("studio-agent", builder),
+					{
+						Use:   "editions",
+						Short: "Work with Protobuf Editions",
+						SubCommands: []*appcmd.Command{
+							editionsmigrate.NewCommand("migrate", builder),
+						},
+					},
+					{
+						Use:   "plugin",
+						Short: "Work with protoc plugins",
+						SubCommands: []*appcmd.Command{
+							pluginrun.NewCommand("run", builder),
+						},
+					},
 					{
 						Use:   "registry",
 						Short: "Manage assets on the Buf Schema Registry",
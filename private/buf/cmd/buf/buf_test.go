@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -1558,6 +1559,124 @@ buf.build/foo/bar
 	)
 }
 
+func TestAffectedSince(t *testing.T) {
+	// Cannot be parallel since we chdir.
+	pwd, err := osext.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		r := recover()
+		assert.NoError(t, osext.Chdir(pwd))
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	tempDirPath := t.TempDir()
+	writeFile := func(path string, content string) {
+		path = filepath.Join(tempDirPath, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	runGit := func(args ...string) {
+		output, err := exec.Command("git", append([]string{"-C", tempDirPath}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	writeFile(
+		"buf.yaml",
+		`version: v2
+modules:
+  - path: a
+  - path: b
+  - path: c
+`,
+	)
+	writeFile("a/a/v1/a.proto", "syntax = \"proto3\";\npackage a.v1;\nmessage A {}\n")
+	writeFile("b/b/v1/b.proto", "syntax = \"proto3\";\npackage b.v1;\nimport \"a/v1/a.proto\";\nmessage B { a.v1.A a = 1; }\n")
+	// This has a lint failure, so we can tell when c is linted.
+	writeFile("c/c/v1/c.proto", "syntax = \"proto3\";\npackage c.v1;\nmessage c {}\n")
+	writeFile("README.md", "# README\n")
+	runGit("init")
+	runGit("config", "user.email", "tests@buf.build")
+	runGit("config", "user.name", "Buf go tests")
+	runGit("add", ".")
+	runGit("commit", "-m", "commit 0")
+	runGit("tag", "base")
+	writeFile("a/a/v1/a.proto", "syntax = \"proto3\";\npackage a.v1;\nmessage A { string value = 1; }\n")
+	runGit("commit", "-a", "-m", "commit 1")
+	writeFile("README.md", "# README changed\n")
+
+	require.NoError(t, osext.Chdir(tempDirPath))
+	testRunStdout(
+		t,
+		nil,
+		0,
+		`
+a
+b
+`,
+		"config",
+		"ls-modules",
+		"--affected-since",
+		"base",
+	)
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"config",
+		"ls-modules",
+		"--affected-since",
+		"HEAD",
+	)
+	testRunStdout(
+		t,
+		nil,
+		bufctl.ExitCodeFileAnnotation,
+		filepath.FromSlash(`c/c/v1/c.proto:3:9:Message name "c" should be PascalCase, such as "C".`),
+		"lint",
+	)
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"lint",
+		"--affected-since",
+		"base",
+	)
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"lint",
+		"--affected-since",
+		"HEAD",
+	)
+	writeFile("c/c/v1/c2.proto", "syntax = \"proto3\";\npackage c.v1;\n")
+	testRunStdout(
+		t,
+		nil,
+		bufctl.ExitCodeFileAnnotation,
+		filepath.FromSlash(`c/c/v1/c.proto:3:9:Message name "c" should be PascalCase, such as "C".`),
+		"lint",
+		"--affected-since",
+		"HEAD",
+	)
+	testRunStdoutStderrNoWarn(
+		t,
+		nil,
+		1,
+		``,
+		`Failure: --affected-since is only valid for use with directory inputs`,
+		"lint",
+		"buf.build/foo/bar",
+		"--affected-since",
+		"HEAD",
+	)
+}
+
 func TestLsModulesModuleV1(t *testing.T) {
 	// Cannot be parallel since we chdir.
 	pwd, err := osext.Getwd()
@@ -2838,6 +2957,94 @@ func TestExportProtoFileRefWithPathFlag(t *testing.T) {
 	)
 }
 
+func TestExportImage(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "image.binpb")
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"build",
+		"-o",
+		imagePath,
+		filepath.Join("testdata", "export", "proto"),
+	)
+	outputDir := filepath.Join(tempDir, "output")
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"export",
+		"-o",
+		outputDir,
+		imagePath,
+	)
+	readWriteBucket, err := storageos.NewProvider().NewReadWriteBucket(outputDir)
+	require.NoError(t, err)
+	storagetesting.AssertPaths(
+		t,
+		readWriteBucket,
+		"",
+		"request.proto",
+		"rpc.proto",
+	)
+	data, err := os.ReadFile(filepath.Join(outputDir, "rpc.proto"))
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`syntax = "proto3";
+
+package example;
+
+import "request.proto";
+
+message RPC {
+  request.Request req = 1;
+}
+`,
+		string(data),
+	)
+}
+
+func TestExportImageExcludeImports(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "image.binpb")
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"build",
+		"-o",
+		imagePath,
+		filepath.Join("testdata", "export", "proto"),
+	)
+	outputDir := filepath.Join(tempDir, "output")
+	testRunStdout(
+		t,
+		nil,
+		0,
+		``,
+		"export",
+		"--exclude-imports",
+		"-o",
+		outputDir,
+		imagePath,
+	)
+	readWriteBucket, err := storageos.NewProvider().NewReadWriteBucket(outputDir)
+	require.NoError(t, err)
+	storagetesting.AssertPaths(
+		t,
+		readWriteBucket,
+		"",
+		"rpc.proto",
+	)
+}
+
 func TestBuildWithPaths(t *testing.T) {
 	t.Parallel()
 	testRunStdout(t, nil, 0, ``, "build", filepath.Join("testdata", "paths"), "--path", filepath.Join("testdata", "paths", "a", "v3"), "--exclude-path", filepath.Join("testdata", "paths", "a", "v3", "foo"))
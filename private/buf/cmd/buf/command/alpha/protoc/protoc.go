@@ -227,7 +227,7 @@ func run(
 	if env.Output == "" {
 		return appcmd.NewInvalidArgumentErrorf("required flag %q not set", outputFlagName)
 	}
-	controller, err := bufcli.NewController(container)
+	controller, err := bufcli.NewController(ctx, container)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,250 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editionsmigrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/bufeditions"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	configFlagName          = "config"
+	diffFlagName            = "diff"
+	diffFlagShortName       = "d"
+	disableSymlinksFlagName = "disable-symlinks"
+	editionFlagName         = "edition"
+	errorFormatFlagName     = "error-format"
+	excludePathsFlagName    = "exclude-path"
+	pathsFlagName           = "path"
+)
+
+var editionStringToEdition = map[string]descriptorpb.Edition{
+	"2023": descriptorpb.Edition_EDITION_2023,
+}
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <source>",
+		Short: "Migrate Protobuf files from proto2 and proto3 syntax to editions",
+		Long: `Rewrite the Protobuf files of the source that use proto2 or proto3 syntax to use an edition.
+
+The behavior of the files is preserved exactly: features are added to files, messages, and
+fields where the defaults of the edition differ from their syntax, and optional and required
+labels, groups, and packed options are converted to their equivalent features. The migrated
+files are compiled and their resolved features are compared with the original files before
+they are written. The migrated files are formatted as with buf format.
+
+Files that already use editions are not changed.
+
+By default, the source is the current directory and the files are rewritten in-place.
+
+Examples:
+
+Migrate the files of the current directory to edition 2023:
+
+    $ buf beta editions migrate
+
+Display a diff of the migration without rewriting the files:
+
+    $ buf beta editions migrate proto -d
+`,
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	Config          string
+	Diff            bool
+	DisableSymlinks bool
+	Edition         string
+	ErrorFormat     string
+	ExcludePaths    []string
+	Paths           []string
+	// special
+	InputHashtag string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
+	bufcli.BindPaths(flagSet, &f.Paths, pathsFlagName)
+	bufcli.BindExcludePaths(flagSet, &f.ExcludePaths, excludePathsFlagName)
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.BoolVarP(
+		&f.Diff,
+		diffFlagName,
+		diffFlagShortName,
+		false,
+		"Display diffs instead of rewriting files",
+	)
+	flagSet.StringVar(
+		&f.Edition,
+		editionFlagName,
+		"2023",
+		fmt.Sprintf(
+			"The edition to migrate to. Must be one of %s",
+			stringutil.SliceToString(slicesext.MapKeysToSortedSlice(editionStringToEdition)),
+		),
+	)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Config,
+		configFlagName,
+		"",
+		`The buf.yaml file or data to use for configuration`,
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) (retErr error) {
+	source, err := bufcli.GetInputValue(container, flags.InputHashtag, ".")
+	if err != nil {
+		return err
+	}
+	edition, ok := editionStringToEdition[flags.Edition]
+	if !ok {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", editionFlagName, flags.Edition)
+	}
+	if !flags.Diff {
+		// The files are rewritten at their external paths, which is only possible for
+		// directories and proto files.
+		dirOrProtoFileRef, err := buffetch.NewDirOrProtoFileRefParser(container.Logger()).GetDirOrProtoFileRef(ctx, source)
+		if err != nil {
+			if errors.Is(err, buffetch.ErrModuleFormatDetectedForDirOrProtoFileRef) {
+				return appcmd.NewInvalidArgumentErrorf("invalid input %q: must be a directory or proto file unless --%s is set", source, diffFlagName)
+			}
+			return appcmd.NewInvalidArgumentErrorf("invalid input %q: %v", source, err)
+		}
+		if protoFileRef, ok := dirOrProtoFileRef.(buffetch.ProtoFileRef); ok && protoFileRef.IncludePackageFiles() {
+			return appcmd.NewInvalidArgumentError("cannot specify include_package_files=true with editions migrate")
+		}
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	image, err := controller.GetImage(
+		ctx,
+		source,
+		bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+		bufctl.WithConfigOverride(flags.Config),
+	)
+	if err != nil {
+		return err
+	}
+	workspace, err := controller.GetWorkspace(
+		ctx,
+		source,
+		bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+		bufctl.WithConfigOverride(flags.Config),
+	)
+	if err != nil {
+		return err
+	}
+	originalReadBucket := bufmodule.ModuleReadBucketToStorageReadBucket(
+		bufmodule.ModuleReadBucketWithOnlyTargetFiles(
+			bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFilesForTargetModules(workspace),
+		),
+	)
+	migratedReadBucket, err := bufeditions.MigrateBucket(ctx, image, originalReadBucket, edition)
+	if err != nil {
+		return err
+	}
+	diffBuffer := bytes.NewBuffer(nil)
+	changedPaths, err := storage.DiffWithFilenames(
+		ctx,
+		diffBuffer,
+		originalReadBucket,
+		migratedReadBucket,
+		storage.DiffWithExternalPaths(), // No need to set prefixes as the buckets are from the same location.
+	)
+	if err != nil {
+		return err
+	}
+	if flags.Diff {
+		_, err := io.Copy(container.Stdout(), diffBuffer)
+		return err
+	}
+	changedPathSet := slicesext.ToStructMap(changedPaths)
+	return storage.WalkReadObjects(
+		ctx,
+		migratedReadBucket,
+		"",
+		func(readObject storage.ReadObject) error {
+			if _, ok := changedPathSet[readObject.Path()]; !ok {
+				return nil
+			}
+			// The source was validated to be a directory or proto file above, so the
+			// external paths are paths on the local filesystem.
+			file, err := os.OpenFile(readObject.ExternalPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				retErr = errors.Join(retErr, file.Close())
+			}()
+			_, err = file.ReadFrom(readObject)
+			return err
+		},
+	)
+}
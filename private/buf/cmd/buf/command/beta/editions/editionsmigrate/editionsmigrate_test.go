@@ -0,0 +1,63 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package editionsmigrate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd/appcmdtesting"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDiff(t *testing.T) {
+	t.Parallel()
+	stdout := bytes.NewBuffer(nil)
+	appcmdtesting.RunCommandSuccess(
+		t,
+		testNewCommand,
+		nil,
+		nil,
+		stdout,
+		"testdata",
+		"--diff",
+	)
+	require.Contains(t, stdout.String(), `-syntax = "proto3";`)
+	require.Contains(t, stdout.String(), `+edition = "2023";`)
+	require.Contains(t, stdout.String(), `+option features.field_presence = IMPLICIT;`)
+	require.Contains(t, stdout.String(), `+  string nickname = 2 [features.field_presence = EXPLICIT];`)
+}
+
+func TestMigrateInvalidEdition(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		testNewCommand,
+		1,
+		[]string{"invalid value for --edition: 2024"},
+		nil,
+		nil,
+		"testdata",
+		"--diff",
+		"--edition",
+		"2024",
+	)
+}
+
+func testNewCommand(use string) *appcmd.Command {
+	return NewCommand("migrate", appext.NewBuilder("migrate"))
+}
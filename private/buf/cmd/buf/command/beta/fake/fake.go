@@ -0,0 +1,244 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufconvert"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/buffake"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufreflect"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/spf13/pflag"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	disableSymlinksFlagName = "disable-symlinks"
+	typeFlagName            = "type"
+	countFlagName           = "count"
+	seedFlagName            = "seed"
+	toFlagName              = "to"
+)
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <input>",
+		Short: "Generate random messages that satisfy their protovalidate rules",
+		Long: `Generate random messages of a type of the input, for example for fixtures and load tests.
+
+The generated messages satisfy the protovalidate rules of their fields where possible, such
+as string lengths and patterns, numeric ranges, in and not_in, required fields, and the
+number of items of repeated fields. Each message is validated with protovalidate, as with
+buf convert --validate, and messages that fail validation, for example because of CEL
+expressions, are generated again.
+
+Messages are generated deterministically from --seed, so the same seed generates the same
+messages for the same schema.
+
+Multiple messages are written as a stream of messages, either with the jsonl format, where
+each message is on its own line, or with the binpb-delimited format, where each message is
+prefixed by its length as a varint. By default, messages are written to stdout with the
+jsonl format.
+
+Examples:
+
+    $ buf beta fake --type=foo.v1.Order --count=100 --seed=42 --to=out.jsonl
+
+Write a single message as JSON:
+
+    $ buf beta fake --type=foo.v1.Order --to=order.json
+` + bufcli.GetInputLong(`the source, module, or image that contains the type of the messages`),
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	DisableSymlinks bool
+	Type            string
+	Count           int
+	Seed            int64
+	To              string
+	// special
+	InputHashtag string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Type,
+		typeFlagName,
+		"",
+		`The full type name of the messages within the input (e.g. acme.weather.v1.Units)`,
+	)
+	_ = appcmd.MarkFlagRequired(flagSet, typeFlagName)
+	flagSet.IntVar(
+		&f.Count,
+		countFlagName,
+		1,
+		"The number of messages to generate",
+	)
+	flagSet.Int64Var(
+		&f.Seed,
+		seedFlagName,
+		0,
+		"The seed of the random messages. The same seed generates the same messages",
+	)
+	flagSet.StringVar(
+		&f.To,
+		toFlagName,
+		"-",
+		fmt.Sprintf(
+			`The output location of the messages. Supported formats are %s for streams of messages, and %s if --%s is 1`,
+			buffetch.MessageStreamFormatsString,
+			buffetch.MessageFormatsString,
+			countFlagName,
+		),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) (retErr error) {
+	input, err := bufcli.GetInputValue(container, flags.InputHashtag, ".")
+	if err != nil {
+		return err
+	}
+	if flags.Count < 1 {
+		return appcmd.NewInvalidArgumentErrorf("--%s must be at least 1", countFlagName)
+	}
+	toMessageRef, err := buffetch.NewMessageRefParser(
+		container.Logger(),
+		buffetch.MessageRefParserWithDefaultMessageEncoding(buffetch.MessageEncodingJSONL),
+		buffetch.MessageRefParserWithMessageStreams(),
+	).GetMessageRef(ctx, flags.To)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", toFlagName, err)
+	}
+	toMessageEncoding := toMessageRef.MessageEncoding()
+	isMessageStream := toMessageEncoding == buffetch.MessageEncodingBinpbDelimited ||
+		toMessageEncoding == buffetch.MessageEncodingJSONL
+	if !isMessageStream && flags.Count > 1 {
+		return appcmd.NewInvalidArgumentErrorf(
+			"--%s must be one of format %s when --%s is greater than 1",
+			toFlagName,
+			buffetch.MessageStreamFormatsString,
+			countFlagName,
+		)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	schemaImage, err := controller.GetImage(ctx, input)
+	if err != nil {
+		return err
+	}
+	// We can't correctly write anything that uses message-set wire format.
+	schemaImage = bufconvert.ImageWithoutMessageSetWireFormatResolution(schemaImage)
+	message, err := bufreflect.NewMessage(ctx, schemaImage, flags.Type)
+	if err != nil {
+		return err
+	}
+	messageType := message.ProtoReflect().Type()
+	// The same validator as for buf convert --validate.
+	validator, err := protovalidate.New()
+	if err != nil {
+		return err
+	}
+	generator := buffake.NewGenerator(flags.Seed, buffake.GeneratorWithValidator(validator))
+	if !isMessageStream {
+		message, err := generator.Generate(messageType)
+		if err != nil {
+			return err
+		}
+		if err := controller.PutMessage(
+			ctx,
+			schemaImage,
+			flags.To,
+			message,
+			toMessageEncoding,
+		); err != nil {
+			return fmt.Errorf("--%s: %w", toFlagName, err)
+		}
+		return nil
+	}
+	messageWriter, err := controller.GetMessageWriter(
+		ctx,
+		schemaImage,
+		flags.To,
+		toMessageEncoding,
+	)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", toFlagName, err)
+	}
+	defer func() {
+		if err := messageWriter.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("--%s: %w", toFlagName, err))
+		}
+	}()
+	for i := 0; i < flags.Count; i++ {
+		message, err := generator.Generate(messageType)
+		if err != nil {
+			return err
+		}
+		if err := messageWriter.Write(message); err != nil {
+			return fmt.Errorf("--%s: %w", toFlagName, err)
+		}
+	}
+	return nil
+}
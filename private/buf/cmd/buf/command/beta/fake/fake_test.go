@@ -0,0 +1,90 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd/appcmdtesting"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+	stdout := bytes.NewBuffer(nil)
+	appcmdtesting.RunCommandSuccess(
+		t,
+		testNewCommand,
+		nil,
+		nil,
+		stdout,
+		"testdata/order.proto",
+		"--type",
+		"acme.v1.Order",
+		"--count",
+		"3",
+		"--seed",
+		"42",
+	)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+	// The same seed generates the same messages.
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		stdout.String(),
+		nil,
+		nil,
+		"testdata/order.proto",
+		"--type",
+		"acme.v1.Order",
+		"--count",
+		"3",
+		"--seed",
+		"42",
+	)
+}
+
+func TestFakeCountSingleMessage(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		testNewCommand,
+		1,
+		[]string{"--to must be one of format [binpb-delimited,jsonl] when --count is greater than 1"},
+		nil,
+		nil,
+		"testdata/order.proto",
+		"--type",
+		"acme.v1.Order",
+		"--count",
+		"2",
+		"--to",
+		"-#format=json",
+	)
+}
+
+func testNewCommand(use string) *appcmd.Command {
+	return NewCommand("fake", appext.NewBuilder("fake"))
+}
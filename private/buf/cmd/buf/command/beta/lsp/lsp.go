@@ -97,7 +97,7 @@ func run(
 		return err
 	}
 
-	controller, err := bufcli.NewController(container)
+	controller, err := bufcli.NewController(ctx, container)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,405 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messagediff
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	differenceKindAdded   = "added"
+	differenceKindRemoved = "removed"
+	differenceKindChanged = "changed"
+)
+
+// difference is a difference between two messages.
+type difference struct {
+	// Path is the path of the value, such as items[0].price.
+	Path string `json:"path"`
+	// Kind is one of added, removed, or changed.
+	Kind string `json:"kind"`
+	// Old is the value in the first message, unless added.
+	Old json.RawMessage `json:"old,omitempty"`
+	// New is the value in the second message, unless removed.
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// String returns the difference as a line of text.
+func (d *difference) String() string {
+	switch d.Kind {
+	case differenceKindAdded:
+		return fmt.Sprintf("+ %s: %s", d.Path, d.New)
+	case differenceKindRemoved:
+		return fmt.Sprintf("- %s: %s", d.Path, d.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", d.Path, d.Old, d.New)
+	}
+}
+
+// diffMessages returns the differences between the two messages, which must be of the
+// same type.
+//
+// The elements of the repeated fields in keyFieldNames are matched by the value of
+// their key field instead of by index.
+func diffMessages(
+	resolver protoencoding.Resolver,
+	keyFieldNames map[protoreflect.FullName]protoreflect.Name,
+	one protoreflect.Message,
+	two protoreflect.Message,
+) ([]*difference, error) {
+	differ := &differ{
+		marshaler:     protoencoding.NewJSONMarshaler(resolver),
+		keyFieldNames: keyFieldNames,
+	}
+	if err := differ.diffMessage("", one, two); err != nil {
+		return nil, err
+	}
+	return differ.differences, nil
+}
+
+type differ struct {
+	marshaler     protoencoding.Marshaler
+	keyFieldNames map[protoreflect.FullName]protoreflect.Name
+	differences   []*difference
+}
+
+func (d *differ) diffMessage(path string, one protoreflect.Message, two protoreflect.Message) error {
+	// The fields that are set in either message, ordered by number.
+	fieldDescriptors := make(map[protoreflect.FieldNumber]protoreflect.FieldDescriptor)
+	addFieldDescriptor := func(fieldDescriptor protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fieldDescriptors[fieldDescriptor.Number()] = fieldDescriptor
+		return true
+	}
+	one.Range(addFieldDescriptor)
+	two.Range(addFieldDescriptor)
+	fieldNumbers := make([]protoreflect.FieldNumber, 0, len(fieldDescriptors))
+	for fieldNumber := range fieldDescriptors {
+		fieldNumbers = append(fieldNumbers, fieldNumber)
+	}
+	sort.Slice(fieldNumbers, func(i int, j int) bool { return fieldNumbers[i] < fieldNumbers[j] })
+	for _, fieldNumber := range fieldNumbers {
+		fieldDescriptor := fieldDescriptors[fieldNumber]
+		fieldPath := getFieldPath(path, fieldDescriptor)
+		switch {
+		case fieldDescriptor.IsList():
+			if err := d.diffList(fieldPath, fieldDescriptor, one.Get(fieldDescriptor).List(), two.Get(fieldDescriptor).List()); err != nil {
+				return err
+			}
+		case fieldDescriptor.IsMap():
+			if err := d.diffMap(fieldPath, fieldDescriptor, one.Get(fieldDescriptor).Map(), two.Get(fieldDescriptor).Map()); err != nil {
+				return err
+			}
+		case !one.Has(fieldDescriptor):
+			if fieldDescriptor.HasPresence() {
+				if err := d.addAdded(fieldPath, fieldDescriptor, two.Get(fieldDescriptor)); err != nil {
+					return err
+				}
+				continue
+			}
+			// Fields without presence are compared with their default value.
+			if err := d.diffValue(fieldPath, fieldDescriptor, one.Get(fieldDescriptor), two.Get(fieldDescriptor)); err != nil {
+				return err
+			}
+		case !two.Has(fieldDescriptor):
+			if fieldDescriptor.HasPresence() {
+				if err := d.addRemoved(fieldPath, fieldDescriptor, one.Get(fieldDescriptor)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.diffValue(fieldPath, fieldDescriptor, one.Get(fieldDescriptor), two.Get(fieldDescriptor)); err != nil {
+				return err
+			}
+		default:
+			if err := d.diffValue(fieldPath, fieldDescriptor, one.Get(fieldDescriptor), two.Get(fieldDescriptor)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffValue diffs two values of the field, which are either singular values, or elements
+// of a list or values of a map.
+func (d *differ) diffValue(
+	path string,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	one protoreflect.Value,
+	two protoreflect.Value,
+) error {
+	if fieldDescriptor.Message() != nil {
+		return d.diffMessage(path, one.Message(), two.Message())
+	}
+	if one.Equal(two) {
+		return nil
+	}
+	oneJSON, err := d.getValueJSON(fieldDescriptor, one)
+	if err != nil {
+		return err
+	}
+	twoJSON, err := d.getValueJSON(fieldDescriptor, two)
+	if err != nil {
+		return err
+	}
+	d.differences = append(
+		d.differences,
+		&difference{
+			Path: path,
+			Kind: differenceKindChanged,
+			Old:  oneJSON,
+			New:  twoJSON,
+		},
+	)
+	return nil
+}
+
+func (d *differ) diffList(
+	path string,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	one protoreflect.List,
+	two protoreflect.List,
+) error {
+	if keyFieldName, ok := d.keyFieldNames[fieldDescriptor.FullName()]; ok {
+		return d.diffListByKey(path, fieldDescriptor, fieldDescriptor.Message().Fields().ByName(keyFieldName), one, two)
+	}
+	for i := 0; i < one.Len() || i < two.Len(); i++ {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+		var err error
+		switch {
+		case i >= one.Len():
+			err = d.addAdded(elementPath, fieldDescriptor, two.Get(i))
+		case i >= two.Len():
+			err = d.addRemoved(elementPath, fieldDescriptor, one.Get(i))
+		default:
+			err = d.diffValue(elementPath, fieldDescriptor, one.Get(i), two.Get(i))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) diffListByKey(
+	path string,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	keyFieldDescriptor protoreflect.FieldDescriptor,
+	one protoreflect.List,
+	two protoreflect.List,
+) error {
+	oneKeys, oneKeyToValue, err := d.getKeysAndValues(path, keyFieldDescriptor, one)
+	if err != nil {
+		return err
+	}
+	twoKeys, twoKeyToValue, err := d.getKeysAndValues(path, keyFieldDescriptor, two)
+	if err != nil {
+		return err
+	}
+	for _, key := range oneKeys {
+		elementPath := fmt.Sprintf("%s[%s=%s]", path, keyFieldDescriptor.Name(), key)
+		if twoValue, ok := twoKeyToValue[key]; ok {
+			err = d.diffValue(elementPath, fieldDescriptor, oneKeyToValue[key], twoValue)
+		} else {
+			err = d.addRemoved(elementPath, fieldDescriptor, oneKeyToValue[key])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, key := range twoKeys {
+		if _, ok := oneKeyToValue[key]; ok {
+			continue
+		}
+		elementPath := fmt.Sprintf("%s[%s=%s]", path, keyFieldDescriptor.Name(), key)
+		if err := d.addAdded(elementPath, fieldDescriptor, twoKeyToValue[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getKeysAndValues returns the keys of the elements of the list in order, and the
+// element for each key.
+func (d *differ) getKeysAndValues(
+	path string,
+	keyFieldDescriptor protoreflect.FieldDescriptor,
+	list protoreflect.List,
+) ([]string, map[string]protoreflect.Value, error) {
+	keys := make([]string, 0, list.Len())
+	keyToValue := make(map[string]protoreflect.Value, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		value := list.Get(i)
+		keyJSON, err := d.getValueJSON(keyFieldDescriptor, value.Message().Get(keyFieldDescriptor))
+		if err != nil {
+			return nil, nil, err
+		}
+		key := string(keyJSON)
+		if _, ok := keyToValue[key]; ok {
+			return nil, nil, fmt.Errorf("%s: duplicate value %s for key field %q", path, key, keyFieldDescriptor.Name())
+		}
+		keys = append(keys, key)
+		keyToValue[key] = value
+	}
+	return keys, keyToValue, nil
+}
+
+func (d *differ) diffMap(
+	path string,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	one protoreflect.Map,
+	two protoreflect.Map,
+) error {
+	keyFieldDescriptor := fieldDescriptor.MapKey()
+	valueFieldDescriptor := fieldDescriptor.MapValue()
+	keyToMapKey := make(map[string]protoreflect.MapKey)
+	addMapKey := func(mapKey protoreflect.MapKey, _ protoreflect.Value) bool {
+		keyToMapKey[getMapKeyString(keyFieldDescriptor, mapKey)] = mapKey
+		return true
+	}
+	one.Range(addMapKey)
+	two.Range(addMapKey)
+	keys := make([]string, 0, len(keyToMapKey))
+	for key := range keyToMapKey {
+		keys = append(keys, key)
+	}
+	sortMapKeys(keys, keyToMapKey)
+	for _, key := range keys {
+		mapKey := keyToMapKey[key]
+		entryPath := fmt.Sprintf("%s[%s]", path, key)
+		var err error
+		switch {
+		case !one.Has(mapKey):
+			err = d.addAdded(entryPath, valueFieldDescriptor, two.Get(mapKey))
+		case !two.Has(mapKey):
+			err = d.addRemoved(entryPath, valueFieldDescriptor, one.Get(mapKey))
+		default:
+			err = d.diffValue(entryPath, valueFieldDescriptor, one.Get(mapKey), two.Get(mapKey))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) addAdded(path string, fieldDescriptor protoreflect.FieldDescriptor, value protoreflect.Value) error {
+	valueJSON, err := d.getValueJSON(fieldDescriptor, value)
+	if err != nil {
+		return err
+	}
+	d.differences = append(
+		d.differences,
+		&difference{
+			Path: path,
+			Kind: differenceKindAdded,
+			New:  valueJSON,
+		},
+	)
+	return nil
+}
+
+func (d *differ) addRemoved(path string, fieldDescriptor protoreflect.FieldDescriptor, value protoreflect.Value) error {
+	valueJSON, err := d.getValueJSON(fieldDescriptor, value)
+	if err != nil {
+		return err
+	}
+	d.differences = append(
+		d.differences,
+		&difference{
+			Path: path,
+			Kind: differenceKindRemoved,
+			Old:  valueJSON,
+		},
+	)
+	return nil
+}
+
+// getValueJSON returns the JSON of a singular value of the field.
+//
+// Messages use the JSON mapping of Protobuf. Unlike the JSON mapping, 64-bit integers
+// are numbers, as the values are only printed.
+func (d *differ) getValueJSON(fieldDescriptor protoreflect.FieldDescriptor, value protoreflect.Value) (json.RawMessage, error) {
+	switch fieldDescriptor.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return d.marshaler.Marshal(value.Message().Interface())
+	case protoreflect.EnumKind:
+		if enumValueDescriptor := fieldDescriptor.Enum().Values().ByNumber(value.Enum()); enumValueDescriptor != nil {
+			return json.Marshal(string(enumValueDescriptor.Name()))
+		}
+		return json.Marshal(int32(value.Enum()))
+	case protoreflect.BytesKind:
+		return json.Marshal(base64.StdEncoding.EncodeToString(value.Bytes()))
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		float := value.Float()
+		if math.IsNaN(float) || math.IsInf(float, 0) {
+			return json.Marshal(strconv.FormatFloat(float, 'g', -1, 64))
+		}
+		return json.Marshal(float)
+	default:
+		return json.Marshal(value.Interface())
+	}
+}
+
+// getFieldPath returns the path of the field in the message at the path.
+func getFieldPath(path string, fieldDescriptor protoreflect.FieldDescriptor) string {
+	name := string(fieldDescriptor.Name())
+	if fieldDescriptor.IsExtension() {
+		name = "[" + string(fieldDescriptor.FullName()) + "]"
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// getMapKeyString returns the map key as it is printed in paths.
+func getMapKeyString(keyFieldDescriptor protoreflect.FieldDescriptor, mapKey protoreflect.MapKey) string {
+	if keyFieldDescriptor.Kind() == protoreflect.StringKind {
+		return strconv.Quote(mapKey.String())
+	}
+	return mapKey.String()
+}
+
+// sortMapKeys sorts the map keys by value.
+func sortMapKeys(keys []string, keyToMapKey map[string]protoreflect.MapKey) {
+	sort.Slice(
+		keys,
+		func(i int, j int) bool {
+			one, two := keyToMapKey[keys[i]].Interface(), keyToMapKey[keys[j]].Interface()
+			switch one := one.(type) {
+			case int32:
+				return one < two.(int32)
+			case int64:
+				return one < two.(int64)
+			case uint32:
+				return one < two.(uint32)
+			case uint64:
+				return one < two.(uint64)
+			case bool:
+				return !one && two.(bool)
+			default:
+				return keys[i] < keys[j]
+			}
+		},
+	)
+}
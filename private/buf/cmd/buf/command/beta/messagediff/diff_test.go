@@ -0,0 +1,118 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messagediff
+
+import (
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagetesting"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestDiffMessages(t *testing.T) {
+	t.Parallel()
+	image := bufimagetesting.BuildImage(
+		t,
+		[]bufmoduletesting.ModuleData{{DirPath: "testdata"}},
+		bufimage.WithExcludeSourceCodeInfo(),
+	)
+	resolver, err := protoencoding.NewResolver(bufimage.ImageToFileDescriptorProtos(image)...)
+	require.NoError(t, err)
+	messageType, err := resolver.FindMessageByName("foo.v1.Order")
+	require.NoError(t, err)
+	one := testNewOrder(messageType, "1", map[string]string{"env": "prod", "team": "a"}, "a", 1, "b", 2)
+	two := testNewOrder(messageType, "", map[string]string{"env": "dev", "zone": "x"}, "b", 3, "c", 1)
+
+	differences, err := diffMessages(resolver, nil, one, two)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{
+			`~ id: "1" -> ""`,
+			`~ items[0].sku: "a" -> "b"`,
+			`~ items[0].quantity: 1 -> 3`,
+			`~ items[1].sku: "b" -> "c"`,
+			`~ items[1].quantity: 2 -> 1`,
+			`~ labels["env"]: "prod" -> "dev"`,
+			`- labels["team"]: "a"`,
+			`+ labels["zone"]: "x"`,
+		},
+		testDifferenceStrings(differences),
+	)
+
+	keyFieldNames := map[protoreflect.FullName]protoreflect.Name{"foo.v1.Order.items": "sku"}
+	differences, err = diffMessages(resolver, keyFieldNames, one, two)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{
+			`~ id: "1" -> ""`,
+			`- items[sku="a"]: {"sku":"a","quantity":"1"}`,
+			`~ items[sku="b"].quantity: 2 -> 3`,
+			`+ items[sku="c"]: {"sku":"c","quantity":"1"}`,
+			`~ labels["env"]: "prod" -> "dev"`,
+			`- labels["team"]: "a"`,
+			`+ labels["zone"]: "x"`,
+		},
+		testDifferenceStrings(differences),
+	)
+
+	differences, err = diffMessages(resolver, keyFieldNames, one, one)
+	require.NoError(t, err)
+	require.Empty(t, differences)
+
+	duplicate := testNewOrder(messageType, "1", nil, "a", 1, "a", 2)
+	_, err = diffMessages(resolver, keyFieldNames, one, duplicate)
+	require.ErrorContains(t, err, `items: duplicate value "a" for key field "sku"`)
+}
+
+// testNewOrder returns a new foo.v1.Order with items given as pairs of sku and quantity.
+func testNewOrder(
+	messageType protoreflect.MessageType,
+	id string,
+	labels map[string]string,
+	skusAndQuantities ...any,
+) protoreflect.Message {
+	order := messageType.New()
+	fields := order.Descriptor().Fields()
+	if id != "" {
+		order.Set(fields.ByName("id"), protoreflect.ValueOfString(id))
+	}
+	items := order.Mutable(fields.ByName("items")).List()
+	for i := 0; i < len(skusAndQuantities); i += 2 {
+		item := items.NewElement().Message()
+		itemFields := item.Descriptor().Fields()
+		item.Set(itemFields.ByName("sku"), protoreflect.ValueOfString(skusAndQuantities[i].(string)))
+		item.Set(itemFields.ByName("quantity"), protoreflect.ValueOfInt64(int64(skusAndQuantities[i+1].(int))))
+		items.Append(protoreflect.ValueOfMessage(item))
+	}
+	labelsMap := order.Mutable(fields.ByName("labels")).Map()
+	for key, value := range labels {
+		labelsMap.Set(protoreflect.ValueOfString(key).MapKey(), protoreflect.ValueOfString(value))
+	}
+	return order
+}
+
+func testDifferenceStrings(differences []*difference) []string {
+	var differenceStrings []string
+	for _, difference := range differences {
+		differenceStrings = append(differenceStrings, difference.String())
+	}
+	return differenceStrings
+}
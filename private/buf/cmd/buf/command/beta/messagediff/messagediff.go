@@ -0,0 +1,267 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messagediff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufconvert"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	disableSymlinksFlagName = "disable-symlinks"
+	schemaFlagName          = "schema"
+	typeFlagName            = "type"
+	keyFlagName             = "key"
+	formatFlagName          = "format"
+	exitCodeFlagName        = "exit-code"
+
+	textFormatString = "text"
+	jsonFormatString = "json"
+)
+
+var allFormatStrings = []string{
+	textFormatString,
+	jsonFormatString,
+}
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <message> <message>",
+		Short: "Print the differences between two messages",
+		Long: fmt.Sprintf(
+			`Print the differences between two messages of the same type, field by field.
+
+The two arguments are the locations of the messages, which can be of any message format %s.
+The type of the messages is given with --%s, and is resolved in the input given with --%s.
+
+Each difference is printed with the path of the field, such as items[0].price or
+labels["env"]: values that are only set in the second message are added, values that are
+only set in the first message are removed, and values that are set in both messages but
+differ are changed.
+
+Repeated fields are matched by index, unless a key field is given with --%s, in which case
+the elements are matched by the value of their key field. Maps are matched by key.
+
+Examples:
+
+    $ buf beta message-diff --type=foo.v1.Order a.binpb b.json
+
+Match the elements of foo.v1.Order.items by their sku field:
+
+    $ buf beta message-diff --type=foo.v1.Order --key=foo.v1.Order.items=sku a.binpb b.json
+`,
+			buffetch.MessageFormatsString,
+			typeFlagName,
+			schemaFlagName,
+			keyFlagName,
+		),
+		Args: appcmd.ExactArgs(2),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	DisableSymlinks bool
+	Schema          string
+	Type            string
+	Keys            []string
+	Format          string
+	ExitCode        bool
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Schema,
+		schemaFlagName,
+		".",
+		fmt.Sprintf(
+			`The input that contains the type of the messages, which must be one of format %s`,
+			buffetch.AllFormatsString,
+		),
+	)
+	flagSet.StringVar(
+		&f.Type,
+		typeFlagName,
+		"",
+		`The full type name of the messages (e.g. acme.weather.v1.Units)`,
+	)
+	_ = appcmd.MarkFlagRequired(flagSet, typeFlagName)
+	flagSet.StringSliceVar(
+		&f.Keys,
+		keyFlagName,
+		nil,
+		`The key field to match the elements of a repeated message field by, as <field>=<key>, where <field> is the full name of the repeated field and <key> is the name of a singular scalar field of its message (e.g. foo.v1.Order.items=sku). May be provided multiple times`,
+	)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		textFormatString,
+		fmt.Sprintf(
+			"The format to print the differences in. Must be one of %s",
+			stringutil.SliceToString(allFormatStrings),
+		),
+	)
+	flagSet.BoolVar(
+		&f.ExitCode,
+		exitCodeFlagName,
+		false,
+		"Exit with a non-zero exit code if the messages differ",
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) (retErr error) {
+	if !slices.Contains(allFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	schemaImage, err := controller.GetImage(ctx, flags.Schema)
+	if err != nil {
+		return err
+	}
+	// We can't correctly read anything that uses message-set wire format.
+	schemaImage = bufconvert.ImageWithoutMessageSetWireFormatResolution(schemaImage)
+	resolver := schemaImage.Resolver()
+	keyFieldNames, err := getKeyFieldNames(resolver, flags.Keys)
+	if err != nil {
+		return err
+	}
+	var messages []protoreflect.Message
+	for i := 0; i < 2; i++ {
+		message, _, err := controller.GetMessage(
+			ctx,
+			schemaImage,
+			container.Arg(i),
+			flags.Type,
+			buffetch.MessageEncodingBinpb,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", container.Arg(i), err)
+		}
+		messages = append(messages, message.ProtoReflect())
+	}
+	differences, err := diffMessages(resolver, keyFieldNames, messages[0], messages[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr == nil && flags.ExitCode && len(differences) > 0 {
+			retErr = bufctl.ErrFileAnnotation
+		}
+	}()
+	for _, difference := range differences {
+		var line string
+		switch flags.Format {
+		case textFormatString:
+			line = difference.String()
+		case jsonFormatString:
+			data, err := json.Marshal(difference)
+			if err != nil {
+				return err
+			}
+			line = string(data)
+		default:
+			return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+		}
+		if _, err := fmt.Fprintln(container.Stdout(), line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getKeyFieldNames parses the values of --key into a map from the full name of each
+// repeated field to the name of its key field.
+func getKeyFieldNames(
+	resolver protoencoding.Resolver,
+	keys []string,
+) (map[protoreflect.FullName]protoreflect.Name, error) {
+	keyFieldNames := make(map[protoreflect.FullName]protoreflect.Name, len(keys))
+	for _, key := range keys {
+		fieldName, keyFieldName, ok := strings.Cut(key, "=")
+		if !ok {
+			return nil, appcmd.NewInvalidArgumentErrorf("--%s: %q must be of the form <field>=<key>", keyFlagName, key)
+		}
+		descriptor, err := resolver.FindDescriptorByName(protoreflect.FullName(fieldName))
+		if err != nil {
+			return nil, appcmd.NewInvalidArgumentErrorf("--%s: field %q not found", keyFlagName, fieldName)
+		}
+		fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor)
+		if !ok || !fieldDescriptor.IsList() || fieldDescriptor.Message() == nil {
+			return nil, appcmd.NewInvalidArgumentErrorf("--%s: %q is not a repeated message field", keyFlagName, fieldName)
+		}
+		keyFieldDescriptor := fieldDescriptor.Message().Fields().ByName(protoreflect.Name(keyFieldName))
+		if keyFieldDescriptor == nil {
+			return nil, appcmd.NewInvalidArgumentErrorf("--%s: field %q not found in %q", keyFlagName, keyFieldName, fieldDescriptor.Message().FullName())
+		}
+		if keyFieldDescriptor.IsList() || keyFieldDescriptor.IsMap() || keyFieldDescriptor.Message() != nil {
+			return nil, appcmd.NewInvalidArgumentErrorf("--%s: key field %q of %q is not a singular scalar field", keyFlagName, keyFieldName, fieldName)
+		}
+		keyFieldNames[fieldDescriptor.FullName()] = keyFieldDescriptor.Name()
+	}
+	return keyFieldNames, nil
+}
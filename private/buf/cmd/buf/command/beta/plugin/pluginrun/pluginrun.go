@@ -0,0 +1,261 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufprotopluginexec"
+	"github.com/bufbuild/buf/private/bufpkg/bufprotoplugin"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/bufbuild/buf/private/pkg/wasm"
+	"github.com/bufbuild/protoplugin"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const (
+	requestFlagName    = "request"
+	formatFlagName     = "format"
+	protocPathFlagName = "protoc-path"
+
+	formatText  = "text"
+	formatJSON  = "json"
+	formatBinpb = "binpb"
+)
+
+var allFormats = []string{
+	formatText,
+	formatJSON,
+	formatBinpb,
+}
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <plugin>",
+		Short: "Run a plugin with a captured CodeGeneratorRequest",
+		Long: `Run a plugin with a captured CodeGeneratorRequest and print the response.
+
+The plugin is either the name of a plugin, such as "go" for "protoc-gen-go" on your $PATH
+or a plugin built in to protoc, the path to a plugin binary, or the path to a Wasm plugin
+ending in ".wasm".
+
+The request is read from the file given by --request, which is in the binary format unless
+it ends in ".json". Use "-" to read the request from stdin. Requests can be captured with
+"buf generate --dump-requests".
+
+If the plugin returns an error, it is printed and the command fails.`,
+		Args: appcmd.ExactArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	Request    string
+	Format     string
+	ProtocPath []string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&f.Request,
+		requestFlagName,
+		"",
+		`The path to the CodeGeneratorRequest to run the plugin with, or "-" for stdin`,
+	)
+	_ = appcmd.MarkFlagRequired(flagSet, requestFlagName)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		formatText,
+		fmt.Sprintf(
+			"The format to print the CodeGeneratorResponse in. Must be one of %s",
+			stringutil.SliceToString(allFormats),
+		),
+	)
+	flagSet.StringSliceVar(
+		&f.ProtocPath,
+		protocPathFlagName,
+		nil,
+		"The path to protoc, used for plugins built in to protoc",
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) (retErr error) {
+	bufcli.WarnBetaCommand(ctx, container)
+	switch flags.Format {
+	case formatText, formatJSON, formatBinpb:
+	default:
+		return appcmd.NewInvalidArgumentErrorf(
+			"--%s must be one of %s, got %q",
+			formatFlagName,
+			stringutil.SliceToString(allFormats),
+			flags.Format,
+		)
+	}
+	request, err := readRequest(container, flags.Request)
+	if err != nil {
+		return err
+	}
+	pluginName := container.Arg(0)
+	var handler protoplugin.Handler
+	if filepath.Ext(pluginName) == ".wasm" {
+		moduleWasm, err := os.ReadFile(pluginName)
+		if err != nil {
+			return err
+		}
+		wasmRuntimeCacheDir, err := bufcli.CreateWasmRuntimeCacheDir(container)
+		if err != nil {
+			return err
+		}
+		wasmRuntime, err := wasm.NewRuntime(ctx, wasm.WithLocalCacheDir(wasmRuntimeCacheDir))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			retErr = errors.Join(retErr, wasmRuntime.Close(ctx))
+		}()
+		compiledModule, err := wasmRuntime.Compile(ctx, filepath.Base(pluginName), moduleWasm)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			retErr = errors.Join(retErr, compiledModule.Close(ctx))
+		}()
+		handler = bufprotopluginexec.NewWasmHandler(container.Logger(), pluginName, compiledModule)
+	} else {
+		handlerOptions := []bufprotopluginexec.HandlerOption{
+			bufprotopluginexec.HandlerWithProtocPath(flags.ProtocPath...),
+		}
+		if isPluginPath(pluginName) {
+			handlerOptions = append(handlerOptions, bufprotopluginexec.HandlerWithPluginPath(pluginName))
+		}
+		handler, err = bufprotopluginexec.NewHandler(
+			container.Logger(),
+			storageos.NewProvider(),
+			pluginName,
+			handlerOptions...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	response, err := bufprotoplugin.NewGenerator(
+		container.Logger(),
+		handler,
+	).Generate(
+		ctx,
+		container,
+		[]*pluginpb.CodeGeneratorRequest{request},
+	)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", pluginName, err)
+	}
+	return printResponse(container.Stdout(), flags.Format, response)
+}
+
+func readRequest(container appext.Container, requestPath string) (*pluginpb.CodeGeneratorRequest, error) {
+	var data []byte
+	var err error
+	if requestPath == "-" {
+		data, err = io.ReadAll(container.Stdin())
+	} else {
+		data, err = os.ReadFile(requestPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	unmarshaler := protoencoding.NewWireUnmarshaler(nil)
+	if filepath.Ext(requestPath) == ".json" {
+		unmarshaler = protoencoding.NewJSONUnmarshaler(nil)
+	}
+	request := &pluginpb.CodeGeneratorRequest{}
+	if err := unmarshaler.Unmarshal(data, request); err != nil {
+		return nil, fmt.Errorf("could not read CodeGeneratorRequest from %q: %w", requestPath, err)
+	}
+	return request, nil
+}
+
+// isPluginPath returns true if the plugin refers to a binary rather than a
+// plugin name that is resolved like in protoc.
+func isPluginPath(pluginName string) bool {
+	return strings.ContainsRune(pluginName, filepath.Separator) ||
+		strings.ContainsRune(pluginName, '/') ||
+		strings.HasPrefix(filepath.Base(pluginName), "protoc-gen-")
+}
+
+func printResponse(writer io.Writer, format string, response *pluginpb.CodeGeneratorResponse) error {
+	switch format {
+	case formatJSON:
+		data, err := protoencoding.NewJSONMarshaler(nil, protoencoding.JSONMarshalerWithIndent()).Marshal(response)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(writer, string(data))
+		return err
+	case formatBinpb:
+		data, err := protoencoding.NewWireMarshaler().Marshal(response)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	default:
+		for _, file := range response.GetFile() {
+			header := file.GetName()
+			if insertionPoint := file.GetInsertionPoint(); insertionPoint != "" {
+				header += " (insertion point " + insertionPoint + ")"
+			}
+			if _, err := fmt.Fprintf(writer, "--- %s ---\n%s", header, file.GetContent()); err != nil {
+				return err
+			}
+			if content := file.GetContent(); content != "" && !strings.HasSuffix(content, "\n") {
+				if _, err := fmt.Fprintln(writer); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrun
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bufbuild/buf/private/buf/cmd/buf/internal/internaltesting"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd/appcmdtesting"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	requestPath := writeTestRequest(t)
+	appcmdtesting.RunCommandSuccessStdout(
+		t,
+		newTestCommand,
+		`--- a.top-level-type-names.yaml ---
+messages:
+    - a.Foo
+`,
+		internaltesting.NewEnvFunc(t),
+		nil,
+		"protoc-gen-top-level-type-names-yaml",
+		"--request",
+		requestPath,
+	)
+}
+
+func TestRunPluginNotFound(t *testing.T) {
+	t.Parallel()
+	requestPath := writeTestRequest(t)
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		newTestCommand,
+		1,
+		[]string{"could not find protoc plugin for name nonexistent"},
+		internaltesting.NewEnvFunc(t),
+		nil,
+		"nonexistent",
+		"--request",
+		requestPath,
+	)
+}
+
+func newTestCommand(name string) *appcmd.Command {
+	return NewCommand(name, appext.NewBuilder(name))
+}
+
+func writeTestRequest(t *testing.T) string {
+	request := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("a.proto"),
+				Package: proto.String("a"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("Foo"),
+					},
+				},
+			},
+		},
+	}
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+	requestPath := filepath.Join(t.TempDir(), "request.binpb")
+	require.NoError(t, os.WriteFile(requestPath, data, 0600))
+	return requestPath
+}
@@ -110,6 +110,7 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 	)
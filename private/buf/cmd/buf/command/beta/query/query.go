@@ -0,0 +1,243 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufquery"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/spf13/pflag"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	disableSymlinksFlagName = "disable-symlinks"
+	queryFlagName           = "query"
+	formatFlagName          = "format"
+
+	textFormatString = "text"
+	jsonFormatString = "json"
+)
+
+var allFormatStrings = []string{
+	textFormatString,
+	jsonFormatString,
+}
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <input>",
+		Short: "Find the elements of the input that match a query",
+		Long: `Find the files, messages, fields, oneofs, enums, enum values, services, methods, and
+extensions of the input that match a query, and print their locations.
+
+A query is an optional kind followed by filters on attributes and functions:
+
+    kind[attribute=value]:function(query)
+
+The kind is one of file, message, field, oneof, enum, enum_value, service, method, or
+extension, or * for any kind.
+
+Attributes are compared with glob patterns with = or !=, or checked to be set and not
+false without a value. The attributes are name, full_name, file, package, type (for
+example string, acme.v1.User, or map<string, acme.v1.User>), label, number, input, and
+output. Any other attribute is an option, such as deprecated or
+(buf.validate.field).string.min_len.
+
+The functions are has (a declared element matches), in (the element is declared within a
+matching element), not, type (the message or enum type of a field matches), input and
+output (the input or output message of a method matches), and reaches (a matching element
+is reachable through the types of fields and methods).
+
+Only the elements of the target files are printed, but imports are used by functions such
+as reaches.
+
+Examples:
+
+Find the fields that are redacted:
+
+    $ buf beta query --query 'field[debug_redact]'
+
+Find the methods that return a message that contains a google.protobuf.Any:
+
+    $ buf beta query --query 'method:output(:reaches(message[full_name=google.protobuf.Any]))'
+
+Find the enums without an UNSPECIFIED value:
+
+    $ buf beta query --query 'enum:not(:has(enum_value[name=*_UNSPECIFIED]))'
+` + bufcli.GetInputLong(`the source, module, or image to query`),
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	DisableSymlinks bool
+	Query           string
+	Format          string
+	// special
+	InputHashtag string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Query,
+		queryFlagName,
+		"",
+		`The query to match the elements of the input against`,
+	)
+	_ = appcmd.MarkFlagRequired(flagSet, queryFlagName)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		textFormatString,
+		fmt.Sprintf(
+			"The format to print the matching elements. Must be one of %s",
+			stringutil.SliceToString(allFormatStrings),
+		),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	input, err := bufcli.GetInputValue(container, flags.InputHashtag, ".")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	query, err := bufquery.ParseQuery(flags.Query)
+	if err != nil {
+		return appcmd.NewInvalidArgumentErrorf("--%s: %v", queryFlagName, err)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	// Source code info is kept for the locations of the elements.
+	image, err := controller.GetImage(ctx, input)
+	if err != nil {
+		return err
+	}
+	elements, err := bufquery.FindElements(ctx, image, query)
+	if err != nil {
+		return err
+	}
+	switch flags.Format {
+	case textFormatString:
+		return printElementsText(container.Stdout(), elements)
+	case jsonFormatString:
+		return printElementsJSON(container.Stdout(), elements)
+	default:
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+}
+
+// printElementsText prints a line for each element, such as:
+//
+//	acme/v1/user.proto:5:9:message acme.v1.User
+func printElementsText(writer io.Writer, elements []bufquery.Element) error {
+	for _, element := range elements {
+		prefix := element.ExternalPath()
+		if location := element.Location(); location != nil {
+			prefix += ":" + strconv.Itoa(location.StartLine()) + ":" + strconv.Itoa(location.StartColumn())
+		}
+		if _, err := fmt.Fprintf(writer, "%s:%s %s\n", prefix, element.Kind(), element.FullName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printElementsJSON prints a JSON object for each element on its own line.
+func printElementsJSON(writer io.Writer, elements []bufquery.Element) error {
+	for _, element := range elements {
+		externalElement := externalElement{
+			Path:     element.ExternalPath(),
+			Kind:     element.Kind().String(),
+			FullName: element.FullName(),
+		}
+		if location := element.Location(); location != nil {
+			externalElement.StartLine = location.StartLine()
+			externalElement.StartColumn = location.StartColumn()
+			externalElement.EndLine = location.EndLine()
+			externalElement.EndColumn = location.EndColumn()
+		}
+		data, err := json.Marshal(externalElement)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type externalElement struct {
+	Path        string `json:"path,omitempty"`
+	StartLine   int    `json:"start_line,omitempty"`
+	StartColumn int    `json:"start_column,omitempty"`
+	EndLine     int    `json:"end_line,omitempty"`
+	EndColumn   int    `json:"end_column,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	FullName    string `json:"full_name,omitempty"`
+}
@@ -0,0 +1,70 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"testing"
+
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd/appcmdtesting"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+)
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		"testdata/user.proto:7:10:field acme.v1.User.email\n",
+		nil,
+		nil,
+		"testdata/user.proto",
+		"--query",
+		"field[debug_redact]",
+	)
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		`{"path":"testdata/user.proto","start_line":10,"start_column":6,"end_line":10,"end_column":10,"kind":"enum","full_name":"acme.v1.Role"}`+"\n",
+		nil,
+		nil,
+		"testdata/user.proto",
+		"--query",
+		"enum:not(:has(enum_value[name=*_UNSPECIFIED]))",
+		"--format",
+		"json",
+	)
+}
+
+func TestQueryInvalid(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		testNewCommand,
+		1,
+		[]string{`--query: invalid query at offset 0: unknown kind "mesage"`},
+		nil,
+		nil,
+		"testdata/user.proto",
+		"--query",
+		"mesage",
+	)
+}
+
+func testNewCommand(use string) *appcmd.Command {
+	return NewCommand("query", appext.NewBuilder("query"))
+}
@@ -0,0 +1,491 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schemaexport
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go/resolver"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	// jsonSchemaDefsRefPrefix is the prefix of references to the definitions of a JSON Schema document.
+	jsonSchemaDefsRefPrefix = "#/$defs/"
+	// durationPattern is the pattern of the JSON representation of google.protobuf.Duration.
+	durationPattern = `^-?[0-9]+(\.[0-9]{1,9})?s$`
+)
+
+// schema is a JSON Schema.
+//
+// This is a map as opposed to a struct as JSON Schema has a large number of keywords. Keys
+// are sorted when marshaled to JSON, so output is deterministic.
+type schema map[string]any
+
+// schemaGenerator generates JSON Schemas for messages and enums following the JSON
+// mapping of Protobuf, as implemented by protojson.
+type schemaGenerator struct {
+	// refPrefix is the prefix of references to definitions.
+	refPrefix      string
+	useProtoNames  bool
+	useEnumNumbers bool
+	// defs are the definitions of all messages and enums that were added, keyed
+	// by their full name.
+	defs map[string]schema
+}
+
+func newSchemaGenerator(refPrefix string, useProtoNames bool, useEnumNumbers bool) *schemaGenerator {
+	return &schemaGenerator{
+		refPrefix:      refPrefix,
+		useProtoNames:  useProtoNames,
+		useEnumNumbers: useEnumNumbers,
+		defs:           make(map[string]schema),
+	}
+}
+
+// addMessage adds the definition of the message, and of all messages and enums it references.
+//
+// Returns a reference to the definition. Well-known types with a special JSON representation
+// are not added as definitions, and their schema is returned instead.
+func (g *schemaGenerator) addMessage(messageDescriptor protoreflect.MessageDescriptor) schema {
+	if wellKnownTypeSchema := getWellKnownTypeSchema(messageDescriptor); wellKnownTypeSchema != nil {
+		return wellKnownTypeSchema
+	}
+	fullName := string(messageDescriptor.FullName())
+	ref := schema{"$ref": g.refPrefix + fullName}
+	if _, ok := g.defs[fullName]; ok {
+		return ref
+	}
+	messageSchema := schema{
+		"type":                 "object",
+		"additionalProperties": false,
+	}
+	// Set before the fields are added, as messages may be recursive.
+	g.defs[fullName] = messageSchema
+	setDescription(messageSchema, messageDescriptor)
+	if isDeprecated(messageDescriptor) {
+		messageSchema["deprecated"] = true
+	}
+	properties := make(map[string]schema)
+	var required []string
+	fields := messageDescriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fieldDescriptor := fields.Get(i)
+		fieldSchema := g.getFieldSchema(fieldDescriptor)
+		setDescription(fieldSchema, fieldDescriptor)
+		if isDeprecated(fieldDescriptor) {
+			fieldSchema["deprecated"] = true
+		}
+		fieldConstraints := resolver.DefaultResolver{}.ResolveFieldConstraints(fieldDescriptor)
+		if fieldConstraints.GetIgnore() != validate.Ignore_IGNORE_ALWAYS {
+			g.applyFieldConstraints(fieldSchema, fieldDescriptor, fieldConstraints)
+			if fieldConstraints.GetRequired() {
+				required = append(required, g.getFieldName(fieldDescriptor))
+			}
+		}
+		properties[g.getFieldName(fieldDescriptor)] = fieldSchema
+	}
+	messageSchema["properties"] = properties
+	if len(required) > 0 {
+		messageSchema["required"] = required
+	}
+	var allOf []schema
+	oneofs := messageDescriptor.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		oneofDescriptor := oneofs.Get(i)
+		if oneofDescriptor.IsSynthetic() {
+			continue
+		}
+		// At most one field of a oneof may be set, or exactly one if the oneof is required.
+		var oneOf []schema
+		oneofFields := oneofDescriptor.Fields()
+		for j := 0; j < oneofFields.Len(); j++ {
+			oneOf = append(oneOf, schema{"required": []string{g.getFieldName(oneofFields.Get(j))}})
+		}
+		oneofConstraints := resolver.DefaultResolver{}.ResolveOneofConstraints(oneofDescriptor)
+		if !oneofConstraints.GetRequired() {
+			oneOf = append(oneOf, schema{"not": schema{"anyOf": slices.Clone(oneOf)}})
+		}
+		allOf = append(allOf, schema{"oneOf": oneOf})
+	}
+	if len(allOf) > 0 {
+		messageSchema["allOf"] = allOf
+	}
+	return ref
+}
+
+// addEnum adds the definition of the enum.
+//
+// Returns a reference to the definition.
+func (g *schemaGenerator) addEnum(enumDescriptor protoreflect.EnumDescriptor) schema {
+	if enumDescriptor.FullName() == "google.protobuf.NullValue" {
+		return schema{"type": "null"}
+	}
+	fullName := string(enumDescriptor.FullName())
+	ref := schema{"$ref": g.refPrefix + fullName}
+	if _, ok := g.defs[fullName]; ok {
+		return ref
+	}
+	enumSchema := schema{}
+	values := enumDescriptor.Values()
+	if g.useEnumNumbers {
+		enumNumbers := make([]int32, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			enumNumbers[i] = int32(values.Get(i).Number())
+		}
+		enumSchema["type"] = "integer"
+		enumSchema["enum"] = enumNumbers
+	} else {
+		enumNames := make([]string, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			enumNames[i] = string(values.Get(i).Name())
+		}
+		enumSchema["type"] = "string"
+		enumSchema["enum"] = enumNames
+	}
+	setDescription(enumSchema, enumDescriptor)
+	g.defs[fullName] = enumSchema
+	return ref
+}
+
+func (g *schemaGenerator) getFieldName(fieldDescriptor protoreflect.FieldDescriptor) string {
+	if g.useProtoNames {
+		return fieldDescriptor.TextName()
+	}
+	return fieldDescriptor.JSONName()
+}
+
+func (g *schemaGenerator) getFieldSchema(fieldDescriptor protoreflect.FieldDescriptor) schema {
+	switch {
+	case fieldDescriptor.IsMap():
+		// Map keys are always strings in JSON.
+		return schema{
+			"type":                 "object",
+			"additionalProperties": g.getSingularFieldSchema(fieldDescriptor.MapValue()),
+		}
+	case fieldDescriptor.IsList():
+		return schema{
+			"type":  "array",
+			"items": g.getSingularFieldSchema(fieldDescriptor),
+		}
+	default:
+		return g.getSingularFieldSchema(fieldDescriptor)
+	}
+}
+
+func (g *schemaGenerator) getSingularFieldSchema(fieldDescriptor protoreflect.FieldDescriptor) schema {
+	switch kind := fieldDescriptor.Kind(); kind {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.addMessage(fieldDescriptor.Message())
+	case protoreflect.EnumKind:
+		return g.addEnum(fieldDescriptor.Enum())
+	default:
+		return getScalarSchema(kind)
+	}
+}
+
+// getScalarSchema returns the schema of a scalar kind.
+//
+// 64-bit integers are strings in JSON, but numbers are also accepted.
+func getScalarSchema(kind protoreflect.Kind) schema {
+	switch kind {
+	case protoreflect.BoolKind:
+		return schema{"type": "boolean"}
+	case protoreflect.StringKind:
+		return schema{"type": "string"}
+	case protoreflect.BytesKind:
+		return schema{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return schema{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return schema{"type": "integer", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return schema{"type": []string{"integer", "string"}, "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return schema{"type": []string{"integer", "string"}, "minimum": 0}
+	case protoreflect.FloatKind:
+		return schema{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return schema{"type": "number", "format": "double"}
+	default:
+		return schema{}
+	}
+}
+
+// getWellKnownTypeSchema returns the schema of well-known types with a special JSON
+// representation, or nil if the message is not one of them.
+func getWellKnownTypeSchema(messageDescriptor protoreflect.MessageDescriptor) schema {
+	switch messageDescriptor.FullName() {
+	case "google.protobuf.Any":
+		return schema{
+			"type": "object",
+			"properties": map[string]schema{
+				"@type": {"type": "string"},
+			},
+			"required": []string{"@type"},
+		}
+	case "google.protobuf.Timestamp":
+		return schema{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return schema{"type": "string", "pattern": durationPattern}
+	case "google.protobuf.FieldMask":
+		return schema{"type": "string"}
+	case "google.protobuf.Struct":
+		return schema{"type": "object"}
+	case "google.protobuf.Value":
+		return schema{}
+	case "google.protobuf.ListValue":
+		return schema{"type": "array"}
+	case "google.protobuf.BoolValue":
+		return getScalarSchema(protoreflect.BoolKind)
+	case "google.protobuf.StringValue":
+		return getScalarSchema(protoreflect.StringKind)
+	case "google.protobuf.BytesValue":
+		return getScalarSchema(protoreflect.BytesKind)
+	case "google.protobuf.Int32Value":
+		return getScalarSchema(protoreflect.Int32Kind)
+	case "google.protobuf.UInt32Value":
+		return getScalarSchema(protoreflect.Uint32Kind)
+	case "google.protobuf.Int64Value":
+		return getScalarSchema(protoreflect.Int64Kind)
+	case "google.protobuf.UInt64Value":
+		return getScalarSchema(protoreflect.Uint64Kind)
+	case "google.protobuf.FloatValue":
+		return getScalarSchema(protoreflect.FloatKind)
+	case "google.protobuf.DoubleValue":
+		return getScalarSchema(protoreflect.DoubleKind)
+	default:
+		return nil
+	}
+}
+
+// applyFieldConstraints maps the protovalidate constraints of a field into the keywords
+// of its schema where possible. Constraints without an equivalent keyword, such as CEL
+// expressions, are ignored.
+func (g *schemaGenerator) applyFieldConstraints(
+	fieldSchema schema,
+	fieldDescriptor protoreflect.FieldDescriptor,
+	fieldConstraints *validate.FieldConstraints,
+) {
+	switch {
+	case fieldConstraints.GetRepeated() != nil:
+		repeatedRules := fieldConstraints.GetRepeated()
+		setIfNotNil(fieldSchema, "minItems", repeatedRules.MinItems)
+		setIfNotNil(fieldSchema, "maxItems", repeatedRules.MaxItems)
+		setIfNotNil(fieldSchema, "uniqueItems", repeatedRules.Unique)
+		if items, ok := fieldSchema["items"].(schema); ok && repeatedRules.GetItems() != nil {
+			// The schema of the items may be a shared reference, so we add the constraints
+			// to a new schema.
+			itemsSchema := schema{"allOf": []schema{items}}
+			g.applyFieldConstraints(itemsSchema, fieldDescriptor, repeatedRules.GetItems())
+			fieldSchema["items"] = itemsSchema
+		}
+	case fieldConstraints.GetMap() != nil:
+		mapRules := fieldConstraints.GetMap()
+		setIfNotNil(fieldSchema, "minProperties", mapRules.MinPairs)
+		setIfNotNil(fieldSchema, "maxProperties", mapRules.MaxPairs)
+		if values, ok := fieldSchema["additionalProperties"].(schema); ok && mapRules.GetValues() != nil {
+			valuesSchema := schema{"allOf": []schema{values}}
+			g.applyFieldConstraints(valuesSchema, fieldDescriptor.MapValue(), mapRules.GetValues())
+			fieldSchema["additionalProperties"] = valuesSchema
+		}
+	case fieldConstraints.GetString_() != nil:
+		applyStringRules(fieldSchema, fieldConstraints.GetString_())
+	case fieldConstraints.GetEnum() != nil:
+		g.applyEnumRules(fieldSchema, fieldDescriptor.Enum(), fieldConstraints.GetEnum())
+	default:
+		applyNumericRules(fieldSchema, fieldDescriptor.Kind(), fieldConstraints)
+	}
+	if fieldConstraints.GetRequired() {
+		// A required repeated or map field must not be empty.
+		switch {
+		case fieldDescriptor.IsList() && fieldSchema["minItems"] == nil:
+			fieldSchema["minItems"] = 1
+		case fieldDescriptor.IsMap() && fieldSchema["minProperties"] == nil:
+			fieldSchema["minProperties"] = 1
+		}
+	}
+}
+
+func applyStringRules(fieldSchema schema, stringRules *validate.StringRules) {
+	setIfNotNil(fieldSchema, "const", stringRules.Const)
+	setIfNotNil(fieldSchema, "minLength", stringRules.Len)
+	setIfNotNil(fieldSchema, "maxLength", stringRules.Len)
+	setIfNotNil(fieldSchema, "minLength", stringRules.MinLen)
+	setIfNotNil(fieldSchema, "maxLength", stringRules.MaxLen)
+	if in := stringRules.GetIn(); len(in) > 0 {
+		fieldSchema["enum"] = in
+	}
+	var patterns []string
+	if stringRules.Pattern != nil {
+		patterns = append(patterns, stringRules.GetPattern())
+	}
+	if stringRules.Prefix != nil {
+		patterns = append(patterns, "^"+regexp.QuoteMeta(stringRules.GetPrefix()))
+	}
+	if stringRules.Suffix != nil {
+		patterns = append(patterns, regexp.QuoteMeta(stringRules.GetSuffix())+"$")
+	}
+	if stringRules.Contains != nil {
+		patterns = append(patterns, regexp.QuoteMeta(stringRules.GetContains()))
+	}
+	// A schema can only have a single pattern, additional patterns are added as subschemas.
+	for i, pattern := range patterns {
+		if i == 0 {
+			fieldSchema["pattern"] = pattern
+			continue
+		}
+		allOf, _ := fieldSchema["allOf"].([]schema)
+		fieldSchema["allOf"] = append(allOf, schema{"pattern": pattern})
+	}
+	switch {
+	case stringRules.GetEmail():
+		fieldSchema["format"] = "email"
+	case stringRules.GetHostname():
+		fieldSchema["format"] = "hostname"
+	case stringRules.GetIpv4():
+		fieldSchema["format"] = "ipv4"
+	case stringRules.GetIpv6():
+		fieldSchema["format"] = "ipv6"
+	case stringRules.GetUri():
+		fieldSchema["format"] = "uri"
+	case stringRules.GetUriRef():
+		fieldSchema["format"] = "uri-reference"
+	case stringRules.GetUuid():
+		fieldSchema["format"] = "uuid"
+	}
+}
+
+func (g *schemaGenerator) applyEnumRules(fieldSchema schema, enumDescriptor protoreflect.EnumDescriptor, enumRules *validate.EnumRules) {
+	// The enum schema is a reference, so restrictions are added next to it, using the same
+	// representation as the referenced enum schema. Numbers that are not values of the enum
+	// are dropped, as they are already rejected by the reference.
+	enumValues := func(numbers []int32) any {
+		if g.useEnumNumbers {
+			return numbers
+		}
+		var names []string
+		for _, number := range numbers {
+			if enumValueDescriptor := enumDescriptor.Values().ByNumber(protoreflect.EnumNumber(number)); enumValueDescriptor != nil {
+				names = append(names, string(enumValueDescriptor.Name()))
+			}
+		}
+		return names
+	}
+	switch {
+	case enumRules.Const != nil:
+		fieldSchema["enum"] = enumValues([]int32{enumRules.GetConst()})
+	case len(enumRules.GetIn()) > 0:
+		fieldSchema["enum"] = enumValues(enumRules.GetIn())
+	case len(enumRules.GetNotIn()) > 0:
+		fieldSchema["not"] = schema{"enum": enumValues(enumRules.GetNotIn())}
+	}
+}
+
+// applyNumericRules applies the rules of all numeric types, which share the same rule names.
+//
+// Only the range rules are applied to 64-bit integers, as they may also be strings.
+func applyNumericRules(fieldSchema schema, kind protoreflect.Kind, fieldConstraints *validate.FieldConstraints) {
+	fieldConstraintsMessage := fieldConstraints.ProtoReflect()
+	typeFieldDescriptor := fieldConstraintsMessage.WhichOneof(
+		fieldConstraintsMessage.Descriptor().Oneofs().ByName("type"),
+	)
+	if typeFieldDescriptor == nil || typeFieldDescriptor.Message() == nil {
+		return
+	}
+	rulesMessage := fieldConstraintsMessage.Get(typeFieldDescriptor).Message()
+	var is64BitInteger bool
+	switch kind {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		is64BitInteger = true
+	}
+	for ruleName, keyword := range map[protoreflect.Name]string{
+		"lt":    "exclusiveMaximum",
+		"lte":   "maximum",
+		"gt":    "exclusiveMinimum",
+		"gte":   "minimum",
+		"const": "const",
+		"in":    "enum",
+	} {
+		ruleFieldDescriptor := rulesMessage.Descriptor().Fields().ByName(ruleName)
+		if ruleFieldDescriptor == nil || !rulesMessage.Has(ruleFieldDescriptor) {
+			continue
+		}
+		if is64BitInteger && (ruleName == "const" || ruleName == "in") {
+			continue
+		}
+		if ruleFieldDescriptor.Kind() == protoreflect.MessageKind {
+			// Duration and timestamp rules.
+			continue
+		}
+		value := rulesMessage.Get(ruleFieldDescriptor)
+		if ruleFieldDescriptor.IsList() {
+			list := value.List()
+			values := make([]any, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				values[i] = list.Get(i).Interface()
+			}
+			fieldSchema[keyword] = values
+			continue
+		}
+		fieldSchema[keyword] = value.Interface()
+	}
+}
+
+func setIfNotNil[T any](s schema, keyword string, value *T) {
+	if value != nil {
+		s[keyword] = *value
+	}
+}
+
+// setDescription sets the description of the schema to the leading comments of the descriptor.
+func setDescription(s schema, descriptor protoreflect.Descriptor) {
+	comments := descriptor.ParentFile().SourceLocations().ByDescriptor(descriptor).LeadingComments
+	if description := strings.TrimSpace(comments); description != "" {
+		s["description"] = description
+	}
+}
+
+func isDeprecated(descriptor protoreflect.Descriptor) bool {
+	type deprecatedOptions interface {
+		GetDeprecated() bool
+	}
+	options, ok := descriptor.Options().(deprecatedOptions)
+	return ok && options.GetDeprecated()
+}
+
+// getJSONSchemaDocument returns the JSON Schema document with the definitions of the messages.
+//
+// If there is a single message, the document is the schema of the message.
+func getJSONSchemaDocument(generator *schemaGenerator, messageDescriptors []protoreflect.MessageDescriptor) schema {
+	document := schema{
+		"$schema": jsonSchemaDialect,
+	}
+	for _, messageDescriptor := range messageDescriptors {
+		messageSchema := generator.addMessage(messageDescriptor)
+		if len(messageDescriptors) == 1 {
+			for keyword, value := range messageSchema {
+				document[keyword] = value
+			}
+		}
+	}
+	document["$defs"] = generator.defs
+	return document
+}
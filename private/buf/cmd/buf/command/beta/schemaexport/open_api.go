@@ -0,0 +1,171 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schemaexport
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	// openAPIDocumentVersion is the version of the described API, which is not known.
+	openAPIDocumentVersion = "0.0.0"
+	// openAPIComponentsRefPrefix is the prefix of references to the schemas of an OpenAPI document.
+	openAPIComponentsRefPrefix = "#/components/schemas/"
+	// connectErrorSchemaName is the name of the schema of Connect errors.
+	connectErrorSchemaName = "connect.error"
+	// connectProtocolVersionHeader is the header that identifies requests of the Connect protocol.
+	connectProtocolVersionHeader = "Connect-Protocol-Version"
+	jsonContentType              = "application/json"
+)
+
+// connectErrorCodes are the error codes of the Connect protocol.
+//
+// See https://connectrpc.com/docs/protocol#error-codes.
+var connectErrorCodes = []string{
+	"canceled",
+	"unknown",
+	"invalid_argument",
+	"deadline_exceeded",
+	"not_found",
+	"already_exists",
+	"permission_denied",
+	"resource_exhausted",
+	"failed_precondition",
+	"aborted",
+	"out_of_range",
+	"unimplemented",
+	"internal",
+	"unavailable",
+	"data_loss",
+	"unauthenticated",
+}
+
+// getOpenAPIDocument returns the OpenAPI document for the unary methods, as served by the
+// Connect protocol with the JSON codec.
+//
+// The schemas of the generator are added as components, so messages added to the generator
+// before are also included.
+func getOpenAPIDocument(
+	generator *schemaGenerator,
+	title string,
+	methodDescriptors []protoreflect.MethodDescriptor,
+) schema {
+	paths := make(map[string]schema)
+	for _, methodDescriptor := range methodDescriptors {
+		if methodDescriptor.IsStreamingClient() || methodDescriptor.IsStreamingServer() {
+			continue
+		}
+		serviceName := string(methodDescriptor.Parent().FullName())
+		paths["/"+serviceName+"/"+string(methodDescriptor.Name())] = schema{
+			"post": getOpenAPIOperation(generator, methodDescriptor),
+		}
+	}
+	schemas := make(map[string]schema, len(generator.defs)+1)
+	for name, messageSchema := range generator.defs {
+		schemas[name] = messageSchema
+	}
+	schemas[connectErrorSchemaName] = getConnectErrorSchema()
+	return schema{
+		"openapi": openAPIVersion,
+		"info": schema{
+			"title":   title,
+			"version": openAPIDocumentVersion,
+		},
+		"paths": paths,
+		"components": schema{
+			"schemas": schemas,
+		},
+	}
+}
+
+func getOpenAPIOperation(
+	generator *schemaGenerator,
+	methodDescriptor protoreflect.MethodDescriptor,
+) schema {
+	operation := schema{
+		"operationId": string(methodDescriptor.FullName()),
+		"tags":        []string{string(methodDescriptor.Parent().FullName())},
+		"parameters": []schema{
+			{
+				"name":     connectProtocolVersionHeader,
+				"in":       "header",
+				"required": false,
+				"schema":   schema{"type": "string", "const": "1"},
+			},
+		},
+		"requestBody": schema{
+			"required": true,
+			"content":  getOpenAPIJSONContent(generator.addMessage(methodDescriptor.Input())),
+		},
+		"responses": schema{
+			"200": schema{
+				"description": "Success",
+				"content":     getOpenAPIJSONContent(generator.addMessage(methodDescriptor.Output())),
+			},
+			"default": schema{
+				"description": "Error",
+				"content":     getOpenAPIJSONContent(schema{"$ref": generator.refPrefix + connectErrorSchemaName}),
+			},
+		},
+	}
+	comments := methodDescriptor.ParentFile().SourceLocations().ByDescriptor(methodDescriptor).LeadingComments
+	if description := strings.TrimSpace(comments); description != "" {
+		operation["description"] = description
+	}
+	if isDeprecated(methodDescriptor) {
+		operation["deprecated"] = true
+	}
+	return operation
+}
+
+func getOpenAPIJSONContent(contentSchema schema) schema {
+	return schema{
+		jsonContentType: schema{
+			"schema": contentSchema,
+		},
+	}
+}
+
+// getConnectErrorSchema returns the schema of the JSON representation of Connect errors.
+//
+// See https://connectrpc.com/docs/protocol#error-end-stream.
+func getConnectErrorSchema() schema {
+	return schema{
+		"type": "object",
+		"properties": map[string]schema{
+			"code": {
+				"type": "string",
+				"enum": connectErrorCodes,
+			},
+			"message": {
+				"type": "string",
+			},
+			"details": {
+				"type": "array",
+				"items": schema{
+					"type": "object",
+					"properties": map[string]schema{
+						"type":  {"type": "string"},
+						"value": {"type": "string", "contentEncoding": "base64"},
+						"debug": {},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schemaexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	configFlagName          = "config"
+	pathsFlagName           = "path"
+	excludePathsFlagName    = "exclude-path"
+	disableSymlinksFlagName = "disable-symlinks"
+	typeFlagName            = "type"
+	formatFlagName          = "format"
+	useProtoNamesFlagName   = "use-proto-names"
+	useEnumNumbersFlagName  = "use-enum-numbers"
+
+	jsonSchemaFormatString = "jsonschema"
+	openAPIFormatString    = "openapi"
+)
+
+var allFormatStrings = []string{
+	jsonSchemaFormatString,
+	openAPIFormatString,
+}
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <input>",
+		Short: "Export JSON Schema or OpenAPI documents for messages and services",
+		Long: `Export a JSON Schema document for the messages of the input, or an OpenAPI document
+for the unary methods of its services as served by Connect.
+
+The schemas follow the JSON mapping of Protobuf: fields are named by their JSON name, enums
+are their value names, 64-bit integers may be strings, and well-known types such as
+google.protobuf.Timestamp have their special representations. Use --use-proto-names and
+--use-enum-numbers to match the corresponding JSON marshaling options.
+
+The messages and services are those declared in the target files of the input. Use --type
+to only export the given types and the types they depend on.
+
+protovalidate rules are mapped into schema keywords where possible. For example,
+(buf.validate.field).string.min_len becomes minLength, and (buf.validate.field).required
+adds the field to required. Rules without an equivalent keyword, such as CEL expressions,
+are not exported.
+
+The following formats are supported:
+
+  - jsonschema: A JSON Schema 2020-12 document with a definition for each message and enum
+    in $defs. If there is a single message, the document is the schema of that message.
+  - openapi: An OpenAPI 3.1 document with a POST operation for each unary method, and a
+    schema for each message and enum in components.
+` + bufcli.GetInputLong(`the source, module, or image to export schemas for`),
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	Config          string
+	Paths           []string
+	ExcludePaths    []string
+	DisableSymlinks bool
+	Types           []string
+	Format          string
+	UseProtoNames   bool
+	UseEnumNumbers  bool
+	// special
+	InputHashtag string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
+	bufcli.BindPaths(flagSet, &f.Paths, pathsFlagName)
+	bufcli.BindExcludePaths(flagSet, &f.ExcludePaths, excludePathsFlagName)
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Config,
+		configFlagName,
+		"",
+		`The buf.yaml file or data to use for configuration`,
+	)
+	flagSet.StringSliceVar(
+		&f.Types,
+		typeFlagName,
+		nil,
+		"The types (package, message, enum, extension, service, method) to export. When specified, only the requested types and the types they depend on are exported",
+	)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		jsonSchemaFormatString,
+		fmt.Sprintf(
+			"The format to export. Must be one of %s",
+			stringutil.SliceToString(allFormatStrings),
+		),
+	)
+	flagSet.BoolVar(
+		&f.UseProtoNames,
+		useProtoNamesFlagName,
+		false,
+		"Name fields by their name in the .proto file instead of their JSON name",
+	)
+	flagSet.BoolVar(
+		&f.UseEnumNumbers,
+		useEnumNumbersFlagName,
+		false,
+		"Represent enums by their numbers instead of their value names",
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	input, err := bufcli.GetInputValue(container, flags.InputHashtag, ".")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	// Source code info is kept, as comments are exported as descriptions.
+	image, err := controller.GetImage(
+		ctx,
+		input,
+		bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+		bufctl.WithImageTypes(flags.Types),
+		bufctl.WithConfigOverride(flags.Config),
+	)
+	if err != nil {
+		return err
+	}
+	fileDescriptors, err := getTargetFileDescriptors(image)
+	if err != nil {
+		return err
+	}
+	var document schema
+	switch flags.Format {
+	case jsonSchemaFormatString:
+		document = getJSONSchemaDocument(
+			newSchemaGenerator(jsonSchemaDefsRefPrefix, flags.UseProtoNames, flags.UseEnumNumbers),
+			getMessageDescriptors(fileDescriptors, flags.Types),
+		)
+	case openAPIFormatString:
+		generator := newSchemaGenerator(openAPIComponentsRefPrefix, flags.UseProtoNames, flags.UseEnumNumbers)
+		for _, messageDescriptor := range getMessageDescriptors(fileDescriptors, flags.Types) {
+			generator.addMessage(messageDescriptor)
+		}
+		document = getOpenAPIDocument(generator, input, getMethodDescriptors(fileDescriptors, flags.Types))
+	default:
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = container.Stdout().Write(append(data, '\n'))
+	return err
+}
+
+// getTargetFileDescriptors returns the FileDescriptors of the files of the image that
+// are not imports.
+func getTargetFileDescriptors(image bufimage.Image) ([]protoreflect.FileDescriptor, error) {
+	resolver, err := protoencoding.NewResolver(bufimage.ImageToFileDescriptorProtos(image)...)
+	if err != nil {
+		return nil, err
+	}
+	var fileDescriptors []protoreflect.FileDescriptor
+	for _, imageFile := range image.Files() {
+		if imageFile.IsImport() {
+			continue
+		}
+		fileDescriptor, err := resolver.FindFileByPath(imageFile.Path())
+		if err != nil {
+			return nil, err
+		}
+		fileDescriptors = append(fileDescriptors, fileDescriptor)
+	}
+	return fileDescriptors, nil
+}
+
+// getMessageDescriptors returns the selected messages declared in the files, including nested
+// messages, but excluding map entries.
+func getMessageDescriptors(fileDescriptors []protoreflect.FileDescriptor, types []string) []protoreflect.MessageDescriptor {
+	var messageDescriptors []protoreflect.MessageDescriptor
+	var addMessageDescriptors func(protoreflect.MessageDescriptors)
+	addMessageDescriptors = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			messageDescriptor := messages.Get(i)
+			if messageDescriptor.IsMapEntry() {
+				continue
+			}
+			if isSelected(messageDescriptor, types) {
+				messageDescriptors = append(messageDescriptors, messageDescriptor)
+			}
+			addMessageDescriptors(messageDescriptor.Messages())
+		}
+	}
+	for _, fileDescriptor := range fileDescriptors {
+		addMessageDescriptors(fileDescriptor.Messages())
+	}
+	return messageDescriptors
+}
+
+// getMethodDescriptors returns the selected methods of the services declared in the files.
+func getMethodDescriptors(fileDescriptors []protoreflect.FileDescriptor, types []string) []protoreflect.MethodDescriptor {
+	var methodDescriptors []protoreflect.MethodDescriptor
+	for _, fileDescriptor := range fileDescriptors {
+		services := fileDescriptor.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				if methodDescriptor := methods.Get(j); isSelected(methodDescriptor, types) {
+					methodDescriptors = append(methodDescriptors, methodDescriptor)
+				}
+			}
+		}
+	}
+	return methodDescriptors
+}
+
+// isSelected returns true if the descriptor, one of its parents, or its package is one of
+// the types given with --type, or if no types were given.
+//
+// The image is already filtered by the types, but it also contains the types they depend on,
+// which are only exported as definitions.
+func isSelected(descriptor protoreflect.Descriptor, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for ; descriptor != nil; descriptor = descriptor.Parent() {
+		name := string(descriptor.FullName())
+		if fileDescriptor, ok := descriptor.(protoreflect.FileDescriptor); ok {
+			name = string(fileDescriptor.Package())
+		}
+		if slices.Contains(types, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schemaexport
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagetesting"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+	fileDescriptor := testNewFileDescriptor(t)
+	document := getJSONSchemaDocument(
+		newSchemaGenerator(jsonSchemaDefsRefPrefix, false, false),
+		getMessageDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, []string{"acme.v1.User"}),
+	)
+	require.JSONEq(
+		t,
+		`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$ref": "#/$defs/acme.v1.User",
+  "$defs": {
+    "acme.v1.Status": {
+      "type": "string",
+      "enum": ["STATUS_UNSPECIFIED", "STATUS_ACTIVE"]
+    },
+    "acme.v1.User": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "emailAddress": {
+          "type": "string",
+          "format": "email",
+          "maxLength": 64
+        },
+        "age": {
+          "type": "integer",
+          "format": "int32",
+          "minimum": 0,
+          "exclusiveMaximum": 150
+        },
+        "tags": {
+          "type": "array",
+          "maxItems": 3,
+          "minItems": 1,
+          "items": {
+            "allOf": [{"type": "string"}],
+            "minLength": 2
+          }
+        },
+        "status": {
+          "$ref": "#/$defs/acme.v1.Status",
+          "enum": ["STATUS_ACTIVE"]
+        },
+        "createTime": {
+          "type": "string",
+          "format": "date-time"
+        },
+        "counts": {
+          "type": "object",
+          "additionalProperties": {
+            "type": ["integer", "string"],
+            "format": "int64"
+          }
+        },
+        "phone": {
+          "type": "string"
+        },
+        "fax": {
+          "type": "string",
+          "deprecated": true
+        }
+      },
+      "required": ["emailAddress", "tags"],
+      "allOf": [
+        {
+          "oneOf": [
+            {"required": ["phone"]},
+            {"required": ["fax"]},
+            {"not": {"anyOf": [{"required": ["phone"]}, {"required": ["fax"]}]}}
+          ]
+        }
+      ]
+    }
+  }
+}`,
+		testMarshal(t, document),
+	)
+
+	generator := newSchemaGenerator(jsonSchemaDefsRefPrefix, true, true)
+	generator.addMessage(fileDescriptor.Messages().ByName("User"))
+	require.Equal(t, schema{"type": "integer", "enum": []int32{0, 1}}, generator.defs["acme.v1.Status"])
+	require.Equal(
+		t,
+		schema{"$ref": "#/$defs/acme.v1.Status", "enum": []int32{1}},
+		generator.defs["acme.v1.User"]["properties"].(map[string]schema)["status"],
+	)
+	require.Contains(t, generator.defs["acme.v1.User"]["properties"], "email_address")
+}
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel()
+	fileDescriptor := testNewFileDescriptor(t)
+	generator := newSchemaGenerator(openAPIComponentsRefPrefix, false, false)
+	methodDescriptors := getMethodDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, nil)
+	require.Len(t, methodDescriptors, 2)
+	document := getOpenAPIDocument(generator, "input", methodDescriptors)
+	// Streaming methods are not exported.
+	paths := document["paths"].(map[string]schema)
+	require.Len(t, paths, 1)
+	operation := paths["/acme.v1.UserService/GetUser"]["post"].(schema)
+	require.Equal(t, "acme.v1.UserService.GetUser", operation["operationId"])
+	require.Equal(
+		t,
+		schema{jsonContentType: schema{"schema": schema{"$ref": "#/components/schemas/acme.v1.User"}}},
+		operation["requestBody"].(schema)["content"],
+	)
+	components := document["components"].(schema)["schemas"].(map[string]schema)
+	require.Contains(t, components, "acme.v1.User")
+	require.Contains(t, components, "acme.v1.Status")
+	require.Contains(t, components, connectErrorSchemaName)
+
+	require.Empty(t, getMethodDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, []string{"acme.v1.User"}))
+	require.Len(t, getMethodDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, []string{"acme.v1.UserService"}), 2)
+	require.Len(t, getMethodDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, []string{"acme.v1.UserService.GetUser"}), 1)
+	require.Len(t, getMessageDescriptors([]protoreflect.FileDescriptor{fileDescriptor}, []string{"acme.v1"}), 1)
+}
+
+func testNewFileDescriptor(t *testing.T) protoreflect.FileDescriptor {
+	image := bufimagetesting.BuildImage(
+		t,
+		[]bufmoduletesting.ModuleData{
+			{
+				DirPath: filepath.Join("testdata", "proto"),
+			},
+			{
+				DirPath:     filepath.Join("testdata", "vendor", "protovalidate"),
+				NotTargeted: true,
+			},
+		},
+	)
+	fileDescriptors, err := getTargetFileDescriptors(image)
+	require.NoError(t, err)
+	require.Len(t, fileDescriptors, 1)
+	return fileDescriptors[0]
+}
+
+func testMarshal(t *testing.T, document schema) string {
+	data, err := json.Marshal(document)
+	require.NoError(t, err)
+	return string(data)
+}
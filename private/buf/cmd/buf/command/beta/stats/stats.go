@@ -17,9 +17,11 @@ package stats
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/buffetch"
 	"github.com/bufbuild/buf/private/buf/bufprint"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
@@ -31,6 +33,7 @@ import (
 
 const (
 	formatFlagName          = "format"
+	againstFlagName         = "against"
 	disableSymlinksFlagName = "disable-symlinks"
 )
 
@@ -43,8 +46,16 @@ func NewCommand(
 	return &appcmd.Command{
 		Use:   name + " <source>",
 		Short: "Get statistics for a given source or module",
-		Long:  bufcli.GetSourceOrModuleLong(`the source or module to get statistics for`),
-		Args:  appcmd.MaximumNArgs(1),
+		Long: bufcli.GetSourceOrModuleLong(`the source or module to get statistics for`) + `
+
+Statistics are printed for the source or module as a whole, and broken down by module and by package.
+In addition to counts of each type of element, this includes the deepest message nesting, the largest
+message by field count, the widest oneofs, the share of deprecated elements, comment coverage per
+element kind, and the files with the most imports and importers.
+
+If --against is set, the statistics for the source or module are compared to the statistics for the
+against source or module. This can be used to track schema growth and documentation quality over time.`,
+		Args: appcmd.MaximumNArgs(1),
 		Run: builder.NewRunFunc(
 			func(ctx context.Context, container appext.Container) error {
 				return run(ctx, container, flags)
@@ -56,6 +67,7 @@ func NewCommand(
 
 type flags struct {
 	Format          string
+	Against         string
 	DisableSymlinks bool
 
 	// special
@@ -73,6 +85,15 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 		bufprint.FormatText.String(),
 		fmt.Sprintf(`The output format to use. Must be one of %s`, bufprint.AllFormatsString),
 	)
+	flagSet.StringVar(
+		&f.Against,
+		againstFlagName,
+		"",
+		fmt.Sprintf(
+			`The source or module to compare statistics against. Must be one of format %s`,
+			buffetch.SourceOrModuleFormatsString,
+		),
+	)
 	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
 	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
 }
@@ -91,35 +112,105 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 	)
 	if err != nil {
 		return err
 	}
+	statsReport, err := getStatsReport(ctx, controller, input)
+	if err != nil {
+		return err
+	}
+	statsPrinter := bufprint.NewStatsPrinter(container.Stdout())
+	if flags.Against == "" {
+		return statsPrinter.PrintStats(
+			ctx,
+			format,
+			statsReport,
+		)
+	}
+	againstStatsReport, err := getStatsReport(ctx, controller, flags.Against)
+	if err != nil {
+		return err
+	}
+	return statsPrinter.PrintStatsAgainst(
+		ctx,
+		format,
+		statsReport,
+		againstStatsReport,
+	)
+}
+
+func getStatsReport(
+	ctx context.Context,
+	controller bufctl.Controller,
+	input string,
+) (*bufprint.StatsReport, error) {
 	workspace, err := controller.GetWorkspace(
 		ctx,
 		input,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	stats, err := protostat.GetStats(
-		ctx,
-		protostatstorage.NewFileWalker(
-			bufmodule.ModuleReadBucketToStorageReadBucket(
-				bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFilesForTargetModules(
-					workspace,
-				),
+	fileWalker := protostatstorage.NewFileWalker(
+		bufmodule.ModuleReadBucketToStorageReadBucket(
+			bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFilesForTargetModules(
+				workspace,
 			),
 		),
 	)
+	stats, err := protostat.GetStats(ctx, fileWalker)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return bufprint.NewStatsPrinter(container.Stdout()).PrintStats(
-		ctx,
-		format,
-		stats,
+	packageToStats, err := protostat.GetPackageStats(ctx, fileWalker)
+	if err != nil {
+		return nil, err
+	}
+	statsReport := &bufprint.StatsReport{
+		Stats:    stats,
+		Modules:  []*bufprint.NamedStats{},
+		Packages: make([]*bufprint.NamedStats, 0, len(packageToStats)),
+	}
+	for _, module := range bufmodule.ModuleSetTargetModules(workspace) {
+		moduleStats, err := protostat.GetStats(
+			ctx,
+			protostatstorage.NewFileWalker(
+				bufmodule.ModuleReadBucketToStorageReadBucket(
+					bufmodule.ModuleReadBucketWithOnlyProtoFiles(
+						module,
+					),
+				),
+			),
+		)
+		if err != nil {
+			return nil, err
+		}
+		statsReport.Modules = append(
+			statsReport.Modules,
+			&bufprint.NamedStats{
+				Name:  module.OpaqueID(),
+				Stats: moduleStats,
+			},
+		)
+	}
+	for packageName, packageStats := range packageToStats {
+		statsReport.Packages = append(
+			statsReport.Packages,
+			&bufprint.NamedStats{
+				Name:  packageName,
+				Stats: packageStats,
+			},
+		)
+	}
+	sort.Slice(
+		statsReport.Packages,
+		func(i int, j int) bool {
+			return statsReport.Packages[i].Name < statsReport.Packages[j].Name
+		},
 	)
+	return statsReport, nil
 }
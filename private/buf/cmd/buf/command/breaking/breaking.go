@@ -22,9 +22,11 @@ import (
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
 	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/buf/bufworkspace"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
 	"github.com/bufbuild/buf/private/bufpkg/bufcheck"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
 	"github.com/bufbuild/buf/private/pkg/slicesext"
@@ -43,6 +45,7 @@ const (
 	againstConfigFlagName     = "against-config"
 	excludePathsFlagName      = "exclude-path"
 	disableSymlinksFlagName   = "disable-symlinks"
+	affectedSinceFlagName     = "affected-since"
 )
 
 // NewCommand returns a new Command.
@@ -78,6 +81,7 @@ type flags struct {
 	AgainstConfig     string
 	ExcludePaths      []string
 	DisableSymlinks   bool
+	AffectedSince     string
 	// special
 	InputHashtag string
 }
@@ -91,6 +95,7 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
 	bufcli.BindExcludePaths(flagSet, &f.ExcludePaths, excludePathsFlagName)
 	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	bufcli.BindAffectedSince(flagSet, &f.AffectedSince, affectedSinceFlagName)
 	flagSet.StringVar(
 		&f.ErrorFormat,
 		errorFormatFlagName,
@@ -153,6 +158,7 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -161,13 +167,43 @@ func run(
 	if err != nil {
 		return err
 	}
+	var targetModuleOptions []bufctl.FunctionOption
+	if flags.AffectedSince != "" {
+		// Images are compared by index, so both the input and the against input need
+		// to build images for the same affected modules.
+		workspace, err := controller.GetWorkspace(
+			ctx,
+			input,
+			bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+			bufctl.WithConfigOverride(flags.Config),
+			bufctl.WithAffectedSince(flags.AffectedSince),
+		)
+		if err != nil {
+			if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+				container.Logger().Info("no modules are affected by the changes since " + flags.AffectedSince)
+				return nil
+			}
+			return err
+		}
+		targetModuleOptions = append(
+			targetModuleOptions,
+			bufctl.WithTargetModuleOpaqueIDs(
+				slicesext.Map(bufmodule.ModuleSetTargetModules(workspace), bufmodule.Module.OpaqueID),
+			),
+		)
+	}
 	// Do not exclude imports here. bufcheck's Client requires all imports.
 	// Use bufcheck's BreakingWithExcludeImports.
 	imageWithConfigs, err := controller.GetTargetImageWithConfigs(
 		ctx,
 		input,
-		bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
-		bufctl.WithConfigOverride(flags.Config),
+		append(
+			[]bufctl.FunctionOption{
+				bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+				bufctl.WithConfigOverride(flags.Config),
+			},
+			targetModuleOptions...,
+		)...,
 	)
 	if err != nil {
 		return err
@@ -186,8 +222,13 @@ func run(
 	againstImageWithConfigs, err := controller.GetTargetImageWithConfigs(
 		ctx,
 		flags.Against,
-		bufctl.WithTargetPaths(externalPaths, flags.ExcludePaths),
-		bufctl.WithConfigOverride(flags.AgainstConfig),
+		append(
+			[]bufctl.FunctionOption{
+				bufctl.WithTargetPaths(externalPaths, flags.ExcludePaths),
+				bufctl.WithConfigOverride(flags.AgainstConfig),
+			},
+			targetModuleOptions...,
+		)...,
 	)
 	if err != nil {
 		return err
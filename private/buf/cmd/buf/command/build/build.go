@@ -16,11 +16,13 @@ package build
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
 	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/buf/bufworkspace"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimageutil"
 	"github.com/bufbuild/buf/private/pkg/app"
@@ -43,6 +45,7 @@ const (
 	excludePathsFlagName                  = "exclude-path"
 	disableSymlinksFlagName               = "disable-symlinks"
 	typeFlagName                          = "type"
+	affectedSinceFlagName                 = "affected-since"
 )
 
 // NewCommand returns a new Command.
@@ -77,6 +80,7 @@ type flags struct {
 	ExcludePaths                  []string
 	DisableSymlinks               bool
 	Types                         []string
+	AffectedSince                 string
 	// special
 	InputHashtag string
 }
@@ -93,6 +97,7 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 	bufcli.BindPaths(flagSet, &f.Paths, pathsFlagName)
 	bufcli.BindExcludePaths(flagSet, &f.ExcludePaths, excludePathsFlagName)
 	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	bufcli.BindAffectedSince(flagSet, &f.AffectedSince, affectedSinceFlagName)
 	flagSet.BoolVar(
 		&f.ExcludeSourceRetentionOptions,
 		excludeSourceRetentionOptionsFlagName,
@@ -145,6 +150,7 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -160,8 +166,13 @@ func run(
 		bufctl.WithImageExcludeImports(flags.ExcludeImports),
 		bufctl.WithImageTypes(flags.Types),
 		bufctl.WithConfigOverride(flags.Config),
+		bufctl.WithAffectedSince(flags.AffectedSince),
 	)
 	if err != nil {
+		if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+			container.Logger().Info("no modules are affected by the changes since " + flags.AffectedSince)
+			return nil
+		}
 		return err
 	}
 	if flags.ExcludeSourceRetentionOptions {
@@ -0,0 +1,152 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufprint"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/cache/internal"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/spf13/pflag"
+)
+
+const formatFlagName = "format"
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name,
+		Short: "List the entries in the cache",
+		Long: `List the modules, commits, compiled Wasm plugins, compiled images, and git dependencies in
+the cache, with their sizes and last access times.
+
+Last access times of modules and compiled images are recorded with a granularity of an hour.
+Commits, compiled Wasm plugins, and git dependencies are never rewritten, so their last access
+time is the time they were cached.`,
+		Args: appcmd.NoArgs,
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	Format string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		bufprint.FormatText.String(),
+		fmt.Sprintf(`The output format to use. Must be one of %s`, bufprint.AllFormatsString),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	format, err := bufprint.ParseFormat(flags.Format)
+	if err != nil {
+		return appcmd.WrapInvalidArgumentError(err)
+	}
+	cacheManager, err := bufcli.NewCacheManager(container)
+	if err != nil {
+		return err
+	}
+	cacheEntries, err := cacheManager.ListCacheEntries(ctx)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case bufprint.FormatText:
+		var totalSize int64
+		if err := bufprint.WithTabWriter(
+			container.Stdout(),
+			[]string{"KIND", "NAME", "SIZE", "LAST ACCESS"},
+			func(tabWriter bufprint.TabWriter) error {
+				for _, cacheEntry := range cacheEntries {
+					totalSize += cacheEntry.Size()
+					if err := tabWriter.Write(
+						cacheEntry.Kind(),
+						cacheEntry.Name(),
+						internal.FormatByteSize(cacheEntry.Size()),
+						internal.FormatLastAccessTime(cacheEntry.LastAccessTime()),
+					); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(
+			container.Stdout(),
+			"\n%d entries, %s total\n",
+			len(cacheEntries),
+			internal.FormatByteSize(totalSize),
+		)
+		return err
+	case bufprint.FormatJSON:
+		for _, cacheEntry := range cacheEntries {
+			externalCacheEntry := externalCacheEntry{
+				Kind: cacheEntry.Kind(),
+				Name: cacheEntry.Name(),
+				Size: cacheEntry.Size(),
+			}
+			if lastAccessTime := cacheEntry.LastAccessTime(); !lastAccessTime.IsZero() {
+				externalCacheEntry.LastAccessTime = lastAccessTime.UTC().Format(time.RFC3339)
+			}
+			data, err := json.Marshal(externalCacheEntry)
+			if err != nil {
+				return err
+			}
+			if _, err := container.Stdout().Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return syserror.Newf("unknown format: %v", format)
+	}
+}
+
+type externalCacheEntry struct {
+	Kind           string `json:"kind"`
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	LastAccessTime string `json:"last_access_time,omitempty"`
+}
@@ -0,0 +1,215 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cacheprune
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/cache/internal"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/spf13/pflag"
+)
+
+const (
+	olderThanFlagName = "older-than"
+	maxSizeFlagName   = "max-size"
+	dryRunFlagName    = "dry-run"
+)
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name,
+		Short: "Evict the oldest entries from the cache",
+		Long: `Evict entries from the cache by age.
+
+The age of modules and compiled images is the time since they were last used, which is recorded
+with a granularity of an hour. Commits, compiled Wasm plugins, and git dependencies are never
+rewritten, and their age is the time since they were cached. Entries whose age is unknown are
+never evicted by --older-than, and are evicted last by --max-size.
+
+If --older-than is set, every entry older than the given age is evicted. If --max-size is set,
+the oldest entries are then evicted until the total size of the cache is at most the given size.
+At least one of the two flags must be set.
+
+For example:
+
+    $ buf cache prune --older-than 30d --max-size 5GB
+
+It is safe to prune the cache while other buf processes are using it. Evicted entries are
+downloaded or compiled again the next time they are needed.`,
+		Args: appcmd.NoArgs,
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	OlderThan string
+	MaxSize   string
+	DryRun    bool
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&f.OlderThan,
+		olderThanFlagName,
+		"",
+		`Evict entries older than this age, such as 30d, 2w, or 12h`,
+	)
+	flagSet.StringVar(
+		&f.MaxSize,
+		maxSizeFlagName,
+		"",
+		`Evict the oldest entries until the cache is at most this size, such as 500MB or 5GB`,
+	)
+	flagSet.BoolVar(
+		&f.DryRun,
+		dryRunFlagName,
+		false,
+		`Print the entries that would be evicted without evicting them`,
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	if flags.OlderThan == "" && flags.MaxSize == "" {
+		return appcmd.NewInvalidArgumentErrorf("at least one of --%s or --%s must be set", olderThanFlagName, maxSizeFlagName)
+	}
+	var olderThan time.Duration
+	if flags.OlderThan != "" {
+		var err error
+		olderThan, err = internal.ParseAge(flags.OlderThan)
+		if err != nil {
+			return appcmd.WrapInvalidArgumentError(err)
+		}
+	}
+	maxSize := int64(-1)
+	if flags.MaxSize != "" {
+		var err error
+		maxSize, err = internal.ParseByteSize(flags.MaxSize)
+		if err != nil {
+			return appcmd.WrapInvalidArgumentError(err)
+		}
+	}
+	cacheManager, err := bufcli.NewCacheManager(container)
+	if err != nil {
+		return err
+	}
+	cacheEntries, err := cacheManager.ListCacheEntries(ctx)
+	if err != nil {
+		return err
+	}
+	evictCacheEntries := getCacheEntriesToEvict(cacheEntries, time.Now(), olderThan, maxSize)
+	var evictedSize int64
+	for _, cacheEntry := range evictCacheEntries {
+		if !flags.DryRun {
+			if err := cacheManager.DeleteCacheEntry(ctx, cacheEntry); err != nil {
+				return err
+			}
+		}
+		evictedSize += cacheEntry.Size()
+		if _, err := fmt.Fprintf(
+			container.Stderr(),
+			"%s %s %s (%s)\n",
+			evictedVerb(flags.DryRun),
+			cacheEntry.Kind(),
+			cacheEntry.Name(),
+			internal.FormatByteSize(cacheEntry.Size()),
+		); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(
+		container.Stderr(),
+		"%s %d entries, %s\n",
+		evictedVerb(flags.DryRun),
+		len(evictCacheEntries),
+		internal.FormatByteSize(evictedSize),
+	)
+	return err
+}
+
+// getCacheEntriesToEvict returns the entries to evict, oldest first.
+//
+// If olderThan is non-zero, all entries last accessed before now minus olderThan are evicted.
+// If maxSize is non-negative, the oldest remaining entries are then evicted until the total
+// size of the remaining entries is at most maxSize.
+//
+// Entries with an unknown last access time are never considered too old, and are sorted
+// after all other entries.
+func getCacheEntriesToEvict(
+	cacheEntries []bufcli.CacheEntry,
+	now time.Time,
+	olderThan time.Duration,
+	maxSize int64,
+) []bufcli.CacheEntry {
+	cacheEntries = append([]bufcli.CacheEntry{}, cacheEntries...)
+	sort.SliceStable(
+		cacheEntries,
+		func(i int, j int) bool {
+			one := cacheEntries[i].LastAccessTime()
+			two := cacheEntries[j].LastAccessTime()
+			if one.IsZero() || two.IsZero() {
+				return !one.IsZero() && two.IsZero()
+			}
+			return one.Before(two)
+		},
+	)
+	var totalSize int64
+	for _, cacheEntry := range cacheEntries {
+		totalSize += cacheEntry.Size()
+	}
+	var evictCacheEntries []bufcli.CacheEntry
+	for _, cacheEntry := range cacheEntries {
+		lastAccessTime := cacheEntry.LastAccessTime()
+		tooOld := olderThan > 0 && !lastAccessTime.IsZero() && lastAccessTime.Before(now.Add(-olderThan))
+		tooLarge := maxSize >= 0 && totalSize > maxSize
+		if !tooOld && !tooLarge {
+			// Entries are sorted by last access time, so no further entries are too old.
+			break
+		}
+		evictCacheEntries = append(evictCacheEntries, cacheEntry)
+		totalSize -= cacheEntry.Size()
+	}
+	return evictCacheEntries
+}
+
+func evictedVerb(dryRun bool) string {
+	if dryRun {
+		return "would evict"
+	}
+	return "evicted"
+}
@@ -0,0 +1,87 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cacheprune
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCacheEntriesToEvict(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cacheEntries := []bufcli.CacheEntry{
+		newTestCacheEntry("unknown", 100, time.Time{}),
+		newTestCacheEntry("new", 10, now.Add(-time.Hour)),
+		newTestCacheEntry("old", 10, now.Add(-48*time.Hour)),
+		newTestCacheEntry("older", 10, now.Add(-72*time.Hour)),
+	}
+	testGetCacheEntriesToEvict(t, cacheEntries, now, 24*time.Hour, -1, "older", "old")
+	// Entries with an unknown last access time are evicted last.
+	testGetCacheEntriesToEvict(t, cacheEntries, now, 0, 100, "older", "old", "new")
+	testGetCacheEntriesToEvict(t, cacheEntries, now, 0, 10, "older", "old", "new", "unknown")
+	require.Empty(t, getCacheEntriesToEvict(cacheEntries, now, 0, 1000))
+}
+
+func testGetCacheEntriesToEvict(
+	t *testing.T,
+	cacheEntries []bufcli.CacheEntry,
+	now time.Time,
+	olderThan time.Duration,
+	maxSize int64,
+	expectedNames ...string,
+) {
+	require.Equal(
+		t,
+		expectedNames,
+		slicesext.Map(
+			getCacheEntriesToEvict(cacheEntries, now, olderThan, maxSize),
+			bufcli.CacheEntry.Name,
+		),
+	)
+}
+
+type testCacheEntry struct {
+	// Embedded to implement the unexported methods of bufcli.CacheEntry.
+	bufcli.CacheEntry
+
+	name           string
+	size           int64
+	lastAccessTime time.Time
+}
+
+func newTestCacheEntry(name string, size int64, lastAccessTime time.Time) *testCacheEntry {
+	return &testCacheEntry{
+		name:           name,
+		size:           size,
+		lastAccessTime: lastAccessTime,
+	}
+}
+
+func (e *testCacheEntry) Name() string {
+	return e.name
+}
+
+func (e *testCacheEntry) Size() int64 {
+	return e.size
+}
+
+func (e *testCacheEntry) LastAccessTime() time.Time {
+	return e.lastAccessTime
+}
@@ -0,0 +1,113 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cacheverify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/spf13/pflag"
+)
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name,
+		Short: "Verify the entries in the cache and evict corrupt entries",
+		Long: `Verify every module, commit, and compiled image in the cache, and evict the entries that
+are corrupt.
+
+Modules that were not completely written to the cache are evicted. The files of every other
+cached module are digested again and compared against the digest of its cached commit, and the
+module is skipped if its commit is not cached. The files of every compiled image
+are digested again and compared against the digest they were cached with. Compiled Wasm plugins
+are validated by the Wasm runtime when they are loaded, and git dependencies are verified against
+the digest in buf.lock when they are used, so both are skipped.
+
+Evicted entries are downloaded or compiled again the next time they are needed.`,
+		Args: appcmd.NoArgs,
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct{}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	cacheManager, err := bufcli.NewCacheManager(container)
+	if err != nil {
+		return err
+	}
+	cacheEntries, err := cacheManager.ListCacheEntries(ctx)
+	if err != nil {
+		return err
+	}
+	var numVerified, numEvicted, numSkipped int
+	for _, cacheEntry := range cacheEntries {
+		verifyErr := cacheManager.VerifyCacheEntry(ctx, cacheEntry)
+		switch {
+		case verifyErr == nil:
+			numVerified++
+		case errors.Is(verifyErr, bufcli.ErrCacheEntryNotVerifiable):
+			numSkipped++
+		case errors.Is(verifyErr, bufcli.ErrCacheEntryCorrupt):
+			if err := cacheManager.DeleteCacheEntry(ctx, cacheEntry); err != nil {
+				return err
+			}
+			numEvicted++
+			if _, err := fmt.Fprintf(
+				container.Stderr(),
+				"evicted corrupt %s %s: %v\n",
+				cacheEntry.Kind(),
+				cacheEntry.Name(),
+				verifyErr,
+			); err != nil {
+				return err
+			}
+		default:
+			return verifyErr
+		}
+	}
+	_, err = fmt.Fprintf(
+		container.Stderr(),
+		"verified %d entries, evicted %d corrupt entries, skipped %d entries that cannot be verified\n",
+		numVerified,
+		numEvicted,
+		numSkipped,
+	)
+	return err
+}
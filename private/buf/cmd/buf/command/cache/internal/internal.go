@@ -0,0 +1,113 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Ordered by longest suffix first, so that "KB" is not matched as "B".
+	byteSizeSuffixes = []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10},
+		{"MiB", 1 << 20},
+		{"GiB", 1 << 30},
+		{"TiB", 1 << 40},
+		{"KB", 1000},
+		{"MB", 1000 * 1000},
+		{"GB", 1000 * 1000 * 1000},
+		{"TB", 1000 * 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+	formatByteSizeUnits = []string{"KB", "MB", "GB", "TB"}
+)
+
+// ParseByteSize parses a size such as "500MB", "5GB", or "1.5GiB" into a number of bytes.
+//
+// KB, MB, GB, and TB are powers of 1000, and KiB, MiB, GiB, and TiB are powers of 1024.
+// A number without a suffix is a number of bytes. Suffixes are case-insensitive.
+func ParseByteSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	multiplier := int64(1)
+	for _, byteSizeSuffix := range byteSizeSuffixes {
+		if len(trimmed) > len(byteSizeSuffix.suffix) &&
+			strings.EqualFold(trimmed[len(trimmed)-len(byteSizeSuffix.suffix):], byteSizeSuffix.suffix) {
+			trimmed = strings.TrimSpace(trimmed[:len(trimmed)-len(byteSizeSuffix.suffix)])
+			multiplier = byteSizeSuffix.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q, must be a non-negative number with an optional unit such as 500MB or 5GB", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatByteSize formats a number of bytes for display, using powers of 1000.
+func FormatByteSize(size int64) string {
+	if size < 1000 {
+		return strconv.FormatInt(size, 10) + "B"
+	}
+	value := float64(size)
+	var unit string
+	for _, formatByteSizeUnit := range formatByteSizeUnits {
+		value /= 1000
+		unit = formatByteSizeUnit
+		if value < 1000 {
+			break
+		}
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + unit
+}
+
+// ParseAge parses an age such as "30d", "2w", or "12h".
+//
+// In addition to the units accepted by time.ParseDuration, "d" (24 hours) and "w" (7 days)
+// are accepted, as long as they are the only unit.
+func ParseAge(s string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	} {
+		if number, ok := strings.CutSuffix(trimmed, suffix); ok {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid age %q, must be a non-negative duration such as 30d or 12h", s)
+			}
+			return time.Duration(value * float64(unit)), nil
+		}
+	}
+	duration, err := time.ParseDuration(trimmed)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %q, must be a non-negative duration such as 30d or 12h", s)
+	}
+	return duration, nil
+}
+
+// FormatLastAccessTime formats a last access time for display.
+func FormatLastAccessTime(lastAccessTime time.Time) string {
+	if lastAccessTime.IsZero() {
+		return "unknown"
+	}
+	return lastAccessTime.Local().Format(time.DateTime)
+}
@@ -0,0 +1,71 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+	testParseByteSize(t, "0", 0)
+	testParseByteSize(t, "100", 100)
+	testParseByteSize(t, "100B", 100)
+	testParseByteSize(t, "500MB", 500*1000*1000)
+	testParseByteSize(t, "5GB", 5*1000*1000*1000)
+	testParseByteSize(t, "5gb", 5*1000*1000*1000)
+	testParseByteSize(t, "1.5GiB", 3<<29)
+	testParseByteSize(t, "2 KiB", 2048)
+	for _, invalid := range []string{"", "GB", "-1GB", "5XB", "five"} {
+		_, err := ParseByteSize(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "0B", FormatByteSize(0))
+	require.Equal(t, "999B", FormatByteSize(999))
+	require.Equal(t, "1.5KB", FormatByteSize(1500))
+	require.Equal(t, "5.0GB", FormatByteSize(5*1000*1000*1000))
+	require.Equal(t, "2000.0TB", FormatByteSize(2*1000*1000*1000*1000*1000))
+}
+
+func TestParseAge(t *testing.T) {
+	t.Parallel()
+	testParseAge(t, "30d", 30*24*time.Hour)
+	testParseAge(t, "2w", 14*24*time.Hour)
+	testParseAge(t, "12h", 12*time.Hour)
+	testParseAge(t, "1h30m", 90*time.Minute)
+	for _, invalid := range []string{"", "d", "-1d", "30", "thirty days"} {
+		_, err := ParseAge(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func testParseByteSize(t *testing.T, s string, expected int64) {
+	actual, err := ParseByteSize(s)
+	require.NoError(t, err, s)
+	require.Equal(t, expected, actual, s)
+}
+
+func testParseAge(t *testing.T, s string, expected time.Duration) {
+	actual, err := ParseAge(s)
+	require.NoError(t, err, s)
+	require.Equal(t, expected, actual, s)
+}
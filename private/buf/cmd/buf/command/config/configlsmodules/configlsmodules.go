@@ -23,7 +23,10 @@ import (
 	"sort"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/bufworkspace"
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
@@ -35,8 +38,9 @@ import (
 )
 
 const (
-	configFlagName = "config"
-	formatFlagName = "format"
+	configFlagName        = "config"
+	formatFlagName        = "format"
+	affectedSinceFlagName = "affected-since"
 
 	formatPath = "path"
 	formatName = "name"
@@ -73,8 +77,9 @@ func NewCommand(
 }
 
 type flags struct {
-	Config string
-	Format string
+	Config        string
+	Format        string
+	AffectedSince string
 }
 
 func newFlags() *flags {
@@ -97,6 +102,7 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 			stringutil.SliceToString(allFormats),
 		),
 	)
+	bufcli.BindAffectedSince(flagSet, &f.AffectedSince, affectedSinceFlagName)
 }
 
 func run(
@@ -108,6 +114,12 @@ func run(
 	if err != nil {
 		return err
 	}
+	if flags.AffectedSince != "" {
+		externalModules, err = getAffectedExternalModules(ctx, container, flags, externalModules)
+		if err != nil {
+			return err
+		}
+	}
 	return printExternalModules(ctx, container, flags.Format, externalModules)
 }
 
@@ -163,6 +175,45 @@ func getExternalModules(
 	return getExternalModulesForBufWorkYAMLFile(ctx, bufWorkYAMLFile)
 }
 
+// getAffectedExternalModules filters the externalModules to the modules affected by the
+// changes since flags.AffectedSince.
+//
+// This preserves the order of externalModules.
+func getAffectedExternalModules(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+	externalModules []*externalModule,
+) ([]*externalModule, error) {
+	controller, err := bufcli.NewController(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := controller.GetWorkspace(
+		ctx,
+		".",
+		bufctl.WithConfigOverride(flags.Config),
+		bufctl.WithAffectedSince(flags.AffectedSince),
+	)
+	if err != nil {
+		if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	affectedModuleDirPaths := make(map[string]struct{})
+	for _, module := range bufmodule.ModuleSetTargetModules(workspace) {
+		affectedModuleDirPaths[workspace.GetModuleDirPathForOpaqueID(module.OpaqueID())] = struct{}{}
+	}
+	return slicesext.Filter(
+		externalModules,
+		func(externalModule *externalModule) bool {
+			_, ok := affectedModuleDirPaths[externalModule.Path]
+			return ok
+		},
+	), nil
+}
+
 // This preserves directory order from the bufWorkYAMLFile.
 func getExternalModulesForBufWorkYAMLFile(
 	ctx context.Context,
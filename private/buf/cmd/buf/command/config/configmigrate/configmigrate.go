@@ -108,11 +108,11 @@ func run(
 	container appext.Container,
 	flags *flags,
 ) error {
-	moduleKeyProvider, err := bufcli.NewModuleKeyProvider(container)
+	moduleKeyProvider, err := bufcli.NewModuleKeyProvider(ctx, container)
 	if err != nil {
 		return err
 	}
-	commitProvider, err := bufcli.NewCommitProvider(container)
+	commitProvider, err := bufcli.NewCommitProvider(ctx, container)
 	if err != nil {
 		return err
 	}
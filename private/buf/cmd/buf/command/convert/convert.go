@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
@@ -41,7 +42,13 @@ const (
 	fromFlagName            = "from"
 	toFlagName              = "to"
 	validateFlagName        = "validate"
+	guessFlagName           = "guess"
 	disableSymlinksFlagName = "disable-symlinks"
+
+	// rawTypeName is the type name given with --type to decode a message without a schema.
+	rawTypeName = "_raw"
+	// maxTypeGuesses is the maximum number of message types printed with --guess.
+	maxTypeGuesses = 10
 )
 
 // NewCommand returns a new Command.
@@ -83,6 +90,26 @@ Redirect from stdin to <input>:
 Use a module on the bsr:
 
     $ buf convert <buf.build/owner/repository> --type buf.Foo --from=payload.json
+
+Streams of messages are converted one record at a time with the binpb-delimited format, where
+each message is prefixed by its length as a varint, and the jsonl format, where each message
+is on its own line. Files with the .jsonl or .ndjson extension default to the jsonl format:
+
+    $ buf convert buf.proto --type buf.Foo --from=dump.binpb#format=binpb-delimited --to=dump.jsonl
+
+Records that cannot be converted, for example because they fail validation with --validate,
+are printed to stderr with their index and byte offset and skipped, and the command fails
+once the stream has been converted.
+
+Use --type=_raw to print the fields of a binary message without a schema, as their field
+numbers and wire format values, like protoc --decode_raw:
+
+    $ buf convert --type=_raw --from=payload.binpb
+
+Use --guess to print the message types of <input> that a binary message may be of, ranked
+by how many of its bytes are fields of the message type:
+
+    $ buf convert <input> --guess --from=payload.binpb
 `,
 		Args: appcmd.MaximumNArgs(1),
 		Run: builder.NewRunFunc(
@@ -100,6 +127,7 @@ type flags struct {
 	From            string
 	To              string
 	Validate        bool
+	Guess           bool
 	DisableSymlinks bool
 
 	// special
@@ -126,15 +154,19 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 		&f.Type,
 		typeFlagName,
 		"",
-		`The full type name of the message within the input (e.g. acme.weather.v1.Units)`,
+		fmt.Sprintf(
+			`The full type name of the message within the input (e.g. acme.weather.v1.Units), or %s to print the fields of a binary message without a schema`,
+			rawTypeName,
+		),
 	)
 	flagSet.StringVar(
 		&f.From,
 		fromFlagName,
 		"-",
 		fmt.Sprintf(
-			`The location of the payload to be converted. Supported formats are %s`,
+			`The location of the payload to be converted. Supported formats are %s, and %s for streams of messages`,
 			buffetch.MessageFormatsString,
+			buffetch.MessageStreamFormatsString,
 		),
 	)
 	flagSet.StringVar(
@@ -142,8 +174,9 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 		toFlagName,
 		"-",
 		fmt.Sprintf(
-			`The output location of the conversion. Supported formats are %s`,
+			`The output location of the conversion. Supported formats are %s, and %s for streams of messages`,
 			buffetch.MessageFormatsString,
+			buffetch.MessageStreamFormatsString,
 		),
 	)
 	flagSet.BoolVar(
@@ -155,6 +188,15 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 			fromFlagName,
 		),
 	)
+	flagSet.BoolVar(
+		&f.Guess,
+		guessFlagName,
+		false,
+		fmt.Sprintf(
+			`Print the message types of the input that the binary message specified with --%s may be of, instead of converting it`,
+			fromFlagName,
+		),
+	)
 }
 
 func run(
@@ -167,6 +209,7 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -174,10 +217,38 @@ func run(
 	if err != nil {
 		return err
 	}
+	if flags.Type == rawTypeName || flags.Guess {
+		if flags.Guess && flags.Type != "" {
+			return appcmd.NewInvalidArgumentErrorf("--%s cannot be used with --%s", guessFlagName, typeFlagName)
+		}
+		if flags.To != "-" {
+			return appcmd.NewInvalidArgumentErrorf("--%s cannot be used with --%s=%s or --%s", toFlagName, typeFlagName, rawTypeName, guessFlagName)
+		}
+		if flags.Validate {
+			return appcmd.NewInvalidArgumentErrorf("--%s cannot be used with --%s=%s or --%s", validateFlagName, typeFlagName, rawTypeName, guessFlagName)
+		}
+	}
+	if flags.Type == rawTypeName {
+		// No schema is needed.
+		data, err := controller.GetMessageData(ctx, flags.From)
+		if err != nil {
+			return fmt.Errorf("--%s: %w", fromFlagName, err)
+		}
+		if err := bufconvert.WriteRawMessage(container.Stdout(), data); err != nil {
+			return fmt.Errorf("--%s: %w", fromFlagName, err)
+		}
+		return nil
+	}
 	schemaImage, schemaImageErr := controller.GetImage(
 		ctx,
 		input,
 	)
+	if flags.Guess {
+		if schemaImageErr != nil {
+			return schemaImageErr
+		}
+		return guessMessageTypes(ctx, container, controller, schemaImage, flags)
+	}
 	var resolveWellKnownType bool
 	// only resolve wkts if input was not set.
 	if container.NumArgs() == 0 {
@@ -215,6 +286,13 @@ func run(
 	if flags.Validate {
 		fromFunctionOptions = append(fromFunctionOptions, bufctl.WithMessageValidation())
 	}
+	fromMessageEncoding, err := getMessageEncoding(ctx, container, flags.From, buffetch.MessageEncodingBinpb)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", fromFlagName, err)
+	}
+	if isMessageStreamEncoding(fromMessageEncoding) {
+		return convertMessageStream(ctx, container, controller, schemaImage, flags, fromFunctionOptions)
+	}
 	fromMessage, fromMessageEncoding, err := controller.GetMessage(
 		ctx,
 		schemaImage,
@@ -230,6 +308,26 @@ func run(
 	if err != nil {
 		return err
 	}
+	toMessageEncoding, err := getMessageEncoding(ctx, container, flags.To, defaultToMessageEncoding)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", toFlagName, err)
+	}
+	if isMessageStreamEncoding(toMessageEncoding) {
+		// The message is written as a stream of a single record.
+		messageWriter, err := controller.GetMessageWriter(
+			ctx,
+			schemaImage,
+			flags.To,
+			defaultToMessageEncoding,
+		)
+		if err != nil {
+			return fmt.Errorf("--%s: %w", toFlagName, err)
+		}
+		if err := errors.Join(messageWriter.Write(fromMessage), messageWriter.Close()); err != nil {
+			return fmt.Errorf("--%s: %w", toFlagName, err)
+		}
+		return nil
+	}
 	if err := controller.PutMessage(
 		ctx,
 		schemaImage,
@@ -242,6 +340,153 @@ func run(
 	return nil
 }
 
+// convertMessageStream converts the records of the message stream given with --from to
+// the message stream given with --to, one record at a time.
+//
+// Records that cannot be converted are printed to stderr and skipped.
+func convertMessageStream(
+	ctx context.Context,
+	container appext.Container,
+	controller bufctl.Controller,
+	schemaImage bufimage.Image,
+	flags *flags,
+	fromFunctionOptions []bufctl.FunctionOption,
+) (retErr error) {
+	messageReader, err := controller.GetMessageReader(
+		ctx,
+		schemaImage,
+		flags.From,
+		flags.Type,
+		buffetch.MessageEncodingBinpb,
+		fromFunctionOptions...,
+	)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", fromFlagName, err)
+	}
+	defer func() {
+		retErr = errors.Join(retErr, messageReader.Close())
+	}()
+	defaultToMessageEncoding, err := inverseEncoding(messageReader.MessageEncoding())
+	if err != nil {
+		return err
+	}
+	toMessageEncoding, err := getMessageEncoding(ctx, container, flags.To, defaultToMessageEncoding)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", toFlagName, err)
+	}
+	if !isMessageStreamEncoding(toMessageEncoding) {
+		return fmt.Errorf(
+			"--%s: must be one of format %s when --%s is a stream of messages",
+			toFlagName,
+			buffetch.MessageStreamFormatsString,
+			fromFlagName,
+		)
+	}
+	messageWriter, err := controller.GetMessageWriter(
+		ctx,
+		schemaImage,
+		flags.To,
+		defaultToMessageEncoding,
+	)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", toFlagName, err)
+	}
+	defer func() {
+		if err := messageWriter.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("--%s: %w", toFlagName, err))
+		}
+	}()
+	var numRecords int
+	var numFailedRecords int
+	for {
+		message, err := messageReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recordError *bufctl.RecordError
+		if errors.As(err, &recordError) {
+			numRecords++
+			numFailedRecords++
+			if _, err := fmt.Fprintf(container.Stderr(), "--%s: %v\n", fromFlagName, recordError); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("--%s: %w", fromFlagName, err)
+		}
+		numRecords++
+		if err := messageWriter.Write(message); err != nil {
+			return fmt.Errorf("--%s: %w", toFlagName, err)
+		}
+	}
+	if numFailedRecords > 0 {
+		return fmt.Errorf("--%s: %d of %d records could not be converted", fromFlagName, numFailedRecords, numRecords)
+	}
+	return nil
+}
+
+// guessMessageTypes prints the message types of the schema image that the message given
+// with --from may be of.
+func guessMessageTypes(
+	ctx context.Context,
+	container appext.Container,
+	controller bufctl.Controller,
+	schemaImage bufimage.Image,
+	flags *flags,
+) error {
+	data, err := controller.GetMessageData(ctx, flags.From)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", fromFlagName, err)
+	}
+	typeGuesses := bufconvert.GuessMessageTypes(
+		// Message types that use the message-set wire format are not guessed, as they cannot
+		// be unmarshaled.
+		bufconvert.ImageWithoutMessageSetWireFormatResolution(schemaImage),
+		data,
+	)
+	if len(typeGuesses) == 0 {
+		return fmt.Errorf("--%s: no message type in the input matches the message", fromFlagName)
+	}
+	if len(typeGuesses) > maxTypeGuesses {
+		typeGuesses = typeGuesses[:maxTypeGuesses]
+	}
+	for _, typeGuess := range typeGuesses {
+		if _, err := fmt.Fprintf(
+			container.Stdout(),
+			"%5.1f%%  %s\n",
+			100*float64(typeGuess.RecognizedBytes)/float64(typeGuess.TotalBytes),
+			typeGuess.Name,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getMessageEncoding returns the MessageEncoding of the message input or output.
+func getMessageEncoding(
+	ctx context.Context,
+	container appext.Container,
+	value string,
+	defaultMessageEncoding buffetch.MessageEncoding,
+) (buffetch.MessageEncoding, error) {
+	messageRef, err := buffetch.NewMessageRefParser(
+		container.Logger(),
+		buffetch.MessageRefParserWithDefaultMessageEncoding(defaultMessageEncoding),
+		buffetch.MessageRefParserWithMessageStreams(),
+	).GetMessageRef(ctx, value)
+	if err != nil {
+		return 0, err
+	}
+	return messageRef.MessageEncoding(), nil
+}
+
+// isMessageStreamEncoding returns true if the encoding is for streams of messages.
+func isMessageStreamEncoding(encoding buffetch.MessageEncoding) bool {
+	return encoding == buffetch.MessageEncodingBinpbDelimited || encoding == buffetch.MessageEncodingJSONL
+}
+
 // inverseEncoding returns the opposite encoding of the provided encoding,
 // which will be the default output encoding for a given payload encoding.
 func inverseEncoding(encoding buffetch.MessageEncoding) (buffetch.MessageEncoding, error) {
@@ -254,6 +499,10 @@ func inverseEncoding(encoding buffetch.MessageEncoding) (buffetch.MessageEncodin
 		return buffetch.MessageEncodingBinpb, nil
 	case buffetch.MessageEncodingYAML:
 		return buffetch.MessageEncodingBinpb, nil
+	case buffetch.MessageEncodingBinpbDelimited:
+		return buffetch.MessageEncodingJSONL, nil
+	case buffetch.MessageEncodingJSONL:
+		return buffetch.MessageEncodingBinpbDelimited, nil
 	default:
 		return 0, fmt.Errorf("unknown message encoding %v", encoding)
 	}
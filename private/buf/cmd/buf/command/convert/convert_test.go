@@ -400,6 +400,179 @@ func TestConvertWKTImport(t *testing.T) {
 	)
 }
 
+func TestConvertJSONLToBinpbDelimited(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		"\x02\x08\x37\x02\x08\x38",
+		nil,
+		nil,
+		"--type",
+		"buf.Foo",
+		"--from",
+		"testdata/convert/bin_json/payload.jsonl",
+	)
+}
+
+func TestConvertBinpbDelimitedToJSONL(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		`{"one":"55"}
+{"one":"56"}`,
+		nil,
+		nil,
+		"--type",
+		"buf.Foo",
+		"--from",
+		"testdata/convert/bin_json/payload.delimited.binpb#format=binpb-delimited",
+	)
+}
+
+func TestConvertJSONToJSONL(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		`{"one":"55"}`,
+		nil,
+		nil,
+		"--type",
+		"buf.Foo",
+		"--from",
+		"testdata/convert/bin_json/payload.json",
+		"--to",
+		"-#format=jsonl",
+	)
+}
+
+func TestConvertJSONLInvalidRecord(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		testNewCommand,
+		1,
+		[]string{
+			`--from: record 2 at offset 13:`,
+			`invalid value for int64 field one: "x"`,
+			`--from: 1 of 3 records could not be converted`,
+		},
+		nil,
+		strings.NewReader("{\"one\":\"55\"}\n{\"one\":\"x\"}\n{\"one\":\"56\"}\n"),
+		"--type",
+		"buf.Foo",
+		"--from",
+		"-#format=jsonl",
+	)
+}
+
+func TestConvertBinpbDelimitedTruncated(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderr(
+		t,
+		testNewCommand,
+		1,
+		`--from: record 2 at offset 3: read 1 of 2 bytes: unexpected EOF`,
+		nil,
+		strings.NewReader("\x02\x08\x37\x02\x08"),
+		"--type",
+		"buf.Foo",
+		"--from",
+		"-#format=binpb-delimited",
+		"--to",
+		"/dev/null",
+	)
+}
+
+func TestConvertBinpbDelimitedCorruptLength(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderr(
+		t,
+		testNewCommand,
+		1,
+		`--from: record 1 at offset 0: read 2 of 2147483647 bytes: unexpected EOF`,
+		nil,
+		strings.NewReader("\xff\xff\xff\xff\x07\x08\x37"),
+		"--type",
+		"buf.Foo",
+		"--from",
+		"-#format=binpb-delimited",
+		"--to",
+		"/dev/null",
+	)
+}
+
+func TestConvertJSONLToSingleMessage(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderr(
+		t,
+		testNewCommand,
+		1,
+		`--to: must be one of format [binpb-delimited,jsonl] when --from is a stream of messages`,
+		nil,
+		nil,
+		"--type",
+		"buf.Foo",
+		"--from",
+		"testdata/convert/bin_json/payload.jsonl",
+		"--to",
+		"-#format=json",
+	)
+}
+
+func TestConvertRaw(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		`1: 55`,
+		nil,
+		nil,
+		"--type",
+		"_raw",
+		"--from",
+		"testdata/convert/bin_json/payload.binpb",
+	)
+}
+
+func TestConvertRawNotBinary(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStderr(
+		t,
+		testNewCommand,
+		1,
+		`--from: "testdata/convert/bin_json/payload.json" must be of format binpb to be read without a schema`,
+		nil,
+		nil,
+		"--type",
+		"_raw",
+		"--from",
+		"testdata/convert/bin_json/payload.json",
+	)
+}
+
+func TestConvertGuess(t *testing.T) {
+	t.Parallel()
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		testNewCommand,
+		0,
+		`100.0%  buf.Foo`,
+		nil,
+		nil,
+		"testdata/convert/bin_json/buf.proto",
+		"--guess",
+		"--from",
+		"testdata/convert/bin_json/payload.binpb",
+	)
+}
+
 func testNewCommand(use string) *appcmd.Command {
 	return NewCommand("convert", appext.NewBuilder("convert"))
 }
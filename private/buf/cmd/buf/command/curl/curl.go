@@ -1014,7 +1014,7 @@ func run(ctx context.Context, container appext.Container, f *flags) (err error)
 		defer closeRes()
 		resolvers = append(resolvers, res)
 	}
-	controller, err := bufcli.NewController(container)
+	controller, err := bufcli.NewController(ctx, container)
 	if err != nil {
 		return err
 	}
@@ -17,18 +17,24 @@ package depgraph
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
+	"sort"
+	"strings"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/internal"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
 	"github.com/bufbuild/buf/private/pkg/dag"
 	"github.com/bufbuild/buf/private/pkg/slicesext"
 	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/bufbuild/buf/private/pkg/syserror"
 	"github.com/bufbuild/buf/private/pkg/uuidutil"
 	"github.com/google/uuid"
 	"github.com/spf13/pflag"
@@ -38,15 +44,34 @@ const (
 	errorFormatFlagName     = "error-format"
 	disableSymlinksFlagName = "disable-symlinks"
 	formatFlagName          = "format"
+	levelFlagName           = "level"
 
-	dotFormatString  = "dot"
-	jsonFormatString = "json"
+	dotFormatString     = "dot"
+	jsonFormatString    = "json"
+	mermaidFormatString = "mermaid"
+	graphMLFormatString = "graphml"
+	cyclesFormatString  = "cycles"
+
+	moduleLevelString  = "module"
+	packageLevelString = "package"
+	fileLevelString    = "file"
+
+	// noPackageName is the name of the node for files without a package at the package level.
+	noPackageName = "(no package)"
 )
 
 var (
 	allGraphFormatStrings = []string{
 		dotFormatString,
 		jsonFormatString,
+		mermaidFormatString,
+		graphMLFormatString,
+		cyclesFormatString,
+	}
+	allLevelStrings = []string{
+		moduleLevelString,
+		packageLevelString,
+		fileLevelString,
 	}
 )
 
@@ -82,6 +107,19 @@ brew install graphviz
 You can easily visualize a dependency graph using the dot tool:
 
 buf dep graph | dot -Tpng >| graph.png && open graph.png
+
+By default, the graph is of modules. Use --level=package or --level=file to print the import
+graph between packages or files instead. These graphs are built from the imports of the
+compiled image, and include the packages and files of dependencies.
+
+The following formats are supported:
+
+  - dot: The DOT language. Fails if the graph has a cycle.
+  - json: The nodes and their dependencies as JSON.
+  - mermaid: A Mermaid flowchart, which can be embedded in Markdown.
+  - graphml: GraphML, which can be loaded by tools such as Gephi and yEd.
+  - cycles: A summary of the graph and every cycle in it, computed as strongly connected
+    components. Only package graphs can have cycles.
 ` + bufcli.GetSourceOrModuleLong(`the source or module to print the dependency graph for`),
 		Args: appcmd.MaximumNArgs(1),
 		Run: builder.NewRunFunc(
@@ -99,6 +137,7 @@ type flags struct {
 	// special
 	InputHashtag string
 	Format       string
+	Level        string
 }
 
 func newFlags() *flags {
@@ -126,6 +165,15 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 			stringutil.SliceToString(allGraphFormatStrings),
 		),
 	)
+	flagSet.StringVar(
+		&f.Level,
+		levelFlagName,
+		moduleLevelString,
+		fmt.Sprintf(
+			"The level to print the graph at. Must be one of %s",
+			stringutil.SliceToString(allLevelStrings),
+		),
+	)
 }
 
 func run(
@@ -138,6 +186,7 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -145,64 +194,233 @@ func run(
 	if err != nil {
 		return err
 	}
-	workspace, err := controller.GetWorkspace(ctx, input)
-	if err != nil {
-		return err
+	if !slices.Contains(allGraphFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
 	}
-	graph, err := bufmodule.ModuleSetToDAG(workspace)
+	workspace, err := controller.GetWorkspace(ctx, input)
 	if err != nil {
 		return err
 	}
 	var graphString string
-	switch flags.Format {
-	case dotFormatString:
-		dotString, err := graph.DOTString(moduleToString)
+	switch flags.Level {
+	case moduleLevelString:
+		graph, err := bufmodule.ModuleSetToDAG(workspace)
 		if err != nil {
 			return err
 		}
-		graphString = dotString
-	case jsonFormatString:
-		// We traverse each module (node) in the graph and populate the deps (outbound nodes).
-		// We keep track of every module we have seen so we can update their d
-		moduleFullNameOrOpaqueIDToExternalModule := make(map[string]externalModule)
-		if err := graph.WalkNodes(
-			func(module bufmodule.Module, _ []bufmodule.Module, deps []bufmodule.Module) error {
-				moduleFullNameOrOpaqueID := moduleFullNameOrOpaqueID(module)
-				// We have already populated this node through deps, we can skip module.
-				if _, ok := moduleFullNameOrOpaqueIDToExternalModule[moduleFullNameOrOpaqueID]; ok {
-					return nil
-				}
-				// We first scaffold a module with no deps populated yet.
-				externalModule, err := externalModuleNoDepsForModule(module)
-				if err != nil {
-					return err
-				}
-				if err := externalModule.addDeps(deps, graph, moduleFullNameOrOpaqueIDToExternalModule, flags); err != nil {
-					return err
-				}
-				// Sort the deps alphabetically before adding our external module.
-				sortExternalModules(externalModule.Deps)
-				moduleFullNameOrOpaqueIDToExternalModule[moduleFullNameOrOpaqueID] = externalModule
-				return nil
-			},
-		); err != nil {
+		if flags.Format == jsonFormatString {
+			graphString, err = moduleGraphToJSONString(graph, flags)
+		} else {
+			graphString, err = graphToString(graph, flags.Format, moduleToString)
+		}
+		if err != nil {
 			return err
 		}
-		externalModules := slicesext.MapValuesToSlice(moduleFullNameOrOpaqueIDToExternalModule)
-		// Sort all modules alphabetically.
-		sortExternalModules(externalModules)
-		data, err := json.Marshal(externalModules)
+	case packageLevelString, fileLevelString:
+		image, err := controller.GetImageForWorkspace(
+			ctx,
+			workspace,
+			// This is a performance optimization - we don't need source code info.
+			bufctl.WithImageExcludeSourceInfo(true),
+		)
+		if err != nil {
+			return err
+		}
+		graph, nameToModule, err := getImportGraph(ctx, workspace, image, flags.Level)
+		if err != nil {
+			return err
+		}
+		if flags.Format == jsonFormatString {
+			graphString, err = importGraphToJSONString(graph, nameToModule)
+		} else {
+			graphString, err = graphToString(graph.Graph(), flags.Format, func(name string) string { return name })
+		}
 		if err != nil {
 			return err
 		}
-		graphString = string(data)
 	default:
-		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", levelFlagName, flags.Level)
 	}
 	_, err = fmt.Fprintln(container.Stdout(), graphString)
 	return err
 }
 
+// graphToString prints the graph in any format but JSON, which is specific to each level.
+func graphToString[Key comparable, Value any](
+	graph *dag.Graph[Key, Value],
+	format string,
+	valueToString func(Value) string,
+) (string, error) {
+	switch format {
+	case dotFormatString:
+		dotString, err := graph.DOTString(valueToString)
+		if err != nil {
+			var cycleError *dag.CycleError[Key]
+			if errors.As(err, &cycleError) {
+				return "", fmt.Errorf("%w: use --%s=%s to print the cycles in the graph", err, formatFlagName, cyclesFormatString)
+			}
+			return "", err
+		}
+		return dotString, nil
+	case mermaidFormatString:
+		return graph.MermaidString(valueToString)
+	case graphMLFormatString:
+		return graph.GraphMLString(valueToString)
+	case cyclesFormatString:
+		components, err := graph.StronglyConnectedComponents()
+		if err != nil {
+			return "", err
+		}
+		// None of our graphs have nodes with edges to themselves, so only components
+		// with more than one node have cycles.
+		var cycles []string
+		for _, component := range components {
+			if len(component) > 1 {
+				cycles = append(cycles, strings.Join(slicesext.Map(component, valueToString), ", "))
+			}
+		}
+		sort.Strings(cycles)
+		lines := []string{
+			fmt.Sprintf("Nodes: %d", graph.NumNodes()),
+			fmt.Sprintf("Edges: %d", graph.NumEdges()),
+			fmt.Sprintf("Cycles: %d", len(cycles)),
+		}
+		if len(cycles) > 0 {
+			lines = append(lines, "")
+			for _, cycle := range cycles {
+				lines = append(lines, "  "+cycle)
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	default:
+		return "", appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, format)
+	}
+}
+
+func moduleGraphToJSONString(graph *dag.Graph[string, bufmodule.Module], flags *flags) (string, error) {
+	// We traverse each module (node) in the graph and populate the deps (outbound nodes).
+	// We keep track of every module we have seen so we can update their d
+	moduleFullNameOrOpaqueIDToExternalModule := make(map[string]externalModule)
+	if err := graph.WalkNodes(
+		func(module bufmodule.Module, _ []bufmodule.Module, deps []bufmodule.Module) error {
+			moduleFullNameOrOpaqueID := internal.ModuleFullNameOrOpaqueID(module)
+			// We have already populated this node through deps, we can skip module.
+			if _, ok := moduleFullNameOrOpaqueIDToExternalModule[moduleFullNameOrOpaqueID]; ok {
+				return nil
+			}
+			// We first scaffold a module with no deps populated yet.
+			externalModule, err := externalModuleNoDepsForModule(module)
+			if err != nil {
+				return err
+			}
+			if err := externalModule.addDeps(deps, graph, moduleFullNameOrOpaqueIDToExternalModule, flags); err != nil {
+				return err
+			}
+			// Sort the deps alphabetically before adding our external module.
+			sortExternalModules(externalModule.Deps)
+			moduleFullNameOrOpaqueIDToExternalModule[moduleFullNameOrOpaqueID] = externalModule
+			return nil
+		},
+	); err != nil {
+		return "", err
+	}
+	externalModules := slicesext.MapValuesToSlice(moduleFullNameOrOpaqueIDToExternalModule)
+	// Sort all modules alphabetically.
+	sortExternalModules(externalModules)
+	data, err := json.Marshal(externalModules)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// getImportGraph returns the graph of imports between the files or packages of the image.
+//
+// Also returns the module of each node at the file level, as nodes are file paths.
+func getImportGraph(
+	ctx context.Context,
+	workspace bufmodule.ModuleSet,
+	image bufimage.Image,
+	level string,
+) (*dag.ComparableGraph[string], map[string]bufmodule.Module, error) {
+	moduleReadBucket := bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(workspace)
+	graph := dag.NewComparableGraph[string]()
+	var nameToModule map[string]bufmodule.Module
+	switch level {
+	case fileLevelString:
+		nameToModule = make(map[string]bufmodule.Module)
+		for _, imageFile := range image.Files() {
+			fileInfo, err := moduleReadBucket.StatFileInfo(ctx, imageFile.Path())
+			if err != nil {
+				return nil, nil, err
+			}
+			nameToModule[imageFile.Path()] = fileInfo.Module()
+			graph.AddNode(imageFile.Path())
+			for _, dependency := range imageFile.FileDescriptorProto().GetDependency() {
+				graph.AddEdge(imageFile.Path(), dependency)
+			}
+		}
+	case packageLevelString:
+		for _, imageFile := range image.Files() {
+			packageName := getPackageName(imageFile)
+			graph.AddNode(packageName)
+			for _, dependency := range imageFile.FileDescriptorProto().GetDependency() {
+				dependencyImageFile := image.GetFile(dependency)
+				if dependencyImageFile == nil {
+					return nil, nil, syserror.Newf("dependency %q of %q not found in image", dependency, imageFile.Path())
+				}
+				if dependencyPackageName := getPackageName(dependencyImageFile); dependencyPackageName != packageName {
+					graph.AddEdge(packageName, dependencyPackageName)
+				}
+			}
+		}
+	default:
+		return nil, nil, syserror.Newf("unknown level: %q", level)
+	}
+	return graph, nameToModule, nil
+}
+
+func getPackageName(imageFile bufimage.ImageFile) string {
+	if packageName := imageFile.FileDescriptorProto().GetPackage(); packageName != "" {
+		return packageName
+	}
+	return noPackageName
+}
+
+func importGraphToJSONString(
+	graph *dag.ComparableGraph[string],
+	nameToModule map[string]bufmodule.Module,
+) (string, error) {
+	var externalNodes []externalNode
+	if err := graph.WalkNodes(
+		func(name string, _ []string, deps []string) error {
+			externalNode := externalNode{
+				Name: name,
+				Deps: slicesext.Copy(deps),
+			}
+			if module, ok := nameToModule[name]; ok {
+				externalNode.Module = internal.ModuleFullNameOrOpaqueID(module)
+			}
+			sort.Strings(externalNode.Deps)
+			externalNodes = append(externalNodes, externalNode)
+			return nil
+		},
+	); err != nil {
+		return "", err
+	}
+	sort.Slice(
+		externalNodes,
+		func(i int, j int) bool {
+			return externalNodes[i].Name < externalNodes[j].Name
+		},
+	)
+	data, err := json.Marshal(externalNodes)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func moduleToString(module bufmodule.Module) string {
 	if moduleFullName := module.ModuleFullName(); moduleFullName != nil {
 		commitID := dashlessCommitIDStringForModule(module)
@@ -214,15 +432,6 @@ func moduleToString(module bufmodule.Module) string {
 	return module.OpaqueID()
 }
 
-// moduleFullNameOrOpaqueID returns the ModuleFullName for a module if available, otherwise
-// it returns the OpaqueID.
-func moduleFullNameOrOpaqueID(module bufmodule.Module) string {
-	if moduleFullName := module.ModuleFullName(); moduleFullName != nil {
-		return moduleFullName.String()
-	}
-	return module.OpaqueID()
-}
-
 // dashlessCommitIDStringForModule returns the dashless UUID for the commit. If no commit
 // is set, we return an empty string.
 func dashlessCommitIDStringForModule(module bufmodule.Module) string {
@@ -242,6 +451,15 @@ type externalModule struct {
 	Local  bool             `json:"local,omitempty" yaml:"local,omitempty"`
 }
 
+// externalNode is a file or package in an import graph.
+type externalNode struct {
+	// File path or package name
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// ModuleFullName if remote, OpaqueID if no ModuleFullName. Only set for files.
+	Module string   `json:"module,omitempty" yaml:"module,omitempty"`
+	Deps   []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+}
+
 func (e *externalModule) addDeps(
 	deps []bufmodule.Module,
 	graph *dag.Graph[string, bufmodule.Module],
@@ -249,7 +467,7 @@ func (e *externalModule) addDeps(
 	flags *flags,
 ) error {
 	for _, dep := range deps {
-		depModuleFullNameOrOpaqueID := moduleFullNameOrOpaqueID(dep)
+		depModuleFullNameOrOpaqueID := internal.ModuleFullNameOrOpaqueID(dep)
 		depExternalModule, ok := moduleFullNameOrOpaqueIDToExternalModule[depModuleFullNameOrOpaqueID]
 		if ok {
 			// If this dependency has already been seen, we can simply update our current module
@@ -288,7 +506,7 @@ func externalModuleNoDepsForModule(module bufmodule.Module) (externalModule, err
 		return externalModule{}, err
 	}
 	return externalModule{
-		Name:   moduleFullNameOrOpaqueID(module),
+		Name:   internal.ModuleFullNameOrOpaqueID(module),
 		Commit: dashlessCommitIDStringForModule(module),
 		Digest: digest.String(),
 		Local:  module.IsLocal(),
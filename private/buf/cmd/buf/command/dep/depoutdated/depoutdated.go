@@ -0,0 +1,397 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depoutdated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/bufprint"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufcheck"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/bufbuild/buf/private/pkg/wasm"
+	"github.com/spf13/pflag"
+)
+
+const formatFlagName = "format"
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <directory>",
+		Short: "Print the dependencies in a buf.lock that have newer commits",
+		Long: `Print the dependencies in a buf.lock that are not on the latest commit of their label.
+
+For each dependency in buf.lock, the latest commit is resolved from the ref of the
+dependency in buf.yaml. Dependencies without a ref, including transitive dependencies
+that are not in buf.yaml, are resolved to the latest commit on the default label of
+their module. Dependencies that are pinned to a commit in buf.yaml are never outdated.
+
+For each outdated dependency, the current and latest commits and their create times are
+printed, along with whether updating to the latest commit would cause breaking changes in
+the files of the dependency that are imported by the workspace. Breaking changes are
+checked with the default breaking rules.
+
+Breaking changes are checked per file, not per type. All files of the dependency that the
+workspace imports, directly or transitively, are checked in full, so a breaking change to
+a type in an imported file marks the dependency as breaking even if the workspace does not
+reference that type.
+
+The first argument is the directory of the local module or workspace.
+Defaults to "." if no argument is specified.`,
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	Format string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		bufprint.FormatText.String(),
+		fmt.Sprintf(`The output format to use. Must be one of %s`, bufprint.AllFormatsString),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	dirPath := "."
+	if container.NumArgs() > 0 {
+		dirPath = container.Arg(0)
+	}
+	format, err := bufprint.ParseFormat(flags.Format)
+	if err != nil {
+		return appcmd.WrapInvalidArgumentError(err)
+	}
+	controller, err := bufcli.NewController(ctx, container)
+	if err != nil {
+		return err
+	}
+	workspaceDepManager, err := controller.GetWorkspaceDepManager(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+	depModuleKeys, err := workspaceDepManager.ExistingBufLockFileDepModuleKeys(ctx)
+	if err != nil {
+		return err
+	}
+	configuredDepModuleRefs, err := workspaceDepManager.ConfiguredDepModuleRefs(ctx)
+	if err != nil {
+		return err
+	}
+	moduleKeyProvider, err := bufcli.NewModuleKeyProvider(ctx, container)
+	if err != nil {
+		return err
+	}
+	commitProvider, err := bufcli.NewCommitProvider(ctx, container)
+	if err != nil {
+		return err
+	}
+	outdatedDeps, err := getOutdatedDeps(
+		ctx,
+		moduleKeyProvider,
+		commitProvider,
+		depModuleKeys,
+		configuredDepModuleRefs,
+		workspaceDepManager.BufLockFileDigestType(),
+	)
+	if err != nil {
+		return err
+	}
+	if len(outdatedDeps) > 0 {
+		workspace, err := controller.GetWorkspace(ctx, dirPath, bufctl.WithIgnoreAndDisallowV1BufWorkYAMLs())
+		if err != nil {
+			return err
+		}
+		// Breaking change detection requires source code info, so it is not excluded.
+		image, err := controller.GetImageForWorkspace(ctx, workspace)
+		if err != nil {
+			return err
+		}
+		// The default breaking rules are all builtin, so no plugins need to be run.
+		client, err := bufcheck.NewClient(container.Logger(), bufcheck.NewRunnerProvider(wasm.UnimplementedRuntime))
+		if err != nil {
+			return err
+		}
+		for _, outdatedDep := range outdatedDeps {
+			importedPaths := getImportedPathsForModuleFullName(image, outdatedDep.current.ModuleKey().ModuleFullName())
+			if len(importedPaths) == 0 {
+				// The dependency is only in buf.lock, and none of its files are imported.
+				continue
+			}
+			latestModuleKey := outdatedDep.latest.ModuleKey()
+			latestImage, err := controller.GetImage(
+				ctx,
+				latestModuleKey.ModuleFullName().String()+":"+uuidutil.ToDashless(latestModuleKey.CommitID()),
+			)
+			if err != nil {
+				return err
+			}
+			outdatedDep.breaking, err = isBreakingForImportedPaths(ctx, client, latestImage, image, importedPaths)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return printOutdatedDeps(container, format, outdatedDeps)
+}
+
+// outdatedDep is a dependency in buf.lock that is not on the latest commit of its label.
+type outdatedDep struct {
+	current bufmodule.Commit
+	latest  bufmodule.Commit
+	// breaking is whether updating to the latest commit causes breaking changes in the
+	// files of the dependency that are imported by the workspace.
+	breaking bool
+}
+
+// getOutdatedDeps resolves the latest commit for every dependency, and returns the
+// dependencies whose latest commit differs from their current commit, in the order
+// of depModuleKeys.
+//
+// A dependency is resolved with its ModuleRef in configuredDepModuleRefs if present, otherwise
+// it is resolved to the default label of its module.
+func getOutdatedDeps(
+	ctx context.Context,
+	moduleKeyProvider bufmodule.ModuleKeyProvider,
+	commitProvider bufmodule.CommitProvider,
+	depModuleKeys []bufmodule.ModuleKey,
+	configuredDepModuleRefs []bufmodule.ModuleRef,
+	digestType bufmodule.DigestType,
+) ([]*outdatedDep, error) {
+	if len(depModuleKeys) == 0 {
+		return nil, nil
+	}
+	moduleFullNameStringToConfiguredDepModuleRef, err := bufmodule.ModuleFullNameStringToUniqueValue(configuredDepModuleRefs)
+	if err != nil {
+		return nil, err
+	}
+	depModuleRefs, err := slicesext.MapError(
+		depModuleKeys,
+		func(depModuleKey bufmodule.ModuleKey) (bufmodule.ModuleRef, error) {
+			if configuredDepModuleRef, ok := moduleFullNameStringToConfiguredDepModuleRef[depModuleKey.ModuleFullName().String()]; ok {
+				return configuredDepModuleRef, nil
+			}
+			moduleFullName := depModuleKey.ModuleFullName()
+			return bufmodule.NewModuleRef(moduleFullName.Registry(), moduleFullName.Owner(), moduleFullName.Name(), "")
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	latestModuleKeys, err := moduleKeyProvider.GetModuleKeysForModuleRefs(ctx, depModuleRefs, digestType)
+	if err != nil {
+		return nil, err
+	}
+	if len(latestModuleKeys) != len(depModuleKeys) {
+		return nil, syserror.Newf("expected %d ModuleKeys, got %d", len(depModuleKeys), len(latestModuleKeys))
+	}
+	var currentModuleKeys []bufmodule.ModuleKey
+	var outdatedLatestModuleKeys []bufmodule.ModuleKey
+	for i, depModuleKey := range depModuleKeys {
+		if depModuleKey.CommitID() != latestModuleKeys[i].CommitID() {
+			currentModuleKeys = append(currentModuleKeys, depModuleKey)
+			outdatedLatestModuleKeys = append(outdatedLatestModuleKeys, latestModuleKeys[i])
+		}
+	}
+	if len(currentModuleKeys) == 0 {
+		return nil, nil
+	}
+	currentCommits, err := commitProvider.GetCommitsForModuleKeys(ctx, currentModuleKeys)
+	if err != nil {
+		return nil, err
+	}
+	latestCommits, err := commitProvider.GetCommitsForModuleKeys(ctx, outdatedLatestModuleKeys)
+	if err != nil {
+		return nil, err
+	}
+	outdatedDeps := make([]*outdatedDep, len(currentCommits))
+	for i := range currentCommits {
+		outdatedDeps[i] = &outdatedDep{
+			current: currentCommits[i],
+			latest:  latestCommits[i],
+		}
+	}
+	return outdatedDeps, nil
+}
+
+// getImportedPathsForModuleFullName returns the paths of the files in the Image that
+// belong to the module with the given ModuleFullName.
+//
+// This is scoped to whole files, as the Image contains every file that is imported by the
+// workspace, but not which of their types are referenced.
+func getImportedPathsForModuleFullName(image bufimage.Image, moduleFullName bufmodule.ModuleFullName) []string {
+	var importedPaths []string
+	for _, imageFile := range image.Files() {
+		if imageFileModuleFullName := imageFile.ModuleFullName(); imageFileModuleFullName != nil &&
+			bufmodule.ModuleFullNameEqual(imageFileModuleFullName, moduleFullName) {
+			importedPaths = append(importedPaths, imageFile.Path())
+		}
+	}
+	return importedPaths
+}
+
+// isBreakingForImportedPaths returns whether the files at importedPaths in image have
+// breaking changes against the same files in againstImage.
+//
+// Files at importedPaths that do not exist in image are deleted files, and are breaking.
+func isBreakingForImportedPaths(
+	ctx context.Context,
+	client bufcheck.Client,
+	image bufimage.Image,
+	againstImage bufimage.Image,
+	importedPaths []string,
+) (bool, error) {
+	if !slices.ContainsFunc(
+		importedPaths,
+		func(importedPath string) bool {
+			return image.GetFile(importedPath) != nil
+		},
+	) {
+		// Every imported file was deleted, and an Image cannot be empty.
+		return true, nil
+	}
+	image, err := bufimage.ImageWithOnlyPathsAllowNotExist(image, importedPaths, nil)
+	if err != nil {
+		return false, err
+	}
+	againstImage, err = bufimage.ImageWithOnlyPaths(againstImage, importedPaths, nil)
+	if err != nil {
+		return false, err
+	}
+	if err := client.Breaking(
+		ctx,
+		bufconfig.DefaultBreakingConfigV2,
+		image,
+		againstImage,
+		bufcheck.BreakingWithExcludeImports(),
+	); err != nil {
+		var fileAnnotationSet bufanalysis.FileAnnotationSet
+		if errors.As(err, &fileAnnotationSet) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+func printOutdatedDeps(container appext.Container, format bufprint.Format, outdatedDeps []*outdatedDep) error {
+	externalOutdatedDeps, err := slicesext.MapError(outdatedDeps, newExternalOutdatedDep)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case bufprint.FormatText:
+		if len(externalOutdatedDeps) == 0 {
+			container.Logger().Info("all dependencies are up to date")
+			return nil
+		}
+		return bufprint.WithTabWriter(
+			container.Stdout(),
+			[]string{"NAME", "CURRENT", "CURRENT CREATE TIME", "LATEST", "LATEST CREATE TIME", "BREAKING"},
+			func(tabWriter bufprint.TabWriter) error {
+				for _, externalOutdatedDep := range externalOutdatedDeps {
+					if err := tabWriter.Write(
+						externalOutdatedDep.Name,
+						externalOutdatedDep.Current,
+						externalOutdatedDep.CurrentCreateTime,
+						externalOutdatedDep.Latest,
+						externalOutdatedDep.LatestCreateTime,
+						strconv.FormatBool(externalOutdatedDep.Breaking),
+					); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		)
+	case bufprint.FormatJSON:
+		for _, externalOutdatedDep := range externalOutdatedDeps {
+			data, err := json.Marshal(externalOutdatedDep)
+			if err != nil {
+				return err
+			}
+			if _, err := container.Stdout().Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return syserror.Newf("unknown format: %v", format)
+	}
+}
+
+type externalOutdatedDep struct {
+	Name              string `json:"name"`
+	Current           string `json:"current"`
+	CurrentCreateTime string `json:"current_create_time"`
+	Latest            string `json:"latest"`
+	LatestCreateTime  string `json:"latest_create_time"`
+	Breaking          bool   `json:"breaking"`
+}
+
+func newExternalOutdatedDep(outdatedDep *outdatedDep) (externalOutdatedDep, error) {
+	currentCreateTime, err := outdatedDep.current.CreateTime()
+	if err != nil {
+		return externalOutdatedDep{}, err
+	}
+	latestCreateTime, err := outdatedDep.latest.CreateTime()
+	if err != nil {
+		return externalOutdatedDep{}, err
+	}
+	return externalOutdatedDep{
+		Name:              outdatedDep.current.ModuleKey().ModuleFullName().String(),
+		Current:           uuidutil.ToDashless(outdatedDep.current.ModuleKey().CommitID()),
+		CurrentCreateTime: currentCreateTime.UTC().Format(time.RFC3339),
+		Latest:            uuidutil.ToDashless(outdatedDep.latest.ModuleKey().CommitID()),
+		LatestCreateTime:  latestCreateTime.UTC().Format(time.RFC3339),
+		Breaking:          outdatedDep.breaking,
+	}, nil
+}
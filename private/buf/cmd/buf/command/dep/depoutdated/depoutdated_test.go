@@ -0,0 +1,227 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depoutdated
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcheck"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/bufbuild/buf/private/pkg/wasm"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCurrentCreateTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testLatestCreateTime  = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestGetOutdatedDeps(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dateCurrentCommitID := uuid.New()
+	dateLatestCommitID := uuid.New()
+	extensionCommitID := uuid.New()
+	currentProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name:       "buf.testing/acme/date",
+			CommitID:   dateCurrentCommitID,
+			CreateTime: testCurrentCreateTime,
+			PathToData: map[string][]byte{
+				"acme/date/date.proto": []byte(`syntax = "proto3"; package acme.date; message Date { int32 year = 1; }`),
+			},
+		},
+		bufmoduletesting.ModuleData{
+			Name:       "buf.testing/acme/extension",
+			CommitID:   extensionCommitID,
+			CreateTime: testCurrentCreateTime,
+			PathToData: map[string][]byte{
+				"acme/extension/extension.proto": []byte(`syntax = "proto3"; package acme.extension;`),
+			},
+		},
+	)
+	require.NoError(t, err)
+	latestProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name:       "buf.testing/acme/date",
+			CommitID:   dateLatestCommitID,
+			CreateTime: testLatestCreateTime,
+			PathToData: map[string][]byte{
+				"acme/date/date.proto": []byte(`syntax = "proto3"; package acme.date; message Date { int32 year = 1; int32 month = 2; }`),
+			},
+		},
+		bufmoduletesting.ModuleData{
+			Name:       "buf.testing/acme/extension",
+			CommitID:   extensionCommitID,
+			CreateTime: testCurrentCreateTime,
+			PathToData: map[string][]byte{
+				"acme/extension/extension.proto": []byte(`syntax = "proto3"; package acme.extension;`),
+			},
+		},
+	)
+	require.NoError(t, err)
+	dateModuleRef, err := bufmodule.ParseModuleRef("buf.testing/acme/date")
+	require.NoError(t, err)
+	extensionModuleRef, err := bufmodule.ParseModuleRef("buf.testing/acme/extension:main")
+	require.NoError(t, err)
+	depModuleKeys, err := currentProvider.GetModuleKeysForModuleRefs(
+		ctx,
+		[]bufmodule.ModuleRef{dateModuleRef, extensionModuleRef},
+		bufmodule.DigestTypeB5,
+	)
+	require.NoError(t, err)
+
+	outdatedDeps, err := getOutdatedDeps(
+		ctx,
+		latestProvider,
+		testCommitProvider{currentProvider, latestProvider},
+		depModuleKeys,
+		[]bufmodule.ModuleRef{extensionModuleRef},
+		bufmodule.DigestTypeB5,
+	)
+	require.NoError(t, err)
+	require.Len(t, outdatedDeps, 1)
+	actualExternalOutdatedDep, err := newExternalOutdatedDep(outdatedDeps[0])
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		externalOutdatedDep{
+			Name:              "buf.testing/acme/date",
+			Current:           uuidutil.ToDashless(dateCurrentCommitID),
+			CurrentCreateTime: "2024-01-01T00:00:00Z",
+			Latest:            uuidutil.ToDashless(dateLatestCommitID),
+			LatestCreateTime:  "2024-06-01T00:00:00Z",
+		},
+		actualExternalOutdatedDep,
+	)
+
+	outdatedDeps, err = getOutdatedDeps(
+		ctx,
+		currentProvider,
+		currentProvider,
+		depModuleKeys,
+		nil,
+		bufmodule.DigestTypeB5,
+	)
+	require.NoError(t, err)
+	require.Empty(t, outdatedDeps)
+}
+
+func TestIsBreakingForImportedPaths(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	currentImage := testBuildImage(
+		t,
+		map[string][]byte{
+			"acme/date/date.proto":   []byte(`syntax = "proto3"; package acme.date; message Date { int32 year = 1; }`),
+			"acme/date/period.proto": []byte(`syntax = "proto3"; package acme.date; message Period { int32 days = 1; }`),
+			"acme/date/time.proto":   []byte(`syntax = "proto3"; package acme.date; message Time { int32 hour = 1; }`),
+		},
+	)
+	require.Equal(
+		t,
+		[]string{"acme/date/date.proto", "acme/date/period.proto", "acme/date/time.proto"},
+		getImportedPathsForModuleFullName(currentImage, testModuleFullName(t, "buf.testing/acme/date")),
+	)
+	require.Empty(t, getImportedPathsForModuleFullName(currentImage, testModuleFullName(t, "buf.testing/acme/other")))
+	// period.proto has a deleted field, and time.proto is deleted.
+	latestImage := testBuildImage(
+		t,
+		map[string][]byte{
+			"acme/date/date.proto":   []byte(`syntax = "proto3"; package acme.date; message Date { int32 year = 1; int32 month = 2; }`),
+			"acme/date/period.proto": []byte(`syntax = "proto3"; package acme.date; message Period {}`),
+		},
+	)
+	client, err := bufcheck.NewClient(slogtestext.NewLogger(t), bufcheck.NewRunnerProvider(wasm.UnimplementedRuntime))
+	require.NoError(t, err)
+	for _, testCase := range []struct {
+		importedPaths    []string
+		expectedBreaking bool
+	}{
+		{importedPaths: []string{"acme/date/date.proto"}, expectedBreaking: false},
+		{importedPaths: []string{"acme/date/period.proto"}, expectedBreaking: true},
+		{importedPaths: []string{"acme/date/time.proto"}, expectedBreaking: true},
+		{importedPaths: []string{"acme/date/date.proto", "acme/date/time.proto"}, expectedBreaking: true},
+	} {
+		breaking, err := isBreakingForImportedPaths(ctx, client, latestImage, currentImage, testCase.importedPaths)
+		require.NoError(t, err)
+		require.Equal(t, testCase.expectedBreaking, breaking, testCase.importedPaths)
+	}
+}
+
+// testCommitProvider gets Commits from the first CommitProvider that has them.
+type testCommitProvider []bufmodule.CommitProvider
+
+func (t testCommitProvider) GetCommitsForModuleKeys(
+	ctx context.Context,
+	moduleKeys []bufmodule.ModuleKey,
+) ([]bufmodule.Commit, error) {
+	var err error
+	for _, commitProvider := range t {
+		var commits []bufmodule.Commit
+		commits, err = commitProvider.GetCommitsForModuleKeys(ctx, moduleKeys)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return commits, err
+		}
+	}
+	return nil, err
+}
+
+func (t testCommitProvider) GetCommitsForCommitKeys(
+	ctx context.Context,
+	commitKeys []bufmodule.CommitKey,
+) ([]bufmodule.Commit, error) {
+	var err error
+	for _, commitProvider := range t {
+		var commits []bufmodule.Commit
+		commits, err = commitProvider.GetCommitsForCommitKeys(ctx, commitKeys)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return commits, err
+		}
+	}
+	return nil, err
+}
+
+func testBuildImage(t *testing.T, pathToData map[string][]byte) bufimage.Image {
+	moduleSet, err := bufmoduletesting.NewModuleSet(
+		bufmoduletesting.ModuleData{
+			Name:       "buf.testing/acme/date",
+			PathToData: pathToData,
+		},
+	)
+	require.NoError(t, err)
+	image, err := bufimage.BuildImage(
+		context.Background(),
+		slogtestext.NewLogger(t),
+		bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+	)
+	require.NoError(t, err)
+	return image
+}
+
+func testModuleFullName(t *testing.T, moduleFullNameString string) bufmodule.ModuleFullName {
+	moduleFullName, err := bufmodule.ParseModuleFullName(moduleFullNameString)
+	require.NoError(t, err)
+	return moduleFullName
+}
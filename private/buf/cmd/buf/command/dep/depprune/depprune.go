@@ -54,7 +54,7 @@ func run(
 	if container.NumArgs() > 0 {
 		dirPath = container.Arg(0)
 	}
-	controller, err := bufcli.NewController(container)
+	controller, err := bufcli.NewController(ctx, container)
 	if err != nil {
 		return err
 	}
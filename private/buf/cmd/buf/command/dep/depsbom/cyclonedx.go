@@ -0,0 +1,163 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsbom
+
+import (
+	"encoding/json"
+	"time"
+
+	"buf.build/go/spdx"
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/google/uuid"
+)
+
+const (
+	cycloneDXBOMFormat   = "CycloneDX"
+	cycloneDXSpecVersion = "1.5"
+	// cycloneDXDigestPropertyName is the name of the property that holds the b5 digest,
+	// as CycloneDX has no hash algorithm for b5 digests.
+	//
+	// See https://github.com/CycloneDX/cyclonedx-property-taxonomy for property naming.
+	cycloneDXDigestPropertyName = "buf:digest"
+	cycloneDXLocalPropertyName  = "buf:local"
+)
+
+// cycloneDXBOMForComponents returns the CycloneDX JSON BOM for the components.
+func cycloneDXBOMForComponents(
+	components []*component,
+	timestamp time.Time,
+	serialNumber uuid.UUID,
+) ([]byte, error) {
+	bom := externalCycloneDXBOM{
+		BOMFormat:    cycloneDXBOMFormat,
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: serialNumber.URN(),
+		Version:      1,
+		Metadata: externalCycloneDXMetadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Tools: externalCycloneDXTools{
+				Components: []externalCycloneDXComponent{
+					{
+						Type:    "application",
+						Name:    "buf",
+						Version: bufcli.Version,
+					},
+				},
+			},
+		},
+		// Set to empty slices so that the fields are always present.
+		Components:   []externalCycloneDXComponent{},
+		Dependencies: []externalCycloneDXDependency{},
+	}
+	for _, component := range components {
+		externalComponent := externalCycloneDXComponent{
+			BOMRef:  component.name,
+			Type:    "library",
+			Name:    component.name,
+			Version: component.commitID,
+			Properties: []externalCycloneDXProperty{
+				{
+					Name:  cycloneDXDigestPropertyName,
+					Value: component.digest,
+				},
+			},
+		}
+		if component.local {
+			externalComponent.Properties = append(
+				externalComponent.Properties,
+				externalCycloneDXProperty{
+					Name:  cycloneDXLocalPropertyName,
+					Value: "true",
+				},
+			)
+		}
+		if component.licenseID != "" {
+			// License IDs must be in the SPDX license list, anything else is given as
+			// an expression, such as "Apache-2.0 OR MIT".
+			if _, ok := spdx.LicenseForID(component.licenseID); ok {
+				externalComponent.Licenses = []externalCycloneDXLicenseChoice{
+					{
+						License: &externalCycloneDXLicense{
+							ID: component.licenseID,
+						},
+					},
+				}
+			} else {
+				externalComponent.Licenses = []externalCycloneDXLicenseChoice{
+					{
+						Expression: component.licenseID,
+					},
+				}
+			}
+		}
+		bom.Components = append(bom.Components, externalComponent)
+		bom.Dependencies = append(
+			bom.Dependencies,
+			externalCycloneDXDependency{
+				Ref:       component.name,
+				DependsOn: component.depNames,
+			},
+		)
+	}
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+type externalCycloneDXBOM struct {
+	BOMFormat    string                        `json:"bomFormat"`
+	SpecVersion  string                        `json:"specVersion"`
+	SerialNumber string                        `json:"serialNumber"`
+	Version      int                           `json:"version"`
+	Metadata     externalCycloneDXMetadata     `json:"metadata"`
+	Components   []externalCycloneDXComponent  `json:"components"`
+	Dependencies []externalCycloneDXDependency `json:"dependencies"`
+}
+
+type externalCycloneDXMetadata struct {
+	Timestamp string                 `json:"timestamp"`
+	Tools     externalCycloneDXTools `json:"tools"`
+}
+
+type externalCycloneDXTools struct {
+	Components []externalCycloneDXComponent `json:"components"`
+}
+
+type externalCycloneDXComponent struct {
+	BOMRef     string                           `json:"bom-ref,omitempty"`
+	Type       string                           `json:"type"`
+	Name       string                           `json:"name"`
+	Version    string                           `json:"version,omitempty"`
+	Licenses   []externalCycloneDXLicenseChoice `json:"licenses,omitempty"`
+	Properties []externalCycloneDXProperty      `json:"properties,omitempty"`
+}
+
+// externalCycloneDXLicenseChoice is either a license or an expression.
+type externalCycloneDXLicenseChoice struct {
+	License    *externalCycloneDXLicense `json:"license,omitempty"`
+	Expression string                    `json:"expression,omitempty"`
+}
+
+type externalCycloneDXLicense struct {
+	ID string `json:"id,omitempty"`
+}
+
+type externalCycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type externalCycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
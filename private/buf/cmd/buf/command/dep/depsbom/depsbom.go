@@ -0,0 +1,362 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsbom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"buf.build/go/spdx"
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/internal"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/ioext"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	disableSymlinksFlagName = "disable-symlinks"
+	formatFlagName          = "format"
+
+	cycloneDXFormatString = "cyclonedx"
+	spdxFormatString      = "spdx"
+
+	// spdxLicenseIdentifierPrefix is the prefix of SPDX short-form license identifier lines.
+	//
+	// See https://spdx.dev/learn/handling-license-info.
+	spdxLicenseIdentifierPrefix = "SPDX-License-Identifier:"
+)
+
+var (
+	allFormatStrings = []string{
+		cycloneDXFormatString,
+		spdxFormatString,
+	}
+	// licenseIDAndMatchers are the phrases that identify the most common license texts.
+	//
+	// A license text matches a license if it contains all of the phrases of one of the
+	// matchers of the license. Phrases are lowercase with whitespace collapsed. The
+	// licenses are checked in order, so more specific licenses come first.
+	licenseIDAndMatchers = []struct {
+		licenseID string
+		matchers  [][]string
+	}{
+		{
+			licenseID: "Apache-2.0",
+			matchers: [][]string{
+				{"apache license", "version 2.0"},
+			},
+		},
+		{
+			licenseID: "MPL-2.0",
+			matchers: [][]string{
+				{"mozilla public license", "version 2.0"},
+			},
+		},
+		{
+			licenseID: "BSD-3-Clause",
+			matchers: [][]string{
+				{"redistribution and use in source and binary forms", "neither the name"},
+				{"redistribution and use in source and binary forms", "names of its contributors"},
+			},
+		},
+		{
+			licenseID: "BSD-2-Clause",
+			matchers: [][]string{
+				{"redistribution and use in source and binary forms"},
+			},
+		},
+		{
+			licenseID: "MIT",
+			matchers: [][]string{
+				{"permission is hereby granted, free of charge"},
+			},
+		},
+		{
+			licenseID: "ISC",
+			matchers: [][]string{
+				{"permission to use, copy, modify, and/or distribute this software for any purpose"},
+			},
+		},
+		{
+			licenseID: "Unlicense",
+			matchers: [][]string{
+				{"this is free and unencumbered software released into the public domain"},
+			},
+		},
+	}
+)
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <input>",
+		Short: "Print a software bill of materials of the modules and their dependencies",
+		Long: `Print a software bill of materials (SBOM) of the modules of the input and all of
+their resolved dependencies.
+
+Each module is listed with its name, commit, b5 digest, license, and the modules it
+directly depends on. Modules without a name are listed by their path. The license is
+detected from the LICENSE file of the module, either from an SPDX-License-Identifier
+line or from the text of common licenses.
+
+The following formats are supported:
+
+  - cyclonedx: CycloneDX 1.5 JSON.
+  - spdx: SPDX 2.3 JSON.
+
+Neither format has a hash algorithm for b5 digests, so digests are recorded as a
+"buf:digest" property in CycloneDX, and as an external reference of type "buf-digest"
+in SPDX.
+` + bufcli.GetSourceOrModuleLong(`the source or module to print the SBOM for`),
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	DisableSymlinks bool
+	// special
+	InputHashtag string
+	Format       string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		cycloneDXFormatString,
+		fmt.Sprintf(
+			"The format to print the SBOM as. Must be one of %s",
+			stringutil.SliceToString(allFormatStrings),
+		),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	input, err := bufcli.GetInputValue(container, flags.InputHashtag, ".")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	workspace, err := controller.GetWorkspace(ctx, input)
+	if err != nil {
+		return err
+	}
+	components, err := getComponents(ctx, workspace)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	switch flags.Format {
+	case cycloneDXFormatString:
+		data, err = cycloneDXBOMForComponents(components, time.Now(), uuid.New())
+	case spdxFormatString:
+		data, err = spdxDocumentForComponents(components, input, time.Now(), uuid.New())
+	default:
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = container.Stdout().Write(append(data, '\n'))
+	return err
+}
+
+// component is a module in an SBOM.
+type component struct {
+	// name is the ModuleFullName if remote, OpaqueID if no ModuleFullName.
+	name string
+	// moduleFullName is nil if the module has no name.
+	moduleFullName bufmodule.ModuleFullName
+	// commitID is the dashless commit ID, or empty if the module has no commit.
+	commitID string
+	// digest is the b5 digest.
+	digest string
+	local  bool
+	// licenseID is the SPDX identifier of the license of the module.
+	//
+	// Empty if the module has no license file, or if the license could not be detected.
+	licenseID string
+	// hasLicenseFile is whether the module has a license file.
+	hasLicenseFile bool
+	// depNames are the names of the direct dependencies of the module.
+	//
+	// Sorted.
+	depNames []string
+}
+
+// getComponents returns the components for the target modules of the ModuleSet and
+// all of their dependencies.
+//
+// Sorted by name.
+func getComponents(ctx context.Context, moduleSet bufmodule.ModuleSet) ([]*component, error) {
+	graph, err := bufmodule.ModuleSetToDAG(moduleSet)
+	if err != nil {
+		return nil, err
+	}
+	var components []*component
+	if err := graph.WalkNodes(
+		func(module bufmodule.Module, _ []bufmodule.Module, deps []bufmodule.Module) error {
+			component, err := getComponentForModule(ctx, module, deps)
+			if err != nil {
+				return err
+			}
+			components = append(components, component)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	sort.Slice(
+		components,
+		func(i int, j int) bool {
+			return components[i].name < components[j].name
+		},
+	)
+	return components, nil
+}
+
+func getComponentForModule(
+	ctx context.Context,
+	module bufmodule.Module,
+	deps []bufmodule.Module,
+) (*component, error) {
+	// We always calculate the b5 digest here, we do not check the digest type that is stored
+	// in buf.lock.
+	digest, err := module.Digest(bufmodule.DigestTypeB5)
+	if err != nil {
+		return nil, err
+	}
+	component := &component{
+		name:           internal.ModuleFullNameOrOpaqueID(module),
+		moduleFullName: module.ModuleFullName(),
+		digest:         digest.String(),
+		local:          module.IsLocal(),
+	}
+	if commitID := module.CommitID(); commitID != uuid.Nil {
+		component.commitID = uuidutil.ToDashless(commitID)
+	}
+	licenseFile, err := bufmodule.GetLicenseFile(ctx, module)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if licenseFile != nil {
+		data, err := ioext.ReadAllAndClose(licenseFile)
+		if err != nil {
+			return nil, err
+		}
+		component.hasLicenseFile = true
+		component.licenseID = detectLicenseID(data)
+	}
+	for _, dep := range deps {
+		component.depNames = append(component.depNames, internal.ModuleFullNameOrOpaqueID(dep))
+	}
+	sort.Strings(component.depNames)
+	return component, nil
+}
+
+// detectLicenseID returns the SPDX identifier of the license text.
+//
+// Returns empty if the license could not be detected.
+func detectLicenseID(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		_, licenseIDString, ok := strings.Cut(scanner.Text(), spdxLicenseIdentifierPrefix)
+		if !ok {
+			continue
+		}
+		if license, ok := spdx.LicenseForID(strings.TrimSpace(licenseIDString)); ok {
+			return license.ID
+		}
+		// Identifiers that are not in the SPDX license list, such as expressions,
+		// are returned as-is.
+		return strings.TrimSpace(licenseIDString)
+	}
+	text := strings.ToLower(strings.Join(strings.Fields(string(data)), " "))
+	for _, licenseIDAndMatcher := range licenseIDAndMatchers {
+		for _, matcher := range licenseIDAndMatcher.matchers {
+			if allContained(text, matcher) {
+				return licenseIDAndMatcher.licenseID
+			}
+		}
+	}
+	return ""
+}
+
+func allContained(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsbom
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLicenseID(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name              string
+		data              string
+		expectedLicenseID string
+	}{
+		{
+			name:              "spdx_identifier",
+			data:              "Copyright Acme\n\nSPDX-License-Identifier: apache-2.0\n",
+			expectedLicenseID: "Apache-2.0",
+		},
+		{
+			name:              "spdx_expression",
+			data:              "SPDX-License-Identifier: Apache-2.0 OR MIT\n",
+			expectedLicenseID: "Apache-2.0 OR MIT",
+		},
+		{
+			name:              "apache",
+			data:              "                                 Apache License\n                           Version 2.0, January 2004\n",
+			expectedLicenseID: "Apache-2.0",
+		},
+		{
+			name: "mit",
+			data: `MIT License
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal`,
+			expectedLicenseID: "MIT",
+		},
+		{
+			name: "bsd_3_clause",
+			data: `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+3. Neither the name of the copyright holder nor the names of its
+   contributors may be used to endorse or promote products derived from`,
+			expectedLicenseID: "BSD-3-Clause",
+		},
+		{
+			name: "bsd_2_clause",
+			data: `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:`,
+			expectedLicenseID: "BSD-2-Clause",
+		},
+		{
+			name:              "unknown",
+			data:              "All rights reserved.",
+			expectedLicenseID: "",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, testCase.expectedLicenseID, detectLicenseID([]byte(testCase.data)))
+		})
+	}
+}
+
+func TestSBOM(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dateCommitID := uuid.New()
+	moduleSet, err := bufmoduletesting.NewModuleSet(
+		bufmoduletesting.ModuleData{
+			Name: "buf.testing/acme/bond",
+			PathToData: map[string][]byte{
+				"acme/bond/bond.proto": []byte(`syntax = "proto3"; package acme.bond; import "acme/date/date.proto";`),
+			},
+		},
+		bufmoduletesting.ModuleData{
+			Name:        "buf.testing/acme/date",
+			CommitID:    dateCommitID,
+			NotTargeted: true,
+			PathToData: map[string][]byte{
+				"acme/date/date.proto": []byte(`syntax = "proto3"; package acme.date;`),
+				"LICENSE":              []byte("SPDX-License-Identifier: MIT\n"),
+			},
+		},
+	)
+	require.NoError(t, err)
+	// All modules of a test ModuleSet are local.
+	components, err := getComponents(ctx, moduleSet)
+	require.NoError(t, err)
+	require.Len(t, components, 2)
+	require.Equal(t, "buf.testing/acme/bond", components[0].name)
+	require.Equal(t, []string{"buf.testing/acme/date"}, components[0].depNames)
+	require.False(t, components[0].hasLicenseFile)
+	require.Equal(t, "buf.testing/acme/date", components[1].name)
+	require.Equal(t, uuidutil.ToDashless(dateCommitID), components[1].commitID)
+	require.Equal(t, "MIT", components[1].licenseID)
+	require.True(t, components[1].hasLicenseFile)
+	require.Empty(t, components[1].depNames)
+
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	data, err := cycloneDXBOMForComponents(components, timestamp, id)
+	require.NoError(t, err)
+	var bom externalCycloneDXBOM
+	require.NoError(t, json.Unmarshal(data, &bom))
+	require.Equal(t, "CycloneDX", bom.BOMFormat)
+	require.Equal(t, id.URN(), bom.SerialNumber)
+	require.Equal(t, "2024-01-01T00:00:00Z", bom.Metadata.Timestamp)
+	require.Len(t, bom.Components, 2)
+	require.Equal(
+		t,
+		[]externalCycloneDXProperty{
+			{Name: "buf:digest", Value: components[1].digest},
+			{Name: "buf:local", Value: "true"},
+		},
+		bom.Components[1].Properties,
+	)
+	require.Equal(
+		t,
+		[]externalCycloneDXLicenseChoice{{License: &externalCycloneDXLicense{ID: "MIT"}}},
+		bom.Components[1].Licenses,
+	)
+	require.Equal(
+		t,
+		[]externalCycloneDXDependency{
+			{Ref: "buf.testing/acme/bond", DependsOn: []string{"buf.testing/acme/date"}},
+			{Ref: "buf.testing/acme/date"},
+		},
+		bom.Dependencies,
+	)
+
+	data, err = spdxDocumentForComponents(components, "input", timestamp, id)
+	require.NoError(t, err)
+	var document externalSPDXDocument
+	require.NoError(t, json.Unmarshal(data, &document))
+	require.Equal(t, "SPDX-2.3", document.SPDXVersion)
+	require.Equal(t, "input", document.Name)
+	require.Equal(t, "https://buf.build/spdxdocs/"+id.String(), document.DocumentNamespace)
+	require.Len(t, document.Packages, 2)
+	require.Equal(t, "NONE", document.Packages[0].LicenseDeclared)
+	require.Equal(t, "https://buf.testing/acme/bond", document.Packages[0].DownloadLocation)
+	require.Equal(t, "MIT", document.Packages[1].LicenseDeclared)
+	require.Equal(t, components[1].digest, document.Packages[1].ExternalRefs[0].ReferenceLocator)
+	require.Equal(
+		t,
+		[]externalSPDXRelationship{
+			{
+				SPDXElementID:      "SPDXRef-DOCUMENT",
+				RelationshipType:   "DESCRIBES",
+				RelatedSPDXElement: "SPDXRef-Module-1",
+			},
+			{
+				SPDXElementID:      "SPDXRef-Module-1",
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: "SPDXRef-Module-2",
+			},
+			{
+				SPDXElementID:      "SPDXRef-DOCUMENT",
+				RelationshipType:   "DESCRIBES",
+				RelatedSPDXElement: "SPDXRef-Module-2",
+			},
+		},
+		document.Relationships,
+	)
+}
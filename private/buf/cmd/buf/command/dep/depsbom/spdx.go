@@ -0,0 +1,163 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsbom
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/google/uuid"
+)
+
+const (
+	spdxVersion     = "SPDX-2.3"
+	spdxDataLicense = "CC0-1.0"
+	spdxDocumentID  = "SPDXRef-DOCUMENT"
+	// spdxNamespacePrefix is the prefix of the unique document namespace. The namespace
+	// is a URI that identifies the document, and is not expected to be resolvable.
+	spdxNamespacePrefix = "https://buf.build/spdxdocs/"
+	// spdxNoAssertion is used when a value was not determined.
+	spdxNoAssertion = "NOASSERTION"
+	// spdxNone is used when a value is known to not exist, such as the license of a
+	// module without a license file.
+	spdxNone = "NONE"
+	// spdxDigestReferenceType is the external reference type that holds the b5 digest,
+	// as SPDX has no checksum algorithm for b5 digests.
+	spdxDigestReferenceType = "buf-digest"
+)
+
+// spdxDocumentForComponents returns the SPDX JSON document for the components.
+//
+// The name is the name of the document, usually the input.
+func spdxDocumentForComponents(
+	components []*component,
+	name string,
+	created time.Time,
+	namespaceID uuid.UUID,
+) ([]byte, error) {
+	document := externalSPDXDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       spdxDataLicense,
+		SPDXID:            spdxDocumentID,
+		Name:              name,
+		DocumentNamespace: spdxNamespacePrefix + namespaceID.String(),
+		CreationInfo: externalSPDXCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: buf-" + bufcli.Version},
+		},
+		// Set to empty slices so that the fields are always present.
+		Packages:      []externalSPDXPackage{},
+		Relationships: []externalSPDXRelationship{},
+	}
+	// SPDX identifiers may only contain letters, numbers, "." and "-", so we use the index
+	// of the component instead of its name.
+	nameToSPDXID := make(map[string]string, len(components))
+	for i, component := range components {
+		nameToSPDXID[component.name] = "SPDXRef-Module-" + strconv.Itoa(i+1)
+	}
+	for _, component := range components {
+		spdxID := nameToSPDXID[component.name]
+		externalPackage := externalSPDXPackage{
+			Name:             component.name,
+			SPDXID:           spdxID,
+			VersionInfo:      component.commitID,
+			DownloadLocation: spdxNoAssertion,
+			FilesAnalyzed:    false,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []externalSPDXExternalRef{
+				{
+					ReferenceCategory: "OTHER",
+					ReferenceType:     spdxDigestReferenceType,
+					ReferenceLocator:  component.digest,
+				},
+			},
+		}
+		if component.moduleFullName != nil {
+			externalPackage.DownloadLocation = "https://" + component.moduleFullName.String()
+		}
+		switch {
+		case component.licenseID != "":
+			externalPackage.LicenseDeclared = component.licenseID
+		case !component.hasLicenseFile:
+			externalPackage.LicenseDeclared = spdxNone
+		}
+		document.Packages = append(document.Packages, externalPackage)
+		if component.local {
+			document.Relationships = append(
+				document.Relationships,
+				externalSPDXRelationship{
+					SPDXElementID:      spdxDocumentID,
+					RelationshipType:   "DESCRIBES",
+					RelatedSPDXElement: spdxID,
+				},
+			)
+		}
+		for _, depName := range component.depNames {
+			document.Relationships = append(
+				document.Relationships,
+				externalSPDXRelationship{
+					SPDXElementID:      spdxID,
+					RelationshipType:   "DEPENDS_ON",
+					RelatedSPDXElement: nameToSPDXID[depName],
+				},
+			)
+		}
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+type externalSPDXDocument struct {
+	SPDXVersion       string                     `json:"spdxVersion"`
+	DataLicense       string                     `json:"dataLicense"`
+	SPDXID            string                     `json:"SPDXID"`
+	Name              string                     `json:"name"`
+	DocumentNamespace string                     `json:"documentNamespace"`
+	CreationInfo      externalSPDXCreationInfo   `json:"creationInfo"`
+	Packages          []externalSPDXPackage      `json:"packages"`
+	Relationships     []externalSPDXRelationship `json:"relationships"`
+}
+
+type externalSPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type externalSPDXPackage struct {
+	Name             string                    `json:"name"`
+	SPDXID           string                    `json:"SPDXID"`
+	VersionInfo      string                    `json:"versionInfo,omitempty"`
+	DownloadLocation string                    `json:"downloadLocation"`
+	FilesAnalyzed    bool                      `json:"filesAnalyzed"`
+	LicenseConcluded string                    `json:"licenseConcluded"`
+	LicenseDeclared  string                    `json:"licenseDeclared"`
+	CopyrightText    string                    `json:"copyrightText"`
+	ExternalRefs     []externalSPDXExternalRef `json:"externalRefs,omitempty"`
+}
+
+type externalSPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type externalSPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
 	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/internal"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
@@ -49,6 +51,13 @@ func NewCommand(
 		Long: `Fetch the latest digests for the specified references in buf.yaml,
 and write them and their transitive dependencies to buf.lock.
 
+Git dependencies are resolved to the commit that their ref currently points
+to, and the commit and the digest of their .proto files and buf.yaml are
+written to buf.lock.
+
+If a vendor directory is configured in buf.yaml, it is refreshed to match
+the updated buf.lock.
+
 The first argument is the directory of the local module to update.
 Defaults to "." if no argument is specified.`,
 		Args:       appcmd.MaximumNArgs(1),
@@ -98,7 +107,7 @@ func run(
 	}
 
 	logger := container.Logger()
-	controller, err := bufcli.NewController(container)
+	controller, err := bufcli.NewController(ctx, container)
 	if err != nil {
 		return err
 	}
@@ -125,12 +134,38 @@ func run(
 		slog.Any("deps", slicesext.Map(configuredDepModuleKeys, bufmodule.ModuleKey.String)),
 	)
 
+	configuredGitDepRefs, err := workspaceDepManager.ConfiguredGitDepRefs(ctx)
+	if err != nil {
+		return err
+	}
+	var configuredGitDepKeys []bufconfig.GitDepKey
+	if len(configuredGitDepRefs) > 0 {
+		gitDepProvider, err := bufcli.NewGitDepProvider(container)
+		if err != nil {
+			return err
+		}
+		configuredGitDepKeys, err = gitDepProvider.GetGitDepKeysForGitDepRefs(ctx, configuredGitDepRefs)
+		if err != nil {
+			return err
+		}
+		logger.DebugContext(
+			ctx,
+			"all git deps",
+			slog.Any("deps", slicesext.Map(configuredGitDepRefs, bufconfig.GitDepRef.String)),
+		)
+	}
+
 	// Store the existing buf.lock data.
 	existingDepModuleKeys, err := workspaceDepManager.ExistingBufLockFileDepModuleKeys(ctx)
 	if err != nil {
 		return err
 	}
-	if configuredDepModuleKeys == nil && existingDepModuleKeys == nil {
+	existingGitDepKeys, err := workspaceDepManager.ExistingBufLockFileGitDepKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if configuredDepModuleKeys == nil && existingDepModuleKeys == nil &&
+		configuredGitDepKeys == nil && existingGitDepKeys == nil {
 		// No new configured deps were found, and no existing buf.lock deps were found, so there
 		// is nothing to update, we can return here.
 		// This ensures we do not create an empty buf.lock when one did not exist in the first
@@ -140,6 +175,26 @@ func run(
 		return nil
 	}
 
+	graphProvider, err := bufcli.NewGraphProvider(ctx, container)
+	if err != nil {
+		return err
+	}
+	// Validate the dependency policy before we write anything.
+	if err := workspaceDepManager.ValidateDepPolicy(ctx, graphProvider, configuredDepModuleKeys); err != nil {
+		var fileAnnotationSet bufanalysis.FileAnnotationSet
+		if errors.As(err, &fileAnnotationSet) {
+			if err := bufanalysis.PrintFileAnnotationSet(
+				container.Stderr(),
+				fileAnnotationSet,
+				bufanalysis.FormatText.String(),
+			); err != nil {
+				return err
+			}
+			return bufctl.ErrFileAnnotation
+		}
+		return err
+	}
+
 	// We're about to edit the buf.lock file on disk. If we have a subsequent error,
 	// attempt to revert the buf.lock file.
 	//
@@ -148,13 +203,25 @@ func run(
 	// overlay the new buf.lock file in a union bucket.
 	defer func() {
 		if retErr != nil {
-			retErr = errors.Join(retErr, workspaceDepManager.UpdateBufLockFile(ctx, existingDepModuleKeys))
+			retErr = errors.Join(
+				retErr,
+				workspaceDepManager.UpdateBufLockFile(ctx, existingDepModuleKeys),
+				workspaceDepManager.UpdateBufLockFileGitDepKeys(ctx, existingGitDepKeys),
+			)
 		}
 	}()
 	// Edit the buf.lock file with the unpruned dependencies.
 	if err := workspaceDepManager.UpdateBufLockFile(ctx, configuredDepModuleKeys); err != nil {
 		return err
 	}
+	if err := workspaceDepManager.UpdateBufLockFileGitDepKeys(ctx, configuredGitDepKeys); err != nil {
+		return err
+	}
+	// If a vendor directory is configured, the workspace only reads dependencies from it,
+	// so it must be refreshed before we build.
+	if err := internal.UpdateVendorDir(ctx, container, workspaceDepManager, configuredDepModuleKeys); err != nil {
+		return err
+	}
 	workspace, err := controller.GetWorkspace(ctx, dirPath, bufctl.WithIgnoreAndDisallowV1BufWorkYAMLs())
 	if err != nil {
 		return err
@@ -0,0 +1,105 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depvendor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/internal"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+)
+
+// NewCommand returns a new vendor Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	return &appcmd.Command{
+		Use:   name + " <directory>",
+		Short: "Vendor the dependencies in a buf.lock into a local directory",
+		Long: `Download the dependencies pinned in buf.lock and write them to the vendor
+directory configured in buf.yaml:
+
+    version: v2
+    deps:
+      - buf.build/googleapis/googleapis
+    vendor: buf_vendor
+
+Once a vendor directory is configured, dependencies are only read from it and
+are never fetched from the network or the cache. Builds fail with an error if a
+dependency in buf.lock is missing from the vendor directory. The digests of all
+vendored files are verified against buf.lock when they are read.
+
+The vendor directory is replaced in its entirety, and is refreshed
+automatically by "buf dep update". Vendoring requires a v2 buf.yaml.
+
+The first argument is the directory of your buf.yaml configuration file.
+Defaults to "." if no argument is specified.`,
+		Args: appcmd.MaximumNArgs(1),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container)
+			},
+		),
+	}
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+) error {
+	dirPath := "."
+	if container.NumArgs() > 0 {
+		dirPath = container.Arg(0)
+	}
+	controller, err := bufcli.NewController(ctx, container)
+	if err != nil {
+		return err
+	}
+	workspaceDepManager, err := controller.GetWorkspaceDepManager(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+	vendorDirPath, err := workspaceDepManager.VendorDirPath(ctx)
+	if err != nil {
+		return err
+	}
+	if vendorDirPath == "" {
+		return errors.New(`no vendor directory configured, set "vendor" in your v2 buf.yaml`)
+	}
+	depModuleKeys, err := workspaceDepManager.ExistingBufLockFileDepModuleKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if err := internal.UpdateVendorDir(ctx, container, workspaceDepManager, depModuleKeys); err != nil {
+		return err
+	}
+	workspace, err := controller.GetWorkspace(ctx, dirPath, bufctl.WithIgnoreAndDisallowV1BufWorkYAMLs())
+	if err != nil {
+		return err
+	}
+	// Validate that the workspace builds from the vendor directory.
+	_, err = controller.GetImageForWorkspace(
+		ctx,
+		workspace,
+		// This is a performance optimization - we don't need source code info.
+		bufctl.WithImageExcludeSourceInfo(true),
+	)
+	return err
+}
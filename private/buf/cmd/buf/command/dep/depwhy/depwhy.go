@@ -0,0 +1,507 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depwhy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/buf/private/buf/bufcli"
+	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/buf/cmd/buf/command/dep/internal"
+	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/dag"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/stringutil"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	errorFormatFlagName     = "error-format"
+	disableSymlinksFlagName = "disable-symlinks"
+	formatFlagName          = "format"
+
+	textFormatString = "text"
+	jsonFormatString = "json"
+)
+
+var (
+	allFormatStrings = []string{
+		textFormatString,
+		jsonFormatString,
+	}
+)
+
+// NewCommand returns a new Command.
+func NewCommand(
+	name string,
+	builder appext.SubCommandBuilder,
+) *appcmd.Command {
+	flags := newFlags()
+	return &appcmd.Command{
+		Use:   name + " <dependency> <input>",
+		Short: "Print why a dependency is needed",
+		Long: `Print every dependency path from the target modules of the input to the given
+dependency, and the files of the target modules that use the dependency.
+
+The first argument is the dependency, which is one of:
+
+  - A module, such as "buf.build/googleapis/googleapis". Local modules can be given by
+    their path within the workspace. Every file of a target module that imports a file
+    from the module is printed.
+  - A file, such as "google/type/date.proto". Every file of a target module that imports
+    the file is printed.
+  - A fully-qualified type, such as "google.type.Date". Every field, extension and method
+    of a target module that references the type is printed.
+
+For example:
+
+    $ buf dep why buf.build/googleapis/googleapis
+    Paths:
+      buf.build/acme/weather -> buf.build/googleapis/googleapis
+      buf.build/acme/weather -> buf.build/acme/units -> buf.build/googleapis/googleapis
+
+    References:
+      acme/weather/v1/weather.proto -> google/type/date.proto
+
+If no target module depends on the dependency, an error is returned.
+
+The second argument is the source or module to query, which must be one of format ` +
+			buffetch.SourceOrModuleFormatsString + `.
+This defaults to "." if no argument is specified.`,
+		Args: appcmd.RangeArgs(1, 2),
+		Run: builder.NewRunFunc(
+			func(ctx context.Context, container appext.Container) error {
+				return run(ctx, container, flags)
+			},
+		),
+		BindFlags: flags.Bind,
+	}
+}
+
+type flags struct {
+	ErrorFormat     string
+	DisableSymlinks bool
+	Format          string
+}
+
+func newFlags() *flags {
+	return &flags{}
+}
+
+func (f *flags) Bind(flagSet *pflag.FlagSet) {
+	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	flagSet.StringVar(
+		&f.ErrorFormat,
+		errorFormatFlagName,
+		"text",
+		fmt.Sprintf(
+			"The format for build errors printed to stderr. Must be one of %s",
+			stringutil.SliceToString(bufanalysis.AllFormatStrings),
+		),
+	)
+	flagSet.StringVar(
+		&f.Format,
+		formatFlagName,
+		textFormatString,
+		fmt.Sprintf(
+			"The format to print the result as. Must be one of %s",
+			stringutil.SliceToString(allFormatStrings),
+		),
+	)
+}
+
+func run(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) error {
+	dependency := container.Arg(0)
+	if dependency == "" {
+		return appcmd.NewInvalidArgumentError("dependency is empty")
+	}
+	input := "."
+	if container.NumArgs() > 1 {
+		input = container.Arg(1)
+	}
+	if !slices.Contains(allFormatStrings, flags.Format) {
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+	controller, err := bufcli.NewController(
+		ctx,
+		container,
+		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
+		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
+	)
+	if err != nil {
+		return err
+	}
+	workspace, err := controller.GetWorkspace(ctx, input)
+	if err != nil {
+		return err
+	}
+	image, err := controller.GetImageForWorkspace(
+		ctx,
+		workspace,
+		// This is a performance optimization - we don't need source code info.
+		bufctl.WithImageExcludeSourceInfo(true),
+	)
+	if err != nil {
+		return err
+	}
+	subject, err := getSubject(ctx, workspace, image, dependency)
+	if err != nil {
+		return err
+	}
+	graph, err := bufmodule.ModuleSetToDAG(workspace)
+	if err != nil {
+		return err
+	}
+	var paths [][]bufmodule.Module
+	// Shared by all target modules, as their dependencies overlap.
+	opaqueIDToModulePaths := make(map[string][][]bufmodule.Module)
+	for _, targetModule := range bufmodule.ModuleSetTargetModules(workspace) {
+		if targetModule.OpaqueID() == subject.module.OpaqueID() {
+			continue
+		}
+		targetModulePaths, err := getModulePaths(graph, targetModule, subject.module, opaqueIDToModulePaths)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, targetModulePaths...)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s is not a dependency of any target module of %s", dependency, input)
+	}
+	references, err := getReferences(ctx, workspace, image, subject)
+	if err != nil {
+		return err
+	}
+	externalPaths := slicesext.Map(
+		paths,
+		func(path []bufmodule.Module) []string {
+			return slicesext.Map(path, internal.ModuleFullNameOrOpaqueID)
+		},
+	)
+	sortExternalPaths(externalPaths)
+	externalResult := externalResult{
+		Dependency: dependency,
+		Module:     internal.ModuleFullNameOrOpaqueID(subject.module),
+		Paths:      externalPaths,
+		References: references,
+	}
+	switch flags.Format {
+	case textFormatString:
+		return printText(container, externalResult)
+	case jsonFormatString:
+		data, err := json.Marshal(externalResult)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(container.Stdout(), string(data))
+		return err
+	default:
+		return appcmd.NewInvalidArgumentErrorf("invalid value for --%s: %s", formatFlagName, flags.Format)
+	}
+}
+
+// subject is the dependency that was queried.
+type subject struct {
+	// module is the module that contains the dependency.
+	module bufmodule.Module
+	// filePaths are the files of the dependency. Files of target modules that import one
+	// of these files reference the dependency.
+	//
+	// Empty if typeName is set.
+	filePaths map[string]struct{}
+	// typeName is the fully-qualified name of the type if a type was queried.
+	typeName protoreflect.FullName
+}
+
+func getSubject(
+	ctx context.Context,
+	workspace bufmodule.ModuleSet,
+	image bufimage.Image,
+	dependency string,
+) (*subject, error) {
+	moduleReadBucket := bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(workspace)
+	switch {
+	case strings.HasSuffix(dependency, ".proto"):
+		fileInfo, err := moduleReadBucket.StatFileInfo(ctx, dependency)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("file %s was not found in the workspace or its dependencies", dependency)
+			}
+			return nil, err
+		}
+		return &subject{
+			module: fileInfo.Module(),
+			filePaths: map[string]struct{}{
+				dependency: {},
+			},
+		}, nil
+	case strings.Contains(dependency, "/"):
+		var module bufmodule.Module
+		if moduleFullName, err := bufmodule.ParseModuleFullName(dependency); err == nil {
+			module = workspace.GetModuleForModuleFullName(moduleFullName)
+		}
+		if module == nil {
+			module = workspace.GetModuleForOpaqueID(dependency)
+		}
+		if module == nil {
+			return nil, fmt.Errorf("module %s was not found in the workspace or its dependencies", dependency)
+		}
+		filePaths, err := bufmodule.GetFilePaths(ctx, bufmodule.ModuleReadBucketWithOnlyProtoFiles(module))
+		if err != nil {
+			return nil, err
+		}
+		return &subject{
+			module:    module,
+			filePaths: slicesext.ToStructMap(filePaths),
+		}, nil
+	default:
+		typeName := protoreflect.FullName(strings.TrimPrefix(dependency, "."))
+		if !typeName.IsValid() {
+			return nil, fmt.Errorf("%s is not a valid module, file, or type", dependency)
+		}
+		descriptor, err := image.Resolver().FindDescriptorByName(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("type %s was not found in the workspace or its dependencies", dependency)
+		}
+		fileInfo, err := moduleReadBucket.StatFileInfo(ctx, descriptor.ParentFile().Path())
+		if err != nil {
+			return nil, err
+		}
+		return &subject{
+			module:   fileInfo.Module(),
+			typeName: typeName,
+		}, nil
+	}
+}
+
+// getModulePaths returns every path in the graph from the module to the dependency.
+//
+// The paths from each module are memoized in opaqueIDToModulePaths, so that each module
+// is only visited once no matter how many paths lead to it.
+func getModulePaths(
+	graph *dag.Graph[string, bufmodule.Module],
+	from bufmodule.Module,
+	to bufmodule.Module,
+	opaqueIDToModulePaths map[string][][]bufmodule.Module,
+) ([][]bufmodule.Module, error) {
+	if from.OpaqueID() == to.OpaqueID() {
+		return [][]bufmodule.Module{{from}}, nil
+	}
+	if paths, ok := opaqueIDToModulePaths[from.OpaqueID()]; ok {
+		return paths, nil
+	}
+	deps, err := graph.OutboundNodes(from.OpaqueID())
+	if err != nil {
+		return nil, err
+	}
+	var paths [][]bufmodule.Module
+	for _, dep := range deps {
+		depPaths, err := getModulePaths(graph, dep, to, opaqueIDToModulePaths)
+		if err != nil {
+			return nil, err
+		}
+		for _, depPath := range depPaths {
+			paths = append(paths, append([]bufmodule.Module{from}, depPath...))
+		}
+	}
+	opaqueIDToModulePaths[from.OpaqueID()] = paths
+	return paths, nil
+}
+
+// getReferences returns the references to the subject from the files of the target
+// modules, excluding the files of the subject module itself.
+func getReferences(
+	ctx context.Context,
+	workspace bufmodule.ModuleSet,
+	image bufimage.Image,
+	subject *subject,
+) ([]externalReference, error) {
+	moduleReadBucket := bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(workspace)
+	var references []externalReference
+	for _, imageFile := range image.Files() {
+		if imageFile.IsImport() {
+			continue
+		}
+		fileInfo, err := moduleReadBucket.StatFileInfo(ctx, imageFile.Path())
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.Module().OpaqueID() == subject.module.OpaqueID() {
+			continue
+		}
+		if subject.typeName != "" {
+			fileDescriptor, err := image.Resolver().FindFileByPath(imageFile.Path())
+			if err != nil {
+				return nil, err
+			}
+			for _, element := range getTypeReferences(fileDescriptor, subject.typeName) {
+				references = append(
+					references,
+					externalReference{
+						File:    imageFile.Path(),
+						Element: string(element),
+					},
+				)
+			}
+			continue
+		}
+		for _, dependency := range imageFile.FileDescriptorProto().GetDependency() {
+			if _, ok := subject.filePaths[dependency]; ok {
+				references = append(
+					references,
+					externalReference{
+						File:   imageFile.Path(),
+						Import: dependency,
+					},
+				)
+			}
+		}
+	}
+	return references, nil
+}
+
+// getTypeReferences returns the fields, extensions and methods within the file that
+// reference the type.
+func getTypeReferences(
+	fileDescriptor protoreflect.FileDescriptor,
+	typeName protoreflect.FullName,
+) []protoreflect.FullName {
+	var elements []protoreflect.FullName
+	addFields := func(fieldDescriptors protoreflect.FieldDescriptors) {
+		for i := 0; i < fieldDescriptors.Len(); i++ {
+			fieldDescriptor := fieldDescriptors.Get(i)
+			if fieldReferencesType(fieldDescriptor, typeName) {
+				elements = append(elements, fieldDescriptor.FullName())
+			}
+		}
+	}
+	addExtensions := func(extensionDescriptors protoreflect.ExtensionDescriptors) {
+		for i := 0; i < extensionDescriptors.Len(); i++ {
+			extensionDescriptor := extensionDescriptors.Get(i)
+			if fieldReferencesType(extensionDescriptor, typeName) {
+				elements = append(elements, extensionDescriptor.FullName())
+			}
+		}
+	}
+	var addMessages func(protoreflect.MessageDescriptors)
+	addMessages = func(messageDescriptors protoreflect.MessageDescriptors) {
+		for i := 0; i < messageDescriptors.Len(); i++ {
+			messageDescriptor := messageDescriptors.Get(i)
+			// Map entries are reported through the map field.
+			if messageDescriptor.IsMapEntry() {
+				continue
+			}
+			addFields(messageDescriptor.Fields())
+			addExtensions(messageDescriptor.Extensions())
+			addMessages(messageDescriptor.Messages())
+		}
+	}
+	addMessages(fileDescriptor.Messages())
+	addExtensions(fileDescriptor.Extensions())
+	services := fileDescriptor.Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			method := methods.Get(j)
+			if method.Input().FullName() == typeName || method.Output().FullName() == typeName {
+				elements = append(elements, method.FullName())
+			}
+		}
+	}
+	return elements
+}
+
+func fieldReferencesType(fieldDescriptor protoreflect.FieldDescriptor, typeName protoreflect.FullName) bool {
+	if fieldDescriptor.IsExtension() && fieldDescriptor.ContainingMessage().FullName() == typeName {
+		return true
+	}
+	if fieldDescriptor.IsMap() {
+		return fieldReferencesType(fieldDescriptor.MapKey(), typeName) ||
+			fieldReferencesType(fieldDescriptor.MapValue(), typeName)
+	}
+	if messageDescriptor := fieldDescriptor.Message(); messageDescriptor != nil {
+		return messageDescriptor.FullName() == typeName
+	}
+	if enumDescriptor := fieldDescriptor.Enum(); enumDescriptor != nil {
+		return enumDescriptor.FullName() == typeName
+	}
+	return false
+}
+
+func printText(container appext.Container, externalResult externalResult) error {
+	var lines []string
+	lines = append(lines, "Paths:")
+	for _, path := range externalResult.Paths {
+		lines = append(lines, "  "+strings.Join(path, " -> "))
+	}
+	if len(externalResult.References) > 0 {
+		lines = append(lines, "", "References:")
+		for _, reference := range externalResult.References {
+			if reference.Element != "" {
+				lines = append(lines, "  "+reference.File+": "+reference.Element)
+			} else {
+				lines = append(lines, "  "+reference.File+" -> "+reference.Import)
+			}
+		}
+	}
+	_, err := fmt.Fprintln(container.Stdout(), strings.Join(lines, "\n"))
+	return err
+}
+
+type externalResult struct {
+	Dependency string `json:"dependency,omitempty" yaml:"dependency,omitempty"`
+	// ModuleFullName if remote, OpaqueID if no ModuleFullName
+	Module     string              `json:"module,omitempty" yaml:"module,omitempty"`
+	Paths      [][]string          `json:"paths,omitempty" yaml:"paths,omitempty"`
+	References []externalReference `json:"references,omitempty" yaml:"references,omitempty"`
+}
+
+type externalReference struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Import is set if the file imports a file of the dependency.
+	Import string `json:"import,omitempty" yaml:"import,omitempty"`
+	// Element is set if a type was queried, and is the field, extension or method
+	// that references the type.
+	Element string `json:"element,omitempty" yaml:"element,omitempty"`
+}
+
+// sortExternalPaths sorts shorter paths first, and then alphabetically.
+func sortExternalPaths(externalPaths [][]string) {
+	slices.SortFunc(
+		externalPaths,
+		func(a []string, b []string) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return slices.Compare(a, b)
+		},
+	)
+}
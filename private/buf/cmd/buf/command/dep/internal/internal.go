@@ -36,7 +36,7 @@ func ModuleKeysAndTransitiveDepModuleKeysForModuleRefs(
 	moduleRefs []bufmodule.ModuleRef,
 	digestType bufmodule.DigestType,
 ) ([]bufmodule.ModuleKey, error) {
-	moduleKeyProvider, err := bufcli.NewModuleKeyProvider(container)
+	moduleKeyProvider, err := bufcli.NewModuleKeyProvider(ctx, container)
 	if err != nil {
 		return nil, err
 	}
@@ -108,6 +108,37 @@ func Prune(
 	return workspaceDepManager.UpdateBufLockFile(ctx, depModuleKeys)
 }
 
+// UpdateVendorDir fetches the ModuleDatas for the given ModuleKeys and writes them to
+// the vendor directory of the workspace, if one is configured.
+//
+// Used by dep update and dep vendor.
+func UpdateVendorDir(
+	ctx context.Context,
+	container appext.Container,
+	workspaceDepManager bufworkspace.WorkspaceDepManager,
+	depModuleKeys []bufmodule.ModuleKey,
+) error {
+	vendorDirPath, err := workspaceDepManager.VendorDirPath(ctx)
+	if err != nil {
+		return err
+	}
+	if vendorDirPath == "" {
+		return nil
+	}
+	var depModuleDatas []bufmodule.ModuleData
+	if len(depModuleKeys) > 0 {
+		moduleDataProvider, err := bufcli.NewModuleDataProvider(ctx, container)
+		if err != nil {
+			return err
+		}
+		depModuleDatas, err = moduleDataProvider.GetModuleDatasForModuleKeys(ctx, depModuleKeys)
+		if err != nil {
+			return err
+		}
+	}
+	return workspaceDepManager.UpdateVendorDir(ctx, depModuleDatas)
+}
+
 // LogUnusedConfiugredDepsForWorkspace takes a workspace and logs the unused configured
 // dependencies as warnings to the user.
 func LogUnusedConfiguredDepsForWorkspace(
@@ -132,6 +163,17 @@ func LogUnusedConfiguredDepsForWorkspace(
 	return nil
 }
 
+// ModuleFullNameOrOpaqueID returns the ModuleFullName for a module if available, otherwise
+// it returns the OpaqueID.
+//
+// Used by dep graph, dep sbom, and dep why.
+func ModuleFullNameOrOpaqueID(module bufmodule.Module) string {
+	if moduleFullName := module.ModuleFullName(); moduleFullName != nil {
+		return moduleFullName.String()
+	}
+	return module.OpaqueID()
+}
+
 // moduleKeysAndTransitiveDepModuleKeysForModuleKeys returns the ModuleKeys
 // and all the transitive dependencies.
 func moduleKeysAndTransitiveDepModuleKeysForModuleKeys(
@@ -139,7 +181,7 @@ func moduleKeysAndTransitiveDepModuleKeysForModuleKeys(
 	container appext.Container,
 	moduleKeys []bufmodule.ModuleKey,
 ) ([]bufmodule.ModuleKey, error) {
-	graphProvider, err := bufcli.NewGraphProvider(container)
+	graphProvider, err := bufcli.NewGraphProvider(ctx, container)
 	if err != nil {
 		return nil, err
 	}
@@ -17,19 +17,27 @@ package export
 import (
 	"context"
 	"errors"
+	"io"
 	"io/fs"
 	"os"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
+	"github.com/bufbuild/buf/private/buf/buffetch"
+	"github.com/bufbuild/buf/private/buf/bufformat"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/gen/data/datawkt"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
 	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/jhump/protoreflect/v2/protoprint"
 	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
@@ -49,9 +57,13 @@ func NewCommand(
 ) *appcmd.Command {
 	flags := newFlags()
 	return &appcmd.Command{
-		Use:   name + " <source>",
+		Use:   name + " <input>",
 		Short: "Export proto files from one location to another",
-		Long: bufcli.GetSourceOrModuleLong(`the source or module to export`) + `
+		Long: bufcli.GetInputLong(`the source, module, or image to export`) + `
+
+If the input is an image or a FileDescriptorSet, the .proto files are regenerated from the
+file descriptors, including the comments of their source code info, and then formatted
+as with buf format. Use --exclude-imports to only regenerate the files that are not imports.
 
 Examples:
 
@@ -74,6 +86,10 @@ Export a specific version of a remote module to a local directory.
 Export a git repo to a local directory.
 
     $ buf export https://github.com/owner/repository.git --output=<output-dir>
+
+Regenerate the proto files of an image to a local directory.
+
+    $ buf export image.binpb --output=<output-dir>
 `,
 		Args: appcmd.MaximumNArgs(1),
 		Run: builder.NewRunFunc(
@@ -133,12 +149,20 @@ func run(
 		return err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 	)
 	if err != nil {
 		return err
 	}
+	ref, err := buffetch.NewRefParser(container.Logger()).GetRef(ctx, input)
+	if err != nil {
+		return err
+	}
+	if _, ok := ref.(buffetch.MessageRef); ok {
+		return exportImage(ctx, controller, input, flags)
+	}
 	workspace, err := controller.GetWorkspace(
 		ctx,
 		input,
@@ -150,17 +174,7 @@ func run(
 	}
 	moduleReadBucket := bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(workspace)
 
-	if err := os.MkdirAll(flags.Output, 0755); err != nil {
-		return err
-	}
-	var options []storageos.ProviderOption
-	if !flags.DisableSymlinks {
-		options = append(options, storageos.ProviderWithSymlinks())
-	}
-	readWriteBucket, err := storageos.NewProvider(options...).NewReadWriteBucket(
-		flags.Output,
-		storageos.ReadWriteBucketWithSymlinksIfSupported(),
-	)
+	readWriteBucket, err := newOutputBucket(flags)
 	if err != nil {
 		return err
 	}
@@ -226,3 +240,80 @@ func run(
 	}
 	return nil
 }
+
+// exportImage regenerates the .proto files of the image input and writes them to the
+// output directory.
+//
+// All files of the image are regenerated, as an image contains the files it imports,
+// unless imports are excluded.
+func exportImage(
+	ctx context.Context,
+	controller bufctl.Controller,
+	input string,
+	flags *flags,
+) error {
+	// Imports are kept even if excluded, as the file descriptors of the files that
+	// are regenerated need their imports to be resolved.
+	image, err := controller.GetImage(
+		ctx,
+		input,
+		bufctl.WithTargetPaths(flags.Paths, flags.ExcludePaths),
+	)
+	if err != nil {
+		return err
+	}
+	resolver, err := protoencoding.NewResolver(bufimage.ImageToFileDescriptorProtos(image)...)
+	if err != nil {
+		return err
+	}
+	var fileDescriptors []protoreflect.FileDescriptor
+	for _, imageFile := range image.Files() {
+		if flags.ExcludeImports && imageFile.IsImport() {
+			continue
+		}
+		fileDescriptor, err := resolver.FindFileByPath(imageFile.Path())
+		if err != nil {
+			return err
+		}
+		fileDescriptors = append(fileDescriptors, fileDescriptor)
+	}
+	if len(fileDescriptors) == 0 {
+		return errors.New("no .proto target files found")
+	}
+	printBucket := storagemem.NewReadWriteBucket()
+	printer := &protoprint.Printer{}
+	if err := printer.PrintProtoFiles(
+		fileDescriptors,
+		func(path string) (io.WriteCloser, error) {
+			return printBucket.Put(ctx, path)
+		},
+	); err != nil {
+		return err
+	}
+	// The printer has its own layout, so the files are formatted to match the files
+	// that buf format would write.
+	formattedBucket, err := bufformat.FormatBucket(ctx, printBucket)
+	if err != nil {
+		return err
+	}
+	readWriteBucket, err := newOutputBucket(flags)
+	if err != nil {
+		return err
+	}
+	_, err = storage.Copy(ctx, formattedBucket, readWriteBucket)
+	return err
+}
+
+func newOutputBucket(flags *flags) (storage.ReadWriteBucket, error) {
+	if err := os.MkdirAll(flags.Output, 0755); err != nil {
+		return nil, err
+	}
+	var options []storageos.ProviderOption
+	if !flags.DisableSymlinks {
+		options = append(options, storageos.ProviderWithSymlinks())
+	}
+	return storageos.NewProvider(options...).NewReadWriteBucket(
+		flags.Output,
+		storageos.ReadWriteBucketWithSymlinksIfSupported(),
+	)
+}
@@ -16,6 +16,7 @@ package generate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -26,11 +27,13 @@ import (
 	"github.com/bufbuild/buf/private/buf/bufcli"
 	"github.com/bufbuild/buf/private/buf/bufctl"
 	"github.com/bufbuild/buf/private/buf/bufgen"
+	"github.com/bufbuild/buf/private/buf/bufworkspace"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
 	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appext"
+	"github.com/bufbuild/buf/private/pkg/chrometrace"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/stringutil"
 	"github.com/spf13/pflag"
@@ -50,6 +53,9 @@ const (
 	disableSymlinksFlagName     = "disable-symlinks"
 	typeFlagName                = "type"
 	typeDeprecatedFlagName      = "include-types"
+	profileFlagName             = "profile"
+	dumpRequestsFlagName        = "dump-requests"
+	affectedSinceFlagName       = "affected-since"
 )
 
 // NewCommand returns a new Command.
@@ -76,6 +82,10 @@ func NewCommand(
         # One of "remote", "local" and "protoc_builtin" is required.
       - remote: buf.build/protocolbuffers/go:v1.28.1
         # The relative output directory.
+        # If this ends in ".zip" or ".jar", the output is written to a zip or jar file.
+        # If this ends in ".tar", ".tar.gz" or ".tgz", the output is written to a tar
+        # file, gzipped for the latter two. If this is "-", the output is written to
+        # stdout as a tar stream, and all plugins with this out share the stream.
         # Required.
         out: gen/go
         # The revision of the remote plugin to use, a sequence number that Buf
@@ -97,6 +107,24 @@ func NewCommand(
         out: gen/es
         include_imports: true
         include_wkt: true
+        # Only generate for files in the given modules.
+        # Files without a module name never match.
+        # Optional.
+        modules:
+          - buf.build/acme/weather
+        # Only generate for the given paths. These are paths within the input, not
+        # relative to the current directory.
+        # Optional.
+        include_paths:
+          - acme/weather/v1
+        # Do not generate for the given paths.
+        # Optional.
+        exclude_paths:
+          - acme/weather/v1/internal
+        # Only generate for the given types, and the types they depend on.
+        # Optional.
+        types:
+          - acme.weather.v1.WeatherService
 
         # The full invocation of a local plugin can be specified as a list.
       - local: ["go", "run", "path/to/plugin.go"]
@@ -163,6 +191,16 @@ func NewCommand(
       #  - php_metadata_namespace
       #  - php_metadata_namespace_suffix
       #  - cc_enable_arenas
+      #  - swift_prefix
+      #  - py_generic_services
+      #
+      # Any other file option, such as an extension or a feature, can be set by its
+      # full name, for example "(acme.v1.options).level" or "features.(pb.go).api_level".
+      # Only scalar values are accepted for these options.
+      #
+      # There are no Kotlin or Dart file options in descriptor.proto. Kotlin code is
+      # generated with the java_* options, and the options of Dart plugins are custom
+      # file options that are set by their full name.
       #
       # An override rule can apply to a field option.
       # The accepted field options are:
@@ -193,6 +231,10 @@ func NewCommand(
           value: foo/bar/baz
           path: x/y/z
 
+          # Sets the custom file option "(acme.v1.options).level" to 3 for all files.
+        - file_option: (acme.v1.options).level
+          value: 3
+
           # Sets a field's "jstype" to "JS_NORMAL".
         - field_option: jstype
           value: JS_STRING
@@ -384,6 +426,9 @@ type flags struct {
 	// want to find out what will break if we do.
 	Types           []string
 	TypesDeprecated []string
+	Profile         string
+	DumpRequests    string
+	AffectedSince   string
 	// special
 	InputHashtag string
 }
@@ -397,6 +442,7 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
 	bufcli.BindPaths(flagSet, &f.Paths, pathsFlagName)
 	bufcli.BindExcludePaths(flagSet, &f.ExcludePaths, excludePathsFlagName)
+	bufcli.BindAffectedSince(flagSet, &f.AffectedSince, affectedSinceFlagName)
 	bindBoolPointer(
 		flagSet,
 		includeImportsFlagName,
@@ -429,7 +475,7 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 		flagSet,
 		deleteOutsFlagName,
 		&f.DeleteOuts,
-		`Prior to generation, delete the directories, jar files, or zip files that the plugins will write to. Allows cleaning of existing assets without having to call rm -rf`,
+		`Prior to generation, delete the directories, jar files, zip files, or tar files that the plugins will write to. Allows cleaning of existing assets without having to call rm -rf`,
 	)
 	flagSet.StringVar(
 		&f.ErrorFormat,
@@ -458,6 +504,18 @@ func (f *flags) Bind(flagSet *pflag.FlagSet) {
 		nil,
 		"The types (package, message, enum, extension, service, method) that should be included in this image. When specified, the resulting image will only include descriptors to describe the requested types. Flag usage overrides buf.gen.yaml",
 	)
+	flagSet.StringVar(
+		&f.Profile,
+		profileFlagName,
+		"",
+		`Write a timeline of generation to the given file in the Chrome trace event format. The timeline includes building the image, executing each plugin, and writing the generated files, and can be viewed with chrome://tracing or https://ui.perfetto.dev`,
+	)
+	flagSet.StringVar(
+		&f.DumpRequests,
+		dumpRequestsFlagName,
+		"",
+		`Write every CodeGeneratorRequest sent to a plugin to the given directory in the binary format. The requests can be replayed with "buf beta plugin run"`,
+	)
 	_ = flagSet.MarkDeprecated(typeDeprecatedFlagName, fmt.Sprintf("use --%s instead", typeFlagName))
 	_ = flagSet.MarkHidden(typeDeprecatedFlagName)
 }
@@ -488,6 +546,7 @@ func run(
 		storageosProvider = storageos.NewProvider(storageos.ProviderWithSymlinks())
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -503,6 +562,14 @@ func run(
 	if err != nil {
 		return err
 	}
+	traceRecorder := chrometrace.NewNopRecorder()
+	if flags.Profile != "" {
+		traceRecorder = chrometrace.NewRecorder()
+		defer func() {
+			retErr = errors.Join(retErr, writeProfile(flags.Profile, traceRecorder))
+		}()
+	}
+	buildImageEvent := traceRecorder.Start("build image", bufgen.TraceCategoryImage, bufgen.TraceMainThreadID)
 	images, err := getInputImages(
 		ctx,
 		logger,
@@ -513,12 +580,27 @@ func run(
 		flags.Paths,
 		flags.ExcludePaths,
 		flags.Types,
+		flags.AffectedSince,
 	)
+	buildImageEvent.End()
 	if err != nil {
 		return err
 	}
+	if len(images) == 0 {
+		// All inputs were skipped as none of their modules were affected. We return early
+		// so that --clean does not delete the outputs.
+		logger.Info("no modules are affected by the changes since " + flags.AffectedSince)
+		return nil
+	}
 	generateOptions := []bufgen.GenerateOption{
 		bufgen.GenerateWithBaseOutDirPath(flags.BaseOutDirPath),
+		bufgen.GenerateWithTraceRecorder(traceRecorder),
+	}
+	if flags.DumpRequests != "" {
+		generateOptions = append(
+			generateOptions,
+			bufgen.GenerateWithDumpRequestsDirPath(flags.DumpRequests),
+		)
 	}
 	if flags.DeleteOuts != nil {
 		generateOptions = append(
@@ -551,6 +633,17 @@ func run(
 	)
 }
 
+func writeProfile(profilePath string, traceRecorder chrometrace.Recorder) (retErr error) {
+	file, err := os.Create(profilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, file.Close())
+	}()
+	return traceRecorder.Write(file)
+}
+
 func readBufGenYAMLFile(
 	ctx context.Context,
 	storageosProvider storageos.Provider,
@@ -587,6 +680,7 @@ func getInputImages(
 	targetPathsOverride []string,
 	excludePathsOverride []string,
 	includeTypesOverride []string,
+	affectedSince string,
 ) ([]bufimage.Image, error) {
 	// If input is specified on the command line, we use that. If input is not
 	// specified on the command line, use the default input.
@@ -608,8 +702,12 @@ func getInputImages(
 			bufctl.WithConfigOverride(moduleConfigOverride),
 			bufctl.WithTargetPaths(targetPathsOverride, excludePathsOverride),
 			bufctl.WithImageTypes(includeTypes),
+			bufctl.WithAffectedSince(affectedSince),
 		)
 		if err != nil {
+			if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+				return nil, nil
+			}
 			return nil, err
 		}
 		return []bufimage.Image{inputImage}, nil
@@ -636,8 +734,13 @@ func getInputImages(
 			bufctl.WithConfigOverride(moduleConfigOverride),
 			bufctl.WithTargetPaths(targetPaths, excludePaths),
 			bufctl.WithImageTypes(includeTypes),
+			bufctl.WithAffectedSince(affectedSince),
 		)
 		if err != nil {
+			if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+				// Skip inputs that have no affected modules.
+				continue
+			}
 			return nil, err
 		}
 		inputImages = append(inputImages, inputImage)
@@ -16,6 +16,7 @@ package generate
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -23,6 +24,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -41,6 +44,8 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
 // TODO FUTURE: this has to change if we split up this repository
@@ -170,6 +175,164 @@ func TestGenerateV2LocalPluginBasic(t *testing.T) {
 	require.Empty(t, string(diff))
 }
 
+func TestGenerateV2LocalPluginArchive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	input := filepath.Join("testdata", "v2", "local_plugin")
+	expected, err := storagemem.NewReadBucket(
+		map[string][]byte{
+			filepath.Join("a", "v1", "a.top-level-type-names.yaml"): []byte(`messages:
+    - a.v1.Bar
+    - a.v1.Foo
+`),
+			filepath.Join("b", "v1", "b.top-level-type-names.yaml"): []byte(`messages:
+    - b.v1.Bar
+    - b.v1.Foo
+`),
+		},
+	)
+	require.NoError(t, err)
+	newTemplate := func(out string) string {
+		return `version: v2
+plugins:
+  - local: protoc-gen-top-level-type-names-yaml
+    out: ` + out + `
+`
+	}
+	for _, out := range []string{"gen.tar", "gen.tar.gz", "gen.tgz"} {
+		tempDirPath := t.TempDir()
+		testRunSuccess(
+			t,
+			"--output",
+			tempDirPath,
+			"--template",
+			newTemplate(out),
+			input,
+		)
+		file, err := os.Open(filepath.Join(tempDirPath, out))
+		require.NoError(t, err)
+		var reader io.Reader = file
+		if out != "gen.tar" {
+			gzipReader, err := gzip.NewReader(file)
+			require.NoError(t, err)
+			reader = gzipReader
+		}
+		actual := storagemem.NewReadWriteBucket()
+		require.NoError(t, storagearchive.Untar(ctx, reader, actual))
+		require.NoError(t, file.Close())
+		diff, err := storage.DiffBytes(ctx, expected, actual)
+		require.NoError(t, err)
+		require.Empty(t, string(diff), out)
+	}
+	// stdout is not joined to the output directory.
+	stdout := bytes.NewBuffer(nil)
+	appcmdtesting.RunCommandSuccess(
+		t,
+		func(name string) *appcmd.Command {
+			return NewCommand(
+				name,
+				appext.NewBuilder(name),
+			)
+		},
+		internaltesting.NewEnvFunc(t),
+		nil,
+		stdout,
+		"--output",
+		t.TempDir(),
+		"--template",
+		newTemplate(`"-"`),
+		input,
+	)
+	actual := storagemem.NewReadWriteBucket()
+	require.NoError(t, storagearchive.Untar(ctx, stdout, actual))
+	diff, err := storage.DiffBytes(ctx, expected, actual)
+	require.NoError(t, err)
+	require.Empty(t, string(diff))
+}
+
+func TestGenerateProfile(t *testing.T) {
+	t.Parallel()
+	tempDirPath := t.TempDir()
+	profilePath := filepath.Join(tempDirPath, "profile.json")
+	testRunSuccess(
+		t,
+		"--output",
+		tempDirPath,
+		"--template",
+		filepath.Join("testdata", "v2", "local_plugin", "buf.basic.gen.yaml"),
+		"--profile",
+		profilePath,
+		filepath.Join("testdata", "v2", "local_plugin"),
+	)
+	data, err := os.ReadFile(profilePath)
+	require.NoError(t, err)
+	var trace struct {
+		TraceEvents []struct {
+			Name string         `json:"name"`
+			TID  int            `json:"tid"`
+			Args map[string]any `json:"args"`
+		} `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(data, &trace))
+	eventNames := make(map[string]struct{})
+	for _, event := range trace.TraceEvents {
+		eventNames[event.Name] = struct{}{}
+		if event.Name == "protoc-gen-top-level-type-names-yaml" {
+			assert.Equal(t, 1, event.TID)
+			assert.Equal(t, float64(2), event.Args["file_count"])
+			assert.NotZero(t, event.Args["request_size"])
+			assert.NotZero(t, event.Args["response_size"])
+		}
+	}
+	for _, expectedEventName := range []string{
+		"thread_name",
+		"build image",
+		"modify image",
+		"exec plugins",
+		"protoc-gen-top-level-type-names-yaml",
+		"build requests",
+		"exec",
+		"merge response protoc-gen-top-level-type-names-yaml",
+		"write files",
+	} {
+		assert.Contains(t, eventNames, expectedEventName)
+	}
+}
+
+func TestGenerateDumpRequests(t *testing.T) {
+	t.Parallel()
+	tempDirPath := t.TempDir()
+	dumpRequestsDirPath := filepath.Join(tempDirPath, "requests")
+	testRunSuccess(
+		t,
+		"--output",
+		tempDirPath,
+		"--template",
+		filepath.Join("testdata", "v2", "local_plugin", "buf.basic.gen.yaml"),
+		"--dump-requests",
+		dumpRequestsDirPath,
+		filepath.Join("testdata", "v2", "local_plugin"),
+	)
+	// The directory strategy results in one request per directory.
+	var filesToGenerate []string
+	for i := 0; i < 2; i++ {
+		data, err := os.ReadFile(
+			filepath.Join(
+				dumpRequestsDirPath,
+				"0",
+				"0_protoc-gen-top-level-type-names-yaml",
+				strconv.Itoa(i)+".binpb",
+			),
+		)
+		require.NoError(t, err)
+		request := &pluginpb.CodeGeneratorRequest{}
+		require.NoError(t, proto.Unmarshal(data, request))
+		filesToGenerate = append(filesToGenerate, request.GetFileToGenerate()...)
+	}
+	sort.Strings(filesToGenerate)
+	require.Equal(t, []string{"a/v1/a.proto", "b/v1/b.proto"}, filesToGenerate)
+}
+
 func TestGenerateV2LocalPluginTypes(t *testing.T) {
 	t.Parallel()
 	testRunTypeArgs := func(t *testing.T, expect map[string][]byte, args ...string) {
@@ -317,6 +480,31 @@ inputs:
 		"--type",
 		"b.v1.Bar",
 	)
+	// plugin-level filters
+	testRunTypeArgs(t, map[string][]byte{
+		filepath.Join("gen", "all", "a", "v1", "a.top-level-type-names.yaml"): []byte(`messages:
+    - a.v1.Bar
+    - a.v1.Foo
+`),
+		filepath.Join("gen", "all", "b", "v1", "b.top-level-type-names.yaml"): []byte(`messages:
+    - b.v1.Bar
+    - b.v1.Foo
+`),
+		filepath.Join("gen", "include", "a", "v1", "a.top-level-type-names.yaml"): []byte(`messages:
+    - a.v1.Bar
+    - a.v1.Foo
+`),
+		filepath.Join("gen", "exclude", "b", "v1", "b.top-level-type-names.yaml"): []byte(`messages:
+    - b.v1.Bar
+    - b.v1.Foo
+`),
+		filepath.Join("gen", "types", "b", "v1", "b.top-level-type-names.yaml"): []byte(`messages:
+    - b.v1.Foo
+`),
+	},
+		"--template",
+		filepath.Join("testdata", "v2", "local_plugin", "buf.plugin.filters.gen.yaml"),
+	)
 }
 
 func TestOutputFlag(t *testing.T) {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/bufbuild/buf/private/buf/bufcli"
@@ -47,6 +48,8 @@ const (
 	sourceControlURLFlagName   = "source-control-url"
 	gitMetadataFlagName        = "git-metadata"
 	excludeUnnamedFlagName     = "exclude-unnamed"
+	toDirFlagName              = "to-dir"
+	affectedSinceFlagName      = "affected-since"
 
 	// All deprecated.
 	tagFlagName      = "tag"
@@ -94,6 +97,8 @@ type flags struct {
 	SourceControlURL   string
 	ExcludeUnnamed     bool
 	GitMetadata        bool
+	ToDir              string
+	AffectedSince      string
 	// special
 	InputHashtag string
 }
@@ -105,6 +110,7 @@ func newFlags() *flags {
 func (f *flags) Bind(flagSet *pflag.FlagSet) {
 	bufcli.BindInputHashtag(flagSet, &f.InputHashtag)
 	bufcli.BindDisableSymlinks(flagSet, &f.DisableSymlinks, disableSymlinksFlagName)
+	bufcli.BindAffectedSince(flagSet, &f.AffectedSince, affectedSinceFlagName)
 	bufcli.BindCreateVisibility(flagSet, &f.CreateVisibility, createVisibilityFlagName, createFlagName)
 	flagSet.StringSliceVar(
 		&f.Labels,
@@ -172,6 +178,16 @@ If you set the --%s flag and/or --%s flag yourself, then the value(s) will be us
 		false,
 		"Only push named modules to the BSR. Named modules must not have any unnamed dependencies.",
 	)
+	flagSet.StringVar(
+		&f.ToDir,
+		toDirFlagName,
+		"",
+		`Push to a local registry directory instead of the BSR.
+The directory is created if it does not exist. Dependencies can then be resolved against
+this directory by setting BUF_LOCAL_REGISTRY to its path, which applies to all dependencies,
+so the same buf.yaml and buf.lock resolve against either the BSR or the directory. Remote
+dependencies of the pushed modules must already have been pushed to the directory.`,
+	)
 
 	flagSet.StringSliceVarP(&f.Tags, tagFlagName, tagFlagShortName, nil, useLabelInstead)
 	_ = flagSet.MarkHidden(tagFlagName)
@@ -215,10 +231,14 @@ func run(
 
 	workspace, err := getBuildableWorkspace(ctx, container, flags)
 	if err != nil {
+		if errors.Is(err, bufworkspace.ErrNoAffectedModules) {
+			container.Logger().Info("no modules are affected by the changes since " + flags.AffectedSince)
+			return nil
+		}
 		return err
 	}
 
-	uploader, err := bufcli.NewUploader(container)
+	uploader, err := getUploader(ctx, container, flags)
 	if err != nil {
 		return err
 	}
@@ -290,6 +310,21 @@ func run(
 	return err
 }
 
+func getUploader(
+	ctx context.Context,
+	container appext.Container,
+	flags *flags,
+) (bufmodule.Uploader, error) {
+	if flags.ToDir == "" {
+		return bufcli.NewUploader(container)
+	}
+	dirPath := strings.TrimPrefix(flags.ToDir, "file://")
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return nil, err
+	}
+	return bufcli.NewLocalRegistry(ctx, container, dirPath)
+}
+
 func getBuildableWorkspace(
 	ctx context.Context,
 	container appext.Container,
@@ -300,6 +335,7 @@ func getBuildableWorkspace(
 		return nil, err
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithDisableSymlinks(flags.DisableSymlinks),
 		bufctl.WithFileAnnotationErrorFormat(flags.ErrorFormat),
@@ -315,6 +351,7 @@ func getBuildableWorkspace(
 		// that we don't want to deal with. If we have a v1 workspace, just outlaw pushing the whole
 		// workspace, and force people into the pre-refactor behavior.
 		bufctl.WithIgnoreAndDisallowV1BufWorkYAMLs(),
+		bufctl.WithAffectedSince(flags.AffectedSince),
 	)
 	if err != nil {
 		return nil, err
@@ -337,6 +374,9 @@ func validateFlags(flags *flags) error {
 	if err := validateLabelFlags(flags); err != nil {
 		return err
 	}
+	if flags.ToDir != "" && bufcli.IsLocalRegistryTarball(flags.ToDir) {
+		return appcmd.NewInvalidArgumentErrorf("--%s must be a directory, not a tarball: %s", toDirFlagName, flags.ToDir)
+	}
 	return validateGitMetadataFlags(flags)
 }
 
@@ -15,6 +15,8 @@
 package buf
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"path/filepath"
 	"strings"
@@ -24,6 +26,8 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd"
 	"github.com/bufbuild/buf/private/pkg/app/appcmd/appcmdtesting"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/uuidutil"
 	"github.com/stretchr/testify/require"
 )
@@ -206,6 +210,257 @@ func TestGraphNoWarningsValidImportFromWorkspaceNamedModules(t *testing.T) {
 	)
 }
 
+func TestGraphFileLevel(t *testing.T) {
+	t.Parallel()
+	testRunStdoutWithCache(
+		t, nil, 0,
+		`digraph {
+
+  "students/v1/students.proto" -> "people/v1/people1.proto"
+  "students/v1/students.proto" -> "people/v1/people2.proto"
+
+}`,
+		"dep",
+		"graph",
+		filepath.Join("testdata", "imports", "success", "students"),
+		"--level",
+		"file",
+	)
+	testRunStdoutWithCache(
+		t, nil, 0,
+		`[{"name":"people/v1/people1.proto","module":"bufbuild.test/bufbot/people"},{"name":"people/v1/people2.proto","module":"bufbuild.test/bufbot/people"},{"name":"students/v1/students.proto","module":"bufbuild.test/bufbot/students","deps":["people/v1/people1.proto","people/v1/people2.proto"]}]`,
+		"dep",
+		"graph",
+		filepath.Join("testdata", "imports", "success", "students"),
+		"--level",
+		"file",
+		"--format",
+		"json",
+	)
+}
+
+func TestGraphPackageLevelCycle(t *testing.T) {
+	t.Parallel()
+	testRunStdout(
+		t, nil, 0,
+		`Nodes: 2
+Edges: 2
+Cycles: 1
+
+  a.v1, b.v1`,
+		"dep",
+		"graph",
+		filepath.Join("testdata", "depgraph", "package_cycle"),
+		"--level",
+		"package",
+		"--format",
+		"cycles",
+	)
+	testRunStdout(
+		t, nil, 0,
+		`flowchart LR
+  n0["a.v1"]
+  n1["b.v1"]
+  n0 --> n1
+  n1 --> n0`,
+		"dep",
+		"graph",
+		filepath.Join("testdata", "depgraph", "package_cycle"),
+		"--level",
+		"package",
+		"--format",
+		"mermaid",
+	)
+	testRunStderrContainsNoWarn(
+		t, nil, 1,
+		[]string{"cycle error: a.v1 -> b.v1 -> a.v1: use --format=cycles"},
+		"dep",
+		"graph",
+		filepath.Join("testdata", "depgraph", "package_cycle"),
+		"--level",
+		"package",
+	)
+}
+
+func TestDepWhyModule(t *testing.T) {
+	t.Parallel()
+	testRunStdoutWithCache(
+		t, nil, 0,
+		`Paths:
+  bufbuild.test/bufbot/students -> bufbuild.test/bufbot/people
+
+References:
+  students/v1/students.proto -> people/v1/people1.proto
+  students/v1/students.proto -> people/v1/people2.proto`,
+		"dep",
+		"why",
+		"bufbuild.test/bufbot/people",
+		filepath.Join("testdata", "imports", "success", "students"),
+	)
+	testRunStdout(
+		t, nil, 0,
+		`Paths:
+  buf.build/foo/mod-a -> buf.build/foo/mod-b
+
+References:
+  a/v1/a.proto -> b/v1/b.proto`,
+		"dep",
+		"why",
+		"buf.build/foo/mod-b",
+		filepath.Join("testdata", "imports", "success", "workspace", "valid_explicit_deps"),
+	)
+}
+
+func TestDepWhyFileAndType(t *testing.T) {
+	t.Parallel()
+	testRunStdoutWithCache(
+		t, nil, 0,
+		`{"dependency":"people/v1/people2.proto","module":"bufbuild.test/bufbot/people","paths":[["bufbuild.test/bufbot/students","bufbuild.test/bufbot/people"]],"references":[{"file":"students/v1/students.proto","import":"people/v1/people2.proto"}]}`,
+		"dep",
+		"why",
+		"people/v1/people2.proto",
+		filepath.Join("testdata", "imports", "success", "students"),
+		"--format",
+		"json",
+	)
+	testRunStdoutWithCache(
+		t, nil, 0,
+		`Paths:
+  bufbuild.test/bufbot/students -> bufbuild.test/bufbot/people
+
+References:
+  students/v1/students.proto: students.v1.Student.person`,
+		"dep",
+		"why",
+		"people.v1.Person1",
+		filepath.Join("testdata", "imports", "success", "students"),
+	)
+	testRunStderrContainsWithCache(
+		t, nil, 1,
+		[]string{"students.v1.Student is not a dependency of any target module"},
+		"dep",
+		"why",
+		"students.v1.Student",
+		filepath.Join("testdata", "imports", "success", "students"),
+	)
+	// The format is validated before the input is read.
+	testRunStderrContainsWithCache(
+		t, nil, 1,
+		[]string{"invalid value for --format: yaml"},
+		"dep",
+		"why",
+		"people.v1.Person1",
+		filepath.Join("testdata", "imports", "success", "does_not_exist"),
+		"--format",
+		"yaml",
+	)
+}
+
+func TestCacheVerifyAndPrune(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheDirPath := t.TempDir()
+	storageosProvider := storageos.NewProvider()
+	cacheBucket, err := storageosProvider.NewReadWriteBucket(cacheDirPath)
+	require.NoError(t, err)
+	// Copy the cache, then overwrite the people module with the corrupted people module.
+	//
+	// Only the module data is copied, as the tests that read from these caches also write
+	// compiled images, lock files, and last access times to them.
+	for _, dirPath := range []string{
+		filepath.Join("testdata", "imports", "cache"),
+		filepath.Join("testdata", "imports", "corrupted_cache_file"),
+	} {
+		readBucket, err := storageosProvider.NewReadWriteBucket(dirPath)
+		require.NoError(t, err)
+		_, err = storage.Copy(
+			ctx,
+			storage.FilterReadBucket(
+				readBucket,
+				storage.MatchAnd(
+					storage.MatchPathContained("v3/modules"),
+					storage.MatchNot(storage.MatchPathBase("last_access")),
+				),
+			),
+			cacheBucket,
+		)
+		require.NoError(t, err)
+	}
+	// Modules are verified against the digest of their cached commit, so only the people
+	// module can be verified.
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			cacheBucket,
+			"v3/commits/b5/bufbuild.test/fc7d540124fd42db92511c19a60a1d98.json",
+			[]byte(`{"version":"v1","owner":"bufbot","module":"people","create_time":"2024-01-01T00:00:00Z","digest":"b5:b22338d6faf2a727613841d760c9cbfd21af6950621a589df329e1fe6611125904c39e22a73e0aa8834006a514dbd084e6c33b6bef29c8e4835b4b9dec631465"}`),
+		),
+	)
+	newEnv := func(use string) map[string]string {
+		return map[string]string{
+			useEnvVar(use, "CACHE_DIR"): cacheDirPath,
+		}
+	}
+
+	stdout := bytes.NewBuffer(nil)
+	appcmdtesting.RunCommandExitCode(t, func(use string) *appcmd.Command { return NewRootCommand(use) }, 0, newEnv, nil, stdout, nil, "cache", "ls")
+	require.Contains(t, stdout.String(), "module  bufbuild.test/bufbot/people:fc7d540124fd42db92511c19a60a1d98 (b5)")
+	require.Contains(t, stdout.String(), "commit  bufbuild.test/bufbot/people:fc7d540124fd42db92511c19a60a1d98 (b5)")
+	require.Contains(t, stdout.String(), "4 entries")
+
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		func(use string) *appcmd.Command { return NewRootCommand(use) },
+		0,
+		[]string{
+			"evicted corrupt module bufbuild.test/bufbot/people:fc7d540124fd42db92511c19a60a1d98 (b5)",
+			"verified 1 entries, evicted 1 corrupt entries, skipped 2 entries that cannot be verified",
+		},
+		newEnv,
+		nil,
+		"cache", "verify",
+	)
+
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		func(use string) *appcmd.Command { return NewRootCommand(use) },
+		1,
+		[]string{"at least one of --older-than or --max-size must be set"},
+		newEnv,
+		nil,
+		"cache", "prune",
+	)
+	appcmdtesting.RunCommandExitCodeStderrContains(
+		t,
+		func(use string) *appcmd.Command { return NewRootCommand(use) },
+		0,
+		[]string{"evicted 3 entries"},
+		newEnv,
+		nil,
+		"cache", "prune", "--max-size", "0",
+	)
+	stdout.Reset()
+	appcmdtesting.RunCommandExitCode(t, func(use string) *appcmd.Command { return NewRootCommand(use) }, 0, newEnv, nil, stdout, nil, "cache", "ls")
+	require.Contains(t, stdout.String(), "0 entries, 0B total")
+}
+
+func testRunStdoutWithCache(t *testing.T, stdin io.Reader, expectedExitCode int, expectedStdout string, args ...string) {
+	appcmdtesting.RunCommandExitCodeStdout(
+		t,
+		func(use string) *appcmd.Command { return NewRootCommand(use) },
+		expectedExitCode,
+		expectedStdout,
+		func(use string) map[string]string {
+			return map[string]string{
+				useEnvVar(use, "CACHE_DIR"): filepath.Join("testdata", "imports", "cache"),
+			}
+		},
+		stdin,
+		args...,
+	)
+}
+
 func testRunStderrWithCache(t *testing.T, stdin io.Reader, expectedExitCode int, expectedStderr string, args ...string) {
 	appcmdtesting.RunCommandExitCodeStderr(
 		t,
@@ -91,6 +91,7 @@ func handle(
 		targetPaths = request.CodeGeneratorRequest().GetFileToGenerate()
 	}
 	controller, err := bufcli.NewController(
+		ctx,
 		container,
 		bufctl.WithFileAnnotationErrorFormat(externalConfig.ErrorFormat),
 	)
@@ -514,6 +514,11 @@ type externalGeneratePluginConfigV2 struct {
 	IncludeWKT     bool `json:"include_wkt,omitempty" yaml:"include_wkt,omitempty"`
 	// Strategy is only valid with ProtoBuiltin and Local.
 	Strategy *string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// Modules, IncludePaths, ExcludePaths and Types filter the image passed to this plugin.
+	Modules      []string `json:"modules,omitempty" yaml:"modules,omitempty"`
+	IncludePaths []string `json:"include_paths,omitempty" yaml:"include_paths,omitempty"`
+	ExcludePaths []string `json:"exclude_paths,omitempty" yaml:"exclude_paths,omitempty"`
+	Types        []string `json:"types,omitempty" yaml:"types,omitempty"`
 }
 
 // externalGenerateManagedConfigV2 represents the managed mode config in a v2 buf.gen.yaml file.
@@ -140,6 +140,8 @@ managed:
       field: foo.bar.Baz.field_name
       path: foo/v1
       field_option: jstype
+    - file_option: (acme.v1.options)
+      module: buf.build/acme/weather
   override:
     - file_option: java_package_prefix
       value: net
@@ -158,6 +160,16 @@ managed:
     - field_option: jstype
       value: JS_STRING
       field: package1.Message2.field3
+    - file_option: swift_prefix
+      value: ACME
+    - file_option: py_generic_services
+      module: buf.build/acme/petapis
+      value: true
+    - file_option: features.(pb.go).api_level
+      value: API_OPAQUE
+    - file_option: (acme.v1.options).level
+      path: foo/v1
+      value: 3
 plugins:
   - remote: buf.build/protocolbuffers/go
     revision: 1
@@ -171,6 +183,16 @@ plugins:
     out: gen/proto
   - local: /usr/bin/path/to/protoc-gen-validate
     out: gen/proto2
+  - local: protoc-gen-es
+    out: gen/es
+    modules:
+      - buf.build/acme/weather
+    include_paths:
+      - acme/weather/v1
+    exclude_paths:
+      - acme/weather/v1/internal
+    types:
+      - acme.weather.v1.WeatherService
   - local: ["go", "run", "google.golang.org/protobuf/cmd/protoc-gen-go"]
     out: gen/proto
     opt:
@@ -223,6 +245,8 @@ managed:
       module: buf.build/acme/petapis
       path: foo/v1
       field: foo.bar.Baz.field_name
+    - file_option: (acme.v1.options)
+      module: buf.build/acme/weather
   override:
     - file_option: java_package_prefix
       value: net
@@ -241,6 +265,16 @@ managed:
     - field_option: jstype
       field: package1.Message2.field3
       value: JS_STRING
+    - file_option: swift_prefix
+      value: ACME
+    - file_option: py_generic_services
+      module: buf.build/acme/petapis
+      value: true
+    - file_option: features.(pb.go).api_level
+      value: API_OPAQUE
+    - file_option: (acme.v1.options).level
+      path: foo/v1
+      value: 3
 plugins:
   - remote: buf.build/protocolbuffers/go
     revision: 1
@@ -254,6 +288,16 @@ plugins:
     out: gen/proto
   - local: /usr/bin/path/to/protoc-gen-validate
     out: gen/proto2
+  - local: protoc-gen-es
+    out: gen/es
+    modules:
+      - buf.build/acme/weather
+    include_paths:
+      - acme/weather/v1
+    exclude_paths:
+      - acme/weather/v1/internal
+    types:
+      - acme.weather.v1.WeatherService
   - local:
       - go
       - run
@@ -367,6 +411,61 @@ plugins:
 `),
 	)
 	require.ErrorContains(t, err, "at most one of file_option and field_option can be specified")
+
+	_, err = ReadBufGenYAMLFile(
+		strings.NewReader(`version: v2
+managed:
+  enabled: true
+  override:
+    - file_option: (acme.v1.options
+      value: 1
+plugins:
+  - local: protoc-gen-csharp
+    out: gen
+`),
+	)
+	require.ErrorContains(t, err, "unterminated extension name")
+
+	_, err = ReadBufGenYAMLFile(
+		strings.NewReader(`version: v2
+managed:
+  enabled: true
+  override:
+    - file_option: features
+      value: 1
+plugins:
+  - local: protoc-gen-csharp
+    out: gen
+`),
+	)
+	require.ErrorContains(t, err, `unknown file_option: "features"`)
+
+	_, err = ReadBufGenYAMLFile(
+		strings.NewReader(`version: v2
+managed:
+  enabled: true
+  override:
+    - file_option: (acme.v1.options).level
+      value:
+        - 1
+plugins:
+  - local: protoc-gen-csharp
+    out: gen
+`),
+	)
+	require.ErrorContains(t, err, "expected a scalar value")
+
+	_, err = ReadBufGenYAMLFile(
+		strings.NewReader(`version: v2
+managed:
+  disable:
+    - file_option: (acme.v1.options)
+      field: a.v1.Foo.bar
+plugins:
+  - local: protoc-gen-csharp
+`),
+	)
+	require.ErrorContains(t, err, "cannot disable a file option for a field")
 }
 
 func TestBufGenYAMLFilePluginConfigErrors(t *testing.T) {
@@ -71,6 +71,13 @@ type BufLockFile interface {
 	// Files with FileVersionV1Beta1 or FileVersionV1 will only have ModuleKeys with Digests of DigestTypeB4,
 	// while Files with FileVersionV2 will only have ModuleKeys with Digests of DigestTypeB5.
 	DepModuleKeys() []bufmodule.ModuleKey
+	// GitDepKeys returns the pinned git dependencies as specified in the buf.lock file.
+	//
+	// All GitDepKeys will have unique Locations.
+	// GitDepKeys are sorted by Location.
+	//
+	// Files with FileVersionV1Beta1 or FileVersionV1 will never have GitDepKeys.
+	GitDepKeys() []GitDepKey
 
 	isBufLockFile()
 }
@@ -79,8 +86,29 @@ type BufLockFile interface {
 //
 // Note that digests are lazily-loaded; if you need to ensure that all digests are valid, run
 // ValidateBufLockFileDigests().
-func NewBufLockFile(fileVersion FileVersion, depModuleKeys []bufmodule.ModuleKey) (BufLockFile, error) {
-	return newBufLockFile(fileVersion, nil, depModuleKeys)
+func NewBufLockFile(
+	fileVersion FileVersion,
+	depModuleKeys []bufmodule.ModuleKey,
+	options ...NewBufLockFileOption,
+) (BufLockFile, error) {
+	bufLockFileNewOptions := newBufLockFileNewOptions()
+	for _, option := range options {
+		option(bufLockFileNewOptions)
+	}
+	return newBufLockFile(fileVersion, nil, depModuleKeys, bufLockFileNewOptions.gitDepKeys)
+}
+
+// NewBufLockFileOption is an option for a new BufLockFile.
+type NewBufLockFileOption func(*bufLockFileNewOptions)
+
+// NewBufLockFileWithGitDepKeys returns a new NewBufLockFileOption that sets the
+// pinned git dependencies.
+//
+// Only valid for v2 buf.lock files.
+func NewBufLockFileWithGitDepKeys(gitDepKeys ...GitDepKey) NewBufLockFileOption {
+	return func(bufLockFileNewOptions *bufLockFileNewOptions) {
+		bufLockFileNewOptions.gitDepKeys = append(bufLockFileNewOptions.gitDepKeys, gitDepKeys...)
+	}
 }
 
 // GetBufLockFileForPrefix gets the buf.lock file at the given bucket prefix.
@@ -183,16 +211,24 @@ type bufLockFile struct {
 	fileVersion   FileVersion
 	objectData    ObjectData
 	depModuleKeys []bufmodule.ModuleKey
+	gitDepKeys    []GitDepKey
 }
 
 func newBufLockFile(
 	fileVersion FileVersion,
 	objectData ObjectData,
 	depModuleKeys []bufmodule.ModuleKey,
+	gitDepKeys []GitDepKey,
 ) (*bufLockFile, error) {
 	if err := validateNoDuplicateModuleKeysByModuleFullName(depModuleKeys); err != nil {
 		return nil, err
 	}
+	if len(gitDepKeys) > 0 && fileVersion != FileVersionV2 {
+		return nil, fmt.Errorf("%s lock files cannot have git deps", fileVersion)
+	}
+	if err := validateNoDuplicateGitDepKeysByLocation(gitDepKeys); err != nil {
+		return nil, err
+	}
 	switch fileVersion {
 	case FileVersionV1Beta1, FileVersionV1:
 		if err := validateExpectedDigestType(depModuleKeys, fileVersion, bufmodule.DigestTypeB4); err != nil {
@@ -213,10 +249,18 @@ func newBufLockFile(
 			return depModuleKeys[i].ModuleFullName().String() < depModuleKeys[j].ModuleFullName().String()
 		},
 	)
+	gitDepKeys = slicesext.Copy(gitDepKeys)
+	sort.Slice(
+		gitDepKeys,
+		func(i int, j int) bool {
+			return gitDepKeys[i].Location() < gitDepKeys[j].Location()
+		},
+	)
 	bufLockFile := &bufLockFile{
 		fileVersion:   fileVersion,
 		objectData:    objectData,
 		depModuleKeys: depModuleKeys,
+		gitDepKeys:    gitDepKeys,
 	}
 	if err := validateV1AndV1Beta1DepsHaveCommits(bufLockFile); err != nil {
 		return nil, err
@@ -240,6 +284,10 @@ func (l *bufLockFile) DepModuleKeys() []bufmodule.ModuleKey {
 	return l.depModuleKeys
 }
 
+func (l *bufLockFile) GitDepKeys() []GitDepKey {
+	return l.gitDepKeys
+}
+
 func (*bufLockFile) isBufLockFile() {}
 func (*bufLockFile) isFile()        {}
 func (*bufLockFile) isFileInfo()    {}
@@ -314,15 +362,33 @@ func readBufLockFile(
 			}
 			depModuleKeys[i] = depModuleKey
 		}
-		return newBufLockFile(fileVersion, objectData, depModuleKeys)
+		return newBufLockFile(fileVersion, objectData, depModuleKeys, nil)
 	case FileVersionV2:
 		var externalBufLockFile externalBufLockFileV2
 		if err := getUnmarshalStrict(allowJSON)(data, &externalBufLockFile); err != nil {
 			return nil, fmt.Errorf("invalid as version %v: %w", fileVersion, err)
 		}
-		depModuleKeys := make([]bufmodule.ModuleKey, len(externalBufLockFile.Deps))
-		for i, dep := range externalBufLockFile.Deps {
+		var depModuleKeys []bufmodule.ModuleKey
+		var gitDepKeys []GitDepKey
+		for _, dep := range externalBufLockFile.Deps {
 			dep := dep
+			if dep.Git != "" {
+				if dep.Name != "" {
+					return nil, fmt.Errorf("dep %s cannot have both a name and git set", dep.Name)
+				}
+				if dep.Commit == "" {
+					return nil, fmt.Errorf("no commit specified for git dep %s", dep.Git)
+				}
+				if dep.Digest == "" {
+					return nil, fmt.Errorf("no digest specified for git dep %s", dep.Git)
+				}
+				gitDepKey, err := ParseGitDepKey(dep.Git, dep.Commit, dep.Digest)
+				if err != nil {
+					return nil, err
+				}
+				gitDepKeys = append(gitDepKeys, gitDepKey)
+				continue
+			}
 			if dep.Name == "" {
 				return nil, errors.New("no module name specified")
 			}
@@ -354,9 +420,9 @@ func readBufLockFile(
 			if err != nil {
 				return nil, err
 			}
-			depModuleKeys[i] = depModuleKey
+			depModuleKeys = append(depModuleKeys, depModuleKey)
 		}
-		return newBufLockFile(fileVersion, objectData, depModuleKeys)
+		return newBufLockFile(fileVersion, objectData, depModuleKeys, gitDepKeys)
 	default:
 		// This is a system error since we've already parsed.
 		return nil, syserror.Newf("unknown FileVersion: %v", fileVersion)
@@ -399,9 +465,10 @@ func writeBufLockFile(
 		return err
 	case FileVersionV2:
 		depModuleKeys := bufLockFile.DepModuleKeys()
+		gitDepKeys := bufLockFile.GitDepKeys()
 		externalBufLockFile := externalBufLockFileV2{
 			Version: fileVersion.String(),
-			Deps:    make([]externalBufLockFileDepV2, len(depModuleKeys)),
+			Deps:    make([]externalBufLockFileDepV2, len(depModuleKeys), len(depModuleKeys)+len(gitDepKeys)),
 		}
 		for i, depModuleKey := range depModuleKeys {
 			digest, err := depModuleKey.Digest()
@@ -414,6 +481,17 @@ func writeBufLockFile(
 				Digest: digest.String(),
 			}
 		}
+		// No need to sort - gitDepKeys is already sorted by Location, and module deps come first.
+		for _, gitDepKey := range gitDepKeys {
+			externalBufLockFile.Deps = append(
+				externalBufLockFile.Deps,
+				externalBufLockFileDepV2{
+					Git:    gitDepKey.Location(),
+					Commit: gitDepKey.Commit(),
+					Digest: gitDepKey.Digest().String(),
+				},
+			)
+		}
 		// No need to sort - depModuleKeys is already sorted by ModuleFullName
 		data, err := encoding.MarshalYAML(&externalBufLockFile)
 		if err != nil {
@@ -453,6 +531,18 @@ func validateNoDuplicateModuleKeysByModuleFullName(moduleKeys []bufmodule.Module
 	return nil
 }
 
+func validateNoDuplicateGitDepKeysByLocation(gitDepKeys []GitDepKey) error {
+	locationMap := make(map[string]struct{})
+	for _, gitDepKey := range gitDepKeys {
+		location := gitDepKey.Location()
+		if _, ok := locationMap[location]; ok {
+			return fmt.Errorf("duplicate git dep %q attempted to be added to lock file", location)
+		}
+		locationMap[location] = struct{}{}
+	}
+	return nil
+}
+
 func validateV1AndV1Beta1DepsHaveCommits(bufLockFile BufLockFile) error {
 	switch fileVersion := bufLockFile.FileVersion(); fileVersion {
 	case FileVersionV1Beta1, FileVersionV1:
@@ -528,7 +618,9 @@ type externalBufLockFileV2 struct {
 // externalBufLockFileDepV2 represents a single dep within a v2 buf.lock file.
 type externalBufLockFileDepV2 struct {
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
-	// Dashless
+	// Git is the location of a git dep, set instead of Name.
+	Git string `json:"git,omitempty" yaml:"git,omitempty"`
+	// Dashless for modules, the full git commit SHA for git deps.
 	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
 	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
 }
@@ -544,3 +636,11 @@ type bufLockFileOptions struct {
 func newBufLockFileOptions() *bufLockFileOptions {
 	return &bufLockFileOptions{}
 }
+
+type bufLockFileNewOptions struct {
+	gitDepKeys []GitDepKey
+}
+
+func newBufLockFileNewOptions() *bufLockFileNewOptions {
+	return &bufLockFileNewOptions{}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconfig
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufLockFileGitDepsRoundTrip(t *testing.T) {
+	t.Parallel()
+	data := `# Generated by buf. DO NOT EDIT.
+version: v2
+deps:
+  - name: buf.build/acme/date
+    commit: ffded0b4cf6b47cab74da08d291a3c2f
+    digest: b5:0c0e7900a42d04a00afef323858bc461deb73fbf01343d3de7dee05f40f8057af15771bed8b022740becc590b4a3824c301eb521a86a4c4c437998680113338c
+  - git: https://github.com/acme/protos.git#subdir=proto
+    commit: 9c8a2b1e4d3f5a6b7c8d9e0f1a2b3c4d5e6f7a8b
+    digest: shake256:c851121abe8095973c9bdc1b446089f3249cf74e0b64660b61d73bcae0350f4d59c7cacc464c532588794afd653ad0dddca9718a8a1e17f10544872535c31e0a
+`
+	bufLockFile, err := ReadBufLockFile(context.Background(), strings.NewReader(data), DefaultBufLockFileName)
+	require.NoError(t, err)
+	require.Len(t, bufLockFile.DepModuleKeys(), 1)
+	gitDepKeys := bufLockFile.GitDepKeys()
+	require.Len(t, gitDepKeys, 1)
+	require.Equal(t, "https://github.com/acme/protos.git", gitDepKeys[0].URL())
+	require.Equal(t, "proto", gitDepKeys[0].SubDirPath())
+	require.Equal(t, "9c8a2b1e4d3f5a6b7c8d9e0f1a2b3c4d5e6f7a8b", gitDepKeys[0].Commit())
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, WriteBufLockFile(buffer, bufLockFile))
+	assert.Equal(t, data, buffer.String())
+}
+
+func TestBufLockFileGitDepsInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := ReadBufLockFile(
+		context.Background(),
+		strings.NewReader(`version: v2
+deps:
+  - git: https://github.com/acme/protos.git#ref=main
+    commit: 9c8a2b1e4d3f5a6b7c8d9e0f1a2b3c4d5e6f7a8b
+    digest: shake256:c851121abe8095973c9bdc1b446089f3249cf74e0b64660b61d73bcae0350f4d59c7cacc464c532588794afd653ad0dddca9718a8a1e17f10544872535c31e0a
+`),
+		DefaultBufLockFileName,
+	)
+	require.ErrorContains(t, err, "cannot be set in a lock file")
+	_, err = ReadBufLockFile(
+		context.Background(),
+		strings.NewReader(`version: v2
+deps:
+  - git: https://github.com/acme/protos.git
+    commit: main
+    digest: shake256:c851121abe8095973c9bdc1b446089f3249cf74e0b64660b61d73bcae0350f4d59c7cacc464c532588794afd653ad0dddca9718a8a1e17f10544872535c31e0a
+`),
+		DefaultBufLockFileName,
+	)
+	require.ErrorContains(t, err, "must be a full git commit SHA")
+}
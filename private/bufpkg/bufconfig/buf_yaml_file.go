@@ -111,6 +111,25 @@ type BufYAMLFile interface {
 	// The ModuleRefs in this list will be unique by ModuleFullName.
 	// Sorted by ModuleFullName.
 	ConfiguredDepModuleRefs() []bufmodule.ModuleRef
+	// ConfiguredGitDepRefs returns the configured dependencies of the Workspace that are
+	// git repositories.
+	//
+	// The GitDepRefs in this list will be unique by Location.
+	// Sorted by Location.
+	//
+	// For v1 buf.yaml files, this will always return nil.
+	ConfiguredGitDepRefs() []GitDepRef
+	// VendorDirPath returns the directory that dependencies are vendored to, relative to the
+	// directory of the buf.yaml file.
+	//
+	// If set, dependencies are only read from this directory and never from the network.
+	//
+	// For v1 buf.yaml files, this will always return empty.
+	VendorDirPath() string
+	// DepPolicyConfig returns the policy that the module dependencies of the Workspace must satisfy.
+	//
+	// For v1 buf.yaml files, and for v2 buf.yaml files without a dep_policy, this will always return nil.
+	DepPolicyConfig() DepPolicyConfig
 	//IncludeDocsLink specifies whether a top-level comment with a link to our public docs
 	// should be included at the top of the buf.yaml file.
 	IncludeDocsLink() bool
@@ -140,6 +159,9 @@ func NewBufYAMLFile(
 		nil, // Do not set top-level breaking config, use only module configs
 		pluginConfigs,
 		configuredDepModuleRefs,
+		bufYAMLFileOptions.configuredGitDepRefs,
+		bufYAMLFileOptions.vendorDirPath,
+		bufYAMLFileOptions.depPolicyConfig,
 		bufYAMLFileOptions.includeDocsLink,
 	)
 }
@@ -156,6 +178,36 @@ func BufYAMLFileWithIncludeDocsLink() BufYAMLFileOption {
 	}
 }
 
+// BufYAMLFileWithVendorDirPath returns a new BufYAMLFileOption that sets the directory
+// that dependencies are vendored to.
+//
+// Only valid for v2 buf.yaml files.
+func BufYAMLFileWithVendorDirPath(vendorDirPath string) BufYAMLFileOption {
+	return func(bufYAMLFileOptions *bufYAMLFileOptions) {
+		bufYAMLFileOptions.vendorDirPath = vendorDirPath
+	}
+}
+
+// BufYAMLFileWithDepPolicyConfig returns a new BufYAMLFileOption that sets the policy
+// that the module dependencies must satisfy.
+//
+// Only valid for v2 buf.yaml files.
+func BufYAMLFileWithDepPolicyConfig(depPolicyConfig DepPolicyConfig) BufYAMLFileOption {
+	return func(bufYAMLFileOptions *bufYAMLFileOptions) {
+		bufYAMLFileOptions.depPolicyConfig = depPolicyConfig
+	}
+}
+
+// BufYAMLFileWithConfiguredGitDepRefs returns a new BufYAMLFileOption that sets the
+// dependencies that are git repositories.
+//
+// Only valid for v2 buf.yaml files.
+func BufYAMLFileWithConfiguredGitDepRefs(configuredGitDepRefs ...GitDepRef) BufYAMLFileOption {
+	return func(bufYAMLFileOptions *bufYAMLFileOptions) {
+		bufYAMLFileOptions.configuredGitDepRefs = append(bufYAMLFileOptions.configuredGitDepRefs, configuredGitDepRefs...)
+	}
+}
+
 // GetBufYAMLFileForPrefix gets the buf.yaml file at the given bucket prefix.
 //
 // The buf.yaml file will be attempted to be read at prefix/buf.yaml.
@@ -254,6 +306,9 @@ type bufYAMLFile struct {
 	topLevelBreakingConfig  BreakingConfig
 	pluginConfigs           []PluginConfig
 	configuredDepModuleRefs []bufmodule.ModuleRef
+	configuredGitDepRefs    []GitDepRef
+	vendorDirPath           string
+	depPolicyConfig         DepPolicyConfig
 	includeDocsLink         bool
 }
 
@@ -265,6 +320,9 @@ func newBufYAMLFile(
 	topLevelBreakingConfig BreakingConfig,
 	pluginConfigs []PluginConfig,
 	configuredDepModuleRefs []bufmodule.ModuleRef,
+	configuredGitDepRefs []GitDepRef,
+	vendorDirPath string,
+	depPolicyConfig DepPolicyConfig,
 	includeDocsLink bool,
 ) (*bufYAMLFile, error) {
 	if (fileVersion == FileVersionV1Beta1 || fileVersion == FileVersionV1) && len(moduleConfigs) > 1 {
@@ -289,6 +347,34 @@ func newBufYAMLFile(
 			return nil, fmt.Errorf("FileVersion %v was passed to NewBufYAMLFile but had BreakingConfig FileVersion %v", fileVersion, moduleConfig.BreakingConfig().FileVersion())
 		}
 	}
+	if vendorDirPath != "" {
+		if fileVersion != FileVersionV2 {
+			return nil, fmt.Errorf("vendor cannot be set for FileVersion %v", fileVersion)
+		}
+		normalVendorDirPath, err := normalpath.NormalizeAndValidate(vendorDirPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vendor path: %w", err)
+		}
+		if normalVendorDirPath == "." {
+			return nil, errors.New("vendor path cannot be the workspace root")
+		}
+		vendorDirPath = normalVendorDirPath
+	}
+	if depPolicyConfig != nil && fileVersion != FileVersionV2 {
+		return nil, fmt.Errorf("dep_policy cannot be set for FileVersion %v", fileVersion)
+	}
+	if len(configuredGitDepRefs) > 0 {
+		if fileVersion != FileVersionV2 {
+			return nil, fmt.Errorf("git deps cannot be set for FileVersion %v", fileVersion)
+		}
+		locationToGitDepRef := make(map[string]GitDepRef, len(configuredGitDepRefs))
+		for _, gitDepRef := range configuredGitDepRefs {
+			if _, ok := locationToGitDepRef[gitDepRef.Location()]; ok {
+				return nil, fmt.Errorf("git dep %s is specified more than once", gitDepRef.Location())
+			}
+			locationToGitDepRef[gitDepRef.Location()] = gitDepRef
+		}
+	}
 	// Zero values are not added to duplicates.
 	if _, err := bufmodule.ModuleFullNameStringToUniqueValue(moduleConfigs); err != nil {
 		return nil, err
@@ -312,6 +398,14 @@ func newBufYAMLFile(
 				configuredDepModuleRefs[j].ModuleFullName().String()
 		},
 	)
+	// To make sure we aren't editing input.
+	configuredGitDepRefs = slicesext.Copy(configuredGitDepRefs)
+	sort.Slice(
+		configuredGitDepRefs,
+		func(i int, j int) bool {
+			return configuredGitDepRefs[i].Location() < configuredGitDepRefs[j].Location()
+		},
+	)
 	return &bufYAMLFile{
 		fileVersion:             fileVersion,
 		objectData:              objectData,
@@ -320,6 +414,9 @@ func newBufYAMLFile(
 		topLevelBreakingConfig:  topLevelBreakingConfig,
 		pluginConfigs:           pluginConfigs,
 		configuredDepModuleRefs: configuredDepModuleRefs,
+		configuredGitDepRefs:    configuredGitDepRefs,
+		vendorDirPath:           vendorDirPath,
+		depPolicyConfig:         depPolicyConfig,
 		includeDocsLink:         includeDocsLink,
 	}, nil
 }
@@ -356,6 +453,18 @@ func (c *bufYAMLFile) ConfiguredDepModuleRefs() []bufmodule.ModuleRef {
 	return slicesext.Copy(c.configuredDepModuleRefs)
 }
 
+func (c *bufYAMLFile) ConfiguredGitDepRefs() []GitDepRef {
+	return slicesext.Copy(c.configuredGitDepRefs)
+}
+
+func (c *bufYAMLFile) VendorDirPath() string {
+	return c.vendorDirPath
+}
+
+func (c *bufYAMLFile) DepPolicyConfig() DepPolicyConfig {
+	return c.depPolicyConfig
+}
+
 func (c *bufYAMLFile) IncludeDocsLink() bool {
 	return c.includeDocsLink
 }
@@ -365,7 +474,10 @@ func (*bufYAMLFile) isFile()        {}
 func (*bufYAMLFile) isFileInfo()    {}
 
 type bufYAMLFileOptions struct {
-	includeDocsLink bool
+	configuredGitDepRefs []GitDepRef
+	vendorDirPath        string
+	depPolicyConfig      DepPolicyConfig
+	includeDocsLink      bool
 }
 
 func newBufYAMLFileOptions() *bufYAMLFileOptions {
@@ -455,6 +567,9 @@ func readBufYAMLFile(
 			breakingConfig,
 			nil,
 			configuredDepModuleRefs,
+			nil,
+			"",
+			nil,
 			includeDocsLink,
 		)
 	case FileVersionV2:
@@ -650,10 +765,23 @@ func readBufYAMLFile(
 			}
 			pluginConfigs = append(pluginConfigs, pluginConfig)
 		}
-		configuredDepModuleRefs, err := getConfiguredDepModuleRefsForExternalDeps(externalBufYAMLFile.Deps)
+		configuredDepModuleRefs, configuredGitDepRefs, err := getConfiguredDepsForExternalDepsV2(externalBufYAMLFile.Deps)
 		if err != nil {
 			return nil, err
 		}
+		var depPolicyConfig DepPolicyConfig
+		if !externalBufYAMLFile.DepPolicy.isEmpty() {
+			depPolicyConfig, err = newDepPolicyConfig(
+				externalBufYAMLFile.DepPolicy.Allow,
+				externalBufYAMLFile.DepPolicy.Deny,
+				externalBufYAMLFile.DepPolicy.DenyTransitive,
+				externalBufYAMLFile.DepPolicy.MaxDepth,
+				externalBufYAMLFile.DepPolicy.RequireLabel,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
 		return newBufYAMLFile(
 			fileVersion,
 			objectData,
@@ -662,6 +790,9 @@ func readBufYAMLFile(
 			topLevelBreakingConfig,
 			pluginConfigs,
 			configuredDepModuleRefs,
+			configuredGitDepRefs,
+			externalBufYAMLFile.Vendor,
+			depPolicyConfig,
 			includeDocsLink,
 		)
 	default:
@@ -759,11 +890,23 @@ func writeBufYAMLFile(writer io.Writer, bufYAMLFile BufYAMLFile) error {
 			Version: fileVersion.String(),
 		}
 		// Already sorted.
-		externalBufYAMLFile.Deps = slicesext.Map(
-			bufYAMLFile.ConfiguredDepModuleRefs(),
-			func(moduleRef bufmodule.ModuleRef) string {
-				return moduleRef.String()
-			},
+		externalBufYAMLFile.Deps = append(
+			slicesext.Map(
+				bufYAMLFile.ConfiguredDepModuleRefs(),
+				func(moduleRef bufmodule.ModuleRef) externalBufYAMLFileDepV2 {
+					return externalBufYAMLFileDepV2{
+						Module: moduleRef.String(),
+					}
+				},
+			),
+			slicesext.Map(
+				bufYAMLFile.ConfiguredGitDepRefs(),
+				func(gitDepRef GitDepRef) externalBufYAMLFileDepV2 {
+					return externalBufYAMLFileDepV2{
+						Git: gitDepRef.String(),
+					}
+				},
+			)...,
 		)
 		// Keep maps of the JSON-marshaled data to the external lint and breaking configs.
 		//
@@ -868,6 +1011,16 @@ func writeBufYAMLFile(writer io.Writer, bufYAMLFile BufYAMLFile) error {
 			externalPlugins = append(externalPlugins, externalPlugin)
 		}
 		externalBufYAMLFile.Plugins = externalPlugins
+		externalBufYAMLFile.Vendor = bufYAMLFile.VendorDirPath()
+		if depPolicyConfig := bufYAMLFile.DepPolicyConfig(); depPolicyConfig != nil {
+			externalBufYAMLFile.DepPolicy = externalBufYAMLFileDepPolicyV2{
+				Allow:          depPolicyConfig.Allow(),
+				Deny:           depPolicyConfig.Deny(),
+				DenyTransitive: depPolicyConfig.DenyTransitive(),
+				MaxDepth:       depPolicyConfig.MaxDepth(),
+				RequireLabel:   depPolicyConfig.RequireLabel(),
+			}
+		}
 
 		data, err := encoding.MarshalYAML(&externalBufYAMLFile)
 		if err != nil {
@@ -975,6 +1128,29 @@ func getConfiguredDepModuleRefsForExternalDeps(
 	return configuredDepModuleRefs, nil
 }
 
+func getConfiguredDepsForExternalDepsV2(
+	externalDeps []externalBufYAMLFileDepV2,
+) ([]bufmodule.ModuleRef, []GitDepRef, error) {
+	var configuredDepModuleRefs []bufmodule.ModuleRef
+	var configuredGitDepRefs []GitDepRef
+	for _, externalDep := range externalDeps {
+		if externalDep.Git != "" {
+			gitDepRef, err := ParseGitDepRef(externalDep.Git)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid dep: %w", err)
+			}
+			configuredGitDepRefs = append(configuredGitDepRefs, gitDepRef)
+			continue
+		}
+		moduleRef, err := bufmodule.ParseModuleRef(externalDep.Module)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid dep: %w", err)
+		}
+		configuredDepModuleRefs = append(configuredDepModuleRefs, moduleRef)
+	}
+	return configuredDepModuleRefs, configuredGitDepRefs, nil
+}
+
 func getLintConfigForExternalLintV1Beta1V1(
 	fileVersion FileVersion,
 	externalLint externalBufYAMLFileLintV1Beta1V1,
@@ -1273,13 +1449,92 @@ type externalBufYAMLFileV1Beta1V1 struct {
 // Note that the lint and breaking ids/categories DID change between versions, make
 // sure to deal with this when parsing what to set as defaults, or how to interpret categories.
 type externalBufYAMLFileV2 struct {
-	Version  string                                 `json:"version,omitempty" yaml:"version,omitempty"`
-	Name     string                                 `json:"name,omitempty" yaml:"name,omitempty"`
-	Modules  []externalBufYAMLFileModuleV2          `json:"modules,omitempty" yaml:"modules,omitempty"`
-	Deps     []string                               `json:"deps,omitempty" yaml:"deps,omitempty"`
-	Lint     externalBufYAMLFileLintV2              `json:"lint,omitempty" yaml:"lint,omitempty"`
-	Breaking externalBufYAMLFileBreakingV1Beta1V1V2 `json:"breaking,omitempty" yaml:"breaking,omitempty"`
-	Plugins  []externalBufYAMLFilePluginV2          `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Version   string                                 `json:"version,omitempty" yaml:"version,omitempty"`
+	Name      string                                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Modules   []externalBufYAMLFileModuleV2          `json:"modules,omitempty" yaml:"modules,omitempty"`
+	Deps      []externalBufYAMLFileDepV2             `json:"deps,omitempty" yaml:"deps,omitempty"`
+	Lint      externalBufYAMLFileLintV2              `json:"lint,omitempty" yaml:"lint,omitempty"`
+	Breaking  externalBufYAMLFileBreakingV1Beta1V1V2 `json:"breaking,omitempty" yaml:"breaking,omitempty"`
+	Plugins   []externalBufYAMLFilePluginV2          `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Vendor    string                                 `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	DepPolicy externalBufYAMLFileDepPolicyV2         `json:"dep_policy,omitempty" yaml:"dep_policy,omitempty"`
+}
+
+// externalBufYAMLFileDepV2 represents a single dep within a v2 buf.yaml file.
+//
+// A dep is either a module reference given as a plain string, or a git repository
+// given as an object with a git key.
+type externalBufYAMLFileDepV2 struct {
+	// Module is set if the dep was a plain string.
+	Module string `json:"-" yaml:"-"`
+	Git    string `json:"git,omitempty" yaml:"git,omitempty"`
+}
+
+// MarshalYAML implements the yaml.Marshaler interface. Module deps are written as plain strings.
+func (e externalBufYAMLFileDepV2) MarshalYAML() (interface{}, error) {
+	if e.Module != "" {
+		return e.Module, nil
+	}
+	type rawExternalBufYAMLFileDepV2 externalBufYAMLFileDepV2
+	return rawExternalBufYAMLFileDepV2(e), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. Module deps are written as plain strings.
+func (e externalBufYAMLFileDepV2) MarshalJSON() ([]byte, error) {
+	if e.Module != "" {
+		return json.Marshal(e.Module)
+	}
+	type rawExternalBufYAMLFileDepV2 externalBufYAMLFileDepV2
+	return json.Marshal(rawExternalBufYAMLFileDepV2(e))
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. This is done to accept both plain
+// string module deps and git deps.
+func (e *externalBufYAMLFileDepV2) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return e.unmarshalWith(unmarshal)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. This is done to accept both plain
+// string module deps and git deps.
+func (e *externalBufYAMLFileDepV2) UnmarshalJSON(data []byte) error {
+	unmarshal := func(v interface{}) error {
+		return json.Unmarshal(data, v)
+	}
+	return e.unmarshalWith(unmarshal)
+}
+
+// unmarshalWith is used to unmarshal into json/yaml. See https://abhinavg.net/posts/flexible-yaml for details.
+func (e *externalBufYAMLFileDepV2) unmarshalWith(unmarshal func(interface{}) error) error {
+	var module string
+	if err := unmarshal(&module); err == nil {
+		e.Module = module
+		return nil
+	}
+	type rawExternalBufYAMLFileDepV2 externalBufYAMLFileDepV2
+	if err := unmarshal((*rawExternalBufYAMLFileDepV2)(e)); err != nil {
+		return err
+	}
+	if e.Git == "" {
+		return errors.New("dep must be either a module name or have git set")
+	}
+	return nil
+}
+
+// externalBufYAMLFileDepPolicyV2 represents the dependency policy within a v2 buf.yaml file.
+type externalBufYAMLFileDepPolicyV2 struct {
+	Allow          []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny           []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	DenyTransitive []string `json:"deny_transitive,omitempty" yaml:"deny_transitive,omitempty"`
+	MaxDepth       int      `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	RequireLabel   bool     `json:"require_label,omitempty" yaml:"require_label,omitempty"`
+}
+
+func (ed externalBufYAMLFileDepPolicyV2) isEmpty() bool {
+	return len(ed.Allow) == 0 &&
+		len(ed.Deny) == 0 &&
+		len(ed.DenyTransitive) == 0 &&
+		ed.MaxDepth == 0 &&
+		!ed.RequireLabel
 }
 
 // externalBufYAMLFileModuleV2 represents a single module configuation within a v2 buf.yaml file.
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -480,6 +481,212 @@ modules:
       - proto/foo
 `,
 	)
+	testReadWriteBufYAMLFileRoundTrip(
+		t,
+		// input
+		`version: v2
+vendor: ./buf_vendor/
+deps:
+  - buf.build/acme/date
+`,
+		// expected output
+		`version: v2
+deps:
+  - buf.build/acme/date
+vendor: buf_vendor
+`,
+	)
+}
+
+func TestBufYAMLFileInvalidVendor(t *testing.T) {
+	t.Parallel()
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+vendor: ../buf_vendor
+`,
+		`invalid vendor path`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+vendor: .
+`,
+		`vendor path cannot be the workspace root`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v1
+vendor: buf_vendor
+`,
+		`vendor`,
+	)
+}
+
+func TestBufYAMLFileDepPolicy(t *testing.T) {
+	t.Parallel()
+	testReadWriteBufYAMLFileRoundTrip(
+		t,
+		// input
+		`version: v2
+deps:
+  - buf.build/acme/date:v1
+dep_policy:
+  require_label: true
+  max_depth: 2
+  deny_transitive:
+    - buf.build/evil
+  deny:
+    - buf.build/acme/extension
+  allow:
+    - buf.build/googleapis/googleapis
+    - buf.build/acme
+`,
+		// expected output
+		`version: v2
+deps:
+  - buf.build/acme/date:v1
+dep_policy:
+  allow:
+    - buf.build/acme
+    - buf.build/googleapis/googleapis
+  deny:
+    - buf.build/acme/extension
+  deny_transitive:
+    - buf.build/evil
+  max_depth: 2
+  require_label: true
+`,
+	)
+	bufYAMLFile := testReadBufYAMLFile(
+		t,
+		`version: v2
+dep_policy:
+  allow:
+    - buf.build/acme
+    - buf.build/googleapis/googleapis
+`,
+	)
+	depPolicyConfig := bufYAMLFile.DepPolicyConfig()
+	require.NotNil(t, depPolicyConfig)
+	for _, testCase := range []struct {
+		moduleFullName  string
+		expectedAllowed bool
+	}{
+		{moduleFullName: "buf.build/acme/date", expectedAllowed: true},
+		{moduleFullName: "buf.build/googleapis/googleapis", expectedAllowed: true},
+		{moduleFullName: "buf.build/googleapis/other", expectedAllowed: false},
+		{moduleFullName: "buf.example.com/acme/date", expectedAllowed: false},
+	} {
+		moduleFullName, err := bufmodule.ParseModuleFullName(testCase.moduleFullName)
+		require.NoError(t, err)
+		var allowed bool
+		for _, entry := range depPolicyConfig.Allow() {
+			if DepPolicyEntryMatches(entry, moduleFullName) {
+				allowed = true
+			}
+		}
+		require.Equal(t, testCase.expectedAllowed, allowed, testCase.moduleFullName)
+	}
+	require.Nil(t, testReadBufYAMLFile(t, "version: v2\n").DepPolicyConfig())
+}
+
+func TestBufYAMLFileInvalidDepPolicy(t *testing.T) {
+	t.Parallel()
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+dep_policy:
+  allow:
+    - buf.build
+`,
+		`dep_policy.allow`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+dep_policy:
+  deny_transitive:
+    - buf.build//foo
+`,
+		`dep_policy.deny_transitive`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+dep_policy:
+  max_depth: -1
+`,
+		`dep_policy.max_depth must be non-negative`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v1
+dep_policy:
+  max_depth: 1
+`,
+		`dep_policy`,
+	)
+}
+
+func TestBufYAMLFileGitDeps(t *testing.T) {
+	t.Parallel()
+	testReadWriteBufYAMLFileRoundTrip(
+		t,
+		// input
+		`version: v2
+deps:
+  - git: https://github.com/acme/protos.git#subdir=./proto/,ref=v1.2.0
+  - buf.build/acme/date
+  - git: https://github.com/acme/extra.git
+`,
+		// expected output
+		`version: v2
+deps:
+  - buf.build/acme/date
+  - git: https://github.com/acme/extra.git
+  - git: https://github.com/acme/protos.git#ref=v1.2.0,subdir=proto
+`,
+	)
+	bufYAMLFile := testReadBufYAMLFile(
+		t,
+		`version: v2
+deps:
+  - git: https://github.com/acme/protos.git#ref=v1.2.0,subdir=proto
+`,
+	)
+	require.Empty(t, bufYAMLFile.ConfiguredDepModuleRefs())
+	gitDepRefs := bufYAMLFile.ConfiguredGitDepRefs()
+	require.Len(t, gitDepRefs, 1)
+	require.Equal(t, "https://github.com/acme/protos.git", gitDepRefs[0].URL())
+	require.Equal(t, "v1.2.0", gitDepRefs[0].Ref())
+	require.Equal(t, "proto", gitDepRefs[0].SubDirPath())
+	require.Equal(t, "https://github.com/acme/protos.git#subdir=proto", gitDepRefs[0].Location())
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+deps:
+  - git: github.com/acme/protos.git
+`,
+		`invalid git dep URL`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+deps:
+  - git: https://github.com/acme/protos.git#branch=main
+`,
+		`unknown option "branch"`,
+	)
+	testReadBufYAMLFileFail(
+		t,
+		`version: v2
+deps:
+  - git: https://github.com/acme/protos.git#ref=v1
+  - git: https://github.com/acme/protos.git#ref=v2
+`,
+		`is specified more than once`,
+	)
 }
 
 func TestBufYAMLFileLintDisabled(t *testing.T) {
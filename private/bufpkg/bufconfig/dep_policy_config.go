@@ -0,0 +1,167 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+)
+
+// DepPolicyConfig is the policy that the module dependencies of a workspace must satisfy.
+//
+// The entries of the allow and deny lists are either module owners of the form
+// "registry/owner", which match every module of the owner, or module names of the
+// form "registry/owner/name".
+//
+// Only valid for v2 buf.yaml files.
+type DepPolicyConfig interface {
+	// Allow returns the module owners and names that the direct dependencies must match.
+	//
+	// If empty, all direct dependencies are allowed unless they are denied.
+	// Sorted.
+	Allow() []string
+	// Deny returns the module owners and names that the direct dependencies must not match.
+	//
+	// Sorted.
+	Deny() []string
+	// DenyTransitive returns the module owners and names that no dependency may match,
+	// including transitive dependencies.
+	//
+	// Sorted.
+	DenyTransitive() []string
+	// MaxDepth returns the maximum depth of a dependency, where the direct dependencies
+	// have a depth of 1.
+	//
+	// If 0, the depth is not limited.
+	MaxDepth() int
+	// RequireLabel returns true if the direct dependencies must be pinned to a label in
+	// the buf.yaml.
+	RequireLabel() bool
+
+	isDepPolicyConfig()
+}
+
+// NewDepPolicyConfig returns a new DepPolicyConfig.
+func NewDepPolicyConfig(
+	allow []string,
+	deny []string,
+	denyTransitive []string,
+	maxDepth int,
+	requireLabel bool,
+) (DepPolicyConfig, error) {
+	return newDepPolicyConfig(
+		allow,
+		deny,
+		denyTransitive,
+		maxDepth,
+		requireLabel,
+	)
+}
+
+// DepPolicyEntryMatches returns true if the entry of a DepPolicyConfig allow or deny list
+// matches the ModuleFullName.
+func DepPolicyEntryMatches(entry string, moduleFullName bufmodule.ModuleFullName) bool {
+	return entry == moduleFullName.String() ||
+		entry == moduleFullName.Registry()+"/"+moduleFullName.Owner()
+}
+
+// *** PRIVATE ***
+
+type depPolicyConfig struct {
+	allow          []string
+	deny           []string
+	denyTransitive []string
+	maxDepth       int
+	requireLabel   bool
+}
+
+func newDepPolicyConfig(
+	allow []string,
+	deny []string,
+	denyTransitive []string,
+	maxDepth int,
+	requireLabel bool,
+) (*depPolicyConfig, error) {
+	for _, fieldNameAndEntries := range []struct {
+		fieldName string
+		entries   []string
+	}{
+		{fieldName: "allow", entries: allow},
+		{fieldName: "deny", entries: deny},
+		{fieldName: "deny_transitive", entries: denyTransitive},
+	} {
+		for _, entry := range fieldNameAndEntries.entries {
+			if err := validateDepPolicyEntry(entry); err != nil {
+				return nil, fmt.Errorf("dep_policy.%s: %w", fieldNameAndEntries.fieldName, err)
+			}
+		}
+	}
+	if maxDepth < 0 {
+		return nil, fmt.Errorf("dep_policy.max_depth must be non-negative, got %d", maxDepth)
+	}
+	return &depPolicyConfig{
+		allow:          slicesext.ToUniqueSorted(allow),
+		deny:           slicesext.ToUniqueSorted(deny),
+		denyTransitive: slicesext.ToUniqueSorted(denyTransitive),
+		maxDepth:       maxDepth,
+		requireLabel:   requireLabel,
+	}, nil
+}
+
+func (d *depPolicyConfig) Allow() []string {
+	return slicesext.Copy(d.allow)
+}
+
+func (d *depPolicyConfig) Deny() []string {
+	return slicesext.Copy(d.deny)
+}
+
+func (d *depPolicyConfig) DenyTransitive() []string {
+	return slicesext.Copy(d.denyTransitive)
+}
+
+func (d *depPolicyConfig) MaxDepth() int {
+	return d.maxDepth
+}
+
+func (d *depPolicyConfig) RequireLabel() bool {
+	return d.requireLabel
+}
+
+func (*depPolicyConfig) isDepPolicyConfig() {}
+
+func validateDepPolicyEntry(entry string) error {
+	switch components := strings.Split(entry, "/"); len(components) {
+	case 2:
+		if components[0] == "" || components[1] == "" {
+			return fmt.Errorf("invalid module owner %q, must be of the form registry/owner", entry)
+		}
+		return nil
+	case 3:
+		if _, err := bufmodule.ParseModuleFullName(entry); err != nil {
+			return err
+		}
+		return nil
+	default:
+		if entry == "" {
+			return errors.New("entry cannot be empty")
+		}
+		return fmt.Errorf("invalid entry %q, must be a module owner of the form registry/owner or a module name of the form registry/owner/name", entry)
+	}
+}
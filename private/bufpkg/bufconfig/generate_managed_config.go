@@ -76,6 +76,12 @@ type ManagedDisableRule interface {
 	FileOption() FileOption
 	// FieldOption returns the field option to disalbe managed mode for.
 	FieldOption() FieldOption
+	// CustomFileOption returns the name of the custom file option to disable
+	// managed mode for, such as "(acme.v1.option)" or "features.(pb.go).api_level".
+	// This also disables the fields within the option, such as "(acme.v1.option).level".
+	// This is guaranteed to be empty if any of FieldName, FileOption and
+	// FieldOption is not empty.
+	CustomFileOption() string
 
 	isManagedDisableRule()
 }
@@ -97,9 +103,24 @@ func NewManagedDisableRule(
 	)
 }
 
+// NewManagedDisableRuleForCustomFileOption returns a new ManagedDisableRule for a
+// custom file option.
+func NewManagedDisableRuleForCustomFileOption(
+	path string,
+	moduleFullName string,
+	customFileOption string,
+) (ManagedDisableRule, error) {
+	return newManagedDisableRuleForCustomFileOption(
+		path,
+		moduleFullName,
+		customFileOption,
+	)
+}
+
 // ManagedOverrideRule is an override rule. An override describes:
 //
-//   - The options to modify. Exactly one of FileOption and FieldOption is not empty.
+//   - The options to modify. Exactly one of FileOption, FieldOption and
+//     CustomFileOption is not empty.
 //   - The value to modify these options with.
 //   - The files/fields for which the options are modified. If all of Path, ModuleFullName
 //   - or FieldName are empty, all files/fields are modified. Otherwise, only
@@ -119,7 +140,13 @@ type ManagedOverrideRule interface {
 	FileOption() FileOption
 	// FieldOption returns the field option to disable managed mode for.
 	FieldOption() FieldOption
+	// CustomFileOption returns the name of the custom file option to override,
+	// such as "(acme.v1.option)" or "features.(pb.go).api_level".
+	CustomFileOption() string
 	// Value returns the override value.
+	//
+	// For a CustomFileOption, this is the scalar value from the configuration.
+	// It is converted to the type of the option when the option is resolved.
 	Value() interface{}
 
 	isManagedOverrideRule()
@@ -157,6 +184,22 @@ func NewManagedOverrideRuleForFieldOption(
 	)
 }
 
+// NewManagedOverrideRuleForCustomFileOption returns a new ManagedOverrideRule for
+// a custom file option.
+func NewManagedOverrideRuleForCustomFileOption(
+	path string,
+	moduleFullName string,
+	customFileOption string,
+	value interface{},
+) (ManagedOverrideRule, error) {
+	return newCustomFileOptionManagedOverrideRule(
+		path,
+		moduleFullName,
+		customFileOption,
+		value,
+	)
+}
+
 // *** PRIVATE ***
 
 type generateManagedConfig struct {
@@ -405,6 +448,24 @@ func newGenerateManagedConfigFromExternalV2(
 	var disables []ManagedDisableRule
 	var overrides []ManagedOverrideRule
 	for _, externalDisableConfig := range externalConfig.Disable {
+		if isCustomFileOptionName(externalDisableConfig.FileOption) {
+			if externalDisableConfig.FieldOption != "" {
+				return nil, errors.New("at most one of file_option and field_option can be specified")
+			}
+			if externalDisableConfig.Field != "" {
+				return nil, errors.New("cannot disable a file option for a field")
+			}
+			disable, err := newManagedDisableRuleForCustomFileOption(
+				externalDisableConfig.Path,
+				externalDisableConfig.Module,
+				strings.TrimSpace(externalDisableConfig.FileOption),
+			)
+			if err != nil {
+				return nil, err
+			}
+			disables = append(disables, disable)
+			continue
+		}
 		var (
 			fileOption  FileOption
 			fieldOption FieldOption
@@ -465,6 +526,19 @@ func newGenerateManagedConfigFromExternalV2(
 		if externalOverrideConfig.Field != "" {
 			return nil, errors.New("must not set field for a file_option override")
 		}
+		if isCustomFileOptionName(externalOverrideConfig.FileOption) {
+			override, err := NewManagedOverrideRuleForCustomFileOption(
+				externalOverrideConfig.Path,
+				externalOverrideConfig.Module,
+				strings.TrimSpace(externalOverrideConfig.FileOption),
+				externalOverrideConfig.Value,
+			)
+			if err != nil {
+				return nil, err
+			}
+			overrides = append(overrides, override)
+			continue
+		}
 		fileOption, err := parseFileOption(externalOverrideConfig.FileOption)
 		if err != nil {
 			return nil, err
@@ -502,11 +576,12 @@ func (g *generateManagedConfig) Overrides() []ManagedOverrideRule {
 func (g *generateManagedConfig) isGenerateManagedConfig() {}
 
 type managedDisableRule struct {
-	path           string
-	moduleFullName string
-	fieldName      string
-	fileOption     FileOption
-	fieldOption    FieldOption
+	path             string
+	moduleFullName   string
+	fieldName        string
+	fileOption       FileOption
+	fieldOption      FieldOption
+	customFileOption string
 }
 
 func newManagedDisableRule(
@@ -544,6 +619,31 @@ func newManagedDisableRule(
 	}, nil
 }
 
+func newManagedDisableRuleForCustomFileOption(
+	path string,
+	moduleFullName string,
+	customFileOption string,
+) (ManagedDisableRule, error) {
+	if err := validateCustomFileOptionName(customFileOption); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := validatePath(path); err != nil {
+			return nil, fmt.Errorf("invalid path for disable rule: %w", err)
+		}
+	}
+	if moduleFullName != "" {
+		if _, err := bufmodule.ParseModuleFullName(moduleFullName); err != nil {
+			return nil, err
+		}
+	}
+	return &managedDisableRule{
+		path:             path,
+		moduleFullName:   moduleFullName,
+		customFileOption: customFileOption,
+	}, nil
+}
+
 func (m *managedDisableRule) Path() string {
 	return m.path
 }
@@ -564,15 +664,20 @@ func (m *managedDisableRule) FieldOption() FieldOption {
 	return m.fieldOption
 }
 
+func (m *managedDisableRule) CustomFileOption() string {
+	return m.customFileOption
+}
+
 func (m *managedDisableRule) isManagedDisableRule() {}
 
 type managedOverrideRule struct {
-	path           string
-	moduleFullName string
-	fieldName      string
-	fileOption     FileOption
-	fieldOption    FieldOption
-	value          interface{}
+	path             string
+	moduleFullName   string
+	fieldName        string
+	fileOption       FileOption
+	fieldOption      FieldOption
+	customFileOption string
+	value            interface{}
 }
 
 func newFileOptionManagedOverrideRule(
@@ -649,6 +754,40 @@ func newFieldOptionManagedOverrideRule(
 	}, nil
 }
 
+func newCustomFileOptionManagedOverrideRule(
+	path string,
+	moduleFullName string,
+	customFileOption string,
+	value interface{},
+) (*managedOverrideRule, error) {
+	if err := validateCustomFileOptionName(customFileOption); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("value must be specified for override")
+	}
+	parsedValue, err := parseOverrideValueCustomFileOption(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %v for %s: %w", value, customFileOption, err)
+	}
+	if moduleFullName != "" {
+		if _, err := bufmodule.ParseModuleFullName(moduleFullName); err != nil {
+			return nil, fmt.Errorf("invalid module name for %s override: %w", customFileOption, err)
+		}
+	}
+	if path != "" {
+		if err := validatePath(path); err != nil {
+			return nil, fmt.Errorf("invalid path for %s override: %w", customFileOption, err)
+		}
+	}
+	return &managedOverrideRule{
+		path:             path,
+		moduleFullName:   moduleFullName,
+		customFileOption: customFileOption,
+		value:            parsedValue,
+	}, nil
+}
+
 func (m *managedOverrideRule) Path() string {
 	return m.path
 }
@@ -669,6 +808,10 @@ func (m *managedOverrideRule) FieldOption() FieldOption {
 	return m.fieldOption
 }
 
+func (m *managedOverrideRule) CustomFileOption() string {
+	return m.customFileOption
+}
+
 func (m *managedOverrideRule) Value() interface{} {
 	return m.value
 }
@@ -749,7 +892,7 @@ func overrideRulesForPerFileOverridesV1(
 			overrideString := filePathToOverride[filePath]
 			var overrideValue interface{} = overrideString
 			switch fileOption {
-			case FileOptionCcEnableArenas, FileOptionJavaMultipleFiles, FileOptionJavaStringCheckUtf8:
+			case FileOptionCcEnableArenas, FileOptionJavaMultipleFiles, FileOptionJavaStringCheckUtf8, FileOptionPyGenericServices:
 				overrideValue, err = strconv.ParseBool(overrideString)
 				if err != nil {
 					return nil, fmt.Errorf("")
@@ -778,7 +921,7 @@ func newExternalManagedConfigV2FromGenerateManagedConfig(
 	}
 	var externalDisables []externalManagedDisableConfigV2
 	for _, disable := range managedConfig.Disables() {
-		var fileOptionName string
+		fileOptionName := disable.CustomFileOption()
 		if disable.FileOption() != FileOptionUnspecified {
 			fileOptionName = disable.FileOption().String()
 		}
@@ -807,9 +950,15 @@ func newExternalManagedConfigV2FromGenerateManagedConfig(
 		if override.FieldOption() != FieldOptionUnspecified {
 			fieldOptionName = override.FieldOption().String()
 		}
-		value, err := getOverrideValue(fileOptionName, fieldOptionName, override.Value())
-		if err != nil {
-			return externalGenerateManagedConfigV2{}, err
+		value := override.Value()
+		if customFileOption := override.CustomFileOption(); customFileOption != "" {
+			fileOptionName = customFileOption
+		} else {
+			var err error
+			value, err = getOverrideValue(fileOptionName, fieldOptionName, value)
+			if err != nil {
+				return externalGenerateManagedConfigV2{}, err
+			}
 		}
 		externalOverrides = append(
 			externalOverrides,
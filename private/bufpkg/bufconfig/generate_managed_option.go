@@ -65,6 +65,10 @@ const (
 	FileOptionRubyPackage
 	// FileOptionRubyPackageSuffix is the file option ruby_package_suffix.
 	FileOptionRubyPackageSuffix
+	// FileOptionSwiftPrefix is the file option swift_prefix.
+	FileOptionSwiftPrefix
+	// FileOptionPyGenericServices is the file option py_generic_services.
+	FileOptionPyGenericServices
 )
 
 // String implements fmt.Stringer.
@@ -117,6 +121,8 @@ var (
 		FileOptionPhpMetadataNamespaceSuffix: "php_metadata_namespace_suffix",
 		FileOptionRubyPackage:                "ruby_package",
 		FileOptionRubyPackageSuffix:          "ruby_package_suffix",
+		FileOptionSwiftPrefix:                "swift_prefix",
+		FileOptionPyGenericServices:          "py_generic_services",
 	}
 	stringToFileOption = map[string]FileOption{
 		"java_package":                  FileOptionJavaPackage,
@@ -137,6 +143,8 @@ var (
 		"php_metadata_namespace_suffix": FileOptionPhpMetadataNamespaceSuffix,
 		"ruby_package":                  FileOptionRubyPackage,
 		"ruby_package_suffix":           FileOptionRubyPackageSuffix,
+		"swift_prefix":                  FileOptionSwiftPrefix,
+		"py_generic_services":           FileOptionPyGenericServices,
 	}
 	fileOptionToParseOverrideValueFunc = map[FileOption]func(interface{}) (interface{}, error){
 		FileOptionJavaPackage:                parseOverrideValue[string],
@@ -157,6 +165,8 @@ var (
 		FileOptionPhpMetadataNamespaceSuffix: parseOverrideValue[string],
 		FileOptionRubyPackage:                parseOverrideValue[string],
 		FileOptionRubyPackageSuffix:          parseOverrideValue[string],
+		FileOptionSwiftPrefix:                parseOverrideValue[string],
+		FileOptionPyGenericServices:          parseOverrideValue[bool],
 	}
 	fieldOptionToString = map[FieldOption]string{
 		FieldOptionJSType: "jstype",
@@ -181,6 +191,66 @@ func parseFileOption(s string) (FileOption, error) {
 	return 0, fmt.Errorf("unknown file_option: %q", s)
 }
 
+// isCustomFileOptionName returns true if the file_option value names a custom
+// option or a feature, such as "(acme.v1.option).name" or "features.(pb.go).api_level",
+// instead of one of the file options managed mode knows about.
+func isCustomFileOptionName(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "(") || strings.HasPrefix(s, "features.")
+}
+
+// validateCustomFileOptionName validates the syntax of a custom file option name.
+//
+// A custom file option name is a sequence of components separated by ".", where
+// each component is either a field name or an extension name in parentheses.
+// The first component must be an extension or "features", as all other fields of
+// google.protobuf.FileOptions are either known to managed mode or not supported.
+func validateCustomFileOptionName(s string) error {
+	if s == "" {
+		return errors.New("empty file_option")
+	}
+	var components []string
+	for remaining := s; remaining != ""; {
+		if strings.HasPrefix(remaining, "(") {
+			end := strings.Index(remaining, ")")
+			if end < 0 {
+				return fmt.Errorf("invalid file_option %q: unterminated extension name", s)
+			}
+			if end == 1 {
+				return fmt.Errorf("invalid file_option %q: empty extension name", s)
+			}
+			components = append(components, remaining[:end+1])
+			remaining = remaining[end+1:]
+		} else {
+			end := strings.Index(remaining, ".")
+			if end < 0 {
+				end = len(remaining)
+			}
+			component := remaining[:end]
+			if component == "" || strings.ContainsAny(component, "()") {
+				return fmt.Errorf("invalid file_option %q", s)
+			}
+			components = append(components, component)
+			remaining = remaining[end:]
+		}
+		if remaining == "" {
+			break
+		}
+		if !strings.HasPrefix(remaining, ".") || remaining == "." {
+			return fmt.Errorf("invalid file_option %q", s)
+		}
+		remaining = remaining[1:]
+	}
+	if first := components[0]; first == "features" {
+		if len(components) < 2 {
+			return fmt.Errorf("invalid file_option %q: must name a feature", s)
+		}
+	} else if !strings.HasPrefix(first, "(") {
+		return fmt.Errorf("invalid file_option %q: must start with an extension name or features", s)
+	}
+	return nil
+}
+
 func parseFieldOption(s string) (FieldOption, error) {
 	s = strings.ToLower(strings.TrimSpace(s))
 	if s == "" {
@@ -201,6 +271,17 @@ func parseOverrideValue[T string | bool](overrideValue interface{}) (interface{}
 	return parsedValue, nil
 }
 
+// parseOverrideValueCustomFileOption accepts any scalar value. The value is
+// converted to the type of the option once the option is resolved against an image.
+func parseOverrideValueCustomFileOption(overrideValue interface{}) (interface{}, error) {
+	switch overrideValue.(type) {
+	case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return overrideValue, nil
+	default:
+		return nil, fmt.Errorf("expected a scalar value, got %T", overrideValue)
+	}
+}
+
 func parseOverrideValueOptimizeMode(overrideValue interface{}) (interface{}, error) {
 	optimizeModeName, ok := overrideValue.(string)
 	if !ok {
@@ -258,7 +339,9 @@ func getOverrideValue(fileOptionName string, fieldOptionName string, value inter
 			FileOptionPhpMetadataNamespace,
 			FileOptionPhpMetadataNamespaceSuffix,
 			FileOptionRubyPackage,
-			FileOptionRubyPackageSuffix:
+			FileOptionRubyPackageSuffix,
+			FileOptionSwiftPrefix,
+			FileOptionPyGenericServices:
 			return value, nil
 
 		case FileOptionOptimizeFor:
@@ -21,8 +21,10 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufremoteplugin/bufremotepluginref"
 	"github.com/bufbuild/buf/private/pkg/encoding"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/syserror"
 )
 
@@ -112,6 +114,25 @@ type GeneratePluginConfig interface {
 	//
 	// This is not empty only when the plugin is remote.
 	Revision() int
+	// Modules returns the full names of the modules whose files the plugin should
+	// generate for. An empty slice means to generate for all modules.
+	//
+	// This is always empty in v1.
+	Modules() []string
+	// IncludePaths returns the paths the plugin should generate for. An empty
+	// slice means to generate for all paths.
+	//
+	// This is always empty in v1.
+	IncludePaths() []string
+	// ExcludePaths returns the paths the plugin should not generate for.
+	//
+	// This is always empty in v1.
+	ExcludePaths() []string
+	// Types returns the types the plugin should generate for. An empty slice
+	// means to generate for all types.
+	//
+	// This is always empty in v1.
+	Types() []string
 
 	isGeneratePluginConfig()
 }
@@ -124,6 +145,10 @@ func NewRemoteGeneratePluginConfig(
 	includeImports bool,
 	includeWKT bool,
 	revision int,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (GeneratePluginConfig, error) {
 	return newRemoteGeneratePluginConfig(
 		name,
@@ -132,6 +157,10 @@ func NewRemoteGeneratePluginConfig(
 		includeImports,
 		includeWKT,
 		revision,
+		modules,
+		includePaths,
+		excludePaths,
+		types,
 	)
 }
 
@@ -143,6 +172,10 @@ func NewLocalOrProtocBuiltinGeneratePluginConfig(
 	includeImports bool,
 	includeWKT bool,
 	strategy *GenerateStrategy,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (GeneratePluginConfig, error) {
 	return newLocalOrProtocBuiltinGeneratePluginConfig(
 		name,
@@ -151,6 +184,10 @@ func NewLocalOrProtocBuiltinGeneratePluginConfig(
 		includeImports,
 		includeWKT,
 		strategy,
+		modules,
+		includePaths,
+		excludePaths,
+		types,
 	)
 }
 
@@ -163,6 +200,10 @@ func NewLocalGeneratePluginConfig(
 	includeWKT bool,
 	strategy *GenerateStrategy,
 	path []string,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (GeneratePluginConfig, error) {
 	return newLocalGeneratePluginConfig(
 		name,
@@ -172,6 +213,10 @@ func NewLocalGeneratePluginConfig(
 		includeWKT,
 		strategy,
 		path,
+		modules,
+		includePaths,
+		excludePaths,
+		types,
 	)
 }
 
@@ -185,6 +230,10 @@ func NewProtocBuiltinGeneratePluginConfig(
 	includeWKT bool,
 	strategy *GenerateStrategy,
 	protocPath []string,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (GeneratePluginConfig, error) {
 	return newProtocBuiltinGeneratePluginConfig(
 		name,
@@ -194,6 +243,10 @@ func NewProtocBuiltinGeneratePluginConfig(
 		includeWKT,
 		strategy,
 		protocPath,
+		modules,
+		includePaths,
+		excludePaths,
+		types,
 	)
 }
 
@@ -232,6 +285,10 @@ type generatePluginConfig struct {
 	protocPath               []string
 	remoteHost               string
 	revision                 int
+	modules                  []string
+	includePaths             []string
+	excludePaths             []string
+	types                    []string
 }
 
 func newGeneratePluginConfigFromExternalV1Beta1(
@@ -260,6 +317,10 @@ func newGeneratePluginConfigFromExternalV1Beta1(
 			false,
 			strategy,
 			[]string{externalConfig.Path},
+			nil,
+			nil,
+			nil,
+			nil,
 		)
 	}
 	return newLocalOrProtocBuiltinGeneratePluginConfig(
@@ -269,6 +330,10 @@ func newGeneratePluginConfigFromExternalV1Beta1(
 		false,
 		false,
 		strategy,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 }
 
@@ -328,6 +393,10 @@ func newGeneratePluginConfigFromExternalV1(
 			false,
 			false,
 			externalConfig.Revision,
+			nil,
+			nil,
+			nil,
+			nil,
 		)
 	}
 	// At this point the plugin must be local, regardless whehter it's specified
@@ -341,6 +410,10 @@ func newGeneratePluginConfigFromExternalV1(
 			false,
 			strategy,
 			path,
+			nil,
+			nil,
+			nil,
+			nil,
 		)
 	}
 	if externalConfig.ProtocPath != nil {
@@ -352,6 +425,10 @@ func newGeneratePluginConfigFromExternalV1(
 			false,
 			strategy,
 			protocPath,
+			nil,
+			nil,
+			nil,
+			nil,
 		)
 	}
 	// It could be either local or protoc built-in. We defer to the plugin executor
@@ -363,6 +440,10 @@ func newGeneratePluginConfigFromExternalV1(
 		false,
 		false,
 		strategy,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 }
 
@@ -400,6 +481,21 @@ func newGeneratePluginConfigFromExternalV2(
 	if err != nil {
 		return nil, err
 	}
+	for _, module := range externalConfig.Modules {
+		if _, err := bufmodule.ParseModuleFullName(module); err != nil {
+			return nil, fmt.Errorf("invalid module %q: %w", module, err)
+		}
+	}
+	modules := externalConfig.Modules
+	includePaths, err := normalizeAndValidatePluginPaths(externalConfig.IncludePaths)
+	if err != nil {
+		return nil, err
+	}
+	excludePaths, err := normalizeAndValidatePluginPaths(externalConfig.ExcludePaths)
+	if err != nil {
+		return nil, err
+	}
+	types := externalConfig.Types
 	switch {
 	case externalConfig.Remote != nil:
 		var revision int
@@ -419,6 +515,10 @@ func newGeneratePluginConfigFromExternalV2(
 			externalConfig.IncludeImports,
 			externalConfig.IncludeWKT,
 			revision,
+			modules,
+			includePaths,
+			excludePaths,
+			types,
 		)
 	case externalConfig.Local != nil:
 		path, err := encoding.InterfaceSliceOrStringToStringSlice(externalConfig.Local)
@@ -440,6 +540,10 @@ func newGeneratePluginConfigFromExternalV2(
 			externalConfig.IncludeWKT,
 			parsedStrategy,
 			path,
+			modules,
+			includePaths,
+			excludePaths,
+			types,
 		)
 	case externalConfig.ProtocBuiltin != nil:
 		protocPath, err := encoding.InterfaceSliceOrStringToStringSlice(externalConfig.ProtocPath)
@@ -457,6 +561,10 @@ func newGeneratePluginConfigFromExternalV2(
 			externalConfig.IncludeWKT,
 			parsedStrategy,
 			protocPath,
+			modules,
+			includePaths,
+			excludePaths,
+			types,
 		)
 	default:
 		return nil, syserror.Newf("must specify one of remote, binary and protoc_builtin")
@@ -470,6 +578,10 @@ func newRemoteGeneratePluginConfig(
 	includeImports bool,
 	includeWKT bool,
 	revision int,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (*generatePluginConfig, error) {
 	if includeWKT && !includeImports {
 		return nil, errors.New("cannot include well-known types without including imports")
@@ -490,6 +602,10 @@ func newRemoteGeneratePluginConfig(
 		opts:                     opt,
 		includeImports:           includeImports,
 		includeWKT:               includeWKT,
+		modules:                  modules,
+		includePaths:             includePaths,
+		excludePaths:             excludePaths,
+		types:                    types,
 	}, nil
 }
 
@@ -500,6 +616,10 @@ func newLocalOrProtocBuiltinGeneratePluginConfig(
 	includeImports bool,
 	includeWKT bool,
 	strategy *GenerateStrategy,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (*generatePluginConfig, error) {
 	if includeWKT && !includeImports {
 		return nil, errors.New("cannot include well-known types without including imports")
@@ -512,6 +632,10 @@ func newLocalOrProtocBuiltinGeneratePluginConfig(
 		opts:                     opt,
 		includeImports:           includeImports,
 		includeWKT:               includeWKT,
+		modules:                  modules,
+		includePaths:             includePaths,
+		excludePaths:             excludePaths,
+		types:                    types,
 	}, nil
 }
 
@@ -523,6 +647,10 @@ func newLocalGeneratePluginConfig(
 	includeWKT bool,
 	strategy *GenerateStrategy,
 	path []string,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (*generatePluginConfig, error) {
 	if len(path) == 0 {
 		return nil, errors.New("must specify a path to the plugin")
@@ -539,6 +667,10 @@ func newLocalGeneratePluginConfig(
 		opts:                     opt,
 		includeImports:           includeImports,
 		includeWKT:               includeWKT,
+		modules:                  modules,
+		includePaths:             includePaths,
+		excludePaths:             excludePaths,
+		types:                    types,
 	}, nil
 }
 
@@ -550,6 +682,10 @@ func newProtocBuiltinGeneratePluginConfig(
 	includeWKT bool,
 	strategy *GenerateStrategy,
 	protocPath []string,
+	modules []string,
+	includePaths []string,
+	excludePaths []string,
+	types []string,
 ) (*generatePluginConfig, error) {
 	if includeWKT && !includeImports {
 		return nil, errors.New("cannot include well-known types without including imports")
@@ -563,6 +699,10 @@ func newProtocBuiltinGeneratePluginConfig(
 		strategy:                 strategy,
 		includeImports:           includeImports,
 		includeWKT:               includeWKT,
+		modules:                  modules,
+		includePaths:             includePaths,
+		excludePaths:             excludePaths,
+		types:                    types,
 	}, nil
 }
 
@@ -613,6 +753,22 @@ func (p *generatePluginConfig) Revision() int {
 	return p.revision
 }
 
+func (p *generatePluginConfig) Modules() []string {
+	return p.modules
+}
+
+func (p *generatePluginConfig) IncludePaths() []string {
+	return p.includePaths
+}
+
+func (p *generatePluginConfig) ExcludePaths() []string {
+	return p.excludePaths
+}
+
+func (p *generatePluginConfig) Types() []string {
+	return p.types
+}
+
 func (p *generatePluginConfig) isGeneratePluginConfig() {}
 
 func newExternalGeneratePluginConfigV2FromPluginConfig(
@@ -641,6 +797,10 @@ func newExternalGeneratePluginConfigV2FromPluginConfig(
 	case strategy != nil && *strategy == GenerateStrategyAll:
 		externalPluginConfigV2.Strategy = toPointer("all")
 	}
+	externalPluginConfigV2.Modules = generatePluginConfig.Modules()
+	externalPluginConfigV2.IncludePaths = generatePluginConfig.IncludePaths()
+	externalPluginConfigV2.ExcludePaths = generatePluginConfig.ExcludePaths()
+	externalPluginConfigV2.Types = generatePluginConfig.Types()
 	switch generatePluginConfig.Type() {
 	case GeneratePluginConfigTypeRemote:
 		externalPluginConfigV2.Remote = toPointer(generatePluginConfig.Name())
@@ -698,6 +858,24 @@ func parseStrategy(s string) (*GenerateStrategy, error) {
 	return &strategy, nil
 }
 
+func normalizeAndValidatePluginPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	normalizedPaths := make([]string, len(paths))
+	for i, path := range paths {
+		normalizedPath, err := normalpath.NormalizeAndValidate(path)
+		if err != nil {
+			return nil, err
+		}
+		normalizedPaths[i] = normalizedPath
+	}
+	if err := normalpath.ValidatePathsNormalizedValidatedUnique(normalizedPaths); err != nil {
+		return nil, err
+	}
+	return normalizedPaths, nil
+}
+
 func parseRemoteHostName(fullName string) (string, error) {
 	if identity, err := bufremotepluginref.PluginIdentityForString(fullName); err == nil {
 		return identity.Remote(), nil
@@ -0,0 +1,290 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+)
+
+const (
+	gitDepRefOptionKey    = "ref"
+	gitDepSubDirOptionKey = "subdir"
+)
+
+// GitDepRef is a git repository configured as a dependency in a buf.yaml file.
+//
+// GitDepRefs are written as a URL, optionally followed by a fragment of comma-separated options,
+// for example https://github.com/acme/protos.git#ref=v1.2.0,subdir=proto.
+type GitDepRef interface {
+	// URL returns the URL of the git repository, including the scheme.
+	URL() string
+	// Ref returns the branch, tag, or commit to resolve.
+	//
+	// Empty if the default branch of the repository should be used.
+	Ref() string
+	// SubDirPath returns the normalized directory within the repository that is the root
+	// of the module.
+	//
+	// This is "." if the module is at the root of the repository.
+	SubDirPath() string
+	// Location returns the URL and SubDirPath of the GitDepRef, without the Ref.
+	//
+	// This identifies the dependency, and is what is written to buf.lock files.
+	Location() string
+	// String returns the GitDepRef as it would appear in a buf.yaml file.
+	String() string
+
+	isGitDepRef()
+}
+
+// NewGitDepRef returns a new GitDepRef.
+//
+// ref may be empty. subDirPath may be empty or ".".
+func NewGitDepRef(url string, ref string, subDirPath string) (GitDepRef, error) {
+	return newGitDepRef(url, ref, subDirPath)
+}
+
+// ParseGitDepRef parses a GitDepRef from its string form.
+func ParseGitDepRef(s string) (GitDepRef, error) {
+	url, options, _ := strings.Cut(s, "#")
+	var ref string
+	var subDirPath string
+	if options != "" {
+		for _, option := range strings.Split(options, ",") {
+			key, value, ok := strings.Cut(option, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("invalid git dep %q: option %q must be of the form key=value", s, option)
+			}
+			switch key {
+			case gitDepRefOptionKey:
+				ref = value
+			case gitDepSubDirOptionKey:
+				subDirPath = value
+			default:
+				return nil, fmt.Errorf("invalid git dep %q: unknown option %q, only %q and %q are allowed", s, key, gitDepRefOptionKey, gitDepSubDirOptionKey)
+			}
+		}
+	}
+	return newGitDepRef(url, ref, subDirPath)
+}
+
+// GitDepKey is a git dependency pinned to a commit, as specified in a buf.lock file.
+type GitDepKey interface {
+	// URL returns the URL of the git repository, including the scheme.
+	URL() string
+	// SubDirPath returns the normalized directory within the repository that is the root
+	// of the module.
+	//
+	// This is "." if the module is at the root of the repository.
+	SubDirPath() string
+	// Location returns the URL and SubDirPath of the GitDepKey.
+	//
+	// This matches GitDepRef.Location for the GitDepRef that the GitDepKey was resolved from.
+	Location() string
+	// Commit returns the full SHA of the git commit.
+	Commit() string
+	// Digest returns the digest of the .proto files within SubDirPath at Commit, and of the
+	// buf.yaml at the root of SubDirPath, if present.
+	Digest() bufcas.Digest
+
+	isGitDepKey()
+}
+
+// NewGitDepKey returns a new GitDepKey.
+func NewGitDepKey(
+	url string,
+	subDirPath string,
+	commit string,
+	digest bufcas.Digest,
+) (GitDepKey, error) {
+	return newGitDepKey(url, subDirPath, commit, digest)
+}
+
+// ParseGitDepKey parses a GitDepKey from the location, commit, and digest strings
+// written to buf.lock files.
+func ParseGitDepKey(location string, commit string, digest string) (GitDepKey, error) {
+	gitDepRef, err := ParseGitDepRef(location)
+	if err != nil {
+		return nil, err
+	}
+	if gitDepRef.Ref() != "" {
+		return nil, fmt.Errorf("invalid git dep %q: %q cannot be set in a lock file", location, gitDepRefOptionKey)
+	}
+	parsedDigest, err := bufcas.ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return newGitDepKey(gitDepRef.URL(), gitDepRef.SubDirPath(), commit, parsedDigest)
+}
+
+// *** PRIVATE ***
+
+type gitDepRef struct {
+	url        string
+	ref        string
+	subDirPath string
+}
+
+func newGitDepRef(url string, ref string, subDirPath string) (*gitDepRef, error) {
+	if err := validateGitDepURL(url); err != nil {
+		return nil, err
+	}
+	normalSubDirPath, err := normalizeGitDepSubDirPath(url, subDirPath)
+	if err != nil {
+		return nil, err
+	}
+	return &gitDepRef{
+		url:        url,
+		ref:        ref,
+		subDirPath: normalSubDirPath,
+	}, nil
+}
+
+func (g *gitDepRef) URL() string {
+	return g.url
+}
+
+func (g *gitDepRef) Ref() string {
+	return g.ref
+}
+
+func (g *gitDepRef) SubDirPath() string {
+	return g.subDirPath
+}
+
+func (g *gitDepRef) Location() string {
+	return getGitDepString(g.url, "", g.subDirPath)
+}
+
+func (g *gitDepRef) String() string {
+	return getGitDepString(g.url, g.ref, g.subDirPath)
+}
+
+func (*gitDepRef) isGitDepRef() {}
+
+type gitDepKey struct {
+	url        string
+	subDirPath string
+	commit     string
+	digest     bufcas.Digest
+}
+
+func newGitDepKey(
+	url string,
+	subDirPath string,
+	commit string,
+	digest bufcas.Digest,
+) (*gitDepKey, error) {
+	if err := validateGitDepURL(url); err != nil {
+		return nil, err
+	}
+	normalSubDirPath, err := normalizeGitDepSubDirPath(url, subDirPath)
+	if err != nil {
+		return nil, err
+	}
+	if !isFullGitCommit(commit) {
+		return nil, fmt.Errorf("invalid commit %q for git dep %s: must be a full git commit SHA", commit, url)
+	}
+	if digest == nil {
+		return nil, fmt.Errorf("no digest specified for git dep %s", url)
+	}
+	return &gitDepKey{
+		url:        url,
+		subDirPath: normalSubDirPath,
+		commit:     commit,
+		digest:     digest,
+	}, nil
+}
+
+func (g *gitDepKey) URL() string {
+	return g.url
+}
+
+func (g *gitDepKey) SubDirPath() string {
+	return g.subDirPath
+}
+
+func (g *gitDepKey) Location() string {
+	return getGitDepString(g.url, "", g.subDirPath)
+}
+
+func (g *gitDepKey) Commit() string {
+	return g.commit
+}
+
+func (g *gitDepKey) Digest() bufcas.Digest {
+	return g.digest
+}
+
+func (*gitDepKey) isGitDepKey() {}
+
+func validateGitDepURL(url string) error {
+	if url == "" {
+		return errors.New("git dep URL is empty")
+	}
+	switch {
+	case strings.HasPrefix(url, "https://"),
+		strings.HasPrefix(url, "http://"),
+		strings.HasPrefix(url, "ssh://"),
+		strings.HasPrefix(url, "git://"),
+		strings.HasPrefix(url, "file://"):
+		return nil
+	default:
+		return fmt.Errorf("invalid git dep URL %q: must start with https://, http://, ssh://, git://, or file://", url)
+	}
+}
+
+func normalizeGitDepSubDirPath(url string, subDirPath string) (string, error) {
+	if subDirPath == "" {
+		return ".", nil
+	}
+	normalSubDirPath, err := normalpath.NormalizeAndValidate(subDirPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid subdir for git dep %s: %w", url, err)
+	}
+	return normalSubDirPath, nil
+}
+
+func getGitDepString(url string, ref string, subDirPath string) string {
+	var options []string
+	if ref != "" {
+		options = append(options, gitDepRefOptionKey+"="+ref)
+	}
+	if subDirPath != "." {
+		options = append(options, gitDepSubDirOptionKey+"="+subDirPath)
+	}
+	if len(options) == 0 {
+		return url
+	}
+	return url + "#" + strings.Join(options, ",")
+}
+
+func isFullGitCommit(commit string) bool {
+	// SHA-1 commits are 40 hex characters, SHA-256 commits are 64.
+	if len(commit) != 40 && len(commit) != 64 {
+		return false
+	}
+	for _, c := range commit {
+		if !(('0' <= c && c <= '9') || ('a' <= c && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufgitdep resolves and fetches dependencies on git repositories.
+//
+// Git dependencies are configured in buf.yaml files as a URL with an optional ref and subdir,
+// and are pinned in buf.lock files to a full commit SHA and the digest of their .proto files
+// and buf.yaml.
+package bufgitdep
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/git"
+	"github.com/bufbuild/buf/private/pkg/storage"
+)
+
+var (
+	// NopProvider is a no-op Provider.
+	//
+	// It returns an error if any git dependencies are requested.
+	NopProvider Provider = nopProvider{}
+)
+
+// Provider resolves and provides git dependencies.
+type Provider interface {
+	// GetGitDepKeysForGitDepRefs resolves the GitDepRefs to the commits that their refs
+	// currently point to.
+	//
+	// The files of the resolved commits are stored in the cache as a side effect.
+	// The returned GitDepKeys are in the same order as the input GitDepRefs.
+	GetGitDepKeysForGitDepRefs(ctx context.Context, gitDepRefs []bufconfig.GitDepRef) ([]bufconfig.GitDepKey, error)
+	// GetReadBucketsForGitDepKeys returns the .proto files of the GitDepKeys, with the SubDirPath
+	// of each GitDepKey as the root of its bucket.
+	//
+	// The buckets also contain the buf.yaml at the root of each SubDirPath, if present.
+	//
+	// Files are read from the cache, and fetched if not present. The digests of the files are
+	// always verified against the GitDepKeys.
+	// The returned buckets are in the same order as the input GitDepKeys.
+	GetReadBucketsForGitDepKeys(ctx context.Context, gitDepKeys []bufconfig.GitDepKey) ([]storage.ReadBucket, error)
+}
+
+// NewProvider returns a new Provider that clones with the given git.Cloner, and
+// stores fetched files in the cache bucket.
+//
+// The locker guards the files of each commit in the cache bucket.
+func NewProvider(
+	logger *slog.Logger,
+	envContainer app.EnvContainer,
+	cloner git.Cloner,
+	cacheBucket storage.ReadWriteBucket,
+	locker filelock.Locker,
+) Provider {
+	return newProvider(logger, envContainer, cloner, cacheBucket, locker)
+}
+
+// ModuleFullNameForGitDepKey returns the ModuleFullName that identifies the module of a git dep
+// that does not specify a module name in a buf.yaml.
+//
+// The registry is the host of the URL, or "localhost" if the URL has no host. The owner is the
+// first component of the path of the URL, and the name is the remaining components of the path
+// and the components of the SubDirPath, joined with "-". For example, the ModuleFullName for
+// https://github.com/acme/protos.git#subdir=proto/public is github.com/acme/protos-proto-public.
+func ModuleFullNameForGitDepKey(gitDepKey bufconfig.GitDepKey) (bufmodule.ModuleFullName, error) {
+	return getModuleFullNameForGitDepKey(gitDepKey)
+}
+
+// *** PRIVATE ***
+
+type nopProvider struct{}
+
+func (nopProvider) GetGitDepKeysForGitDepRefs(
+	_ context.Context,
+	gitDepRefs []bufconfig.GitDepRef,
+) ([]bufconfig.GitDepKey, error) {
+	if len(gitDepRefs) == 0 {
+		return nil, nil
+	}
+	return nil, errors.New("git dependencies are not supported")
+}
+
+func (nopProvider) GetReadBucketsForGitDepKeys(
+	_ context.Context,
+	gitDepKeys []bufconfig.GitDepKey,
+) ([]storage.ReadBucket, error) {
+	if len(gitDepKeys) == 0 {
+		return nil, nil
+	}
+	return nil, errors.New("git dependencies are not supported")
+}
@@ -0,0 +1,221 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufgitdep
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/git"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repoDirPath := testCreateGitRepository(t)
+
+	gitDepRef, err := bufconfig.NewGitDepRef("file://"+filepath.Join(repoDirPath, ".git"), "v1", "proto")
+	require.NoError(t, err)
+	gitDepKeys, err := testNewProvider(t, storagemem.NewReadWriteBucket()).GetGitDepKeysForGitDepRefs(
+		ctx,
+		[]bufconfig.GitDepRef{gitDepRef},
+	)
+	require.NoError(t, err)
+	require.Len(t, gitDepKeys, 1)
+	gitDepKey := gitDepKeys[0]
+	require.Len(t, gitDepKey.Commit(), 40)
+	require.Equal(t, "proto", gitDepKey.SubDirPath())
+
+	// A provider with an empty cache fetches the pinned commit, not the latest commit.
+	cacheBucket := storagemem.NewReadWriteBucket()
+	provider := testNewProvider(t, cacheBucket)
+	readBuckets, err := provider.GetReadBucketsForGitDepKeys(ctx, []bufconfig.GitDepKey{gitDepKey})
+	require.NoError(t, err)
+	require.Len(t, readBuckets, 1)
+	data, err := storage.ReadPath(ctx, readBuckets[0], "a.proto")
+	require.NoError(t, err)
+	require.Equal(t, "// v1", string(data))
+	_, err = storage.ReadPath(ctx, readBuckets[0], "b.proto")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	data, err = storage.ReadPath(ctx, readBuckets[0], "buf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "version: v2\nname: buf.build/acme/a\n", string(data))
+
+	// A corrupted cache is fetched again.
+	require.NoError(t, storage.PutPath(ctx, cacheBucket, gitDepKey.Commit()+"/proto/a.proto", []byte("// bad")))
+	readBuckets, err = provider.GetReadBucketsForGitDepKeys(ctx, []bufconfig.GitDepKey{gitDepKey})
+	require.NoError(t, err)
+	data, err = storage.ReadPath(ctx, readBuckets[0], "a.proto")
+	require.NoError(t, err)
+	require.Equal(t, "// v1", string(data))
+
+	// The buf.yaml is part of the digest, so a corrupted buf.yaml is fetched again.
+	require.NoError(t, storage.PutPath(ctx, cacheBucket, gitDepKey.Commit()+"/proto/buf.yaml", []byte("version: v2\nname: buf.build/acme/b\n")))
+	readBuckets, err = provider.GetReadBucketsForGitDepKeys(ctx, []bufconfig.GitDepKey{gitDepKey})
+	require.NoError(t, err)
+	data, err = storage.ReadPath(ctx, readBuckets[0], "buf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "version: v2\nname: buf.build/acme/a\n", string(data))
+
+	// The buf.yaml of a subdirectory is not part of the digest of the root of the same commit.
+	rootGitDepRef, err := bufconfig.NewGitDepRef(gitDepKey.URL(), "v1", "")
+	require.NoError(t, err)
+	rootGitDepKeys, err := testNewProvider(t, storagemem.NewReadWriteBucket()).GetGitDepKeysForGitDepRefs(
+		ctx,
+		[]bufconfig.GitDepRef{rootGitDepRef},
+	)
+	require.NoError(t, err)
+	require.Len(t, rootGitDepKeys, 1)
+	require.Equal(t, gitDepKey.Commit(), rootGitDepKeys[0].Commit())
+	readBuckets, err = provider.GetReadBucketsForGitDepKeys(ctx, rootGitDepKeys)
+	require.NoError(t, err)
+	data, err = storage.ReadPath(ctx, readBuckets[0], "root.proto")
+	require.NoError(t, err)
+	require.Equal(t, "// root", string(data))
+
+	// A digest that does not match the repository is an error.
+	badDigest, err := bufcas.NewDigestForContent(strings.NewReader(""))
+	require.NoError(t, err)
+	badGitDepKey, err := bufconfig.NewGitDepKey(gitDepKey.URL(), gitDepKey.SubDirPath(), gitDepKey.Commit(), badDigest)
+	require.NoError(t, err)
+	_, err = provider.GetReadBucketsForGitDepKeys(ctx, []bufconfig.GitDepKey{badGitDepKey})
+	require.ErrorContains(t, err, "tampered")
+}
+
+func TestCacheManager(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repoDirPath := testCreateGitRepository(t)
+
+	gitDepRef, err := bufconfig.NewGitDepRef("file://"+filepath.Join(repoDirPath, ".git"), "v1", "proto")
+	require.NoError(t, err)
+	cacheBucket, err := storageos.NewProvider().NewReadWriteBucket(t.TempDir())
+	require.NoError(t, err)
+	gitDepKeys, err := testNewProvider(t, cacheBucket).GetGitDepKeysForGitDepRefs(
+		ctx,
+		[]bufconfig.GitDepRef{gitDepRef},
+	)
+	require.NoError(t, err)
+	require.Len(t, gitDepKeys, 1)
+
+	cacheManager := NewCacheManager(slogtestext.NewLogger(t), cacheBucket, filelock.NewNopLocker())
+	cacheEntries, err := cacheManager.ListCacheEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, cacheEntries, 1)
+	require.Equal(t, gitDepKeys[0].Commit(), cacheEntries[0].Commit())
+	require.Greater(t, cacheEntries[0].Size(), int64(0))
+	require.False(t, cacheEntries[0].ModTime().IsZero())
+
+	require.NoError(t, cacheManager.DeleteCacheEntry(ctx, cacheEntries[0]))
+	cacheEntries, err = cacheManager.ListCacheEntries(ctx)
+	require.NoError(t, err)
+	require.Empty(t, cacheEntries)
+}
+
+func TestModuleFullNameForGitDepKey(t *testing.T) {
+	t.Parallel()
+	digest, err := bufcas.NewDigestForContent(strings.NewReader(""))
+	require.NoError(t, err)
+	for _, testCase := range []struct {
+		url                    string
+		subDirPath             string
+		expectedModuleFullName string
+	}{
+		{
+			url:                    "https://github.com/acme/protos.git",
+			expectedModuleFullName: "github.com/acme/protos",
+		},
+		{
+			url:                    "https://github.com/acme/protos.git",
+			subDirPath:             "proto/public",
+			expectedModuleFullName: "github.com/acme/protos-proto-public",
+		},
+		{
+			url:                    "ssh://git@gitlab.example.com:2222/acme/team/protos",
+			expectedModuleFullName: "gitlab.example.com/acme/team-protos",
+		},
+		{
+			url:                    "file:///src/protos/.git",
+			subDirPath:             "proto",
+			expectedModuleFullName: "localhost/src/protos-proto",
+		},
+		{
+			url:                    "https://example.com/protos.git",
+			expectedModuleFullName: "example.com/protos/protos",
+		},
+	} {
+		gitDepKey, err := bufconfig.NewGitDepKey(testCase.url, testCase.subDirPath, strings.Repeat("a", 40), digest)
+		require.NoError(t, err)
+		moduleFullName, err := ModuleFullNameForGitDepKey(gitDepKey)
+		require.NoError(t, err)
+		require.Equal(t, testCase.expectedModuleFullName, moduleFullName.String())
+	}
+	gitDepKey, err := bufconfig.NewGitDepKey("https://example.com", "", strings.Repeat("a", 40), digest)
+	require.NoError(t, err)
+	_, err = ModuleFullNameForGitDepKey(gitDepKey)
+	require.ErrorContains(t, err, "the URL has no path")
+}
+
+func testNewProvider(t *testing.T, cacheBucket storage.ReadWriteBucket) Provider {
+	envContainer, err := app.NewEnvContainerForOS()
+	require.NoError(t, err)
+	return NewProvider(
+		slogtestext.NewLogger(t),
+		envContainer,
+		git.NewCloner(slogtestext.NewLogger(t), storageos.NewProvider(), git.ClonerOptions{}),
+		cacheBucket,
+		filelock.NewNopLocker(),
+	)
+}
+
+// testCreateGitRepository creates a repository with a v1 tag that has proto/a.proto and
+// proto/buf.yaml, and a later commit on the default branch that changes it and adds proto/b.proto.
+func testCreateGitRepository(t *testing.T) string {
+	repoDirPath := t.TempDir()
+	protoDirPath := filepath.Join(repoDirPath, "proto")
+	require.NoError(t, os.MkdirAll(protoDirPath, 0755))
+	testRunGit(t, repoDirPath, "init")
+	testRunGit(t, repoDirPath, "config", "user.email", "tests@buf.build")
+	testRunGit(t, repoDirPath, "config", "user.name", "Buf go tests")
+	require.NoError(t, os.WriteFile(filepath.Join(protoDirPath, "a.proto"), []byte("// v1"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(protoDirPath, "buf.yaml"), []byte("version: v2\nname: buf.build/acme/a\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDirPath, "root.proto"), []byte("// root"), 0600))
+	testRunGit(t, repoDirPath, "add", ".")
+	testRunGit(t, repoDirPath, "commit", "-m", "v1")
+	testRunGit(t, repoDirPath, "tag", "v1")
+	require.NoError(t, os.WriteFile(filepath.Join(protoDirPath, "a.proto"), []byte("// v2"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(protoDirPath, "b.proto"), []byte("// v2"), 0600))
+	testRunGit(t, repoDirPath, "add", ".")
+	testRunGit(t, repoDirPath, "commit", "-m", "v2")
+	return repoDirPath
+}
+
+func testRunGit(t *testing.T, dirPath string, args ...string) {
+	output, err := exec.Command("git", append([]string{"-C", dirPath}, args...)...).CombinedOutput()
+	require.NoError(t, err, string(output))
+}
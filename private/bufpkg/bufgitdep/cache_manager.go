@@ -0,0 +1,199 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufgitdep
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/storage"
+)
+
+// CacheEntry is the cached files of a single commit of a git dependency.
+type CacheEntry interface {
+	// Commit is the full commit SHA the files were fetched at.
+	Commit() string
+	// Size is the total size of all files of the entry in bytes.
+	Size() int64
+	// ModTime is the latest time a file of the entry was written if the cache is on local
+	// disk, and the zero time otherwise.
+	//
+	// Reads are not recorded, as the files of a commit never change.
+	ModTime() time.Time
+
+	isCacheEntry()
+}
+
+// CacheManager lists and deletes the entries of the cache of a Provider.
+type CacheManager interface {
+	// ListCacheEntries lists all entries in the cache.
+	//
+	// Entries are sorted by commit.
+	ListCacheEntries(ctx context.Context) ([]CacheEntry, error)
+	// DeleteCacheEntry deletes the entry from the cache.
+	//
+	// This holds an exclusive lock on the commit of the entry while deleting.
+	DeleteCacheEntry(ctx context.Context, cacheEntry CacheEntry) error
+}
+
+// NewCacheManager returns a new CacheManager for the cache bucket and locker given to NewProvider.
+func NewCacheManager(
+	logger *slog.Logger,
+	cacheBucket storage.ReadWriteBucket,
+	locker filelock.Locker,
+) CacheManager {
+	return newCacheManager(logger, cacheBucket, locker)
+}
+
+// *** PRIVATE ***
+
+type cacheManager struct {
+	logger      *slog.Logger
+	cacheBucket storage.ReadWriteBucket
+	locker      filelock.Locker
+}
+
+func newCacheManager(
+	logger *slog.Logger,
+	cacheBucket storage.ReadWriteBucket,
+	locker filelock.Locker,
+) *cacheManager {
+	return &cacheManager{
+		logger:      logger,
+		cacheBucket: cacheBucket,
+		locker:      locker,
+	}
+}
+
+func (c *cacheManager) ListCacheEntries(ctx context.Context) ([]CacheEntry, error) {
+	commitToCacheEntry := make(map[string]*cacheEntry)
+	if err := c.cacheBucket.Walk(
+		ctx,
+		"",
+		func(objectInfo storage.ObjectInfo) error {
+			// "commit/path"
+			commit, _, ok := strings.Cut(objectInfo.Path(), "/")
+			if !ok {
+				return nil
+			}
+			size, err := getObjectSize(ctx, c.cacheBucket, objectInfo)
+			if err != nil {
+				return err
+			}
+			entry, ok := commitToCacheEntry[commit]
+			if !ok {
+				entry = &cacheEntry{
+					commit: commit,
+				}
+				commitToCacheEntry[commit] = entry
+			}
+			entry.size += size
+			if modTime := getObjectModTime(objectInfo); modTime.After(entry.modTime) {
+				entry.modTime = modTime
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	cacheEntries := make([]CacheEntry, 0, len(commitToCacheEntry))
+	for _, entry := range commitToCacheEntry {
+		cacheEntries = append(cacheEntries, entry)
+	}
+	sort.Slice(
+		cacheEntries,
+		func(i int, j int) bool {
+			return cacheEntries[i].Commit() < cacheEntries[j].Commit()
+		},
+	)
+	return cacheEntries, nil
+}
+
+func (c *cacheManager) DeleteCacheEntry(ctx context.Context, cacheEntry CacheEntry) (retErr error) {
+	commit := cacheEntry.Commit()
+	unlocker, err := c.locker.Lock(ctx, getCommitLockPath(commit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	c.logger.DebugContext(ctx, "git dep cache delete", slog.String("commit", commit))
+	return c.cacheBucket.DeleteAll(ctx, commit)
+}
+
+type cacheEntry struct {
+	commit  string
+	size    int64
+	modTime time.Time
+}
+
+func (e *cacheEntry) Commit() string {
+	return e.commit
+}
+
+func (e *cacheEntry) Size() int64 {
+	return e.size
+}
+
+func (e *cacheEntry) ModTime() time.Time {
+	return e.modTime
+}
+
+func (*cacheEntry) isCacheEntry() {}
+
+// getObjectSize returns the size of the object.
+//
+// If the object is on local disk, this stats the file, otherwise the object is read.
+func getObjectSize(ctx context.Context, readBucket storage.ReadBucket, objectInfo storage.ObjectInfo) (_ int64, retErr error) {
+	if localPath := objectInfo.LocalPath(); localPath != "" {
+		fileInfo, err := os.Stat(localPath)
+		if err != nil {
+			return 0, err
+		}
+		return fileInfo.Size(), nil
+	}
+	readObjectCloser, err := readBucket.Get(ctx, objectInfo.Path())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, readObjectCloser.Close())
+	}()
+	return io.Copy(io.Discard, readObjectCloser)
+}
+
+// getObjectModTime returns the modification time of the object if the object is on
+// local disk, and the zero time otherwise.
+func getObjectModTime(objectInfo storage.ObjectInfo) time.Time {
+	localPath := objectInfo.LocalPath()
+	if localPath == "" {
+		return time.Time{}
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return fileInfo.ModTime().UTC()
+}
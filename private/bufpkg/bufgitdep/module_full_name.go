@@ -0,0 +1,56 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufgitdep
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+)
+
+// localRegistry is the registry of the ModuleFullNames of git deps with URLs that have no host,
+// such as file:// URLs.
+const localRegistry = "localhost"
+
+func getModuleFullNameForGitDepKey(gitDepKey bufconfig.GitDepKey) (bufmodule.ModuleFullName, error) {
+	parsedURL, err := url.Parse(gitDepKey.URL())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse URL of git dep %s: %w", gitDepKey.Location(), err)
+	}
+	registry := strings.ToLower(parsedURL.Hostname())
+	if registry == "" {
+		registry = localRegistry
+	}
+	repositoryPath := strings.Trim(strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git"), "/")
+	var components []string
+	if repositoryPath != "" {
+		components = strings.Split(repositoryPath, "/")
+	}
+	if subDirPath := gitDepKey.SubDirPath(); subDirPath != "." {
+		components = append(components, normalpath.Components(subDirPath)...)
+	}
+	switch len(components) {
+	case 0:
+		return nil, fmt.Errorf("could not derive a module name for git dep %s, the URL has no path", gitDepKey.Location())
+	case 1:
+		return bufmodule.NewModuleFullName(registry, components[0], components[0])
+	default:
+		return bufmodule.NewModuleFullName(registry, components[0], strings.Join(components[1:], "-"))
+	}
+}
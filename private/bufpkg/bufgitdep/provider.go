@@ -0,0 +1,326 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufgitdep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/git"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+)
+
+const (
+	// resolveCloneDepth is the depth used when resolving refs. This matches the default
+	// depth of git inputs, and allows partial commits to be resolved.
+	resolveCloneDepth = 50
+	// fetchCloneDepth is the depth used when fetching a known commit.
+	fetchCloneDepth = 1
+	// commitLockFileExt is the file extension of the lock file for each commit in the cache.
+	commitLockFileExt = ".lock"
+)
+
+type provider struct {
+	logger       *slog.Logger
+	envContainer app.EnvContainer
+	cloner       git.Cloner
+	// cacheBucket stores the .proto files and buf.yaml files of each commit at <commit>/<path>.
+	//
+	// Only the files within the SubDirPaths that were fetched for a commit are stored.
+	cacheBucket storage.ReadWriteBucket
+	// locker guards the files of each commit in cacheBucket, with one lock file per commit.
+	locker filelock.Locker
+}
+
+func newProvider(
+	logger *slog.Logger,
+	envContainer app.EnvContainer,
+	cloner git.Cloner,
+	cacheBucket storage.ReadWriteBucket,
+	locker filelock.Locker,
+) *provider {
+	return &provider{
+		logger:       logger,
+		envContainer: envContainer,
+		cloner:       cloner,
+		cacheBucket:  cacheBucket,
+		locker:       locker,
+	}
+}
+
+func (p *provider) GetGitDepKeysForGitDepRefs(
+	ctx context.Context,
+	gitDepRefs []bufconfig.GitDepRef,
+) ([]bufconfig.GitDepKey, error) {
+	return slicesext.MapError(
+		gitDepRefs,
+		func(gitDepRef bufconfig.GitDepRef) (bufconfig.GitDepKey, error) {
+			return p.getGitDepKeyForGitDepRef(ctx, gitDepRef)
+		},
+	)
+}
+
+func (p *provider) GetReadBucketsForGitDepKeys(
+	ctx context.Context,
+	gitDepKeys []bufconfig.GitDepKey,
+) ([]storage.ReadBucket, error) {
+	return slicesext.MapError(
+		gitDepKeys,
+		func(gitDepKey bufconfig.GitDepKey) (storage.ReadBucket, error) {
+			return p.getReadBucketForGitDepKey(ctx, gitDepKey)
+		},
+	)
+}
+
+func (p *provider) getGitDepKeyForGitDepRef(
+	ctx context.Context,
+	gitDepRef bufconfig.GitDepRef,
+) (_ bufconfig.GitDepKey, retErr error) {
+	var gitName git.Name
+	if ref := gitDepRef.Ref(); ref != "" {
+		gitName = git.NewRefName(ref)
+	}
+	readWriteBucket := storagemem.NewReadWriteBucket()
+	commit, err := p.cloner.CloneToBucketAndGetCommit(
+		ctx,
+		p.envContainer,
+		gitDepRef.URL(),
+		resolveCloneDepth,
+		readWriteBucket,
+		git.CloneToBucketOptions{
+			Matcher: getMatcherForSubDirPath(gitDepRef.SubDirPath()),
+			Name:    gitName,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve git dep %s: %w", gitDepRef.String(), err)
+	}
+	subDirReadBucket := storage.MapReadBucket(readWriteBucket, storage.MapOnPrefix(gitDepRef.SubDirPath()))
+	digest, err := getDigestForReadBucket(ctx, subDirReadBucket)
+	if err != nil {
+		return nil, err
+	}
+	unlocker, err := p.locker.Lock(ctx, getCommitLockPath(commit))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	if _, err := storage.Copy(ctx, readWriteBucket, p.getCommitCacheBucket(commit)); err != nil {
+		return nil, err
+	}
+	p.logger.DebugContext(
+		ctx,
+		"resolved git dep",
+		slog.String("dep", gitDepRef.String()),
+		slog.String("commit", commit),
+	)
+	return bufconfig.NewGitDepKey(gitDepRef.URL(), gitDepRef.SubDirPath(), commit, digest)
+}
+
+func (p *provider) getReadBucketForGitDepKey(
+	ctx context.Context,
+	gitDepKey bufconfig.GitDepKey,
+) (storage.ReadBucket, error) {
+	readBucket, err := p.getCachedReadBucketForGitDepKey(ctx, gitDepKey)
+	if err != nil {
+		return nil, err
+	}
+	if readBucket != nil {
+		return readBucket, nil
+	}
+	return p.fetchReadBucketForGitDepKey(ctx, gitDepKey)
+}
+
+// getCachedReadBucketForGitDepKey returns the files of the GitDepKey from the cache, or nil
+// if the cache does not contain files matching the digest of the GitDepKey.
+//
+// The files are copied out of the cache while holding a shared lock on the commit, so that
+// they cannot be replaced from underneath us.
+func (p *provider) getCachedReadBucketForGitDepKey(
+	ctx context.Context,
+	gitDepKey bufconfig.GitDepKey,
+) (_ storage.ReadBucket, retErr error) {
+	unlocker, err := p.locker.RLock(ctx, getCommitLockPath(gitDepKey.Commit()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	return p.copyCachedReadBucketForGitDepKey(ctx, gitDepKey)
+}
+
+// fetchReadBucketForGitDepKey fetches the files of the GitDepKey into the cache, and returns them.
+//
+// This holds an exclusive lock on the commit while writing to the cache.
+func (p *provider) fetchReadBucketForGitDepKey(
+	ctx context.Context,
+	gitDepKey bufconfig.GitDepKey,
+) (_ storage.ReadBucket, retErr error) {
+	unlocker, err := p.locker.Lock(ctx, getCommitLockPath(gitDepKey.Commit()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	// The shared lock was released before acquiring the exclusive lock, so another process
+	// may have fetched the files in the interim.
+	readBucket, err := p.copyCachedReadBucketForGitDepKey(ctx, gitDepKey)
+	if err != nil {
+		return nil, err
+	}
+	if readBucket != nil {
+		return readBucket, nil
+	}
+	// Either the files were never fetched, or the cache is corrupted. In both cases we
+	// replace what is in the cache for this subdirectory with a fresh fetch.
+	p.logger.DebugContext(
+		ctx,
+		"git dep cache miss",
+		slog.String("dep", gitDepKey.Location()),
+		slog.String("commit", gitDepKey.Commit()),
+	)
+	commitCacheBucket := p.getCommitCacheBucket(gitDepKey.Commit())
+	if err := commitCacheBucket.DeleteAll(ctx, gitDepKey.SubDirPath()); err != nil {
+		return nil, err
+	}
+	if _, err := p.cloner.CloneToBucketAndGetCommit(
+		ctx,
+		p.envContainer,
+		gitDepKey.URL(),
+		fetchCloneDepth,
+		commitCacheBucket,
+		git.CloneToBucketOptions{
+			Matcher: getMatcherForSubDirPath(gitDepKey.SubDirPath()),
+			Name:    git.NewRefName(gitDepKey.Commit()),
+		},
+	); err != nil {
+		return nil, fmt.Errorf("could not fetch git dep %s at commit %s: %w", gitDepKey.Location(), gitDepKey.Commit(), err)
+	}
+	subDirReadBucket := storage.MapReadBucket(commitCacheBucket, storage.MapOnPrefix(gitDepKey.SubDirPath()))
+	digest, err := getDigestForReadBucket(ctx, subDirReadBucket)
+	if err != nil {
+		return nil, err
+	}
+	if !bufcas.DigestEqual(digest, gitDepKey.Digest()) {
+		return nil, fmt.Errorf(
+			"git dep %s at commit %s had digest %s but buf.lock specifies digest %s, the repository may have been tampered with",
+			gitDepKey.Location(),
+			gitDepKey.Commit(),
+			digest.String(),
+			gitDepKey.Digest().String(),
+		)
+	}
+	return copyReadBucket(ctx, subDirReadBucket)
+}
+
+// copyCachedReadBucketForGitDepKey copies the files of the GitDepKey out of the cache, or
+// returns nil if the cache does not contain files matching the digest of the GitDepKey.
+//
+// Any required locks must be held by the caller.
+func (p *provider) copyCachedReadBucketForGitDepKey(
+	ctx context.Context,
+	gitDepKey bufconfig.GitDepKey,
+) (storage.ReadBucket, error) {
+	subDirReadBucket := storage.MapReadBucket(
+		p.getCommitCacheBucket(gitDepKey.Commit()),
+		storage.MapOnPrefix(gitDepKey.SubDirPath()),
+	)
+	digest, err := getDigestForReadBucket(ctx, subDirReadBucket)
+	if err != nil {
+		return nil, err
+	}
+	if !bufcas.DigestEqual(digest, gitDepKey.Digest()) {
+		return nil, nil
+	}
+	return copyReadBucket(ctx, subDirReadBucket)
+}
+
+func (p *provider) getCommitCacheBucket(commit string) storage.ReadWriteBucket {
+	return storage.MapReadWriteBucket(p.cacheBucket, storage.MapOnPrefix(commit))
+}
+
+func getCommitLockPath(commit string) string {
+	return commit + commitLockFileExt
+}
+
+// getMatcherForSubDirPath matches the .proto files within the SubDirPath, and the buf.yaml
+// at the root of the SubDirPath, which configures the module of the git dep.
+func getMatcherForSubDirPath(subDirPath string) storage.Matcher {
+	bufYAMLMatcher := storage.MatchPathEqual(normalpath.Join(subDirPath, bufconfig.DefaultBufYAMLFileName))
+	if subDirPath == "." {
+		return storage.MatchOr(
+			storage.MatchPathExt(".proto"),
+			bufYAMLMatcher,
+		)
+	}
+	return storage.MatchOr(
+		storage.MatchAnd(
+			storage.MatchPathExt(".proto"),
+			storage.MatchPathContained(subDirPath),
+		),
+		bufYAMLMatcher,
+	)
+}
+
+// getDigestForReadBucket returns the digest of the .proto files and the buf.yaml at the root
+// of the bucket.
+//
+// The buf.yaml is part of the digest, as it determines the name, roots, and dependencies of
+// the module of the git dep.
+func getDigestForReadBucket(ctx context.Context, readBucket storage.ReadBucket) (bufcas.Digest, error) {
+	fileSet, err := bufcas.NewFileSetForBucket(
+		ctx,
+		storage.FilterReadBucket(
+			readBucket,
+			storage.MatchOr(
+				storage.MatchPathExt(".proto"),
+				// Only the buf.yaml at the root, the cache for a commit may also contain the
+				// buf.yaml of another git dep on a subdirectory of the same commit.
+				storage.MatchPathEqual(bufconfig.DefaultBufYAMLFileName),
+			),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return bufcas.ManifestToDigest(fileSet.Manifest())
+}
+
+func copyReadBucket(ctx context.Context, readBucket storage.ReadBucket) (storage.ReadBucket, error) {
+	readWriteBucket := storagemem.NewReadWriteBucket()
+	if _, err := storage.Copy(ctx, readBucket, readWriteBucket); err != nil {
+		return nil, err
+	}
+	return readWriteBucket, nil
+}
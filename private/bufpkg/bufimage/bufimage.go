@@ -24,6 +24,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/gen/data/datawkt"
 	imagev1 "github.com/bufbuild/buf/private/gen/proto/go/buf/alpha/image/v1"
@@ -284,6 +285,7 @@ func BuildImage(
 		moduleReadBucket,
 		buildImageOptions.excludeSourceCodeInfo,
 		buildImageOptions.noParallelism,
+		buildImageOptions.imageFileStore,
 	)
 }
 
@@ -308,6 +310,66 @@ func WithNoParallelism() BuildImageOption {
 	}
 }
 
+// WithImageFileStore returns a new BuildImageOption that reuses the compiled files of
+// remote Modules from the given ImageFileStore.
+//
+// Files of remote Modules that are not targets are read from the store if present, and
+// are written to the store after a successful build if not present. Local Modules are
+// never read from or written to the store.
+//
+// The default is to not use an ImageFileStore.
+func WithImageFileStore(imageFileStore ImageFileStore) BuildImageOption {
+	return func(buildImageOptions *buildImageOptions) {
+		buildImageOptions.imageFileStore = imageFileStore
+	}
+}
+
+// ImageFileStore reads and writes compiled ImageFiles.
+//
+// This is used by BuildImage to compile the files of remote Modules only once across builds.
+type ImageFileStore interface {
+	// GetImageFile gets the ImageFile for the ImageFileKey from the store.
+	//
+	// Only the FileDescriptorProto and IsSyntaxUnspecified are read from the store.
+	// Returns an error that fulfills errors.Is(err, fs.ErrNotExist) if the ImageFile is not
+	// in the store. Corrupt entries are evicted and reported as not found.
+	GetImageFile(ctx context.Context, imageFileKey ImageFileKey) (ImageFile, error)
+	// PutImageFile puts the ImageFile for the ImageFileKey to the store.
+	PutImageFile(ctx context.Context, imageFileKey ImageFileKey, imageFile ImageFile) error
+}
+
+// ImageFileKey is the key of a compiled ImageFile within an ImageFileStore.
+//
+// A compiled file can only be reused if the file itself, every file it can import, the
+// compiler, and the options that affect compilation are all the same.
+type ImageFileKey interface {
+	// ModuleDigest is a Digest of the Module that contains the file, and of all of the
+	// dependencies of the Module as resolved for the build.
+	//
+	// This identifies the contents of the file and of every file the file can import.
+	ModuleDigest() bufcas.Digest
+	// CompilerVersion identifies the version of the compiler that compiled the file.
+	CompilerVersion() string
+	// ExcludeSourceCodeInfo returns true if the file was compiled without source code info.
+	ExcludeSourceCodeInfo() bool
+	// Path is the path of the file.
+	//
+	// Normalized and validated.
+	Path() string
+
+	isImageFileKey()
+}
+
+// NewImageFileKey returns a new ImageFileKey.
+func NewImageFileKey(
+	moduleDigest bufcas.Digest,
+	compilerVersion string,
+	excludeSourceCodeInfo bool,
+	path string,
+) (ImageFileKey, error) {
+	return newImageFileKey(moduleDigest, compilerVersion, excludeSourceCodeInfo, path)
+}
+
 // CloneImage returns a deep copy of the given image.
 func CloneImage(image Image) (Image, error) {
 	originalFiles := image.Files()
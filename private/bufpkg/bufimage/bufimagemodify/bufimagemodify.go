@@ -44,8 +44,13 @@ func Modify(
 			modifyOptmizeFor,
 			modifyPhpMetadataNamespace,
 			modifyPhpNamespace,
+			modifyPyGenericServices,
 			modifyRubyPackage,
+			modifySwiftPrefix,
 			modifyJsType,
+			// Custom file options are applied last, so that they take precedence
+			// over any of the options above for the same field.
+			newModifyCustomFileOptions(image.Resolver()),
 		},
 		options...,
 	)
@@ -163,6 +168,51 @@ func ModifyRubyPackage(
 	)
 }
 
+// ModifySwiftPrefix modifies the swift_prefix file option.
+func ModifySwiftPrefix(
+	image bufimage.Image,
+	config bufconfig.GenerateManagedConfig,
+	options ...ModifyOption,
+) error {
+	return modifyImageForSingleOption(
+		image,
+		config,
+		modifySwiftPrefix,
+		options...,
+	)
+}
+
+// ModifyPyGenericServices modifies the py_generic_services file option.
+func ModifyPyGenericServices(
+	image bufimage.Image,
+	config bufconfig.GenerateManagedConfig,
+	options ...ModifyOption,
+) error {
+	return modifyImageForSingleOption(
+		image,
+		config,
+		modifyPyGenericServices,
+		options...,
+	)
+}
+
+// ModifyCustomFileOptions modifies the custom file options, such as extensions
+// of google.protobuf.FileOptions and features, that are overridden in the config.
+//
+// Custom file options are resolved against the image.
+func ModifyCustomFileOptions(
+	image bufimage.Image,
+	config bufconfig.GenerateManagedConfig,
+	options ...ModifyOption,
+) error {
+	return modifyImageForSingleOption(
+		image,
+		config,
+		newModifyCustomFileOptions(image.Resolver()),
+		options...,
+	)
+}
+
 // ModifyCcEnableArenas modifies the cc_enable_arenas file option.
 func ModifyCcEnableArenas(
 	image bufimage.Image,
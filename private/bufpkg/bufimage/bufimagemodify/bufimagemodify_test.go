@@ -25,11 +25,13 @@ import (
 	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagetesting"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
 	"github.com/bufbuild/buf/private/pkg/slogtestext"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/descriptorpb"
 )
@@ -547,6 +549,57 @@ func TestModifyImageFile(
 				"foo_all/with_package.proto":      {objcClassPrefixPath},
 			},
 		},
+		{
+			description: "swift_prefix",
+			dirPathToModuleFullName: map[string]string{
+				filepath.Join("testdata", "foo"): "buf.build/acme/foo",
+				filepath.Join("testdata", "bar"): "buf.build/acme/bar",
+			},
+			config: bufconfig.NewGenerateManagedConfig(
+				true,
+				[]bufconfig.ManagedDisableRule{
+					newTestManagedDisableRule(t, "foo_empty/with_package.proto", "", "", bufconfig.FileOptionSwiftPrefix, bufconfig.FieldOptionUnspecified),
+				},
+				[]bufconfig.ManagedOverrideRule{
+					newTestFileOptionOverrideRule(t, "", "buf.build/acme/foo", bufconfig.FileOptionSwiftPrefix, "FOO"),
+				},
+			),
+			modifyFunc: modifySwiftPrefix,
+			filePathToExpectedOptions: map[string]*descriptorpb.FileOptions{
+				"bar_empty/with_package.proto": nil,
+				"foo_empty/with_package.proto": nil,
+				"foo_empty/without_package.proto": {
+					SwiftPrefix: proto.String("FOO"),
+				},
+			},
+			filePathToExpectedMarkedLocationPaths: map[string][][]int32{
+				"foo_empty/without_package.proto": {swiftPrefixPath},
+			},
+		},
+		{
+			description: "py_generic_services",
+			dirPathToModuleFullName: map[string]string{
+				filepath.Join("testdata", "foo"): "buf.build/acme/foo",
+				filepath.Join("testdata", "bar"): "buf.build/acme/bar",
+			},
+			config: bufconfig.NewGenerateManagedConfig(
+				true,
+				[]bufconfig.ManagedDisableRule{},
+				[]bufconfig.ManagedOverrideRule{
+					newTestFileOptionOverrideRule(t, "bar_empty", "buf.build/acme/bar", bufconfig.FileOptionPyGenericServices, true),
+				},
+			),
+			modifyFunc: modifyPyGenericServices,
+			filePathToExpectedOptions: map[string]*descriptorpb.FileOptions{
+				"foo_empty/without_package.proto": nil,
+				"bar_empty/without_package.proto": {
+					PyGenericServices: proto.Bool(true),
+				},
+			},
+			filePathToExpectedMarkedLocationPaths: map[string][][]int32{
+				"bar_empty/without_package.proto": {pyGenericServicesPath},
+			},
+		},
 	}
 	for _, testcase := range testcases {
 		testcase := testcase
@@ -582,6 +635,193 @@ func TestModifyImageFile(
 	}
 }
 
+func TestModifyCustomFileOptions(t *testing.T) {
+	t.Parallel()
+	image := testGetImageFromDirs(
+		t,
+		map[string]string{
+			filepath.Join("testdata", "customoptions"): "buf.build/acme/custom",
+		},
+		true,
+	)
+	config := bufconfig.NewGenerateManagedConfig(
+		true,
+		[]bufconfig.ManagedDisableRule{
+			newTestCustomFileOptionDisableRule(t, "b.proto", "", "(acme.v1.acme_name)"),
+		},
+		[]bufconfig.ManagedOverrideRule{
+			newTestCustomFileOptionOverrideRule(t, "", "", "(acme.v1.acme_name)", "overridden"),
+			newTestCustomFileOptionOverrideRule(t, "", "buf.build/acme/custom", "(acme.v1.acme).level", 2),
+			newTestCustomFileOptionOverrideRule(t, "b.proto", "", "(acme.v1.acme).mode", "MODE_FAST"),
+		},
+	)
+	require.NoError(t, Modify(image, config))
+	acmeNameExtensionType, err := image.Resolver().FindExtensionByName("acme.v1.acme_name")
+	require.NoError(t, err)
+	acmeExtensionType, err := image.Resolver().FindExtensionByName("acme.v1.acme")
+	require.NoError(t, err)
+	acmeOptionsDescriptor := acmeExtensionType.TypeDescriptor().Message()
+	testcases := []struct {
+		filePath                string
+		expectedAcmeName        string
+		expectedLevel           int32
+		expectedMode            protoreflect.EnumNumber
+		expectedRemovedLocation []int32
+	}{
+		{
+			filePath:                "a.proto",
+			expectedAcmeName:        "overridden",
+			expectedLevel:           2,
+			expectedRemovedLocation: []int32{8, 50000},
+		},
+		{
+			filePath: "b.proto",
+			// acme_name is disabled for b.proto.
+			expectedLevel:           2,
+			expectedMode:            1,
+			expectedRemovedLocation: []int32{8, 50001, 3},
+		},
+	}
+	for _, testcase := range testcases {
+		imageFile := image.GetFile(testcase.filePath)
+		require.NotNil(t, imageFile)
+		fileOptions := proto.Clone(imageFile.FileDescriptorProto().GetOptions()).(*descriptorpb.FileOptions)
+		require.NoError(t, protoencoding.ReparseExtensions(image.Resolver(), fileOptions.ProtoReflect()))
+		fileOptionsMessage := fileOptions.ProtoReflect()
+		require.Equal(
+			t,
+			testcase.expectedAcmeName,
+			fileOptionsMessage.Get(acmeNameExtensionType.TypeDescriptor()).String(),
+			testcase.filePath,
+		)
+		acmeOptions := fileOptionsMessage.Get(acmeExtensionType.TypeDescriptor()).Message()
+		require.Equal(t, testcase.expectedLevel, int32(acmeOptions.Get(acmeOptionsDescriptor.Fields().ByName("level")).Int()), testcase.filePath)
+		require.Equal(t, testcase.expectedMode, acmeOptions.Get(acmeOptionsDescriptor.Fields().ByName("mode")).Enum(), testcase.filePath)
+		for _, location := range imageFile.FileDescriptorProto().GetSourceCodeInfo().GetLocation() {
+			require.NotEqual(t, testcase.expectedRemovedLocation, location.GetPath(), testcase.filePath)
+		}
+	}
+}
+
+func TestModifyCustomFileOptionsDisableMessage(t *testing.T) {
+	t.Parallel()
+	image := testGetImageFromDirs(
+		t,
+		map[string]string{
+			filepath.Join("testdata", "customoptions"): "buf.build/acme/custom",
+		},
+		true,
+	)
+	config := bufconfig.NewGenerateManagedConfig(
+		true,
+		[]bufconfig.ManagedDisableRule{
+			// Disabling a message option disables all of its fields, but not (acme.v1.acme_name).
+			newTestCustomFileOptionDisableRule(t, "a.proto", "", "(acme.v1.acme)"),
+		},
+		[]bufconfig.ManagedOverrideRule{
+			newTestCustomFileOptionOverrideRule(t, "", "", "(acme.v1.acme_name)", "overridden"),
+			newTestCustomFileOptionOverrideRule(t, "", "", "(acme.v1.acme).level", 2),
+			newTestCustomFileOptionOverrideRule(t, "", "", "(acme.v1.acme).mode", "MODE_FAST"),
+		},
+	)
+	require.NoError(t, Modify(image, config))
+	acmeNameExtensionType, err := image.Resolver().FindExtensionByName("acme.v1.acme_name")
+	require.NoError(t, err)
+	acmeExtensionType, err := image.Resolver().FindExtensionByName("acme.v1.acme")
+	require.NoError(t, err)
+	acmeOptionsDescriptor := acmeExtensionType.TypeDescriptor().Message()
+	testcases := []struct {
+		filePath         string
+		expectedAcmeName string
+		expectedLevel    int32
+		expectedMode     protoreflect.EnumNumber
+	}{
+		{
+			filePath:         "a.proto",
+			expectedAcmeName: "overridden",
+			expectedLevel:    1,
+		},
+		{
+			filePath:         "b.proto",
+			expectedAcmeName: "overridden",
+			expectedLevel:    2,
+			expectedMode:     1,
+		},
+	}
+	for _, testcase := range testcases {
+		imageFile := image.GetFile(testcase.filePath)
+		require.NotNil(t, imageFile)
+		fileOptions := proto.Clone(imageFile.FileDescriptorProto().GetOptions()).(*descriptorpb.FileOptions)
+		require.NoError(t, protoencoding.ReparseExtensions(image.Resolver(), fileOptions.ProtoReflect()))
+		fileOptionsMessage := fileOptions.ProtoReflect()
+		require.Equal(
+			t,
+			testcase.expectedAcmeName,
+			fileOptionsMessage.Get(acmeNameExtensionType.TypeDescriptor()).String(),
+			testcase.filePath,
+		)
+		acmeOptions := fileOptionsMessage.Get(acmeExtensionType.TypeDescriptor()).Message()
+		require.Equal(t, testcase.expectedLevel, int32(acmeOptions.Get(acmeOptionsDescriptor.Fields().ByName("level")).Int()), testcase.filePath)
+		require.Equal(t, testcase.expectedMode, acmeOptions.Get(acmeOptionsDescriptor.Fields().ByName("mode")).Enum(), testcase.filePath)
+	}
+}
+
+func TestModifyCustomFileOptionsErrors(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		description      string
+		customFileOption string
+		value            interface{}
+	}{
+		{
+			description:      "unknown_extension",
+			customFileOption: "(acme.v1.unknown)",
+			value:            "value",
+		},
+		{
+			description:      "unknown_field",
+			customFileOption: "(acme.v1.acme).unknown",
+			value:            "value",
+		},
+		{
+			description:      "message_option",
+			customFileOption: "(acme.v1.acme)",
+			value:            "value",
+		},
+		{
+			description:      "wrong_value_type",
+			customFileOption: "(acme.v1.acme).level",
+			value:            "value",
+		},
+		{
+			description:      "unknown_enum_value",
+			customFileOption: "(acme.v1.acme).mode",
+			value:            "MODE_UNKNOWN",
+		},
+	}
+	for _, testcase := range testcases {
+		testcase := testcase
+		t.Run(testcase.description, func(t *testing.T) {
+			t.Parallel()
+			image := testGetImageFromDirs(
+				t,
+				map[string]string{
+					filepath.Join("testdata", "customoptions"): "buf.build/acme/custom",
+				},
+				false,
+			)
+			config := bufconfig.NewGenerateManagedConfig(
+				true,
+				nil,
+				[]bufconfig.ManagedOverrideRule{
+					newTestCustomFileOptionOverrideRule(t, "", "", testcase.customFileOption, testcase.value),
+				},
+			)
+			require.Error(t, ModifyCustomFileOptions(image, config))
+		})
+	}
+}
+
 // TODO FUTURE: add default values
 func TestGetStringOverrideFromConfig(t *testing.T) {
 	t.Parallel()
@@ -854,3 +1094,35 @@ func newTestFileOptionOverrideRule(
 	require.NoError(t, err)
 	return fileOptionOverride
 }
+
+func newTestCustomFileOptionDisableRule(
+	t *testing.T,
+	path string,
+	moduleFullName string,
+	customFileOption string,
+) bufconfig.ManagedDisableRule {
+	disable, err := bufconfig.NewManagedDisableRuleForCustomFileOption(
+		path,
+		moduleFullName,
+		customFileOption,
+	)
+	require.NoError(t, err)
+	return disable
+}
+
+func newTestCustomFileOptionOverrideRule(
+	t *testing.T,
+	path string,
+	moduleFullName string,
+	customFileOption string,
+	value interface{},
+) bufconfig.ManagedOverrideRule {
+	customFileOptionOverride, err := bufconfig.NewManagedOverrideRuleForCustomFileOption(
+		path,
+		moduleFullName,
+		customFileOption,
+		value,
+	)
+	require.NoError(t, err)
+	return customFileOptionOverride
+}
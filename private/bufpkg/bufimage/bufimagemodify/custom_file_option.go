@@ -0,0 +1,346 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimagemodify
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufconfig"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagemodify/internal"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// fileOptionsTagInFile is the field number of options in google.protobuf.FileDescriptorProto.
+const fileOptionsTagInFile = 8
+
+// newModifyCustomFileOptions returns a modify func that applies the custom file
+// option overrides, resolving extensions with the given resolver.
+func newModifyCustomFileOptions(
+	resolver protoencoding.Resolver,
+) func(internal.MarkSweeper, bufimage.ImageFile, bufconfig.GenerateManagedConfig, ...ModifyOption) error {
+	return func(
+		sweeper internal.MarkSweeper,
+		imageFile bufimage.ImageFile,
+		config bufconfig.GenerateManagedConfig,
+		options ...ModifyOption,
+	) error {
+		modifyOptions := newModifyOptions()
+		for _, option := range options {
+			option(modifyOptions)
+		}
+		// Unless overridden, custom file options are not modified. The last
+		// matching override for each option wins.
+		var customFileOptions []string
+		customFileOptionToValue := make(map[string]interface{})
+		for _, overrideRule := range config.Overrides() {
+			customFileOption := overrideRule.CustomFileOption()
+			if customFileOption == "" {
+				continue
+			}
+			if !fileMatchConfig(imageFile, overrideRule.Path(), overrideRule.ModuleFullName()) {
+				continue
+			}
+			if _, ok := customFileOptionToValue[customFileOption]; !ok {
+				customFileOptions = append(customFileOptions, customFileOption)
+			}
+			customFileOptionToValue[customFileOption] = overrideRule.Value()
+		}
+		for _, customFileOption := range customFileOptions {
+			if isCustomFileOptionDisabledForFile(imageFile, customFileOption, config) {
+				continue
+			}
+			if err := modifyCustomFileOption(
+				sweeper,
+				imageFile,
+				resolver,
+				modifyOptions.preserveExisting,
+				customFileOption,
+				customFileOptionToValue[customFileOption],
+			); err != nil {
+				return fmt.Errorf("%s: file_option %s: %w", imageFile.Path(), customFileOption, err)
+			}
+		}
+		return nil
+	}
+}
+
+// *** PRIVATE ***
+
+func modifyCustomFileOption(
+	sweeper internal.MarkSweeper,
+	imageFile bufimage.ImageFile,
+	resolver protoencoding.Resolver,
+	preserveExisting bool,
+	customFileOption string,
+	overrideValue interface{},
+) error {
+	descriptor := imageFile.FileDescriptorProto()
+	// We modify a copy, and only replace the options of the file if the value changed.
+	fileOptions := &descriptorpb.FileOptions{}
+	if descriptor.Options != nil {
+		fileOptions = proto.Clone(descriptor.Options).(*descriptorpb.FileOptions)
+	}
+	// Extensions are usually unrecognized fields at this point.
+	if err := protoencoding.ReparseExtensions(resolver, fileOptions.ProtoReflect()); err != nil {
+		return err
+	}
+	components := splitCustomFileOptionName(customFileOption)
+	sourceLocationPath := []int32{fileOptionsTagInFile}
+	message := fileOptions.ProtoReflect()
+	for i, component := range components {
+		fieldDescriptor, err := resolveCustomFileOptionComponent(resolver, message.Descriptor(), component)
+		if err != nil {
+			return err
+		}
+		sourceLocationPath = append(sourceLocationPath, int32(fieldDescriptor.Number()))
+		if i < len(components)-1 {
+			if fieldDescriptor.Message() == nil || fieldDescriptor.IsList() || fieldDescriptor.IsMap() {
+				return fmt.Errorf("%s is not a singular message field", fieldDescriptor.FullName())
+			}
+			message = message.Mutable(fieldDescriptor).Message()
+			continue
+		}
+		if fieldDescriptor.Message() != nil || fieldDescriptor.IsList() || fieldDescriptor.IsMap() {
+			return fmt.Errorf("%s is not a singular scalar field", fieldDescriptor.FullName())
+		}
+		if preserveExisting && message.Has(fieldDescriptor) {
+			return nil
+		}
+		value, err := customFileOptionValue(fieldDescriptor, overrideValue)
+		if err != nil {
+			return err
+		}
+		if message.Has(fieldDescriptor) && scalarValuesEqual(message.Get(fieldDescriptor), value) {
+			// The option is already set to the same value, don't modify or mark it.
+			return nil
+		}
+		message.Set(fieldDescriptor, value)
+	}
+	descriptor.Options = fileOptions
+	sweeper.Mark(imageFile, sourceLocationPath)
+	return nil
+}
+
+func isCustomFileOptionDisabledForFile(
+	imageFile bufimage.ImageFile,
+	customFileOption string,
+	config bufconfig.GenerateManagedConfig,
+) bool {
+	for _, disableRule := range config.Disables() {
+		if disableRule.FileOption() != bufconfig.FileOptionUnspecified ||
+			disableRule.FieldOption() != bufconfig.FieldOptionUnspecified {
+			continue
+		}
+		if disableRule.CustomFileOption() != "" && !customFileOptionNameHasPrefix(customFileOption, disableRule.CustomFileOption()) {
+			continue
+		}
+		if !fileMatchConfig(imageFile, disableRule.Path(), disableRule.ModuleFullName()) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// customFileOptionNameHasPrefix returns true if the custom file option name is equal to the
+// prefix, or is a field within the prefix, such as "(acme.v1.options).level" for the
+// prefix "(acme.v1.options)".
+func customFileOptionNameHasPrefix(customFileOption string, prefix string) bool {
+	components := splitCustomFileOptionName(customFileOption)
+	prefixComponents := splitCustomFileOptionName(prefix)
+	if len(prefixComponents) > len(components) {
+		return false
+	}
+	return slices.Equal(components[:len(prefixComponents)], prefixComponents)
+}
+
+// splitCustomFileOptionName splits a custom file option name such as
+// "features.(pb.go).api_level" into "features", "(pb.go)" and "api_level".
+//
+// The name has already been validated by bufconfig.
+func splitCustomFileOptionName(customFileOption string) []string {
+	var components []string
+	for remaining := customFileOption; remaining != ""; {
+		var end int
+		if strings.HasPrefix(remaining, "(") {
+			end = strings.Index(remaining, ")") + 1
+		} else {
+			end = strings.Index(remaining, ".")
+			if end < 0 {
+				end = len(remaining)
+			}
+		}
+		components = append(components, remaining[:end])
+		remaining = strings.TrimPrefix(remaining[end:], ".")
+	}
+	return components
+}
+
+func resolveCustomFileOptionComponent(
+	resolver protoencoding.Resolver,
+	messageDescriptor protoreflect.MessageDescriptor,
+	component string,
+) (protoreflect.FieldDescriptor, error) {
+	if !strings.HasPrefix(component, "(") {
+		fieldDescriptor := messageDescriptor.Fields().ByName(protoreflect.Name(component))
+		if fieldDescriptor == nil {
+			return nil, fmt.Errorf("%s has no field named %q", messageDescriptor.FullName(), component)
+		}
+		return fieldDescriptor, nil
+	}
+	extensionName := protoreflect.FullName(strings.TrimPrefix(strings.TrimSuffix(component, ")"), "("))
+	extensionType, err := resolver.FindExtensionByName(extensionName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve extension %s: %w", extensionName, err)
+	}
+	extensionDescriptor := extensionType.TypeDescriptor()
+	if extendee := extensionDescriptor.ContainingMessage().FullName(); extendee != messageDescriptor.FullName() {
+		return nil, fmt.Errorf("extension %s extends %s, not %s", extensionName, extendee, messageDescriptor.FullName())
+	}
+	return extensionDescriptor, nil
+}
+
+// customFileOptionValue converts the value from the configuration to a value for the field.
+func customFileOptionValue(fieldDescriptor protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	invalidValueErr := fmt.Errorf("invalid value %v for %s of kind %v", value, fieldDescriptor.FullName(), fieldDescriptor.Kind())
+	switch fieldDescriptor.Kind() {
+	case protoreflect.BoolKind:
+		boolValue, ok := value.(bool)
+		if !ok {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfBool(boolValue), nil
+	case protoreflect.StringKind:
+		stringValue, ok := value.(string)
+		if !ok {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfString(stringValue), nil
+	case protoreflect.BytesKind:
+		stringValue, ok := value.(string)
+		if !ok {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfBytes([]byte(stringValue)), nil
+	case protoreflect.EnumKind:
+		if enumValueName, ok := value.(string); ok {
+			enumValue := fieldDescriptor.Enum().Values().ByName(protoreflect.Name(enumValueName))
+			if enumValue == nil {
+				return protoreflect.Value{}, fmt.Errorf("%s has no value named %q", fieldDescriptor.Enum().FullName(), enumValueName)
+			}
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		intValue, ok := int64ForValue(value)
+		if !ok || intValue < math.MinInt32 || intValue > math.MaxInt32 {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(intValue)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		intValue, ok := int64ForValue(value)
+		if !ok || intValue < math.MinInt32 || intValue > math.MaxInt32 {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfInt32(int32(intValue)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		intValue, ok := int64ForValue(value)
+		if !ok {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfInt64(intValue), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		intValue, ok := int64ForValue(value)
+		if !ok || intValue < 0 || intValue > math.MaxUint32 {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfUint32(uint32(intValue)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if uintValue, ok := value.(uint64); ok {
+			return protoreflect.ValueOfUint64(uintValue), nil
+		}
+		intValue, ok := int64ForValue(value)
+		if !ok || intValue < 0 {
+			return protoreflect.Value{}, invalidValueErr
+		}
+		return protoreflect.ValueOfUint64(uint64(intValue)), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		var floatValue float64
+		switch typedValue := value.(type) {
+		case float32:
+			floatValue = float64(typedValue)
+		case float64:
+			floatValue = typedValue
+		default:
+			intValue, ok := int64ForValue(value)
+			if !ok {
+				return protoreflect.Value{}, invalidValueErr
+			}
+			floatValue = float64(intValue)
+		}
+		if fieldDescriptor.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(floatValue)), nil
+		}
+		return protoreflect.ValueOfFloat64(floatValue), nil
+	default:
+		return protoreflect.Value{}, invalidValueErr
+	}
+}
+
+// int64ForValue returns the value as an int64 if it is an integer. Floats with
+// integral values are accepted, since JSON numbers are decoded as float64.
+func int64ForValue(value interface{}) (int64, bool) {
+	switch typedValue := value.(type) {
+	case int:
+		return int64(typedValue), true
+	case int32:
+		return int64(typedValue), true
+	case int64:
+		return typedValue, true
+	case uint:
+		if uint64(typedValue) > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(typedValue), true
+	case uint32:
+		return int64(typedValue), true
+	case uint64:
+		if typedValue > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(typedValue), true
+	case float64:
+		if typedValue != math.Trunc(typedValue) || typedValue < math.MinInt64 || typedValue > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(typedValue), true
+	default:
+		return 0, false
+	}
+}
+
+func scalarValuesEqual(a protoreflect.Value, b protoreflect.Value) bool {
+	if aBytes, ok := a.Interface().([]byte); ok {
+		bBytes, ok := b.Interface().([]byte)
+		return ok && bytes.Equal(aBytes, bBytes)
+	}
+	return a.Interface() == b.Interface()
+}
@@ -59,7 +59,8 @@ func modifyJsType(
 		func(disable bufconfig.ManagedDisableRule) bool {
 			return (disable.FieldOption() == bufconfig.FieldOptionJSType ||
 				(disable.FieldOption() == bufconfig.FieldOptionUnspecified &&
-					disable.FileOption() == bufconfig.FileOptionUnspecified)) &&
+					disable.FileOption() == bufconfig.FileOptionUnspecified &&
+					disable.CustomFileOption() == "")) &&
 				fileMatchConfig(imageFile, disable.Path(), disable.ModuleFullName())
 		},
 	)
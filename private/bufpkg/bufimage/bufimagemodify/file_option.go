@@ -56,6 +56,12 @@ var (
 	// phpNamespacePath is the SourceCodeInfo path for the php_namespace option.
 	// Ref: https://github.com/protocolbuffers/protobuf/blob/61689226c0e3ec88287eaed66164614d9c4f2bf7/src/google/protobuf/descriptor.proto#L443
 	phpNamespacePath = []int32{8, 41}
+	// pyGenericServicesPath is the SourceCodeInfo path for the py_generic_services option.
+	// https://github.com/protocolbuffers/protobuf/blob/61689226c0e3ec88287eaed66164614d9c4f2bf7/src/google/protobuf/descriptor.proto#L403
+	pyGenericServicesPath = []int32{8, 18}
+	// swiftPrefixPath is the SourceCodeInfo path for the swift_prefix option.
+	// https://github.com/protocolbuffers/protobuf/blob/61689226c0e3ec88287eaed66164614d9c4f2bf7/src/google/protobuf/descriptor.proto#L432
+	swiftPrefixPath = []int32{8, 39}
 
 	// rubyPackagePath is the SourceCodeInfo path for the ruby_package option.
 	// https://github.com/protocolbuffers/protobuf/blob/61689226c0e3ec88287eaed66164614d9c4f2bf7/src/google/protobuf/descriptor.proto#L453
@@ -359,6 +365,44 @@ func modifyRubyPackage(
 	)
 }
 
+func modifySwiftPrefix(
+	sweeper internal.MarkSweeper,
+	imageFile bufimage.ImageFile,
+	config bufconfig.GenerateManagedConfig,
+	options ...ModifyOption,
+) error {
+	modifyOptions := newModifyOptions()
+	for _, option := range options {
+		option(modifyOptions)
+	}
+	return modifyStringOption(
+		sweeper,
+		imageFile,
+		config,
+		modifyOptions.preserveExisting,
+		bufconfig.FileOptionSwiftPrefix,
+		bufconfig.FileOptionUnspecified,
+		bufconfig.FileOptionUnspecified,
+		// Unless overridden, swift_prefix is not modified.
+		func(bufimage.ImageFile) stringOverrideOptions {
+			return stringOverrideOptions{}
+		},
+		func(bufimage.ImageFile, stringOverrideOptions) string {
+			return ""
+		},
+		func(options *descriptorpb.FileOptions) string {
+			return options.GetSwiftPrefix()
+		},
+		func(options *descriptorpb.FileOptions, value string) {
+			options.SwiftPrefix = proto.String(value)
+		},
+		func(options *descriptorpb.FileOptions) bool {
+			return options != nil && options.SwiftPrefix != nil
+		},
+		swiftPrefixPath,
+	)
+}
+
 func modifyCcEnableArenas(
 	sweeper internal.MarkSweeper,
 	imageFile bufimage.ImageFile,
@@ -449,6 +493,37 @@ func modifyJavaStringCheckUtf8(
 	)
 }
 
+func modifyPyGenericServices(
+	sweeper internal.MarkSweeper,
+	imageFile bufimage.ImageFile,
+	config bufconfig.GenerateManagedConfig,
+	options ...ModifyOption,
+) error {
+	modifyOptions := newModifyOptions()
+	for _, option := range options {
+		option(modifyOptions)
+	}
+	return modifyFileOption(
+		sweeper,
+		imageFile,
+		config,
+		modifyOptions.preserveExisting,
+		bufconfig.FileOptionPyGenericServices,
+		// Unless overridden, py_generic_services is not modified.
+		imageFile.FileDescriptorProto().GetOptions().GetPyGenericServices(),
+		func(options *descriptorpb.FileOptions) bool {
+			return options.GetPyGenericServices()
+		},
+		func(options *descriptorpb.FileOptions, value bool) {
+			options.PyGenericServices = proto.Bool(value)
+		},
+		func(options *descriptorpb.FileOptions) bool {
+			return options != nil && options.PyGenericServices != nil
+		},
+		pyGenericServicesPath,
+	)
+}
+
 func modifyOptmizeFor(
 	sweeper internal.MarkSweeper,
 	imageFile bufimage.ImageFile,
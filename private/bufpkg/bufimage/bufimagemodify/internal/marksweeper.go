@@ -156,9 +156,10 @@ func removeLocationsFromSourceCodeInfo(
 }
 
 func isPathForFileOption(path []int32) bool {
-	// a file option's path is {8, x}
-	fileOptionPathLen := 2
-	return len(path) == fileOptionPathLen && path[0] == fileOptionPath[0]
+	// a file option's path is {8, x}, or {8, x, y, ...} for a field within a
+	// message-typed file option, such as features or a custom option.
+	fileOptionPathMinLen := 2
+	return len(path) >= fileOptionPathMinLen && path[0] == fileOptionPath[0]
 }
 
 // getPathKey returns a unique key for the given path.
@@ -0,0 +1,373 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimagestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/slogext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// externalImageFileVersion is the version written to the header of every stored file.
+	//
+	// If the format of stored files changes, this should be changed. Stored files with a
+	// different version are evicted on read.
+	externalImageFileVersion = "v1"
+	// externalImageFileSyntaxUnspecifiedFlag is the header flag for files that did not
+	// specify a syntax.
+	externalImageFileSyntaxUnspecifiedFlag = "syntax_unspecified"
+	// externalImageFileNoFlags is the header flag for files with no flags.
+	externalImageFileNoFlags = "-"
+	// externalEntryFileName is the name of the file within each entry directory that records
+	// information about the module of the entry.
+	externalEntryFileName = "entry.json"
+	// externalLastAccessFileName is the name of the file within each entry directory that
+	// records the last time the entry was read from or written to.
+	externalLastAccessFileName = "last_access"
+	// externalFilesDirName is the name of the directory within each entry directory that
+	// contains the stored files.
+	externalFilesDirName = "files"
+	// lastAccessRecordInterval is the minimum interval between writes of the last access file.
+	//
+	// This bounds the number of writes we do on reads from the store.
+	lastAccessRecordInterval = time.Hour
+
+	sourceCodeInfoDirName   = "source"
+	noSourceCodeInfoDirName = "nosource"
+)
+
+// NewImageFileStore returns a new bufimage.ImageFileStore for the given bucket.
+//
+// It is assumed that the ImageFileStore has complete control of the bucket.
+//
+// Every file is written atomically and is stored with the Digest of its content, which
+// is verified on every read. Files that fail verification are deleted and treated as
+// not found, so no locking is needed between processes that share the bucket.
+//
+// This is typically used to interact with a cache directory.
+func NewImageFileStore(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) bufimage.ImageFileStore {
+	return newImageFileStore(logger, bucket)
+}
+
+// *** PRIVATE ***
+
+type imageFileStore struct {
+	logger *slog.Logger
+	bucket storage.ReadWriteBucket
+}
+
+func newImageFileStore(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) *imageFileStore {
+	return &imageFileStore{
+		logger: logger,
+		bucket: bucket,
+	}
+}
+
+func (p *imageFileStore) GetImageFile(
+	ctx context.Context,
+	imageFileKey bufimage.ImageFileKey,
+) (_ bufimage.ImageFile, retErr error) {
+	entryBucket := p.getReadWriteBucketForEntry(imageFileKey)
+	path := normalpath.Join(externalFilesDirName, imageFileKey.Path())
+	data, err := storage.ReadPath(ctx, entryBucket, path)
+	p.logDebugImageFileKey(
+		ctx,
+		imageFileKey,
+		"image file store get file",
+		slog.Bool("found", err == nil),
+		slogext.ErrorAttr(err),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var invalidReason string
+	defer func() {
+		if retErr != nil {
+			retErr = p.deleteInvalidImageFile(ctx, imageFileKey, entryBucket, path, invalidReason, retErr)
+		}
+	}()
+	isSyntaxUnspecified, payload, err := readExternalImageFile(data)
+	if err != nil {
+		invalidReason = "corrupted"
+		return nil, err
+	}
+	fileDescriptorProto := &descriptorpb.FileDescriptorProto{}
+	if err := protoencoding.NewWireUnmarshaler(nil).Unmarshal(payload, fileDescriptorProto); err != nil {
+		invalidReason = "corrupted"
+		return nil, err
+	}
+	if fileDescriptorProto.GetName() != imageFileKey.Path() {
+		invalidReason = "mismatched path"
+		return nil, fmt.Errorf("expected stored file %q but got %q", imageFileKey.Path(), fileDescriptorProto.GetName())
+	}
+	imageFile, err := bufimage.NewImageFile(
+		fileDescriptorProto,
+		nil,
+		uuid.Nil,
+		"",
+		"",
+		false,
+		isSyntaxUnspecified,
+		nil,
+	)
+	if err != nil {
+		invalidReason = "invalid"
+		return nil, err
+	}
+	p.recordLastAccess(ctx, imageFileKey, entryBucket)
+	return imageFile, nil
+}
+
+func (p *imageFileStore) PutImageFile(
+	ctx context.Context,
+	imageFileKey bufimage.ImageFileKey,
+	imageFile bufimage.ImageFile,
+) error {
+	if imageFile.Path() != imageFileKey.Path() {
+		return fmt.Errorf("cannot put file %q for key with path %q", imageFile.Path(), imageFileKey.Path())
+	}
+	payload, err := protoencoding.NewWireMarshaler().Marshal(imageFile.FileDescriptorProto())
+	if err != nil {
+		return err
+	}
+	data, err := newExternalImageFile(imageFile.IsSyntaxUnspecified(), payload)
+	if err != nil {
+		return err
+	}
+	entryBucket := p.getReadWriteBucketForEntry(imageFileKey)
+	if moduleFullName := imageFile.ModuleFullName(); moduleFullName != nil {
+		if _, err := entryBucket.Stat(ctx, externalEntryFileName); err != nil {
+			externalEntry := externalEntry{
+				Module: moduleFullName.String(),
+			}
+			if commitID := imageFile.CommitID(); commitID != uuid.Nil {
+				externalEntry.Commit = commitID.String()
+			}
+			entryData, err := json.Marshal(externalEntry)
+			if err != nil {
+				return err
+			}
+			if err := storage.PutPath(ctx, entryBucket, externalEntryFileName, entryData, storage.PutWithAtomic()); err != nil {
+				return err
+			}
+		}
+	}
+	path := normalpath.Join(externalFilesDirName, imageFileKey.Path())
+	err = storage.PutPath(ctx, entryBucket, path, data, storage.PutWithAtomic())
+	p.logDebugImageFileKey(
+		ctx,
+		imageFileKey,
+		"image file store put file",
+		slogext.ErrorAttr(err),
+	)
+	if err != nil {
+		return err
+	}
+	p.recordLastAccess(ctx, imageFileKey, entryBucket)
+	return nil
+}
+
+func (p *imageFileStore) getReadWriteBucketForEntry(imageFileKey bufimage.ImageFileKey) storage.ReadWriteBucket {
+	return storage.MapReadWriteBucket(p.bucket, storage.MapOnPrefix(getImageFileStoreEntryDirPath(imageFileKey)))
+}
+
+func (p *imageFileStore) deleteInvalidImageFile(
+	ctx context.Context,
+	imageFileKey bufimage.ImageFileKey,
+	bucket storage.WriteBucket,
+	path string,
+	invalidReason string,
+	invalidErr error,
+) error {
+	p.logDebugImageFileKey(
+		ctx,
+		imageFileKey,
+		fmt.Sprintf("image file store %s file", invalidReason),
+		slog.Any("error", invalidErr),
+	)
+	// Attempt to delete file as it cannot be used.
+	if err := bucket.Delete(ctx, path); err != nil {
+		// Otherwise ignore error.
+		p.logDebugImageFileKey(
+			ctx,
+			imageFileKey,
+			fmt.Sprintf("image file store could not delete %s file", invalidReason),
+			slogext.ErrorAttr(err),
+		)
+	}
+	// This will act as if the file is not found
+	return &fs.PathError{Op: "read", Path: path, Err: fs.ErrNotExist}
+}
+
+// recordLastAccess records the current time as the last access time of the entry.
+//
+// The time is only written if the recorded time is older than lastAccessRecordInterval.
+// Errors are logged and otherwise ignored, as the last access time is only used for pruning.
+func (p *imageFileStore) recordLastAccess(
+	ctx context.Context,
+	imageFileKey bufimage.ImageFileKey,
+	entryBucket storage.ReadWriteBucket,
+) {
+	now := time.Now().UTC()
+	if lastAccessTime, err := readLastAccessTime(ctx, entryBucket); err == nil && now.Sub(lastAccessTime) < lastAccessRecordInterval {
+		return
+	}
+	err := storage.PutPath(
+		ctx,
+		entryBucket,
+		externalLastAccessFileName,
+		[]byte(now.Format(time.RFC3339)),
+		storage.PutWithAtomic(),
+	)
+	p.logDebugImageFileKey(
+		ctx,
+		imageFileKey,
+		fmt.Sprintf("image file store put %s", externalLastAccessFileName),
+		slogext.ErrorAttr(err),
+	)
+}
+
+func (p *imageFileStore) logDebugImageFileKey(ctx context.Context, imageFileKey bufimage.ImageFileKey, message string, fields ...any) {
+	p.logger.DebugContext(
+		ctx,
+		message,
+		append(
+			[]any{
+				slog.String("moduleDigest", imageFileKey.ModuleDigest().String()),
+				slog.String("compilerVersion", imageFileKey.CompilerVersion()),
+				slog.Bool("excludeSourceCodeInfo", imageFileKey.ExcludeSourceCodeInfo()),
+				slog.String("path", imageFileKey.Path()),
+			},
+			fields...,
+		)...,
+	)
+}
+
+// readLastAccessTime reads the last access time from the bucket for a single entry.
+func readLastAccessTime(ctx context.Context, entryBucket storage.ReadBucket) (time.Time, error) {
+	data, err := storage.ReadPath(ctx, entryBucket, externalLastAccessFileName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, string(data))
+}
+
+// Returns the directory path within the store for the entry of the ImageFileKey.
+//
+// This is "compilerVersion/digestType/digestHex/source", i.e.
+// "protocompile-v0.14.1-1/shake256/12345abcde/nosource".
+func getImageFileStoreEntryDirPath(imageFileKey bufimage.ImageFileKey) string {
+	return getEntryDirPath(
+		imageFileKey.CompilerVersion(),
+		imageFileKey.ModuleDigest(),
+		imageFileKey.ExcludeSourceCodeInfo(),
+	)
+}
+
+func getEntryDirPath(compilerVersion string, moduleDigest bufcas.Digest, excludeSourceCodeInfo bool) string {
+	sourceDirName := sourceCodeInfoDirName
+	if excludeSourceCodeInfo {
+		sourceDirName = noSourceCodeInfoDirName
+	}
+	return normalpath.Join(
+		compilerVersion,
+		moduleDigest.Type().String(),
+		hex.EncodeToString(moduleDigest.Value()),
+		sourceDirName,
+	)
+}
+
+// newExternalImageFile returns the store representation of a file.
+//
+// This is a header line "version digest flags" followed by the payload, where the Digest
+// is the Digest of the payload.
+func newExternalImageFile(isSyntaxUnspecified bool, payload []byte) ([]byte, error) {
+	digest, err := bufcas.NewDigestForContent(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	flags := externalImageFileNoFlags
+	if isSyntaxUnspecified {
+		flags = externalImageFileSyntaxUnspecifiedFlag
+	}
+	header := fmt.Sprintf("%s %s %s\n", externalImageFileVersion, digest.String(), flags)
+	return append([]byte(header), payload...), nil
+}
+
+// readExternalImageFile reads the store representation of a file, verifying the Digest
+// of the payload.
+func readExternalImageFile(data []byte) (isSyntaxUnspecified bool, payload []byte, _ error) {
+	header, payload, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return false, nil, errors.New("no header")
+	}
+	headerFields := strings.Fields(string(header))
+	if len(headerFields) != 3 {
+		return false, nil, fmt.Errorf("invalid header %q", string(header))
+	}
+	if headerFields[0] != externalImageFileVersion {
+		return false, nil, fmt.Errorf("unknown version %q", headerFields[0])
+	}
+	expectedDigest, err := bufcas.ParseDigest(headerFields[1])
+	if err != nil {
+		return false, nil, err
+	}
+	switch headerFields[2] {
+	case externalImageFileNoFlags:
+	case externalImageFileSyntaxUnspecifiedFlag:
+		isSyntaxUnspecified = true
+	default:
+		return false, nil, fmt.Errorf("unknown flags %q", headerFields[2])
+	}
+	actualDigest, err := bufcas.NewDigestForContent(bytes.NewReader(payload))
+	if err != nil {
+		return false, nil, err
+	}
+	if !bufcas.DigestEqual(expectedDigest, actualDigest) {
+		return false, nil, fmt.Errorf("expected digest %q but got %q", expectedDigest.String(), actualDigest.String())
+	}
+	return isSyntaxUnspecified, payload, nil
+}
+
+// externalEntry is the store representation of the module of an entry.
+//
+// This is informational only, and is used when listing entries.
+type externalEntry struct {
+	Module string `json:"module,omitempty" yaml:"module,omitempty"`
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+}
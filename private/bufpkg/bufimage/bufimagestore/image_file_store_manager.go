@@ -0,0 +1,337 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimagestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slogext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// ErrCorruptStoreEntry is wrapped by errors returned when verifying an entry of an
+// ImageFileStore finds that the entry is corrupt.
+var ErrCorruptStoreEntry = errors.New("corrupt image file store entry")
+
+// ImageFileStoreEntry is the set of compiled files of a single module stored within
+// an ImageFileStore.
+type ImageFileStoreEntry interface {
+	// ModuleFullName is the full name of the module the files were compiled from.
+	//
+	// May be nil if the module had no name.
+	ModuleFullName() bufmodule.ModuleFullName
+	// CommitID is the ID of the commit of the module the files were compiled from.
+	//
+	// May be empty if the module had no commit.
+	CommitID() uuid.UUID
+	// ModuleDigest is the Digest of the module and its dependencies the entry is keyed by.
+	ModuleDigest() bufcas.Digest
+	// CompilerVersion is the version of the compiler the files were compiled with.
+	CompilerVersion() string
+	// ExcludeSourceCodeInfo says whether the files were compiled without source code info.
+	ExcludeSourceCodeInfo() bool
+	// Size is the total size of all files of the entry in bytes.
+	Size() int64
+	// LastAccessTime is the last time a file of the entry was read from or written to the store.
+	//
+	// Access times are recorded with a granularity of an hour. If no access time was
+	// recorded, this is the latest modification time of the files of the entry if the
+	// store is on local disk, and the zero time otherwise.
+	LastAccessTime() time.Time
+
+	isImageFileStoreEntry()
+}
+
+// ImageFileStoreManager lists, verifies, and deletes the entries of an ImageFileStore.
+type ImageFileStoreManager interface {
+	// ListImageFileStoreEntries lists all entries in the store.
+	//
+	// Ordered by ModuleFullName, then CommitID, then CompilerVersion, then ModuleDigest,
+	// then ExcludeSourceCodeInfo.
+	ListImageFileStoreEntries(ctx context.Context) ([]ImageFileStoreEntry, error)
+	// VerifyImageFileStoreEntry re-computes the Digest of every file of the entry and
+	// compares it against the Digest recorded for the file.
+	//
+	// Returns an error wrapping ErrCorruptStoreEntry if a file cannot be read or the
+	// Digests do not match.
+	VerifyImageFileStoreEntry(ctx context.Context, imageFileStoreEntry ImageFileStoreEntry) error
+	// DeleteImageFileStoreEntry deletes the entry from the store.
+	//
+	// It is not an error to delete an entry that does not exist.
+	DeleteImageFileStoreEntry(ctx context.Context, imageFileStoreEntry ImageFileStoreEntry) error
+}
+
+// NewImageFileStoreManager returns a new ImageFileStoreManager for the given bucket.
+//
+// The bucket should be the same as the one given to NewImageFileStore.
+func NewImageFileStoreManager(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) ImageFileStoreManager {
+	return newImageFileStore(logger, bucket)
+}
+
+// *** PRIVATE ***
+
+func (p *imageFileStore) ListImageFileStoreEntries(ctx context.Context) ([]ImageFileStoreEntry, error) {
+	dirPathToSize := make(map[string]int64)
+	dirPathToModTime := make(map[string]time.Time)
+	if err := p.bucket.Walk(
+		ctx,
+		"",
+		func(objectInfo storage.ObjectInfo) error {
+			// "compilerVersion/digestType/digestHex/source/..."
+			components := strings.Split(objectInfo.Path(), "/")
+			if len(components) < 5 {
+				return nil
+			}
+			dirPath := normalpath.Join(components[:4]...)
+			size, err := getObjectSize(ctx, p.bucket, objectInfo)
+			if err != nil {
+				return err
+			}
+			dirPathToSize[dirPath] += size
+			if modTime := getObjectModTime(objectInfo); modTime.After(dirPathToModTime[dirPath]) {
+				dirPathToModTime[dirPath] = modTime
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	var imageFileStoreEntries []ImageFileStoreEntry
+	for dirPath, size := range dirPathToSize {
+		imageFileStoreEntry, err := p.getImageFileStoreEntry(ctx, dirPath, size, dirPathToModTime[dirPath])
+		if err != nil {
+			// The directory does not have the layout we expect, this was not written by us.
+			p.logger.DebugContext(
+				ctx,
+				"image file store ignoring unknown directory",
+				slog.String("dirPath", dirPath),
+				slogext.ErrorAttr(err),
+			)
+			continue
+		}
+		imageFileStoreEntries = append(imageFileStoreEntries, imageFileStoreEntry)
+	}
+	sort.Slice(
+		imageFileStoreEntries,
+		func(i int, j int) bool {
+			one := imageFileStoreEntries[i]
+			two := imageFileStoreEntries[j]
+			if oneName, twoName := moduleFullNameString(one.ModuleFullName()), moduleFullNameString(two.ModuleFullName()); oneName != twoName {
+				return oneName < twoName
+			}
+			if oneCommitID, twoCommitID := one.CommitID().String(), two.CommitID().String(); oneCommitID != twoCommitID {
+				return oneCommitID < twoCommitID
+			}
+			if one.CompilerVersion() != two.CompilerVersion() {
+				return one.CompilerVersion() < two.CompilerVersion()
+			}
+			if oneDigest, twoDigest := one.ModuleDigest().String(), two.ModuleDigest().String(); oneDigest != twoDigest {
+				return oneDigest < twoDigest
+			}
+			return !one.ExcludeSourceCodeInfo() && two.ExcludeSourceCodeInfo()
+		},
+	)
+	return imageFileStoreEntries, nil
+}
+
+func (p *imageFileStore) VerifyImageFileStoreEntry(
+	ctx context.Context,
+	imageFileStoreEntry ImageFileStoreEntry,
+) error {
+	filesBucket := storage.MapReadBucket(
+		p.bucket,
+		storage.MapOnPrefix(normalpath.Join(getImageFileStoreEntryDirPathForEntry(imageFileStoreEntry), externalFilesDirName)),
+	)
+	return filesBucket.Walk(
+		ctx,
+		"",
+		func(objectInfo storage.ObjectInfo) error {
+			data, err := storage.ReadPath(ctx, filesBucket, objectInfo.Path())
+			if err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrCorruptStoreEntry, objectInfo.Path(), err)
+			}
+			if _, _, err := readExternalImageFile(data); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrCorruptStoreEntry, objectInfo.Path(), err)
+			}
+			return nil
+		},
+	)
+}
+
+func (p *imageFileStore) DeleteImageFileStoreEntry(
+	ctx context.Context,
+	imageFileStoreEntry ImageFileStoreEntry,
+) error {
+	dirPath := getImageFileStoreEntryDirPathForEntry(imageFileStoreEntry)
+	p.logger.DebugContext(ctx, "image file store delete", slog.String("dirPath", dirPath))
+	return p.bucket.DeleteAll(ctx, dirPath)
+}
+
+func (p *imageFileStore) getImageFileStoreEntry(
+	ctx context.Context,
+	dirPath string,
+	size int64,
+	modTime time.Time,
+) (*imageFileStoreEntry, error) {
+	components := strings.Split(dirPath, "/")
+	moduleDigest, err := bufcas.ParseDigest(components[1] + ":" + components[2])
+	if err != nil {
+		return nil, err
+	}
+	var excludeSourceCodeInfo bool
+	switch components[3] {
+	case sourceCodeInfoDirName:
+	case noSourceCodeInfoDirName:
+		excludeSourceCodeInfo = true
+	default:
+		return nil, fmt.Errorf("unknown source directory %q", components[3])
+	}
+	entryBucket := storage.MapReadBucket(p.bucket, storage.MapOnPrefix(dirPath))
+	// The module information is optional and informational only, so we ignore errors here.
+	var moduleFullName bufmodule.ModuleFullName
+	var commitID uuid.UUID
+	if data, err := storage.ReadPath(ctx, entryBucket, externalEntryFileName); err == nil {
+		var externalEntry externalEntry
+		if err := json.Unmarshal(data, &externalEntry); err == nil {
+			if parsedModuleFullName, err := bufmodule.ParseModuleFullName(externalEntry.Module); err == nil {
+				moduleFullName = parsedModuleFullName
+			}
+			if parsedCommitID, err := uuid.Parse(externalEntry.Commit); err == nil {
+				commitID = parsedCommitID
+			}
+		}
+	}
+	lastAccessTime, err := readLastAccessTime(ctx, entryBucket)
+	if err != nil {
+		lastAccessTime = modTime
+	}
+	return &imageFileStoreEntry{
+		moduleFullName:        moduleFullName,
+		commitID:              commitID,
+		moduleDigest:          moduleDigest,
+		compilerVersion:       components[0],
+		excludeSourceCodeInfo: excludeSourceCodeInfo,
+		size:                  size,
+		lastAccessTime:        lastAccessTime,
+	}, nil
+}
+
+type imageFileStoreEntry struct {
+	moduleFullName        bufmodule.ModuleFullName
+	commitID              uuid.UUID
+	moduleDigest          bufcas.Digest
+	compilerVersion       string
+	excludeSourceCodeInfo bool
+	size                  int64
+	lastAccessTime        time.Time
+}
+
+func (e *imageFileStoreEntry) ModuleFullName() bufmodule.ModuleFullName {
+	return e.moduleFullName
+}
+
+func (e *imageFileStoreEntry) CommitID() uuid.UUID {
+	return e.commitID
+}
+
+func (e *imageFileStoreEntry) ModuleDigest() bufcas.Digest {
+	return e.moduleDigest
+}
+
+func (e *imageFileStoreEntry) CompilerVersion() string {
+	return e.compilerVersion
+}
+
+func (e *imageFileStoreEntry) ExcludeSourceCodeInfo() bool {
+	return e.excludeSourceCodeInfo
+}
+
+func (e *imageFileStoreEntry) Size() int64 {
+	return e.size
+}
+
+func (e *imageFileStoreEntry) LastAccessTime() time.Time {
+	return e.lastAccessTime
+}
+
+func (*imageFileStoreEntry) isImageFileStoreEntry() {}
+
+// getImageFileStoreEntryDirPathForEntry returns the same path as getImageFileStoreEntryDirPath
+// for the keys of the files of the entry.
+func getImageFileStoreEntryDirPathForEntry(imageFileStoreEntry ImageFileStoreEntry) string {
+	return getEntryDirPath(
+		imageFileStoreEntry.CompilerVersion(),
+		imageFileStoreEntry.ModuleDigest(),
+		imageFileStoreEntry.ExcludeSourceCodeInfo(),
+	)
+}
+
+// getObjectSize returns the size of the object.
+//
+// If the object is on local disk, this stats the file, otherwise the object is read.
+func getObjectSize(ctx context.Context, readBucket storage.ReadBucket, objectInfo storage.ObjectInfo) (_ int64, retErr error) {
+	if localPath := objectInfo.LocalPath(); localPath != "" {
+		fileInfo, err := os.Stat(localPath)
+		if err != nil {
+			return 0, err
+		}
+		return fileInfo.Size(), nil
+	}
+	readObjectCloser, err := readBucket.Get(ctx, objectInfo.Path())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, readObjectCloser.Close())
+	}()
+	return io.Copy(io.Discard, readObjectCloser)
+}
+
+// getObjectModTime returns the modification time of the object if the object is on
+// local disk, and the zero time otherwise.
+func getObjectModTime(objectInfo storage.ObjectInfo) time.Time {
+	localPath := objectInfo.LocalPath()
+	if localPath == "" {
+		return time.Time{}
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return fileInfo.ModTime().UTC()
+}
+
+func moduleFullNameString(moduleFullName bufmodule.ModuleFullName) string {
+	if moduleFullName == nil {
+		return ""
+	}
+	return moduleFullName.String()
+}
@@ -0,0 +1,238 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimagestore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestImageFileStoreBasic(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	imageFileStore := NewImageFileStore(slogtestext.NewLogger(t), storagemem.NewReadWriteBucket())
+	imageFileKey := testNewImageFileKey(t, "mod1", false, "a/a.proto")
+	_, err := imageFileStore.GetImageFile(ctx, imageFileKey)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	imageFile := testNewImageFile(t, "a/a.proto", false)
+	require.NoError(t, imageFileStore.PutImageFile(ctx, imageFileKey, imageFile))
+	storedImageFile, err := imageFileStore.GetImageFile(ctx, imageFileKey)
+	require.NoError(t, err)
+	require.Equal(t, "a/a.proto", storedImageFile.Path())
+	require.False(t, storedImageFile.IsSyntaxUnspecified())
+	require.True(t, proto.Equal(imageFile.FileDescriptorProto(), storedImageFile.FileDescriptorProto()))
+
+	// Any difference in the key is a miss.
+	for _, otherImageFileKey := range []bufimage.ImageFileKey{
+		testNewImageFileKey(t, "mod2", false, "a/a.proto"),
+		testNewImageFileKey(t, "mod1", true, "a/a.proto"),
+		testNewImageFileKey(t, "mod1", false, "a/b.proto"),
+		testNewImageFileKeyForCompilerVersion(t, "mod1", "other", false, "a/a.proto"),
+	} {
+		_, err := imageFileStore.GetImageFile(ctx, otherImageFileKey)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	}
+
+	syntaxUnspecifiedImageFileKey := testNewImageFileKey(t, "mod1", false, "b.proto")
+	require.NoError(t, imageFileStore.PutImageFile(ctx, syntaxUnspecifiedImageFileKey, testNewImageFile(t, "b.proto", true)))
+	storedImageFile, err = imageFileStore.GetImageFile(ctx, syntaxUnspecifiedImageFileKey)
+	require.NoError(t, err)
+	require.True(t, storedImageFile.IsSyntaxUnspecified())
+
+	require.Error(t, imageFileStore.PutImageFile(ctx, imageFileKey, testNewImageFile(t, "b.proto", false)))
+}
+
+func TestImageFileStoreCorrupt(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	bucket := storagemem.NewReadWriteBucket()
+	imageFileStore := NewImageFileStore(slogtestext.NewLogger(t), bucket)
+	imageFileKey := testNewImageFileKey(t, "mod1", false, "a/a.proto")
+	require.NoError(t, imageFileStore.PutImageFile(ctx, imageFileKey, testNewImageFile(t, "a/a.proto", false)))
+
+	path := normalpath.Join(getImageFileStoreEntryDirPath(imageFileKey), externalFilesDirName, "a/a.proto")
+	data, err := storage.ReadPath(ctx, bucket, path)
+	require.NoError(t, err)
+	// Flip the last byte of the payload.
+	data[len(data)-1]++
+	require.NoError(t, storage.PutPath(ctx, bucket, path, data))
+
+	_, err = imageFileStore.GetImageFile(ctx, imageFileKey)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	// The corrupt file was evicted.
+	_, err = bucket.Stat(ctx, path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, storage.PutPath(ctx, bucket, path, []byte("v0 shake256:1234 -\n")))
+	_, err = imageFileStore.GetImageFile(ctx, imageFileKey)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = bucket.Stat(ctx, path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestImageFileStoreManagerBasic(t *testing.T) {
+	t.Parallel()
+	testImageFileStoreManager(t, storagemem.NewReadWriteBucket())
+}
+
+func TestImageFileStoreManagerOS(t *testing.T) {
+	t.Parallel()
+	bucket, err := storageos.NewProvider().NewReadWriteBucket(t.TempDir())
+	require.NoError(t, err)
+	testImageFileStoreManager(t, bucket)
+}
+
+func testImageFileStoreManager(t *testing.T, bucket storage.ReadWriteBucket) {
+	ctx := context.Background()
+	logger := slogtestext.NewLogger(t)
+	imageFileStore := NewImageFileStore(logger, bucket)
+	imageFileStoreManager := NewImageFileStoreManager(logger, bucket)
+
+	moduleFullName, err := bufmodule.NewModuleFullName("buf.build", "foo", "mod1")
+	require.NoError(t, err)
+	commitID := uuid.New()
+	namedImageFile, err := bufimage.NewImageFile(
+		testNewFileDescriptorProto("a.proto"),
+		moduleFullName,
+		commitID,
+		"",
+		"",
+		true,
+		false,
+		nil,
+	)
+	require.NoError(t, err)
+	require.NoError(t, imageFileStore.PutImageFile(ctx, testNewImageFileKey(t, "mod1", false, "a.proto"), namedImageFile))
+	require.NoError(t, imageFileStore.PutImageFile(ctx, testNewImageFileKey(t, "mod1", false, "b.proto"), testNewImageFile(t, "b.proto", false)))
+	require.NoError(t, imageFileStore.PutImageFile(ctx, testNewImageFileKey(t, "mod2", true, "c.proto"), testNewImageFile(t, "c.proto", false)))
+	// Not written by the store.
+	require.NoError(t, storage.PutPath(ctx, bucket, "foo/bar/baz/qux/a.proto", []byte("foo")))
+
+	imageFileStoreEntries, err := imageFileStoreManager.ListImageFileStoreEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, imageFileStoreEntries, 2)
+	unnamedEntry := imageFileStoreEntries[0]
+	require.Nil(t, unnamedEntry.ModuleFullName())
+	require.Equal(t, uuid.Nil, unnamedEntry.CommitID())
+	require.True(t, unnamedEntry.ExcludeSourceCodeInfo())
+	require.Equal(t, testNewModuleDigest(t, "mod2").String(), unnamedEntry.ModuleDigest().String())
+	namedEntry := imageFileStoreEntries[1]
+	require.Equal(t, "buf.build/foo/mod1", namedEntry.ModuleFullName().String())
+	require.Equal(t, commitID, namedEntry.CommitID())
+	require.False(t, namedEntry.ExcludeSourceCodeInfo())
+	require.Equal(t, "test", namedEntry.CompilerVersion())
+	require.Equal(t, testNewModuleDigest(t, "mod1").String(), namedEntry.ModuleDigest().String())
+	for _, imageFileStoreEntry := range imageFileStoreEntries {
+		require.Greater(t, imageFileStoreEntry.Size(), int64(0))
+		require.False(t, imageFileStoreEntry.LastAccessTime().IsZero())
+		require.NoError(t, imageFileStoreManager.VerifyImageFileStoreEntry(ctx, imageFileStoreEntry))
+	}
+
+	path := normalpath.Join(getImageFileStoreEntryDirPathForEntry(namedEntry), externalFilesDirName, "b.proto")
+	data, err := storage.ReadPath(ctx, bucket, path)
+	require.NoError(t, err)
+	require.NoError(t, storage.PutPath(ctx, bucket, path, []byte(strings.Replace(string(data), "b.proto", "d.proto", 1))))
+	err = imageFileStoreManager.VerifyImageFileStoreEntry(ctx, namedEntry)
+	require.True(t, errors.Is(err, ErrCorruptStoreEntry), err)
+
+	require.NoError(t, imageFileStoreManager.DeleteImageFileStoreEntry(ctx, namedEntry))
+	require.NoError(t, imageFileStoreManager.DeleteImageFileStoreEntry(ctx, namedEntry))
+	imageFileStoreEntries, err = imageFileStoreManager.ListImageFileStoreEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, imageFileStoreEntries, 1)
+	_, err = imageFileStore.GetImageFile(ctx, testNewImageFileKey(t, "mod1", false, "a.proto"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func testNewImageFileKey(t *testing.T, moduleName string, excludeSourceCodeInfo bool, path string) bufimage.ImageFileKey {
+	return testNewImageFileKeyForCompilerVersion(t, moduleName, "test", excludeSourceCodeInfo, path)
+}
+
+func testNewImageFileKeyForCompilerVersion(
+	t *testing.T,
+	moduleName string,
+	compilerVersion string,
+	excludeSourceCodeInfo bool,
+	path string,
+) bufimage.ImageFileKey {
+	imageFileKey, err := bufimage.NewImageFileKey(
+		testNewModuleDigest(t, moduleName),
+		compilerVersion,
+		excludeSourceCodeInfo,
+		path,
+	)
+	require.NoError(t, err)
+	return imageFileKey
+}
+
+func testNewModuleDigest(t *testing.T, moduleName string) bufcas.Digest {
+	digest, err := bufcas.NewDigestForContent(strings.NewReader(moduleName))
+	require.NoError(t, err)
+	return digest
+}
+
+func testNewImageFile(t *testing.T, path string, isSyntaxUnspecified bool) bufimage.ImageFile {
+	imageFile, err := bufimage.NewImageFile(
+		testNewFileDescriptorProto(path),
+		nil,
+		uuid.Nil,
+		"",
+		"",
+		false,
+		isSyntaxUnspecified,
+		nil,
+	)
+	require.NoError(t, err)
+	return imageFile
+}
+
+func testNewFileDescriptorProto(path string) *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(path),
+		Package: proto.String("foo"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Foo"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("bar"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						JsonName: proto.String("bar"),
+					},
+				},
+			},
+		},
+	}
+}
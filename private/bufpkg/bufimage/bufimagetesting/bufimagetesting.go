@@ -15,12 +15,15 @@
 package bufimagetesting
 
 import (
+	"context"
 	"testing"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
 	imagev1 "github.com/bufbuild/buf/private/gen/proto/go/buf/alpha/image/v1"
 	"github.com/bufbuild/buf/private/pkg/protodescriptor"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -55,6 +58,26 @@ func NewImageFile(
 	return imageFile
 }
 
+// BuildImage builds a new Image for testing from the ModuleDatas.
+//
+// The ModuleDatas are given to bufmoduletesting.NewModuleSet.
+func BuildImage(
+	t testing.TB,
+	moduleDatas []bufmoduletesting.ModuleData,
+	options ...bufimage.BuildImageOption,
+) bufimage.Image {
+	moduleSet, err := bufmoduletesting.NewModuleSet(moduleDatas...)
+	require.NoError(t, err)
+	image, err := bufimage.BuildImage(
+		context.Background(),
+		slogtestext.NewLogger(t),
+		bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+		options...,
+	)
+	require.NoError(t, err)
+	return image
+}
+
 // NewProtoImageFile returns a new *imagev1.ImageFile for testing.
 //
 // This is also a protodescriptor.FileDescriptor.
@@ -45,6 +45,7 @@ func buildImage(
 	moduleReadBucket bufmodule.ModuleReadBucket,
 	excludeSourceCodeInfo bool,
 	noParallelism bool,
+	imageFileStore ImageFileStore,
 ) (Image, error) {
 	defer slogext.DebugProfile(logger)()
 
@@ -64,9 +65,26 @@ func buildImage(
 	}
 	paths := bufmodule.FileInfoPaths(targetFileInfos)
 
+	var imageFileStoreResolver *imageFileStoreResolver
+	if imageFileStore != nil {
+		if compilerVersion := compilerVersion(); compilerVersion != "" {
+			imageFileStoreResolver = newImageFileStoreResolver(
+				ctx,
+				logger,
+				moduleReadBucket,
+				parserAccessorHandler,
+				imageFileStore,
+				compilerVersion,
+				excludeSourceCodeInfo,
+			)
+		} else {
+			logger.DebugContext(ctx, "image file store disabled as the compiler version is unknown")
+		}
+	}
 	buildResult := getBuildResult(
 		ctx,
 		parserAccessorHandler,
+		imageFileStoreResolver,
 		paths,
 		excludeSourceCodeInfo,
 		noParallelism,
@@ -90,12 +108,22 @@ func buildImage(
 	if err != nil {
 		return nil, err
 	}
+	if imageFileStoreResolver != nil {
+		if err := imageFileStoreResolver.ReparseStoredImageFiles(image); err != nil {
+			return nil, err
+		}
+		imageFileStoreResolver.PutMissedImageFiles(image)
+	}
 	return image, nil
 }
 
+// getBuildResult compiles the paths.
+//
+// If imageFileStoreResolver is nil, all files are compiled from source.
 func getBuildResult(
 	ctx context.Context,
 	parserAccessorHandler *parserAccessorHandler,
+	imageFileStoreResolver *imageFileStoreResolver,
 	paths []string,
 	excludeSourceCodeInfo bool,
 	noParallelism bool,
@@ -113,11 +141,15 @@ func getBuildResult(
 	if noParallelism {
 		parallelism = 1
 	}
+	var resolver protocompile.Resolver = &protocompile.SourceResolver{Accessor: parserAccessorHandler.Open}
+	if imageFileStoreResolver != nil {
+		resolver = imageFileStoreResolver
+	}
 	symbols := &linker.Symbols{}
 	compiler := protocompile.Compiler{
 		MaxParallelism: parallelism,
 		SourceInfoMode: sourceInfoMode,
-		Resolver:       &protocompile.SourceResolver{Accessor: parserAccessorHandler.Open},
+		Resolver:       resolver,
 		Symbols:        symbols,
 		Reporter: reporter.NewReporter(
 			func(errorWithPos reporter.ErrorWithPos) error {
@@ -186,6 +218,11 @@ func getBuildResult(
 		maybeAddSyntaxUnspecified(syntaxUnspecifiedFilenames, warningErrorWithPos)
 		maybeAddUnusedImport(filenameToUnusedDependencyFilenames, warningErrorWithPos)
 	}
+	if imageFileStoreResolver != nil {
+		for path := range imageFileStoreResolver.SyntaxUnspecifiedPaths() {
+			syntaxUnspecifiedFilenames[path] = struct{}{}
+		}
+	}
 	return newBuildResult(
 		compiledFiles,
 		symbols,
@@ -417,6 +454,7 @@ func newFailedBuildResult(err error) *buildResult {
 type buildImageOptions struct {
 	excludeSourceCodeInfo bool
 	noParallelism         bool
+	imageFileStore        ImageFileStore
 }
 
 func newBuildImageOptions() *buildImageOptions {
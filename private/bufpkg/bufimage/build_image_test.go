@@ -21,20 +21,25 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/bufbuild/buf/private/buf/buftesting"
 	"github.com/bufbuild/buf/private/bufpkg/bufanalysis"
 	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufimage/bufimagestore"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
 	"github.com/bufbuild/buf/private/bufpkg/bufprotosource"
 	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/prototesting"
 	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
 	"github.com/bufbuild/buf/private/pkg/testingext"
+	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
 )
 
 var buftestingDirPath = filepath.Join(
@@ -364,6 +369,18 @@ func TestModuleTargetFiles(t *testing.T) {
 	testTagetImageFiles(t, []string{"b.proto", "c.proto"}, "buf.build/foo/b", "buf.build/foo/c")
 }
 
+func TestImageFileStore(t *testing.T) {
+	t.Parallel()
+	t.Run("source", func(t *testing.T) {
+		t.Parallel()
+		testImageFileStore(t, false)
+	})
+	t.Run("nosource", func(t *testing.T) {
+		t.Parallel()
+		testImageFileStore(t, true)
+	})
+}
+
 func testCompare(t *testing.T, relDirPath string) {
 	dirPath := filepath.Join("testdata", relDirPath)
 	image, fileAnnotations := testBuild(t, false, dirPath, false)
@@ -459,3 +476,161 @@ func testImageWithExcludedFilePaths(t *testing.T, image bufimage.Image, excludeP
 		}
 	}
 }
+
+func testImageFileStore(t *testing.T, excludeSourceCodeInfo bool) {
+	ctx := context.Background()
+	logger := slogtestext.NewLogger(t)
+	bsrProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/b",
+			PathToData: map[string][]byte{
+				"b.proto": []byte(
+					`syntax = "proto3"; package b; import "c.proto"; message B { option (c.c) = "b"; }`,
+				),
+				"nosyntax.proto": []byte(
+					`package b; message NoSyntax {}`,
+				),
+			},
+		},
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/c",
+			PathToData: map[string][]byte{
+				"c.proto": []byte(
+					`syntax = "proto3"; package c; import "google/protobuf/descriptor.proto"; extend google.protobuf.MessageOptions { string c = 50000; }`,
+				),
+			},
+		},
+	)
+	require.NoError(t, err)
+	moduleRefB, err := bufmodule.NewModuleRef("buf.build", "foo", "b", "")
+	require.NoError(t, err)
+	moduleRefC, err := bufmodule.NewModuleRef("buf.build", "foo", "c", "")
+	require.NoError(t, err)
+	moduleKeys, err := bsrProvider.GetModuleKeysForModuleRefs(
+		ctx,
+		[]bufmodule.ModuleRef{moduleRefB, moduleRefC},
+		bufmodule.DigestTypeB5,
+	)
+	require.NoError(t, err)
+	moduleSetBuilder := bufmodule.NewModuleSetBuilder(ctx, logger, bsrProvider, bsrProvider)
+	for _, moduleKey := range moduleKeys {
+		moduleSetBuilder.AddRemoteModule(moduleKey, false)
+	}
+	localBucket, err := storagemem.NewReadBucket(
+		map[string][]byte{
+			"a.proto": []byte(
+				`syntax = "proto3"; package a; import "b.proto"; import "nosyntax.proto"; message A { b.B b = 1; b.NoSyntax no_syntax = 2; }`,
+			),
+		},
+	)
+	require.NoError(t, err)
+	moduleSetBuilder.AddLocalModule(localBucket, "a", true)
+	moduleSet, err := moduleSetBuilder.Build()
+	require.NoError(t, err)
+
+	imageFileStore := newCountingImageFileStore(bufimagestore.NewImageFileStore(logger, storagemem.NewReadWriteBucket()))
+	buildImage := func() bufimage.Image {
+		options := []bufimage.BuildImageOption{bufimage.WithImageFileStore(imageFileStore)}
+		if excludeSourceCodeInfo {
+			options = append(options, bufimage.WithExcludeSourceCodeInfo())
+		}
+		image, err := bufimage.BuildImage(
+			ctx,
+			logger,
+			bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+			options...,
+		)
+		require.NoError(t, err)
+		return image
+	}
+	expectedImage, err := bufimage.BuildImage(
+		ctx,
+		logger,
+		bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+		func() []bufimage.BuildImageOption {
+			if excludeSourceCodeInfo {
+				return []bufimage.BuildImageOption{bufimage.WithExcludeSourceCodeInfo()}
+			}
+			return nil
+		}()...,
+	)
+	require.NoError(t, err)
+
+	// The files of the remote Modules are put to the store, the local file is not.
+	image := buildImage()
+	testRequireImagesEqual(t, expectedImage, image)
+	assert.Equal(t, 0, imageFileStore.hits)
+	assert.Equal(t, []string{"b.proto", "c.proto", "nosyntax.proto"}, imageFileStore.putPaths())
+
+	// The files of the remote Modules are read from the store.
+	image = buildImage()
+	testRequireImagesEqual(t, expectedImage, image)
+	assert.Equal(t, 3, imageFileStore.hits)
+	assert.Equal(t, []string{"b.proto", "c.proto", "nosyntax.proto"}, imageFileStore.putPaths())
+}
+
+func testRequireImagesEqual(t *testing.T, expected bufimage.Image, actual bufimage.Image) {
+	expectedFiles := expected.Files()
+	actualFiles := actual.Files()
+	require.Equal(t, len(expectedFiles), len(actualFiles))
+	for i, expectedFile := range expectedFiles {
+		actualFile := actualFiles[i]
+		require.Equal(t, expectedFile.Path(), actualFile.Path())
+		require.Equal(t, expectedFile.ExternalPath(), actualFile.ExternalPath(), expectedFile.Path())
+		require.Equal(t, expectedFile.LocalPath(), actualFile.LocalPath(), expectedFile.Path())
+		require.Equal(t, expectedFile.IsImport(), actualFile.IsImport(), expectedFile.Path())
+		require.Equal(t, expectedFile.IsSyntaxUnspecified(), actualFile.IsSyntaxUnspecified(), expectedFile.Path())
+		require.Equal(t, expectedFile.UnusedDependencyIndexes(), actualFile.UnusedDependencyIndexes(), expectedFile.Path())
+		require.Equal(t, expectedFile.CommitID(), actualFile.CommitID(), expectedFile.Path())
+		if expectedFile.ModuleFullName() == nil {
+			require.Nil(t, actualFile.ModuleFullName(), expectedFile.Path())
+		} else {
+			require.Equal(t, expectedFile.ModuleFullName().String(), actualFile.ModuleFullName().String(), expectedFile.Path())
+		}
+		require.Empty(
+			t,
+			cmp.Diff(expectedFile.FileDescriptorProto(), actualFile.FileDescriptorProto(), protocmp.Transform()),
+			expectedFile.Path(),
+		)
+	}
+}
+
+type countingImageFileStore struct {
+	delegate bufimage.ImageFileStore
+	hits     int
+	paths    map[string]struct{}
+	lock     sync.Mutex
+}
+
+func newCountingImageFileStore(delegate bufimage.ImageFileStore) *countingImageFileStore {
+	return &countingImageFileStore{
+		delegate: delegate,
+		paths:    make(map[string]struct{}),
+	}
+}
+
+func (c *countingImageFileStore) GetImageFile(ctx context.Context, imageFileKey bufimage.ImageFileKey) (bufimage.ImageFile, error) {
+	imageFile, err := c.delegate.GetImageFile(ctx, imageFileKey)
+	if err == nil {
+		c.lock.Lock()
+		c.hits++
+		c.lock.Unlock()
+	}
+	return imageFile, err
+}
+
+func (c *countingImageFileStore) PutImageFile(ctx context.Context, imageFileKey bufimage.ImageFileKey, imageFile bufimage.ImageFile) error {
+	c.lock.Lock()
+	c.paths[imageFileKey.Path()] = struct{}{}
+	c.lock.Unlock()
+	return c.delegate.PutImageFile(ctx, imageFileKey, imageFile)
+}
+
+func (c *countingImageFileStore) putPaths() []string {
+	paths := make([]string, 0, len(c.paths))
+	for path := range c.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
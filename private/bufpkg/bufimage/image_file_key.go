@@ -0,0 +1,71 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimage
+
+import (
+	"errors"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+)
+
+type imageFileKey struct {
+	moduleDigest          bufcas.Digest
+	compilerVersion       string
+	excludeSourceCodeInfo bool
+	path                  string
+}
+
+func newImageFileKey(
+	moduleDigest bufcas.Digest,
+	compilerVersion string,
+	excludeSourceCodeInfo bool,
+	path string,
+) (*imageFileKey, error) {
+	if moduleDigest == nil {
+		return nil, errors.New("nil Digest when constructing ImageFileKey")
+	}
+	if compilerVersion == "" {
+		return nil, errors.New("empty compiler version when constructing ImageFileKey")
+	}
+	path, err := normalpath.NormalizeAndValidate(path)
+	if err != nil {
+		return nil, err
+	}
+	return &imageFileKey{
+		moduleDigest:          moduleDigest,
+		compilerVersion:       compilerVersion,
+		excludeSourceCodeInfo: excludeSourceCodeInfo,
+		path:                  path,
+	}, nil
+}
+
+func (i *imageFileKey) ModuleDigest() bufcas.Digest {
+	return i.moduleDigest
+}
+
+func (i *imageFileKey) CompilerVersion() string {
+	return i.compilerVersion
+}
+
+func (i *imageFileKey) ExcludeSourceCodeInfo() bool {
+	return i.excludeSourceCodeInfo
+}
+
+func (i *imageFileKey) Path() string {
+	return i.path
+}
+
+func (*imageFileKey) isImageFileKey() {}
@@ -0,0 +1,273 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"github.com/bufbuild/buf/private/pkg/slogext"
+	"github.com/bufbuild/protocompile"
+)
+
+const (
+	protocompileModulePath = "github.com/bufbuild/protocompile"
+	// imageFileStoreBuildVersion is appended to the version of protocompile to make
+	// up the compiler version of ImageFileKeys.
+	//
+	// Increment this when changing how BuildImage compiles files, so that files compiled
+	// by older versions of buf are not read from an ImageFileStore.
+	imageFileStoreBuildVersion = "1"
+)
+
+// compilerVersion is the compiler version for ImageFileKeys.
+//
+// This is empty if the version of protocompile cannot be determined, in which case an
+// ImageFileStore is not used.
+var compilerVersion = sync.OnceValue(getCompilerVersion)
+
+// imageFileStoreResolver is a protocompile.Resolver that resolves the files of remote
+// Modules from an ImageFileStore, and otherwise delegates to a protocompile.SourceResolver.
+type imageFileStoreResolver struct {
+	ctx                   context.Context
+	logger                *slog.Logger
+	moduleReadBucket      bufmodule.ModuleReadBucket
+	parserAccessorHandler *parserAccessorHandler
+	imageFileStore        ImageFileStore
+	compilerVersion       string
+	excludeSourceCodeInfo bool
+	delegate              protocompile.Resolver
+
+	// moduleOpaqueIDToGetModuleDigest memoizes the Digests of Modules.
+	moduleOpaqueIDToGetModuleDigest map[string]func() (bufcas.Digest, error)
+	// pathToMissedImageFileKey contains the ImageFileKeys of the files that were
+	// not in the store, to be written to the store after a successful build.
+	pathToMissedImageFileKey map[string]ImageFileKey
+	// storedPaths contains the paths of the files that were read from the store.
+	storedPaths map[string]struct{}
+	// syntaxUnspecifiedPaths contains the paths of the files read from the store
+	// that did not specify a syntax. Since these files are not parsed, the compiler
+	// does not warn about them.
+	syntaxUnspecifiedPaths map[string]struct{}
+	lock                   sync.Mutex
+}
+
+func newImageFileStoreResolver(
+	ctx context.Context,
+	logger *slog.Logger,
+	moduleReadBucket bufmodule.ModuleReadBucket,
+	parserAccessorHandler *parserAccessorHandler,
+	imageFileStore ImageFileStore,
+	compilerVersion string,
+	excludeSourceCodeInfo bool,
+) *imageFileStoreResolver {
+	return &imageFileStoreResolver{
+		ctx:                             ctx,
+		logger:                          logger,
+		moduleReadBucket:                moduleReadBucket,
+		parserAccessorHandler:           parserAccessorHandler,
+		imageFileStore:                  imageFileStore,
+		compilerVersion:                 compilerVersion,
+		excludeSourceCodeInfo:           excludeSourceCodeInfo,
+		delegate:                        &protocompile.SourceResolver{Accessor: parserAccessorHandler.Open},
+		moduleOpaqueIDToGetModuleDigest: make(map[string]func() (bufcas.Digest, error)),
+		pathToMissedImageFileKey:        make(map[string]ImageFileKey),
+		storedPaths:                     make(map[string]struct{}),
+		syntaxUnspecifiedPaths:          make(map[string]struct{}),
+	}
+}
+
+func (r *imageFileStoreResolver) FindFileByPath(path string) (protocompile.SearchResult, error) {
+	fileInfo, imageFileKey, err := r.getFileInfoAndImageFileKey(path)
+	if err != nil {
+		return protocompile.SearchResult{}, err
+	}
+	if imageFileKey == nil {
+		return r.delegate.FindFileByPath(path)
+	}
+	imageFile, err := r.imageFileStore.GetImageFile(r.ctx, imageFileKey)
+	if err != nil {
+		// The store is only an optimization, we never fail a build because of it.
+		if !errors.Is(err, fs.ErrNotExist) {
+			r.logger.DebugContext(r.ctx, "image file store get failed", slog.String("path", path), slogext.ErrorAttr(err))
+		}
+		r.lock.Lock()
+		r.pathToMissedImageFileKey[path] = imageFileKey
+		r.lock.Unlock()
+		return r.delegate.FindFileByPath(path)
+	}
+	if err := r.parserAccessorHandler.addPath(
+		path,
+		fileInfo.ExternalPath(),
+		fileInfo.LocalPath(),
+		fileInfo.Module().ModuleFullName(),
+		fileInfo.Module().CommitID(),
+	); err != nil {
+		return protocompile.SearchResult{}, err
+	}
+	r.lock.Lock()
+	r.storedPaths[path] = struct{}{}
+	if imageFile.IsSyntaxUnspecified() {
+		r.syntaxUnspecifiedPaths[path] = struct{}{}
+	}
+	r.lock.Unlock()
+	return protocompile.SearchResult{Proto: imageFile.FileDescriptorProto()}, nil
+}
+
+// SyntaxUnspecifiedPaths returns the paths of the files read from the store that did not
+// specify a syntax.
+//
+// Only call after compilation has completed.
+func (r *imageFileStoreResolver) SyntaxUnspecifiedPaths() map[string]struct{} {
+	return r.syntaxUnspecifiedPaths
+}
+
+// ReparseStoredImageFiles reparses the extensions of the files of the Image that were read
+// from the store.
+//
+// Custom options of stored files are unrecognized fields after they are read from the store,
+// while the compiler populates custom options of files compiled from source as extensions.
+// This makes the files read from the store equivalent to files compiled from source.
+//
+// Only call after compilation has successfully completed.
+func (r *imageFileStoreResolver) ReparseStoredImageFiles(image Image) error {
+	for _, imageFile := range image.Files() {
+		if _, ok := r.storedPaths[imageFile.Path()]; !ok {
+			continue
+		}
+		if err := protoencoding.ReparseExtensions(image.Resolver(), imageFile.FileDescriptorProto().ProtoReflect()); err != nil {
+			return fmt.Errorf("could not reparse %s: %w", imageFile.Path(), err)
+		}
+	}
+	return nil
+}
+
+// PutMissedImageFiles puts the files of the Image that were not in the store to the store.
+//
+// Only call after compilation has successfully completed.
+func (r *imageFileStoreResolver) PutMissedImageFiles(image Image) {
+	for _, imageFile := range image.Files() {
+		imageFileKey, ok := r.pathToMissedImageFileKey[imageFile.Path()]
+		if !ok {
+			continue
+		}
+		if err := r.imageFileStore.PutImageFile(r.ctx, imageFileKey, imageFile); err != nil {
+			// The store is only an optimization, we never fail a build because of it.
+			r.logger.DebugContext(r.ctx, "image file store put failed", slog.String("path", imageFile.Path()), slogext.ErrorAttr(err))
+		}
+	}
+}
+
+// getFileInfoAndImageFileKey returns the FileInfo and ImageFileKey for the path.
+//
+// Returns a nil ImageFileKey if the file should not be read from or written to the store.
+func (r *imageFileStoreResolver) getFileInfoAndImageFileKey(path string) (bufmodule.FileInfo, ImageFileKey, error) {
+	fileInfo, err := r.moduleReadBucket.StatFileInfo(r.ctx, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Well-known types that are not part of any Module.
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	// Target files are compiled from source, as the compiler reports unused imports
+	// for target files only.
+	module := fileInfo.Module()
+	if fileInfo.IsTargetFile() || module.IsLocal() {
+		return nil, nil, nil
+	}
+	moduleDigest, err := r.getModuleDigest(module)
+	if err != nil {
+		return nil, nil, err
+	}
+	imageFileKey, err := newImageFileKey(moduleDigest, r.compilerVersion, r.excludeSourceCodeInfo, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileInfo, imageFileKey, nil
+}
+
+func (r *imageFileStoreResolver) getModuleDigest(module bufmodule.Module) (bufcas.Digest, error) {
+	r.lock.Lock()
+	getModuleDigest, ok := r.moduleOpaqueIDToGetModuleDigest[module.OpaqueID()]
+	if !ok {
+		getModuleDigest = sync.OnceValues(
+			func() (bufcas.Digest, error) {
+				return getModuleDigestForImageFileKey(module)
+			},
+		)
+		r.moduleOpaqueIDToGetModuleDigest[module.OpaqueID()] = getModuleDigest
+	}
+	r.lock.Unlock()
+	return getModuleDigest()
+}
+
+// getModuleDigestForImageFileKey returns a Digest of the b5 Digest of the Module and the
+// b5 Digests of all of its dependencies as resolved for the build.
+//
+// The b5 Digest of a remote Module includes the Digests of the dependencies the Module
+// declared when it was pushed, which may differ from the dependencies the Module is
+// built with.
+func getModuleDigestForImageFileKey(module bufmodule.Module) (bufcas.Digest, error) {
+	moduleDigest, err := module.Digest(bufmodule.DigestTypeB5)
+	if err != nil {
+		return nil, err
+	}
+	moduleDeps, err := module.ModuleDeps()
+	if err != nil {
+		return nil, err
+	}
+	depDigestStrings := make([]string, 0, len(moduleDeps))
+	for _, moduleDep := range moduleDeps {
+		depDigest, err := moduleDep.Digest(bufmodule.DigestTypeB5)
+		if err != nil {
+			return nil, err
+		}
+		depDigestStrings = append(depDigestStrings, depDigest.String())
+	}
+	sort.Strings(depDigestStrings)
+	digestStrings := append([]string{moduleDigest.String()}, depDigestStrings...)
+	return bufcas.NewDigestForContent(strings.NewReader(strings.Join(digestStrings, "\n")))
+}
+
+func getCompilerVersion() string {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range buildInfo.Deps {
+		if dep.Path != protocompileModulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		if dep.Version == "" || dep.Version == "(devel)" {
+			return ""
+		}
+		return fmt.Sprintf("protocompile-%s-%s", dep.Version, imageFileStoreBuildVersion)
+	}
+	return ""
+}
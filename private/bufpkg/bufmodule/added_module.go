@@ -29,7 +29,8 @@ import (
 
 // addedModule represents a Module that was added in moduleSetBuilder.
 //
-// It either represents a local Module, or a remote Module.
+// It either represents a local Module, a remote Module read from a Bucket, or a remote Module
+// read from the ModuleDataProvider.
 //
 // This is needed because when we add a remote Module, we make
 // a call out to the API to get the ModuleData by ModuleKey. However, if we are in
@@ -46,6 +47,7 @@ import (
 // buf.lock-added Modules filtered out, and no BSR call will be made.
 type addedModule struct {
 	localModule              Module
+	remoteBucketModule       Module
 	remoteModuleKey          ModuleKey
 	remoteTargetPaths        []string
 	remoteTargetExcludePaths []string
@@ -62,6 +64,14 @@ func newLocalAddedModule(
 	}
 }
 
+func newRemoteBucketAddedModule(
+	remoteBucketModule Module,
+) *addedModule {
+	return &addedModule{
+		remoteBucketModule: remoteBucketModule,
+	}
+}
+
 func newRemoteAddedModule(
 	remoteModuleKey ModuleKey,
 	remoteTargetPaths []string,
@@ -91,12 +101,15 @@ func (a *addedModule) OpaqueID() string {
 	if a.remoteModuleKey != nil {
 		return a.remoteModuleKey.ModuleFullName().String()
 	}
+	if a.remoteBucketModule != nil {
+		return a.remoteBucketModule.OpaqueID()
+	}
 	return a.localModule.OpaqueID()
 }
 
 // ToModule converts the addedModule to a Module.
 //
-// If the addedModule is a local Module, this is just returned.
+// If the addedModule is a local Module or a remote Module read from a Bucket, this is just returned.
 // If the addedModule is a remote Module, the ModuleDataProvider and CommitProvider are queried to get the Module.
 func (a *addedModule) ToModule(
 	ctx context.Context,
@@ -107,6 +120,9 @@ func (a *addedModule) ToModule(
 	if a.localModule != nil {
 		return a.localModule, nil
 	}
+	if a.remoteBucketModule != nil {
+		return a.remoteBucketModule, nil
+	}
 	// Else, get the remote Module.
 	getModuleData := sync.OnceValues(
 		func() (ModuleData, error) {
@@ -403,8 +419,8 @@ func selectAddedModuleForOpaqueID(
 // selectAddedModuleForOpaqueIDIgnoreTargeting is a child function of selectAddedModuleForOpaqueID
 // that assumes targeting has already been taken into account.
 //
-// This function will just take into account local vs remote, and then resolution between
-// remote Modules.
+// This function will just take into account local vs remote, then remote Modules read from
+// a Bucket, and then resolution between remote Modules.
 func selectAddedModuleForOpaqueIDIgnoreTargeting(
 	ctx context.Context,
 	commitProvider CommitProvider,
@@ -414,7 +430,14 @@ func selectAddedModuleForOpaqueIDIgnoreTargeting(
 	localAddedModules := slicesext.Filter(addedModules, (*addedModule).IsLocal)
 	switch len(localAddedModules) {
 	case 0:
-		// We have no local Modules. We will select a remote Module.
+		// We have no local Modules. If a remote Module was read from a Bucket, it was explicitly
+		// configured, and we prefer it. Otherwise, we will select a remote Module.
+		if remoteBucketAddedModules := slicesext.Filter(
+			addedModules,
+			func(addedModule *addedModule) bool { return addedModule.remoteBucketModule != nil },
+		); len(remoteBucketAddedModules) > 0 {
+			return remoteBucketAddedModules[0], nil
+		}
 		return selectRemoteAddedModuleForOpaqueIDIgnoreTargeting(ctx, commitProvider, addedModules)
 	default:
 		// We have one or more added Modules. We just return the first one - we have
@@ -658,6 +658,119 @@ func TestProtoFileTargetPath(t *testing.T) {
 	)
 }
 
+func TestRemoteModuleForBucket(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	bsrProvider, err := bufmoduletesting.NewOmniProvider(
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/extdep1",
+			PathToData: map[string][]byte{
+				"extdep1.proto": []byte(
+					`syntax = proto3; package extdep1;`,
+				),
+			},
+		},
+		// This module has the same name as the Module read from a bucket below, which is preferred.
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/gitdep",
+			PathToData: map[string][]byte{
+				"other.proto": []byte(
+					`syntax = proto3; package other;`,
+				),
+			},
+		},
+	)
+	require.NoError(t, err)
+	moduleRefExtdep1, err := bufmodule.NewModuleRef("buf.build", "foo", "extdep1", "")
+	require.NoError(t, err)
+	moduleRefGitdep, err := bufmodule.NewModuleRef("buf.build", "foo", "gitdep", "")
+	require.NoError(t, err)
+	moduleKeys, err := bsrProvider.GetModuleKeysForModuleRefs(
+		ctx,
+		[]bufmodule.ModuleRef{
+			moduleRefExtdep1,
+			moduleRefGitdep,
+		},
+		bufmodule.DigestTypeB5,
+	)
+	require.NoError(t, err)
+
+	moduleSetBuilder := bufmodule.NewModuleSetBuilder(ctx, slogtestext.NewLogger(t), bsrProvider, bsrProvider)
+	for _, moduleKey := range moduleKeys {
+		moduleSetBuilder.AddRemoteModule(moduleKey, false)
+	}
+	gitdepModuleFullName, err := bufmodule.NewModuleFullName("buf.build", "foo", "gitdep")
+	require.NoError(t, err)
+	moduleSetBuilder.AddRemoteModuleForBucket(
+		testNewBucketForPathToData(
+			t,
+			map[string][]byte{
+				"gitdep.proto": []byte(
+					`syntax = proto3; package gitdep; import "extdep1.proto";`,
+				),
+			},
+		),
+		gitdepModuleFullName,
+		"git dep https://github.com/foo/gitdep.git",
+	)
+	module1ModuleFullName, err := bufmodule.NewModuleFullName("buf.build", "bar", "module1")
+	require.NoError(t, err)
+	moduleSetBuilder.AddLocalModule(
+		testNewBucketForPathToData(
+			t,
+			map[string][]byte{
+				"module1.proto": []byte(
+					`syntax = proto3; package module1; import "gitdep.proto";`,
+				),
+			},
+		),
+		"path/to/module1",
+		true,
+		bufmodule.LocalModuleWithModuleFullName(module1ModuleFullName),
+	)
+	moduleSet, err := moduleSetBuilder.Build()
+	require.NoError(t, err)
+
+	gitdepModule := moduleSet.GetModuleForOpaqueID("buf.build/foo/gitdep")
+	require.NotNil(t, gitdepModule)
+	require.False(t, gitdepModule.IsLocal())
+	require.False(t, gitdepModule.IsTarget())
+	require.True(t, bufmodule.IsRemoteModuleForBucket(gitdepModule))
+	require.Equal(t, "git dep https://github.com/foo/gitdep.git", gitdepModule.Description())
+	testFilePaths(t, gitdepModule, "gitdep.proto")
+	module1 := moduleSet.GetModuleForOpaqueID("buf.build/bar/module1")
+	require.NotNil(t, module1)
+	require.False(t, bufmodule.IsRemoteModuleForBucket(module1))
+	require.Equal(
+		t,
+		map[string]bool{
+			"buf.build/foo/gitdep":  true,
+			"buf.build/foo/extdep1": false,
+		},
+		testGetDepOpaqueIDToDirect(t, module1),
+	)
+
+	// The Module read from a bucket is never pushed.
+	contentModules, err := bufmodule.ModuleSetTargetLocalModulesAndTransitiveLocalDeps(moduleSet)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{"buf.build/bar/module1"},
+		slicesext.Map(contentModules, bufmodule.Module.OpaqueID),
+	)
+	// The Module read from a bucket is not a RemoteDep, but its dependencies are.
+	remoteDeps, err := bufmodule.RemoteDepsForModuleSet(moduleSet)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{"buf.build/foo/extdep1"},
+		slicesext.Map(remoteDeps, func(remoteDep bufmodule.RemoteDep) string { return remoteDep.OpaqueID() }),
+	)
+	require.False(t, remoteDeps[0].IsDirect())
+}
+
 func testNewBucketForPathToData(t *testing.T, pathToData map[string][]byte) storage.ReadBucket {
 	bucket, err := storagemem.NewReadBucket(pathToData)
 	require.NoError(t, err)
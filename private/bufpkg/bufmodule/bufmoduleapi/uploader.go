@@ -124,6 +124,11 @@ func (a *uploader) Upload(
 		if err != nil {
 			return false, err
 		}
+		for _, dep := range deps {
+			if bufmodule.IsRemoteModuleForBucket(dep) {
+				return false, fmt.Errorf("module %q cannot be pushed because it depends on %s, which is not in a registry", moduleName.String(), dep.Description())
+			}
+		}
 		if allDepModuleDescriptions := slicesext.Reduce(deps, func(allDepModuleDescriptions []string, dep bufmodule.ModuleDep) []string {
 			if moduleName := dep.ModuleFullName(); moduleName == nil {
 				return append(allDepModuleDescriptions, dep.Description())
@@ -16,6 +16,7 @@ package bufmodulecache
 
 import (
 	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -224,6 +225,56 @@ func TestModuleDataProviderBasic(t *testing.T) {
 	)
 }
 
+func TestVendorModuleDataProvider(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bsrProvider, moduleKeys := testGetBSRProviderAndModuleKeys(t, ctx)
+	logger := slogtestext.NewLogger(t)
+
+	bucket := storagemem.NewReadWriteBucket()
+	moduleDatas, err := bsrProvider.GetModuleDatasForModuleKeys(ctx, moduleKeys[:2])
+	require.NoError(t, err)
+	require.NoError(
+		t,
+		bufmodulestore.NewModuleDataStore(
+			logger,
+			bucket,
+			filelock.NewNopLocker(),
+		).PutModuleDatas(ctx, moduleDatas),
+	)
+	vendorProvider := NewVendorModuleDataProvider(
+		logger,
+		bufmodulestore.NewReadOnlyModuleDataStore(logger, bucket),
+	)
+
+	moduleDatas, err = vendorProvider.GetModuleDatasForModuleKeys(ctx, moduleKeys[:2])
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{
+			"buf.build/foo/mod1",
+			"buf.build/foo/mod3",
+		},
+		slicesext.Map(
+			moduleDatas,
+			func(moduleData bufmodule.ModuleData) string {
+				return moduleData.ModuleKey().ModuleFullName().String()
+			},
+		),
+	)
+	for _, moduleData := range moduleDatas {
+		// Verifies the digest of the vendored files.
+		_, err := moduleData.Bucket()
+		require.NoError(t, err)
+	}
+
+	_, err = vendorProvider.GetModuleDatasForModuleKeys(ctx, moduleKeys)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, "buf.build/foo/mod2")
+	require.ErrorContains(t, err, "buf dep vendor")
+}
+
 func TestConcurrentCacheReadWrite(t *testing.T) {
 	t.Parallel()
 
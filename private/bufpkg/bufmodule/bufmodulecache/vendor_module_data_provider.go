@@ -0,0 +1,61 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmodulecache
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulestore"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+)
+
+// NewVendorModuleDataProvider returns a new ModuleDataProvider that only reads from
+// the given ModuleDataStore, typically backed by a vendor directory.
+//
+// If any ModuleKey is not found in the store, an error is returned instead of falling
+// back to a remote ModuleDataProvider.
+func NewVendorModuleDataProvider(
+	logger *slog.Logger,
+	store bufmodulestore.ModuleDataStore,
+) bufmodule.ModuleDataProvider {
+	return newModuleDataProvider(logger, vendorMissModuleDataProvider{}, store)
+}
+
+// *** PRIVATE ***
+
+// vendorMissModuleDataProvider is the delegate for a vendor ModuleDataProvider.
+//
+// It is only called for ModuleKeys that were not found in the store, and always
+// errors if there are any.
+type vendorMissModuleDataProvider struct{}
+
+func (vendorMissModuleDataProvider) GetModuleDatasForModuleKeys(
+	_ context.Context,
+	moduleKeys []bufmodule.ModuleKey,
+) ([]bufmodule.ModuleData, error) {
+	if len(moduleKeys) == 0 {
+		return nil, nil
+	}
+	return nil, fmt.Errorf(
+		"%w: dependencies not found in vendor directory, run \"buf dep vendor\" to update it: %s",
+		fs.ErrNotExist,
+		strings.Join(slicesext.Map(moduleKeys, bufmodule.ModuleKey.String), ", "),
+	)
+}
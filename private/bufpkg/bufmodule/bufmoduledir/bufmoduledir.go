@@ -0,0 +1,62 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufmoduledir implements a registry of modules backed by a plain directory.
+//
+// A registry directory can be used in place of the BSR to resolve dependencies, for
+// example for air-gapped environments or hermetic tests. Modules are published into
+// the directory with an Uploader, and read back with the ModuleKeyProvider,
+// ModuleDataProvider, CommitProvider, and GraphProvider.
+//
+// The layout of a registry directory is:
+//
+//	modules/<registry>/<owner>/<name>/module.yaml        The module and its default label.
+//	modules/<registry>/<owner>/<name>/labels/<label>     The dashless commit ID a label points to.
+//	commits/<commit>.yaml                                The module, create time, digests, and
+//	                                                     direct dependencies of a commit.
+//	data/                                                The files of each commit, in the layout
+//	                                                     of a bufmodulestore.ModuleDataStore.
+//
+// The files of each commit are stored for both b4 and b5 digests, so that both v1 and v2
+// buf.lock files can be resolved against a registry directory.
+package bufmoduledir
+
+import (
+	"log/slog"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/storage"
+)
+
+// Registry is a registry of modules backed by a directory.
+type Registry interface {
+	bufmodule.ModuleKeyProvider
+	bufmodule.ModuleDataProvider
+	bufmodule.CommitProvider
+	bufmodule.GraphProvider
+	bufmodule.Uploader
+
+	isRegistry()
+}
+
+// NewRegistry returns a new Registry for the given bucket.
+//
+// It is assumed that the Registry has complete control of the bucket. No locking is
+// performed, so concurrent uploads to the same bucket are not supported.
+func NewRegistry(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) Registry {
+	return newRegistry(logger, bucket)
+}
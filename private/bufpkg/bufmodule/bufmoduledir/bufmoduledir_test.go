@@ -0,0 +1,231 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmoduledir
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadAndResolve(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	registry := NewRegistry(slogtestext.NewLogger(t), storagemem.NewReadWriteBucket())
+	moduleSet := testNewModuleSet(t)
+
+	_, err := registry.Upload(ctx, moduleSet)
+	require.Error(t, err)
+
+	commits, err := registry.Upload(
+		ctx,
+		moduleSet,
+		bufmodule.UploadWithCreateIfNotExist(bufmodule.ModuleVisibilityPrivate, ""),
+	)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	moduleFullNameStringToCommitID := make(map[string]string)
+	for _, commit := range commits {
+		moduleFullNameStringToCommitID[commit.ModuleKey().ModuleFullName().String()] = commit.ModuleKey().CommitID().String()
+	}
+	require.Len(t, moduleFullNameStringToCommitID, 2)
+
+	moduleRef, err := bufmodule.ParseModuleRef("buf.build/foo/a")
+	require.NoError(t, err)
+	moduleKeys, err := registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB5)
+	require.NoError(t, err)
+	require.Len(t, moduleKeys, 1)
+	require.Equal(t, moduleFullNameStringToCommitID["buf.build/foo/a"], moduleKeys[0].CommitID().String())
+
+	moduleRef, err = bufmodule.ParseModuleRef("buf.build/foo/a:main")
+	require.NoError(t, err)
+	labelModuleKeys, err := registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB4)
+	require.NoError(t, err)
+	require.Equal(t, moduleKeys[0].CommitID(), labelModuleKeys[0].CommitID())
+
+	moduleRef, err = bufmodule.ParseModuleRef("buf.build/foo/a:" + moduleKeys[0].CommitID().String())
+	require.NoError(t, err)
+	commitModuleKeys, err := registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB5)
+	require.NoError(t, err)
+	require.Equal(t, moduleKeys[0].CommitID(), commitModuleKeys[0].CommitID())
+
+	moduleRef, err = bufmodule.ParseModuleRef("buf.build/foo/a:unknown")
+	require.NoError(t, err)
+	_, err = registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB5)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	moduleDatas, err := registry.GetModuleDatasForModuleKeys(ctx, moduleKeys)
+	require.NoError(t, err)
+	require.Len(t, moduleDatas, 1)
+	bucket, err := moduleDatas[0].Bucket()
+	require.NoError(t, err)
+	data, err := bucket.Get(ctx, "a.proto")
+	require.NoError(t, err)
+	require.NoError(t, data.Close())
+	declaredDepModuleKeys, err := moduleDatas[0].DeclaredDepModuleKeys()
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{"buf.build/foo/b"},
+		slicesext.Map(
+			declaredDepModuleKeys,
+			func(moduleKey bufmodule.ModuleKey) string {
+				return moduleKey.ModuleFullName().String()
+			},
+		),
+	)
+
+	graph, err := registry.GetGraphForModuleKeys(ctx, moduleKeys)
+	require.NoError(t, err)
+	var graphModuleFullNameStrings []string
+	require.NoError(
+		t,
+		graph.WalkNodes(
+			func(moduleKey bufmodule.ModuleKey, _ []bufmodule.ModuleKey, _ []bufmodule.ModuleKey) error {
+				graphModuleFullNameStrings = append(graphModuleFullNameStrings, moduleKey.ModuleFullName().String())
+				return nil
+			},
+		),
+	)
+	require.ElementsMatch(t, []string{"buf.build/foo/a", "buf.build/foo/b"}, graphModuleFullNameStrings)
+
+	registryCommits, err := registry.GetCommitsForModuleKeys(ctx, moduleKeys)
+	require.NoError(t, err)
+	require.Len(t, registryCommits, 1)
+	_, err = registryCommits[0].CreateTime()
+	require.NoError(t, err)
+
+	// Uploading the same content again reuses the existing commits.
+	commits, err = registry.Upload(ctx, moduleSet)
+	require.NoError(t, err)
+	for _, commit := range commits {
+		require.Equal(
+			t,
+			moduleFullNameStringToCommitID[commit.ModuleKey().ModuleFullName().String()],
+			commit.ModuleKey().CommitID().String(),
+		)
+	}
+}
+
+func TestUploadInvalidLabel(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bucket := storagemem.NewReadWriteBucket()
+	registry := NewRegistry(slogtestext.NewLogger(t), bucket)
+	moduleSet := testNewModuleSet(t)
+
+	for _, label := range []string{"", ".", ".."} {
+		_, err := registry.Upload(
+			ctx,
+			moduleSet,
+			bufmodule.UploadWithCreateIfNotExist(bufmodule.ModuleVisibilityPrivate, ""),
+			bufmodule.UploadWithLabels(label),
+		)
+		require.ErrorContains(t, err, "invalid label")
+	}
+	// Slashes are escaped, so the label stays within the labels directory.
+	_, err := registry.Upload(
+		ctx,
+		moduleSet,
+		bufmodule.UploadWithCreateIfNotExist(bufmodule.ModuleVisibilityPrivate, ""),
+		bufmodule.UploadWithLabels("../main"),
+	)
+	require.NoError(t, err)
+	moduleRef, err := bufmodule.ParseModuleRef("buf.build/foo/a:../main")
+	require.NoError(t, err)
+	_, err = registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB5)
+	require.NoError(t, err)
+	_, err = bucket.Stat(ctx, "modules/buf.build/foo/a/labels/..%2Fmain")
+	require.NoError(t, err)
+}
+
+func TestUploadWithRemoteModuleForBucket(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	registry := NewRegistry(slogtestext.NewLogger(t), storagemem.NewReadWriteBucket())
+	gitdepModuleFullName, err := bufmodule.ParseModuleFullName("buf.build/foo/gitdep")
+	require.NoError(t, err)
+	gitdepBucket, err := storagemem.NewReadBucket(
+		map[string][]byte{
+			"gitdep.proto": []byte(`syntax = "proto3"; package gitdep;`),
+		},
+	)
+	require.NoError(t, err)
+	aModuleFullName, err := bufmodule.ParseModuleFullName("buf.build/foo/a")
+	require.NoError(t, err)
+	aBucket, err := storagemem.NewReadBucket(
+		map[string][]byte{
+			"a.proto": []byte(`syntax = "proto3"; package a; import "gitdep.proto";`),
+		},
+	)
+	require.NoError(t, err)
+	moduleSet, err := bufmodule.NewModuleSetBuilder(
+		ctx,
+		slogtestext.NewLogger(t),
+		bufmodule.NopModuleDataProvider,
+		bufmodule.NopCommitProvider,
+	).AddRemoteModuleForBucket(
+		gitdepBucket,
+		gitdepModuleFullName,
+		"git dep https://github.com/foo/gitdep.git",
+	).AddLocalModule(
+		aBucket,
+		"a",
+		true,
+		bufmodule.LocalModuleWithModuleFullName(aModuleFullName),
+	).Build()
+	require.NoError(t, err)
+
+	_, err = registry.Upload(
+		ctx,
+		moduleSet,
+		bufmodule.UploadWithCreateIfNotExist(bufmodule.ModuleVisibilityPrivate, ""),
+	)
+	require.ErrorContains(t, err, `module "buf.build/foo/a" cannot be pushed because it depends on git dep https://github.com/foo/gitdep.git, which is not in a registry`)
+	// The git dep was not uploaded.
+	moduleRef, err := bufmodule.ParseModuleRef("buf.build/foo/gitdep")
+	require.NoError(t, err)
+	_, err = registry.GetModuleKeysForModuleRefs(ctx, []bufmodule.ModuleRef{moduleRef}, bufmodule.DigestTypeB5)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func testNewModuleSet(t *testing.T) bufmodule.ModuleSet {
+	moduleSet, err := bufmoduletesting.NewModuleSet(
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/a",
+			PathToData: map[string][]byte{
+				"a.proto": []byte(`syntax = "proto3"; package a; import "b.proto";`),
+			},
+		},
+		bufmoduletesting.ModuleData{
+			Name: "buf.build/foo/b",
+			PathToData: map[string][]byte{
+				"b.proto": []byte(`syntax = "proto3"; package b;`),
+			},
+		},
+	)
+	require.NoError(t, err)
+	return moduleSet
+}
@@ -0,0 +1,741 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmoduledir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmodulestore"
+	"github.com/bufbuild/buf/private/pkg/dag"
+	"github.com/bufbuild/buf/private/pkg/encoding"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/google/uuid"
+)
+
+const (
+	externalVersion        = "v1"
+	modulesDirPath         = "modules"
+	commitsDirPath         = "commits"
+	dataDirPath            = "data"
+	moduleFileName         = "module.yaml"
+	labelsDirName          = "labels"
+	commitFileExt          = ".yaml"
+	defaultDefaultLabel    = "main"
+	uploadDigestTypeString = "b5"
+)
+
+type registry struct {
+	logger          *slog.Logger
+	bucket          storage.ReadWriteBucket
+	moduleDataStore bufmodulestore.ModuleDataStore
+}
+
+func newRegistry(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) *registry {
+	return &registry{
+		logger: logger,
+		bucket: bucket,
+		moduleDataStore: bufmodulestore.NewModuleDataStore(
+			logger,
+			storage.MapReadWriteBucket(bucket, storage.MapOnPrefix(dataDirPath)),
+			filelock.NewNopLocker(),
+		),
+	}
+}
+
+func (r *registry) GetModuleKeysForModuleRefs(
+	ctx context.Context,
+	moduleRefs []bufmodule.ModuleRef,
+	digestType bufmodule.DigestType,
+) ([]bufmodule.ModuleKey, error) {
+	return slicesext.MapError(
+		moduleRefs,
+		func(moduleRef bufmodule.ModuleRef) (bufmodule.ModuleKey, error) {
+			commitID, err := r.getCommitIDForModuleRef(ctx, moduleRef)
+			if err != nil {
+				return nil, err
+			}
+			moduleKey, _, err := r.getModuleKeyForCommitID(ctx, commitID, digestType)
+			return moduleKey, err
+		},
+	)
+}
+
+func (r *registry) GetModuleDatasForModuleKeys(
+	ctx context.Context,
+	moduleKeys []bufmodule.ModuleKey,
+) ([]bufmodule.ModuleData, error) {
+	if len(moduleKeys) == 0 {
+		return nil, nil
+	}
+	if _, err := bufmodule.UniqueDigestTypeForModuleKeys(moduleKeys); err != nil {
+		return nil, err
+	}
+	if _, err := bufmodule.ModuleFullNameStringToUniqueValue(moduleKeys); err != nil {
+		return nil, err
+	}
+	moduleDatas, notFoundModuleKeys, err := r.moduleDataStore.GetModuleDatasForModuleKeys(ctx, moduleKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(notFoundModuleKeys) > 0 {
+		return nil, &fs.PathError{Op: "read", Path: notFoundModuleKeys[0].String(), Err: fs.ErrNotExist}
+	}
+	return moduleDatas, nil
+}
+
+func (r *registry) GetCommitsForModuleKeys(
+	ctx context.Context,
+	moduleKeys []bufmodule.ModuleKey,
+) ([]bufmodule.Commit, error) {
+	if len(moduleKeys) == 0 {
+		return nil, nil
+	}
+	digestType, err := bufmodule.UniqueDigestTypeForModuleKeys(moduleKeys)
+	if err != nil {
+		return nil, err
+	}
+	return slicesext.MapError(
+		moduleKeys,
+		func(moduleKey bufmodule.ModuleKey) (bufmodule.Commit, error) {
+			storedModuleKey, externalCommit, err := r.getModuleKeyForCommitID(ctx, moduleKey.CommitID(), digestType)
+			if err != nil {
+				return nil, err
+			}
+			if storedModuleKey.ModuleFullName().String() != moduleKey.ModuleFullName().String() {
+				return nil, &fs.PathError{Op: "read", Path: moduleKey.String(), Err: fs.ErrNotExist}
+			}
+			expectedDigest, err := storedModuleKey.Digest()
+			if err != nil {
+				return nil, err
+			}
+			return bufmodule.NewCommit(
+				moduleKey,
+				func() (time.Time, error) {
+					return externalCommit.CreateTime, nil
+				},
+				bufmodule.CommitWithExpectedDigest(expectedDigest),
+			), nil
+		},
+	)
+}
+
+func (r *registry) GetCommitsForCommitKeys(
+	ctx context.Context,
+	commitKeys []bufmodule.CommitKey,
+) ([]bufmodule.Commit, error) {
+	if len(commitKeys) == 0 {
+		return nil, nil
+	}
+	if _, err := bufmodule.UniqueDigestTypeForCommitKeys(commitKeys); err != nil {
+		return nil, err
+	}
+	return slicesext.MapError(
+		commitKeys,
+		func(commitKey bufmodule.CommitKey) (bufmodule.Commit, error) {
+			moduleKey, externalCommit, err := r.getModuleKeyForCommitID(ctx, commitKey.CommitID(), commitKey.DigestType())
+			if err != nil {
+				return nil, err
+			}
+			if moduleKey.ModuleFullName().Registry() != commitKey.Registry() {
+				return nil, &fs.PathError{Op: "read", Path: uuidutil.ToDashless(commitKey.CommitID()), Err: fs.ErrNotExist}
+			}
+			return bufmodule.NewCommit(
+				moduleKey,
+				func() (time.Time, error) {
+					return externalCommit.CreateTime, nil
+				},
+			), nil
+		},
+	)
+}
+
+func (r *registry) GetGraphForModuleKeys(
+	ctx context.Context,
+	moduleKeys []bufmodule.ModuleKey,
+) (*dag.Graph[bufmodule.RegistryCommitID, bufmodule.ModuleKey], error) {
+	graph := dag.NewGraph[bufmodule.RegistryCommitID, bufmodule.ModuleKey](bufmodule.ModuleKeyToRegistryCommitID)
+	if len(moduleKeys) == 0 {
+		return graph, nil
+	}
+	digestType, err := bufmodule.UniqueDigestTypeForModuleKeys(moduleKeys)
+	if err != nil {
+		return nil, err
+	}
+	visitedCommitIDs := make(map[uuid.UUID]struct{})
+	for _, moduleKey := range moduleKeys {
+		if err := r.addModuleKeyToGraphRec(ctx, graph, moduleKey, digestType, visitedCommitIDs); err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+func (r *registry) Upload(
+	ctx context.Context,
+	moduleSet bufmodule.ModuleSet,
+	options ...bufmodule.UploadOption,
+) ([]bufmodule.Commit, error) {
+	uploadOptions, err := bufmodule.NewUploadOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	contentModules, err := bufmodule.ModuleSetTargetLocalModulesAndTransitiveLocalDeps(moduleSet)
+	if err != nil {
+		return nil, err
+	}
+	contentModules, err = slicesext.FilterError(
+		contentModules,
+		func(module bufmodule.Module) (bool, error) {
+			if module.ModuleFullName() == nil {
+				if uploadOptions.ExcludeUnnamed() {
+					r.logger.Warn("Excluding unnamed module", slog.String("module", module.Description()))
+					return false, nil
+				}
+				return false, fmt.Errorf("a name must be specified in buf.yaml to push module: %s", module.Description())
+			}
+			return true, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	upload := &registryUpload{
+		registry:         r,
+		uploadOptions:    uploadOptions,
+		createTime:       time.Now().UTC(),
+		opaqueIDToCommit: make(map[string]bufmodule.Commit),
+	}
+	return slicesext.MapError(
+		contentModules,
+		func(module bufmodule.Module) (bufmodule.Commit, error) {
+			return upload.uploadModule(ctx, module)
+		},
+	)
+}
+
+func (r *registry) getCommitIDForModuleRef(
+	ctx context.Context,
+	moduleRef bufmodule.ModuleRef,
+) (uuid.UUID, error) {
+	moduleFullName := moduleRef.ModuleFullName()
+	externalModule, err := r.readModule(ctx, moduleFullName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return uuid.Nil, &fs.PathError{Op: "read", Path: moduleRef.String(), Err: fs.ErrNotExist}
+		}
+		return uuid.Nil, err
+	}
+	ref := moduleRef.Ref()
+	if ref == "" {
+		ref = externalModule.DefaultLabel
+	}
+	commitID, err := r.readLabel(ctx, moduleFullName, ref)
+	if err == nil {
+		return commitID, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return uuid.Nil, err
+	}
+	// The ref was not a label, it may be a commit ID.
+	commitID, err = uuidutil.FromDashless(ref)
+	if err != nil {
+		commitID, err = uuidutil.FromString(ref)
+	}
+	if err == nil {
+		externalCommit, err := r.readCommit(ctx, commitID)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return uuid.Nil, err
+		}
+		if err == nil && externalCommit.Module == moduleFullName.String() {
+			return commitID, nil
+		}
+	}
+	return uuid.Nil, &fs.PathError{Op: "read", Path: moduleRef.String(), Err: fs.ErrNotExist}
+}
+
+func (r *registry) getModuleKeyForCommitID(
+	ctx context.Context,
+	commitID uuid.UUID,
+	digestType bufmodule.DigestType,
+) (bufmodule.ModuleKey, *externalCommit, error) {
+	externalCommit, err := r.readCommit(ctx, commitID)
+	if err != nil {
+		return nil, nil, err
+	}
+	moduleFullName, err := bufmodule.ParseModuleFullName(externalCommit.Module)
+	if err != nil {
+		return nil, nil, err
+	}
+	var digest bufmodule.Digest
+	for _, digestString := range externalCommit.Digests {
+		candidateDigest, err := bufmodule.ParseDigest(digestString)
+		if err != nil {
+			return nil, nil, err
+		}
+		if candidateDigest.Type() == digestType {
+			digest = candidateDigest
+			break
+		}
+	}
+	if digest == nil {
+		return nil, nil, fmt.Errorf("commit %s has no %v digest", uuidutil.ToDashless(commitID), digestType)
+	}
+	moduleKey, err := bufmodule.NewModuleKey(
+		moduleFullName,
+		commitID,
+		func() (bufmodule.Digest, error) {
+			return digest, nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return moduleKey, externalCommit, nil
+}
+
+func (r *registry) addModuleKeyToGraphRec(
+	ctx context.Context,
+	graph *dag.Graph[bufmodule.RegistryCommitID, bufmodule.ModuleKey],
+	moduleKey bufmodule.ModuleKey,
+	digestType bufmodule.DigestType,
+	visitedCommitIDs map[uuid.UUID]struct{},
+) error {
+	graph.AddNode(moduleKey)
+	if _, ok := visitedCommitIDs[moduleKey.CommitID()]; ok {
+		return nil
+	}
+	visitedCommitIDs[moduleKey.CommitID()] = struct{}{}
+	externalCommit, err := r.readCommit(ctx, moduleKey.CommitID())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &fs.PathError{Op: "read", Path: moduleKey.String(), Err: fs.ErrNotExist}
+		}
+		return err
+	}
+	for _, dep := range externalCommit.Deps {
+		depCommitID, err := uuidutil.FromDashless(dep)
+		if err != nil {
+			return err
+		}
+		depModuleKey, _, err := r.getModuleKeyForCommitID(ctx, depCommitID, digestType)
+		if err != nil {
+			return err
+		}
+		graph.AddEdge(moduleKey, depModuleKey)
+		if err := r.addModuleKeyToGraphRec(ctx, graph, depModuleKey, digestType, visitedCommitIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *registry) readModule(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+) (*externalModule, error) {
+	var externalModule externalModule
+	if err := r.readYAML(ctx, normalpath.Join(getModuleDirPath(moduleFullName), moduleFileName), &externalModule); err != nil {
+		return nil, err
+	}
+	if externalModule.Version != externalVersion {
+		return nil, fmt.Errorf("unknown version %q for module %s", externalModule.Version, moduleFullName.String())
+	}
+	return &externalModule, nil
+}
+
+func (r *registry) writeModule(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+	externalModule *externalModule,
+) error {
+	return r.writeYAML(ctx, normalpath.Join(getModuleDirPath(moduleFullName), moduleFileName), externalModule)
+}
+
+func (r *registry) readLabel(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+	label string,
+) (uuid.UUID, error) {
+	labelPath, err := getLabelPath(moduleFullName, label)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	data, err := storage.ReadPath(ctx, r.bucket, labelPath)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuidutil.FromDashless(strings.TrimSpace(string(data)))
+}
+
+func (r *registry) writeLabel(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+	label string,
+	commitID uuid.UUID,
+) error {
+	labelPath, err := getLabelPath(moduleFullName, label)
+	if err != nil {
+		return err
+	}
+	return storage.PutPath(ctx, r.bucket, labelPath, []byte(uuidutil.ToDashless(commitID)+"\n"))
+}
+
+func (r *registry) readCommit(
+	ctx context.Context,
+	commitID uuid.UUID,
+) (*externalCommit, error) {
+	var externalCommit externalCommit
+	if err := r.readYAML(ctx, getCommitPath(commitID), &externalCommit); err != nil {
+		return nil, err
+	}
+	if externalCommit.Version != externalVersion {
+		return nil, fmt.Errorf("unknown version %q for commit %s", externalCommit.Version, uuidutil.ToDashless(commitID))
+	}
+	return &externalCommit, nil
+}
+
+func (r *registry) writeCommit(
+	ctx context.Context,
+	commitID uuid.UUID,
+	externalCommit *externalCommit,
+) error {
+	return r.writeYAML(ctx, getCommitPath(commitID), externalCommit)
+}
+
+func (r *registry) readYAML(ctx context.Context, path string, v any) error {
+	data, err := storage.ReadPath(ctx, r.bucket, path)
+	if err != nil {
+		return err
+	}
+	return encoding.UnmarshalYAMLNonStrict(data, v)
+}
+
+func (r *registry) writeYAML(ctx context.Context, path string, v any) error {
+	data, err := encoding.MarshalYAML(v)
+	if err != nil {
+		return err
+	}
+	return storage.PutPath(ctx, r.bucket, path, data, storage.PutWithAtomic())
+}
+
+func (*registry) isRegistry() {}
+
+// registryUpload is the state of a single call to Upload.
+type registryUpload struct {
+	registry         *registry
+	uploadOptions    bufmodule.UploadOptions
+	createTime       time.Time
+	opaqueIDToCommit map[string]bufmodule.Commit
+}
+
+// uploadModule uploads the local Module, after first uploading any of its local dependencies.
+//
+// If a label of the Module already points at a commit with the same content, no new commit is
+// created, and the existing commit is returned.
+func (u *registryUpload) uploadModule(
+	ctx context.Context,
+	module bufmodule.Module,
+) (bufmodule.Commit, error) {
+	if commit, ok := u.opaqueIDToCommit[module.OpaqueID()]; ok {
+		return commit, nil
+	}
+	moduleFullName := module.ModuleFullName()
+	externalModule, err := u.getOrCreateModule(ctx, moduleFullName)
+	if err != nil {
+		return nil, err
+	}
+	moduleDeps, err := module.ModuleDeps()
+	if err != nil {
+		return nil, err
+	}
+	// Dependencies must be in the registry before the Module itself.
+	depOpaqueIDToCommitID := make(map[string]uuid.UUID, len(moduleDeps))
+	for _, moduleDep := range moduleDeps {
+		if moduleDep.ModuleFullName() == nil {
+			return nil, fmt.Errorf("all dependencies for module %q must be named but this module is not: %s", moduleFullName.String(), moduleDep.Description())
+		}
+		if bufmodule.IsRemoteModuleForBucket(moduleDep) {
+			return nil, fmt.Errorf("module %q cannot be pushed because it depends on %s, which is not in a registry", moduleFullName.String(), moduleDep.Description())
+		}
+		if moduleDep.IsLocal() {
+			depCommit, err := u.uploadModule(ctx, moduleDep)
+			if err != nil {
+				return nil, err
+			}
+			depOpaqueIDToCommitID[moduleDep.OpaqueID()] = depCommit.ModuleKey().CommitID()
+			continue
+		}
+		if _, err := u.registry.readCommit(ctx, moduleDep.CommitID()); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf(
+					"dependency %s:%s of module %s does not exist in the registry directory, push it first",
+					moduleDep.ModuleFullName().String(),
+					uuidutil.ToDashless(moduleDep.CommitID()),
+					moduleFullName.String(),
+				)
+			}
+			return nil, err
+		}
+		depOpaqueIDToCommitID[moduleDep.OpaqueID()] = moduleDep.CommitID()
+	}
+	labels := u.uploadOptions.Labels()
+	if len(labels) == 0 {
+		labels = append([]string{externalModule.DefaultLabel}, u.uploadOptions.Tags()...)
+	}
+	b5Digest, err := module.Digest(bufmodule.DigestTypeB5)
+	if err != nil {
+		return nil, err
+	}
+	commitID, createTime, err := u.getExistingCommitForLabels(ctx, moduleFullName, labels, b5Digest)
+	if err != nil {
+		return nil, err
+	}
+	if commitID == uuid.Nil {
+		commitID, err = uuidutil.New()
+		if err != nil {
+			return nil, err
+		}
+		createTime = u.createTime
+		if err := u.putCommit(ctx, module, moduleDeps, commitID, depOpaqueIDToCommitID); err != nil {
+			return nil, err
+		}
+	}
+	for _, label := range labels {
+		if err := u.registry.writeLabel(ctx, moduleFullName, label, commitID); err != nil {
+			return nil, err
+		}
+	}
+	moduleKey, err := bufmodule.NewModuleKey(
+		moduleFullName,
+		commitID,
+		func() (bufmodule.Digest, error) {
+			return b5Digest, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	commit := bufmodule.NewCommit(
+		moduleKey,
+		func() (time.Time, error) {
+			return createTime, nil
+		},
+	)
+	u.opaqueIDToCommit[module.OpaqueID()] = commit
+	return commit, nil
+}
+
+func (u *registryUpload) getOrCreateModule(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+) (*externalModule, error) {
+	externalModule, err := u.registry.readModule(ctx, moduleFullName)
+	if err == nil {
+		return externalModule, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if !u.uploadOptions.CreateIfNotExist() {
+		return nil, fmt.Errorf("module %s does not exist in the registry directory", moduleFullName.String())
+	}
+	externalModule = newExternalModule(u.uploadOptions.CreateDefaultLabel())
+	if err := u.registry.writeModule(ctx, moduleFullName, externalModule); err != nil {
+		return nil, err
+	}
+	return externalModule, nil
+}
+
+func (u *registryUpload) getExistingCommitForLabels(
+	ctx context.Context,
+	moduleFullName bufmodule.ModuleFullName,
+	labels []string,
+	b5Digest bufmodule.Digest,
+) (uuid.UUID, time.Time, error) {
+	for _, label := range labels {
+		commitID, err := u.registry.readLabel(ctx, moduleFullName, label)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return uuid.Nil, time.Time{}, err
+		}
+		externalCommit, err := u.registry.readCommit(ctx, commitID)
+		if err != nil {
+			return uuid.Nil, time.Time{}, err
+		}
+		if slices.Contains(externalCommit.Digests, b5Digest.String()) {
+			return commitID, externalCommit.CreateTime, nil
+		}
+	}
+	return uuid.Nil, time.Time{}, nil
+}
+
+// putCommit writes the files of the Module for all DigestTypes, and then the commit itself.
+//
+// The commit is written last, so that a commit is only ever visible once all of its data is present.
+func (u *registryUpload) putCommit(
+	ctx context.Context,
+	module bufmodule.Module,
+	moduleDeps []bufmodule.ModuleDep,
+	commitID uuid.UUID,
+	depOpaqueIDToCommitID map[string]uuid.UUID,
+) error {
+	moduleDatas := make([]bufmodule.ModuleData, 0, len(bufmodule.AllDigestTypes))
+	digestStrings := make([]string, 0, len(bufmodule.AllDigestTypes))
+	for _, digestType := range bufmodule.AllDigestTypes {
+		digest, err := module.Digest(digestType)
+		if err != nil {
+			return err
+		}
+		digestStrings = append(digestStrings, digest.String())
+		moduleKey, err := bufmodule.NewModuleKey(
+			module.ModuleFullName(),
+			commitID,
+			func() (bufmodule.Digest, error) {
+				return digest, nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+		declaredDepModuleKeys, err := slicesext.MapError(
+			moduleDeps,
+			func(moduleDep bufmodule.ModuleDep) (bufmodule.ModuleKey, error) {
+				return bufmodule.NewModuleKey(
+					moduleDep.ModuleFullName(),
+					depOpaqueIDToCommitID[moduleDep.OpaqueID()],
+					func() (bufmodule.Digest, error) {
+						return moduleDep.Digest(digestType)
+					},
+				)
+			},
+		)
+		if err != nil {
+			return err
+		}
+		moduleDatas = append(
+			moduleDatas,
+			bufmodule.NewModuleData(
+				ctx,
+				moduleKey,
+				func() (storage.ReadBucket, error) {
+					return bufmodule.ModuleReadBucketToStorageReadBucket(module), nil
+				},
+				func() ([]bufmodule.ModuleKey, error) {
+					return declaredDepModuleKeys, nil
+				},
+				module.V1Beta1OrV1BufYAMLObjectData,
+				module.V1Beta1OrV1BufLockObjectData,
+			),
+		)
+	}
+	if err := u.registry.moduleDataStore.PutModuleDatas(ctx, moduleDatas); err != nil {
+		return err
+	}
+	directModuleDeps, err := bufmodule.ModuleDirectModuleDeps(module)
+	if err != nil {
+		return err
+	}
+	return u.registry.writeCommit(
+		ctx,
+		commitID,
+		&externalCommit{
+			Version:    externalVersion,
+			Module:     module.ModuleFullName().String(),
+			CreateTime: u.createTime,
+			Digests:    digestStrings,
+			Deps: slicesext.Map(
+				directModuleDeps,
+				func(moduleDep bufmodule.ModuleDep) string {
+					return uuidutil.ToDashless(depOpaqueIDToCommitID[moduleDep.OpaqueID()])
+				},
+			),
+		},
+	)
+}
+
+// externalModule is the module.yaml of a module in a registry directory.
+type externalModule struct {
+	Version      string `json:"version,omitempty" yaml:"version,omitempty"`
+	DefaultLabel string `json:"default_label,omitempty" yaml:"default_label,omitempty"`
+}
+
+func newExternalModule(defaultLabel string) *externalModule {
+	if defaultLabel == "" {
+		defaultLabel = defaultDefaultLabel
+	}
+	return &externalModule{
+		Version:      externalVersion,
+		DefaultLabel: defaultLabel,
+	}
+}
+
+// externalCommit is the commit file of a commit in a registry directory.
+type externalCommit struct {
+	Version    string    `json:"version,omitempty" yaml:"version,omitempty"`
+	Module     string    `json:"module,omitempty" yaml:"module,omitempty"`
+	CreateTime time.Time `json:"create_time,omitempty" yaml:"create_time,omitempty"`
+	// Digests are the digests of the commit for all DigestTypes, as strings.
+	Digests []string `json:"digests,omitempty" yaml:"digests,omitempty"`
+	// Deps are the dashless commit IDs of the direct dependencies of the commit.
+	Deps []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+}
+
+func getModuleDirPath(moduleFullName bufmodule.ModuleFullName) string {
+	return normalpath.Join(
+		modulesDirPath,
+		moduleFullName.Registry(),
+		moduleFullName.Owner(),
+		moduleFullName.Name(),
+	)
+}
+
+func getLabelPath(moduleFullName bufmodule.ModuleFullName, label string) (string, error) {
+	// Labels may contain characters that are not valid in paths, such as slashes.
+	labelFileName := url.PathEscape(label)
+	// Escaping leaves "." and ".." as is, which would otherwise resolve outside of the labels directory.
+	if labelFileName == "." || labelFileName == ".." {
+		return "", fmt.Errorf("invalid label %q", label)
+	}
+	normalizedLabelFileName, err := normalpath.NormalizeAndValidate(labelFileName)
+	if err != nil {
+		return "", fmt.Errorf("invalid label %q: %w", label, err)
+	}
+	if normalizedLabelFileName != labelFileName {
+		return "", fmt.Errorf("invalid label %q", label)
+	}
+	return normalpath.Join(getModuleDirPath(moduleFullName), labelsDirName, labelFileName), nil
+}
+
+func getCommitPath(commitID uuid.UUID) string {
+	return normalpath.Join(commitsDirPath, uuidutil.ToDashless(commitID)+commitFileExt)
+}
@@ -0,0 +1,235 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmodulestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slogext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+)
+
+// CommitStoreEntry is a single Commit stored within a CommitStore.
+type CommitStoreEntry interface {
+	// CommitKey is the CommitKey of the Commit.
+	CommitKey() bufmodule.CommitKey
+	// ModuleFullName is the full name of the module of the Commit.
+	//
+	// This may be nil if the stored Commit cannot be read.
+	ModuleFullName() bufmodule.ModuleFullName
+	// Size is the size of the entry in bytes.
+	Size() int64
+	// LastAccessTime is the time the Commit was written to the store, if known, and
+	// the zero time otherwise.
+	//
+	// Commits are immutable and small, so reads are not recorded.
+	LastAccessTime() time.Time
+
+	isCommitStoreEntry()
+}
+
+// CommitStoreManager lists, verifies, and deletes the entries of a CommitStore.
+type CommitStoreManager interface {
+	// ListCommitStoreEntries lists all entries in the store.
+	//
+	// Ordered by registry, then CommitID.
+	ListCommitStoreEntries(ctx context.Context) ([]CommitStoreEntry, error)
+	// VerifyCommitStoreEntry verifies that the entry can be read and is valid.
+	//
+	// Returns an error wrapping ErrCorruptStoreEntry if the entry is corrupt.
+	VerifyCommitStoreEntry(ctx context.Context, commitStoreEntry CommitStoreEntry) error
+	// DeleteCommitStoreEntry deletes the entry from the store.
+	//
+	// It is not an error to delete an entry that does not exist.
+	DeleteCommitStoreEntry(ctx context.Context, commitStoreEntry CommitStoreEntry) error
+}
+
+// NewCommitStoreManager returns a new CommitStoreManager for the given bucket.
+//
+// The bucket should be the same as the bucket given to NewCommitStore.
+func NewCommitStoreManager(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+) CommitStoreManager {
+	return newCommitStore(logger, bucket)
+}
+
+// *** PRIVATE ***
+
+func (p *commitStore) ListCommitStoreEntries(ctx context.Context) ([]CommitStoreEntry, error) {
+	var commitStoreEntries []CommitStoreEntry
+	if err := p.bucket.Walk(
+		ctx,
+		"",
+		func(objectInfo storage.ObjectInfo) error {
+			commitStoreEntry, err := p.getCommitStoreEntry(ctx, objectInfo)
+			if err != nil {
+				// The file does not have the layout we expect, this was not written by us.
+				p.logger.DebugContext(
+					ctx,
+					"commit store ignoring unknown file",
+					slog.String("path", objectInfo.Path()),
+					slogext.ErrorAttr(err),
+				)
+				return nil
+			}
+			commitStoreEntries = append(commitStoreEntries, commitStoreEntry)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	sort.Slice(
+		commitStoreEntries,
+		func(i int, j int) bool {
+			one := commitStoreEntries[i].CommitKey()
+			two := commitStoreEntries[j].CommitKey()
+			if one.Registry() != two.Registry() {
+				return one.Registry() < two.Registry()
+			}
+			if oneCommitID, twoCommitID := one.CommitID().String(), two.CommitID().String(); oneCommitID != twoCommitID {
+				return oneCommitID < twoCommitID
+			}
+			return one.DigestType() < two.DigestType()
+		},
+	)
+	return commitStoreEntries, nil
+}
+
+func (p *commitStore) VerifyCommitStoreEntry(ctx context.Context, commitStoreEntry CommitStoreEntry) error {
+	commitKey := commitStoreEntry.CommitKey()
+	data, err := storage.ReadPath(
+		ctx,
+		p.getReadWriteBucketForDir(ctx, commitKey),
+		getCommitStoreFilePath(commitKey),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptStoreEntry, err)
+	}
+	if _, err := getValidExternalCommitForCommitKey(commitKey, data); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptStoreEntry, err)
+	}
+	return nil
+}
+
+func (p *commitStore) DeleteCommitStoreEntry(ctx context.Context, commitStoreEntry CommitStoreEntry) error {
+	commitKey := commitStoreEntry.CommitKey()
+	p.logDebugCommitKey(ctx, commitKey, "commit store delete")
+	// Commit files are written atomically, so no locking is required.
+	if err := p.bucket.Delete(
+		ctx,
+		normalpath.Join(getCommitStoreDirPath(commitKey), getCommitStoreFilePath(commitKey)),
+	); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (p *commitStore) getCommitStoreEntry(ctx context.Context, objectInfo storage.ObjectInfo) (*commitStoreEntry, error) {
+	// "digestType/registry/dashlessCommitID.json"
+	components := strings.Split(objectInfo.Path(), "/")
+	if len(components) != 3 {
+		return nil, errors.New("unexpected path")
+	}
+	digestType, err := bufmodule.ParseDigestType(components[0])
+	if err != nil {
+		return nil, err
+	}
+	dashlessCommitID, ok := strings.CutSuffix(components[2], ".json")
+	if !ok {
+		return nil, errors.New("unexpected file extension")
+	}
+	commitID, err := uuidutil.FromDashless(dashlessCommitID)
+	if err != nil {
+		return nil, err
+	}
+	commitKey, err := bufmodule.NewCommitKey(components[1], commitID, digestType)
+	if err != nil {
+		return nil, err
+	}
+	size, err := getObjectSize(ctx, p.bucket, objectInfo)
+	if err != nil {
+		return nil, err
+	}
+	// A commit file that cannot be read is reported when verifying, so we ignore errors here.
+	var moduleFullName bufmodule.ModuleFullName
+	if data, err := storage.ReadPath(ctx, p.bucket, objectInfo.Path()); err == nil {
+		if externalCommit, err := getValidExternalCommitForCommitKey(commitKey, data); err == nil {
+			moduleFullName, _ = bufmodule.NewModuleFullName(commitKey.Registry(), externalCommit.Owner, externalCommit.Module)
+		}
+	}
+	return &commitStoreEntry{
+		commitKey:      commitKey,
+		moduleFullName: moduleFullName,
+		size:           size,
+		lastAccessTime: getObjectModTime(objectInfo),
+	}, nil
+}
+
+type commitStoreEntry struct {
+	commitKey      bufmodule.CommitKey
+	moduleFullName bufmodule.ModuleFullName
+	size           int64
+	lastAccessTime time.Time
+}
+
+func (e *commitStoreEntry) CommitKey() bufmodule.CommitKey {
+	return e.commitKey
+}
+
+func (e *commitStoreEntry) ModuleFullName() bufmodule.ModuleFullName {
+	return e.moduleFullName
+}
+
+func (e *commitStoreEntry) Size() int64 {
+	return e.size
+}
+
+func (e *commitStoreEntry) LastAccessTime() time.Time {
+	return e.lastAccessTime
+}
+
+func (*commitStoreEntry) isCommitStoreEntry() {}
+
+// getValidExternalCommitForCommitKey unmarshals the data and validates it in the same
+// way as getCommitForCommitKey.
+func getValidExternalCommitForCommitKey(commitKey bufmodule.CommitKey, data []byte) (externalCommit, error) {
+	var externalCommit externalCommit
+	if err := json.Unmarshal(data, &externalCommit); err != nil {
+		return externalCommit, err
+	}
+	if !externalCommit.isValid() {
+		return externalCommit, fmt.Errorf("invalid commit: %+v", externalCommit)
+	}
+	digest, err := bufmodule.ParseDigest(externalCommit.Digest)
+	if err != nil {
+		return externalCommit, err
+	}
+	if commitKey.DigestType() != digest.Type() {
+		return externalCommit, fmt.Errorf("expected digest type %v but got %v", commitKey.DigestType(), digest.Type())
+	}
+	return externalCommit, nil
+}
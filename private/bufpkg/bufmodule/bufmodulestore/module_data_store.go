@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"time"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
 	"github.com/bufbuild/buf/private/pkg/encoding"
@@ -30,6 +31,7 @@ import (
 	"github.com/bufbuild/buf/private/pkg/storage"
 	"github.com/bufbuild/buf/private/pkg/storage/storagearchive"
 	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/buf/private/pkg/syserror"
 	"github.com/bufbuild/buf/private/pkg/uuidutil"
 )
 
@@ -40,6 +42,14 @@ var (
 	externalModuleDataV1BufYAMLDir = "v1_buf_yaml"
 	externalModuleDataV1BufLockDir = "v1_buf_lock"
 	externalModuleDataLockFileExt  = ".lock"
+	// externalModuleDataLastAccessFileName is the name of the file that records the last time
+	// the module data was read from the store. It is not part of the module data itself.
+	externalModuleDataLastAccessFileName = "last_access"
+	// lastAccessRecordInterval is the minimum interval between writes of the last access file.
+	//
+	// We do not want to write to the store on every read, and last access times are only used
+	// for pruning, where an hour of granularity is more than enough.
+	lastAccessRecordInterval = time.Hour
 )
 
 // ModuleDatasResult is a result for a get of ModuleDatas.
@@ -86,6 +96,21 @@ func NewModuleDataStore(
 	return newModuleDataStore(logger, bucket, locker, options...)
 }
 
+// NewReadOnlyModuleDataStore returns a new ModuleDataStore that only reads from the given bucket.
+//
+// The bucket is expected to have the same layout as a bucket written by NewModuleDataStore
+// without ModuleDataStoreWithTar. No locking is performed, and PutModuleDatas always errors.
+//
+// This is typically used to read from a vendor directory checked into a repository.
+func NewReadOnlyModuleDataStore(
+	logger *slog.Logger,
+	bucket storage.ReadBucket,
+) ModuleDataStore {
+	moduleDataStore := newModuleDataStore(logger, newReadOnlyBucket(bucket), filelock.NewNopLocker())
+	moduleDataStore.readOnly = true
+	return moduleDataStore
+}
+
 // ModuleDataStoreOption is an option for a new ModuleDataStore.
 type ModuleDataStoreOption func(*moduleDataStore)
 
@@ -99,6 +124,17 @@ func ModuleDataStoreWithTar() ModuleDataStoreOption {
 	}
 }
 
+// ModuleDataStoreWithRecordLastAccess returns a new ModuleDataStoreOption that records
+// the time that module data was last read or written, which is used for pruning caches.
+//
+// This has no effect if ModuleDataStoreWithTar is also set.
+// The default is to not record the last access time.
+func ModuleDataStoreWithRecordLastAccess() ModuleDataStoreOption {
+	return func(moduleDataStore *moduleDataStore) {
+		moduleDataStore.recordLastAccessTime = true
+	}
+}
+
 /// *** PRIVATE ***
 
 type moduleDataStore struct {
@@ -106,7 +142,9 @@ type moduleDataStore struct {
 	bucket storage.ReadWriteBucket
 	locker filelock.Locker
 
-	tar bool
+	tar                  bool
+	readOnly             bool
+	recordLastAccessTime bool
 }
 
 func newModuleDataStore(
@@ -150,6 +188,9 @@ func (p *moduleDataStore) PutModuleDatas(
 	ctx context.Context,
 	moduleDatas []bufmodule.ModuleData,
 ) error {
+	if p.readOnly && len(moduleDatas) > 0 {
+		return syserror.New("cannot put ModuleDatas to a read-only ModuleDataStore")
+	}
 	for _, moduleData := range moduleDatas {
 		if err := p.putModuleData(ctx, moduleData); err != nil {
 			return err
@@ -189,6 +230,8 @@ func (p *moduleDataStore) getModuleDataForModuleKey(
 ) (retValue bufmodule.ModuleData, retErr error) {
 	var moduleCacheBucket storage.ReadBucket
 	var err error
+	// Only set if not storing tar files.
+	var moduleCacheReadWriteBucket storage.ReadWriteBucket
 	if p.tar {
 		moduleCacheBucket, err = p.getReadBucketForTar(ctx, moduleKey)
 		if err != nil {
@@ -218,7 +261,8 @@ func (p *moduleDataStore) getModuleDataForModuleKey(
 			"module data store dir read write bucket",
 			slog.String("dirPath", dirPath),
 		)
-		moduleCacheBucket = storage.MapReadWriteBucket(p.bucket, storage.MapOnPrefix(dirPath))
+		moduleCacheReadWriteBucket = storage.MapReadWriteBucket(p.bucket, storage.MapOnPrefix(dirPath))
+		moduleCacheBucket = moduleCacheReadWriteBucket
 		moduleDataStoreDirLockPath, err := getModuleDataStoreDirLockPath(moduleKey)
 		if err != nil {
 			return nil, err
@@ -235,6 +279,26 @@ func (p *moduleDataStore) getModuleDataForModuleKey(
 			}
 		}()
 	}
+	moduleData, err := p.readModuleData(ctx, moduleKey, moduleCacheBucket)
+	if err != nil {
+		return nil, err
+	}
+	if moduleCacheReadWriteBucket != nil && p.recordLastAccessTime {
+		// We are still holding the shared lock, so the module data cannot be deleted from
+		// underneath us while we record the access.
+		p.recordLastAccess(ctx, moduleKey, moduleCacheReadWriteBucket)
+	}
+	return moduleData, nil
+}
+
+// readModuleData reads the module data for the module key from the bucket for the module key.
+//
+// Any required locks must be held by the caller.
+func (p *moduleDataStore) readModuleData(
+	ctx context.Context,
+	moduleKey bufmodule.ModuleKey,
+	moduleCacheBucket storage.ReadBucket,
+) (bufmodule.ModuleData, error) {
 	// Attempt to read module.yaml from cache. The module.yaml file is always written last,
 	// so if a valid module.yaml file is present, then we proceed to read the rest of the
 	// the module data.
@@ -525,12 +589,46 @@ func (p *moduleDataStore) putModuleData(
 	// Put the module.yaml last, so that we only have a module.yaml if the cache is finished writing.
 	// We can use the existence of the module.yaml file to say whether or not the cache contains a
 	// given ModuleKey, otherwise we overwrite any contents in the cache.
-	return storage.PutPath(
+	if err := storage.PutPath(
 		ctx,
 		moduleCacheBucket,
 		externalModuleDataFileName,
 		data,
 		storage.PutWithAtomic(),
+	); err != nil {
+		return err
+	}
+	if !p.tar && p.recordLastAccessTime {
+		p.recordLastAccess(ctx, moduleKey, moduleCacheBucket)
+	}
+	return nil
+}
+
+// recordLastAccess records the current time as the last access time of the module data.
+//
+// The time is only written if the recorded time is older than lastAccessRecordInterval.
+// Errors are logged and otherwise ignored, as the last access time is only used for pruning.
+func (p *moduleDataStore) recordLastAccess(
+	ctx context.Context,
+	moduleKey bufmodule.ModuleKey,
+	moduleCacheBucket storage.ReadWriteBucket,
+) {
+	now := time.Now().UTC()
+	if lastAccessTime, err := readLastAccessTime(ctx, moduleCacheBucket); err == nil && now.Sub(lastAccessTime) < lastAccessRecordInterval {
+		return
+	}
+	err := storage.PutPath(
+		ctx,
+		moduleCacheBucket,
+		externalModuleDataLastAccessFileName,
+		[]byte(now.Format(time.RFC3339)),
+		storage.PutWithAtomic(),
+	)
+	p.logDebugModuleKey(
+		ctx,
+		moduleKey,
+		fmt.Sprintf("module data store put %s", externalModuleDataLastAccessFileName),
+		slogext.ErrorAttr(err),
 	)
 }
 
@@ -683,6 +781,15 @@ func getDeclaredDepModuleKeyForExternalModuleDataDep(dep externalModuleDataDep)
 	)
 }
 
+// readLastAccessTime reads the last access time from the bucket for a single module.
+func readLastAccessTime(ctx context.Context, moduleCacheBucket storage.ReadBucket) (time.Time, error) {
+	data, err := storage.ReadPath(ctx, moduleCacheBucket, externalModuleDataLastAccessFileName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, string(data))
+}
+
 func getModuleDataStoreDirLockPath(moduleKey bufmodule.ModuleKey) (string, error) {
 	moduleDataStoreDirPath, err := getModuleDataStoreDirPath(moduleKey)
 	if err != nil {
@@ -0,0 +1,352 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmodulestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slogext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/bufbuild/buf/private/pkg/uuidutil"
+	"github.com/google/uuid"
+)
+
+// ErrCorruptStoreEntry is wrapped by errors returned when verifying an entry of a store
+// finds that the entry is corrupt.
+var ErrCorruptStoreEntry = errors.New("corrupt store entry")
+
+// ModuleDataStoreEntry is a single module stored within a ModuleDataStore.
+type ModuleDataStoreEntry interface {
+	// ModuleFullName is the full name of the module.
+	ModuleFullName() bufmodule.ModuleFullName
+	// CommitID is the ID of the commit of the module.
+	CommitID() uuid.UUID
+	// DigestType is the type of the digest the module was stored under.
+	DigestType() bufmodule.DigestType
+	// Size is the total size of all files of the entry in bytes.
+	Size() int64
+	// LastAccessTime is the last time the module was read from or written to the store.
+	//
+	// Access times are recorded with a granularity of an hour. If no access time was
+	// recorded, this is the last time a file of the module was written to the store, if
+	// known, and the zero time otherwise.
+	LastAccessTime() time.Time
+
+	isModuleDataStoreEntry()
+}
+
+// ModuleDataStoreManager lists, verifies, and deletes the entries of a ModuleDataStore.
+//
+// All operations take the same locks as the ModuleDataStore, so it is safe to use
+// a ModuleDataStoreManager while other processes read from and write to the store.
+type ModuleDataStoreManager interface {
+	// ListModuleDataStoreEntries lists all entries in the store.
+	//
+	// Entries that were not completely written are also listed, and are reported as corrupt
+	// by VerifyModuleDataStoreEntry.
+	// Ordered by ModuleFullName, then CommitID.
+	ListModuleDataStoreEntries(ctx context.Context) ([]ModuleDataStoreEntry, error)
+	// VerifyModuleDataStoreEntry re-computes the Digest of the entry and compares it
+	// against the expected Digest.
+	//
+	// The expected Digest is the Digest of the module that the entry is stored for, such as
+	// the Digest of its commit. If the expected Digest is nil, this only verifies that the
+	// entry was completely written and can be read.
+	//
+	// Returns an error wrapping ErrCorruptStoreEntry if the entry cannot be read or
+	// the Digests do not match.
+	VerifyModuleDataStoreEntry(
+		ctx context.Context,
+		moduleDataStoreEntry ModuleDataStoreEntry,
+		expectedDigest bufmodule.Digest,
+	) error
+	// DeleteModuleDataStoreEntry deletes the entry from the store.
+	//
+	// It is not an error to delete an entry that does not exist.
+	DeleteModuleDataStoreEntry(ctx context.Context, moduleDataStoreEntry ModuleDataStoreEntry) error
+}
+
+// NewModuleDataStoreManager returns a new ModuleDataStoreManager for the given bucket.
+//
+// The bucket and locker should be the same as those given to NewModuleDataStore. Only stores
+// that store individual files are supported, that is stores not created with ModuleDataStoreWithTar.
+func NewModuleDataStoreManager(
+	logger *slog.Logger,
+	bucket storage.ReadWriteBucket,
+	locker filelock.Locker,
+) ModuleDataStoreManager {
+	return newModuleDataStore(logger, bucket, locker)
+}
+
+// *** PRIVATE ***
+
+func (p *moduleDataStore) ListModuleDataStoreEntries(ctx context.Context) ([]ModuleDataStoreEntry, error) {
+	dirPathToSize := make(map[string]int64)
+	dirPathToModTime := make(map[string]time.Time)
+	if err := p.bucket.Walk(
+		ctx,
+		"",
+		func(objectInfo storage.ObjectInfo) error {
+			// "digestType/registry/owner/name/dashlessCommitID/..."
+			components := strings.Split(objectInfo.Path(), "/")
+			if len(components) < 6 {
+				// Not part of a module directory, for example a tar file.
+				return nil
+			}
+			dirPath := normalpath.Join(components[:5]...)
+			size, err := getObjectSize(ctx, p.bucket, objectInfo)
+			if err != nil {
+				return err
+			}
+			dirPathToSize[dirPath] += size
+			if modTime := getObjectModTime(objectInfo); modTime.After(dirPathToModTime[dirPath]) {
+				dirPathToModTime[dirPath] = modTime
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	var moduleDataStoreEntries []ModuleDataStoreEntry
+	for dirPath, size := range dirPathToSize {
+		moduleDataStoreEntry, err := p.getModuleDataStoreEntry(
+			ctx,
+			dirPath,
+			size,
+			dirPathToModTime[dirPath],
+		)
+		if err != nil {
+			// The directory does not have the layout we expect, this was not written by us.
+			p.logger.DebugContext(
+				ctx,
+				"module data store ignoring unknown directory",
+				slog.String("dirPath", dirPath),
+				slogext.ErrorAttr(err),
+			)
+			continue
+		}
+		moduleDataStoreEntries = append(moduleDataStoreEntries, moduleDataStoreEntry)
+	}
+	sort.Slice(
+		moduleDataStoreEntries,
+		func(i int, j int) bool {
+			one := moduleDataStoreEntries[i]
+			two := moduleDataStoreEntries[j]
+			if oneName, twoName := one.ModuleFullName().String(), two.ModuleFullName().String(); oneName != twoName {
+				return oneName < twoName
+			}
+			if oneCommitID, twoCommitID := one.CommitID().String(), two.CommitID().String(); oneCommitID != twoCommitID {
+				return oneCommitID < twoCommitID
+			}
+			return one.DigestType() < two.DigestType()
+		},
+	)
+	return moduleDataStoreEntries, nil
+}
+
+func (p *moduleDataStore) VerifyModuleDataStoreEntry(
+	ctx context.Context,
+	moduleDataStoreEntry ModuleDataStoreEntry,
+	expectedDigest bufmodule.Digest,
+) (retErr error) {
+	if expectedDigest != nil && expectedDigest.Type() != moduleDataStoreEntry.DigestType() {
+		return fmt.Errorf(
+			"expected digest of type %v for %s but got %v",
+			moduleDataStoreEntry.DigestType(),
+			getModuleDataStoreEntryDirPath(moduleDataStoreEntry),
+			expectedDigest.Type(),
+		)
+	}
+	moduleKey, err := bufmodule.NewModuleKey(
+		moduleDataStoreEntry.ModuleFullName(),
+		moduleDataStoreEntry.CommitID(),
+		func() (bufmodule.Digest, error) {
+			if expectedDigest == nil {
+				return nil, syserror.New("no expected digest")
+			}
+			return expectedDigest, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	dirPath := getModuleDataStoreEntryDirPath(moduleDataStoreEntry)
+	// Acquire a shared lock so that the module data is not written or deleted while we verify it.
+	unlocker, err := p.locker.RLock(ctx, dirPath+externalModuleDataLockFileExt)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	moduleData, err := p.readModuleData(
+		ctx,
+		moduleKey,
+		storage.MapReadBucket(p.bucket, storage.MapOnPrefix(dirPath)),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptStoreEntry, err)
+	}
+	if expectedDigest == nil {
+		return nil
+	}
+	// Bucket checks the digest of the module data against the digest of the ModuleKey.
+	if _, err := moduleData.Bucket(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptStoreEntry, err)
+	}
+	return nil
+}
+
+func (p *moduleDataStore) DeleteModuleDataStoreEntry(
+	ctx context.Context,
+	moduleDataStoreEntry ModuleDataStoreEntry,
+) (retErr error) {
+	dirPath := getModuleDataStoreEntryDirPath(moduleDataStoreEntry)
+	// Acquire an exclusive lock so that no other process is reading or writing the module data.
+	unlocker, err := p.locker.Lock(ctx, dirPath+externalModuleDataLockFileExt)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unlocker.Unlock(); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	p.logger.DebugContext(ctx, "module data store delete", slog.String("dirPath", dirPath))
+	return p.bucket.DeleteAll(ctx, dirPath)
+}
+
+func (p *moduleDataStore) getModuleDataStoreEntry(
+	ctx context.Context,
+	dirPath string,
+	size int64,
+	modTime time.Time,
+) (*moduleDataStoreEntry, error) {
+	components := strings.Split(dirPath, "/")
+	digestType, err := bufmodule.ParseDigestType(components[0])
+	if err != nil {
+		return nil, err
+	}
+	moduleFullName, err := bufmodule.NewModuleFullName(components[1], components[2], components[3])
+	if err != nil {
+		return nil, err
+	}
+	commitID, err := uuidutil.FromDashless(components[4])
+	if err != nil {
+		return nil, err
+	}
+	moduleCacheBucket := storage.MapReadBucket(p.bucket, storage.MapOnPrefix(dirPath))
+	lastAccessTime, err := readLastAccessTime(ctx, moduleCacheBucket)
+	if err != nil {
+		lastAccessTime = modTime
+	}
+	return &moduleDataStoreEntry{
+		moduleFullName: moduleFullName,
+		commitID:       commitID,
+		digestType:     digestType,
+		size:           size,
+		lastAccessTime: lastAccessTime,
+	}, nil
+}
+
+type moduleDataStoreEntry struct {
+	moduleFullName bufmodule.ModuleFullName
+	commitID       uuid.UUID
+	digestType     bufmodule.DigestType
+	size           int64
+	lastAccessTime time.Time
+}
+
+func (e *moduleDataStoreEntry) ModuleFullName() bufmodule.ModuleFullName {
+	return e.moduleFullName
+}
+
+func (e *moduleDataStoreEntry) CommitID() uuid.UUID {
+	return e.commitID
+}
+
+func (e *moduleDataStoreEntry) DigestType() bufmodule.DigestType {
+	return e.digestType
+}
+
+func (e *moduleDataStoreEntry) Size() int64 {
+	return e.size
+}
+
+func (e *moduleDataStoreEntry) LastAccessTime() time.Time {
+	return e.lastAccessTime
+}
+
+func (*moduleDataStoreEntry) isModuleDataStoreEntry() {}
+
+// getModuleDataStoreEntryDirPath returns the same path as getModuleDataStoreDirPath.
+func getModuleDataStoreEntryDirPath(moduleDataStoreEntry ModuleDataStoreEntry) string {
+	return normalpath.Join(
+		moduleDataStoreEntry.DigestType().String(),
+		moduleDataStoreEntry.ModuleFullName().Registry(),
+		moduleDataStoreEntry.ModuleFullName().Owner(),
+		moduleDataStoreEntry.ModuleFullName().Name(),
+		uuidutil.ToDashless(moduleDataStoreEntry.CommitID()),
+	)
+}
+
+// getObjectSize returns the size of the object.
+//
+// If the object is on local disk, this stats the file, otherwise the object is read.
+func getObjectSize(ctx context.Context, readBucket storage.ReadBucket, objectInfo storage.ObjectInfo) (_ int64, retErr error) {
+	if localPath := objectInfo.LocalPath(); localPath != "" {
+		fileInfo, err := os.Stat(localPath)
+		if err != nil {
+			return 0, err
+		}
+		return fileInfo.Size(), nil
+	}
+	readObjectCloser, err := readBucket.Get(ctx, objectInfo.Path())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		retErr = errors.Join(retErr, readObjectCloser.Close())
+	}()
+	return io.Copy(io.Discard, readObjectCloser)
+}
+
+// getObjectModTime returns the modification time of the object if the object is on
+// local disk, and the zero time otherwise.
+func getObjectModTime(objectInfo storage.ObjectInfo) time.Time {
+	localPath := objectInfo.LocalPath()
+	if localPath == "" {
+		return time.Time{}
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return fileInfo.ModTime().UTC()
+}
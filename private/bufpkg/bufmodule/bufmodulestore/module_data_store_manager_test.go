@@ -0,0 +1,144 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufmodulestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/filelock"
+	"github.com/bufbuild/buf/private/pkg/normalpath"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/bufbuild/buf/private/pkg/storage"
+	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
+	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleDataStoreManagerBasic(t *testing.T) {
+	t.Parallel()
+	testModuleDataStoreManager(t, storagemem.NewReadWriteBucket(), filelock.NewNopLocker())
+}
+
+func TestModuleDataStoreManagerOS(t *testing.T) {
+	t.Parallel()
+	// The store and the locks are in separate directories, as in the cache.
+	bucket, err := storageos.NewProvider().NewReadWriteBucket(t.TempDir())
+	require.NoError(t, err)
+	filelocker, err := filelock.NewLocker(t.TempDir())
+	require.NoError(t, err)
+	testModuleDataStoreManager(t, bucket, filelocker)
+}
+
+func testModuleDataStoreManager(
+	t *testing.T,
+	bucket storage.ReadWriteBucket,
+	filelocker filelock.Locker,
+) {
+	ctx := context.Background()
+	logger := slogtestext.NewLogger(t)
+	moduleDataStore := NewModuleDataStore(logger, bucket, filelocker, ModuleDataStoreWithRecordLastAccess())
+	moduleDataStoreManager := NewModuleDataStoreManager(logger, bucket, filelocker)
+	moduleKeys, moduleDatas := testGetModuleKeysAndModuleDatas(t, ctx)
+	require.NoError(t, moduleDataStore.PutModuleDatas(ctx, moduleDatas))
+
+	moduleDataStoreEntries, err := moduleDataStoreManager.ListModuleDataStoreEntries(ctx)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{
+			"buf.build/foo/mod1",
+			"buf.build/foo/mod2",
+			"buf.build/foo/mod3",
+		},
+		slicesext.Map(
+			moduleDataStoreEntries,
+			func(moduleDataStoreEntry ModuleDataStoreEntry) string {
+				return moduleDataStoreEntry.ModuleFullName().String()
+			},
+		),
+	)
+	moduleFullNameStringToDigest := make(map[string]bufmodule.Digest)
+	for _, moduleKey := range moduleKeys {
+		digest, err := moduleKey.Digest()
+		require.NoError(t, err)
+		moduleFullNameStringToDigest[moduleKey.ModuleFullName().String()] = digest
+	}
+	for _, moduleDataStoreEntry := range moduleDataStoreEntries {
+		require.Equal(t, bufmodule.DigestTypeB5, moduleDataStoreEntry.DigestType())
+		require.Greater(t, moduleDataStoreEntry.Size(), int64(0))
+		require.False(t, moduleDataStoreEntry.LastAccessTime().IsZero())
+		require.NoError(
+			t,
+			moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, moduleDataStoreEntry, moduleFullNameStringToDigest[moduleDataStoreEntry.ModuleFullName().String()]),
+		)
+		require.NoError(
+			t,
+			moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, moduleDataStoreEntry, nil),
+		)
+	}
+	// The digest of another module does not match.
+	require.ErrorIs(
+		t,
+		moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, moduleDataStoreEntries[0], moduleFullNameStringToDigest["buf.build/foo/mod2"]),
+		ErrCorruptStoreEntry,
+	)
+
+	// Corrupt a file of mod1, leaving module.yaml intact.
+	dirPath, err := getModuleDataStoreDirPath(moduleKeys[0])
+	require.NoError(t, err)
+	require.NoError(
+		t,
+		storage.PutPath(
+			ctx,
+			bucket,
+			normalpath.Join(dirPath, externalModuleDataFilesDir, "mod1.proto"),
+			[]byte(`syntax = proto3; package corrupt;`),
+		),
+	)
+	err = moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, moduleDataStoreEntries[0], moduleFullNameStringToDigest["buf.build/foo/mod1"])
+	require.ErrorIs(t, err, ErrCorruptStoreEntry)
+	var digestMismatchError *bufmodule.DigestMismatchError
+	require.ErrorAs(t, err, &digestMismatchError)
+
+	// Remove the module.yaml of mod2, as if it was not completely written. It is still listed,
+	// and is corrupt even without an expected digest.
+	require.Equal(t, "buf.build/foo/mod2", moduleDataStoreEntries[1].ModuleFullName().String())
+	require.NoError(
+		t,
+		bucket.Delete(ctx, normalpath.Join(getModuleDataStoreEntryDirPath(moduleDataStoreEntries[1]), externalModuleDataFileName)),
+	)
+	moduleDataStoreEntries, err = moduleDataStoreManager.ListModuleDataStoreEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, moduleDataStoreEntries, 3)
+	require.Equal(t, "buf.build/foo/mod2", moduleDataStoreEntries[1].ModuleFullName().String())
+	require.ErrorIs(
+		t,
+		moduleDataStoreManager.VerifyModuleDataStoreEntry(ctx, moduleDataStoreEntries[1], nil),
+		ErrCorruptStoreEntry,
+	)
+
+	require.NoError(t, moduleDataStoreManager.DeleteModuleDataStoreEntry(ctx, moduleDataStoreEntries[0]))
+	// Deleting again is not an error.
+	require.NoError(t, moduleDataStoreManager.DeleteModuleDataStoreEntry(ctx, moduleDataStoreEntries[0]))
+	moduleDataStoreEntries, err = moduleDataStoreManager.ListModuleDataStoreEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, moduleDataStoreEntries, 2)
+	_, notFoundModuleKeys, err := moduleDataStore.GetModuleDatasForModuleKeys(ctx, moduleKeys)
+	require.NoError(t, err)
+	testRequireModuleKeyNamesEqual(t, []string{"buf.build/foo/mod1", "buf.build/foo/mod2"}, notFoundModuleKeys)
+}
@@ -16,6 +16,7 @@ package bufmodulestore
 
 import (
 	"context"
+	"io/fs"
 	"testing"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
@@ -93,6 +94,13 @@ func testModuleDataStore(
 
 	err = moduleDataStore.PutModuleDatas(ctx, moduleDatas)
 	require.NoError(t, err)
+	if !tar {
+		// The last access time is only recorded with ModuleDataStoreWithRecordLastAccess.
+		dirPath, err := getModuleDataStoreDirPath(moduleKeys[0])
+		require.NoError(t, err)
+		_, err = storage.ReadPath(ctx, bucket, normalpath.Join(dirPath, externalModuleDataLastAccessFileName))
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	}
 
 	foundModuleDatas, notFoundModuleKeys, err = moduleDataStore.GetModuleDatasForModuleKeys(
 		ctx,
@@ -16,12 +16,16 @@ package bufmodulestore
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/pkg/storage"
 	"github.com/bufbuild/buf/private/pkg/uuidutil"
 )
 
+var errReadOnlyBucket = errors.New("cannot write to a read-only module data store")
+
 func logDebugModuleKey(ctx context.Context, logger *slog.Logger, moduleKey bufmodule.ModuleKey, message string, fields ...any) {
 	logger.DebugContext(
 		ctx,
@@ -50,3 +54,31 @@ func logDebugCommitKey(ctx context.Context, logger *slog.Logger, commitKey bufmo
 		)...,
 	)
 }
+
+// readOnlyBucket adapts a storage.ReadBucket to a storage.ReadWriteBucket for use
+// by read-only stores. All write operations return an error.
+type readOnlyBucket struct {
+	storage.ReadBucket
+}
+
+func newReadOnlyBucket(readBucket storage.ReadBucket) *readOnlyBucket {
+	return &readOnlyBucket{
+		ReadBucket: readBucket,
+	}
+}
+
+func (*readOnlyBucket) Put(context.Context, string, ...storage.PutOption) (storage.WriteObjectCloser, error) {
+	return nil, errReadOnlyBucket
+}
+
+func (*readOnlyBucket) Delete(context.Context, string) error {
+	return errReadOnlyBucket
+}
+
+func (*readOnlyBucket) DeleteAll(context.Context, string) error {
+	return errReadOnlyBucket
+}
+
+func (*readOnlyBucket) SetExternalAndLocalPathsSupported() bool {
+	return false
+}
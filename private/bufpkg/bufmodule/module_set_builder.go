@@ -97,6 +97,29 @@ type ModuleSetBuilder interface {
 		isTarget bool,
 		options ...RemoteModuleOption,
 	) ModuleSetBuilder
+	// AddRemoteModuleForBucket adds a new remote Module for the given Bucket.
+	//
+	// This is for remote Modules that are not retrieved from the ModuleDataProvider, such as
+	// dependencies on git repositories. As opposed to local Modules, these Modules are not part of
+	// the local context, and are never pushed.
+	//
+	// The Bucket used to construct the module will only be read for .proto files,
+	// license file(s), and documentation file(s).
+	//
+	// The ModuleFullName is required, and the resulting Module will not have a BucketID or a CommitID.
+	// The Module is never a target.
+	//
+	// The dependencies of the Module are *not* automatically added to the ModuleSet.
+	//
+	// Modules added with AddLocalModule take precedence, followed by Modules added with
+	// AddRemoteModuleForBucket, followed by Modules added with AddRemoteModule.
+	//
+	// Returns the same ModuleSetBuilder.
+	AddRemoteModuleForBucket(
+		bucket storage.ReadBucket,
+		moduleFullName ModuleFullName,
+		description string,
+	) ModuleSetBuilder
 	// Build builds the Modules into a ModuleSet.
 	//
 	// Any errors from Add* calls will be returned here as well.
@@ -404,6 +427,53 @@ func (b *moduleSetBuilder) AddRemoteModule(
 	return b
 }
 
+func (b *moduleSetBuilder) AddRemoteModuleForBucket(
+	bucket storage.ReadBucket,
+	moduleFullName ModuleFullName,
+	description string,
+) ModuleSetBuilder {
+	if b.buildCalled.Load() {
+		return b.addError(errBuildAlreadyCalled)
+	}
+	if moduleFullName == nil {
+		return b.addError(syserror.New("moduleFullName is required when calling AddRemoteModuleForBucket"))
+	}
+	module, err := newModule(
+		b.ctx,
+		getSyncOnceValuesGetBucketWithStorageMatcherApplied(
+			b.ctx,
+			func() (storage.ReadBucket, error) {
+				return bucket, nil
+			},
+		),
+		"",
+		description,
+		moduleFullName,
+		uuid.Nil,
+		false,
+		false,
+		func() (ObjectData, error) { return nil, nil },
+		func() (ObjectData, error) { return nil, nil },
+		func() ([]ModuleKey, error) {
+			// The dependencies of a bucket-backed remote Module are not pinned by the Module
+			// itself, they are resolved from the ModuleSet.
+			return nil, nil
+		},
+		nil,
+		nil,
+		"",
+		false,
+	)
+	if err != nil {
+		return b.addError(err)
+	}
+	b.addedModules = append(
+		b.addedModules,
+		newRemoteBucketAddedModule(module),
+	)
+	return b
+}
+
 func (b *moduleSetBuilder) Build() (ModuleSet, error) {
 	defer slogext.DebugProfile(b.logger)()
 
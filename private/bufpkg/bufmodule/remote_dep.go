@@ -18,6 +18,7 @@ import (
 	"sort"
 
 	"github.com/bufbuild/buf/private/pkg/syserror"
+	"github.com/google/uuid"
 )
 
 // RemoteDep is a remote dependency of some local Module in a ModuleSet.
@@ -37,8 +38,21 @@ type RemoteDep interface {
 	isRemoteDep()
 }
 
+// IsRemoteModuleForBucket returns true if the Module is a remote Module that was added with
+// ModuleSetBuilder.AddRemoteModuleForBucket.
+//
+// These Modules are not in a registry, so they have no CommitID, and cannot be depended on by
+// Modules that are pushed.
+func IsRemoteModuleForBucket(module Module) bool {
+	// Remote Modules read from the ModuleDataProvider always have a CommitID.
+	return !module.IsLocal() && module.CommitID() == uuid.Nil
+}
+
 // RemoteDepsForModuleSet returns the remote dependencies of the local Modules in the ModuleSet.
 //
+// Remote Modules added with ModuleSetBuilder.AddRemoteModuleForBucket are not RemoteDeps, as
+// they are not in a registry, but their own remote dependencies are.
+//
 // Sorted by ModuleFullName.
 //
 // TODO FUTURE: This needs a LOT of testing.
@@ -78,7 +92,7 @@ func RemoteDepsForModules(modules []Module) ([]RemoteDep, error) {
 				// Just a sanity check.
 				return nil, syserror.New("remote module did not have a ModuleFullName")
 			}
-			if moduleDep.IsDirect() {
+			if moduleDep.IsDirect() && !IsRemoteModuleForBucket(moduleDep) {
 				remoteDepModuleFullNameStringsThatAreDirectDepsOfLocal[moduleDepFullName.String()] = struct{}{}
 			}
 			iRemoteDepModules, err := remoteDepsForModuleSetRec(
@@ -152,7 +166,9 @@ func remoteDepsForModuleSetRec(
 		return nil, err
 	}
 	recDeps := make([]Module, 0, len(recModuleDeps)+1)
-	recDeps = append(recDeps, remoteModule)
+	if !IsRemoteModuleForBucket(remoteModule) {
+		recDeps = append(recDeps, remoteModule)
+	}
 	for _, recModuleDep := range recModuleDeps {
 		if recModuleDep.IsLocal() {
 			continue
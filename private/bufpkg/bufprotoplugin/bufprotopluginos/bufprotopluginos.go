@@ -24,6 +24,9 @@ import (
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
+// StdoutPluginOut is the plugin out that denotes a tar stream written to stdout.
+const StdoutPluginOut = "-"
+
 // ResponseWriter writes CodeGeneratorResponses to the OS filesystem.
 type ResponseWriter interface {
 	// Close writes all of the responses to disk. No further calls can be
@@ -32,8 +35,10 @@ type ResponseWriter interface {
 
 	// AddResponse adds the response to the writer, switching on the file extension.
 	// If there is a .jar extension, this generates a jar. If there is a .zip
-	// extension, this generates a zip. If there is no extension, this outputs
-	// to the directory.
+	// extension, this generates a zip. If there is a .tar extension, this generates
+	// a tar, and if there is a .tar.gz or .tgz extension, this generates a gzipped tar.
+	// If pluginOut is StdoutPluginOut, this writes a tar to stdout. Otherwise, this
+	// outputs to the directory.
 	//
 	// pluginOut will be unnormalized within this function.
 	AddResponse(
@@ -67,6 +72,16 @@ func ResponseWriterWithCreateOutDirIfNotExists() ResponseWriterOption {
 	}
 }
 
+// ResponseWriterWithStdout returns a new ResponseWriterOption that writes the
+// responses for StdoutPluginOut as a tar stream to the given writer.
+//
+// If this is not set, using StdoutPluginOut results in an error.
+func ResponseWriterWithStdout(stdout io.Writer) ResponseWriterOption {
+	return func(responseWriterOptions *responseWriterOptions) {
+		responseWriterOptions.stdout = stdout
+	}
+}
+
 // Cleaner deletes output locations prior to generation.
 //
 // This must be done before any interaction with  ResponseWriters, as multiple plugins may output to a single
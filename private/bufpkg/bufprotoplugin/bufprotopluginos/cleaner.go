@@ -23,6 +23,7 @@ import (
 	"github.com/bufbuild/buf/private/pkg/filepathext"
 	"github.com/bufbuild/buf/private/pkg/normalpath"
 	"github.com/bufbuild/buf/private/pkg/osext"
+	"github.com/bufbuild/buf/private/pkg/slicesext"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
 	"github.com/bufbuild/buf/private/pkg/syserror"
 )
@@ -51,6 +52,13 @@ func (c *cleaner) DeleteOuts(
 	if err != nil {
 		return err
 	}
+	pluginOuts = slicesext.Filter(
+		pluginOuts,
+		func(pluginOut string) bool {
+			// Nothing to delete for stdout.
+			return pluginOut != StdoutPluginOut
+		},
+	)
 	for _, pluginOut := range pluginOuts {
 		if err := validatePluginOut(pwd, pluginOut); err != nil {
 			return err
@@ -70,13 +78,11 @@ func (c *cleaner) deleteOut(
 ) error {
 	dirPath := pluginOut
 	removePath := "."
-	switch filepath.Ext(pluginOut) {
-	case ".jar", ".zip":
+	if _, ok := archiveTypeForPluginOut(pluginOut); ok {
 		dirPath = normalpath.Dir(pluginOut)
 		removePath = normalpath.Base(pluginOut)
-	default:
-		// Assume output is a directory.
 	}
+	// Otherwise, assume output is a directory.
 	bucket, err := c.storageosProvider.NewReadWriteBucket(
 		dirPath,
 		storageos.ReadWriteBucketWithSymlinksIfSupported(),
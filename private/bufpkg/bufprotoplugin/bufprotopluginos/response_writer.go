@@ -15,12 +15,15 @@
 package bufprotopluginos
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/bufbuild/buf/private/bufpkg/bufprotoplugin"
@@ -29,6 +32,7 @@ import (
 	"github.com/bufbuild/buf/private/pkg/storage/storagearchive"
 	"github.com/bufbuild/buf/private/pkg/storage/storagemem"
 	"github.com/bufbuild/buf/private/pkg/storage/storageos"
+	"github.com/bufbuild/buf/private/pkg/syserror"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
@@ -47,6 +51,8 @@ type responseWriter struct {
 	responseWriter    bufprotoplugin.ResponseWriter
 	// If set, create directories if they don't already exist.
 	createOutDirIfNotExists bool
+	// If set, plugins with an out of StdoutPluginOut write a tar stream here.
+	stdout io.Writer
 	// Cache the readWriteBuckets by their respective output paths.
 	// These builders are transformed to storage.ReadBuckets and written
 	// to disk once the responseWriter is flushed.
@@ -84,6 +90,7 @@ func newResponseWriter(
 		storageosProvider:       storageosProvider,
 		responseWriter:          bufprotoplugin.NewResponseWriter(logger),
 		createOutDirIfNotExists: responseWriterOptions.createOutDirIfNotExists,
+		stdout:                  responseWriterOptions.stdout,
 		readWriteBuckets:        make(map[string]storage.ReadWriteBucket),
 	}
 }
@@ -102,9 +109,13 @@ func (w *responseWriter) AddResponse(
 	// For example:
 	//
 	// --insertion-point-receiver_out=insertion --insertion-point-writer_out=./insertion/ --insertion-point_writer_out=/foo/insertion
-	absPluginOut, err := filepath.Abs(normalpath.Unnormalize(pluginOut))
-	if err != nil {
-		return err
+	absPluginOut := StdoutPluginOut
+	if pluginOut != StdoutPluginOut {
+		var err error
+		absPluginOut, err = filepath.Abs(normalpath.Unnormalize(pluginOut))
+		if err != nil {
+			return err
+		}
 	}
 	w.lock.Lock()
 	defer w.lock.Unlock()
@@ -142,40 +153,36 @@ func (w *responseWriter) addResponse(
 	pluginOut string,
 	createOutDirIfNotExists bool,
 ) error {
-	switch filepath.Ext(pluginOut) {
-	case ".jar":
-		return w.writeZip(
+	if pluginOut == StdoutPluginOut {
+		return w.writeStdout(
 			ctx,
 			response,
-			pluginOut,
-			true,
-			createOutDirIfNotExists,
 		)
-	case ".zip":
-		return w.writeZip(
-			ctx,
-			response,
-			pluginOut,
-			false,
-			createOutDirIfNotExists,
-		)
-	default:
-		return w.writeDirectory(
+	}
+	if archiveType, ok := archiveTypeForPluginOut(pluginOut); ok {
+		return w.writeArchive(
 			ctx,
 			response,
 			pluginOut,
+			archiveType,
 			createOutDirIfNotExists,
 		)
 	}
+	return w.writeDirectory(
+		ctx,
+		response,
+		pluginOut,
+		createOutDirIfNotExists,
+	)
 }
 
-func (w *responseWriter) writeZip(
+func (w *responseWriter) writeArchive(
 	ctx context.Context,
 	response *pluginpb.CodeGeneratorResponse,
 	outFilePath string,
-	includeManifest bool,
+	archiveType archiveType,
 	createOutDirIfNotExists bool,
-) (retErr error) {
+) error {
 	outDirPath := filepath.Dir(outFilePath)
 	if readWriteBucket, ok := w.readWriteBuckets[outFilePath]; ok {
 		// We already have a readWriteBucket for this outFilePath, so
@@ -193,21 +200,17 @@ func (w *responseWriter) writeZip(
 	// OK to use os.Stat instead of os.Lstat here.
 	fileInfo, err := os.Stat(outDirPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			if createOutDirIfNotExists {
-				if err := os.MkdirAll(outDirPath, 0755); err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
+		if !os.IsNotExist(err) || !createOutDirIfNotExists {
+			return err
+		}
+		if err := os.MkdirAll(outDirPath, 0755); err != nil {
+			return err
 		}
-		return err
 	} else if !fileInfo.IsDir() {
 		return fmt.Errorf("not a directory: %s", outDirPath)
 	}
 	readWriteBucket := storagemem.NewReadWriteBucket()
-	if includeManifest {
+	if archiveType == archiveTypeJar {
 		if err := storage.PutPath(ctx, readWriteBucket, manifestPath, manifestContent); err != nil {
 			return err
 		}
@@ -225,7 +228,7 @@ func (w *responseWriter) writeZip(
 	w.readWriteBuckets[outFilePath] = readWriteBucket
 	w.closers = append(w.closers, func() (retErr error) {
 		// We're done writing all of the content into this
-		// readWriteBucket, so we zip it when we flush.
+		// readWriteBucket, so we archive it when we flush.
 		file, err := os.Create(outFilePath)
 		if err != nil {
 			return err
@@ -233,8 +236,41 @@ func (w *responseWriter) writeZip(
 		defer func() {
 			retErr = errors.Join(retErr, file.Close())
 		}()
-		// protoc does not compress.
-		return storagearchive.Zip(ctx, readWriteBucket, file, false)
+		return writeArchiveType(ctx, readWriteBucket, file, archiveType)
+	})
+	return nil
+}
+
+func (w *responseWriter) writeStdout(
+	ctx context.Context,
+	response *pluginpb.CodeGeneratorResponse,
+) error {
+	if w.stdout == nil {
+		return errors.New("cannot write plugin output to stdout")
+	}
+	if readWriteBucket, ok := w.readWriteBuckets[StdoutPluginOut]; ok {
+		// We already have a readWriteBucket for stdout, so
+		// we can write to the same bucket.
+		return w.responseWriter.WriteResponse(
+			ctx,
+			readWriteBucket,
+			response,
+			bufprotoplugin.WriteResponseWithInsertionPointReadBucket(readWriteBucket),
+		)
+	}
+	readWriteBucket := storagemem.NewReadWriteBucket()
+	if err := w.responseWriter.WriteResponse(
+		ctx,
+		readWriteBucket,
+		response,
+		bufprotoplugin.WriteResponseWithInsertionPointReadBucket(readWriteBucket),
+	); err != nil {
+		return err
+	}
+	w.readWriteBuckets[StdoutPluginOut] = readWriteBucket
+	w.closers = append(w.closers, func() error {
+		// All plugins that output to stdout share a single tar stream.
+		return storagearchive.Tar(ctx, readWriteBucket, w.stdout)
 	})
 	return nil
 }
@@ -292,8 +328,61 @@ func (w *responseWriter) writeDirectory(
 	return nil
 }
 
+type archiveType int
+
+const (
+	archiveTypeZip archiveType = iota + 1
+	archiveTypeJar
+	archiveTypeTar
+	archiveTypeTarGz
+)
+
+// archiveTypeForPluginOut returns the archiveType for the given plugin out
+// based on its file extension, or false if the plugin out is a directory.
+func archiveTypeForPluginOut(pluginOut string) (archiveType, bool) {
+	switch filepath.Ext(pluginOut) {
+	case ".jar":
+		return archiveTypeJar, true
+	case ".zip":
+		return archiveTypeZip, true
+	case ".tar":
+		return archiveTypeTar, true
+	case ".tgz":
+		return archiveTypeTarGz, true
+	case ".gz":
+		if filepath.Ext(strings.TrimSuffix(pluginOut, ".gz")) == ".tar" {
+			return archiveTypeTarGz, true
+		}
+	}
+	return 0, false
+}
+
+func writeArchiveType(
+	ctx context.Context,
+	readBucket storage.ReadBucket,
+	writer io.Writer,
+	archiveType archiveType,
+) (retErr error) {
+	switch archiveType {
+	case archiveTypeZip, archiveTypeJar:
+		// protoc does not compress.
+		return storagearchive.Zip(ctx, readBucket, writer, false)
+	case archiveTypeTar:
+		return storagearchive.Tar(ctx, readBucket, writer)
+	case archiveTypeTarGz:
+		gzipWriter := gzip.NewWriter(writer)
+		defer func() {
+			retErr = errors.Join(retErr, gzipWriter.Close())
+		}()
+		return storagearchive.Tar(ctx, readBucket, gzipWriter)
+	default:
+		return syserror.Newf("unknown archiveType: %v", archiveType)
+	}
+}
+
 type responseWriterOptions struct {
 	createOutDirIfNotExists bool
+	stdout                  io.Writer
 }
 
 func newResponseWriterOptions() *responseWriterOptions {
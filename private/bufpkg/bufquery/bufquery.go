@@ -0,0 +1,165 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bufquery finds the elements of an Image that match a query.
+//
+// A query is a selector, which is an optional kind followed by filters:
+//
+//	kind[attribute=value]:function(selector)
+//
+// The kind is one of file, message, field, oneof, enum, enum_value, service, method, or
+// extension, or * for any kind, which is the default.
+//
+// Attribute filters compare an attribute of the element with a value, which is a glob
+// pattern as of path.Match, with = or !=. Without a value, [attribute] matches if the
+// attribute is set and not false. The attributes are:
+//
+//   - name: The name of the element, or the path of a file.
+//   - full_name: The fully-qualified name of the element, or the path of a file.
+//   - file: The path of the file of the element.
+//   - package: The package of the element.
+//   - type: The type of a field or extension, such as string or acme.v1.User, or
+//     map<string, acme.v1.User> for maps.
+//   - label: The cardinality of a field or extension, one of optional, required, or repeated.
+//   - number: The number of a field, extension, or enum value.
+//   - input, output: The full names of the input and output messages of a method.
+//
+// Any other attribute is an option, including custom options, such as deprecated,
+// debug_redact, or (buf.validate.field).string.min_len.
+//
+// Function filters match the element with respect to the elements that match the selector
+// given to them:
+//
+//   - has: The element declares a matching element, at any depth.
+//   - in: The element is declared within a matching element, at any depth.
+//   - not: The element does not match the selector.
+//   - type: The message or enum type of a field or extension matches.
+//   - input, output: The input or output message of a method matches.
+//   - reaches: A matching message, enum, or field is reachable from the element through
+//     the types of fields and the input and output messages of methods.
+//
+// For example, the methods that return a message that contains a google.protobuf.Any:
+//
+//	method:output(:reaches(message[full_name=google.protobuf.Any]))
+//
+// And the enums without an UNSPECIFIED value:
+//
+//	enum:not(:has(enum_value[name=*_UNSPECIFIED]))
+package bufquery
+
+import (
+	"context"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufprotosource"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// KindFile is the kind of files.
+	KindFile Kind = iota + 1
+	// KindMessage is the kind of messages.
+	KindMessage
+	// KindField is the kind of the fields of messages.
+	KindField
+	// KindOneof is the kind of oneofs.
+	KindOneof
+	// KindEnum is the kind of enums.
+	KindEnum
+	// KindEnumValue is the kind of enum values.
+	KindEnumValue
+	// KindService is the kind of services.
+	KindService
+	// KindMethod is the kind of methods.
+	KindMethod
+	// KindExtension is the kind of extensions.
+	KindExtension
+)
+
+var (
+	kindToString = map[Kind]string{
+		KindFile:      "file",
+		KindMessage:   "message",
+		KindField:     "field",
+		KindOneof:     "oneof",
+		KindEnum:      "enum",
+		KindEnumValue: "enum_value",
+		KindService:   "service",
+		KindMethod:    "method",
+		KindExtension: "extension",
+	}
+	stringToKind = map[string]Kind{
+		"file":       KindFile,
+		"message":    KindMessage,
+		"field":      KindField,
+		"oneof":      KindOneof,
+		"enum":       KindEnum,
+		"enum_value": KindEnumValue,
+		"service":    KindService,
+		"method":     KindMethod,
+		"extension":  KindExtension,
+	}
+)
+
+// Kind is the kind of an element.
+type Kind int
+
+// String returns the string representation of the Kind.
+func (k Kind) String() string {
+	s, ok := kindToString[k]
+	if !ok {
+		return "unknown"
+	}
+	return s
+}
+
+// Query is a parsed query.
+type Query interface {
+	// String returns the query as given to ParseQuery.
+	String() string
+
+	isQuery()
+}
+
+// ParseQuery parses the query.
+func ParseQuery(query string) (Query, error) {
+	return parseQuery(query)
+}
+
+// Element is an element of an Image that matches a Query.
+type Element interface {
+	// Kind returns the kind of the element.
+	Kind() Kind
+	// FullName returns the fully-qualified name of the element, or the path of a file.
+	FullName() string
+	// ExternalPath returns the external path of the file of the element.
+	ExternalPath() string
+	// Location returns the location of the name of the element.
+	//
+	// Can return nil, for example for files, or if the Image does not have source code info.
+	Location() bufprotosource.Location
+	// Descriptor returns the descriptor of the element.
+	Descriptor() protoreflect.Descriptor
+
+	isElement()
+}
+
+// FindElements returns the elements of the non-import files of the Image that match the
+// Query, in the order they are declared.
+//
+// Imports are not matched themselves, but they are used to evaluate functions such as
+// reaches.
+func FindElements(ctx context.Context, image bufimage.Image, query Query) ([]Element, error) {
+	return findElements(ctx, image, query)
+}
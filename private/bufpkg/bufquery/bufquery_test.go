@@ -0,0 +1,187 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufquery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule/bufmoduletesting"
+	"github.com/bufbuild/buf/private/pkg/slogtestext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindElements(t *testing.T) {
+	t.Parallel()
+	moduleSet, err := bufmoduletesting.NewModuleSetForDirPath("testdata")
+	require.NoError(t, err)
+	image, err := bufimage.BuildImage(
+		context.Background(),
+		slogtestext.NewLogger(t),
+		bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+	)
+	require.NoError(t, err)
+	testFindElements(t, image, "file[name=acme/v1/user.proto]", "file acme/v1/user.proto")
+	testFindElements(
+		t,
+		image,
+		"message:in(file[name=acme/*/user.proto])",
+		"message acme.v1.User",
+		"message acme.v1.User.Detail",
+		"message acme.v1.Address",
+		"message acme.v1.GetUserRequest",
+		"message acme.v1.GetUserResponse",
+	)
+	testFindElements(t, image, "field[debug_redact]", "field acme.v1.User.email")
+	testFindElements(t, image, "field[deprecated=true]", "field acme.v1.User.nickname")
+	testFindElements(t, image, "field[(acme.v1.sensitivity).level=high]", "field acme.v1.User.email")
+	testFindElements(t, image, "field[(acme.v1.sensitivity).tags=pii]", "field acme.v1.User.email")
+	testFindElements(t, image, "field[(acme.v1.sensitivity)]", "field acme.v1.User.email")
+	testFindElements(
+		t,
+		image,
+		"field[type=string]:in(message[name=User])",
+		"field acme.v1.User.id",
+		"field acme.v1.User.email",
+		"field acme.v1.User.phone",
+		"field acme.v1.User.nickname",
+	)
+	testFindElements(t, image, "field[type=map<*>]", "field acme.v1.User.details")
+	testFindElements(t, image, `field[type="map<string, acme.v1.User.Detail>"]`, "field acme.v1.User.details")
+	testFindElements(t, image, "field[label=repeated]", "field acme.v1.Sensitivity.tags", "field acme.v1.User.details")
+	testFindElements(
+		t,
+		image,
+		"oneof",
+		"oneof acme.v1.User.contact",
+	)
+	testFindElements(
+		t,
+		image,
+		"field:in(oneof)",
+		"field acme.v1.User.phone",
+		"field acme.v1.User.address",
+	)
+	testFindElements(t, image, "field:type(enum)", "field acme.v1.User.status")
+	testFindElements(t, image, "enum:not(:has(enum_value[name=*_UNSPECIFIED]))", "enum acme.v1.Role")
+	testFindElements(t, image, "enum_value[number=1]", "enum_value acme.v1.STATUS_ACTIVE")
+	testFindElements(t, image, "message:has(oneof)", "message acme.v1.User")
+	testFindElements(
+		t,
+		image,
+		"method:output(:reaches(message[full_name=google.protobuf.Any]))",
+		"method acme.v1.UserService.GetUser",
+	)
+	testFindElements(t, image, "method:input(message[name=GetUserRequest])[output=acme.v1.Address]", "method acme.v1.UserService.GetAddress")
+	testFindElements(t, image, "service:has(method[name=GetAddress])", "service acme.v1.UserService")
+	testFindElements(t, image, "extension", "extension acme.v1.sensitivity")
+	testFindElements(t, image, "message[name!=*User*]:in(file[package=acme.v1])", "message acme.v1.Sensitivity", "message acme.v1.User.Detail", "message acme.v1.Address")
+	// Imports are not matched.
+	testFindElements(t, image, "message[full_name=google.protobuf.Any]")
+
+	query, err := ParseQuery("field[(acme.v1.unknown)]")
+	require.NoError(t, err)
+	_, err = FindElements(context.Background(), image, query)
+	require.ErrorContains(t, err, `unknown option extension "acme.v1.unknown"`)
+}
+
+func TestFindElementsLocation(t *testing.T) {
+	t.Parallel()
+	moduleSet, err := bufmoduletesting.NewModuleSetForPathToData(
+		map[string][]byte{
+			"a.proto": []byte(`syntax = "proto3";
+package a;
+message A {
+  enum E {
+    E_UNSPECIFIED = 0;
+  }
+}
+`),
+		},
+	)
+	require.NoError(t, err)
+	image, err := bufimage.BuildImage(
+		context.Background(),
+		slogtestext.NewLogger(t),
+		bufmodule.ModuleSetToModuleReadBucketWithOnlyProtoFiles(moduleSet),
+	)
+	require.NoError(t, err)
+	query, err := ParseQuery("*")
+	require.NoError(t, err)
+	elements, err := FindElements(context.Background(), image, query)
+	require.NoError(t, err)
+	var locations []string
+	for _, element := range elements {
+		location := "<none>"
+		if element.Location() != nil {
+			location = fmt.Sprintf("%d:%d", element.Location().StartLine(), element.Location().StartColumn())
+		}
+		locations = append(locations, fmt.Sprintf("%s %s %s", element.Kind(), element.FullName(), location))
+	}
+	require.Equal(
+		t,
+		[]string{
+			"file a.proto <none>",
+			"message a.A 3:9",
+			"enum a.A.E 4:8",
+			"enum_value a.A.E_UNSPECIFIED 5:5",
+		},
+		locations,
+	)
+}
+
+func TestParseQueryError(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		query         string
+		expectedError string
+	}{
+		{query: "", expectedError: "query is empty"},
+		{query: "mesage", expectedError: `invalid query at offset 0: unknown kind "mesage"`},
+		{query: "message[", expectedError: "invalid query at offset 8: unexpected end of query, expected attribute"},
+		{query: "message[name=A", expectedError: `invalid query at offset 14: unexpected end of query, expected "]"`},
+		{query: `message[name="A]`, expectedError: "invalid query at offset 13: unterminated quoted value"},
+		{query: "message[name=[]", expectedError: `invalid query at offset 13: invalid pattern "["`},
+		{query: "message[(a.b]", expectedError: `invalid query at offset 8: invalid option "(a.b"`},
+		{query: "message:foo(*)", expectedError: `invalid query at offset 8: unknown function "foo"`},
+		{query: "message:has(field", expectedError: `invalid query at offset 17: unexpected end of query, expected ")"`},
+		{query: "message field", expectedError: `invalid query at offset 8: unexpected 'f'`},
+	} {
+		t.Run(testCase.query, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseQuery(testCase.query)
+			require.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func testFindElements(t *testing.T, image bufimage.Image, queryString string, expectedElements ...string) {
+	query, err := ParseQuery(queryString)
+	require.NoError(t, err)
+	require.Equal(t, queryString, query.String())
+	elements, err := FindElements(context.Background(), image, query)
+	require.NoError(t, err)
+	actualElements := make([]string, len(elements))
+	for i, element := range elements {
+		actualElements[i] = element.Kind().String() + " " + element.FullName()
+	}
+	if len(expectedElements) == 0 {
+		expectedElements = []string{}
+	}
+	require.Equal(t, expectedElements, actualElements, queryString)
+}
@@ -0,0 +1,687 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufquery
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufimage"
+	"github.com/bufbuild/buf/private/bufpkg/bufprotosource"
+	"github.com/bufbuild/buf/private/pkg/protoencoding"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	attributeNameName     = "name"
+	attributeNameFullName = "full_name"
+	attributeNameFile     = "file"
+	attributeNamePackage  = "package"
+	attributeNameType     = "type"
+	attributeNameLabel    = "label"
+	attributeNameNumber   = "number"
+	attributeNameInput    = "input"
+	attributeNameOutput   = "output"
+)
+
+var attributeNames = map[string]struct{}{
+	attributeNameName:     {},
+	attributeNameFullName: {},
+	attributeNameFile:     {},
+	attributeNamePackage:  {},
+	attributeNameType:     {},
+	attributeNameLabel:    {},
+	attributeNameNumber:   {},
+	attributeNameInput:    {},
+	attributeNameOutput:   {},
+}
+
+type element struct {
+	kind         Kind
+	descriptor   protoreflect.Descriptor
+	externalPath string
+	location     bufprotosource.Location
+}
+
+func (e *element) Kind() Kind {
+	return e.kind
+}
+
+func (e *element) FullName() string {
+	return getFullName(e.descriptor)
+}
+
+func (e *element) ExternalPath() string {
+	return e.externalPath
+}
+
+func (e *element) Location() bufprotosource.Location {
+	return e.location
+}
+
+func (e *element) Descriptor() protoreflect.Descriptor {
+	return e.descriptor
+}
+
+func (*element) isElement() {}
+
+func findElements(ctx context.Context, image bufimage.Image, q Query) ([]Element, error) {
+	query, ok := q.(*query)
+	if !ok {
+		return nil, fmt.Errorf("unknown Query type: %T", q)
+	}
+	resolver := image.Resolver()
+	files, err := bufprotosource.NewFiles(ctx, image.Files(), resolver)
+	if err != nil {
+		return nil, err
+	}
+	evaluator := newEvaluator(resolver)
+	var elements []Element
+	for _, file := range files {
+		if file.IsImport() {
+			continue
+		}
+		fileDescriptor, err := resolver.FindFileByPath(file.Path())
+		if err != nil {
+			return nil, err
+		}
+		keyToLocation := getKeyToLocation(file)
+		if err := walkDescriptor(
+			fileDescriptor,
+			func(descriptor protoreflect.Descriptor) error {
+				matches, err := evaluator.matches(query.selector, descriptor)
+				if err != nil || !matches {
+					return err
+				}
+				kind := getKind(descriptor)
+				elements = append(
+					elements,
+					&element{
+						kind:         kind,
+						descriptor:   descriptor,
+						externalPath: file.ExternalPath(),
+						location:     keyToLocation[newLocationKey(kind, descriptor)],
+					},
+				)
+				return nil
+			},
+		); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+type evaluator struct {
+	resolver protoencoding.Resolver
+	// cache is the result of matching a selector with a descriptor, as functions such as
+	// reaches would otherwise evaluate the same selectors many times.
+	cache               map[matchKey]bool
+	descriptorToOptions map[protoreflect.Descriptor]protoreflect.Message
+	extensionNameToType map[string]protoreflect.ExtensionType
+}
+
+type matchKey struct {
+	selector   *selector
+	descriptor protoreflect.Descriptor
+}
+
+func newEvaluator(resolver protoencoding.Resolver) *evaluator {
+	return &evaluator{
+		resolver:            resolver,
+		cache:               make(map[matchKey]bool),
+		descriptorToOptions: make(map[protoreflect.Descriptor]protoreflect.Message),
+		extensionNameToType: make(map[string]protoreflect.ExtensionType),
+	}
+}
+
+func (e *evaluator) matches(selector *selector, descriptor protoreflect.Descriptor) (bool, error) {
+	key := matchKey{selector: selector, descriptor: descriptor}
+	if matches, ok := e.cache[key]; ok {
+		return matches, nil
+	}
+	matches, err := e.matchesUncached(selector, descriptor)
+	if err != nil {
+		return false, err
+	}
+	e.cache[key] = matches
+	return matches, nil
+}
+
+func (e *evaluator) matchesUncached(selector *selector, descriptor protoreflect.Descriptor) (bool, error) {
+	if selector.kind != 0 && selector.kind != getKind(descriptor) {
+		return false, nil
+	}
+	for _, filter := range selector.filters {
+		var matches bool
+		var err error
+		switch filter := filter.(type) {
+		case *attributeFilter:
+			matches, err = e.matchesAttributeFilter(filter, descriptor)
+		case *functionFilter:
+			matches, err = e.matchesFunctionFilter(filter, descriptor)
+		default:
+			return false, fmt.Errorf("unknown filter type: %T", filter)
+		}
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (e *evaluator) matchesAttributeFilter(filter *attributeFilter, descriptor protoreflect.Descriptor) (bool, error) {
+	var values []string
+	if filter.optionPath != nil {
+		var err error
+		values, err = e.getOptionValues(filter.optionPath, descriptor)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		values = getAttributeValues(filter.key, descriptor)
+	}
+	if !filter.hasValue {
+		for _, value := range values {
+			if value != "" && value != "false" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if len(values) == 0 {
+		return false, nil
+	}
+	for _, value := range values {
+		// The pattern was validated when parsing.
+		if matches, _ := path.Match(filter.pattern, value); matches {
+			return !filter.negated, nil
+		}
+	}
+	return filter.negated, nil
+}
+
+func (e *evaluator) matchesFunctionFilter(filter *functionFilter, descriptor protoreflect.Descriptor) (bool, error) {
+	switch filter.name {
+	case functionNameHas:
+		var matches bool
+		for _, child := range getChildren(descriptor) {
+			if err := walkDescriptor(
+				child,
+				func(descendant protoreflect.Descriptor) error {
+					if matches {
+						return nil
+					}
+					var err error
+					matches, err = e.matches(filter.selector, descendant)
+					return err
+				},
+			); err != nil {
+				return false, err
+			}
+		}
+		return matches, nil
+	case functionNameIn:
+		for parent := getParent(descriptor); parent != nil; parent = getParent(parent) {
+			if matches, err := e.matches(filter.selector, parent); err != nil || matches {
+				return matches, err
+			}
+		}
+		return false, nil
+	case functionNameNot:
+		matches, err := e.matches(filter.selector, descriptor)
+		return !matches, err
+	case functionNameType:
+		fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor)
+		if !ok {
+			return false, nil
+		}
+		if messageDescriptor := fieldDescriptor.Message(); messageDescriptor != nil && !messageDescriptor.IsMapEntry() {
+			return e.matches(filter.selector, messageDescriptor)
+		}
+		if enumDescriptor := fieldDescriptor.Enum(); enumDescriptor != nil {
+			return e.matches(filter.selector, enumDescriptor)
+		}
+		return false, nil
+	case functionNameInput, functionNameOutput:
+		methodDescriptor, ok := descriptor.(protoreflect.MethodDescriptor)
+		if !ok {
+			return false, nil
+		}
+		if filter.name == functionNameInput {
+			return e.matches(filter.selector, methodDescriptor.Input())
+		}
+		return e.matches(filter.selector, methodDescriptor.Output())
+	case functionNameReaches:
+		return e.reaches(filter.selector, descriptor)
+	default:
+		return false, fmt.Errorf("unknown function: %q", filter.name)
+	}
+}
+
+// reaches returns true if an element that matches the selector is reachable from the
+// descriptor, not including the descriptor itself.
+func (e *evaluator) reaches(selector *selector, descriptor protoreflect.Descriptor) (bool, error) {
+	seen := map[protoreflect.Descriptor]struct{}{
+		descriptor: {},
+	}
+	queue := getReferences(descriptor)
+	for len(queue) > 0 {
+		reference := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[reference]; ok {
+			continue
+		}
+		seen[reference] = struct{}{}
+		// Map entries are traversed, but are not elements themselves.
+		if messageDescriptor, ok := reference.(protoreflect.MessageDescriptor); !ok || !messageDescriptor.IsMapEntry() {
+			matches, err := e.matches(selector, reference)
+			if err != nil || matches {
+				return matches, err
+			}
+		}
+		queue = append(queue, getReferences(reference)...)
+	}
+	return false, nil
+}
+
+// getOptionValues returns the values of the option at the path for the descriptor.
+//
+// Repeated options have a value for each element.
+func (e *evaluator) getOptionValues(optionPath []optionPathSegment, descriptor protoreflect.Descriptor) ([]string, error) {
+	options, err := e.getOptions(descriptor)
+	if err != nil || options == nil {
+		return nil, err
+	}
+	messages := []protoreflect.Message{options}
+	var values []protoreflect.Value
+	var fieldDescriptor protoreflect.FieldDescriptor
+	for i, segment := range optionPath {
+		if i > 0 {
+			if fieldDescriptor.Message() == nil {
+				return nil, nil
+			}
+			messages = messages[:0]
+			for _, value := range values {
+				messages = append(messages, value.Message())
+			}
+		}
+		if len(messages) == 0 {
+			return nil, nil
+		}
+		messageDescriptor := messages[0].Descriptor()
+		if segment.isExtension {
+			extensionType, err := e.getExtensionType(segment.name)
+			if err != nil {
+				return nil, err
+			}
+			fieldDescriptor = extensionType.TypeDescriptor()
+			if fieldDescriptor.ContainingMessage().FullName() != messageDescriptor.FullName() {
+				return nil, nil
+			}
+		} else {
+			fieldDescriptor = messageDescriptor.Fields().ByName(protoreflect.Name(segment.name))
+			if fieldDescriptor == nil {
+				return nil, nil
+			}
+		}
+		values = values[:0]
+		for _, message := range messages {
+			if !message.Has(fieldDescriptor) {
+				continue
+			}
+			value := message.Get(fieldDescriptor)
+			if fieldDescriptor.IsList() {
+				list := value.List()
+				for j := 0; j < list.Len(); j++ {
+					values = append(values, list.Get(j))
+				}
+				continue
+			}
+			values = append(values, value)
+		}
+	}
+	formattedValues := make([]string, len(values))
+	for i, value := range values {
+		formattedValues[i] = formatOptionValue(fieldDescriptor, value)
+	}
+	return formattedValues, nil
+}
+
+// getOptions returns the options of the descriptor, with the custom options parsed.
+//
+// Returns nil if the descriptor has no options.
+func (e *evaluator) getOptions(descriptor protoreflect.Descriptor) (protoreflect.Message, error) {
+	if options, ok := e.descriptorToOptions[descriptor]; ok {
+		return options, nil
+	}
+	var options protoreflect.Message
+	if message := descriptor.Options(); message != nil && message.ProtoReflect().IsValid() {
+		options = proto.Clone(message).ProtoReflect()
+		if err := protoencoding.ReparseExtensions(e.resolver, options); err != nil {
+			return nil, err
+		}
+	}
+	e.descriptorToOptions[descriptor] = options
+	return options, nil
+}
+
+func (e *evaluator) getExtensionType(name string) (protoreflect.ExtensionType, error) {
+	if extensionType, ok := e.extensionNameToType[name]; ok {
+		return extensionType, nil
+	}
+	extensionType, err := e.resolver.FindExtensionByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("unknown option extension %q: %w", name, err)
+	}
+	e.extensionNameToType[name] = extensionType
+	return extensionType, nil
+}
+
+// getAttributeValues returns the values of the attribute for the descriptor, which are
+// empty if the attribute does not apply to the kind of the descriptor.
+func getAttributeValues(name string, descriptor protoreflect.Descriptor) []string {
+	switch name {
+	case attributeNameName:
+		if fileDescriptor, ok := descriptor.(protoreflect.FileDescriptor); ok {
+			return []string{fileDescriptor.Path()}
+		}
+		return []string{string(descriptor.Name())}
+	case attributeNameFullName:
+		return []string{getFullName(descriptor)}
+	case attributeNameFile:
+		return []string{descriptor.ParentFile().Path()}
+	case attributeNamePackage:
+		return []string{string(descriptor.ParentFile().Package())}
+	case attributeNameType:
+		if fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor); ok {
+			return []string{getFieldType(fieldDescriptor)}
+		}
+	case attributeNameLabel:
+		if fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor); ok {
+			return []string{fieldDescriptor.Cardinality().String()}
+		}
+	case attributeNameNumber:
+		switch descriptor := descriptor.(type) {
+		case protoreflect.FieldDescriptor:
+			return []string{strconv.Itoa(int(descriptor.Number()))}
+		case protoreflect.EnumValueDescriptor:
+			return []string{strconv.Itoa(int(descriptor.Number()))}
+		}
+	case attributeNameInput:
+		if methodDescriptor, ok := descriptor.(protoreflect.MethodDescriptor); ok {
+			return []string{string(methodDescriptor.Input().FullName())}
+		}
+	case attributeNameOutput:
+		if methodDescriptor, ok := descriptor.(protoreflect.MethodDescriptor); ok {
+			return []string{string(methodDescriptor.Output().FullName())}
+		}
+	}
+	return nil
+}
+
+// getFieldType returns the type of the field, such as string, acme.v1.User, or
+// map<string, acme.v1.User>.
+func getFieldType(fieldDescriptor protoreflect.FieldDescriptor) string {
+	if fieldDescriptor.IsMap() {
+		return fmt.Sprintf(
+			"map<%s, %s>",
+			getFieldType(fieldDescriptor.MapKey()),
+			getFieldType(fieldDescriptor.MapValue()),
+		)
+	}
+	if messageDescriptor := fieldDescriptor.Message(); messageDescriptor != nil {
+		return string(messageDescriptor.FullName())
+	}
+	if enumDescriptor := fieldDescriptor.Enum(); enumDescriptor != nil {
+		return string(enumDescriptor.FullName())
+	}
+	return fieldDescriptor.Kind().String()
+}
+
+func formatOptionValue(fieldDescriptor protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch fieldDescriptor.Kind() {
+	case protoreflect.EnumKind:
+		if enumValueDescriptor := fieldDescriptor.Enum().Values().ByNumber(value.Enum()); enumValueDescriptor != nil {
+			return string(enumValueDescriptor.Name())
+		}
+		return strconv.Itoa(int(value.Enum()))
+	case protoreflect.BytesKind:
+		return string(value.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "{" + prototext.MarshalOptions{}.Format(value.Message().Interface()) + "}"
+	default:
+		return value.String()
+	}
+}
+
+// walkDescriptor calls f for the descriptor and its descendants, in the order they are
+// declared.
+func walkDescriptor(descriptor protoreflect.Descriptor, f func(protoreflect.Descriptor) error) error {
+	if err := f(descriptor); err != nil {
+		return err
+	}
+	for _, child := range getChildren(descriptor) {
+		if err := walkDescriptor(child, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getChildren returns the elements directly declared within the descriptor.
+//
+// The fields of a oneof are children of the oneof, which is declared at its first field.
+// Map entries and synthetic oneofs are not elements.
+func getChildren(descriptor protoreflect.Descriptor) []protoreflect.Descriptor {
+	var children []protoreflect.Descriptor
+	switch descriptor := descriptor.(type) {
+	case protoreflect.FileDescriptor:
+		children = appendMessages(children, descriptor.Messages())
+		children = appendEnums(children, descriptor.Enums())
+		for i := 0; i < descriptor.Services().Len(); i++ {
+			children = append(children, descriptor.Services().Get(i))
+		}
+		children = appendExtensions(children, descriptor.Extensions())
+	case protoreflect.MessageDescriptor:
+		for i := 0; i < descriptor.Fields().Len(); i++ {
+			fieldDescriptor := descriptor.Fields().Get(i)
+			oneofDescriptor := fieldDescriptor.ContainingOneof()
+			if oneofDescriptor == nil || oneofDescriptor.IsSynthetic() {
+				children = append(children, fieldDescriptor)
+				continue
+			}
+			if oneofDescriptor.Fields().Get(0) == fieldDescriptor {
+				children = append(children, oneofDescriptor)
+			}
+		}
+		children = appendMessages(children, descriptor.Messages())
+		children = appendEnums(children, descriptor.Enums())
+		children = appendExtensions(children, descriptor.Extensions())
+	case protoreflect.OneofDescriptor:
+		for i := 0; i < descriptor.Fields().Len(); i++ {
+			children = append(children, descriptor.Fields().Get(i))
+		}
+	case protoreflect.EnumDescriptor:
+		for i := 0; i < descriptor.Values().Len(); i++ {
+			children = append(children, descriptor.Values().Get(i))
+		}
+	case protoreflect.ServiceDescriptor:
+		for i := 0; i < descriptor.Methods().Len(); i++ {
+			children = append(children, descriptor.Methods().Get(i))
+		}
+	}
+	return children
+}
+
+// getParent returns the element the descriptor is declared within, or nil for files.
+func getParent(descriptor protoreflect.Descriptor) protoreflect.Descriptor {
+	if fieldDescriptor, ok := descriptor.(protoreflect.FieldDescriptor); ok {
+		if oneofDescriptor := fieldDescriptor.ContainingOneof(); oneofDescriptor != nil && !oneofDescriptor.IsSynthetic() {
+			return oneofDescriptor
+		}
+	}
+	return descriptor.Parent()
+}
+
+// getReferences returns the elements directly reachable from the descriptor.
+func getReferences(descriptor protoreflect.Descriptor) []protoreflect.Descriptor {
+	switch descriptor := descriptor.(type) {
+	case protoreflect.MessageDescriptor:
+		references := make([]protoreflect.Descriptor, 0, descriptor.Fields().Len())
+		for i := 0; i < descriptor.Fields().Len(); i++ {
+			references = append(references, descriptor.Fields().Get(i))
+		}
+		return references
+	case protoreflect.FieldDescriptor:
+		if messageDescriptor := descriptor.Message(); messageDescriptor != nil {
+			return []protoreflect.Descriptor{messageDescriptor}
+		}
+		if enumDescriptor := descriptor.Enum(); enumDescriptor != nil {
+			return []protoreflect.Descriptor{enumDescriptor}
+		}
+		return nil
+	case protoreflect.MethodDescriptor:
+		return []protoreflect.Descriptor{descriptor.Input(), descriptor.Output()}
+	default:
+		return getChildren(descriptor)
+	}
+}
+
+func appendMessages(descriptors []protoreflect.Descriptor, messages protoreflect.MessageDescriptors) []protoreflect.Descriptor {
+	for i := 0; i < messages.Len(); i++ {
+		if messageDescriptor := messages.Get(i); !messageDescriptor.IsMapEntry() {
+			descriptors = append(descriptors, messageDescriptor)
+		}
+	}
+	return descriptors
+}
+
+func appendEnums(descriptors []protoreflect.Descriptor, enums protoreflect.EnumDescriptors) []protoreflect.Descriptor {
+	for i := 0; i < enums.Len(); i++ {
+		descriptors = append(descriptors, enums.Get(i))
+	}
+	return descriptors
+}
+
+func appendExtensions(descriptors []protoreflect.Descriptor, extensions protoreflect.ExtensionDescriptors) []protoreflect.Descriptor {
+	for i := 0; i < extensions.Len(); i++ {
+		descriptors = append(descriptors, extensions.Get(i))
+	}
+	return descriptors
+}
+
+func getKind(descriptor protoreflect.Descriptor) Kind {
+	switch descriptor := descriptor.(type) {
+	case protoreflect.FileDescriptor:
+		return KindFile
+	case protoreflect.MessageDescriptor:
+		return KindMessage
+	case protoreflect.FieldDescriptor:
+		if descriptor.IsExtension() {
+			return KindExtension
+		}
+		return KindField
+	case protoreflect.OneofDescriptor:
+		return KindOneof
+	case protoreflect.EnumDescriptor:
+		return KindEnum
+	case protoreflect.EnumValueDescriptor:
+		return KindEnumValue
+	case protoreflect.ServiceDescriptor:
+		return KindService
+	case protoreflect.MethodDescriptor:
+		return KindMethod
+	default:
+		return 0
+	}
+}
+
+func getFullName(descriptor protoreflect.Descriptor) string {
+	if fileDescriptor, ok := descriptor.(protoreflect.FileDescriptor); ok {
+		return fileDescriptor.Path()
+	}
+	return string(descriptor.FullName())
+}
+
+// locationKey identifies an element of a file for its location.
+//
+// Enum values are identified by the full name of their enum, as their full names are
+// scoped to the parent of their enum.
+type locationKey struct {
+	kind     Kind
+	fullName string
+}
+
+func newLocationKey(kind Kind, descriptor protoreflect.Descriptor) locationKey {
+	fullName := getFullName(descriptor)
+	if enumValueDescriptor, ok := descriptor.(protoreflect.EnumValueDescriptor); ok {
+		fullName = string(enumValueDescriptor.Parent().FullName()) + "." + string(enumValueDescriptor.Name())
+	}
+	return locationKey{kind: kind, fullName: fullName}
+}
+
+// getKeyToLocation returns the locations of the names of the elements of the file.
+func getKeyToLocation(file bufprotosource.File) map[locationKey]bufprotosource.Location {
+	keyToLocation := make(map[locationKey]bufprotosource.Location)
+	add := func(kind Kind, namedDescriptor bufprotosource.NamedDescriptor) {
+		if location := namedDescriptor.NameLocation(); location != nil {
+			keyToLocation[locationKey{kind: kind, fullName: namedDescriptor.FullName()}] = location
+		}
+	}
+	addExtensions := func(extensions []bufprotosource.Field) {
+		for _, extension := range extensions {
+			add(KindExtension, extension)
+		}
+	}
+	var addEnums func([]bufprotosource.Enum)
+	addEnums = func(enums []bufprotosource.Enum) {
+		for _, enum := range enums {
+			add(KindEnum, enum)
+			for _, enumValue := range enum.Values() {
+				add(KindEnumValue, enumValue)
+			}
+		}
+	}
+	var addMessages func([]bufprotosource.Message)
+	addMessages = func(messages []bufprotosource.Message) {
+		for _, message := range messages {
+			add(KindMessage, message)
+			for _, field := range message.Fields() {
+				add(KindField, field)
+			}
+			for _, oneof := range message.Oneofs() {
+				add(KindOneof, oneof)
+			}
+			addMessages(message.Messages())
+			addEnums(message.Enums())
+			addExtensions(message.Extensions())
+		}
+	}
+	addMessages(file.Messages())
+	addEnums(file.Enums())
+	for _, service := range file.Services() {
+		add(KindService, service)
+		for _, method := range service.Methods() {
+			add(KindMethod, method)
+		}
+	}
+	addExtensions(file.Extensions())
+	return keyToLocation
+}
@@ -0,0 +1,357 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bufquery
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	functionNameHas     = "has"
+	functionNameIn      = "in"
+	functionNameNot     = "not"
+	functionNameType    = "type"
+	functionNameInput   = "input"
+	functionNameOutput  = "output"
+	functionNameReaches = "reaches"
+)
+
+var allFunctionNames = map[string]struct{}{
+	functionNameHas:     {},
+	functionNameIn:      {},
+	functionNameNot:     {},
+	functionNameType:    {},
+	functionNameInput:   {},
+	functionNameOutput:  {},
+	functionNameReaches: {},
+}
+
+type query struct {
+	value    string
+	selector *selector
+}
+
+func (q *query) String() string {
+	return q.value
+}
+
+func (*query) isQuery() {}
+
+// selector matches elements of the given kind that match all the filters.
+//
+// A zero kind matches elements of any kind.
+type selector struct {
+	kind    Kind
+	filters []filter
+}
+
+// filter is either an *attributeFilter or a *functionFilter.
+type filter interface {
+	isFilter()
+}
+
+type attributeFilter struct {
+	key string
+	// optionPath is the parsed key if the key is not a known attribute.
+	optionPath []optionPathSegment
+	// hasValue is false for [key].
+	hasValue bool
+	negated  bool
+	pattern  string
+}
+
+func (*attributeFilter) isFilter() {}
+
+type optionPathSegment struct {
+	name        string
+	isExtension bool
+}
+
+type functionFilter struct {
+	name     string
+	selector *selector
+}
+
+func (*functionFilter) isFilter() {}
+
+func parseQuery(value string) (*query, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, errors.New("query is empty")
+	}
+	parser := &parser{value: value}
+	selector, err := parser.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	parser.skipSpaces()
+	if !parser.done() {
+		return nil, parser.newErrorf("unexpected %q", parser.value[parser.offset])
+	}
+	return &query{
+		value:    value,
+		selector: selector,
+	}, nil
+}
+
+type parser struct {
+	value  string
+	offset int
+}
+
+// parseSelector parses a selector until the end of the value or a closing parenthesis.
+func (p *parser) parseSelector() (*selector, error) {
+	selector := &selector{}
+	p.skipSpaces()
+	if p.consume('*') {
+		// Any kind.
+	} else if identifier := p.parseIdentifier(); identifier != "" {
+		kind, ok := stringToKind[identifier]
+		if !ok {
+			return nil, newParseError(p.offset-len(identifier), fmt.Sprintf("unknown kind %q", identifier))
+		}
+		selector.kind = kind
+	}
+	for {
+		p.skipSpaces()
+		switch {
+		case p.consume('['):
+			attributeFilter, err := p.parseAttributeFilter()
+			if err != nil {
+				return nil, err
+			}
+			selector.filters = append(selector.filters, attributeFilter)
+		case p.consume(':'):
+			functionFilter, err := p.parseFunctionFilter()
+			if err != nil {
+				return nil, err
+			}
+			selector.filters = append(selector.filters, functionFilter)
+		default:
+			return selector, nil
+		}
+	}
+}
+
+// parseAttributeFilter parses an attribute filter after the opening bracket.
+func (p *parser) parseAttributeFilter() (*attributeFilter, error) {
+	p.skipSpaces()
+	keyOffset := p.offset
+	key := p.parseKey()
+	if key == "" {
+		return nil, p.newErrorf("expected attribute")
+	}
+	attributeFilter := &attributeFilter{key: key}
+	if _, ok := attributeNames[key]; !ok {
+		optionPath, err := parseOptionPath(key)
+		if err != nil {
+			return nil, newParseError(keyOffset, err.Error())
+		}
+		attributeFilter.optionPath = optionPath
+	}
+	p.skipSpaces()
+	if p.consume(']') {
+		return attributeFilter, nil
+	}
+	if p.consume('!') {
+		attributeFilter.negated = true
+	}
+	if !p.consume('=') {
+		return nil, p.newErrorf("expected \"=\", \"!=\", or \"]\"")
+	}
+	attributeFilter.hasValue = true
+	p.skipSpaces()
+	valueOffset := p.offset
+	pattern, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, newParseError(valueOffset, fmt.Sprintf("invalid pattern %q", pattern))
+	}
+	attributeFilter.pattern = pattern
+	p.skipSpaces()
+	if !p.consume(']') {
+		return nil, p.newErrorf("expected \"]\"")
+	}
+	return attributeFilter, nil
+}
+
+// parseFunctionFilter parses a function filter after the colon.
+func (p *parser) parseFunctionFilter() (*functionFilter, error) {
+	name := p.parseIdentifier()
+	if name == "" {
+		return nil, p.newErrorf("expected function")
+	}
+	if _, ok := allFunctionNames[name]; !ok {
+		return nil, newParseError(p.offset-len(name), fmt.Sprintf("unknown function %q", name))
+	}
+	if !p.consume('(') {
+		return nil, p.newErrorf("expected \"(\"")
+	}
+	selector, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if !p.consume(')') {
+		return nil, p.newErrorf("expected \")\"")
+	}
+	return &functionFilter{
+		name:     name,
+		selector: selector,
+	}, nil
+}
+
+func (p *parser) parseIdentifier() string {
+	start := p.offset
+	for !p.done() && isIdentifierByte(p.value[p.offset]) {
+		p.offset++
+	}
+	return p.value[start:p.offset]
+}
+
+// parseKey parses an attribute, which is an identifier or an option path such as
+// (buf.validate.field).string.min_len.
+func (p *parser) parseKey() string {
+	start := p.offset
+	inParentheses := false
+	for !p.done() {
+		c := p.value[p.offset]
+		switch {
+		case c == '(':
+			inParentheses = true
+		case c == ')':
+			if !inParentheses {
+				return p.value[start:p.offset]
+			}
+			inParentheses = false
+		case c == '.' || isIdentifierByte(c):
+		default:
+			return p.value[start:p.offset]
+		}
+		p.offset++
+	}
+	return p.value[start:p.offset]
+}
+
+// parseValue parses a quoted value, or an unquoted value until the closing bracket.
+func (p *parser) parseValue() (string, error) {
+	start := p.offset
+	if p.consume('"') {
+		for !p.done() {
+			switch p.value[p.offset] {
+			case '\\':
+				p.offset += 2
+				continue
+			case '"':
+				p.offset++
+				value, err := strconv.Unquote(p.value[start:p.offset])
+				if err != nil {
+					return "", newParseError(start, fmt.Sprintf("invalid quoted value %s", p.value[start:p.offset]))
+				}
+				return value, nil
+			}
+			p.offset++
+		}
+		return "", newParseError(start, "unterminated quoted value")
+	}
+	end := strings.IndexByte(p.value[start:], ']')
+	if end < 0 {
+		p.offset = len(p.value)
+		return "", p.newErrorf("expected \"]\"")
+	}
+	p.offset = start + end
+	return strings.TrimSpace(p.value[start:p.offset]), nil
+}
+
+func (p *parser) skipSpaces() {
+	for !p.done() && isSpaceByte(p.value[p.offset]) {
+		p.offset++
+	}
+}
+
+func (p *parser) consume(c byte) bool {
+	if !p.done() && p.value[p.offset] == c {
+		p.offset++
+		return true
+	}
+	return false
+}
+
+func (p *parser) done() bool {
+	return p.offset >= len(p.value)
+}
+
+func (p *parser) newErrorf(format string, args ...interface{}) error {
+	if p.done() {
+		return newParseError(p.offset, "unexpected end of query, "+fmt.Sprintf(format, args...))
+	}
+	return newParseError(p.offset, fmt.Sprintf(format, args...))
+}
+
+// parseOptionPath parses an option path such as deprecated or (buf.validate.field).string.min_len.
+func parseOptionPath(key string) ([]optionPathSegment, error) {
+	var optionPath []optionPathSegment
+	for remaining := key; remaining != ""; {
+		var segment optionPathSegment
+		if strings.HasPrefix(remaining, "(") {
+			end := strings.IndexByte(remaining, ')')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid option %q", key)
+			}
+			segment = optionPathSegment{
+				name:        strings.TrimPrefix(remaining[1:end], "."),
+				isExtension: true,
+			}
+			remaining = remaining[end+1:]
+		} else {
+			end := strings.IndexByte(remaining, '.')
+			if end < 0 {
+				end = len(remaining)
+			}
+			segment = optionPathSegment{
+				name: remaining[:end],
+			}
+			remaining = remaining[end:]
+		}
+		if segment.name == "" || strings.ContainsAny(segment.name, "()") {
+			return nil, fmt.Errorf("invalid option %q", key)
+		}
+		optionPath = append(optionPath, segment)
+		if remaining != "" {
+			if !strings.HasPrefix(remaining, ".") || len(remaining) == 1 {
+				return nil, fmt.Errorf("invalid option %q", key)
+			}
+			remaining = remaining[1:]
+		}
+	}
+	return optionPath, nil
+}
+
+func newParseError(offset int, message string) error {
+	return fmt.Errorf("invalid query at offset %d: %s", offset, message)
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
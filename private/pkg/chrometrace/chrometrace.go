@@ -0,0 +1,211 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chrometrace records timelines in the Chrome trace event format.
+//
+// The output can be loaded into chrome://tracing, https://ui.perfetto.dev, or
+// any other viewer that understands the trace event format.
+//
+// See https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU.
+package chrometrace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recorder records events.
+//
+// All functions are safe to call concurrently.
+type Recorder interface {
+	// Start starts a new event with the given name and category on the given thread.
+	//
+	// Events on the same thread should be strictly nested, otherwise viewers may not
+	// display them correctly. End must be called on the returned Event for it to be recorded.
+	Start(name string, category string, threadID int) Event
+	// SetThreadName sets the display name of the given thread.
+	SetThreadName(threadID int, name string)
+	// Write writes all ended events as JSON to the writer.
+	//
+	// Events that have not ended are not written.
+	Write(writer io.Writer) error
+}
+
+// NewRecorder returns a new Recorder.
+//
+// Timestamps are relative to the time the Recorder was created.
+func NewRecorder() Recorder {
+	return newRecorder()
+}
+
+// NewNopRecorder returns a new Recorder that does not record anything.
+//
+// Write writes an empty trace.
+func NewNopRecorder() Recorder {
+	return nopRecorder{}
+}
+
+// Event is a started event.
+type Event interface {
+	// SetArg sets an argument that is displayed with the event.
+	//
+	// The value must be JSON-serializable.
+	SetArg(key string, value any)
+	// End ends the event and records it.
+	//
+	// Calls after the first call have no effect.
+	End()
+}
+
+// *** PRIVATE ***
+
+const pid = 1
+
+type recorder struct {
+	start  time.Time
+	events []*externalEvent
+	lock   sync.Mutex
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		start: time.Now(),
+	}
+}
+
+func (r *recorder) Start(name string, category string, threadID int) Event {
+	return &event{
+		recorder: r,
+		externalEvent: &externalEvent{
+			Name:      name,
+			Category:  category,
+			Phase:     "X",
+			Timestamp: r.sinceStart(time.Now()),
+			PID:       pid,
+			TID:       threadID,
+		},
+	}
+}
+
+func (r *recorder) SetThreadName(threadID int, name string) {
+	r.add(
+		&externalEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			PID:   pid,
+			TID:   threadID,
+			Args: map[string]any{
+				"name": name,
+			},
+		},
+	)
+}
+
+func (r *recorder) Write(writer io.Writer) error {
+	r.lock.Lock()
+	events := make([]*externalEvent, len(r.events))
+	copy(events, r.events)
+	r.lock.Unlock()
+	return writeTrace(writer, events)
+}
+
+func (r *recorder) add(externalEvent *externalEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, externalEvent)
+}
+
+// sinceStart returns the microseconds since the start of the recorder.
+func (r *recorder) sinceStart(t time.Time) int64 {
+	return t.Sub(r.start).Microseconds()
+}
+
+type event struct {
+	recorder      *recorder
+	externalEvent *externalEvent
+	once          sync.Once
+	lock          sync.Mutex
+}
+
+func (e *event) SetArg(key string, value any) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.externalEvent.Args == nil {
+		e.externalEvent.Args = make(map[string]any)
+	}
+	e.externalEvent.Args[key] = value
+}
+
+func (e *event) End() {
+	e.once.Do(func() {
+		end := e.recorder.sinceStart(time.Now())
+		e.lock.Lock()
+		duration := end - e.externalEvent.Timestamp
+		e.externalEvent.Duration = &duration
+		e.lock.Unlock()
+		e.recorder.add(e.externalEvent)
+	})
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Start(string, string, int) Event {
+	return nopEvent{}
+}
+
+func (nopRecorder) SetThreadName(int, string) {}
+
+func (nopRecorder) Write(writer io.Writer) error {
+	return writeTrace(writer, nil)
+}
+
+type nopEvent struct{}
+
+func (nopEvent) SetArg(string, any) {}
+
+func (nopEvent) End() {}
+
+type externalTrace struct {
+	TraceEvents     []*externalEvent `json:"traceEvents"`
+	DisplayTimeUnit string           `json:"displayTimeUnit"`
+}
+
+type externalEvent struct {
+	Name     string `json:"name"`
+	Category string `json:"cat,omitempty"`
+	Phase    string `json:"ph"`
+	// Timestamp is in microseconds.
+	Timestamp int64 `json:"ts"`
+	// Duration is in microseconds, and only set for complete events.
+	Duration *int64         `json:"dur,omitempty"`
+	PID      int            `json:"pid"`
+	TID      int            `json:"tid"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+func writeTrace(writer io.Writer, events []*externalEvent) error {
+	if events == nil {
+		events = []*externalEvent{}
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(
+		&externalTrace{
+			TraceEvents:     events,
+			DisplayTimeUnit: "ms",
+		},
+	)
+}
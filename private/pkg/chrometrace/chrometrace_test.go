@@ -0,0 +1,74 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrometrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+	recorder := NewRecorder()
+	recorder.SetThreadName(1, "main")
+	outer := recorder.Start("outer", "test", 1)
+	inner := recorder.Start("inner", "test", 1)
+	inner.SetArg("size", 5)
+	inner.End()
+	// Not ended, not recorded.
+	_ = recorder.Start("unended", "test", 2)
+	outer.End()
+	outer.End()
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, recorder.Write(buffer))
+	var trace struct {
+		TraceEvents []struct {
+			Name     string         `json:"name"`
+			Category string         `json:"cat"`
+			Phase    string         `json:"ph"`
+			TID      int            `json:"tid"`
+			Duration *int64         `json:"dur"`
+			Args     map[string]any `json:"args"`
+		} `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &trace))
+	require.Len(t, trace.TraceEvents, 3)
+	assert.Equal(t, "thread_name", trace.TraceEvents[0].Name)
+	assert.Equal(t, "M", trace.TraceEvents[0].Phase)
+	assert.Equal(t, map[string]any{"name": "main"}, trace.TraceEvents[0].Args)
+	assert.Equal(t, "inner", trace.TraceEvents[1].Name)
+	assert.Equal(t, "X", trace.TraceEvents[1].Phase)
+	assert.Equal(t, "test", trace.TraceEvents[1].Category)
+	assert.Equal(t, 1, trace.TraceEvents[1].TID)
+	assert.NotNil(t, trace.TraceEvents[1].Duration)
+	assert.Equal(t, map[string]any{"size": float64(5)}, trace.TraceEvents[1].Args)
+	assert.Equal(t, "outer", trace.TraceEvents[2].Name)
+}
+
+func TestNopRecorder(t *testing.T) {
+	t.Parallel()
+	recorder := NewNopRecorder()
+	event := recorder.Start("event", "test", 1)
+	event.SetArg("key", "value")
+	event.End()
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, recorder.Write(buffer))
+	assert.JSONEq(t, `{"traceEvents":[],"displayTimeUnit":"ms"}`, buffer.String())
+}
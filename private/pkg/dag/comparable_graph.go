@@ -122,6 +122,42 @@ func (g *ComparableGraph[Value]) DOTString(valueToString func(Value) string) (st
 	return g.Graph().DOTString(valueToString)
 }
 
+// MermaidString returns a Mermaid flowchart representation of the graph.
+//
+// valueToString is used to print out the label for each node.
+//
+// Unlike DOTString, this does not walk the edges from the source nodes, and
+// can be used for graphs with cycles.
+//
+// https://mermaid.js.org/syntax/flowchart.html
+func (g *ComparableGraph[Value]) MermaidString(valueToString func(Value) string) (string, error) {
+	return g.Graph().MermaidString(valueToString)
+}
+
+// GraphMLString returns a GraphML representation of the graph.
+//
+// valueToString is used to print out the label for each node, which is stored in
+// the "label" data key.
+//
+// Unlike DOTString, this does not walk the edges from the source nodes, and
+// can be used for graphs with cycles.
+//
+// http://graphml.graphdrawing.org
+func (g *ComparableGraph[Value]) GraphMLString(valueToString func(Value) string) (string, error) {
+	return g.Graph().GraphMLString(valueToString)
+}
+
+// StronglyConnectedComponents returns the strongly connected components of the graph.
+//
+// Every node is in exactly one component. A component with more than one node, or a
+// single node with an edge to itself, contains a cycle.
+//
+// Components are returned in reverse topological order, that is a component is returned
+// before any component that has an edge to it. Within a component, nodes are in insertion order.
+func (g *ComparableGraph[Value]) StronglyConnectedComponents() ([][]Value, error) {
+	return g.Graph().StronglyConnectedComponents()
+}
+
 // Graph returns the underlying Graph that backs the ComparableGraph.
 //
 // Used for functions that need a Graph instead of a ComparableGraph.
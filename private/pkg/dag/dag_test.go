@@ -430,6 +430,80 @@ func TestDOTString(t *testing.T) {
 	)
 }
 
+func TestMermaidString(t *testing.T) {
+	t.Parallel()
+	graph := dag.NewComparableGraph[string]()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "a")
+	graph.AddEdge("b", "c")
+	graph.AddNode(`d"`)
+	mermaidString, err := graph.MermaidString(func(key string) string { return key })
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`flowchart LR
+  n0["a"]
+  n1["b"]
+  n2["c"]
+  n3["d#quot;"]
+  n0 --> n1
+  n1 --> n0
+  n1 --> n2`,
+		mermaidString,
+	)
+}
+
+func TestGraphMLString(t *testing.T) {
+	t.Parallel()
+	graph := dag.NewComparableGraph[string]()
+	graph.AddEdge("a", "b<")
+	graph.AddEdge("b<", "a")
+	graphMLString, err := graph.GraphMLString(func(key string) string { return key })
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="label" for="node" attr.name="label" attr.type="string"/>
+  <graph id="G" edgedefault="directed">
+    <node id="n0">
+      <data key="label">a</data>
+    </node>
+    <node id="n1">
+      <data key="label">b&lt;</data>
+    </node>
+    <edge source="n0" target="n1"/>
+    <edge source="n1" target="n0"/>
+  </graph>
+</graphml>`,
+		graphMLString,
+	)
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	t.Parallel()
+	graph := dag.NewComparableGraph[string]()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "c")
+	graph.AddEdge("c", "a")
+	graph.AddEdge("c", "d")
+	graph.AddEdge("d", "e")
+	graph.AddEdge("e", "e")
+	graph.AddNode("f")
+	components, err := graph.StronglyConnectedComponents()
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[][]string{
+			{"e"},
+			{"d"},
+			{"a", "b", "c"},
+			{"f"},
+		},
+		components,
+	)
+}
+
 func testTopoSortSuccess(
 	t *testing.T,
 	setupGraph func(*dag.ComparableGraph[string]),
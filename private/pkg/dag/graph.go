@@ -19,6 +19,9 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/bufbuild/buf/private/pkg/slicesext"
 	"github.com/bufbuild/buf/private/pkg/syserror"
@@ -305,6 +308,153 @@ func (g *Graph[Key, Value]) DOTString(valueToString func(Value) string) (string,
 	return buffer.String(), nil
 }
 
+// MermaidString returns a Mermaid flowchart representation of the graph.
+//
+// valueToString is used to print out the label for each node.
+//
+// Unlike DOTString, this does not walk the edges from the source nodes, and
+// can be used for graphs with cycles.
+//
+// https://mermaid.js.org/syntax/flowchart.html
+func (g *Graph[Key, Value]) MermaidString(valueToString func(Value) string) (string, error) {
+	if err := g.checkInit(); err != nil {
+		return "", err
+	}
+	keyToID := g.getKeyToID()
+	buffer := bytes.NewBuffer(nil)
+	_, _ = buffer.WriteString("flowchart LR\n")
+	for _, key := range g.keys {
+		value, err := g.getValueForKey(key)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(buffer, "  %s[\"%s\"]\n", keyToID[key], mermaidEscape(valueToString(value)))
+	}
+	for _, key := range g.keys {
+		for _, to := range g.keyToNode[key].outboundEdges {
+			_, _ = fmt.Fprintf(buffer, "  %s --> %s\n", keyToID[key], keyToID[to])
+		}
+	}
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+// GraphMLString returns a GraphML representation of the graph.
+//
+// valueToString is used to print out the label for each node, which is stored in
+// the "label" data key.
+//
+// Unlike DOTString, this does not walk the edges from the source nodes, and
+// can be used for graphs with cycles.
+//
+// http://graphml.graphdrawing.org
+func (g *Graph[Key, Value]) GraphMLString(valueToString func(Value) string) (string, error) {
+	if err := g.checkInit(); err != nil {
+		return "", err
+	}
+	keyToID := g.getKeyToID()
+	buffer := bytes.NewBuffer(nil)
+	_, _ = buffer.WriteString(xml.Header)
+	_, _ = buffer.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	_, _ = buffer.WriteString(`  <key id="label" for="node" attr.name="label" attr.type="string"/>` + "\n")
+	_, _ = buffer.WriteString(`  <graph id="G" edgedefault="directed">` + "\n")
+	for _, key := range g.keys {
+		value, err := g.getValueForKey(key)
+		if err != nil {
+			return "", err
+		}
+		label, err := xmlEscape(valueToString(value))
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(buffer, "    <node id=%q>\n", keyToID[key])
+		_, _ = fmt.Fprintf(buffer, "      <data key=\"label\">%s</data>\n", label)
+		_, _ = buffer.WriteString("    </node>\n")
+	}
+	for _, key := range g.keys {
+		for _, to := range g.keyToNode[key].outboundEdges {
+			_, _ = fmt.Fprintf(buffer, "    <edge source=%q target=%q/>\n", keyToID[key], keyToID[to])
+		}
+	}
+	_, _ = buffer.WriteString("  </graph>\n")
+	_, _ = buffer.WriteString("</graphml>")
+	return buffer.String(), nil
+}
+
+// StronglyConnectedComponents returns the strongly connected components of the graph.
+//
+// Every node is in exactly one component. A component with more than one node, or a
+// single node with an edge to itself, contains a cycle.
+//
+// Components are returned in reverse topological order, that is a component is returned
+// before any component that has an edge to it. Within a component, nodes are in insertion order.
+func (g *Graph[Key, Value]) StronglyConnectedComponents() ([][]Value, error) {
+	if err := g.checkInit(); err != nil {
+		return nil, err
+	}
+	// Tarjan's algorithm.
+	//
+	// https://en.wikipedia.org/wiki/Tarjan%27s_strongly_connected_components_algorithm
+	keyToInsertionIndex := make(map[Key]int, len(g.keys))
+	for i, key := range g.keys {
+		keyToInsertionIndex[key] = i
+	}
+	keyToIndex := make(map[Key]int, len(g.keys))
+	keyToLowLink := make(map[Key]int, len(g.keys))
+	onStack := make(map[Key]struct{})
+	var stack []Key
+	var components [][]Value
+	var visit func(Key) error
+	visit = func(key Key) error {
+		keyToIndex[key] = len(keyToIndex)
+		keyToLowLink[key] = keyToIndex[key]
+		stack = append(stack, key)
+		onStack[key] = struct{}{}
+		for _, to := range g.keyToNode[key].outboundEdges {
+			if _, ok := keyToIndex[to]; !ok {
+				if err := visit(to); err != nil {
+					return err
+				}
+				keyToLowLink[key] = min(keyToLowLink[key], keyToLowLink[to])
+			} else if _, ok := onStack[to]; ok {
+				keyToLowLink[key] = min(keyToLowLink[key], keyToIndex[to])
+			}
+		}
+		if keyToLowLink[key] != keyToIndex[key] {
+			return nil
+		}
+		var componentKeys []Key
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			delete(onStack, top)
+			componentKeys = append(componentKeys, top)
+			if top == key {
+				break
+			}
+		}
+		sort.Slice(
+			componentKeys,
+			func(i int, j int) bool {
+				return keyToInsertionIndex[componentKeys[i]] < keyToInsertionIndex[componentKeys[j]]
+			},
+		)
+		component, err := g.getValuesForKeys(componentKeys)
+		if err != nil {
+			return err
+		}
+		components = append(components, component)
+		return nil
+	}
+	for _, key := range g.keys {
+		if _, ok := keyToIndex[key]; !ok {
+			if err := visit(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return components, nil
+}
+
 // *** PRIVATE ***
 
 func (g *Graph[Key, Value]) checkInit() error {
@@ -323,6 +473,16 @@ func (g *Graph[Key, Value]) checkInit() error {
 	return nil
 }
 
+// getKeyToID returns a stable identifier for each key based on insertion order,
+// for formats that require node identifiers separate from their labels.
+func (g *Graph[Key, Value]) getKeyToID() map[Key]string {
+	keyToID := make(map[Key]string, len(g.keys))
+	for i, key := range g.keys {
+		keyToID[key] = "n" + strconv.Itoa(i)
+	}
+	return keyToID
+}
+
 func (g *Graph[Key, Value]) getValuesForKeys(keys []Key) ([]Value, error) {
 	return slicesext.MapError(keys, g.getValueForKey)
 }
@@ -511,3 +671,13 @@ func xmlEscape(s string) (string, error) {
 	}
 	return buffer.String(), nil
 }
+
+// mermaidEscape escapes characters that cannot appear in a quoted Mermaid label.
+//
+// https://mermaid.js.org/syntax/flowchart.html#entity-codes-to-escape-characters
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(
+		`"`, "#quot;",
+		"\n", " ",
+	).Replace(s)
+}
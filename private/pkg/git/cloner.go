@@ -56,7 +56,30 @@ func (c *cloner) CloneToBucket(
 	depth uint32,
 	writeBucket storage.WriteBucket,
 	options CloneToBucketOptions,
-) (retErr error) {
+) error {
+	_, err := c.cloneToBucket(ctx, envContainer, url, depth, writeBucket, options)
+	return err
+}
+
+func (c *cloner) CloneToBucketAndGetCommit(
+	ctx context.Context,
+	envContainer app.EnvContainer,
+	url string,
+	depth uint32,
+	writeBucket storage.WriteBucket,
+	options CloneToBucketOptions,
+) (string, error) {
+	return c.cloneToBucket(ctx, envContainer, url, depth, writeBucket, options)
+}
+
+func (c *cloner) cloneToBucket(
+	ctx context.Context,
+	envContainer app.EnvContainer,
+	url string,
+	depth uint32,
+	writeBucket storage.WriteBucket,
+	options CloneToBucketOptions,
+) (_ string, retErr error) {
 	defer slogext.DebugProfile(c.logger)()
 
 	var err error
@@ -67,18 +90,18 @@ func (c *cloner) CloneToBucket(
 		strings.HasPrefix(url, "git://"),
 		strings.HasPrefix(url, "file://"):
 	default:
-		return fmt.Errorf("invalid git url: %q", url)
+		return "", fmt.Errorf("invalid git url: %q", url)
 	}
 
 	if depth == 0 {
-		return errors.New("depth must be > 0")
+		return "", errors.New("depth must be > 0")
 	}
 
 	depthArg := strconv.Itoa(int(depth))
 
 	baseDir, err := tmp.NewDir(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func() {
 		retErr = errors.Join(retErr, baseDir.Close())
@@ -93,7 +116,7 @@ func (c *cloner) CloneToBucket(
 		execext.WithStderr(buffer),
 		execext.WithDir(baseDir.Path()),
 	); err != nil {
-		return newGitCommandError(err, buffer)
+		return "", newGitCommandError(err, buffer)
 	}
 
 	buffer.Reset()
@@ -105,7 +128,7 @@ func (c *cloner) CloneToBucket(
 		execext.WithStderr(buffer),
 		execext.WithDir(baseDir.Path()),
 	); err != nil {
-		return newGitCommandError(err, buffer)
+		return "", newGitCommandError(err, buffer)
 	}
 
 	var gitConfigAuthArgs []string
@@ -114,7 +137,7 @@ func (c *cloner) CloneToBucket(
 		// is only a flag on the parent git command, not on git fetch.
 		extraArgs, err := c.getArgsForHTTPSCommand(envContainer)
 		if err != nil {
-			return err
+			return "", err
 		}
 		gitConfigAuthArgs = append(gitConfigAuthArgs, extraArgs...)
 	}
@@ -122,7 +145,7 @@ func (c *cloner) CloneToBucket(
 	if strings.HasPrefix(url, "ssh://") {
 		envContainer, err = c.getEnvContainerWithGitSSHCommand(envContainer)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 	// First, try to fetch the fetchRef directly. If the ref is not found, we
@@ -148,7 +171,7 @@ func (c *cloner) CloneToBucket(
 	); err != nil {
 		// If the ref fetch failed, without a fallback, return the error.
 		if fallbackRef == "" {
-			return newGitCommandError(err, buffer)
+			return "", newGitCommandError(err, buffer)
 		}
 		// Failed to fetch the ref directly, try to fetch the fallback ref.
 		usedFallback = true
@@ -168,7 +191,7 @@ func (c *cloner) CloneToBucket(
 			execext.WithStderr(buffer),
 			execext.WithDir(baseDir.Path()),
 		); err != nil {
-			return newGitCommandError(err, buffer)
+			return "", newGitCommandError(err, buffer)
 		}
 	}
 
@@ -183,7 +206,7 @@ func (c *cloner) CloneToBucket(
 		execext.WithStderr(buffer),
 		execext.WithDir(baseDir.Path()),
 	); err != nil {
-		return newGitCommandError(err, buffer)
+		return "", newGitCommandError(err, buffer)
 	}
 	// Should checkout if the fallback was used or if the checkout ref is different
 	// from the fetch ref.
@@ -197,7 +220,7 @@ func (c *cloner) CloneToBucket(
 			execext.WithStderr(buffer),
 			execext.WithDir(baseDir.Path()),
 		); err != nil {
-			return newGitCommandError(err, buffer)
+			return "", newGitCommandError(err, buffer)
 		}
 	}
 
@@ -220,21 +243,38 @@ func (c *cloner) CloneToBucket(
 			execext.WithStderr(buffer),
 			execext.WithDir(baseDir.Path()),
 		); err != nil {
-			return newGitCommandError(err, buffer)
+			return "", newGitCommandError(err, buffer)
 		}
 	}
 
+	buffer.Reset()
+	stdout := bytes.NewBuffer(nil)
+	if err := execext.Run(
+		ctx,
+		"git",
+		execext.WithArgs("rev-parse", "HEAD"),
+		execext.WithEnv(app.Environ(envContainer)),
+		execext.WithStdout(stdout),
+		execext.WithStderr(buffer),
+		execext.WithDir(baseDir.Path()),
+	); err != nil {
+		return "", newGitCommandError(err, buffer)
+	}
+	commit := strings.TrimSpace(stdout.String())
+
 	// we do NOT want to read in symlinks
 	tmpReadWriteBucket, err := c.storageosProvider.NewReadWriteBucket(baseDir.Path())
 	if err != nil {
-		return err
+		return "", err
 	}
 	var readBucket storage.ReadBucket = tmpReadWriteBucket
 	if options.Matcher != nil {
 		readBucket = storage.FilterReadBucket(readBucket, options.Matcher)
 	}
-	_, err = storage.Copy(ctx, readBucket, writeBucket)
-	return err
+	if _, err := storage.Copy(ctx, readBucket, writeBucket); err != nil {
+		return "", err
+	}
+	return commit, nil
 }
 
 func (c *cloner) getArgsForHTTPSCommand(envContainer app.EnvContainer) ([]string, error) {
@@ -90,6 +90,16 @@ type Cloner interface {
 		writeBucket storage.WriteBucket,
 		options CloneToBucketOptions,
 	) error
+	// CloneToBucketAndGetCommit clones the repository to the bucket in the same manner
+	// as CloneToBucket, and returns the full SHA of the commit that was checked out.
+	CloneToBucketAndGetCommit(
+		ctx context.Context,
+		envContainer app.EnvContainer,
+		url string,
+		depth uint32,
+		writeBucket storage.WriteBucket,
+		options CloneToBucketOptions,
+	) (string, error)
 }
 
 // CloneToBucketOptions are options for Clone.
@@ -281,6 +291,53 @@ func CheckForUncommittedGitChanges(
 	return modifiedFiles, nil
 }
 
+// GetChangedFilesSinceRef returns the files within the given directory that were added,
+// modified, or deleted since the given ref. This includes committed, staged, and unstaged
+// changes, as well as untracked files that are not ignored.
+//
+// Renamed files are returned as both their old and new paths. The returned paths are
+// relative to the given directory, and files outside of the directory are not returned.
+func GetChangedFilesSinceRef(
+	ctx context.Context,
+	envContainer app.EnvContainer,
+	dir string,
+	ref string,
+) ([]string, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	environ := app.Environ(envContainer)
+	// Committed, staged, and unstaged changes. The working tree is compared against the ref.
+	if err := execext.Run(
+		ctx,
+		gitCommand,
+		execext.WithArgs("diff", "--name-only", "--no-renames", "--relative", "-z", ref, "--"),
+		execext.WithStdout(stdout),
+		execext.WithStderr(stderr),
+		execext.WithDir(dir),
+		execext.WithEnv(environ),
+	); err != nil {
+		return nil, fmt.Errorf("failed to get changes since %s: %w: %s", ref, err, stderr.String())
+	}
+	changedFiles := getAllNULSeparatedValuesFromBuffer(stdout)
+
+	stdout = bytes.NewBuffer(nil)
+	stderr = bytes.NewBuffer(nil)
+	// Untracked files, which git diff does not report.
+	if err := execext.Run(
+		ctx,
+		gitCommand,
+		execext.WithArgs("ls-files", "--others", "--exclude-standard", "-z"),
+		execext.WithStdout(stdout),
+		execext.WithStderr(stderr),
+		execext.WithDir(dir),
+		execext.WithEnv(environ),
+	); err != nil {
+		return nil, fmt.Errorf("failed to get untracked files: %w: %s", err, stderr.String())
+	}
+	changedFiles = append(changedFiles, getAllNULSeparatedValuesFromBuffer(stdout)...)
+	return changedFiles, nil
+}
+
 // GetCurrentHEADGitCommit returns the current HEAD commit based on the given directory.
 func GetCurrentHEADGitCommit(
 	ctx context.Context,
@@ -354,3 +411,13 @@ func getAllTrimmedLinesFromBuffer(buffer *bytes.Buffer) []string {
 	}
 	return lines
 }
+
+func getAllNULSeparatedValuesFromBuffer(buffer *bytes.Buffer) []string {
+	var values []string
+	for _, value := range strings.Split(buffer.String(), "\x00") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
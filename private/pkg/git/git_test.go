@@ -273,6 +273,32 @@ func TestGitCloner(t *testing.T) {
 		assert.True(t, errors.Is(err, fs.ErrNotExist))
 	})
 
+	t.Run("ref=<partial-commit>,get-commit", func(t *testing.T) {
+		t.Parallel()
+		revParseBytes, err := runStdout(ctx, container, "git", "-C", workDir, "rev-parse", "HEAD~")
+		require.NoError(t, err)
+		expectedCommit := strings.TrimSpace(string(revParseBytes))
+		storageosProvider := storageos.NewProvider(storageos.ProviderWithSymlinks())
+		cloner := NewCloner(slogtestext.NewLogger(t), storageosProvider, ClonerOptions{})
+		readWriteBucket := storagemem.NewReadWriteBucket()
+		commit, err := cloner.CloneToBucketAndGetCommit(
+			ctx,
+			container,
+			"file://"+filepath.Join(workDir, ".git"),
+			8,
+			readWriteBucket,
+			CloneToBucketOptions{
+				Matcher: storage.MatchPathExt(".proto"),
+				Name:    NewRefName(expectedCommit[:8]),
+			},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, expectedCommit, commit)
+		content, err := storage.ReadPath(ctx, readWriteBucket, "test.proto")
+		require.NoError(t, err)
+		assert.Equal(t, "// commit 1", string(content))
+	})
+
 	t.Run("ref=<commit>,branch=origin/remote-branch", func(t *testing.T) {
 		t.Parallel()
 		revParseBytes, err := runStdout(ctx, container, "git", "-C", originDir, "rev-parse", "remote-branch~")
@@ -300,6 +326,60 @@ func TestGitCloner(t *testing.T) {
 	})
 }
 
+func TestGetChangedFilesSinceRef(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	container, err := app.NewContainerForOS()
+	require.NoError(t, err)
+	repoPath := t.TempDir()
+	runCommand(ctx, t, container, "git", "-C", repoPath, "init")
+	runCommand(ctx, t, container, "git", "-C", repoPath, "config", "user.email", "tests@buf.build")
+	runCommand(ctx, t, container, "git", "-C", repoPath, "config", "user.name", "Buf go tests")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoPath, "proto", "a"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(repoPath, "proto", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# commit 0"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "proto", "a", "a.proto"), []byte("// commit 0"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "proto", "b", "b.proto"), []byte("// commit 0"), 0600))
+	runCommand(ctx, t, container, "git", "-C", repoPath, "add", ".")
+	runCommand(ctx, t, container, "git", "-C", repoPath, "commit", "-m", "commit 0")
+	runCommand(ctx, t, container, "git", "-C", repoPath, "tag", "base")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# commit 1"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "proto", "a", "a.proto"), []byte("// commit 1"), 0600))
+	runCommand(ctx, t, container, "git", "-C", repoPath, "commit", "-a", "-m", "commit 1")
+	// Staged rename.
+	runCommand(ctx, t, container, "git", "-C", repoPath, "mv", "proto/b/b.proto", "proto/b/c.proto")
+	// Untracked file.
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "proto", "b", "d.proto"), []byte("// untracked"), 0600))
+
+	changedFiles, err := GetChangedFilesSinceRef(ctx, container, repoPath, "base")
+	require.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]string{
+			"README.md",
+			"proto/a/a.proto",
+			"proto/b/b.proto",
+			"proto/b/c.proto",
+			"proto/b/d.proto",
+		},
+		changedFiles,
+	)
+	changedFiles, err = GetChangedFilesSinceRef(ctx, container, filepath.Join(repoPath, "proto"), "HEAD")
+	require.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]string{
+			"b/b.proto",
+			"b/c.proto",
+			"b/d.proto",
+		},
+		changedFiles,
+	)
+	_, err = GetChangedFilesSinceRef(ctx, container, repoPath, "nonexistent")
+	require.Error(t, err)
+}
+
 func readBucketForName(ctx context.Context, t *testing.T, path string, depth uint32, name Name, recurseSubmodules bool) storage.ReadBucket {
 	t.Helper()
 	storageosProvider := storageos.NewProvider(storageos.ProviderWithSymlinks())
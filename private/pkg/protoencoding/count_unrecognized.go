@@ -0,0 +1,56 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protoencoding
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CountUnrecognized returns the number of bytes of unrecognized fields in the message,
+// including the unrecognized fields of the messages it contains.
+func CountUnrecognized(message protoreflect.Message) int {
+	var count int
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsMap():
+			if !isMessageKind(field.MapValue().Kind()) {
+				break
+			}
+			// Note: Technically, each message entry could have had unrecognized field
+			// bytes, but they are discarded by the runtime. So we can only look at
+			// unrecognized fields in message values inside the map.
+			value.Map().Range(func(_ protoreflect.MapKey, mapValue protoreflect.Value) bool {
+				count += CountUnrecognized(mapValue.Message())
+				return true
+			})
+		case field.IsList():
+			if !isMessageKind(field.Kind()) {
+				break
+			}
+			list := value.List()
+			for i, length := 0, list.Len(); i < length; i++ {
+				count += CountUnrecognized(list.Get(i).Message())
+			}
+		case isMessageKind(field.Kind()):
+			count += CountUnrecognized(value.Message())
+		}
+		return true
+	})
+	return count + len(message.GetUnknown())
+}
+
+func isMessageKind(kind protoreflect.Kind) bool {
+	return kind == protoreflect.MessageKind || kind == protoreflect.GroupKind
+}
@@ -12,14 +12,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package bufcurl
+package protoencoding
 
 import (
 	"context"
 	"os"
 	"testing"
 
-	"github.com/bufbuild/buf/private/pkg/protoencoding"
 	"github.com/bufbuild/protocompile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,7 +38,7 @@ func TestCountUnrecognized(t *testing.T) {
 	msg := msgType.New()
 	msgData, err := os.ReadFile("./testdata/testdata.txt")
 	require.NoError(t, err)
-	err = protoencoding.NewTxtpbUnmarshaler(nil).Unmarshal(msgData, msg.Interface())
+	err = NewTxtpbUnmarshaler(nil).Unmarshal(msgData, msg.Interface())
 	require.NoError(t, err)
 	// Add some unrecognized bytes
 	unknownBytes := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 1}
@@ -68,6 +67,6 @@ func TestCountUnrecognized(t *testing.T) {
 		return true
 	})
 
-	unrecognized := countUnrecognized(msg)
+	unrecognized := CountUnrecognized(msg)
 	assert.Equal(t, expectedUnrecognized, unrecognized)
 }
@@ -17,16 +17,20 @@ package protostat
 import (
 	"context"
 	"io"
+	"sort"
 
 	"github.com/bufbuild/protocompile/ast"
 	"github.com/bufbuild/protocompile/parser"
 	"github.com/bufbuild/protocompile/reporter"
 )
 
+// MaxWidestOneofs is the maximum number of oneofs reported in Stats.WidestOneofs.
+const MaxWidestOneofs = 5
+
 // Stats represents some statistics about one or more Protobuf files.
 //
 // Note that as opposed to most structs in this codebase, we do not omitempty for
-// the fields for JSON or YAML.
+// the fields for JSON or YAML, except for the fields that are nil when there are no imports.
 type Stats struct {
 	NumFiles                 int `json:"num_files" yaml:"num_files"`
 	NumPackages              int `json:"num_packages" yaml:"num_packages"`
@@ -38,12 +42,64 @@ type Stats struct {
 	NumExtensions            int `json:"num_extensions" yaml:"num_extensions"`
 	NumServices              int `json:"num_services" yaml:"num_services"`
 	NumMethods               int `json:"num_methods" yaml:"num_methods"`
+	NumImports               int `json:"num_imports" yaml:"num_imports"`
+	// MaxMessageDepth is the deepest message nesting, where a top-level message has a depth of 1.
+	MaxMessageDepth int `json:"max_message_depth" yaml:"max_message_depth"`
+	// LargestMessage is the fully-qualified name of the message with the most fields.
+	LargestMessage NamedCount `json:"largest_message" yaml:"largest_message"`
+	// WidestOneofs are the fully-qualified names of the oneofs with the most fields,
+	// widest first, up to MaxWidestOneofs.
+	WidestOneofs []NamedCount `json:"widest_oneofs" yaml:"widest_oneofs"`
+	// MaxImportFanIn is the imported file path that is imported by the most files.
+	//
+	// This is nil if there are no imports.
+	MaxImportFanIn *NamedCount `json:"max_import_fan_in,omitempty" yaml:"max_import_fan_in,omitempty"`
+	// MaxImportFanOut is the file path that has the most imports.
+	//
+	// This is nil if there are no imports.
+	MaxImportFanOut *NamedCount `json:"max_import_fan_out,omitempty" yaml:"max_import_fan_out,omitempty"`
+	// Deprecated counts the elements that have the deprecated option set to true.
+	Deprecated ElementCounts `json:"deprecated" yaml:"deprecated"`
+	// Commented counts the elements that have a leading comment.
+	Commented ElementCounts `json:"commented" yaml:"commented"`
+}
+
+// NamedCount is a count associated with a name.
+type NamedCount struct {
+	Name  string `json:"name" yaml:"name"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// ElementCounts are counts of elements by kind.
+//
+// These are used for subsets of elements, such as deprecated or commented elements,
+// and can be compared to the corresponding totals in Stats.
+type ElementCounts struct {
+	Messages   int `json:"messages" yaml:"messages"`
+	Fields     int `json:"fields" yaml:"fields"`
+	Enums      int `json:"enums" yaml:"enums"`
+	EnumValues int `json:"enum_values" yaml:"enum_values"`
+	Extensions int `json:"extensions" yaml:"extensions"`
+	Services   int `json:"services" yaml:"services"`
+	Methods    int `json:"methods" yaml:"methods"`
+}
+
+// Total returns the total count across all element kinds.
+func (e ElementCounts) Total() int {
+	return e.Messages + e.Fields + e.Enums + e.EnumValues + e.Extensions + e.Services + e.Methods
+}
+
+// NumElements returns the total number of elements counted by ElementCounts.
+func (s *Stats) NumElements() int {
+	return s.NumMessages + s.NumFields + s.NumEnums + s.NumEnumValues + s.NumExtensions + s.NumServices + s.NumMethods
 }
 
 // FileWalker goes through all .proto files for GetStats.
 type FileWalker interface {
 	// Walk will invoke f for all .proto files for GetStats.
-	Walk(ctx context.Context, f func(io.Reader) error) error
+	//
+	// The filePath is used to name files in import statistics.
+	Walk(ctx context.Context, f func(filePath string, file io.Reader) error) error
 }
 
 // GetStats gathers some simple statistics about a set of Protobuf files.
@@ -51,47 +107,57 @@ type FileWalker interface {
 // See the packages protostatos and protostatstorage for helpers for the
 // os and storage packages.
 func GetStats(ctx context.Context, fileWalker FileWalker) (*Stats, error) {
-	handler := reporter.NewHandler(
-		reporter.NewReporter(
-			func(reporter.ErrorWithPos) error {
-				// never aborts
-				return nil
-			},
-			nil,
-		),
-	)
 	statsBuilder := newStatsBuilder()
-	if err := fileWalker.Walk(
+	if err := walkFiles(
 		ctx,
-		func(file io.Reader) error {
-			// This can return an error and non-nil AST.
-			// We do not need the filePath because we do not report errors.
-			astRoot, err := parser.Parse("", file, handler)
-			if astRoot == nil {
-				// No AST implies an I/O error trying to read the
-				// file contents. No stats to collect.
-				return err
-			}
-			if err != nil {
-				// There was a syntax error, but we still have a partial
-				// AST we can examine.
-				statsBuilder.NumFilesWithSyntaxErrors++
+		fileWalker,
+		func(fileNode *ast.FileNode, hasSyntaxErrors bool) {
+			examineFile(statsBuilder, fileNode, hasSyntaxErrors)
+		},
+	); err != nil {
+		return nil, err
+	}
+	return statsBuilder.build(), nil
+}
+
+// GetPackageStats gathers the same statistics as GetStats, but broken down by package.
+//
+// Files without a package are keyed by the empty string.
+func GetPackageStats(ctx context.Context, fileWalker FileWalker) (map[string]*Stats, error) {
+	packageToStatsBuilder := make(map[string]*statsBuilder)
+	if err := walkFiles(
+		ctx,
+		fileWalker,
+		func(fileNode *ast.FileNode, hasSyntaxErrors bool) {
+			packageName := getPackageName(fileNode)
+			statsBuilder, ok := packageToStatsBuilder[packageName]
+			if !ok {
+				statsBuilder = newStatsBuilder()
+				packageToStatsBuilder[packageName] = statsBuilder
 			}
-			examineFile(statsBuilder, astRoot)
-			return nil
+			examineFile(statsBuilder, fileNode, hasSyntaxErrors)
 		},
 	); err != nil {
 		return nil, err
 	}
-	statsBuilder.NumPackages = len(statsBuilder.packages)
-	return statsBuilder.Stats, nil
+	packageToStats := make(map[string]*Stats, len(packageToStatsBuilder))
+	for packageName, statsBuilder := range packageToStatsBuilder {
+		packageToStats[packageName] = statsBuilder.build()
+	}
+	return packageToStats, nil
 }
 
 // MergeStats merged multiple stats objects into one single Stats object.
 //
+// Counts are summed, and maximums are taken across all stats. Note that
+// this means that NumPackages, MaxImportFanIn and MaxImportFanOut are
+// approximations if the stats objects overlap in packages or imports.
+//
 // A new object is returned.
 func MergeStats(statsSlice ...*Stats) *Stats {
-	resultStats := &Stats{}
+	resultStats := &Stats{
+		WidestOneofs: []NamedCount{},
+	}
 	for _, stats := range statsSlice {
 		resultStats.NumFiles += stats.NumFiles
 		resultStats.NumPackages += stats.NumPackages
@@ -103,94 +169,385 @@ func MergeStats(statsSlice ...*Stats) *Stats {
 		resultStats.NumExtensions += stats.NumExtensions
 		resultStats.NumServices += stats.NumServices
 		resultStats.NumMethods += stats.NumMethods
+		resultStats.NumImports += stats.NumImports
+		resultStats.MaxMessageDepth = max(resultStats.MaxMessageDepth, stats.MaxMessageDepth)
+		resultStats.LargestMessage = maxNamedCount(resultStats.LargestMessage, stats.LargestMessage)
+		resultStats.WidestOneofs = append(resultStats.WidestOneofs, stats.WidestOneofs...)
+		resultStats.MaxImportFanIn = maxNamedCountPointer(resultStats.MaxImportFanIn, stats.MaxImportFanIn)
+		resultStats.MaxImportFanOut = maxNamedCountPointer(resultStats.MaxImportFanOut, stats.MaxImportFanOut)
+		resultStats.Deprecated = addElementCounts(resultStats.Deprecated, stats.Deprecated)
+		resultStats.Commented = addElementCounts(resultStats.Commented, stats.Commented)
 	}
+	resultStats.WidestOneofs = topNamedCounts(resultStats.WidestOneofs, MaxWidestOneofs)
 	return resultStats
 }
 
+// DiffStats returns the difference of the counts in to and from.
+//
+// Only the counts are compared. Names in the result are set from to.
+//
+// A new object is returned.
+func DiffStats(from *Stats, to *Stats) *Stats {
+	return &Stats{
+		NumFiles:                 to.NumFiles - from.NumFiles,
+		NumPackages:              to.NumPackages - from.NumPackages,
+		NumFilesWithSyntaxErrors: to.NumFilesWithSyntaxErrors - from.NumFilesWithSyntaxErrors,
+		NumMessages:              to.NumMessages - from.NumMessages,
+		NumFields:                to.NumFields - from.NumFields,
+		NumEnums:                 to.NumEnums - from.NumEnums,
+		NumEnumValues:            to.NumEnumValues - from.NumEnumValues,
+		NumExtensions:            to.NumExtensions - from.NumExtensions,
+		NumServices:              to.NumServices - from.NumServices,
+		NumMethods:               to.NumMethods - from.NumMethods,
+		NumImports:               to.NumImports - from.NumImports,
+		MaxMessageDepth:          to.MaxMessageDepth - from.MaxMessageDepth,
+		LargestMessage: NamedCount{
+			Name:  to.LargestMessage.Name,
+			Count: to.LargestMessage.Count - from.LargestMessage.Count,
+		},
+		// Individual oneofs cannot be meaningfully subtracted.
+		WidestOneofs:    []NamedCount{},
+		MaxImportFanIn:  diffNamedCountPointer(from.MaxImportFanIn, to.MaxImportFanIn),
+		MaxImportFanOut: diffNamedCountPointer(from.MaxImportFanOut, to.MaxImportFanOut),
+		Deprecated:      subtractElementCounts(to.Deprecated, from.Deprecated),
+		Commented:       subtractElementCounts(to.Commented, from.Commented),
+	}
+}
+
 type statsBuilder struct {
 	*Stats
 
-	packages map[ast.Identifier]struct{}
+	packages map[string]struct{}
+	// importPathToFanIn is the number of files that import a given path.
+	importPathToFanIn map[string]int
+	oneofs            []NamedCount
 }
 
 func newStatsBuilder() *statsBuilder {
 	return &statsBuilder{
-		Stats:    &Stats{},
-		packages: make(map[ast.Identifier]struct{}),
+		Stats:             &Stats{},
+		packages:          make(map[string]struct{}),
+		importPathToFanIn: make(map[string]int),
+	}
+}
+
+func (s *statsBuilder) build() *Stats {
+	s.NumPackages = len(s.packages)
+	for importPath, fanIn := range s.importPathToFanIn {
+		s.MaxImportFanIn = maxNamedCountPointer(s.MaxImportFanIn, &NamedCount{Name: importPath, Count: fanIn})
 	}
+	s.WidestOneofs = topNamedCounts(s.oneofs, MaxWidestOneofs)
+	return s.Stats
+}
+
+func walkFiles(
+	ctx context.Context,
+	fileWalker FileWalker,
+	f func(fileNode *ast.FileNode, hasSyntaxErrors bool),
+) error {
+	handler := reporter.NewHandler(
+		reporter.NewReporter(
+			func(reporter.ErrorWithPos) error {
+				// never aborts
+				return nil
+			},
+			nil,
+		),
+	)
+	return fileWalker.Walk(
+		ctx,
+		func(filePath string, file io.Reader) error {
+			// This can return an error and non-nil AST.
+			astRoot, err := parser.Parse(filePath, file, handler)
+			if astRoot == nil {
+				// No AST implies an I/O error trying to read the
+				// file contents. No stats to collect.
+				return err
+			}
+			// If there was a syntax error, we still have a partial
+			// AST we can examine.
+			f(astRoot, err != nil)
+			return nil
+		},
+	)
 }
 
-func examineFile(statsBuilder *statsBuilder, fileNode *ast.FileNode) {
+func examineFile(statsBuilder *statsBuilder, fileNode *ast.FileNode, hasSyntaxErrors bool) {
 	statsBuilder.NumFiles++
+	if hasSyntaxErrors {
+		statsBuilder.NumFilesWithSyntaxErrors++
+	}
+	packageName := getPackageName(fileNode)
+	if packageName != "" {
+		statsBuilder.packages[packageName] = struct{}{}
+	}
+	var numImports int
 	for _, decl := range fileNode.Decls {
 		switch decl := decl.(type) {
-		case *ast.PackageNode:
-			statsBuilder.packages[decl.Name.AsIdentifier()] = struct{}{}
+		case *ast.ImportNode:
+			numImports++
+			statsBuilder.importPathToFanIn[decl.Name.AsString()]++
 		case *ast.MessageNode:
-			examineMessage(statsBuilder, &decl.MessageBody)
+			examineMessage(statsBuilder, fileNode, packageName, decl, decl.Name.Val, &decl.MessageBody, 1)
 		case *ast.EnumNode:
-			examineEnum(statsBuilder, decl)
+			examineEnum(statsBuilder, fileNode, decl)
 		case *ast.ExtendNode:
-			examineExtend(statsBuilder, decl)
+			examineExtend(statsBuilder, fileNode, packageName, decl, 1)
 		case *ast.ServiceNode:
 			statsBuilder.NumServices++
+			examineElement(fileNode, decl, &statsBuilder.Deprecated.Services, &statsBuilder.Commented.Services)
 			for _, decl := range decl.Decls {
-				_, ok := decl.(*ast.RPCNode)
+				rpcNode, ok := decl.(*ast.RPCNode)
 				if ok {
 					statsBuilder.NumMethods++
+					examineElement(fileNode, rpcNode, &statsBuilder.Deprecated.Methods, &statsBuilder.Commented.Methods)
 				}
 			}
 		}
 	}
+	statsBuilder.NumImports += numImports
+	statsBuilder.MaxImportFanOut = maxNamedCountPointer(
+		statsBuilder.MaxImportFanOut,
+		&NamedCount{Name: fileNode.Name(), Count: numImports},
+	)
 }
 
-func examineMessage(statsBuilder *statsBuilder, messageBody *ast.MessageBody) {
+func examineMessage(
+	statsBuilder *statsBuilder,
+	fileNode *ast.FileNode,
+	prefix string,
+	messageNode ast.MessageDeclNode,
+	name string,
+	messageBody *ast.MessageBody,
+	depth int,
+) {
 	statsBuilder.NumMessages++
+	statsBuilder.MaxMessageDepth = max(statsBuilder.MaxMessageDepth, depth)
+	examineElement(fileNode, messageNode, &statsBuilder.Deprecated.Messages, &statsBuilder.Commented.Messages)
+	fullName := joinName(prefix, name)
+	var numFields int
 	for _, decl := range messageBody.Decls {
 		switch decl := decl.(type) {
 		case *ast.FieldNode, *ast.MapFieldNode:
-			statsBuilder.NumFields++
+			numFields++
+			examineField(statsBuilder, fileNode, decl.(ast.FieldDeclNode))
 		case *ast.GroupNode:
-			statsBuilder.NumFields++
-			examineMessage(statsBuilder, &decl.MessageBody)
+			numFields++
+			examineField(statsBuilder, fileNode, decl)
+			examineMessage(statsBuilder, fileNode, fullName, decl.AsMessage(), decl.Name.Val, &decl.MessageBody, depth+1)
 		case *ast.OneofNode:
+			var numOneofFields int
 			for _, ooDecl := range decl.Decls {
 				switch ooDecl := ooDecl.(type) {
 				case *ast.FieldNode:
-					statsBuilder.NumFields++
+					numOneofFields++
+					examineField(statsBuilder, fileNode, ooDecl)
 				case *ast.GroupNode:
-					statsBuilder.NumFields++
-					examineMessage(statsBuilder, &ooDecl.MessageBody)
+					numOneofFields++
+					examineField(statsBuilder, fileNode, ooDecl)
+					examineMessage(statsBuilder, fileNode, fullName, ooDecl.AsMessage(), ooDecl.Name.Val, &ooDecl.MessageBody, depth+1)
 				}
 			}
+			numFields += numOneofFields
+			statsBuilder.oneofs = append(
+				statsBuilder.oneofs,
+				NamedCount{Name: joinName(fullName, decl.Name.Val), Count: numOneofFields},
+			)
 		case *ast.MessageNode:
-			examineMessage(statsBuilder, &decl.MessageBody)
+			examineMessage(statsBuilder, fileNode, fullName, decl, decl.Name.Val, &decl.MessageBody, depth+1)
 		case *ast.EnumNode:
-			examineEnum(statsBuilder, decl)
+			examineEnum(statsBuilder, fileNode, decl)
 		case *ast.ExtendNode:
-			examineExtend(statsBuilder, decl)
+			examineExtend(statsBuilder, fileNode, fullName, decl, depth+1)
 		}
 	}
+	statsBuilder.NumFields += numFields
+	statsBuilder.LargestMessage = maxNamedCount(
+		statsBuilder.LargestMessage,
+		NamedCount{Name: fullName, Count: numFields},
+	)
+}
+
+func examineField(statsBuilder *statsBuilder, fileNode *ast.FileNode, fieldNode ast.FieldDeclNode) {
+	examineElement(fileNode, fieldNode, &statsBuilder.Deprecated.Fields, &statsBuilder.Commented.Fields)
 }
 
-func examineEnum(statsBuilder *statsBuilder, enumNode *ast.EnumNode) {
+func examineEnum(statsBuilder *statsBuilder, fileNode *ast.FileNode, enumNode *ast.EnumNode) {
 	statsBuilder.NumEnums++
+	examineElement(fileNode, enumNode, &statsBuilder.Deprecated.Enums, &statsBuilder.Commented.Enums)
 	for _, decl := range enumNode.Decls {
-		_, ok := decl.(*ast.EnumValueNode)
+		enumValueNode, ok := decl.(*ast.EnumValueNode)
 		if ok {
 			statsBuilder.NumEnumValues++
+			examineElement(fileNode, enumValueNode, &statsBuilder.Deprecated.EnumValues, &statsBuilder.Commented.EnumValues)
 		}
 	}
 }
 
-func examineExtend(statsBuilder *statsBuilder, extendNode *ast.ExtendNode) {
+func examineExtend(statsBuilder *statsBuilder, fileNode *ast.FileNode, prefix string, extendNode *ast.ExtendNode, depth int) {
 	for _, decl := range extendNode.Decls {
 		switch decl := decl.(type) {
 		case *ast.FieldNode:
 			statsBuilder.NumExtensions++
+			examineElement(fileNode, decl, &statsBuilder.Deprecated.Extensions, &statsBuilder.Commented.Extensions)
 		case *ast.GroupNode:
 			statsBuilder.NumExtensions++
-			examineMessage(statsBuilder, &decl.MessageBody)
+			examineElement(fileNode, decl, &statsBuilder.Deprecated.Extensions, &statsBuilder.Commented.Extensions)
+			// Group messages are defined in the scope enclosing the extend block.
+			examineMessage(statsBuilder, fileNode, prefix, decl.AsMessage(), decl.Name.Val, &decl.MessageBody, depth)
+		}
+	}
+}
+
+// examineElement increments deprecatedCount if the element is deprecated, and
+// commentedCount if the element has a leading comment.
+func examineElement(
+	fileNode *ast.FileNode,
+	node ast.NodeWithOptions,
+	deprecatedCount *int,
+	commentedCount *int,
+) {
+	if isDeprecated(node) {
+		*deprecatedCount++
+	}
+	if fileNode.NodeInfo(node).LeadingComments().Len() > 0 {
+		*commentedCount++
+	}
+}
+
+func isDeprecated(node ast.NodeWithOptions) bool {
+	// RangeOptions panics for fields and enum values without compact options.
+	switch node := node.(type) {
+	case ast.FieldDeclNode:
+		if node.GetOptions() == nil {
+			return false
+		}
+	case *ast.EnumValueNode:
+		if node.Options == nil {
+			return false
+		}
+	}
+	var deprecated bool
+	node.RangeOptions(
+		func(optionNode *ast.OptionNode) bool {
+			if optionNode.Name == nil || len(optionNode.Name.Parts) != 1 {
+				return true
+			}
+			part := optionNode.Name.Parts[0]
+			if part.IsExtension() || part.Value() != "deprecated" {
+				return true
+			}
+			identValueNode, ok := optionNode.Val.(ast.IdentValueNode)
+			if ok && identValueNode.AsIdentifier() == "true" {
+				deprecated = true
+				return false
+			}
+			return true
+		},
+	)
+	return deprecated
+}
+
+func getPackageName(fileNode *ast.FileNode) string {
+	for _, decl := range fileNode.Decls {
+		if packageNode, ok := decl.(*ast.PackageNode); ok {
+			return string(packageNode.Name.AsIdentifier())
 		}
 	}
+	return ""
+}
+
+func joinName(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// maxNamedCount returns the NamedCount with the larger count, breaking ties by name.
+//
+// NamedCounts with a zero count are ignored.
+func maxNamedCount(one NamedCount, two NamedCount) NamedCount {
+	if two.Count == 0 {
+		return one
+	}
+	if one.Name == "" || two.Count > one.Count || (two.Count == one.Count && two.Name < one.Name) {
+		return two
+	}
+	return one
+}
+
+// maxNamedCountPointer is maxNamedCount for optional NamedCounts.
+//
+// Nil is returned if both are nil or have a zero count.
+func maxNamedCountPointer(one *NamedCount, two *NamedCount) *NamedCount {
+	if two == nil || two.Count == 0 {
+		return one
+	}
+	if one == nil {
+		result := *two
+		return &result
+	}
+	result := maxNamedCount(*one, *two)
+	return &result
+}
+
+// diffNamedCountPointer returns the difference of the counts in to and from, named from to.
+//
+// Nil is returned if both are nil.
+func diffNamedCountPointer(from *NamedCount, to *NamedCount) *NamedCount {
+	if from == nil && to == nil {
+		return nil
+	}
+	var result NamedCount
+	if to != nil {
+		result = *to
+	}
+	if from != nil {
+		result.Count -= from.Count
+	}
+	return &result
+}
+
+// topNamedCounts returns the n largest NamedCounts, largest first and breaking ties by name.
+func topNamedCounts(namedCounts []NamedCount, n int) []NamedCount {
+	sorted := make([]NamedCount, len(namedCounts))
+	copy(sorted, namedCounts)
+	sort.SliceStable(
+		sorted,
+		func(i int, j int) bool {
+			if sorted[i].Count != sorted[j].Count {
+				return sorted[i].Count > sorted[j].Count
+			}
+			return sorted[i].Name < sorted[j].Name
+		},
+	)
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func addElementCounts(one ElementCounts, two ElementCounts) ElementCounts {
+	return ElementCounts{
+		Messages:   one.Messages + two.Messages,
+		Fields:     one.Fields + two.Fields,
+		Enums:      one.Enums + two.Enums,
+		EnumValues: one.EnumValues + two.EnumValues,
+		Extensions: one.Extensions + two.Extensions,
+		Services:   one.Services + two.Services,
+		Methods:    one.Methods + two.Methods,
+	}
+}
+
+func subtractElementCounts(one ElementCounts, two ElementCounts) ElementCounts {
+	return ElementCounts{
+		Messages:   one.Messages - two.Messages,
+		Fields:     one.Fields - two.Fields,
+		Enums:      one.Enums - two.Enums,
+		EnumValues: one.EnumValues - two.EnumValues,
+		Extensions: one.Extensions - two.Extensions,
+		Services:   one.Services - two.Services,
+		Methods:    one.Methods - two.Methods,
+	}
 }
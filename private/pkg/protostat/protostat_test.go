@@ -0,0 +1,254 @@
+// Copyright 2020-2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protostat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStats(t *testing.T) {
+	t.Parallel()
+	stats, err := GetStats(context.Background(), newTestFileWalker(testFiles))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		&Stats{
+			NumFiles:        3,
+			NumPackages:     2,
+			NumMessages:     5,
+			NumFields:       11,
+			NumEnums:        1,
+			NumEnumValues:   3,
+			NumExtensions:   1,
+			NumServices:     1,
+			NumMethods:      2,
+			NumImports:      3,
+			MaxMessageDepth: 3,
+			LargestMessage:  NamedCount{Name: "acme.v1.User", Count: 6},
+			WidestOneofs: []NamedCount{
+				{Name: "acme.v1.User.contact", Count: 2},
+				{Name: "acme.v1.User.Address.kind", Count: 1},
+			},
+			MaxImportFanIn:  &NamedCount{Name: "acme/v1/user.proto", Count: 2},
+			MaxImportFanOut: &NamedCount{Name: "acme/v1/service.proto", Count: 2},
+			Deprecated: ElementCounts{
+				Messages:   1,
+				Fields:     1,
+				EnumValues: 1,
+				Methods:    1,
+			},
+			Commented: ElementCounts{
+				Messages: 2,
+				Fields:   2,
+				Enums:    1,
+				Services: 1,
+			},
+		},
+		stats,
+	)
+}
+
+func TestGetPackageStats(t *testing.T) {
+	t.Parallel()
+	packageToStats, err := GetPackageStats(context.Background(), newTestFileWalker(testFiles))
+	require.NoError(t, err)
+	require.Len(t, packageToStats, 2)
+	acmeStats := packageToStats["acme.v1"]
+	require.NotNil(t, acmeStats)
+	assert.Equal(t, 2, acmeStats.NumFiles)
+	assert.Equal(t, 1, acmeStats.NumPackages)
+	assert.Equal(t, 4, acmeStats.NumMessages)
+	assert.Equal(t, &NamedCount{Name: "acme/v1/user.proto", Count: 1}, acmeStats.MaxImportFanIn)
+	otherStats := packageToStats["other.v1"]
+	require.NotNil(t, otherStats)
+	assert.Equal(t, 1, otherStats.NumFiles)
+	assert.Equal(t, 1, otherStats.NumMessages)
+	assert.Equal(t, 1, otherStats.NumExtensions)
+	// Merging the package stats gives the same counts as the stats for all files.
+	stats, err := GetStats(context.Background(), newTestFileWalker(testFiles))
+	require.NoError(t, err)
+	mergedStats := MergeStats(acmeStats, otherStats)
+	assert.Equal(t, stats.NumElements(), mergedStats.NumElements())
+	assert.Equal(t, stats.Deprecated, mergedStats.Deprecated)
+	assert.Equal(t, stats.Commented, mergedStats.Commented)
+	assert.Equal(t, stats.LargestMessage, mergedStats.LargestMessage)
+	assert.Equal(t, stats.WidestOneofs, mergedStats.WidestOneofs)
+	assert.Equal(t, stats.MaxImportFanOut, mergedStats.MaxImportFanOut)
+}
+
+func TestDiffStats(t *testing.T) {
+	t.Parallel()
+	from := &Stats{
+		NumFiles:       2,
+		NumMessages:    5,
+		LargestMessage: NamedCount{Name: "a.A", Count: 3},
+		Commented:      ElementCounts{Messages: 1},
+	}
+	to := &Stats{
+		NumFiles:       3,
+		NumMessages:    4,
+		LargestMessage: NamedCount{Name: "a.B", Count: 7},
+		Commented:      ElementCounts{Messages: 4},
+	}
+	diff := DiffStats(from, to)
+	assert.Equal(t, 1, diff.NumFiles)
+	assert.Equal(t, -1, diff.NumMessages)
+	assert.Equal(t, NamedCount{Name: "a.B", Count: 4}, diff.LargestMessage)
+	assert.Equal(t, ElementCounts{Messages: 3}, diff.Commented)
+	assert.Nil(t, diff.MaxImportFanIn)
+	to.MaxImportFanIn = &NamedCount{Name: "a.proto", Count: 2}
+	diff = DiffStats(from, to)
+	assert.Equal(t, &NamedCount{Name: "a.proto", Count: 2}, diff.MaxImportFanIn)
+}
+
+func TestGetStatsNoImports(t *testing.T) {
+	t.Parallel()
+	stats, err := GetStats(
+		context.Background(),
+		newTestFileWalker(
+			map[string]string{
+				"a.proto": `syntax = "proto3"; message Foo { string name = 1; }`,
+			},
+		),
+	)
+	require.NoError(t, err)
+	assert.Nil(t, stats.MaxImportFanIn)
+	assert.Nil(t, stats.MaxImportFanOut)
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "max_import_fan")
+}
+
+func TestGetStatsSyntaxError(t *testing.T) {
+	t.Parallel()
+	stats, err := GetStats(
+		context.Background(),
+		newTestFileWalker(
+			map[string]string{
+				"a.proto": `syntax = "proto3"; message Foo { string name = 1; `,
+			},
+		),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.NumFiles)
+	assert.Equal(t, 1, stats.NumFilesWithSyntaxErrors)
+}
+
+var testFiles = map[string]string{
+	"acme/v1/user.proto": `syntax = "proto3";
+
+package acme.v1;
+
+// User is a user.
+message User {
+  option deprecated = true;
+
+  // The id.
+  string id = 1;
+  string name = 2 [deprecated = true];
+  oneof contact {
+    string email = 3;
+    string phone = 4;
+  }
+  Address address = 5;
+  map<string, string> labels = 6;
+
+  message Address {
+    string line = 1;
+    oneof kind {
+      Point point = 2;
+    }
+    message Point {
+      double lat = 1;
+      double lng = 2;
+    }
+  }
+}
+
+// Status is a status.
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_ACTIVE = 1;
+  STATUS_OLD = 2 [deprecated = true];
+}
+`,
+	"acme/v1/service.proto": `syntax = "proto3";
+
+package acme.v1;
+
+import "acme/v1/user.proto";
+import "google/protobuf/empty.proto";
+
+// UserService manages users.
+service UserService {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc DeleteUser(GetUserRequest) returns (google.protobuf.Empty) {
+    option deprecated = true;
+  }
+}
+
+// GetUserRequest gets a user.
+message GetUserRequest {
+  // The id.
+  string id = 1;
+}
+`,
+	"other/v1/other.proto": `syntax = "proto2";
+
+package other.v1;
+
+import "acme/v1/user.proto";
+
+message Other {
+  extensions 100 to 200;
+}
+
+extend Other {
+  optional string extra = 100;
+}
+`,
+}
+
+type testFileWalker struct {
+	filePathToData map[string]string
+}
+
+func newTestFileWalker(filePathToData map[string]string) *testFileWalker {
+	return &testFileWalker{
+		filePathToData: filePathToData,
+	}
+}
+
+func (w *testFileWalker) Walk(ctx context.Context, f func(string, io.Reader) error) error {
+	filePaths := make([]string, 0, len(w.filePathToData))
+	for filePath := range w.filePathToData {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+	for _, filePath := range filePaths {
+		if err := f(filePath, strings.NewReader(w.filePathToData[filePath])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
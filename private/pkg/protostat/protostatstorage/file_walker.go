@@ -35,7 +35,7 @@ func newFileWalker(readBucket storage.ReadBucket) *fileWalker {
 	}
 }
 
-func (f *fileWalker) Walk(ctx context.Context, fu func(io.Reader) error) error {
+func (f *fileWalker) Walk(ctx context.Context, fu func(string, io.Reader) error) error {
 	return f.readBucket.Walk(
 		ctx,
 		"",
@@ -47,7 +47,7 @@ func (f *fileWalker) Walk(ctx context.Context, fu func(io.Reader) error) error {
 			defer func() {
 				retErr = errors.Join(retErr, readObjectCloser.Close())
 			}()
-			return fu(readObjectCloser)
+			return fu(objectInfo.Path(), readObjectCloser)
 		},
 	)
 }